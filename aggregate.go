@@ -0,0 +1,293 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultAggregationMTU is the largest datagram an AggregationClient sends
+// by default, which fits in an Ethernet frame with the IPv6 and UDP headers
+// and some room for tunnelling.
+const defaultAggregationMTU = 1432
+
+// maxSnapshotSize is the largest snapshot an AggregationServer will read
+// from a stream, to keep a bad length prefix from exhausting memory.
+const maxSnapshotSize = 64 << 20
+
+// AggregationServer receives snapshots from AggregationClients in other
+// processes and merges them into one registry with MergeSnapshot, so that a
+// deployment of many processes exports one set of series: counters summed,
+// histogram samples merged and gauges last-write-wins.
+//
+// On stream connections (TCP or Unix sockets) each snapshot is preceded by
+// its length as a big-endian uint32.  On packet connections (UDP or Unix
+// datagram sockets) each datagram is one snapshot, which clients split
+// across several to keep them within the MTU.
+type AggregationServer struct {
+	registry Registry
+	mutex    sync.Mutex // serialises merges so new metrics are registered once
+	closers  map[io.Closer]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// NewAggregationServer constructs a new AggregationServer which merges into
+// r.
+func NewAggregationServer(r Registry) *AggregationServer {
+	return &AggregationServer{registry: r, closers: make(map[io.Closer]struct{})}
+}
+
+// ListenAndServe listens on the given network ("tcp", "udp", "unix",
+// "unixgram", etc) and address and serves the connections, blocking until
+// the server is closed.
+func (s *AggregationServer) ListenAndServe(network, addr string) error {
+	switch network {
+	case "udp", "udp4", "udp6", "unixgram":
+		c, err := net.ListenPacket(network, addr)
+		if nil != err {
+			return err
+		}
+		return s.ServePacket(c)
+	}
+	l, err := net.Listen(network, addr)
+	if nil != err {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts stream connections on l and merges the snapshots read from
+// them, blocking until the server is closed or l fails.  It always returns a
+// non-nil error and closes l.
+func (s *AggregationServer) Serve(l net.Listener) error {
+	if !s.track(l) {
+		l.Close()
+		return fmt.Errorf("metrics: aggregation server closed")
+	}
+	defer s.untrack(l)
+	defer l.Close()
+	for {
+		conn, err := l.Accept()
+		if nil != err {
+			return err
+		}
+		if !s.track(conn) {
+			conn.Close()
+			continue
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer s.untrack(conn)
+			defer conn.Close()
+			if err := s.serveConn(conn); nil != err && io.EOF != err && !s.isClosed() {
+				log.Printf("metrics: aggregation server: %s: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// ServePacket reads datagrams from c and merges the snapshot in each,
+// blocking until the server is closed or c fails.  It always returns a
+// non-nil error and closes c.
+func (s *AggregationServer) ServePacket(c net.PacketConn) error {
+	if !s.track(c) {
+		c.Close()
+		return fmt.Errorf("metrics: aggregation server closed")
+	}
+	defer s.untrack(c)
+	defer c.Close()
+	buf := make([]byte, 65536)
+	for {
+		n, addr, err := c.ReadFrom(buf)
+		if nil != err {
+			return err
+		}
+		if err := s.merge(bytes.NewReader(buf[:n])); nil != err {
+			log.Printf("metrics: aggregation server: %s: %v", addr, err)
+		}
+	}
+}
+
+// Close stops the server, closing its listeners and connections, and waits
+// for any merges in progress to finish.
+func (s *AggregationServer) Close() error {
+	s.mutex.Lock()
+	s.closed = true
+	for c := range s.closers {
+		c.Close()
+	}
+	s.mutex.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+func (s *AggregationServer) isClosed() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.closed
+}
+
+// merge merges one snapshot into the server's registry.  A panic while
+// merging, which a malformed snapshot shouldn't be able to cause but would
+// otherwise take the whole process down, is returned as an error.
+func (s *AggregationServer) merge(r io.Reader) (err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	defer func() {
+		if p := recover(); nil != p {
+			err = fmt.Errorf("merging snapshot: %v", p)
+		}
+	}()
+	return MergeSnapshot(s.registry, r)
+}
+
+func (s *AggregationServer) serveConn(conn net.Conn) error {
+	var size [4]byte
+	for {
+		if _, err := io.ReadFull(conn, size[:]); nil != err {
+			return err
+		}
+		n := binary.BigEndian.Uint32(size[:])
+		if n > maxSnapshotSize {
+			return fmt.Errorf("snapshot of %d bytes is too large", n)
+		}
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(conn, buf); nil != err {
+			return err
+		}
+		if err := s.merge(bytes.NewReader(buf)); nil != err {
+			return err
+		}
+	}
+}
+
+func (s *AggregationServer) track(c io.Closer) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return false
+	}
+	s.closers[c] = struct{}{}
+	return true
+}
+
+func (s *AggregationServer) untrack(c io.Closer) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.closers, c)
+}
+
+// AggregationClientConfig provides a container with configuration parameters
+// for an AggregationClient.
+type AggregationClientConfig struct {
+	Network       string        // Network of the server, eg "tcp", "udp" or "unix"
+	Addr          string        // Address of the server
+	Registry      Registry      // Registry to be forwarded
+	FlushInterval time.Duration // Flush interval, for NewAggregationReporter
+	DialTimeout   time.Duration // Timeout for connecting to the server, if any
+	Filter        MetricFilter  // Selects the metrics to forward, defaulting to all
+	MTU           int           // Largest datagram to send on packet networks, splitting snapshots across several, defaulting to 1432
+}
+
+// AggregationClient forwards snapshots of a registry to an
+// AggregationServer.  Since counters and histograms are cleared as they're
+// encoded, what was recorded since the last snapshot is lost if sending one
+// fails.
+type AggregationClient struct {
+	config AggregationClientConfig
+	mutex  sync.Mutex
+	conn   net.Conn
+}
+
+// NewAggregationClient constructs a new AggregationClient.  It doesn't
+// connect until the first Send.
+func NewAggregationClient(c AggregationClientConfig) *AggregationClient {
+	return &AggregationClient{config: c}
+}
+
+// NewAggregationReporter constructs a new Reporter which sends a snapshot
+// to the server every flush interval, logging any errors.
+func NewAggregationReporter(c AggregationClientConfig) *Reporter {
+	return newExporterReporter(c.Registry, NewAggregationClient(c), SchedulerConfig{Interval: c.FlushInterval})
+}
+
+// Send encodes a snapshot of the registry and sends it to the server,
+// connecting first if need be, so that nothing is cleared while the server
+// can't be reached.  After an error the connection is closed and the next
+// Send reconnects.
+func (c *AggregationClient) Send() error {
+	return c.Export(c.config.Registry)
+}
+
+// Export sends a snapshot of r, as Send does of the configured registry, so
+// that an AggregationClient is an Exporter.
+func (c *AggregationClient) Export(r Registry) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if nil == c.conn {
+		conn, err := net.DialTimeout(c.config.Network, c.config.Addr, c.config.DialTimeout)
+		if nil != err {
+			return err
+		}
+		c.conn = conn
+	}
+
+	if c.packet() {
+		return c.exportDatagrams(r)
+	}
+	var buf bytes.Buffer
+	buf.Write(make([]byte, 4))
+	if err := EncodeSnapshot(c.config.Filter.registry(r), &buf); nil != err {
+		return err
+	}
+	b := buf.Bytes()
+	binary.BigEndian.PutUint32(b, uint32(len(b)-4))
+	if _, err := c.conn.Write(b); nil != err {
+		c.conn.Close()
+		c.conn = nil
+		return err
+	}
+	return nil
+}
+
+// exportDatagrams sends a snapshot of r in as many datagrams as it takes to
+// keep each within the MTU.  The caller must hold mutex.
+func (c *AggregationClient) exportDatagrams(r Registry) error {
+	mtu := c.config.MTU
+	if mtu <= 0 {
+		mtu = defaultAggregationMTU
+	}
+	datagrams, err := encodeSnapshotDatagrams(c.config.Filter.registry(r), mtu)
+	for _, b := range datagrams {
+		if _, werr := c.conn.Write(b); nil != werr {
+			c.conn.Close()
+			c.conn = nil
+			return werr
+		}
+	}
+	return err
+}
+
+// Close closes the connection to the server, if any.
+func (c *AggregationClient) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if nil == c.conn {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+func (c *AggregationClient) packet() bool {
+	return strings.HasPrefix(c.config.Network, "udp") || "unixgram" == c.config.Network
+}
@@ -0,0 +1,178 @@
+package metrics
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func waitForCount(t *testing.T, r Registry, name string, want int64) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if c, ok := r.Get(name).(Counter); ok && want == c.Count() {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("%s: never reached %v\n", name, want)
+}
+
+func TestAggregationTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	r := NewRegistry()
+	s := NewAggregationServer(r)
+	go s.Serve(l)
+	defer s.Close()
+
+	for i := 1; i <= 2; i++ {
+		worker := NewRegistry()
+		c := NewAggregationClient(AggregationClientConfig{Network: "tcp", Addr: l.Addr().String(), Registry: worker})
+		defer c.Close()
+		counter := NewRegisteredCounter("requests", worker)
+		h := NewRegisteredHistogram("latency", worker, NewUniformSample(100))
+		counter.Inc(int64(i))
+		h.Update(int64(i))
+		if err := c.Send(); nil != err {
+			t.Fatal(err)
+		}
+		counter.Inc(10)
+		if err := c.Send(); nil != err {
+			t.Fatal(err)
+		}
+	}
+	waitForCount(t, r, "requests", 23)
+	if c := r.Get("latency").(Histogram).Count(); 2 != c {
+		t.Errorf("latency: 2 != %v\n", c)
+	}
+}
+
+func TestAggregationUDP(t *testing.T) {
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	r := NewRegistry()
+	s := NewAggregationServer(r)
+	go s.ServePacket(c)
+	defer s.Close()
+
+	worker := NewRegistry()
+	NewRegisteredCounter("requests", worker).Inc(5)
+	client := NewAggregationClient(AggregationClientConfig{Network: "udp", Addr: c.LocalAddr().String(), Registry: worker})
+	defer client.Close()
+	if err := client.Send(); nil != err {
+		t.Fatal(err)
+	}
+	waitForCount(t, r, "requests", 5)
+}
+
+func TestAggregationUDPMalformed(t *testing.T) {
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	r := NewRegistry()
+	s := NewAggregationServer(r)
+	go s.ServePacket(c)
+	defer s.Close()
+
+	conn, err := net.Dial("udp", c.LocalAddr().String())
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	for _, m := range []dumpedMetric{
+		{Type: "histogram", Sample: "uniform", ReservoirSize: 1 << 62},
+		{Type: "histogram", Sample: "expdecay", ReservoirSize: 0},
+	} {
+		if _, err := conn.Write(encodeDump(registryDump{Version: 1, Metrics: map[string]dumpedMetric{"latency": m}})); nil != err {
+			t.Fatal(err)
+		}
+	}
+	if _, err := conn.Write(encodeDump(registryDump{Version: 1, Metrics: map[string]dumpedMetric{"requests": {Type: "counter", Value: 5}}})); nil != err {
+		t.Fatal(err)
+	}
+	waitForCount(t, r, "requests", 5)
+	if i := r.Get("latency"); nil != i {
+		t.Errorf("latency: %v\n", i)
+	}
+}
+
+func TestAggregationUDPSplit(t *testing.T) {
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	r := NewRegistry()
+	s := NewAggregationServer(r)
+	go s.ServePacket(c)
+	defer s.Close()
+
+	worker := NewRegistry()
+	for i := 0; i < 100; i++ {
+		NewRegisteredCounter(fmt.Sprintf("requests.%d", i), worker).Inc(int64(i))
+	}
+	h := NewRegisteredHistogram("latency", worker, NewUniformSample(1028))
+	for i := 0; i < 1000; i++ {
+		h.Update(int64(i))
+	}
+	NewRegisteredCounter("total", worker).Inc(3)
+	client := NewAggregationClient(AggregationClientConfig{Network: "udp", Addr: c.LocalAddr().String(), Registry: worker, MTU: 512})
+	defer client.Close()
+	if err := client.Send(); nil != err {
+		t.Fatal(err)
+	}
+	waitForCount(t, r, "total", 3)
+	waitForCount(t, r, "requests.99", 99)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if h, ok := r.Get("latency").(Histogram); ok && 1000 == h.Count() {
+			if sum := h.Sum(); 499500 != sum {
+				t.Errorf("h.Sum(): 499500 != %v\n", sum)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("latency: never reached 1000")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestAggregationUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metrics")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "aggregate.sock")
+	r := NewRegistry()
+	s := NewAggregationServer(r)
+	done := make(chan error)
+	go func() { done <- s.ListenAndServe("unix", path) }()
+
+	worker := NewRegistry()
+	NewRegisteredCounter("requests", worker).Inc(7)
+	client := NewAggregationClient(AggregationClientConfig{Network: "unix", Addr: path, Registry: worker})
+	defer client.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for err := client.Send(); nil != err; err = client.Send() {
+		if time.Now().After(deadline) {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	waitForCount(t, r, "requests", 7)
+
+	s.Close()
+	if err := <-done; nil == err {
+		t.Error("ListenAndServe: nil error after Close\n")
+	}
+}
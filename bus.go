@@ -0,0 +1,388 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BusPublisher publishes messages to a message bus such as NATS or MQTT.
+type BusPublisher interface {
+	Publish(subject string, payload []byte) error
+}
+
+// BusConfig provides a container with configuration parameters for the
+// message bus exporter, which publishes a message per metric, for
+// deployments which reach their metrics backend over a bus rather than HTTP.
+//
+// Each message is a JSON object with the metric's "name", its "tags" from
+// its Metadata, a Unix "timestamp" and its "values", as WriteJSON writes
+// them.  Its subject is Prefix, then the metric's name and then the values
+// of its tags in order of their keys, separated by Separator, unless Subject
+// is set.
+type BusConfig struct {
+	Publisher     BusPublisher                                     // Publisher, eg a NATSPublisher or MQTTPublisher
+	Registry      Registry                                         // Registry to be exported
+	FlushInterval time.Duration                                    // Flush interval
+	DurationUnit  time.Duration                                    // Time conversion unit for durations
+	Prefix        string                                           // Prefix to be prepended to subjects, eg "metrics."
+	Separator     string                                           // Separator of subject tokens, defaulting to "." as NATS uses; "/" for MQTT
+	Subject       func(name string, tags map[string]string) string // If set, derives subjects instead
+	Temporality   Temporality                                      // Whether to publish counts as they stand, the default, or since the last flush
+	Filter        MetricFilter                                     // Selects the metrics to publish, defaulting to all
+}
+
+// Bus is a blocking exporter function which publishes the metrics in
+// c.Registry every c.FlushInterval.
+func Bus(c BusConfig) {
+	NewBusReporter(c).Run(context.Background())
+}
+
+// NewBusReporter constructs a new Reporter which exports just as Bus does,
+// logging any errors.
+func NewBusReporter(c BusConfig) *Reporter {
+	return newExporterReporter(c.Registry, NewBusExporter(c), SchedulerConfig{Interval: c.FlushInterval})
+}
+
+// NewBusExporter constructs a new Exporter which publishes the registry it's
+// given as BusOnce does, for use with a Scheduler.  c.Registry and
+// c.FlushInterval are ignored.
+func NewBusExporter(c BusConfig) Exporter {
+	return ExporterFunc(func(r Registry) error {
+		c := c
+		c.Registry = r
+		return BusOnce(c)
+	})
+}
+
+// BusOnce publishes the metrics in c.Registry once, returning the first
+// error but trying every metric.
+func BusOnce(c BusConfig) error {
+	sep := c.Separator
+	if "" == sep {
+		sep = "."
+	}
+	now := time.Now().Unix()
+	var first error
+	EachWithMetadata(c.Temporality.registry(c.Filter.registry(c.Registry)), func(name string, i interface{}, md Metadata) {
+		var subject string
+		if nil != c.Subject {
+			subject = c.Subject(name, md.Tags)
+		} else {
+			subject = busSubject(c.Prefix, name, md.Tags, sep)
+		}
+		payload, err := json.Marshal(map[string]interface{}{
+			"name":      name,
+			"tags":      md.Tags,
+			"timestamp": now,
+			"values":    metricJSON(i, c.DurationUnit),
+		})
+		if nil == err {
+			err = c.Publisher.Publish(subject, payload)
+		}
+		if nil != err && nil == first {
+			first = err
+		}
+	})
+	return first
+}
+
+// busSubject returns the default subject for the given metric, its name
+// split into tokens on dots and each token stripped of the characters NATS
+// and MQTT reserve.
+func busSubject(prefix, name string, tags map[string]string, sep string) string {
+	tokens := strings.Split(name, ".")
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		tokens = append(tokens, tags[k])
+	}
+	for i, t := range tokens {
+		tokens[i] = strings.Map(func(r rune) rune {
+			switch r {
+			case ' ', '\t', '\r', '\n', '.', '/', '*', '>', '+', '#':
+				return '_'
+			}
+			return r
+		}, t)
+	}
+	return prefix + strings.Join(tokens, sep)
+}
+
+// NATSConfig provides a container with configuration parameters for a
+// NATSPublisher.
+type NATSConfig struct {
+	Addr        string        // Address of the NATS server, eg "localhost:4222"
+	User        string        // User, if the server requires one
+	Password    string        // Password, if the server requires one
+	Token       string        // Authentication token, if the server requires one
+	Name        string        // Name of the connection, shown by the server's monitoring
+	DialTimeout time.Duration // Timeout for connecting to the server, if any
+}
+
+// NATSPublisher publishes messages to a NATS server, speaking just enough of
+// its text protocol to do so without a client library.  It connects on the
+// first Publish and reconnects on the Publish after a failure.
+type NATSPublisher struct {
+	config NATSConfig
+	mutex  sync.Mutex
+	conn   net.Conn
+	err    error // an error the server sent asynchronously
+}
+
+// NewNATSPublisher constructs a new NATSPublisher.
+func NewNATSPublisher(c NATSConfig) *NATSPublisher {
+	return &NATSPublisher{config: c}
+}
+
+// Publish publishes a message with the given subject.
+func (p *NATSPublisher) Publish(subject string, payload []byte) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if nil != p.err {
+		err := p.err
+		p.closeLocked()
+		return err
+	}
+	if nil == p.conn {
+		if err := p.connect(); nil != err {
+			return err
+		}
+	}
+	msg := make([]byte, 0, len(subject)+len(payload)+32)
+	msg = append(msg, fmt.Sprintf("PUB %s %d\r\n", subject, len(payload))...)
+	msg = append(msg, payload...)
+	msg = append(msg, "\r\n"...)
+	if _, err := p.conn.Write(msg); nil != err {
+		p.closeLocked()
+		return err
+	}
+	return nil
+}
+
+// Close closes the connection to the server, if any.
+func (p *NATSPublisher) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.closeLocked()
+}
+
+func (p *NATSPublisher) closeLocked() error {
+	p.err = nil
+	if nil == p.conn {
+		return nil
+	}
+	err := p.conn.Close()
+	p.conn = nil
+	return err
+}
+
+// connect connects to the server, waiting for the PONG which says it
+// accepted the CONNECT, and starts a goroutine answering its PINGs.
+func (p *NATSPublisher) connect() error {
+	conn, err := net.DialTimeout("tcp", p.config.Addr, p.config.DialTimeout)
+	if nil != err {
+		return err
+	}
+	r := bufio.NewReader(conn)
+	if line, err := r.ReadString('\n'); nil != err {
+		conn.Close()
+		return err
+	} else if !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return fmt.Errorf("NATS: unexpected %q", strings.TrimSpace(line))
+	}
+	options, err := json.Marshal(map[string]interface{}{
+		"verbose":    false,
+		"pedantic":   false,
+		"name":       p.config.Name,
+		"user":       p.config.User,
+		"pass":       p.config.Password,
+		"auth_token": p.config.Token,
+		"lang":       "go",
+		"version":    "go-metrics",
+	})
+	if nil != err {
+		conn.Close()
+		return err
+	}
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\nPING\r\n", options); nil != err {
+		conn.Close()
+		return err
+	}
+	for {
+		line, err := r.ReadString('\n')
+		if nil != err {
+			conn.Close()
+			return err
+		}
+		line = strings.TrimSpace(line)
+		if "PONG" == line {
+			break
+		}
+		if strings.HasPrefix(line, "-ERR") {
+			conn.Close()
+			return fmt.Errorf("NATS: %s", line)
+		}
+	}
+	p.conn = conn
+	go p.read(conn, r)
+	return nil
+}
+
+// read answers the server's PINGs and remembers any error it sends, until
+// the connection is closed.
+func (p *NATSPublisher) read(conn net.Conn, r *bufio.Reader) {
+	for {
+		line, err := r.ReadString('\n')
+		if nil != err {
+			return
+		}
+		line = strings.TrimSpace(line)
+		p.mutex.Lock()
+		if conn == p.conn {
+			switch {
+			case "PING" == line:
+				conn.Write([]byte("PONG\r\n"))
+			case strings.HasPrefix(line, "-ERR"):
+				p.err = fmt.Errorf("NATS: %s", line)
+			}
+		}
+		p.mutex.Unlock()
+	}
+}
+
+// MQTTConfig provides a container with configuration parameters for an
+// MQTTPublisher.
+type MQTTConfig struct {
+	Addr        string        // Address of the MQTT broker, eg "localhost:1883"
+	ClientID    string        // Client identifier, which the broker may require to be unique
+	User        string        // User, if the broker requires one
+	Password    string        // Password, if the broker requires one
+	Retain      bool          // Whether the broker should retain the last message on each topic
+	DialTimeout time.Duration // Timeout for connecting to the broker, if any
+}
+
+// MQTTPublisher publishes messages to an MQTT 3.1.1 broker at QoS 0, speaking
+// just enough of the protocol to do so without a client library.  It
+// connects on the first Publish and reconnects on the Publish after a
+// failure.
+type MQTTPublisher struct {
+	config MQTTConfig
+	mutex  sync.Mutex
+	conn   net.Conn
+}
+
+// NewMQTTPublisher constructs a new MQTTPublisher.
+func NewMQTTPublisher(c MQTTConfig) *MQTTPublisher {
+	return &MQTTPublisher{config: c}
+}
+
+// Publish publishes a message with the given topic.
+func (p *MQTTPublisher) Publish(topic string, payload []byte) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if nil == p.conn {
+		if err := p.connect(); nil != err {
+			return err
+		}
+	}
+	header := byte(0x30)
+	if p.config.Retain {
+		header |= 0x01
+	}
+	body := appendMQTTString(nil, topic)
+	body = append(body, payload...)
+	if _, err := p.conn.Write(appendMQTTPacket(nil, header, body)); nil != err {
+		p.conn.Close()
+		p.conn = nil
+		return err
+	}
+	return nil
+}
+
+// Close disconnects from the broker, if connected.
+func (p *MQTTPublisher) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if nil == p.conn {
+		return nil
+	}
+	p.conn.Write([]byte{0xe0, 0x00}) // DISCONNECT
+	err := p.conn.Close()
+	p.conn = nil
+	return err
+}
+
+// connect connects to the broker and waits for it to accept.  The keep-alive
+// is disabled so that nothing need be sent between flushes.
+func (p *MQTTPublisher) connect() error {
+	conn, err := net.DialTimeout("tcp", p.config.Addr, p.config.DialTimeout)
+	if nil != err {
+		return err
+	}
+	flags := byte(0x02) // clean session
+	body := appendMQTTString(nil, "MQTT")
+	body = append(body, 4, 0, 0, 0) // level 4, flags, no keep-alive
+	body = appendMQTTString(body, p.config.ClientID)
+	if "" != p.config.User {
+		flags |= 0x80
+		body = appendMQTTString(body, p.config.User)
+	}
+	if "" != p.config.Password {
+		flags |= 0x40
+		body = appendMQTTString(body, p.config.Password)
+	}
+	body[7] = flags
+	if _, err := conn.Write(appendMQTTPacket(nil, 0x10, body)); nil != err {
+		conn.Close()
+		return err
+	}
+	var connack [4]byte
+	if _, err := io.ReadFull(conn, connack[:]); nil != err {
+		conn.Close()
+		return err
+	}
+	if 0x20 != connack[0] || 0 != connack[3] {
+		conn.Close()
+		return fmt.Errorf("MQTT: connection refused with code %d", connack[3])
+	}
+	p.conn = conn
+	return nil
+}
+
+func appendMQTTString(b []byte, s string) []byte {
+	var n [2]byte
+	binary.BigEndian.PutUint16(n[:], uint16(len(s)))
+	return append(append(b, n[:]...), s...)
+}
+
+// appendMQTTPacket appends a packet with the given fixed header byte and
+// body, its remaining length encoded in MQTT's variable-length integers.
+func appendMQTTPacket(b []byte, header byte, body []byte) []byte {
+	b = append(b, header)
+	n := len(body)
+	for {
+		d := byte(n % 128)
+		n /= 128
+		if 0 < n {
+			d |= 0x80
+		}
+		b = append(b, d)
+		if 0 == n {
+			break
+		}
+	}
+	return append(b, body...)
+}
@@ -0,0 +1,159 @@
+package metrics
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type recordingBusPublisher map[string]string
+
+func (p recordingBusPublisher) Publish(subject string, payload []byte) error {
+	p[subject] = string(payload)
+	return nil
+}
+
+func TestBusOnce(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("http.requests", r).Inc(5)
+	r.(*StandardRegistry).SetMetadata("http.requests", Metadata{Tags: map[string]string{"region": "us-east-1", "code": "2xx"}})
+	p := make(recordingBusPublisher)
+	if err := BusOnce(BusConfig{Publisher: p, Registry: r, Prefix: "metrics/", Separator: "/"}); nil != err {
+		t.Fatal(err)
+	}
+	payload, ok := p["metrics/http/requests/2xx/us-east-1"]
+	if !ok {
+		t.Fatalf("subjects: %v\n", p)
+	}
+	var msg struct {
+		Name   string
+		Tags   map[string]string
+		Values map[string]int64
+	}
+	if err := json.Unmarshal([]byte(payload), &msg); nil != err {
+		t.Fatal(err)
+	}
+	if "http.requests" != msg.Name || "2xx" != msg.Tags["code"] || 5 != msg.Values["count"] {
+		t.Errorf("payload: %s\n", payload)
+	}
+}
+
+func TestBusOncePrefixedRegistry(t *testing.T) {
+	r := NewPrefixedRegistry("http.")
+	NewRegisteredCounter("requests", r).Inc(5)
+	r.(MetadataRegistry).SetMetadata("requests", Metadata{Tags: map[string]string{"code": "2xx"}})
+	p := make(recordingBusPublisher)
+	if err := BusOnce(BusConfig{Publisher: p, Registry: r}); nil != err {
+		t.Fatal(err)
+	}
+	payload, ok := p["http.requests.2xx"]
+	if !ok {
+		t.Fatalf("subjects: %v\n", p)
+	}
+	if !strings.Contains(payload, `"tags":{"code":"2xx"}`) {
+		t.Errorf("payload: %s\n", payload)
+	}
+}
+
+func TestBusSubject(t *testing.T) {
+	if s := busSubject("m.", "a b.c*", map[string]string{"k": "v.w"}, "."); "m.a_b.c_.v_w" != s {
+		t.Errorf("subject: m.a_b.c_.v_w != %v\n", s)
+	}
+}
+
+func TestNATSPublisher(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if nil != err {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		io.WriteString(conn, "INFO {}\r\n")
+		for {
+			line, err := r.ReadString('\n')
+			if nil != err {
+				return
+			}
+			switch fields := strings.Fields(line); fields[0] {
+			case "PING":
+				io.WriteString(conn, "PONG\r\n")
+			case "PUB":
+				n, _ := strconv.Atoi(fields[2])
+				payload := make([]byte, n+2)
+				io.ReadFull(r, payload)
+				received <- fields[1] + " " + string(payload[:n])
+			}
+		}
+	}()
+
+	p := NewNATSPublisher(NATSConfig{Addr: l.Addr().String()})
+	defer p.Close()
+	if err := p.Publish("metrics.foo", []byte("hello")); nil != err {
+		t.Fatal(err)
+	}
+	if msg := <-received; "metrics.foo hello" != msg {
+		t.Errorf("message: metrics.foo hello != %v\n", msg)
+	}
+}
+
+func TestMQTTPublisher(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	received := make(chan []byte, 2)
+	go func() {
+		conn, err := l.Accept()
+		if nil != err {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			header, err := r.ReadByte()
+			if nil != err {
+				return
+			}
+			n, mul := 0, 1
+			for {
+				b, _ := r.ReadByte()
+				n += int(b&0x7f) * mul
+				mul *= 128
+				if 0 == b&0x80 {
+					break
+				}
+			}
+			body := make([]byte, n)
+			io.ReadFull(r, body)
+			received <- append([]byte{header}, body...)
+			if 0x10 == header {
+				conn.Write([]byte{0x20, 0x02, 0x00, 0x00})
+			}
+		}
+	}()
+
+	p := NewMQTTPublisher(MQTTConfig{Addr: l.Addr().String(), ClientID: "worker", User: "u", Password: "p"})
+	defer p.Close()
+	if err := p.Publish("metrics/foo", []byte("hello")); nil != err {
+		t.Fatal(err)
+	}
+	connect := <-received
+	if want := "\x10\x00\x04MQTT\x04\xc2\x00\x00\x00\x06worker\x00\x01u\x00\x01p"; want != string(connect) {
+		t.Errorf("CONNECT: %q != %q\n", want, connect)
+	}
+	if want, publish := "\x30\x00\x0bmetrics/foohello", <-received; want != string(publish) {
+		t.Errorf("PUBLISH: %q != %q\n", want, publish)
+	}
+}
@@ -0,0 +1,150 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+// CacheRecorder is what a cache records its activity into, so that cache
+// libraries can take one and report under the names CacheMetrics gives
+// rather than each inventing its own.
+type CacheRecorder interface {
+	Hit()
+	Miss()
+	Evict(int64)
+	SetSize(int64)
+	Load(func() error) error
+}
+
+// CacheStats are the cumulative statistics which a cache keeping its own
+// counts reports, for CacheMetrics.CaptureOnce.
+type CacheStats struct {
+	Hits, Misses, Evictions int64
+	Size                    int64
+}
+
+// CacheStatsReader is implemented by caches which keep their own counts.
+type CacheStatsReader interface {
+	CacheStats() CacheStats
+}
+
+// CacheMetrics is a CacheRecorder which records, under the name it's
+// constructed with followed by
+//
+//	.Hits        a Counter of the lookups which found an entry
+//	.Misses      a Counter of the lookups which didn't
+//	.Size        a Gauge of the entries, or bytes, held
+//	.Evictions   a Meter of the entries evicted
+//	.LoadTime    a Timer of the time taken to load entries on misses
+//	.LoadErrors  a Counter of the loads which failed
+type CacheMetrics struct {
+	Hits       Counter
+	Misses     Counter
+	Size       Gauge
+	Evictions  Meter
+	LoadTime   Timer
+	LoadErrors Counter
+
+	mutex sync.Mutex
+	last  CacheStats // As of the last CaptureOnce
+}
+
+// NewCacheMetrics constructs a new CacheMetrics and registers its metrics
+// in r.
+func NewCacheMetrics(name string, r Registry) *CacheMetrics {
+	return &CacheMetrics{
+		Hits:       GetOrRegisterCounter(name+".Hits", r),
+		Misses:     GetOrRegisterCounter(name+".Misses", r),
+		Size:       GetOrRegisterGauge(name+".Size", r),
+		Evictions:  GetOrRegisterMeter(name+".Evictions", r),
+		LoadTime:   GetOrRegisterTimer(name+".LoadTime", r),
+		LoadErrors: GetOrRegisterCounter(name+".LoadErrors", r),
+	}
+}
+
+// Hit records a lookup which found an entry.
+func (m *CacheMetrics) Hit() {
+	m.Hits.Inc(1)
+}
+
+// Miss records a lookup which didn't find an entry.
+func (m *CacheMetrics) Miss() {
+	m.Misses.Inc(1)
+}
+
+// Evict records n entries evicted.
+func (m *CacheMetrics) Evict(n int64) {
+	m.Evictions.Mark(n)
+}
+
+// SetSize records the number of entries, or bytes, the cache holds.
+func (m *CacheMetrics) SetSize(n int64) {
+	m.Size.Update(n)
+}
+
+// Load runs f, which loads an entry after a miss, timing it and counting
+// its failure if it returns an error, which Load returns.
+func (m *CacheMetrics) Load(f func() error) error {
+	t := time.Now()
+	err := f()
+	m.LoadTime.UpdateSince(t)
+	if nil != err {
+		m.LoadErrors.Inc(1)
+	}
+	return err
+}
+
+// Capture new values from a cache which keeps its own counts.  This is
+// designed to be called as a goroutine.
+func (m *CacheMetrics) Capture(s CacheStatsReader, d time.Duration) {
+	for _ = range time.Tick(d) {
+		m.CaptureOnce(s)
+	}
+}
+
+// CaptureOnce records the hits, misses and evictions a cache which keeps
+// its own counts has counted since the last capture, and its size.
+func (m *CacheMetrics) CaptureOnce(s CacheStatsReader) {
+	stats := s.CacheStats()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if stats.Hits < m.last.Hits || stats.Misses < m.last.Misses || stats.Evictions < m.last.Evictions {
+		m.last = CacheStats{} // reset
+	}
+	m.Hits.Inc(stats.Hits - m.last.Hits)
+	m.Misses.Inc(stats.Misses - m.last.Misses)
+	if n := stats.Evictions - m.last.Evictions; 0 < n {
+		m.Evictions.Mark(n)
+	}
+	m.Size.Update(stats.Size)
+	m.last = stats
+}
+
+// InstrumentedPool is a sync.Pool whose Gets are recorded in a CacheMetrics
+// as hits if they reuse a value and misses if they construct a new one.
+type InstrumentedPool struct {
+	pool    sync.Pool
+	new     func() interface{}
+	metrics *CacheMetrics
+}
+
+// NewInstrumentedPool constructs a new InstrumentedPool which constructs
+// values with f and registers its CacheMetrics in r.
+func NewInstrumentedPool(name string, r Registry, f func() interface{}) *InstrumentedPool {
+	return &InstrumentedPool{new: f, metrics: NewCacheMetrics(name, r)}
+}
+
+// Get returns a value from the pool, or a new one if it's empty.
+func (p *InstrumentedPool) Get() interface{} {
+	if v := p.pool.Get(); nil != v {
+		p.metrics.Hit()
+		return v
+	}
+	p.metrics.Miss()
+	return p.new()
+}
+
+// Put adds v to the pool.
+func (p *InstrumentedPool) Put(v interface{}) {
+	p.pool.Put(v)
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+)
+
+type cacheStats CacheStats
+
+func (s *cacheStats) CacheStats() CacheStats { return CacheStats(*s) }
+
+func TestCacheMetrics(t *testing.T) {
+	r := NewRegistry()
+	var c CacheRecorder = NewCacheMetrics("cache", r)
+	c.Hit()
+	c.Miss()
+	c.Evict(2)
+	c.SetSize(5)
+	c.Load(func() error { return nil })
+	if err := c.Load(func() error { return errors.New("down") }); nil == err {
+		t.Error("Load: want the error")
+	}
+	for name, want := range map[string]int64{
+		"cache.Hits":       1,
+		"cache.Misses":     1,
+		"cache.LoadErrors": 1,
+	} {
+		if count := r.Get(name).(Counter).Count(); want != count {
+			t.Errorf("%s: %v != %v\n", name, want, count)
+		}
+	}
+	if count := r.Get("cache.Evictions").(Meter).Count(); 2 != count {
+		t.Errorf("cache.Evictions: 2 != %v\n", count)
+	}
+	if size := r.Get("cache.Size").(Gauge).Value(); 5 != size {
+		t.Errorf("cache.Size: 5 != %v\n", size)
+	}
+	if count := r.Get("cache.LoadTime").(Timer).Count(); 2 != count {
+		t.Errorf("cache.LoadTime: 2 != %v\n", count)
+	}
+}
+
+func TestCacheMetricsCaptureOnce(t *testing.T) {
+	r := NewRegistry()
+	m := NewCacheMetrics("cache", r)
+	s := &cacheStats{Hits: 3, Misses: 1, Size: 10}
+	m.CaptureOnce(s)
+	s.Hits, s.Evictions = 5, 2
+	m.CaptureOnce(s)
+	if count := m.Hits.Count(); 5 != count {
+		t.Errorf("Hits: 5 != %v\n", count)
+	}
+	if count := m.Evictions.Count(); 2 != count {
+		t.Errorf("Evictions: 2 != %v\n", count)
+	}
+	*s = cacheStats{Hits: 1} // the cache was reset
+	m.CaptureOnce(s)
+	if count := m.Hits.Count(); 6 != count {
+		t.Errorf("Hits after reset: 6 != %v\n", count)
+	}
+	if size := m.Size.Value(); 0 != size {
+		t.Errorf("Size: 0 != %v\n", size)
+	}
+}
+
+func TestInstrumentedPool(t *testing.T) {
+	r := NewRegistry()
+	p := NewInstrumentedPool("pool", r, func() interface{} { return new([]byte) })
+	if nil == p.Get() {
+		t.Fatal("Get: want a new value")
+	}
+	if count := r.Get("pool.Misses").(Counter).Count(); 1 != count {
+		t.Errorf("pool.Misses: 1 != %v\n", count)
+	}
+}
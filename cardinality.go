@@ -0,0 +1,203 @@
+package metrics
+
+import (
+	"math"
+	"math/bits"
+	"sync"
+)
+
+// Cardinalities estimate the number of distinct string values observed, eg
+// unique users or keys, in bounded memory using HyperLogLog++.  Reporters
+// read them with Snapshot, and so report the number of distinct values
+// observed since construction or the last Clear, except through a
+// NewDeltaRegistry, which clears them so that each flush reports the number
+// observed since the last.
+type Cardinality interface {
+	Clear() Cardinality // atomically clears and returns a snapshot
+	Estimate() uint64
+	Observe(string)
+	Snapshot() Cardinality
+}
+
+// GetOrRegisterCardinality returns an existing Cardinality or constructs and
+// registers a new StandardCardinality.
+func GetOrRegisterCardinality(name string, r Registry) Cardinality {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	c, ok := r.GetOrRegister(name, NewCardinality).(Cardinality)
+	if !ok {
+		return registrationConflict(DuplicateMetric(name), NewCardinality()).(Cardinality)
+	}
+	return c
+}
+
+// GetCardinality returns the Cardinality registered under the given name, or
+// false if there is none or the metric registered there is not a
+// Cardinality.
+func GetCardinality(name string, r Registry) (Cardinality, bool) {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	c, ok := r.Get(name).(Cardinality)
+	return c, ok
+}
+
+// NewCardinality constructs a new StandardCardinality with a precision of
+// 12, which uses 4KiB and has a standard error of about 1.6%.
+func NewCardinality() Cardinality {
+	return NewCardinalityWithPrecision(12)
+}
+
+// NewCardinalityWithPrecision constructs a new StandardCardinality with 2^p
+// registers, where p is between 4 and 18.  Its standard error is about
+// 1.04/sqrt(2^p).
+func NewCardinalityWithPrecision(p uint8) Cardinality {
+	if p < 4 || 18 < p {
+		panic("NewCardinalityWithPrecision called with a precision outside 4 to 18")
+	}
+	if UseNilMetrics {
+		return NilCardinality{}
+	}
+	return &StandardCardinality{p: p, registers: make([]uint8, 1<<p)}
+}
+
+// NewRegisteredCardinality constructs and registers a new
+// StandardCardinality.
+func NewRegisteredCardinality(name string, r Registry) Cardinality {
+	c := NewCardinality()
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// CardinalitySnapshot is a read-only copy of another Cardinality.
+type CardinalitySnapshot uint64
+
+// Clear panics unless PanicOnSnapshotMutation is false.
+func (c CardinalitySnapshot) Clear() Cardinality {
+	snapshotMisuse("Clear called on a CardinalitySnapshot")
+	return c
+}
+
+// Estimate returns the estimate at the time the snapshot was taken.
+func (c CardinalitySnapshot) Estimate() uint64 { return uint64(c) }
+
+// Observe panics unless PanicOnSnapshotMutation is false.
+func (CardinalitySnapshot) Observe(string) {
+	snapshotMisuse("Observe called on a CardinalitySnapshot")
+}
+
+// Snapshot returns the snapshot.
+func (c CardinalitySnapshot) Snapshot() Cardinality { return c }
+
+// NilCardinality is a no-op Cardinality.
+type NilCardinality struct{}
+
+// Clear is a no-op.
+func (NilCardinality) Clear() Cardinality { return NilCardinality{} }
+
+// Estimate is a no-op.
+func (NilCardinality) Estimate() uint64 { return 0 }
+
+// Observe is a no-op.
+func (NilCardinality) Observe(string) {}
+
+// Snapshot is a no-op.
+func (NilCardinality) Snapshot() Cardinality { return NilCardinality{} }
+
+// StandardCardinality is the standard implementation of a Cardinality.  It's
+// HyperLogLog with the 64-bit hash and small-range linear counting of
+// Heule et al's HyperLogLog++, though without its empirical bias correction
+// or sparse representation.
+type StandardCardinality struct {
+	mutex     sync.Mutex
+	p         uint8
+	registers []uint8
+}
+
+// Clear resets the estimate to zero and returns a snapshot of it beforehand.
+func (c *StandardCardinality) Clear() Cardinality {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	s := CardinalitySnapshot(c.estimate())
+	for i := range c.registers {
+		c.registers[i] = 0
+	}
+	return s
+}
+
+// Estimate returns the approximate number of distinct values observed.
+func (c *StandardCardinality) Estimate() uint64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.estimate()
+}
+
+// Observe records the given value.
+func (c *StandardCardinality) Observe(v string) {
+	x := cardinalityHash(v)
+	i := x >> (64 - c.p)
+	rho := uint8(bits.LeadingZeros64(x<<c.p|1<<(c.p-1))) + 1
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.registers[i] < rho {
+		c.registers[i] = rho
+	}
+}
+
+// Snapshot returns a read-only copy of the estimate.
+func (c *StandardCardinality) Snapshot() Cardinality {
+	return CardinalitySnapshot(c.Estimate())
+}
+
+// cardinalityThresholds are the estimates below which HyperLogLog++ prefers
+// linear counting, by precision from 4.
+var cardinalityThresholds = [...]float64{
+	10, 20, 40, 80, 220, 400, 900, 1800, 3100, 6500, 11500, 20000, 50000, 120000, 350000,
+}
+
+// estimate must be called with the mutex held.
+func (c *StandardCardinality) estimate() uint64 {
+	m := float64(len(c.registers))
+	var sum float64
+	var zeros int
+	for _, r := range c.registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if 0 == r {
+			zeros++
+		}
+	}
+	var alpha float64
+	switch len(c.registers) {
+	case 16:
+		alpha = 0.673
+	case 32:
+		alpha = 0.697
+	case 64:
+		alpha = 0.709
+	default:
+		alpha = 0.7213 / (1 + 1.079/m)
+	}
+	e := alpha * m * m / sum
+	if 0 < zeros {
+		if lc := m * math.Log(m/float64(zeros)); lc <= cardinalityThresholds[c.p-4] {
+			return uint64(lc + 0.5)
+		}
+	}
+	return uint64(e + 0.5)
+}
+
+// cardinalityHash is 64-bit FNV-1a followed by MurmurHash3's finalizer,
+// which HyperLogLog needs to spread FNV's poorly-mixed high bits.
+func cardinalityHash(s string) uint64 {
+	h := fnv64a(s)
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
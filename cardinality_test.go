@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"strconv"
+	"testing"
+)
+
+func BenchmarkCardinality(b *testing.B) {
+	c := NewCardinality()
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Observe(keys[i%len(keys)])
+	}
+}
+
+func TestCardinality(t *testing.T) {
+	for _, n := range []int{0, 1, 10, 100, 1000, 10000, 100000} {
+		c := NewCardinality()
+		for i := 0; i < n; i++ {
+			c.Observe("user-" + strconv.Itoa(i))
+			c.Observe("user-" + strconv.Itoa(i))
+		}
+		// Five standard errors.
+		if e := float64(c.Estimate()); e < float64(n)*0.92 || float64(n)*1.08 < e {
+			t.Errorf("%v: c.Estimate(): %v\n", n, e)
+		}
+	}
+}
+
+func TestCardinalityPrecision(t *testing.T) {
+	for _, p := range []uint8{4, 8, 18} {
+		c := NewCardinalityWithPrecision(p)
+		for i := 0; i < 5000; i++ {
+			c.Observe(strconv.Itoa(i))
+		}
+		if e := c.Estimate(); e < 2500 || 7500 < e {
+			t.Errorf("%v: c.Estimate(): %v\n", p, e)
+		}
+	}
+	defer func() {
+		if nil == recover() {
+			t.Error("NewCardinalityWithPrecision(3) didn't panic")
+		}
+	}()
+	NewCardinalityWithPrecision(3)
+}
+
+func TestCardinalityClear(t *testing.T) {
+	c := NewCardinality()
+	c.Observe("foo")
+	c.Observe("bar")
+	if e := c.Clear().Estimate(); 2 != e {
+		t.Errorf("c.Clear().Estimate(): 2 != %v\n", e)
+	}
+	if e := c.Estimate(); 0 != e {
+		t.Errorf("c.Estimate(): 0 != %v\n", e)
+	}
+}
+
+func TestCardinalitySnapshot(t *testing.T) {
+	c := NewCardinality()
+	c.Observe("foo")
+	snapshot := c.Snapshot()
+	c.Observe("bar")
+	if e := snapshot.Estimate(); 1 != e {
+		t.Errorf("snapshot.Estimate(): 1 != %v\n", e)
+	}
+}
+
+func TestCardinalityReported(t *testing.T) {
+	r := NewRegistry()
+	c := GetOrRegisterCardinality("foo", r)
+	c.Observe("bar")
+	if _, ok := GetCardinality("foo", r); !ok {
+		t.Fatal(r.Get("foo"))
+	}
+	for i, want := range []float64{1, 1} {
+		points := CollectPoints(r, 0)
+		if 1 != len(points) || PointGauge != points[0].Kind || want != points[0].Value {
+			t.Errorf("%v: %+v\n", i, points)
+		}
+	}
+	for i, want := range []float64{1, 0} {
+		points := CollectPoints(NewDeltaRegistry(r), 0)
+		if 1 != len(points) || PointGauge != points[0].Kind || want != points[0].Value {
+			t.Errorf("delta %v: %+v\n", i, points)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// CatalogEntry describes one metric in a catalog of the metrics in a
+// registry, for publishing reference documentation generated from the code
+// which registers them.
+type CatalogEntry struct {
+	Name        string            `json:"name"`
+	Type        string            `json:"type"` // Name of the metric's interface, eg "Counter"
+	Unit        string            `json:"unit,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+}
+
+// Catalog returns an entry for each metric in r, in order of name, with its
+// unit, description and tags taken from its Metadata.
+func Catalog(r Registry) []CatalogEntry {
+	var entries []CatalogEntry
+	EachWithMetadata(r, func(name string, i interface{}, md Metadata) {
+		entries = append(entries, CatalogEntry{
+			Name:        name,
+			Type:        metricType(i),
+			Unit:        md.Unit,
+			Description: md.Description,
+			Tags:        md.Tags,
+		})
+	})
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries
+}
+
+// WriteCatalogJSON writes the Catalog of r to w as a JSON array.
+func WriteCatalogJSON(r Registry, w io.Writer) error {
+	entries := Catalog(r)
+	if nil == entries {
+		entries = []CatalogEntry{}
+	}
+	return json.NewEncoder(w).Encode(entries)
+}
+
+// WriteCatalogMarkdown writes the Catalog of r to w as a Markdown table with
+// a row for each metric.
+func WriteCatalogMarkdown(r Registry, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	escape := strings.NewReplacer("|", `\|`, "\r\n", " ", "\n", " ")
+	fmt.Fprintln(bw, "| Name | Type | Unit | Description | Tags |")
+	fmt.Fprintln(bw, "| --- | --- | --- | --- | --- |")
+	for _, e := range Catalog(r) {
+		fmt.Fprintf(
+			bw,
+			"| `%s` | %s | %s | %s | %s |\n",
+			escape.Replace(strings.Replace(e.Name, "`", "'", -1)),
+			e.Type,
+			escape.Replace(e.Unit),
+			escape.Replace(e.Description),
+			escape.Replace(catalogTags(e.Tags)),
+		)
+	}
+	return bw.Flush()
+}
+
+// catalogTags renders the given tags sorted by name, eg "env=prod, region=eu".
+func catalogTags(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for k, v := range tags {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
+
+// metricType returns the name of the interface of the metric i, eg "Counter",
+// or "" if it's not a metric.
+func metricType(i interface{}) string {
+	switch i.(type) {
+	case EnumGauge:
+		return "EnumGauge"
+	case Counter:
+		return "Counter"
+	case Uint64Counter:
+		return "Uint64Counter"
+	case BigCounter:
+		return "BigCounter"
+	case GaugeCounter:
+		return "GaugeCounter"
+	case Gauge:
+		return "Gauge"
+	case GaugeFloat64:
+		return "GaugeFloat64"
+	case MovingAverage:
+		return "MovingAverage"
+	case RateGauge:
+		return "RateGauge"
+	case Cardinality:
+		return "Cardinality"
+	case BoolGauge:
+		return "BoolGauge"
+	case InfoMetric:
+		return "InfoMetric"
+	case Histogram:
+		return "Histogram"
+	case HistogramFloat64:
+		return "HistogramFloat64"
+	case SummaryFloat64:
+		return "SummaryFloat64"
+	case Meter:
+		return "Meter"
+	case Timer:
+		return "Timer"
+	case TopK:
+		return "TopK"
+	case Healthcheck:
+		return "Healthcheck"
+	}
+	return ""
+}
@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func catalogRegistry() Registry {
+	r := NewRegistry()
+	NewRegisteredCounter("requests", r)
+	NewRegisteredTimer("latency", r)
+	NewRegisteredGaugeFloat64("load", r)
+	r.(MetadataRegistry).SetMetadata("requests", Metadata{
+		Description: "Requests served | by route",
+		Unit:        "requests",
+		Tags:        map[string]string{"region": "eu", "env": "prod"},
+	})
+	return r
+}
+
+func TestCatalog(t *testing.T) {
+	want := []CatalogEntry{
+		{Name: "latency", Type: "Timer"},
+		{Name: "load", Type: "GaugeFloat64"},
+		{
+			Name:        "requests",
+			Type:        "Counter",
+			Unit:        "requests",
+			Description: "Requests served | by route",
+			Tags:        map[string]string{"region": "eu", "env": "prod"},
+		},
+	}
+	if got := Catalog(catalogRegistry()); !reflect.DeepEqual(want, got) {
+		t.Errorf("%v != %v\n", want, got)
+	}
+}
+
+func TestWriteCatalogJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCatalogJSON(catalogRegistry(), &buf); nil != err {
+		t.Fatal(err)
+	}
+	var entries []CatalogEntry
+	if err := json.Unmarshal(buf.Bytes(), &entries); nil != err {
+		t.Fatal(err)
+	}
+	if 3 != len(entries) || "Requests served | by route" != entries[2].Description {
+		t.Errorf("entries: %v\n", entries)
+	}
+
+	buf.Reset()
+	WriteCatalogJSON(NewRegistry(), &buf)
+	if "[]\n" != buf.String() {
+		t.Errorf("empty catalog: %q\n", buf.String())
+	}
+}
+
+func TestWriteCatalogMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCatalogMarkdown(catalogRegistry(), &buf); nil != err {
+		t.Fatal(err)
+	}
+	want := "| Name | Type | Unit | Description | Tags |\n" +
+		"| --- | --- | --- | --- | --- |\n" +
+		"| `latency` | Timer |  |  |  |\n" +
+		"| `load` | GaugeFloat64 |  |  |  |\n" +
+		"| `requests` | Counter | requests | Requests served \\| by route | env=prod, region=eu |\n"
+	if want != buf.String() {
+		t.Errorf("%q != %q\n", want, buf.String())
+	}
+}
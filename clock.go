@@ -0,0 +1,82 @@
+package metrics
+
+import "time"
+
+// Clocks tell the time to the metrics which depend on it: meters, timers and
+// exponentially-decaying samples.  Substituting a fake Clock, such as the one
+// in the metricstest package, makes code using those metrics deterministic to
+// test.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Tickers deliver the time on a channel at regular intervals, like a
+// time.Ticker.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// DefaultClock is the Clock used by the metrics constructed without one.
+var DefaultClock Clock = SystemClock{}
+
+// SystemClock is a Clock which reads the system clock using the time package.
+type SystemClock struct{}
+
+// Now returns time.Now().
+func (SystemClock) Now() time.Time { return time.Now() }
+
+// NewTicker returns a Ticker wrapping time.NewTicker(d).
+func (SystemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+type systemTicker struct {
+	ticker *time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time { return t.ticker.C }
+
+func (t systemTicker) Stop() { t.ticker.Stop() }
+
+// ClockFunc is a Clock which reads the time from a user-supplied function,
+// for environments where time.Now isn't the best source.  Its Tickers are
+// the system's.
+type ClockFunc func() time.Time
+
+// Now returns f().
+func (f ClockFunc) Now() time.Time { return f() }
+
+// NewTicker returns a Ticker wrapping time.NewTicker(d).
+func (ClockFunc) NewTicker(d time.Duration) Ticker {
+	return SystemClock{}.NewTicker(d)
+}
+
+// MonotonicClock is a Clock whose times are measured on the monotonic clock
+// from the moment it was constructed, so that they never go backwards nor
+// jump when the wall clock is stepped, as it may be in virtualized
+// environments.  Unlike times from time.Now, they stay monotonic even once
+// their monotonic clock reading has been stripped, eg by Round(0) or
+// serialization, at the cost of drifting from the wall clock.
+type MonotonicClock struct {
+	start time.Time // with a monotonic clock reading
+	wall  time.Time // start without it
+}
+
+// NewMonotonicClock constructs a new MonotonicClock starting now.
+func NewMonotonicClock() *MonotonicClock {
+	now := time.Now()
+	return &MonotonicClock{start: now, wall: now.Round(0)}
+}
+
+// Now returns the start time plus the monotonic time elapsed since.
+func (c *MonotonicClock) Now() time.Time {
+	return c.wall.Add(time.Since(c.start))
+}
+
+// NewTicker returns a Ticker wrapping time.NewTicker(d), which is itself
+// driven by the monotonic clock.
+func (*MonotonicClock) NewTicker(d time.Duration) Ticker {
+	return SystemClock{}.NewTicker(d)
+}
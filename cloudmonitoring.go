@@ -0,0 +1,399 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// cloudMonitoringMaxSeries is the most time series Cloud Monitoring
+	// accepts in one request.
+	cloudMonitoringMaxSeries = 200
+
+	// cloudMonitoringMinInterval is how often Cloud Monitoring accepts a
+	// point for a time series.
+	cloudMonitoringMinInterval = 5 * time.Second
+)
+
+// CloudMonitoringConfig provides a container with configuration parameters
+// for the Google Cloud Monitoring (formerly Stackdriver) exporter, which
+// writes custom metrics through its REST API.
+//
+// Counters and meters become cumulative metrics starting when the client was
+// constructed, and other sums and gauges gauge metrics, all doubles.
+// Histograms and StandardTimers become gauge distributions of the values in
+// their samples, in exponential buckets given by BucketScale,
+// BucketGrowthFactor and BucketCount, or in the sample's own buckets for a
+// HistogramFloat64 backed by a BucketSampleFloat64.  SummaryFloat64s become
+// name.count and name.sum gauges.  Metric types are "custom.googleapis.com/"
+// followed by Prefix and the metric's name, and every series is labelled with
+// Labels and then the Tags from its metric's Metadata.
+type CloudMonitoringConfig struct {
+	ProjectID          string                 // Project to write to
+	ResourceType       string                 // Monitored resource type, defaulting to "global"
+	ResourceLabels     map[string]string      // Monitored resource labels, eg {"instance_id": "1234", "zone": "us-east1-b"}, defaulting to {"project_id": ProjectID}
+	Registry           Registry               // Registry to be exported
+	FlushInterval      time.Duration          // Flush interval, at least five seconds
+	DurationUnit       time.Duration          // Time conversion unit for durations
+	Prefix             string                 // Prefix to be prepended to metric names
+	Labels             map[string]string      // Labels added to every series
+	BucketScale        float64                // Upper bound of the first distribution bucket, defaulting to 1
+	BucketGrowthFactor float64                // Ratio of each bucket's upper bound to the last's, defaulting to 2
+	BucketCount        int                    // Number of finite distribution buckets, defaulting to 32
+	MaxRetries         int                    // Times to retry a failed request, defaulting to 3; negative for none
+	RetryInterval      time.Duration          // Wait before the first retry unless the server says, doubling for each after, defaulting to a second
+	Token              func() (string, error) // If set, returns an OAuth2 access token for each request
+	URL                string                 // Base URL of the API, defaulting to "https://monitoring.googleapis.com"
+	Client             *http.Client           // HTTP client, eg an authorising one from golang.org/x/oauth2/google, defaulting to http.DefaultClient
+	Filter             MetricFilter           // Selects the metrics to send, defaulting to all
+}
+
+// CloudMonitoring is a blocking exporter function which writes the metrics in
+// c.Registry to Cloud Monitoring every c.FlushInterval.
+func CloudMonitoring(c CloudMonitoringConfig) {
+	NewCloudMonitoringReporter(c).Run(context.Background())
+}
+
+// NewCloudMonitoringReporter constructs a new Reporter which exports just as
+// CloudMonitoring does, logging any errors.
+func NewCloudMonitoringReporter(c CloudMonitoringConfig) *Reporter {
+	return newExporterReporter(c.Registry, NewCloudMonitoringClient(c), SchedulerConfig{Interval: c.FlushInterval})
+}
+
+// CloudMonitoringClient writes metrics to Cloud Monitoring.
+type CloudMonitoringClient struct {
+	config   CloudMonitoringConfig
+	mutex    sync.Mutex
+	start    time.Time
+	lastSent time.Time
+	ints     []int64   // Buffer for the values of Histograms and Timers, reused across flushes
+	floats   []float64 // Buffer for the values of distributions, reused across flushes
+}
+
+// NewCloudMonitoringClient constructs a new CloudMonitoringClient.  The
+// cumulative metrics it writes start now.
+func NewCloudMonitoringClient(c CloudMonitoringConfig) *CloudMonitoringClient {
+	return &CloudMonitoringClient{config: c, start: time.Now()}
+}
+
+// Send writes the metrics in the registry once, in batches of as many time
+// series as Cloud Monitoring accepts in a request.  Since it accepts a point
+// per time series only every five seconds, Send returns an error without
+// writing if called sooner after the last.  A request which fails with a
+// network error, a 429 or a 5xx status is retried, after the delay in any
+// Retry-After header.
+func (g *CloudMonitoringClient) Send() error {
+	return g.Export(g.config.Registry)
+}
+
+// Export writes the metrics in r once, as Send does the configured
+// registry's, so that a CloudMonitoringClient is an Exporter.
+func (g *CloudMonitoringClient) Export(r Registry) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	now := time.Now()
+	if now.Sub(g.lastSent) < cloudMonitoringMinInterval {
+		return fmt.Errorf("Cloud Monitoring: points written less than %v apart", cloudMonitoringMinInterval)
+	}
+	g.lastSent = now
+	series := g.timeSeries(r, now)
+	sort.SliceStable(series, func(i, j int) bool { return series[i].Metric.Type < series[j].Metric.Type })
+	for 0 < len(series) {
+		n := len(series)
+		if n > cloudMonitoringMaxSeries {
+			n = cloudMonitoringMaxSeries
+		}
+		if err := g.post(series[:n]); nil != err {
+			return err
+		}
+		series = series[n:]
+	}
+	return nil
+}
+
+// cloudMonitoringSeries is one TimeSeries with one point, in the shape the
+// API takes.
+type cloudMonitoringSeries struct {
+	Metric struct {
+		Type   string            `json:"type"`
+		Labels map[string]string `json:"labels,omitempty"`
+	} `json:"metric"`
+	Resource struct {
+		Type   string            `json:"type"`
+		Labels map[string]string `json:"labels"`
+	} `json:"resource"`
+	MetricKind string                 `json:"metricKind"`
+	ValueType  string                 `json:"valueType"`
+	Points     []cloudMonitoringPoint `json:"points"`
+}
+
+type cloudMonitoringPoint struct {
+	Interval struct {
+		StartTime string `json:"startTime,omitempty"`
+		EndTime   string `json:"endTime"`
+	} `json:"interval"`
+	Value map[string]interface{} `json:"value"`
+}
+
+// timeSeries translates r.
+func (g *CloudMonitoringClient) timeSeries(r Registry, now time.Time) []cloudMonitoringSeries {
+	r = g.config.Filter.registry(r)
+	resourceType := g.config.ResourceType
+	if "" == resourceType {
+		resourceType = "global"
+	}
+	resourceLabels := g.config.ResourceLabels
+	if nil == resourceLabels {
+		resourceLabels = map[string]string{"project_id": g.config.ProjectID}
+	}
+	var series []cloudMonitoringSeries
+	add := func(name string, tags map[string]string, kind, valueType string, value map[string]interface{}) {
+		s := cloudMonitoringSeries{MetricKind: kind, ValueType: valueType}
+		s.Metric.Type = "custom.googleapis.com/" + cloudMonitoringName(g.config.Prefix+name)
+		s.Metric.Labels = make(map[string]string, len(g.config.Labels)+len(tags))
+		for k, v := range g.config.Labels {
+			s.Metric.Labels[prometheusName(k)] = v
+		}
+		for k, v := range tags {
+			s.Metric.Labels[prometheusName(k)] = v
+		}
+		s.Resource.Type, s.Resource.Labels = resourceType, resourceLabels
+		p := cloudMonitoringPoint{Value: value}
+		p.Interval.EndTime = now.UTC().Format(time.RFC3339Nano)
+		if "CUMULATIVE" == kind {
+			p.Interval.StartTime = g.start.UTC().Format(time.RFC3339Nano)
+		}
+		s.Points = []cloudMonitoringPoint{p}
+		series = append(series, s)
+	}
+	double := func(name string, tags map[string]string, kind string, v float64) {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return
+		}
+		add(name, tags, kind, "DOUBLE", map[string]interface{}{"doubleValue": v})
+	}
+
+	distributed := make(map[string]bool)
+	EachWithMetadata(r, func(name string, i interface{}, md Metadata) {
+		var d map[string]interface{}
+		switch metric := i.(type) {
+		case Histogram:
+			g.ints = AppendSampleValues(g.ints[:0], metric.Snapshot().Sample())
+			g.floats = g.floats[:0]
+			for _, v := range g.ints {
+				g.floats = append(g.floats, float64(v))
+			}
+			d = g.distribution(g.floats)
+		case HistogramFloat64:
+			h := metric.Snapshot()
+			if b, ok := h.Sample().(interface {
+				Buckets() ([]float64, []int64)
+			}); ok {
+				bounds, counts := b.Buckets()
+				d = cloudMonitoringBucketDistribution(h.Count(), h.Mean(), h.Variance(), bounds, counts)
+			} else {
+				g.floats = AppendSampleFloat64Values(g.floats[:0], h.Sample())
+				d = g.distribution(g.floats)
+			}
+		case Timer:
+			t, ok := metric.Snapshot().(*TimerSnapshot)
+			if !ok {
+				return
+			}
+			du := float64(timerDurationUnit(t, g.config.DurationUnit))
+			g.ints = AppendSampleValues(g.ints[:0], t.histogram.Sample())
+			g.floats = g.floats[:0]
+			for _, v := range g.ints {
+				g.floats = append(g.floats, float64(v)/du)
+			}
+			d = g.distribution(g.floats)
+		default:
+			return
+		}
+		distributed[name] = true
+		add(name, md.Tags, "GAUGE", "DISTRIBUTION", map[string]interface{}{"distributionValue": d})
+	})
+	for _, p := range CollectPoints(r, g.config.DurationUnit) {
+		if distributed[p.Name] {
+			continue
+		}
+		switch {
+		case PointSum == p.Kind && p.Monotonic:
+			double(p.Name, p.Tags, "CUMULATIVE", p.Value)
+		case PointSummary == p.Kind:
+			double(p.Name+".count", p.Tags, "GAUGE", float64(p.Count))
+			double(p.Name+".sum", p.Tags, "GAUGE", p.Sum)
+		default:
+			double(p.Name, p.Tags, "GAUGE", p.Value)
+		}
+	}
+	return series
+}
+
+// distribution returns a Distribution of the given values in the configured
+// exponential buckets.  Values which aren't finite are left out.
+func (g *CloudMonitoringClient) distribution(values []float64) map[string]interface{} {
+	scale, factor, n := g.config.BucketScale, g.config.BucketGrowthFactor, g.config.BucketCount
+	if scale <= 0 {
+		scale = 1
+	}
+	if factor <= 1 {
+		factor = 2
+	}
+	if n <= 0 {
+		n = 32
+	}
+	counts := make([]int64, n+2)
+	var count int64
+	var mean, m2 float64
+	for _, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			continue
+		}
+		count++
+		delta := v - mean
+		mean += delta / float64(count)
+		m2 += delta * (v - mean)
+		i := 0
+		if v >= scale {
+			i = 1 + int(math.Floor(math.Log(v/scale)/math.Log(factor)))
+			if i > n+1 {
+				i = n + 1
+			}
+		}
+		counts[i]++
+	}
+	return map[string]interface{}{
+		"count":                 strconv.FormatInt(count, 10),
+		"mean":                  mean,
+		"sumOfSquaredDeviation": m2,
+		"bucketOptions": map[string]interface{}{"exponentialBuckets": map[string]interface{}{
+			"numFiniteBuckets": n,
+			"growthFactor":     factor,
+			"scale":            scale,
+		}},
+		"bucketCounts": cloudMonitoringCounts(counts),
+	}
+}
+
+// cloudMonitoringBucketDistribution returns a Distribution in explicit
+// buckets with the given bounds.
+func cloudMonitoringBucketDistribution(count int64, mean, variance float64, bounds []float64, counts []int64) map[string]interface{} {
+	return map[string]interface{}{
+		"count":                 strconv.FormatInt(count, 10),
+		"mean":                  mean,
+		"sumOfSquaredDeviation": variance * float64(count),
+		"bucketOptions": map[string]interface{}{"explicitBuckets": map[string]interface{}{
+			"bounds": bounds,
+		}},
+		"bucketCounts": cloudMonitoringCounts(counts),
+	}
+}
+
+// cloudMonitoringCounts formats bucket counts as the strings JSON int64s are
+// encoded as, leaving off trailing zeroes as the API allows.
+func cloudMonitoringCounts(counts []int64) []string {
+	n := len(counts)
+	for 0 < n && 0 == counts[n-1] {
+		n--
+	}
+	s := make([]string, n)
+	for i, c := range counts[:n] {
+		s[i] = strconv.FormatInt(c, 10)
+	}
+	return s
+}
+
+// cloudMonitoringName replaces the characters Cloud Monitoring doesn't allow
+// in metric types with underscores.
+func cloudMonitoringName(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || '_' == c || '.' == c || '/' == c) {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// post writes the given time series, retrying as configured.
+func (g *CloudMonitoringClient) post(series []cloudMonitoringSeries) error {
+	body, err := json.Marshal(map[string]interface{}{"timeSeries": series})
+	if nil != err {
+		return err
+	}
+	base := g.config.URL
+	if "" == base {
+		base = "https://monitoring.googleapis.com"
+	}
+	u := fmt.Sprintf("%s/v3/projects/%s/timeSeries", strings.TrimSuffix(base, "/"), g.config.ProjectID)
+	retries := g.config.MaxRetries
+	if 0 == retries {
+		retries = 3
+	}
+	wait := g.config.RetryInterval
+	if wait <= 0 {
+		wait = time.Second
+	}
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := g.postOnce(u, body)
+		if nil == err || retryAfter < 0 || attempt >= retries {
+			return err
+		}
+		if 0 < retryAfter {
+			time.Sleep(retryAfter)
+		} else {
+			time.Sleep(wait)
+		}
+		wait *= 2
+	}
+}
+
+// postOnce makes one request.  If it fails it returns how long the server
+// asked to wait before retrying, zero if it didn't say, or a negative
+// duration if it's not worth retrying.
+func (g *CloudMonitoringClient) postOnce(u string, body []byte) (time.Duration, error) {
+	req, err := http.NewRequest("POST", u, bytes.NewReader(body))
+	if nil != err {
+		return -1, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if nil != g.config.Token {
+		token, err := g.config.Token()
+		if nil != err {
+			return -1, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	client := g.config.Client
+	if nil == client {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if nil != err {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		err := fmt.Errorf("Cloud Monitoring: %s: %s", resp.Status, bytes.TrimSpace(b))
+		if http.StatusTooManyRequests != resp.StatusCode && resp.StatusCode < 500 {
+			return -1, err
+		}
+		if seconds, e := strconv.Atoi(resp.Header.Get("Retry-After")); nil == e && 0 < seconds {
+			return time.Duration(seconds) * time.Second, err
+		}
+		return 0, err
+	}
+	return 0, nil
+}
@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCloudMonitoring(t *testing.T) {
+	var paths, auths []string
+	var body struct{ TimeSeries []cloudMonitoringSeries }
+	failures := 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if 0 < failures {
+			failures--
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		paths = append(paths, req.URL.Path)
+		auths = append(auths, req.Header.Get("Authorization"))
+		b, _ := ioutil.ReadAll(req.Body)
+		if err := json.Unmarshal(b, &body); nil != err {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	r := NewRegistry()
+	NewRegisteredCounter("requests", r).Inc(5)
+	NewRegisteredGaugeFloat64("load", r).Update(0.5)
+	r.(*StandardRegistry).SetMetadata("load", Metadata{Tags: map[string]string{"cpu": "0"}})
+	h := NewRegisteredHistogram("sizes", r, NewUniformSample(100))
+	for _, v := range []int64{0, 1, 3, 1000} {
+		h.Update(v)
+	}
+	hf := NewRegisteredHistogramFloat64("latency", r, NewBucketSampleFloat64([]float64{1, 2}))
+	hf.Update(1.5)
+	client := NewCloudMonitoringClient(CloudMonitoringConfig{
+		ProjectID:     "proj",
+		Registry:      r,
+		Labels:        map[string]string{"env": "test"},
+		BucketCount:   4,
+		RetryInterval: time.Millisecond,
+		Token:         func() (string, error) { return "token", nil },
+		URL:           server.URL,
+	})
+	if err := client.Send(); nil != err {
+		t.Fatal(err)
+	}
+	if err := client.Send(); nil == err {
+		t.Error("Send: nil error less than five seconds after the last\n")
+	}
+
+	if 1 != len(paths) || "/v3/projects/proj/timeSeries" != paths[0] || "Bearer token" != auths[0] {
+		t.Fatalf("requests: %v, %v\n", paths, auths)
+	}
+	series := make(map[string]cloudMonitoringSeries)
+	for _, s := range body.TimeSeries {
+		series[s.Metric.Type] = s
+	}
+	if 4 != len(series) {
+		t.Errorf("series: 4 != %v\n", len(series))
+	}
+	s := series["custom.googleapis.com/requests"]
+	if "CUMULATIVE" != s.MetricKind || "" == s.Points[0].Interval.StartTime || 5.0 != s.Points[0].Value["doubleValue"] {
+		t.Errorf("requests: %+v\n", s)
+	}
+	if "global" != s.Resource.Type || "proj" != s.Resource.Labels["project_id"] || "test" != s.Metric.Labels["env"] {
+		t.Errorf("requests: %+v\n", s)
+	}
+	s = series["custom.googleapis.com/load"]
+	if "GAUGE" != s.MetricKind || "" != s.Points[0].Interval.StartTime || "0" != s.Metric.Labels["cpu"] {
+		t.Errorf("load: %+v\n", s)
+	}
+	d := series["custom.googleapis.com/sizes"].Points[0].Value["distributionValue"].(map[string]interface{})
+	if "4" != d["count"] || "[1 1 1 0 0 1]" != fmt.Sprint(d["bucketCounts"]) {
+		t.Errorf("sizes: %v\n", d)
+	}
+	d = series["custom.googleapis.com/latency"].Points[0].Value["distributionValue"].(map[string]interface{})
+	if "1" != d["count"] || "[0 1]" != fmt.Sprint(d["bucketCounts"]) || "map[explicitBuckets:map[bounds:[1 2]]]" != fmt.Sprint(d["bucketOptions"]) {
+		t.Errorf("latency: %v\n", d)
+	}
+}
@@ -0,0 +1,209 @@
+package metrics
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PointKind says how a Point should be interpreted, following the data model
+// shared by OpenTelemetry and OpenCensus.
+type PointKind int
+
+const (
+	// PointSum is a cumulative total.  Monotonic says whether it only grows.
+	PointSum PointKind = iota
+	// PointGauge is an instantaneous value.
+	PointGauge
+	// PointSummary is a distribution given by its count, sum and quantiles.
+	PointSummary
+)
+
+// Quantile is one quantile of a PointSummary, eg {0.99, 12.5}.
+type Quantile struct {
+	Quantile float64
+	Value    float64
+}
+
+// Point is the value of one metric at collection time, in a form which can be
+// handed to another metrics system.  Value is set for sums and gauges, Count,
+// Sum and Quantiles for summaries.  Unit is set for timers and otherwise taken
+// from the metric's Metadata, as are Description and Tags.  Exemplars are
+// those of metrics which are ExemplarRecorders.
+type Point struct {
+	Name        string
+	Description string
+	Kind        PointKind
+	Monotonic   bool
+	Unit        string
+	Value       float64
+	Count       int64
+	Sum         float64
+	Quantiles   []Quantile
+	Tags        map[string]string
+	Exemplars   []Exemplar
+}
+
+// pointQuantiles are the quantiles reported for histograms and timers.
+var pointQuantiles = []float64{0.5, 0.75, 0.95, 0.99, 0.999}
+
+// CollectPoints snapshots every metric in the given registry and returns
+// their values sorted by name, with timer durations in `scale` units unless
+// the timer carries its own DurationUnit.  It is meant to be called from the
+// collection callback of a bridge such as an OpenTelemetry metric.Producer.
+//
+// Counters and meters become monotonic sums, GaugeCounters non-monotonic
+// sums, gauges gauges, and histograms and timers summaries.  EnumGauges
+// become a gauge per state, tagged with the state, and InfoMetrics a gauge of
+// 1 tagged with their labels.  Meter and timer
+// rates are left out since the receiving system derives its own.
+// Healthchecks are not collected.  A Kind of "counter" or "gauge" in a
+// metric's Metadata overrides whether a sum or gauge is collected.
+func CollectPoints(r Registry, scale time.Duration) []Point {
+	var points []Point
+	EachWithMetadata(r, func(name string, i interface{}, md Metadata) {
+		if g, ok := i.(EnumGauge); ok {
+			points = append(points, enumPoints(name, g, md)...)
+		} else if p, ok := collectPoint(name, i, scale); ok {
+			p = p.withMetadata(md)
+			if m, ok := i.(InfoMetric); ok {
+				p.Tags = infoTags(m, md.Tags)
+			}
+			points = append(points, p)
+		}
+	})
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Name < points[j].Name })
+	return points
+}
+
+func collectPoint(name string, i interface{}, scale time.Duration) (Point, bool) {
+	p := Point{Name: name}
+	switch metric := i.(type) {
+	case Counter:
+		p.Kind, p.Monotonic = PointSum, true
+		p.Value = float64(metric.Count())
+	case Uint64Counter:
+		p.Kind, p.Monotonic = PointSum, true
+		p.Value = float64(metric.Count())
+	case BigCounter:
+		p.Kind, p.Monotonic = PointSum, true
+		p.Value = bigFloat64(metric.Count())
+	case GaugeCounter:
+		p.Kind = PointSum
+		p.Value = float64(metric.Count())
+	case Gauge:
+		p.Kind = PointGauge
+		p.Value = float64(metric.Value())
+	case GaugeFloat64:
+		p.Kind = PointGauge
+		p.Value = metric.Value()
+	case MovingAverage:
+		p.Kind = PointGauge
+		p.Value = metric.Value()
+	case RateGauge:
+		p.Kind = PointGauge
+		p.Value = metric.Rate()
+	case Cardinality:
+		p.Kind = PointGauge
+		p.Value = float64(metric.Snapshot().Estimate())
+	case BoolGauge:
+		p.Kind = PointGauge
+		p.Value = float64(boolGaugeValue(metric))
+	case InfoMetric:
+		p.Kind = PointGauge
+		p.Value = 1
+	case Histogram:
+		h := metric.Snapshot()
+		p.Kind = PointSummary
+		p.Count = h.Count()
+		p.Sum = float64(h.Sum())
+		p.Quantiles = pointQuantileValues(h.Percentiles(pointQuantiles), 1)
+	case HistogramFloat64:
+		h := metric.Snapshot()
+		p.Kind = PointSummary
+		p.Count = h.Count()
+		p.Sum = h.Sum()
+		p.Quantiles = pointQuantileValues(h.Percentiles(pointQuantiles), 1)
+	case SummaryFloat64:
+		s := metric.Snapshot()
+		p.Kind = PointSummary
+		p.Count = s.Count()
+		p.Sum = s.Sum()
+	case Meter:
+		p.Kind, p.Monotonic = PointSum, true
+		p.Value = float64(metric.Count())
+	case Timer:
+		t := metric.Snapshot()
+		unit := timerDurationUnit(t, scale)
+		du := float64(unit)
+		p.Kind = PointSummary
+		p.Unit = durationUnitSuffix(unit)
+		p.Count = t.Count()
+		p.Sum = float64(t.Sum()) / du
+		p.Quantiles = pointQuantileValues(t.Percentiles(pointQuantiles), du)
+	default:
+		return p, false
+	}
+	if e, ok := i.(ExemplarRecorder); ok {
+		p.Exemplars = e.Exemplars()
+	}
+	return p, true
+}
+
+// enumPoints returns a gauge for each of the states of g, tagged with the
+// state, which is 1 for the current state and 0 for the others.
+func enumPoints(name string, g EnumGauge, md Metadata) []Point {
+	var points []Point
+	eachEnumState(g, func(state string, v int64) {
+		p := Point{Name: name, Kind: PointGauge, Value: float64(v)}.withMetadata(md)
+		p.Tags = make(map[string]string, len(md.Tags)+1)
+		for k, v := range md.Tags {
+			p.Tags[k] = v
+		}
+		p.Tags["state"] = state
+		points = append(points, p)
+	})
+	return points
+}
+
+// infoTags returns the given tags with the labels of m added.
+func infoTags(m InfoMetric, tags map[string]string) map[string]string {
+	labels := m.Labels()
+	for k, v := range tags {
+		if _, ok := labels[k]; !ok {
+			labels[k] = v
+		}
+	}
+	return labels
+}
+
+// withMetadata returns a copy of p described by the given Metadata.
+func (p Point) withMetadata(md Metadata) Point {
+	p.Description = md.Description
+	p.Tags = md.Tags
+	if "" == p.Unit {
+		p.Unit = md.Unit
+	}
+	switch {
+	case "counter" == md.Kind && PointGauge == p.Kind:
+		p.Kind, p.Monotonic = PointSum, true
+	case "gauge" == md.Kind && PointSum == p.Kind:
+		p.Kind, p.Monotonic = PointGauge, false
+	}
+	return p
+}
+
+// quantileSuffix returns a name for the given quantile for exporters which
+// report each as its own series, eg "p99" for 0.99 and "p999" for 0.999.
+func quantileSuffix(q float64) string {
+	return "p" + strings.Replace(strconv.FormatFloat(100*q, 'g', -1, 64), ".", "", -1)
+}
+
+func pointQuantileValues(ps []float64, du float64) []Quantile {
+	qs := make([]Quantile, len(ps))
+	for i, v := range ps {
+		qs[i] = Quantile{Quantile: pointQuantiles[i], Value: v / du}
+	}
+	return qs
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCollectPoints(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("a.counter", r).Inc(47)
+	NewRegisteredGaugeFloat64("b.gauge", r).Update(4.7)
+	tm := NewRegisteredTimer("c.timer", r)
+	tm.Update(time.Second)
+	tm.Update(3 * time.Second)
+	r.Register("d.healthcheck", NewHealthcheck(func(Healthcheck) {}))
+
+	points := CollectPoints(r, time.Second)
+	if 3 != len(points) {
+		t.Fatalf("len(points): 3 != %v\n", len(points))
+	}
+	if p := points[0]; "a.counter" != p.Name || PointSum != p.Kind || !p.Monotonic || 47 != p.Value {
+		t.Errorf("counter: %+v\n", p)
+	}
+	if p := points[1]; "b.gauge" != p.Name || PointGauge != p.Kind || 4.7 != p.Value {
+		t.Errorf("gauge: %+v\n", p)
+	}
+	p := points[2]
+	if "c.timer" != p.Name || PointSummary != p.Kind || "s" != p.Unit || 2 != p.Count || 4 != p.Sum {
+		t.Errorf("timer: %+v\n", p)
+	}
+	if q := p.Quantiles[len(p.Quantiles)-1]; 0.999 != q.Quantile || 3 != q.Value {
+		t.Errorf("timer 99.9%%: %+v\n", q)
+	}
+}
+
+func TestCollectPointsMetadata(t *testing.T) {
+	r := NewRegistry()
+	RegisterWithMetadata("a.total", r, NewGauge(), Metadata{
+		Description: "Bytes sent",
+		Unit:        "bytes",
+		Kind:        "counter",
+	})
+	RegisterWithMetadata("b.timer", r, NewTimer(), Metadata{Unit: "widgets"})
+
+	points := CollectPoints(r, time.Millisecond)
+	if p := points[0]; "Bytes sent" != p.Description || "bytes" != p.Unit || PointSum != p.Kind || !p.Monotonic {
+		t.Errorf("gauge: %+v\n", p)
+	}
+	if p := points[1]; "ms" != p.Unit {
+		t.Errorf("timer: %+v\n", p)
+	}
+}
@@ -13,10 +13,35 @@ type Counter interface {
 // GetOrRegisterCounter returns an existing Counter or constructs and registers
 // a new StandardCounter.
 func GetOrRegisterCounter(name string, r Registry) Counter {
+	c, err := TryGetOrRegisterCounter(name, r)
+	if nil != err {
+		return registrationConflict(err, NewCounter()).(Counter)
+	}
+	return c
+}
+
+// GetCounter returns the Counter registered under the given name, or false if
+// there is none or the metric registered there is not a Counter.
+func GetCounter(name string, r Registry) (Counter, bool) {
 	if nil == r {
 		r = DefaultRegistry
 	}
-	return r.GetOrRegister(name, NewCounter).(Counter)
+	c, ok := r.Get(name).(Counter)
+	return c, ok
+}
+
+// TryGetOrRegisterCounter is like GetOrRegisterCounter but returns a
+// DuplicateMetric error, rather than panicking, if a metric which is not a
+// Counter is registered under the given name.
+func TryGetOrRegisterCounter(name string, r Registry) (Counter, error) {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	c, ok := r.GetOrRegister(name, NewCounter).(Counter)
+	if !ok {
+		return nil, DuplicateMetric(name)
+	}
+	return c, nil
 }
 
 // NewCounter constructs a new StandardCounter.
@@ -24,7 +49,7 @@ func NewCounter() Counter {
 	if UseNilMetrics {
 		return NilCounter{}
 	}
-	return &StandardCounter{0}
+	return &StandardCounter{}
 }
 
 // NewRegisteredCounter constructs and registers a new StandardCounter.
@@ -40,22 +65,23 @@ func NewRegisteredCounter(name string, r Registry) Counter {
 // CounterSnapshot is a read-only copy of another Counter.
 type CounterSnapshot int64
 
-// Clear panics.
-func (CounterSnapshot) Clear() Counter {
-	panic("Clear called on a CounterSnapshot")
+// Clear panics unless PanicOnSnapshotMutation is false.
+func (c CounterSnapshot) Clear() Counter {
+	snapshotMisuse("Clear called on a CounterSnapshot")
+	return c
 }
 
 // Count returns the count at the time the snapshot was taken.
 func (c CounterSnapshot) Count() int64 { return int64(c) }
 
-// Dec panics.
+// Dec panics unless PanicOnSnapshotMutation is false.
 func (CounterSnapshot) Dec(int64) {
-	panic("Dec called on a CounterSnapshot")
+	snapshotMisuse("Dec called on a CounterSnapshot")
 }
 
-// Inc panics.
+// Inc panics unless PanicOnSnapshotMutation is false.
 func (CounterSnapshot) Inc(int64) {
-	panic("Inc called on a CounterSnapshot")
+	snapshotMisuse("Inc called on a CounterSnapshot")
 }
 
 // Snapshot returns the snapshot.
@@ -79,7 +105,8 @@ func (NilCounter) Snapshot() Counter { return NilCounter{} }
 // StandardCounter is the standard implementation of a Counter and uses the
 // sync/atomic package to manage a single int64 value.
 type StandardCounter struct {
-	count int64
+	count     int64
+	exemplars exemplarSlots
 }
 
 // Clear resets the counter to zero and returns old counter
@@ -93,6 +120,11 @@ func (c *StandardCounter) Count() int64 {
 	return atomic.LoadInt64(&c.count)
 }
 
+// Exemplars returns the latest exemplar, if any.
+func (c *StandardCounter) Exemplars() []Exemplar {
+	return c.exemplars.exemplars()
+}
+
 // Inc increments the counter by the given amount.
 func (c *StandardCounter) Inc(i int64) {
 	atomic.AddInt64(&c.count, i)
@@ -102,3 +134,10 @@ func (c *StandardCounter) Inc(i int64) {
 func (c *StandardCounter) Snapshot() Counter {
 	return CounterSnapshot(c.Count())
 }
+
+// UpdateWithExemplar increments the counter by v, truncated to an integer,
+// and keeps it as the latest exemplar with the given labels.
+func (c *StandardCounter) UpdateWithExemplar(v float64, labels map[string]string) {
+	c.Inc(int64(v))
+	c.exemplars.set(0, 1, v, labels)
+}
@@ -0,0 +1,185 @@
+package metrics
+
+import (
+	"math"
+	"math/big"
+	"sync"
+)
+
+// BigCounters hold a count of arbitrary size, kept in a big.Int, for counts
+// which could overflow even a Uint64Counter, eg the sum of many processes'
+// byte counters merged by an AggregationServer.  Reporters export them like
+// Counters, as float64s where they need a number.  Counts are never
+// negative.
+type BigCounter interface {
+	Add(*big.Int)
+	Clear() BigCounter
+	Count() *big.Int
+	Inc(uint64)
+	Snapshot() BigCounter
+}
+
+// GetOrRegisterBigCounter returns an existing BigCounter or constructs and
+// registers a new StandardBigCounter.
+func GetOrRegisterBigCounter(name string, r Registry) BigCounter {
+	c, err := TryGetOrRegisterBigCounter(name, r)
+	if nil != err {
+		return registrationConflict(err, NewBigCounter()).(BigCounter)
+	}
+	return c
+}
+
+// GetBigCounter returns the BigCounter registered under the given name, or
+// false if there is none or the metric registered there is not a
+// BigCounter.
+func GetBigCounter(name string, r Registry) (BigCounter, bool) {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	c, ok := r.Get(name).(BigCounter)
+	return c, ok
+}
+
+// TryGetOrRegisterBigCounter is like GetOrRegisterBigCounter but returns a
+// DuplicateMetric error, rather than panicking, if a metric which is not a
+// BigCounter is registered under the given name.
+func TryGetOrRegisterBigCounter(name string, r Registry) (BigCounter, error) {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	c, ok := r.GetOrRegister(name, NewBigCounter).(BigCounter)
+	if !ok {
+		return nil, DuplicateMetric(name)
+	}
+	return c, nil
+}
+
+// NewBigCounter constructs a new StandardBigCounter.
+func NewBigCounter() BigCounter {
+	if UseNilMetrics {
+		return NilBigCounter{}
+	}
+	return &StandardBigCounter{}
+}
+
+// NewRegisteredBigCounter constructs and registers a new StandardBigCounter.
+func NewRegisteredBigCounter(name string, r Registry) BigCounter {
+	c := NewBigCounter()
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// BigCounterSnapshot is a read-only copy of another BigCounter.
+type BigCounterSnapshot struct {
+	count big.Int
+}
+
+// Add panics unless PanicOnSnapshotMutation is false.
+func (*BigCounterSnapshot) Add(*big.Int) {
+	snapshotMisuse("Add called on a BigCounterSnapshot")
+}
+
+// Clear panics unless PanicOnSnapshotMutation is false.
+func (c *BigCounterSnapshot) Clear() BigCounter {
+	snapshotMisuse("Clear called on a BigCounterSnapshot")
+	return c
+}
+
+// Count returns a copy of the count at the time the snapshot was taken.
+func (c *BigCounterSnapshot) Count() *big.Int { return new(big.Int).Set(&c.count) }
+
+// Inc panics unless PanicOnSnapshotMutation is false.
+func (*BigCounterSnapshot) Inc(uint64) {
+	snapshotMisuse("Inc called on a BigCounterSnapshot")
+}
+
+// Snapshot returns the snapshot.
+func (c *BigCounterSnapshot) Snapshot() BigCounter { return c }
+
+// NilBigCounter is a no-op BigCounter.
+type NilBigCounter struct{}
+
+// Add is a no-op.
+func (NilBigCounter) Add(*big.Int) {}
+
+// Clear is a no-op.
+func (NilBigCounter) Clear() BigCounter { return NilBigCounter{} }
+
+// Count is a no-op.
+func (NilBigCounter) Count() *big.Int { return new(big.Int) }
+
+// Inc is a no-op.
+func (NilBigCounter) Inc(uint64) {}
+
+// Snapshot is a no-op.
+func (NilBigCounter) Snapshot() BigCounter { return NilBigCounter{} }
+
+// StandardBigCounter is the standard implementation of a BigCounter and uses
+// a mutex to manage a big.Int.
+type StandardBigCounter struct {
+	count big.Int
+	mutex sync.Mutex
+}
+
+// Add increments the counter by the given amount, ignoring negative amounts.
+func (c *StandardBigCounter) Add(i *big.Int) {
+	if i.Sign() <= 0 {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.count.Add(&c.count, i)
+}
+
+// Clear resets the counter to zero and returns a snapshot of it from before.
+func (c *StandardBigCounter) Clear() BigCounter {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	snapshot := &BigCounterSnapshot{}
+	snapshot.count.Set(&c.count)
+	c.count.SetUint64(0)
+	return snapshot
+}
+
+// Count returns a copy of the current count.
+func (c *StandardBigCounter) Count() *big.Int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return new(big.Int).Set(&c.count)
+}
+
+// Inc increments the counter by the given amount.
+func (c *StandardBigCounter) Inc(i uint64) {
+	var n big.Int
+	n.SetUint64(i)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.count.Add(&c.count, &n)
+}
+
+// Snapshot returns a read-only copy of the counter.
+func (c *StandardBigCounter) Snapshot() BigCounter {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	snapshot := &BigCounterSnapshot{}
+	snapshot.count.Set(&c.count)
+	return snapshot
+}
+
+// bigFloat64 returns i as the nearest float64, or +Inf if it's too big.
+func bigFloat64(i *big.Int) float64 {
+	f, _ := new(big.Float).SetInt(i).Float64()
+	return f
+}
+
+// bigInt64 returns i, or math.MaxInt64 if it's bigger, for reporters which
+// can only take an int64.
+func bigInt64(i *big.Int) int64 {
+	if !i.IsInt64() {
+		return math.MaxInt64
+	}
+	return i.Int64()
+}
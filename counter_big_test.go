@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func BenchmarkBigCounter(b *testing.B) {
+	c := NewBigCounter()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Inc(1)
+	}
+}
+
+func TestBigCounter(t *testing.T) {
+	c := NewBigCounter()
+	c.Inc(math.MaxUint64)
+	c.Inc(math.MaxUint64)
+	c.Add(big.NewInt(-1))
+	want := new(big.Int).Mul(new(big.Int).SetUint64(math.MaxUint64), big.NewInt(2))
+	if count := c.Count(); 0 != want.Cmp(count) {
+		t.Errorf("c.Count(): %v != %v\n", want, count)
+	}
+	snapshot := c.Snapshot()
+	if count := c.Clear().Count(); 0 != want.Cmp(count) {
+		t.Errorf("c.Clear().Count(): %v != %v\n", want, count)
+	}
+	if count := c.Count(); 0 != count.Sign() {
+		t.Errorf("c.Count(): 0 != %v\n", count)
+	}
+	snapshot.Count().SetInt64(0)
+	if count := snapshot.Count(); 0 != want.Cmp(count) {
+		t.Errorf("snapshot.Count(): %v != %v\n", want, count)
+	}
+}
+
+func TestGetOrRegisterBigCounter(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredBigCounter("foo", r).Inc(47)
+	if c := GetOrRegisterBigCounter("foo", r); 0 != big.NewInt(47).Cmp(c.Count()) {
+		t.Fatal(c)
+	}
+}
+
+func TestBigCounterDumpRestore(t *testing.T) {
+	r := NewRegistry()
+	c := NewRegisteredBigCounter("foo", r)
+	c.Inc(math.MaxUint64)
+	c.Inc(1)
+	var buf bytes.Buffer
+	if err := DumpRegistry(r, &buf); nil != err {
+		t.Fatal(err)
+	}
+	restored, err := RestoreRegistry(&buf)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if rc, ok := GetBigCounter("foo", restored); !ok || "18446744073709551616" != rc.Count().String() {
+		t.Fatal(rc, ok)
+	}
+}
+
+func TestMergeSnapshotBigCounter(t *testing.T) {
+	exporter := NewRegistry()
+	NewRegisteredBigCounter("bytes", exporter)
+	for i := 0; i < 2; i++ {
+		worker := NewRegistry()
+		NewRegisteredUint64Counter("bytes", worker).Inc(math.MaxUint64)
+		var buf bytes.Buffer
+		if err := EncodeSnapshot(worker, &buf); nil != err {
+			t.Fatal(err)
+		}
+		if c := worker.Get("bytes").(Uint64Counter).Count(); 0 != c {
+			t.Errorf("worker bytes: 0 != %v\n", c)
+		}
+		if err := MergeSnapshot(exporter, &buf); nil != err {
+			t.Fatal(err)
+		}
+	}
+	if c := exporter.Get("bytes").(BigCounter).Count().String(); "36893488147419103230" != c {
+		t.Errorf("bytes: 36893488147419103230 != %v\n", c)
+	}
+}
@@ -62,3 +62,29 @@ func TestGetOrRegisterCounter(t *testing.T) {
 		t.Fatal(c)
 	}
 }
+
+func TestGetCounter(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("foo", r).Inc(47)
+	NewRegisteredGauge("bar", r)
+	if c, ok := GetCounter("foo", r); !ok || 47 != c.Count() {
+		t.Fatal(c, ok)
+	}
+	if c, ok := GetCounter("bar", r); ok {
+		t.Fatal(c)
+	}
+	if c, ok := GetCounter("baz", r); ok {
+		t.Fatal(c)
+	}
+}
+
+func TestTryGetOrRegisterCounter(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredGauge("bar", r)
+	if c, err := TryGetOrRegisterCounter("foo", r); nil != err || nil == c {
+		t.Fatal(c, err)
+	}
+	if c, err := TryGetOrRegisterCounter("bar", r); DuplicateMetric("bar") != err {
+		t.Fatal(c, err)
+	}
+}
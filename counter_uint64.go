@@ -0,0 +1,142 @@
+package metrics
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// Uint64Counters hold a uint64 value that can be incremented, for counts
+// such as cumulative bytes which could overflow an int64 Counter over a long
+// uptime.  Reporters export them like Counters.
+type Uint64Counter interface {
+	Clear() Uint64Counter
+	Count() uint64
+	Inc(uint64)
+	Snapshot() Uint64Counter
+}
+
+// GetOrRegisterUint64Counter returns an existing Uint64Counter or constructs
+// and registers a new StandardUint64Counter.
+func GetOrRegisterUint64Counter(name string, r Registry) Uint64Counter {
+	c, err := TryGetOrRegisterUint64Counter(name, r)
+	if nil != err {
+		return registrationConflict(err, NewUint64Counter()).(Uint64Counter)
+	}
+	return c
+}
+
+// GetUint64Counter returns the Uint64Counter registered under the given name,
+// or false if there is none or the metric registered there is not a
+// Uint64Counter.
+func GetUint64Counter(name string, r Registry) (Uint64Counter, bool) {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	c, ok := r.Get(name).(Uint64Counter)
+	return c, ok
+}
+
+// TryGetOrRegisterUint64Counter is like GetOrRegisterUint64Counter but
+// returns a DuplicateMetric error, rather than panicking, if a metric which
+// is not a Uint64Counter is registered under the given name.
+func TryGetOrRegisterUint64Counter(name string, r Registry) (Uint64Counter, error) {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	c, ok := r.GetOrRegister(name, NewUint64Counter).(Uint64Counter)
+	if !ok {
+		return nil, DuplicateMetric(name)
+	}
+	return c, nil
+}
+
+// NewUint64Counter constructs a new StandardUint64Counter.
+func NewUint64Counter() Uint64Counter {
+	if UseNilMetrics {
+		return NilUint64Counter{}
+	}
+	return &StandardUint64Counter{0}
+}
+
+// NewRegisteredUint64Counter constructs and registers a new
+// StandardUint64Counter.
+func NewRegisteredUint64Counter(name string, r Registry) Uint64Counter {
+	c := NewUint64Counter()
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// Uint64CounterSnapshot is a read-only copy of another Uint64Counter.
+type Uint64CounterSnapshot uint64
+
+// Clear panics unless PanicOnSnapshotMutation is false.
+func (c Uint64CounterSnapshot) Clear() Uint64Counter {
+	snapshotMisuse("Clear called on a Uint64CounterSnapshot")
+	return c
+}
+
+// Count returns the count at the time the snapshot was taken.
+func (c Uint64CounterSnapshot) Count() uint64 { return uint64(c) }
+
+// Inc panics unless PanicOnSnapshotMutation is false.
+func (Uint64CounterSnapshot) Inc(uint64) {
+	snapshotMisuse("Inc called on a Uint64CounterSnapshot")
+}
+
+// Snapshot returns the snapshot.
+func (c Uint64CounterSnapshot) Snapshot() Uint64Counter { return c }
+
+// NilUint64Counter is a no-op Uint64Counter.
+type NilUint64Counter struct{}
+
+// Clear is a no-op.
+func (NilUint64Counter) Clear() Uint64Counter { return NilUint64Counter{} }
+
+// Count is a no-op.
+func (NilUint64Counter) Count() uint64 { return 0 }
+
+// Inc is a no-op.
+func (NilUint64Counter) Inc(i uint64) {}
+
+// Snapshot is a no-op.
+func (NilUint64Counter) Snapshot() Uint64Counter { return NilUint64Counter{} }
+
+// StandardUint64Counter is the standard implementation of a Uint64Counter and
+// uses the sync/atomic package to manage a single uint64 value.
+type StandardUint64Counter struct {
+	count uint64
+}
+
+// Clear resets the counter to zero and returns old counter
+func (c *StandardUint64Counter) Clear() Uint64Counter {
+	count := atomic.SwapUint64(&c.count, 0)
+	return Uint64CounterSnapshot(count)
+}
+
+// Count returns the current count.
+func (c *StandardUint64Counter) Count() uint64 {
+	return atomic.LoadUint64(&c.count)
+}
+
+// Inc increments the counter by the given amount, wrapping around past
+// math.MaxUint64.
+func (c *StandardUint64Counter) Inc(i uint64) {
+	atomic.AddUint64(&c.count, i)
+}
+
+// Snapshot returns a read-only copy of the counter.
+func (c *StandardUint64Counter) Snapshot() Uint64Counter {
+	return Uint64CounterSnapshot(c.Count())
+}
+
+// clampInt64 returns i, or math.MaxInt64 if it's bigger, for reporters which
+// can only take an int64.
+func clampInt64(i uint64) int64 {
+	if i > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(i)
+}
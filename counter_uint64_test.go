@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func BenchmarkUint64Counter(b *testing.B) {
+	c := NewUint64Counter()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Inc(1)
+	}
+}
+
+func TestUint64Counter(t *testing.T) {
+	c := NewUint64Counter()
+	c.Inc(math.MaxInt64)
+	c.Inc(math.MaxInt64)
+	if count := c.Count(); 2*math.MaxInt64 != count {
+		t.Errorf("c.Count(): %v != %v\n", uint64(2*math.MaxInt64), count)
+	}
+	snapshot := c.Snapshot()
+	if count := c.Clear().Count(); 2*math.MaxInt64 != count {
+		t.Errorf("c.Clear().Count(): %v != %v\n", uint64(2*math.MaxInt64), count)
+	}
+	if count := c.Count(); 0 != count {
+		t.Errorf("c.Count(): 0 != %v\n", count)
+	}
+	if count := snapshot.Count(); 2*math.MaxInt64 != count {
+		t.Errorf("snapshot.Count(): %v != %v\n", uint64(2*math.MaxInt64), count)
+	}
+}
+
+func TestGetOrRegisterUint64Counter(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredUint64Counter("foo", r).Inc(47)
+	if c := GetOrRegisterUint64Counter("foo", r); 47 != c.Count() {
+		t.Fatal(c)
+	}
+	if _, err := TryGetOrRegisterCounter("foo", r); nil == err {
+		t.Fatal("TryGetOrRegisterCounter found a Uint64Counter")
+	}
+}
+
+func TestUint64CounterVisited(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredUint64Counter("foo", r).Inc(math.MaxUint64)
+	v := &countVisitor{}
+	r.(*StandardRegistry).VisitSnapshots(v)
+	if math.MaxInt64 != v.count {
+		t.Errorf("v.count: %v != %v\n", int64(math.MaxInt64), v.count)
+	}
+}
+
+func TestUint64CounterDumpRestore(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredUint64Counter("foo", r).Inc(math.MaxUint64)
+	var buf bytes.Buffer
+	if err := DumpRegistry(r, &buf); nil != err {
+		t.Fatal(err)
+	}
+	restored, err := RestoreRegistry(&buf)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if c, ok := GetUint64Counter("foo", restored); !ok || math.MaxUint64 != c.Count() {
+		t.Fatal(c, ok)
+	}
+}
+
+type countVisitor struct {
+	NilVisitor
+	count int64
+}
+
+func (v *countVisitor) VisitCounter(name string, count int64) { v.count = count }
@@ -0,0 +1,350 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DatadogConfig provides a container with configuration parameters for the
+// Datadog exporter, which submits metrics through Datadog's HTTP API.
+//
+// Metrics are translated as by CollectPoints: monotonic sums become counts
+// of their increase since the last flush, other sums and gauges become
+// gauges, and summaries become name.count and name.sum gauges plus a gauge
+// per quantile, eg name.p99.  Every series is tagged with Tags and then the
+// Tags from its metric's Metadata.
+//
+// If Distributions is set, HistogramFloat64s backed by a uniform or
+// exponentially-decaying sample are instead submitted as distributions, each
+// flush clearing them and sending the values recorded since the last, so that
+// Datadog computes percentiles from every value rather than from ours.
+// They're exact as long as the reservoir holds every value recorded between
+// flushes.  Since they're cleared, nothing else, eg another reporter, should
+// read them.  The values of a flush which fails are put back into their
+// histograms for the next.
+type DatadogConfig struct {
+	APIKey        string            // Datadog API key
+	URL           string            // Base URL of the API, defaulting to "https://api.datadoghq.com"
+	Registry      Registry          // Registry to be exported
+	FlushInterval time.Duration     // Flush interval
+	DurationUnit  time.Duration     // Time conversion unit for durations
+	Prefix        string            // Prefix to be prepended to metric names
+	Host          string            // Host of every series, defaulting to the hostname
+	Tags          map[string]string // Tags added to every series
+	BatchSize     int               // Series per request, defaulting to 500
+	MaxRetries    int               // Times to retry a failed request, defaulting to 3; negative for none
+	RetryInterval time.Duration     // Wait before the first retry, doubling for each after, defaulting to a second
+	ProxyURL      string            // If set and Client isn't, proxy requests through this URL rather than $HTTPS_PROXY
+	Client        *http.Client      // HTTP client, defaulting to http.DefaultClient
+	Filter        MetricFilter      // Selects the metrics to send, defaulting to all
+	Distributions bool              // Submit HistogramFloat64s as distributions, clearing them each flush
+}
+
+// Datadog is a blocking exporter function which submits the metrics in
+// c.Registry to Datadog every c.FlushInterval.
+func Datadog(c DatadogConfig) {
+	NewDatadogReporter(c).Run(context.Background())
+}
+
+// NewDatadogReporter constructs a new Reporter which exports just as Datadog
+// does, logging any errors.
+func NewDatadogReporter(c DatadogConfig) *Reporter {
+	return newExporterReporter(c.Registry, NewDatadogClient(c), SchedulerConfig{Interval: c.FlushInterval})
+}
+
+// DatadogClient submits metrics to Datadog.  It remembers what each counter
+// stood at when last sent successfully, so that it can send their increases.
+type DatadogClient struct {
+	config DatadogConfig
+	client *http.Client
+	mutex  sync.Mutex
+	counts map[string]float64
+}
+
+// NewDatadogClient constructs a new DatadogClient.  If c.ProxyURL doesn't
+// parse, Send returns an error.
+func NewDatadogClient(c DatadogConfig) *DatadogClient {
+	return &DatadogClient{config: c, counts: make(map[string]float64)}
+}
+
+// Send submits the metrics in the registry once.  A request which fails with
+// a network error, a 429 or a 5xx status is retried.
+func (d *DatadogClient) Send() error {
+	return d.Export(d.config.Registry)
+}
+
+// Export submits the metrics in r once, as Send does the configured
+// registry's, so that a DatadogClient is an Exporter.
+func (d *DatadogClient) Export(r Registry) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	client, err := d.httpClient()
+	if nil != err {
+		return err
+	}
+	now := time.Now().Unix()
+	series, distributions := d.series(r, now)
+	size := d.config.BatchSize
+	if size <= 0 {
+		size = 500
+	}
+	for len(series) > 0 {
+		n := len(series)
+		if n > size {
+			n = size
+		}
+		if err := d.post(client, "/api/v1/series", series[:n]); nil != err {
+			restoreDatadogDistributions(distributions)
+			return err
+		}
+		for _, s := range series[:n] {
+			if s := s.(datadogSeries); "" != s.countKey {
+				d.counts[s.countKey] = s.count
+			}
+		}
+		series = series[n:]
+	}
+	for len(distributions) > 0 {
+		n := len(distributions)
+		if n > size {
+			n = size
+		}
+		if err := d.post(client, "/api/v1/distribution_points", distributions[:n]); nil != err {
+			restoreDatadogDistributions(distributions)
+			return err
+		}
+		distributions = distributions[n:]
+	}
+	return nil
+}
+
+// restoreDatadogDistributions puts the values of the given distributions,
+// which couldn't be sent, back into the histograms they were cleared from.
+func restoreDatadogDistributions(distributions []interface{}) {
+	for _, i := range distributions {
+		dist := i.(datadogDistribution)
+		for _, v := range dist.values {
+			dist.histogram.Update(v)
+		}
+	}
+}
+
+// datadogSeries is one series, in the shape Datadog's series API takes.
+// Counts also carry what their counter stood at, for Export to remember
+// once they're sent.
+type datadogSeries struct {
+	Metric   string       `json:"metric"`
+	Points   [][2]float64 `json:"points"`
+	Type     string       `json:"type,omitempty"`
+	Host     string       `json:"host,omitempty"`
+	Tags     []string     `json:"tags,omitempty"`
+	countKey string
+	count    float64
+}
+
+// datadogDistribution is one distribution, in the shape Datadog's
+// distribution points API takes, ie points of a timestamp and values.  It
+// also carries the histogram its values were cleared from, to put them back
+// if they can't be sent.
+type datadogDistribution struct {
+	Metric    string           `json:"metric"`
+	Points    [][2]interface{} `json:"points"`
+	Host      string           `json:"host,omitempty"`
+	Tags      []string         `json:"tags,omitempty"`
+	histogram HistogramFloat64
+	values    []float64
+}
+
+// series translates r, clearing the histograms it submits as distributions
+// if configured to.
+func (d *DatadogClient) series(r Registry, now int64) ([]interface{}, []interface{}) {
+	r = d.config.Filter.registry(r)
+	host := d.config.Host
+	if "" == host {
+		host, _ = os.Hostname()
+	}
+	distributed := make(map[string]bool)
+	var series, distributions []interface{}
+	EachWithMetadata(r, func(name string, i interface{}, md Metadata) {
+		h, ok := i.(HistogramFloat64)
+		if !ok || !d.config.Distributions || !datadogDistributable(h) {
+			return
+		}
+		distributed[name] = true
+		values := AppendSampleFloat64Values(nil, h.Clear().Sample())
+		n := 0
+		for _, v := range values {
+			if !math.IsNaN(v) && !math.IsInf(v, 0) {
+				values[n] = v
+				n++
+			}
+		}
+		values = values[:n]
+		if 0 == len(values) {
+			return
+		}
+		distributions = append(distributions, datadogDistribution{
+			Metric:    d.config.Prefix + name,
+			Points:    [][2]interface{}{{now, values}},
+			Host:      host,
+			Tags:      datadogTags(d.config.Tags, md.Tags),
+			histogram: h,
+			values:    values,
+		})
+	})
+	for _, p := range CollectPoints(r, d.config.DurationUnit) {
+		if distributed[p.Name] {
+			continue
+		}
+		name := d.config.Prefix + p.Name
+		tags := datadogTags(d.config.Tags, p.Tags)
+		add := func(name, typ string, v float64) {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return
+			}
+			series = append(series, datadogSeries{
+				Metric: name,
+				Points: [][2]float64{{float64(now), v}},
+				Type:   typ,
+				Host:   host,
+				Tags:   tags,
+			})
+		}
+		switch {
+		case PointSum == p.Kind && p.Monotonic:
+			key := name + "|" + strings.Join(tags, ",")
+			delta := p.Value - d.counts[key]
+			if delta < 0 {
+				delta = p.Value // reset, eg by Clear
+			}
+			n := len(series)
+			add(name, "count", delta)
+			if len(series) > n {
+				s := series[n].(datadogSeries)
+				s.countKey, s.count = key, p.Value
+				series[n] = s
+			}
+		case PointSummary == p.Kind:
+			add(name+".count", "gauge", float64(p.Count))
+			add(name+".sum", "gauge", p.Sum)
+			for _, q := range p.Quantiles {
+				add(name+"."+quantileSuffix(q.Quantile), "gauge", q.Value)
+			}
+		default:
+			add(name, "gauge", p.Value)
+		}
+	}
+	return series, distributions
+}
+
+// datadogDistributable returns whether the given histogram keeps its values
+// in a sample which can be cleared, so that they can be submitted as a
+// distribution.
+func datadogDistributable(h HistogramFloat64) bool {
+	switch h.(type) {
+	case *StandardHistogramFloat64, *ExpDecayHistogramFloat64:
+	default:
+		return false
+	}
+	switch h.Sample().(type) {
+	case *UniformSampleFloat64, *ExpDecaySampleFloat64:
+		return true
+	}
+	return false
+}
+
+// datadogTags returns the given tags as Datadog's "key:value" strings,
+// sorted, later maps replacing earlier ones' keys.
+func datadogTags(maps ...map[string]string) []string {
+	all := make(map[string]string)
+	for _, m := range maps {
+		for k, v := range m {
+			all[k] = v
+		}
+	}
+	tags := make([]string, 0, len(all))
+	for k, v := range all {
+		tags = append(tags, k+":"+v)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+func (d *DatadogClient) httpClient() (*http.Client, error) {
+	if nil != d.config.Client {
+		return d.config.Client, nil
+	}
+	if "" == d.config.ProxyURL {
+		return http.DefaultClient, nil
+	}
+	if nil == d.client {
+		u, err := url.Parse(d.config.ProxyURL)
+		if nil != err {
+			return nil, fmt.Errorf("Datadog: proxy URL: %v", err)
+		}
+		d.client = &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(u)}}
+	}
+	return d.client, nil
+}
+
+// post posts the given series to the given API path, retrying as
+// configured.
+func (d *DatadogClient) post(client *http.Client, path string, series []interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{"series": series})
+	if nil != err {
+		return err
+	}
+	base := d.config.URL
+	if "" == base {
+		base = "https://api.datadoghq.com"
+	}
+	retries := d.config.MaxRetries
+	if 0 == retries {
+		retries = 3
+	}
+	wait := d.config.RetryInterval
+	if wait <= 0 {
+		wait = time.Second
+	}
+	for attempt := 0; ; attempt++ {
+		retry, err := d.postOnce(client, strings.TrimSuffix(base, "/")+path, body)
+		if nil == err || !retry || attempt >= retries {
+			return err
+		}
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+// postOnce makes one request, returning whether it's worth retrying if it
+// fails.
+func (d *DatadogClient) postOnce(client *http.Client, u string, body []byte) (bool, error) {
+	req, err := http.NewRequest("POST", u, bytes.NewReader(body))
+	if nil != err {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("DD-API-KEY", d.config.APIKey)
+	resp, err := client.Do(req)
+	if nil != err {
+		return true, err
+	}
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		retry := http.StatusTooManyRequests == resp.StatusCode || resp.StatusCode >= 500
+		return retry, fmt.Errorf("Datadog: %s: %s", resp.Status, bytes.TrimSpace(b))
+	}
+	return false, nil
+}
@@ -0,0 +1,205 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type datadogRequest struct {
+	Path   string
+	APIKey string
+	Series []map[string]interface{}
+}
+
+func newDatadogServer(t *testing.T, failures int) (*httptest.Server, func() []datadogRequest) {
+	var mutex sync.Mutex
+	var requests []datadogRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if 0 < failures {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		b, _ := ioutil.ReadAll(req.Body)
+		var body struct{ Series []map[string]interface{} }
+		if err := json.Unmarshal(b, &body); nil != err {
+			t.Error(err)
+		}
+		requests = append(requests, datadogRequest{req.URL.Path, req.Header.Get("DD-API-KEY"), body.Series})
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	return server, func() []datadogRequest {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]datadogRequest(nil), requests...)
+	}
+}
+
+func TestDatadog(t *testing.T) {
+	server, requests := newDatadogServer(t, 0)
+	defer server.Close()
+	r := NewRegistry()
+	c := NewRegisteredCounter("requests", r)
+	NewRegisteredGauge("queue", r).Update(3)
+	hf := NewRegisteredHistogramFloat64("latency", r, NewUniformSampleFloat64(100))
+	NewRegisteredHistogram("sizes", r, NewUniformSample(100)).Update(10)
+	r.(*StandardRegistry).SetMetadata("queue", Metadata{Tags: map[string]string{"queue": "jobs"}})
+	client := NewDatadogClient(DatadogConfig{
+		APIKey:   "secret",
+		URL:      server.URL,
+		Registry: r,
+		Prefix:   "app.",
+		Host:     "host1",
+		Tags:     map[string]string{"env": "test"},
+
+		Distributions: true,
+	})
+
+	c.Inc(5)
+	hf.Update(1.5)
+	hf.Update(2.5)
+	if err := client.Send(); nil != err {
+		t.Fatal(err)
+	}
+	c.Inc(2)
+	if err := client.Send(); nil != err {
+		t.Fatal(err)
+	}
+
+	reqs := requests()
+	if 3 != len(reqs) {
+		t.Fatalf("requests: 3 != %v\n", len(reqs))
+	}
+	if "secret" != reqs[0].APIKey {
+		t.Errorf("API key: secret != %v\n", reqs[0].APIKey)
+	}
+	if "/api/v1/series" != reqs[0].Path || "/api/v1/distribution_points" != reqs[1].Path || "/api/v1/series" != reqs[2].Path {
+		t.Errorf("paths: %v, %v, %v\n", reqs[0].Path, reqs[1].Path, reqs[2].Path)
+	}
+	series := func(req datadogRequest, name string) map[string]interface{} {
+		for _, s := range req.Series {
+			if name == s["metric"] {
+				return s
+			}
+		}
+		t.Errorf("%s: missing from %v\n", name, req.Series)
+		return map[string]interface{}{"points": []interface{}{[]interface{}{0.0, 0.0}}}
+	}
+	value := func(s map[string]interface{}) interface{} {
+		return s["points"].([]interface{})[0].([]interface{})[1]
+	}
+	s := series(reqs[0], "app.requests")
+	if "count" != s["type"] || 5.0 != value(s) {
+		t.Errorf("app.requests: count 5 != %v %v\n", s["type"], value(s))
+	}
+	if s := series(reqs[2], "app.requests"); 2.0 != value(s) {
+		t.Errorf("app.requests: 2 != %v\n", value(s))
+	}
+	s = series(reqs[0], "app.queue")
+	if "gauge" != s["type"] || 3.0 != value(s) || "host1" != s["host"] {
+		t.Errorf("app.queue: %v\n", s)
+	}
+	if tags := s["tags"].([]interface{}); 2 != len(tags) || "env:test" != tags[0] || "queue:jobs" != tags[1] {
+		t.Errorf("app.queue tags: %v\n", tags)
+	}
+	if s := series(reqs[0], "app.sizes.p99"); 10.0 != value(s) {
+		t.Errorf("app.sizes.p99: 10 != %v\n", value(s))
+	}
+	for _, req := range []datadogRequest{reqs[0], reqs[2]} {
+		for _, s := range req.Series {
+			if "app.latency" == s["metric"] || "app.latency.count" == s["metric"] {
+				t.Errorf("latency sent as series: %v\n", s)
+			}
+		}
+	}
+	values := value(series(reqs[1], "app.latency")).([]interface{})
+	if 2 != len(values) || 1.5 != values[0] || 2.5 != values[1] {
+		t.Errorf("app.latency: [1.5 2.5] != %v\n", values)
+	}
+	if n := hf.Count(); 0 != n {
+		t.Errorf("latency count: 0 != %v\n", n)
+	}
+}
+
+func TestDatadogFailedSend(t *testing.T) {
+	server, requests := newDatadogServer(t, 1)
+	defer server.Close()
+	r := NewRegistry()
+	NewRegisteredCounter("requests", r).Inc(5)
+	hf := NewRegisteredHistogramFloat64("latency", r, NewUniformSampleFloat64(100))
+	hf.Update(1.5)
+	hf.Update(2.5)
+	client := NewDatadogClient(DatadogConfig{URL: server.URL, Registry: r, MaxRetries: -1, Distributions: true})
+	if err := client.Send(); nil == err {
+		t.Fatal("Send: nil error\n")
+	}
+	if n := hf.Count(); 2 != n {
+		t.Errorf("latency count after failure: 2 != %v\n", n)
+	}
+	if err := client.Send(); nil != err {
+		t.Fatal(err)
+	}
+	reqs := requests()
+	if 2 != len(reqs) {
+		t.Fatalf("requests: 2 != %v\n", len(reqs))
+	}
+	if s := reqs[0].Series[0]; "requests" != s["metric"] || 5.0 != s["points"].([]interface{})[0].([]interface{})[1] {
+		t.Errorf("requests: 5 != %v\n", s)
+	}
+	if s := reqs[1].Series[0]; 2 != len(s["points"].([]interface{})[0].([]interface{})[1].([]interface{})) {
+		t.Errorf("latency: %v\n", s)
+	}
+}
+
+func TestDatadogWithoutDistributions(t *testing.T) {
+	server, requests := newDatadogServer(t, 0)
+	defer server.Close()
+	r := NewRegistry()
+	hf := NewRegisteredHistogramFloat64("latency", r, NewUniformSampleFloat64(100))
+	hf.Update(1.5)
+	if err := NewDatadogClient(DatadogConfig{URL: server.URL, Registry: r}).Send(); nil != err {
+		t.Fatal(err)
+	}
+	if n := hf.Count(); 1 != n {
+		t.Errorf("latency count: 1 != %v\n", n)
+	}
+	reqs := requests()
+	if 1 != len(reqs) || "/api/v1/series" != reqs[0].Path {
+		t.Fatalf("requests: %v\n", reqs)
+	}
+	var found bool
+	for _, s := range reqs[0].Series {
+		found = found || "latency.count" == s["metric"]
+	}
+	if !found {
+		t.Errorf("latency.count missing from %v\n", reqs[0].Series)
+	}
+}
+
+func TestDatadogRetries(t *testing.T) {
+	server, requests := newDatadogServer(t, 2)
+	defer server.Close()
+	r := NewRegistry()
+	NewRegisteredGauge("queue", r).Update(3)
+	config := DatadogConfig{URL: server.URL, Registry: r, RetryInterval: time.Millisecond}
+	if err := NewDatadogClient(config).Send(); nil != err {
+		t.Fatal(err)
+	}
+	if n := len(requests()); 1 != n {
+		t.Errorf("requests: 1 != %v\n", n)
+	}
+
+	server, _ = newDatadogServer(t, 2)
+	defer server.Close()
+	config.URL, config.MaxRetries = server.URL, 1
+	if err := NewDatadogClient(config).Send(); nil == err {
+		t.Error("Send: nil error after too many failures\n")
+	}
+}
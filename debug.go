@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"runtime"
 	"runtime/debug"
 	"time"
 )
@@ -12,13 +13,25 @@ var (
 			NumGC  Gauge
 			Pause  Histogram
 			//PauseQuantiles Histogram
-			PauseTotal Gauge
+			PauseTotal    Gauge
+			PauseSeconds  HistogramFloat64
+			GCCPUFraction GaugeFloat64
+			NumForcedGC   Counter
 		}
 		ReadGCStats Timer
 	}
-	gcStats debug.GCStats
+	gcStats       debug.GCStats
+	gcMemStats    runtime.MemStats
+	gcNumGC       int64
+	gcNumForcedGC uint32
 )
 
+// DebugGCStatsConfig provides a container with configuration parameters for
+// RegisterDebugGCStatsWithConfig.
+type DebugGCStatsConfig struct {
+	PauseSeconds bool // Also record every pause in seconds, the GC CPU fraction and forced GCs, at the cost of reading runtime.MemStats
+}
+
 // Capture new values for the Go garbage collector statistics exported in
 // debug.GCStats.  This is designed to be called as a goroutine.
 func CaptureDebugGCStats(r Registry, d time.Duration) {
@@ -48,12 +61,40 @@ func CaptureDebugGCStatsOnce(r Registry) {
 	}
 	//debugMetrics.GCStats.PauseQuantiles.Update(gcStats.PauseQuantiles)
 	debugMetrics.GCStats.PauseTotal.Update(int64(gcStats.PauseTotal))
+
+	if nil != debugMetrics.GCStats.PauseSeconds {
+		// gcStats.Pause holds the most recent pauses first, so those since
+		// the last capture are at its start unless there were too many.
+		n := gcStats.NumGC - gcNumGC
+		if n > int64(len(gcStats.Pause)) {
+			n = int64(len(gcStats.Pause))
+		}
+		for i := n - 1; i >= 0; i-- {
+			debugMetrics.GCStats.PauseSeconds.Update(gcStats.Pause[i].Seconds())
+		}
+		runtime.ReadMemStats(&gcMemStats)
+		debugMetrics.GCStats.GCCPUFraction.Update(gcCPUFraction(&gcMemStats))
+		forced := numForcedGC(&gcMemStats)
+		debugMetrics.GCStats.NumForcedGC.Inc(int64(forced - gcNumForcedGC))
+		gcNumForcedGC = forced
+	}
+	gcNumGC = gcStats.NumGC
 }
 
 // Register metrics for the Go garbage collector statistics exported in
 // debug.GCStats.  The metrics are named by their fully-qualified Go symbols,
 // i.e. debug.GCStats.PauseTotal.
 func RegisterDebugGCStats(r Registry) {
+	RegisterDebugGCStatsWithConfig(r, DebugGCStatsConfig{})
+}
+
+// RegisterDebugGCStatsWithConfig is like RegisterDebugGCStats but takes a
+// DebugGCStatsConfig.  With PauseSeconds set it also registers
+// debug.GCStats.PauseSeconds, a HistogramFloat64 of every pause in seconds
+// from which to take proper percentiles, debug.GCStats.GCCPUFraction and
+// debug.GCStats.NumForcedGC, a counter of the collections forced by
+// runtime.GC and debug.FreeOSMemory.
+func RegisterDebugGCStatsWithConfig(r Registry, c DebugGCStatsConfig) {
 	debugMetrics.GCStats.LastGC = NewGauge()
 	debugMetrics.GCStats.NumGC = NewGauge()
 	debugMetrics.GCStats.Pause = NewHistogram(NewExpDecaySample(1028, 0.015))
@@ -67,6 +108,18 @@ func RegisterDebugGCStats(r Registry) {
 	//r.Register("debug.GCStats.PauseQuantiles", debugMetrics.GCStats.PauseQuantiles)
 	r.Register("debug.GCStats.PauseTotal", debugMetrics.GCStats.PauseTotal)
 	r.Register("debug.ReadGCStats", debugMetrics.ReadGCStats)
+
+	debugMetrics.GCStats.PauseSeconds = nil
+	debugMetrics.GCStats.GCCPUFraction = nil
+	debugMetrics.GCStats.NumForcedGC = nil
+	if c.PauseSeconds {
+		debugMetrics.GCStats.PauseSeconds = NewHistogramFloat64(NewExpDecaySampleFloat64(1028, 0.015))
+		debugMetrics.GCStats.GCCPUFraction = NewGaugeFloat64()
+		debugMetrics.GCStats.NumForcedGC = NewCounter()
+		r.Register("debug.GCStats.PauseSeconds", debugMetrics.GCStats.PauseSeconds)
+		r.Register("debug.GCStats.GCCPUFraction", debugMetrics.GCStats.GCCPUFraction)
+		r.Register("debug.GCStats.NumForcedGC", debugMetrics.GCStats.NumForcedGC)
+	}
 }
 
 // Allocate an initial slice for gcStats.Pause to avoid allocations during
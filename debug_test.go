@@ -46,3 +46,31 @@ func testDebugGCStatsBlocking(ch chan int) {
 		}
 	}
 }
+
+func TestDebugGCStatsPauseSeconds(t *testing.T) {
+	r := NewRegistry()
+	RegisterDebugGCStatsWithConfig(r, DebugGCStatsConfig{PauseSeconds: true})
+	runtime.GC() // Finish any cycle left in progress by earlier tests.
+	CaptureDebugGCStatsOnce(r)
+	h := r.Get("debug.GCStats.PauseSeconds").(HistogramFloat64)
+	forced := r.Get("debug.GCStats.NumForcedGC").(Counter)
+	zero, zeroForced := h.Count(), forced.Count()
+	runtime.GC()
+	runtime.GC()
+	CaptureDebugGCStatsOnce(r)
+	if count := h.Count(); 2 != count-zero {
+		t.Errorf("PauseSeconds count: 2 != %v\n", count-zero)
+	}
+	if max := h.Max(); max <= 0 || max > 1 {
+		t.Errorf("PauseSeconds max: %v\n", max)
+	}
+	if count := forced.Count(); 2 != count-zeroForced {
+		t.Errorf("NumForcedGC: 2 != %v\n", count-zeroForced)
+	}
+	if nil == r.Get("debug.GCStats.GCCPUFraction") {
+		t.Error("GCCPUFraction: not registered")
+	}
+
+	RegisterDebugGCStats(NewRegistry())
+	CaptureDebugGCStatsOnce(r) // doesn't panic without them
+}
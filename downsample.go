@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"math"
+	"sync"
+)
+
+// DownsampleConfig provides a container with configuration parameters for a
+// downsampling exporter, which cuts the writes to a backend of eg thousands
+// of gauges which rarely change.
+type DownsampleConfig struct {
+	Every       int     // If above 1, forward only every Every-th flush, starting with the first
+	OnlyChanged bool    // Forward only the metrics whose values changed by more than Threshold since they were last forwarded
+	Threshold   float64 // Change in value beyond which a metric is forwarded, if OnlyChanged
+	Heartbeat   int     // If set, with OnlyChanged, forward every metric anyway every Heartbeat forwarded flushes, so that the backend doesn't take them as stale
+}
+
+// NewDownsamplingExporter constructs a new Exporter which forwards to e only
+// some flushes, or only the metrics which changed in them, as configured.
+//
+// The value compared of counters and gauges is their count or value, and of
+// histograms, meters, timers and SummaryFloat64s their count.  Other
+// metrics, eg EnumGauges, Cardinalities and ratio gauges, are forwarded
+// every time.
+// Metrics are remembered as forwarded only when e succeeds.
+func NewDownsamplingExporter(e Exporter, c DownsampleConfig) Exporter {
+	return &downsamplingExporter{exporter: e, config: c, last: make(map[string]float64)}
+}
+
+type downsamplingExporter struct {
+	exporter  Exporter
+	config    DownsampleConfig
+	mutex     sync.Mutex
+	flushes   int
+	forwarded int
+	last      map[string]float64
+}
+
+func (d *downsamplingExporter) Export(r Registry) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.flushes++
+	if 1 < d.config.Every && 0 != (d.flushes-1)%d.config.Every {
+		return nil
+	}
+	if !d.config.OnlyChanged {
+		return d.exporter.Export(r)
+	}
+	heartbeat := 0 < d.config.Heartbeat && 0 == d.forwarded%d.config.Heartbeat
+	forward := make(map[string]bool)
+	values := make(map[string]float64)
+	r.Each(func(name string, i interface{}) {
+		v, ok := downsampleValue(i)
+		if !ok {
+			forward[name] = true
+			return
+		}
+		values[name] = v
+		last, seen := d.last[name]
+		if heartbeat || !seen || math.Abs(v-last) > d.config.Threshold {
+			forward[name] = true
+		}
+	})
+	if err := d.exporter.Export(filteredRegistry{Registry: r, match: func(name string) bool {
+		return forward[name]
+	}}); nil != err {
+		return err
+	}
+	d.forwarded++
+	for name := range d.last {
+		if _, ok := values[name]; !ok {
+			delete(d.last, name) // unregistered
+		}
+	}
+	for name, v := range values {
+		if forward[name] {
+			d.last[name] = v
+		}
+	}
+	return nil
+}
+
+// downsampleValue returns the value of the given metric which a
+// downsamplingExporter compares, or false if there's none it can read
+// without side effects.
+func downsampleValue(i interface{}) (float64, bool) {
+	switch metric := i.(type) {
+	case Counter:
+		return float64(metric.Count()), true
+	case Uint64Counter:
+		return float64(metric.Count()), true
+	case BigCounter:
+		return bigFloat64(metric.Count()), true
+	case GaugeCounter:
+		return float64(metric.Count()), true
+	case Gauge:
+		return float64(metric.Value()), true
+	case *StandardRatioGauge:
+		return 0, false // reading it starts a new interval
+	case GaugeFloat64:
+		return metric.Value(), true
+	case MovingAverage:
+		return metric.Value(), true
+	case RateGauge:
+		return metric.Rate(), true
+	case BoolGauge:
+		return float64(boolGaugeValue(metric)), true
+	case Histogram:
+		return float64(metric.Count()), true
+	case HistogramFloat64:
+		return float64(metric.Count()), true
+	case Meter:
+		return float64(metric.Count()), true
+	case Timer:
+		return float64(metric.Count()), true
+	case SummaryFloat64:
+		return float64(metric.Count()), true
+	}
+	return 0, false
+}
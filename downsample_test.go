@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// namesExporter records the names of the metrics in each registry it's
+// given.
+type namesExporter struct {
+	flushes [][]string
+	err     error
+}
+
+func (e *namesExporter) Export(r Registry) error {
+	var names []string
+	r.Each(func(name string, i interface{}) {
+		names = append(names, name)
+	})
+	sort.Strings(names)
+	e.flushes = append(e.flushes, names)
+	return e.err
+}
+
+func TestDownsampleEvery(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredGauge("foo", r)
+	e := &namesExporter{}
+	d := NewDownsamplingExporter(e, DownsampleConfig{Every: 3})
+	for i := 0; i < 7; i++ {
+		d.Export(r)
+	}
+	if 3 != len(e.flushes) {
+		t.Errorf("flushes: 3 != %v\n", len(e.flushes))
+	}
+}
+
+func TestDownsampleOnlyChanged(t *testing.T) {
+	r := NewRegistry()
+	g := NewRegisteredGaugeFloat64("foo", r)
+	c := NewRegisteredCounter("bar", r)
+	NewRegisteredInfoMetric("build", r, map[string]string{"version": "1"})
+	e := &namesExporter{}
+	d := NewDownsamplingExporter(e, DownsampleConfig{OnlyChanged: true, Threshold: 0.5, Heartbeat: 3})
+	d.Export(r)
+	g.Update(0.25) // within the threshold
+	c.Inc(1)
+	d.Export(r)
+	g.Update(1)
+	d.Export(r)
+	d.Export(r) // heartbeat
+	want := [][]string{
+		{"bar", "build", "foo"},
+		{"bar", "build"},
+		{"build", "foo"},
+		{"bar", "build", "foo"},
+	}
+	if !reflect.DeepEqual(want, e.flushes) {
+		t.Errorf("flushes: %v != %v\n", want, e.flushes)
+	}
+
+	e.flushes, e.err = nil, errors.New("down")
+	c.Inc(1)
+	d.Export(r)
+	e.err = nil
+	d.Export(r)
+	if want := [][]string{{"bar", "build"}, {"bar", "build"}}; !reflect.DeepEqual(want, e.flushes) {
+		t.Errorf("flushes after failure: %v != %v\n", want, e.flushes)
+	}
+}
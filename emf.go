@@ -0,0 +1,204 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// emfMaxMetrics is the most metrics CloudWatch accepts in one EMF document.
+const emfMaxMetrics = 100
+
+// EMFConfig provides a container with configuration parameters for the
+// CloudWatch Embedded Metric Format exporter, which writes a JSON document
+// per line that the CloudWatch agent, or Lambda's or ECS's log drivers,
+// extract metrics from, so that no CloudWatch client is needed.
+//
+// Metrics are translated as by CollectPoints: monotonic sums become their
+// increase since the last flush, other sums and gauges their value, and
+// summaries name.count and name.sum plus a metric per quantile, eg
+// name.p99.  Metrics are grouped into documents by their dimensions, which
+// are Dimensions and then the Tags from each metric's Metadata.
+type EMFConfig struct {
+	Namespace     string            // CloudWatch namespace, defaulting to "go-metrics"
+	Writer        io.Writer         // Destination of the documents, eg a file or socket, defaulting to os.Stdout
+	Registry      Registry          // Registry to be exported
+	FlushInterval time.Duration     // Flush interval
+	DurationUnit  time.Duration     // Time conversion unit for durations
+	Prefix        string            // Prefix to be prepended to metric names
+	Dimensions    map[string]string // Dimensions added to every metric, eg {"Service": "api"}
+	Filter        MetricFilter      // Selects the metrics to send, defaulting to all
+}
+
+// EMF is a blocking exporter function which writes the metrics in
+// c.Registry to c.Writer every c.FlushInterval.
+func EMF(c EMFConfig) {
+	NewEMFReporter(c).Run(context.Background())
+}
+
+// NewEMFReporter constructs a new Reporter which exports just as EMF does,
+// logging any errors.
+func NewEMFReporter(c EMFConfig) *Reporter {
+	return newExporterReporter(c.Registry, NewEMFWriter(c), SchedulerConfig{Interval: c.FlushInterval})
+}
+
+// EMFWriter writes metrics in the CloudWatch Embedded Metric Format.  It
+// remembers what each counter stood at when last written, so that it can
+// write their increases.
+type EMFWriter struct {
+	config EMFConfig
+	mutex  sync.Mutex
+	counts map[string]float64
+}
+
+// NewEMFWriter constructs a new EMFWriter.
+func NewEMFWriter(c EMFConfig) *EMFWriter {
+	return &EMFWriter{config: c, counts: make(map[string]float64)}
+}
+
+// Write writes the metrics in the registry once.
+func (e *EMFWriter) Write() error {
+	return e.Export(e.config.Registry)
+}
+
+// Export writes the metrics in r once, as Write does the configured
+// registry's, so that an EMFWriter is an Exporter.
+func (e *EMFWriter) Export(r Registry) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	w := e.config.Writer
+	if nil == w {
+		w = os.Stdout
+	}
+	enc := json.NewEncoder(w)
+	for _, doc := range e.documents(r, time.Now()) {
+		if err := enc.Encode(doc); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// emfMetric is one metric's definition in an EMF document.
+type emfMetric struct {
+	Name string `json:"Name"`
+	Unit string `json:"Unit,omitempty"`
+}
+
+// emfGroup is the metrics which share a set of dimensions.
+type emfGroup struct {
+	dimensions map[string]string
+	metrics    []emfMetric
+	values     map[string]float64
+}
+
+// documents translates r into EMF documents, updating the remembered
+// counts.
+func (e *EMFWriter) documents(r Registry, now time.Time) []map[string]interface{} {
+	namespace := e.config.Namespace
+	if "" == namespace {
+		namespace = "go-metrics"
+	}
+	groups := make(map[string]*emfGroup)
+	var keys []string
+	for _, p := range CollectPoints(e.config.Filter.registry(r), e.config.DurationUnit) {
+		dimensions := make(map[string]string, len(e.config.Dimensions)+len(p.Tags))
+		for k, v := range e.config.Dimensions {
+			dimensions[k] = v
+		}
+		for k, v := range p.Tags {
+			dimensions[k] = v
+		}
+		key := strings.Join(datadogTags(dimensions), ",")
+		g, ok := groups[key]
+		if !ok {
+			g = &emfGroup{dimensions: dimensions, values: make(map[string]float64)}
+			groups[key] = g
+			keys = append(keys, key)
+		}
+		name := e.config.Prefix + p.Name
+		unit := emfUnit(p.Unit)
+		add := func(name, unit string, v float64) {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return
+			}
+			g.metrics = append(g.metrics, emfMetric{Name: name, Unit: unit})
+			g.values[name] = v
+		}
+		switch {
+		case PointSum == p.Kind && p.Monotonic:
+			delta := p.Value - e.counts[name+"|"+key]
+			if delta < 0 {
+				delta = p.Value // reset, eg by Clear
+			}
+			e.counts[name+"|"+key] = p.Value
+			add(name, "Count", delta)
+		case PointSummary == p.Kind:
+			add(name+".count", "Count", float64(p.Count))
+			add(name+".sum", unit, p.Sum)
+			for _, q := range p.Quantiles {
+				add(name+"."+quantileSuffix(q.Quantile), unit, q.Value)
+			}
+		default:
+			add(name, unit, p.Value)
+		}
+	}
+
+	ts := now.UnixNano() / int64(time.Millisecond)
+	var docs []map[string]interface{}
+	for _, key := range keys {
+		g := groups[key]
+		names := make([]string, 0, len(g.dimensions))
+		for k := range g.dimensions {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		for i := 0; i < len(g.metrics); i += emfMaxMetrics {
+			j := i + emfMaxMetrics
+			if j > len(g.metrics) {
+				j = len(g.metrics)
+			}
+			doc := make(map[string]interface{}, len(g.dimensions)+j-i+1)
+			for k, v := range g.dimensions {
+				doc[k] = v
+			}
+			for _, m := range g.metrics[i:j] {
+				doc[m.Name] = g.values[m.Name]
+			}
+			doc["_aws"] = map[string]interface{}{
+				"Timestamp": ts,
+				"CloudWatchMetrics": []interface{}{map[string]interface{}{
+					"Namespace":  namespace,
+					"Dimensions": [][]string{names},
+					"Metrics":    g.metrics[i:j],
+				}},
+			}
+			docs = append(docs, doc)
+		}
+	}
+	return docs
+}
+
+// emfUnit returns the CloudWatch unit for the given unit, as set in a
+// metric's Metadata or suffixed by durationUnitSuffix, or "" if there's none.
+func emfUnit(unit string) string {
+	switch strings.ToLower(unit) {
+	case "us", "µs", "microseconds":
+		return "Microseconds"
+	case "ms", "milliseconds":
+		return "Milliseconds"
+	case "s", "seconds":
+		return "Seconds"
+	case "bytes":
+		return "Bytes"
+	case "percent":
+		return "Percent"
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEMF(t *testing.T) {
+	r := NewRegistry()
+	c := NewRegisteredCounter("requests", r)
+	NewRegisteredGauge("queue", r).Update(3)
+	r.(*StandardRegistry).SetMetadata("queue", Metadata{Unit: "bytes", Tags: map[string]string{"Queue": "jobs"}})
+	NewRegisteredTimer("latency", r).Update(2 * time.Millisecond)
+	var buf bytes.Buffer
+	w := NewEMFWriter(EMFConfig{
+		Namespace:    "app",
+		Writer:       &buf,
+		Registry:     r,
+		DurationUnit: time.Millisecond,
+		Dimensions:   map[string]string{"Service": "api"},
+	})
+
+	c.Inc(5)
+	if err := w.Write(); nil != err {
+		t.Fatal(err)
+	}
+	c.Inc(2)
+	if err := w.Write(); nil != err {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if 4 != len(lines) {
+		t.Fatalf("lines: 4 != %v\n", len(lines))
+	}
+	type directive struct {
+		Namespace  string
+		Dimensions [][]string
+		Metrics    []emfMetric
+	}
+	var docs []map[string]json.RawMessage
+	for _, line := range lines {
+		var doc map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(line), &doc); nil != err {
+			t.Fatal(err)
+		}
+		docs = append(docs, doc)
+	}
+
+	var aws struct{ CloudWatchMetrics []directive }
+	json.Unmarshal(docs[0]["_aws"], &aws)
+	d := aws.CloudWatchMetrics[0]
+	if "app" != d.Namespace || "[[Service]]" != fmt.Sprint(d.Dimensions) {
+		t.Errorf("directive: %+v\n", d)
+	}
+	names := make(map[string]string)
+	for _, m := range d.Metrics {
+		names[m.Name] = m.Unit
+	}
+	if u, ok := names["latency.p99"]; !ok || "Milliseconds" != u {
+		t.Errorf("latency.p99: %q, %v\n", u, ok)
+	}
+	if "5" != string(docs[0]["requests"]) || "2" != string(docs[2]["requests"]) {
+		t.Errorf("requests: 5, 2 != %s, %s\n", docs[0]["requests"], docs[2]["requests"])
+	}
+	if "\"api\"" != string(docs[0]["Service"]) {
+		t.Errorf("Service: %s\n", docs[0]["Service"])
+	}
+
+	json.Unmarshal(docs[1]["_aws"], &aws)
+	d = aws.CloudWatchMetrics[0]
+	if "[[Queue Service]]" != fmt.Sprint(d.Dimensions) || 1 != len(d.Metrics) || "Bytes" != d.Metrics[0].Unit {
+		t.Errorf("directive: %+v\n", d)
+	}
+	if "3" != string(docs[1]["queue"]) || "\"jobs\"" != string(docs[1]["Queue"]) {
+		t.Errorf("queue: %s, %s\n", docs[1]["queue"], docs[1]["Queue"])
+	}
+}
+
+func TestEMFMaxMetrics(t *testing.T) {
+	r := NewRegistry()
+	for i := 0; i < 150; i++ {
+		NewRegisteredGauge(fmt.Sprintf("gauge%d", i), r)
+	}
+	var buf bytes.Buffer
+	if err := NewEMFWriter(EMFConfig{Writer: &buf, Registry: r}).Write(); nil != err {
+		t.Fatal(err)
+	}
+	if n := strings.Count(buf.String(), "\n"); 2 != n {
+		t.Errorf("documents: 2 != %v\n", n)
+	}
+}
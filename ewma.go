@@ -15,12 +15,26 @@ type EWMA interface {
 	Update(int64)
 }
 
-// NewEWMA constructs a new EWMA with the given alpha.
+// NewEWMA constructs a new EWMA with the given alpha, bias-corrected if
+// UseBiasCorrectedEWMAs is true.
 func NewEWMA(alpha float64) EWMA {
 	if UseNilMetrics {
 		return NilEWMA{}
 	}
-	return &StandardEWMA{alpha: alpha}
+	return &StandardEWMA{alpha: alpha, biasCorrected: UseBiasCorrectedEWMAs}
+}
+
+// NewBiasCorrectedEWMA constructs a new EWMA with the given alpha which
+// starts from zero and divides its average by the total weight of the ticks
+// it has seen, 1-(1-alpha)^ticks, rather than taking the rate of its first
+// tick as the rate of all time before it.  Its rates are those of the ticks
+// seen so far, so they are accurate from the start, even if the first tick
+// only covers part of an interval, and converge on those of a plain EWMA.
+func NewBiasCorrectedEWMA(alpha float64) EWMA {
+	if UseNilMetrics {
+		return NilEWMA{}
+	}
+	return &StandardEWMA{alpha: alpha, biasCorrected: true}
 }
 
 // NewEWMA1 constructs a new EWMA for a one-minute moving average.
@@ -48,14 +62,14 @@ func (a EWMASnapshot) Rate() float64 { return float64(a) }
 // Snapshot returns the snapshot.
 func (a EWMASnapshot) Snapshot() EWMA { return a }
 
-// Tick panics.
+// Tick panics unless PanicOnSnapshotMutation is false.
 func (EWMASnapshot) Tick() {
-	panic("Tick called on an EWMASnapshot")
+	snapshotMisuse("Tick called on an EWMASnapshot")
 }
 
-// Update panics.
+// Update panics unless PanicOnSnapshotMutation is false.
 func (EWMASnapshot) Update(int64) {
-	panic("Update called on an EWMASnapshot")
+	snapshotMisuse("Update called on an EWMASnapshot")
 }
 
 // NilEWMA is a no-op EWMA.
@@ -77,17 +91,25 @@ func (NilEWMA) Update(n int64) {}
 // of uncounted events and processes them on each tick.  It uses the
 // sync/atomic package to manage uncounted events.
 type StandardEWMA struct {
-	uncounted int64 // /!\ this should be the first member to ensure 64-bit alignment
-	alpha     float64
-	rate      float64
-	init      bool
-	mutex     sync.Mutex
+	uncounted     int64 // /!\ this should be the first member to ensure 64-bit alignment
+	alpha         float64
+	rate          float64
+	init          bool
+	biasCorrected bool
+	weight        float64 // of the ticks seen, if biasCorrected
+	mutex         sync.Mutex
 }
 
 // Rate returns the moving average rate of events per second.
 func (a *StandardEWMA) Rate() float64 {
 	a.mutex.Lock()
 	defer a.mutex.Unlock()
+	if a.biasCorrected {
+		if 0 == a.weight {
+			return 0
+		}
+		return a.rate / a.weight * float64(1e9)
+	}
 	return a.rate * float64(1e9)
 }
 
@@ -104,7 +126,10 @@ func (a *StandardEWMA) Tick() {
 	instantRate := float64(count) / float64(5e9)
 	a.mutex.Lock()
 	defer a.mutex.Unlock()
-	if a.init {
+	if a.biasCorrected {
+		a.rate += a.alpha * (instantRate - a.rate)
+		a.weight += a.alpha * (1 - a.weight)
+	} else if a.init {
 		a.rate += a.alpha * (instantRate - a.rate)
 	} else {
 		a.init = true
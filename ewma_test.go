@@ -1,6 +1,9 @@
 package metrics
 
-import "testing"
+import (
+	"math"
+	"testing"
+)
 
 func BenchmarkEWMA(b *testing.B) {
 	a := NewEWMA1()
@@ -223,3 +226,48 @@ func elapseMinute(a EWMA) {
 		a.Tick()
 	}
 }
+
+func TestBiasCorrectedEWMA(t *testing.T) {
+	a := NewBiasCorrectedEWMA(1 - math.Exp(-5.0/60.0/1))
+	if rate := a.Rate(); 0 != rate {
+		t.Errorf("a.Rate() before ticking: 0 != %v\n", rate)
+	}
+	for i := 0; i < 12; i++ {
+		a.Update(5)
+		a.Tick()
+		if rate := a.Rate(); 1e-9 < math.Abs(1-rate) {
+			t.Errorf("tick %d: a.Rate(): 1 != %v\n", i, rate)
+		}
+	}
+}
+
+func TestBiasCorrectedEWMAPartialFirstTick(t *testing.T) {
+	a, b := NewEWMA1(), NewBiasCorrectedEWMA(1-math.Exp(-5.0/60.0/1))
+	for _, e := range []EWMA{a, b} {
+		e.Update(1) // a fifth of the interval before the first tick
+		e.Tick()
+		for i := 0; i < 11; i++ {
+			e.Update(5)
+			e.Tick()
+		}
+	}
+	if rate := a.Rate(); 0.7 < rate {
+		t.Errorf("1 minute a.Rate(): %v\n", rate)
+	}
+	if rate := b.Rate(); rate < 0.95 {
+		t.Errorf("1 minute b.Rate(): %v\n", rate)
+	}
+}
+
+func TestUseBiasCorrectedEWMAs(t *testing.T) {
+	UseBiasCorrectedEWMAs = true
+	defer func() { UseBiasCorrectedEWMAs = false }()
+	a := NewEWMA5()
+	a.Update(5)
+	a.Tick()
+	a.Update(10)
+	a.Tick()
+	if rate := a.Rate(); rate <= 1.5 {
+		t.Errorf("a.Rate(): %v <= 1.5\n", rate)
+	}
+}
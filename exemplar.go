@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Exemplar is one value recorded by a metric along with labels identifying
+// where it came from, eg {"trace_id": "4bf92f3577b34da6"}, so that a spike in
+// a metric can be followed to a trace which contributed to it.
+type Exemplar struct {
+	Labels    map[string]string
+	Value     float64
+	Timestamp time.Time
+}
+
+// ExemplarRecorder is implemented by Counters and Histograms which can keep
+// exemplars.  StandardCounter, StandardHistogram and StandardHistogramFloat64
+// implement it.  CollectPoints passes exemplars on in each Point, and the
+// remote-write exporter sends them with a counter's series or a summary's
+// _count series.
+type ExemplarRecorder interface {
+	// UpdateWithExemplar records v as Update does, or as Inc does for a
+	// counter, and keeps it as an exemplar with the given labels.
+	UpdateWithExemplar(v float64, labels map[string]string)
+
+	// Exemplars returns the exemplars kept, the latest for each of the
+	// buckets of a histogram whose sample is a BucketSampleFloat64 in order
+	// of bucket, or else just the latest.
+	Exemplars() []Exemplar
+}
+
+// exemplarSlots keeps the latest exemplar for each of a number of slots, eg
+// the buckets of a histogram, allocating them on first use so that metrics
+// which are never given an exemplar pay only for the empty struct.
+type exemplarSlots struct {
+	mutex sync.Mutex
+	slots []Exemplar
+}
+
+// set keeps an exemplar of the given value and labels in slot i of n.
+func (s *exemplarSlots) set(i, n int, v float64, labels map[string]string) {
+	e := Exemplar{Labels: make(map[string]string, len(labels)), Value: v, Timestamp: time.Now()}
+	for k, v := range labels {
+		e.Labels[k] = v
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.slots) != n {
+		s.slots = make([]Exemplar, n)
+	}
+	s.slots[i] = e
+}
+
+// exemplars returns the exemplars kept, in order of slot.
+func (s *exemplarSlots) exemplars() []Exemplar {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var exemplars []Exemplar
+	for _, e := range s.slots {
+		if !e.Timestamp.IsZero() {
+			exemplars = append(exemplars, e)
+		}
+	}
+	return exemplars
+}
+
+// exemplarSlot returns the slot of an exemplar of value v recorded by a
+// histogram of the given sample, and the number of slots: the bucket it
+// falls in if the sample is bucketed, else the only one.
+func exemplarSlot(sample SampleFloat64, v float64) (int, int) {
+	if s, ok := sample.(*BucketSampleFloat64); ok {
+		bounds := s.bounds()
+		return sort.SearchFloat64s(bounds, v), len(bounds) + 1
+	}
+	return 0, 1
+}
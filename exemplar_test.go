@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCounterExemplars(t *testing.T) {
+	c := NewCounter()
+	if e := c.(ExemplarRecorder).Exemplars(); nil != e {
+		t.Errorf("Exemplars() before UpdateWithExemplar: nil != %v\n", e)
+	}
+	c.(ExemplarRecorder).UpdateWithExemplar(2, map[string]string{"trace_id": "a"})
+	c.(ExemplarRecorder).UpdateWithExemplar(3, map[string]string{"trace_id": "b"})
+	if 5 != c.Count() {
+		t.Errorf("c.Count(): 5 != %v\n", c.Count())
+	}
+	e := c.(ExemplarRecorder).Exemplars()
+	if 1 != len(e) || 3 != e[0].Value || "b" != e[0].Labels["trace_id"] || e[0].Timestamp.IsZero() {
+		t.Fatalf("Exemplars(): %v\n", e)
+	}
+}
+
+func TestHistogramFloat64ExemplarsBuckets(t *testing.T) {
+	h := NewHistogramFloat64(NewBucketSampleFloat64([]float64{1, 10})).(*StandardHistogramFloat64)
+	labels := map[string]string{"trace_id": "a"}
+	h.UpdateWithExemplar(0.5, labels)
+	h.UpdateWithExemplar(20, labels)
+	h.UpdateWithExemplar(0.7, map[string]string{"trace_id": "b"})
+	labels["trace_id"] = "c"
+	if 3 != h.Count() {
+		t.Errorf("h.Count(): 3 != %v\n", h.Count())
+	}
+	var values []float64
+	var traces []string
+	for _, e := range h.Exemplars() {
+		values = append(values, e.Value)
+		traces = append(traces, e.Labels["trace_id"])
+	}
+	if want := []float64{0.7, 20}; !reflect.DeepEqual(want, values) {
+		t.Errorf("values: %v != %v\n", want, values)
+	}
+	if want := []string{"b", "a"}; !reflect.DeepEqual(want, traces) {
+		t.Errorf("traces: %v != %v\n", want, traces)
+	}
+}
+
+func TestCollectPointsExemplars(t *testing.T) {
+	r := NewRegistry()
+	h := NewRegisteredHistogram("foo", r, NewUniformSample(10))
+	h.(ExemplarRecorder).UpdateWithExemplar(7, map[string]string{"trace_id": "a"})
+	NewRegisteredCounter("bar", r).Inc(1)
+	points := CollectPoints(r, 0)
+	if 0 != len(points[0].Exemplars) {
+		t.Errorf("bar exemplars: %v\n", points[0].Exemplars)
+	}
+	if e := points[1].Exemplars; 1 != len(e) || 7 != e[0].Value {
+		t.Errorf("foo exemplars: %v\n", e)
+	}
+}
@@ -5,6 +5,7 @@ package exp
 import (
 	"expvar"
 	"fmt"
+	"math/big"
 	"net/http"
 	"sync"
 
@@ -78,11 +79,32 @@ func (exp *exp) getFloat(name string) *expvar.Float {
 	return v
 }
 
+func (exp *exp) getString(name string) *expvar.String {
+	var v *expvar.String
+	exp.expvarLock.Lock()
+	p := expvar.Get(name)
+	if p != nil {
+		v = p.(*expvar.String)
+	} else {
+		v = new(expvar.String)
+		expvar.Publish(name, v)
+	}
+	exp.expvarLock.Unlock()
+	return v
+}
+
 func (exp *exp) publishCounter(name string, metric metrics.Counter) {
 	v := exp.getInt(name)
 	v.Set(metric.Count())
 }
 
+// publishBigCounter publishes a Uint64Counter or BigCounter as a float, as
+// expvar has no unsigned or arbitrary-precision integers.
+func (exp *exp) publishBigCounter(name string, count *big.Int) {
+	f, _ := new(big.Float).SetInt(count).Float64()
+	exp.getFloat(name).Set(f)
+}
+
 func (exp *exp) publishGaugeCounter(name string, metric metrics.GaugeCounter) {
 	v := exp.getInt(name)
 	v.Set(metric.Count())
@@ -159,20 +181,61 @@ func (exp *exp) syncToExpvar() {
 		switch i.(type) {
 		case metrics.Counter:
 			exp.publishCounter(name, i.(metrics.Counter))
+		case metrics.Uint64Counter:
+			exp.publishBigCounter(name, new(big.Int).SetUint64(i.(metrics.Uint64Counter).Count()))
+		case metrics.BigCounter:
+			exp.publishBigCounter(name, i.(metrics.BigCounter).Count())
 		case metrics.GaugeCounter:
 			exp.publishGaugeCounter(name, i.(metrics.GaugeCounter))
 		case metrics.Gauge:
 			exp.publishGauge(name, i.(metrics.Gauge))
 		case metrics.GaugeFloat64:
 			exp.publishGaugeFloat64(name, i.(metrics.GaugeFloat64))
+		case metrics.MovingAverage:
+			exp.getFloat(name).Set(i.(metrics.MovingAverage).Value())
+		case metrics.RateGauge:
+			exp.getFloat(name).Set(i.(metrics.RateGauge).Rate())
+		case metrics.Cardinality:
+			exp.getInt(name).Set(int64(i.(metrics.Cardinality).Snapshot().Estimate()))
+		case metrics.BoolGauge:
+			v := int64(0)
+			if i.(metrics.BoolGauge).Value() {
+				v = 1
+			}
+			exp.getInt(name).Set(v)
+		case metrics.EnumGauge:
+			g := i.(metrics.EnumGauge).Snapshot()
+			for _, s := range g.States() {
+				v := int64(0)
+				if s == g.Value() {
+					v = 1
+				}
+				exp.getInt(name + "." + s).Set(v)
+			}
+		case metrics.InfoMetric:
+			for k, v := range i.(metrics.InfoMetric).Labels() {
+				exp.getString(name + "." + k).Set(v)
+			}
 		case metrics.Histogram:
 			exp.publishHistogram(name, i.(metrics.Histogram))
 		case metrics.HistogramFloat64:
 			exp.publishHistogram(name, i.(metrics.Histogram))
+		case metrics.SummaryFloat64:
+			s := i.(metrics.SummaryFloat64).Snapshot()
+			exp.getInt(name + ".count").Set(s.Count())
+			exp.getFloat(name + ".min").Set(s.Min())
+			exp.getFloat(name + ".max").Set(s.Max())
+			exp.getFloat(name + ".mean").Set(s.Mean())
+			exp.getFloat(name + ".std-dev").Set(s.StdDev())
+			exp.getFloat(name + ".sum").Set(s.Sum())
 		case metrics.Meter:
 			exp.publishMeter(name, i.(metrics.Meter))
 		case metrics.Timer:
 			exp.publishTimer(name, i.(metrics.Timer))
+		case metrics.TopK:
+			for _, e := range i.(metrics.TopK).Top() {
+				exp.getInt(name + "." + e.Key).Set(e.Count)
+			}
 		default:
 			panic(fmt.Sprintf("unsupported type for '%s': %T", name, i))
 		}
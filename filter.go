@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"path"
+	"regexp"
+)
+
+// MetricFilter selects the metrics a reporter sends by name, so that eg
+// high-cardinality debug metrics can be kept in-process but not sent to an
+// expensive backend.  A metric is selected if its name matches none of the
+// exclusions and, if there are any inclusions, one of them.
+type MetricFilter struct {
+	Include       []string         // If set, only names matching one of these patterns in the syntax of path.Match, eg "http.*", or one of IncludeRegexp
+	Exclude       []string         // Never names matching one of these patterns
+	IncludeRegexp []*regexp.Regexp // If set, only names matching one of these or one of Include
+	ExcludeRegexp []*regexp.Regexp // Never names matching one of these
+}
+
+// Match returns whether the metric with the given name is selected.
+func (f MetricFilter) Match(name string) bool {
+	for _, pattern := range f.Exclude {
+		if ok, _ := path.Match(pattern, name); ok {
+			return false
+		}
+	}
+	for _, re := range f.ExcludeRegexp {
+		if re.MatchString(name) {
+			return false
+		}
+	}
+	if 0 == len(f.Include) && 0 == len(f.IncludeRegexp) {
+		return true
+	}
+	for _, pattern := range f.Include {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	for _, re := range f.IncludeRegexp {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// empty returns whether the filter selects every metric.
+func (f MetricFilter) empty() bool {
+	return 0 == len(f.Include) && 0 == len(f.Exclude) && 0 == len(f.IncludeRegexp) && 0 == len(f.ExcludeRegexp)
+}
+
+// registry returns r as a reporter with this filter reads it.
+func (f MetricFilter) registry(r Registry) Registry {
+	if f.empty() || nil == r {
+		return r
+	}
+	return NewFilteredRegistry(r, f)
+}
+
+// NewFilteredRegistry returns a view of r whose iteration methods, eg Each
+// and EachWithMetadata, and Get see only the metrics f selects.  The methods
+// which register and unregister metrics are r's own.
+func NewFilteredRegistry(r Registry, f MetricFilter) Registry {
+	return filteredRegistry{Registry: r, match: f.Match}
+}
+
+// filteredRegistry is a view of a registry which sees only the metrics
+// whose names match.
+type filteredRegistry struct {
+	Registry
+	match func(string) bool
+}
+
+func (r filteredRegistry) Each(f func(string, interface{})) {
+	r.Registry.Each(func(name string, i interface{}) {
+		if r.match(name) {
+			f(name, i)
+		}
+	})
+}
+
+func (r filteredRegistry) EachErr(f func(string, interface{}) error) error {
+	return r.Registry.EachErr(r.filtered(f))
+}
+
+func (r filteredRegistry) EachSortedErr(f func(string, interface{}) error) error {
+	return r.Registry.EachSortedErr(r.filtered(f))
+}
+
+func (r filteredRegistry) EachSorted(f func(string, interface{})) {
+	r.Registry.EachSortedErr(r.filtered(ignoreErr(f)))
+}
+
+func (r filteredRegistry) EachMatching(pattern string, f func(string, interface{})) error {
+	return eachMatching(r, pattern, f)
+}
+
+func (r filteredRegistry) VisitSnapshots(v Visitor) {
+	visitEach(r, v)
+}
+
+func (r filteredRegistry) EachWithMetadata(f func(string, interface{}, Metadata)) {
+	EachWithMetadata(r.Registry, func(name string, i interface{}, md Metadata) {
+		if r.match(name) {
+			f(name, i, md)
+		}
+	})
+}
+
+func (r filteredRegistry) Get(name string) interface{} {
+	if !r.match(name) {
+		return nil
+	}
+	return r.Registry.Get(name)
+}
+
+func (r filteredRegistry) Metadata(name string) (Metadata, bool) {
+	return GetMetadata(name, r.Registry)
+}
+
+func (r filteredRegistry) SetMetadata(name string, md Metadata) {
+	if mr, ok := r.Registry.(MetadataRegistry); ok {
+		mr.SetMetadata(name, md)
+	}
+}
+
+// filtered wraps f so that it's only called for the metrics whose names
+// match.
+func (r filteredRegistry) filtered(f func(string, interface{}) error) func(string, interface{}) error {
+	return func(name string, i interface{}) error {
+		if r.match(name) {
+			return f(name, i)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestMetricFilter(t *testing.T) {
+	f := MetricFilter{
+		Include:       []string{"http.*"},
+		IncludeRegexp: []*regexp.Regexp{regexp.MustCompile(`^db\.`)},
+		Exclude:       []string{"http.debug"},
+		ExcludeRegexp: []*regexp.Regexp{regexp.MustCompile(`\.by-user\.`)},
+	}
+	for name, want := range map[string]bool{
+		"http.requests":            true,
+		"http.debug":               false,
+		"db.queries":               true,
+		"db.queries.by-user.alice": false,
+		"runtime.goroutines":       false,
+	} {
+		if got := f.Match(name); want != got {
+			t.Errorf("%s: %v != %v\n", name, want, got)
+		}
+	}
+	if !(MetricFilter{}).Match("anything") {
+		t.Error("empty filter: anything not matched")
+	}
+}
+
+func TestFilteredRegistry(t *testing.T) {
+	r := NewRegistry()
+	c := NewRegisteredCounter("http.requests", r)
+	NewRegisteredCounter("http.requests.by-user.alice", r).Inc(1)
+	NewRegisteredCounter("runtime.goroutines", r)
+	f := NewFilteredRegistry(r, MetricFilter{ExcludeRegexp: []*regexp.Regexp{regexp.MustCompile(`\.by-user\.|^runtime\.`)}})
+	var names []string
+	f.EachSorted(func(name string, i interface{}) {
+		names = append(names, name)
+	})
+	if want := []string{"http.requests"}; !reflect.DeepEqual(want, names) {
+		t.Errorf("names: %v != %v\n", want, names)
+	}
+	if f.Get("http.requests") != c {
+		t.Error("Get: not the counter")
+	}
+	if nil != f.Get("runtime.goroutines") {
+		t.Error("Get: excluded metric found")
+	}
+
+	// Excluded metrics aren't cleared by a delta reporter.
+	l := &bufferLogger{}
+	newLogReporter(LogConfig{
+		Registry:      r,
+		Logger:        l,
+		Format:        LogFormatKeyValue,
+		ExcludeRegexp: []*regexp.Regexp{regexp.MustCompile(`\.by-user\.`)},
+		Temporality:   Delta,
+	}).flush()
+	if s := l.String(); strings.Contains(s, "by-user") {
+		t.Errorf("unexpected output: %q", s)
+	}
+	if count := r.Get("http.requests.by-user.alice").(Counter).Count(); 1 != count {
+		t.Errorf("by-user: 1 != %v\n", count)
+	}
+}
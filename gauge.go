@@ -12,10 +12,35 @@ type Gauge interface {
 // GetOrRegisterGauge returns an existing Gauge or constructs and registers a
 // new StandardGauge.
 func GetOrRegisterGauge(name string, r Registry) Gauge {
+	g, err := TryGetOrRegisterGauge(name, r)
+	if nil != err {
+		return registrationConflict(err, NewGauge()).(Gauge)
+	}
+	return g
+}
+
+// GetGauge returns the Gauge registered under the given name, or false if
+// there is none or the metric registered there is not a Gauge.
+func GetGauge(name string, r Registry) (Gauge, bool) {
 	if nil == r {
 		r = DefaultRegistry
 	}
-	return r.GetOrRegister(name, NewGauge).(Gauge)
+	g, ok := r.Get(name).(Gauge)
+	return g, ok
+}
+
+// TryGetOrRegisterGauge is like GetOrRegisterGauge but returns a
+// DuplicateMetric error, rather than panicking, if a metric which is not a
+// Gauge is registered under the given name.
+func TryGetOrRegisterGauge(name string, r Registry) (Gauge, error) {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	g, ok := r.GetOrRegister(name, NewGauge).(Gauge)
+	if !ok {
+		return nil, DuplicateMetric(name)
+	}
+	return g, nil
 }
 
 // NewGauge constructs a new StandardGauge.
@@ -60,9 +85,9 @@ type GaugeSnapshot int64
 // Snapshot returns the snapshot.
 func (g GaugeSnapshot) Snapshot() Gauge { return g }
 
-// Update panics.
+// Update panics unless PanicOnSnapshotMutation is false.
 func (GaugeSnapshot) Update(int64) {
-	panic("Update called on a GaugeSnapshot")
+	snapshotMisuse("Update called on a GaugeSnapshot")
 }
 
 // Value returns the value at the time the snapshot was taken.
@@ -114,7 +139,7 @@ func (g FunctionalGauge) Value() int64 {
 // Snapshot returns the snapshot.
 func (g FunctionalGauge) Snapshot() Gauge { return GaugeSnapshot(g.Value()) }
 
-// Update panics.
+// Update panics unless PanicOnSnapshotMutation is false.
 func (FunctionalGauge) Update(int64) {
-	panic("Update called on a FunctionalGauge")
+	snapshotMisuse("Update called on a FunctionalGauge")
 }
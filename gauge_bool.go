@@ -0,0 +1,111 @@
+package metrics
+
+import "sync/atomic"
+
+// BoolGauges hold a boolean value, eg whether a connection is up.  Reporters
+// export them as gauges of 0 or 1.
+type BoolGauge interface {
+	Snapshot() BoolGauge
+	Update(bool)
+	Value() bool
+}
+
+// GetOrRegisterBoolGauge returns an existing BoolGauge or constructs and
+// registers a new StandardBoolGauge.
+func GetOrRegisterBoolGauge(name string, r Registry) BoolGauge {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	g, ok := r.GetOrRegister(name, NewBoolGauge).(BoolGauge)
+	if !ok {
+		return registrationConflict(DuplicateMetric(name), NewBoolGauge()).(BoolGauge)
+	}
+	return g
+}
+
+// GetBoolGauge returns the BoolGauge registered under the given name, or
+// false if there is none or the metric registered there is not a BoolGauge.
+func GetBoolGauge(name string, r Registry) (BoolGauge, bool) {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	g, ok := r.Get(name).(BoolGauge)
+	return g, ok
+}
+
+// NewBoolGauge constructs a new StandardBoolGauge.
+func NewBoolGauge() BoolGauge {
+	if UseNilMetrics {
+		return NilBoolGauge{}
+	}
+	return &StandardBoolGauge{}
+}
+
+// NewRegisteredBoolGauge constructs and registers a new StandardBoolGauge.
+func NewRegisteredBoolGauge(name string, r Registry) BoolGauge {
+	c := NewBoolGauge()
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// BoolGaugeSnapshot is a read-only copy of another BoolGauge.
+type BoolGaugeSnapshot bool
+
+// Snapshot returns the snapshot.
+func (g BoolGaugeSnapshot) Snapshot() BoolGauge { return g }
+
+// Update panics unless PanicOnSnapshotMutation is false.
+func (BoolGaugeSnapshot) Update(bool) {
+	snapshotMisuse("Update called on a BoolGaugeSnapshot")
+}
+
+// Value returns the value at the time the snapshot was taken.
+func (g BoolGaugeSnapshot) Value() bool { return bool(g) }
+
+// NilBoolGauge is a no-op BoolGauge.
+type NilBoolGauge struct{}
+
+// Snapshot is a no-op.
+func (NilBoolGauge) Snapshot() BoolGauge { return NilBoolGauge{} }
+
+// Update is a no-op.
+func (NilBoolGauge) Update(v bool) {}
+
+// Value is a no-op.
+func (NilBoolGauge) Value() bool { return false }
+
+// StandardBoolGauge is the standard implementation of a BoolGauge and uses
+// the sync/atomic package to manage a single value.
+type StandardBoolGauge struct {
+	value int32
+}
+
+// Snapshot returns a read-only copy of the gauge.
+func (g *StandardBoolGauge) Snapshot() BoolGauge {
+	return BoolGaugeSnapshot(g.Value())
+}
+
+// Update updates the gauge's value.
+func (g *StandardBoolGauge) Update(v bool) {
+	var i int32
+	if v {
+		i = 1
+	}
+	atomic.StoreInt32(&g.value, i)
+}
+
+// Value returns the gauge's current value.
+func (g *StandardBoolGauge) Value() bool {
+	return 1 == atomic.LoadInt32(&g.value)
+}
+
+// boolGaugeValue returns the number 0 or 1 reporters export for the gauge.
+func boolGaugeValue(g BoolGauge) int64 {
+	if g.Value() {
+		return 1
+	}
+	return 0
+}
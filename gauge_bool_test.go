@@ -0,0 +1,28 @@
+package metrics
+
+import "testing"
+
+func TestBoolGauge(t *testing.T) {
+	g := NewBoolGauge()
+	if g.Value() {
+		t.Error("g.Value(): true")
+	}
+	g.Update(true)
+	snapshot := g.Snapshot()
+	g.Update(false)
+	if !snapshot.Value() {
+		t.Error("snapshot.Value(): false")
+	}
+}
+
+func TestGetOrRegisterBoolGauge(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredBoolGauge("foo", r).Update(true)
+	if g := GetOrRegisterBoolGauge("foo", r); !g.Value() {
+		t.Fatal(g)
+	}
+	points := CollectPoints(r, 0)
+	if 1 != len(points) || PointGauge != points[0].Kind || 1.0 != points[0].Value {
+		t.Fatalf("%+v\n", points)
+	}
+}
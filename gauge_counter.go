@@ -13,10 +13,36 @@ type GaugeCounter interface {
 // GetOrRegisterCounter returns an existing GaugeCounter or constructs and registers
 // a new StandardGaugeCounter.
 func GetOrRegisterGaugeCounter(name string, r Registry) GaugeCounter {
+  c, err := TryGetOrRegisterGaugeCounter(name, r)
+  if nil != err {
+    return registrationConflict(err, NewGaugeCounter()).(GaugeCounter)
+  }
+  return c
+}
+
+// GetGaugeCounter returns the GaugeCounter registered under the given name,
+// or false if there is none or the metric registered there is not a
+// GaugeCounter.
+func GetGaugeCounter(name string, r Registry) (GaugeCounter, bool) {
+  if nil == r {
+    r = DefaultRegistry
+  }
+  c, ok := r.Get(name).(GaugeCounter)
+  return c, ok
+}
+
+// TryGetOrRegisterGaugeCounter is like GetOrRegisterGaugeCounter but returns
+// a DuplicateMetric error, rather than panicking, if a metric which is not a
+// GaugeCounter is registered under the given name.
+func TryGetOrRegisterGaugeCounter(name string, r Registry) (GaugeCounter, error) {
   if nil == r {
     r = DefaultRegistry
   }
-  return r.GetOrRegister(name, NewGaugeCounter).(GaugeCounter)
+  c, ok := r.GetOrRegister(name, NewGaugeCounter).(GaugeCounter)
+  if !ok {
+    return nil, DuplicateMetric(name)
+  }
+  return c, nil
 }
 
 // NewGaugeCounter constructs a new StandardGaugeCounter.
@@ -24,7 +50,7 @@ func NewGaugeCounter() GaugeCounter {
   if UseNilMetrics {
     return NilGaugeCounter{}
   }
-  return &StandardGaugeCounter{StandardCounter{0}}
+  return &StandardGaugeCounter{StandardCounter{}}
 }
 
 // NewRegisteredCounter constructs and registers a new StandardGaugeCounter.
@@ -43,14 +69,14 @@ type GaugeCounterSnapshot int64
 // Count returns the count at the time the snapshot was taken.
 func (c GaugeCounterSnapshot) Count() int64 { return int64(c) }
 
-// Dec panics.
+// Dec panics unless PanicOnSnapshotMutation is false.
 func (GaugeCounterSnapshot) Dec(int64) {
-  panic("Dec called on a GaugeCounterSnapshot")
+  snapshotMisuse("Dec called on a GaugeCounterSnapshot")
 }
 
-// Inc panics.
+// Inc panics unless PanicOnSnapshotMutation is false.
 func (GaugeCounterSnapshot) Inc(int64) {
-  panic("Inc called on a GaugeCounterSnapshot")
+  snapshotMisuse("Inc called on a GaugeCounterSnapshot")
 }
 
 // Snapshot returns the snapshot.
@@ -0,0 +1,142 @@
+package metrics
+
+import "sync/atomic"
+
+// EnumGauges hold one of a declared set of string states, eg "connecting",
+// "valid" or "interrupted", so that state machines can be exported without
+// numbering their states.  Reporters export them as one gauge per state
+// which is 1 for the current state and 0 for the others.
+type EnumGauge interface {
+	Snapshot() EnumGauge
+	States() []string
+	Update(string)
+	Value() string
+}
+
+// GetOrRegisterEnumGauge returns an existing EnumGauge or constructs and
+// registers a new StandardEnumGauge of the given states.
+func GetOrRegisterEnumGauge(name string, r Registry, states ...string) EnumGauge {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	g, ok := r.GetOrRegister(name, func() EnumGauge { return NewEnumGauge(states...) }).(EnumGauge)
+	if !ok {
+		return registrationConflict(DuplicateMetric(name), NewEnumGauge(states...)).(EnumGauge)
+	}
+	return g
+}
+
+// GetEnumGauge returns the EnumGauge registered under the given name, or
+// false if there is none or the metric registered there is not an
+// EnumGauge.
+func GetEnumGauge(name string, r Registry) (EnumGauge, bool) {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	g, ok := r.Get(name).(EnumGauge)
+	return g, ok
+}
+
+// NewEnumGauge constructs a new StandardEnumGauge of the given states, in
+// the first of them.  It panics if there are none.
+func NewEnumGauge(states ...string) EnumGauge {
+	if 0 == len(states) {
+		panic("NewEnumGauge called without any states")
+	}
+	if UseNilMetrics {
+		return NilEnumGauge{}
+	}
+	return &StandardEnumGauge{states: append([]string(nil), states...)}
+}
+
+// NewRegisteredEnumGauge constructs and registers a new StandardEnumGauge.
+func NewRegisteredEnumGauge(name string, r Registry, states ...string) EnumGauge {
+	c := NewEnumGauge(states...)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// EnumGaugeSnapshot is a read-only copy of another EnumGauge.
+type EnumGaugeSnapshot struct {
+	states []string
+	value  int
+}
+
+// Snapshot returns the snapshot.
+func (g *EnumGaugeSnapshot) Snapshot() EnumGauge { return g }
+
+// States returns the states the gauge may be in, which mustn't be modified.
+func (g *EnumGaugeSnapshot) States() []string { return g.states }
+
+// Update panics unless PanicOnSnapshotMutation is false.
+func (*EnumGaugeSnapshot) Update(string) {
+	snapshotMisuse("Update called on an EnumGaugeSnapshot")
+}
+
+// Value returns the state at the time the snapshot was taken.
+func (g *EnumGaugeSnapshot) Value() string { return g.states[g.value] }
+
+// NilEnumGauge is a no-op EnumGauge.
+type NilEnumGauge struct{}
+
+// Snapshot is a no-op.
+func (NilEnumGauge) Snapshot() EnumGauge { return NilEnumGauge{} }
+
+// States is a no-op.
+func (NilEnumGauge) States() []string { return nil }
+
+// Update is a no-op.
+func (NilEnumGauge) Update(string) {}
+
+// Value is a no-op.
+func (NilEnumGauge) Value() string { return "" }
+
+// StandardEnumGauge is the standard implementation of an EnumGauge and uses
+// the sync/atomic package to manage the index of its current state.
+type StandardEnumGauge struct {
+	value  int32
+	states []string
+}
+
+// Snapshot returns a read-only copy of the gauge.
+func (g *StandardEnumGauge) Snapshot() EnumGauge {
+	return &EnumGaugeSnapshot{states: g.states, value: int(atomic.LoadInt32(&g.value))}
+}
+
+// States returns the states the gauge may be in, which mustn't be modified.
+func (g *StandardEnumGauge) States() []string {
+	return g.states
+}
+
+// Update changes the gauge's state.  States which weren't declared when the
+// gauge was constructed are ignored.
+func (g *StandardEnumGauge) Update(state string) {
+	for i, s := range g.states {
+		if s == state {
+			atomic.StoreInt32(&g.value, int32(i))
+			return
+		}
+	}
+}
+
+// Value returns the gauge's current state.
+func (g *StandardEnumGauge) Value() string {
+	return g.states[atomic.LoadInt32(&g.value)]
+}
+
+// eachEnumState calls f for each of the gauge's states with 1 if it's the
+// current state and 0 otherwise.
+func eachEnumState(g EnumGauge, f func(state string, v int64)) {
+	g = g.Snapshot()
+	value := g.Value()
+	for _, s := range g.States() {
+		if s == value {
+			f(s, 1)
+		} else {
+			f(s, 0)
+		}
+	}
+}
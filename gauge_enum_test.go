@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEnumGauge(t *testing.T) {
+	g := NewEnumGauge("connecting", "valid", "interrupted")
+	if v := g.Value(); "connecting" != v {
+		t.Errorf("g.Value(): connecting != %v\n", v)
+	}
+	g.Update("valid")
+	snapshot := g.Snapshot()
+	g.Update("unknown")
+	if v := g.Value(); "valid" != v {
+		t.Errorf("g.Value(): valid != %v\n", v)
+	}
+	g.Update("interrupted")
+	if v := snapshot.Value(); "valid" != v {
+		t.Errorf("snapshot.Value(): valid != %v\n", v)
+	}
+	if states := snapshot.States(); !reflect.DeepEqual([]string{"connecting", "valid", "interrupted"}, states) {
+		t.Errorf("snapshot.States(): %v\n", states)
+	}
+}
+
+func TestEnumGaugeWithoutStates(t *testing.T) {
+	defer func() {
+		if nil == recover() {
+			t.Error("NewEnumGauge() didn't panic")
+		}
+	}()
+	NewEnumGauge()
+}
+
+func TestEnumGaugeReported(t *testing.T) {
+	r := NewRegistry()
+	GetOrRegisterEnumGauge("foo", r, "up", "down").Update("down")
+	if g, ok := GetEnumGauge("foo", r); !ok || "down" != g.Value() {
+		t.Fatal(g, ok)
+	}
+	points := CollectPoints(r, 0)
+	if 2 != len(points) ||
+		"up" != points[0].Tags["state"] || 0.0 != points[0].Value ||
+		"down" != points[1].Tags["state"] || 1.0 != points[1].Value {
+		t.Fatalf("%+v\n", points)
+	}
+
+	var b bytes.Buffer
+	if err := writePrometheusText(&b, r, 0, ""); nil != err {
+		t.Fatal(err)
+	}
+	if want := "# TYPE foo gauge\nfoo{state=\"up\"} 0\nfoo{state=\"down\"} 1\n"; want != b.String() {
+		t.Errorf("%q != %q\n", want, b.String())
+	}
+
+	var s strings.Builder
+	WriteOnce(r, &s)
+	if want := "enum foo\n  up:                  0\n  down:                1\n"; want != s.String() {
+		t.Errorf("%q != %q\n", want, s.String())
+	}
+}
@@ -1,9 +1,14 @@
 package metrics
 
-import "sync"
+import (
+	"math"
+	"sync/atomic"
+)
 
-// GaugeFloat64s hold a float64 value that can be set arbitrarily.
+// GaugeFloat64s hold a float64 value that can be set arbitrarily or added
+// to.
 type GaugeFloat64 interface {
+	Add(float64)
 	Snapshot() GaugeFloat64
 	Update(float64)
 	Value() float64
@@ -12,10 +17,36 @@ type GaugeFloat64 interface {
 // GetOrRegisterGaugeFloat64 returns an existing GaugeFloat64 or constructs and registers a
 // new StandardGaugeFloat64.
 func GetOrRegisterGaugeFloat64(name string, r Registry) GaugeFloat64 {
+	g, err := TryGetOrRegisterGaugeFloat64(name, r)
+	if nil != err {
+		return registrationConflict(err, NewGaugeFloat64()).(GaugeFloat64)
+	}
+	return g
+}
+
+// GetGaugeFloat64 returns the GaugeFloat64 registered under the given name,
+// or false if there is none or the metric registered there is not a
+// GaugeFloat64.
+func GetGaugeFloat64(name string, r Registry) (GaugeFloat64, bool) {
 	if nil == r {
 		r = DefaultRegistry
 	}
-	return r.GetOrRegister(name, NewGaugeFloat64()).(GaugeFloat64)
+	g, ok := r.Get(name).(GaugeFloat64)
+	return g, ok
+}
+
+// TryGetOrRegisterGaugeFloat64 is like GetOrRegisterGaugeFloat64 but returns
+// a DuplicateMetric error, rather than panicking, if a metric which is not a
+// GaugeFloat64 is registered under the given name.
+func TryGetOrRegisterGaugeFloat64(name string, r Registry) (GaugeFloat64, error) {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	g, ok := r.GetOrRegister(name, NewGaugeFloat64).(GaugeFloat64)
+	if !ok {
+		return nil, DuplicateMetric(name)
+	}
+	return g, nil
 }
 
 // NewGaugeFloat64 constructs a new StandardGaugeFloat64.
@@ -23,9 +54,7 @@ func NewGaugeFloat64() GaugeFloat64 {
 	if UseNilMetrics {
 		return NilGaugeFloat64{}
 	}
-	return &StandardGaugeFloat64{
-		value: 0.0,
-	}
+	return &StandardGaugeFloat64{}
 }
 
 // NewRegisteredGaugeFloat64 constructs and registers a new StandardGaugeFloat64.
@@ -59,12 +88,17 @@ func NewRegisteredFunctionalGaugeFloat64(name string, r Registry, f func() float
 // GaugeFloat64Snapshot is a read-only copy of another GaugeFloat64.
 type GaugeFloat64Snapshot float64
 
+// Add panics unless PanicOnSnapshotMutation is false.
+func (GaugeFloat64Snapshot) Add(float64) {
+	snapshotMisuse("Add called on a GaugeFloat64Snapshot")
+}
+
 // Snapshot returns the snapshot.
 func (g GaugeFloat64Snapshot) Snapshot() GaugeFloat64 { return g }
 
-// Update panics.
+// Update panics unless PanicOnSnapshotMutation is false.
 func (GaugeFloat64Snapshot) Update(float64) {
-	panic("Update called on a GaugeFloat64Snapshot")
+	snapshotMisuse("Update called on a GaugeFloat64Snapshot")
 }
 
 // Value returns the value at the time the snapshot was taken.
@@ -73,6 +107,9 @@ func (g GaugeFloat64Snapshot) Value() float64 { return float64(g) }
 // NilGauge is a no-op Gauge.
 type NilGaugeFloat64 struct{}
 
+// Add is a no-op.
+func (NilGaugeFloat64) Add(v float64) {}
+
 // Snapshot is a no-op.
 func (NilGaugeFloat64) Snapshot() GaugeFloat64 { return NilGaugeFloat64{} }
 
@@ -83,10 +120,21 @@ func (NilGaugeFloat64) Update(v float64) {}
 func (NilGaugeFloat64) Value() float64 { return 0.0 }
 
 // StandardGaugeFloat64 is the standard implementation of a GaugeFloat64 and uses
-// sync.Mutex to manage a single float64 value.
+// the sync/atomic package to manage a single float64 value, stored as its
+// IEEE 754 bits.
 type StandardGaugeFloat64 struct {
-	mutex sync.Mutex
-	value float64
+	bits uint64
+}
+
+// Add adds the given amount to the gauge's value, so that it can serve as
+// a float64 accumulator.
+func (g *StandardGaugeFloat64) Add(v float64) {
+	for {
+		old := atomic.LoadUint64(&g.bits)
+		if atomic.CompareAndSwapUint64(&g.bits, old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
 }
 
 // Snapshot returns a read-only copy of the gauge.
@@ -96,16 +144,12 @@ func (g *StandardGaugeFloat64) Snapshot() GaugeFloat64 {
 
 // Update updates the gauge's value.
 func (g *StandardGaugeFloat64) Update(v float64) {
-	g.mutex.Lock()
-	defer g.mutex.Unlock()
-	g.value = v
+	atomic.StoreUint64(&g.bits, math.Float64bits(v))
 }
 
 // Value returns the gauge's current value.
 func (g *StandardGaugeFloat64) Value() float64 {
-	g.mutex.Lock()
-	defer g.mutex.Unlock()
-	return g.value
+	return math.Float64frombits(atomic.LoadUint64(&g.bits))
 }
 
 // FunctionalGaugeFloat64 returns value from given function
@@ -113,6 +157,11 @@ type FunctionalGaugeFloat64 struct {
 	value func() float64
 }
 
+// Add panics unless PanicOnSnapshotMutation is false.
+func (FunctionalGaugeFloat64) Add(float64) {
+	snapshotMisuse("Add called on a FunctionalGaugeFloat64")
+}
+
 // Value returns the gauge's current value.
 func (g FunctionalGaugeFloat64) Value() float64 {
 	return g.value()
@@ -121,7 +170,7 @@ func (g FunctionalGaugeFloat64) Value() float64 {
 // Snapshot returns the snapshot.
 func (g FunctionalGaugeFloat64) Snapshot() GaugeFloat64 { return GaugeFloat64Snapshot(g.Value()) }
 
-// Update panics.
+// Update panics unless PanicOnSnapshotMutation is false.
 func (FunctionalGaugeFloat64) Update(float64) {
-	panic("Update called on a FunctionalGaugeFloat64")
+	snapshotMisuse("Update called on a FunctionalGaugeFloat64")
 }
@@ -1,6 +1,9 @@
 package metrics
 
-import "testing"
+import (
+	"sync"
+	"testing"
+)
 
 func BenchmarkGuageFloat64(b *testing.B) {
 	g := NewGaugeFloat64()
@@ -18,6 +21,35 @@ func TestGaugeFloat64(t *testing.T) {
 	}
 }
 
+func BenchmarkGaugeFloat64Add(b *testing.B) {
+	g := NewGaugeFloat64()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			g.Add(1)
+		}
+	})
+}
+
+func TestGaugeFloat64Add(t *testing.T) {
+	g := NewGaugeFloat64()
+	g.Update(0.5)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				g.Add(0.25)
+			}
+		}()
+	}
+	wg.Wait()
+	if v := g.Value(); 2000.5 != v {
+		t.Errorf("g.Value(): 2000.5 != %v\n", v)
+	}
+}
+
 func TestGaugeFloat64Snapshot(t *testing.T) {
 	g := NewGaugeFloat64()
 	g.Update(float64(47.0))
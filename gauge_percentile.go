@@ -0,0 +1,86 @@
+package metrics
+
+import "fmt"
+
+// GetOrRegisterPercentileGauge returns an existing GaugeFloat64 or constructs
+// and registers a new PercentileGauge of the given percentile of h.
+func GetOrRegisterPercentileGauge(name string, r Registry, h interface{}, p float64) GaugeFloat64 {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	g, ok := r.GetOrRegister(name, func() GaugeFloat64 { return NewPercentileGauge(h, p) }).(GaugeFloat64)
+	if !ok {
+		return registrationConflict(DuplicateMetric(name), NewPercentileGauge(h, p)).(GaugeFloat64)
+	}
+	return g
+}
+
+// NewPercentileGauge constructs a new PercentileGauge of the given
+// percentile, eg 0.99, of h, which must be a Histogram, HistogramFloat64 or
+// Timer.
+func NewPercentileGauge(h interface{}, p float64) GaugeFloat64 {
+	if UseNilMetrics {
+		return NilGaugeFloat64{}
+	}
+	g := &PercentileGauge{percentile: p}
+	switch h := h.(type) {
+	case Histogram:
+		g.clear = func() float64 { return h.Clear().Percentile(p) }
+	case HistogramFloat64:
+		g.clear = func() float64 { return h.Clear().Percentile(p) }
+	case Timer:
+		g.clear = func() float64 { return h.Clear().Percentile(p) }
+	default:
+		panic(fmt.Sprintf("NewPercentileGauge: %T is not a Histogram, HistogramFloat64 or Timer", h))
+	}
+	return g
+}
+
+// NewRegisteredPercentileGauge constructs and registers a new
+// PercentileGauge.
+func NewRegisteredPercentileGauge(name string, r Registry, h interface{}, p float64) GaugeFloat64 {
+	g := NewPercentileGauge(h, p)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, g)
+	return g
+}
+
+// PercentileGauge is a GaugeFloat64 of a percentile of the values recorded
+// in a histogram or timer between one reading and the next, so that backends
+// which only store gauges get a series of per-interval percentiles, eg p99
+// latencies, rather than of percentiles of a reservoir which spans many
+// flushes.  Each reading clears the histogram, so it should be dedicated to
+// the gauge, with one histogram per percentile, and only one reporter
+// should read the gauge.  A timer's percentiles are in nanoseconds.
+type PercentileGauge struct {
+	percentile float64
+	clear      func() float64
+}
+
+// Add panics unless PanicOnSnapshotMutation is false.
+func (*PercentileGauge) Add(float64) {
+	snapshotMisuse("Add called on a PercentileGauge")
+}
+
+// Percentile returns the percentile the gauge reads, eg 0.99.
+func (g *PercentileGauge) Percentile() float64 { return g.percentile }
+
+// Snapshot returns a read-only copy of the gauge's value, which it counts as
+// a reading itself.
+func (g *PercentileGauge) Snapshot() GaugeFloat64 {
+	return GaugeFloat64Snapshot(g.Value())
+}
+
+// Update panics unless PanicOnSnapshotMutation is false.
+func (*PercentileGauge) Update(float64) {
+	snapshotMisuse("Update called on a PercentileGauge")
+}
+
+// Value returns the percentile of the values recorded since the last call
+// to Value or Snapshot, or since the gauge was constructed, or zero if there
+// were none, and clears the histogram to start the next interval.
+func (g *PercentileGauge) Value() float64 {
+	return g.clear()
+}
@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPercentileGauge(t *testing.T) {
+	h := NewHistogram(NewUniformSample(100))
+	g := NewPercentileGauge(h, 0.5)
+	for i := int64(1); i <= 9; i++ {
+		h.Update(i)
+	}
+	if v := g.Value(); 5 != v {
+		t.Errorf("g.Value(): 5 != %v\n", v)
+	}
+	if count := h.Count(); 0 != count {
+		t.Errorf("h.Count(): 0 != %v\n", count)
+	}
+	h.Update(100)
+	if v := g.Snapshot().Value(); 100 != v {
+		t.Errorf("g.Snapshot().Value(): 100 != %v\n", v)
+	}
+	if v := g.Value(); 0 != v {
+		t.Errorf("g.Value() with no values: 0 != %v\n", v)
+	}
+}
+
+func TestPercentileGaugeTimer(t *testing.T) {
+	r := NewRegistry()
+	tm := NewTimer()
+	g := GetOrRegisterPercentileGauge("latency.p99", r, tm, 0.99)
+	tm.Update(time.Millisecond)
+	if v := r.Get("latency.p99").(GaugeFloat64).Value(); float64(time.Millisecond) != v {
+		t.Errorf("latency.p99: %v != %v\n", float64(time.Millisecond), v)
+	}
+	if p := g.(*PercentileGauge).Percentile(); 0.99 != p {
+		t.Errorf("g.Percentile(): 0.99 != %v\n", p)
+	}
+}
+
+func TestPercentileGaugeNotAHistogram(t *testing.T) {
+	defer func() {
+		if nil == recover() {
+			t.Error("NewPercentileGauge(Counter): no panic")
+		}
+	}()
+	NewPercentileGauge(NewCounter(), 0.99)
+}
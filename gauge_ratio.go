@@ -0,0 +1,93 @@
+package metrics
+
+import "sync"
+
+// CountFunc adapts a function to the Count method, so that a RatioGauge or a
+// RateGauge can read a count kept outside this package.
+type CountFunc func() int64
+
+// Count calls f.
+func (f CountFunc) Count() int64 { return f() }
+
+// GetOrRegisterRatioGauge returns an existing GaugeFloat64 or constructs and
+// registers a new StandardRatioGauge of the given counts.
+func GetOrRegisterRatioGauge(name string, r Registry, numerator, denominator interface{ Count() int64 }) GaugeFloat64 {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	g, ok := r.GetOrRegister(name, func() GaugeFloat64 { return NewRatioGauge(numerator, denominator) }).(GaugeFloat64)
+	if !ok {
+		return registrationConflict(DuplicateMetric(name), NewRatioGauge(numerator, denominator)).(GaugeFloat64)
+	}
+	return g
+}
+
+// NewRatioGauge constructs a new StandardRatioGauge of the given Counters,
+// GaugeCounters or CountFuncs, eg of errors and requests for an error rate
+// or of hits and lookups for a cache hit ratio.
+func NewRatioGauge(numerator, denominator interface{ Count() int64 }) GaugeFloat64 {
+	if UseNilMetrics {
+		return NilGaugeFloat64{}
+	}
+	return &StandardRatioGauge{
+		numerator:   numerator,
+		denominator: denominator,
+		num:         numerator.Count(),
+		den:         denominator.Count(),
+	}
+}
+
+// NewRegisteredRatioGauge constructs and registers a new
+// StandardRatioGauge.
+func NewRegisteredRatioGauge(name string, r Registry, numerator, denominator interface{ Count() int64 }) GaugeFloat64 {
+	g := NewRatioGauge(numerator, denominator)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, g)
+	return g
+}
+
+// StandardRatioGauge is a GaugeFloat64 of the ratio of the changes of two
+// counts between one reading and the next, so that reporters export an error
+// rate or hit ratio over each interval as a single series rather than
+// leaving it to a dashboard formula.  It remembers the counts at the last
+// reading and measures the next against them.
+type StandardRatioGauge struct {
+	numerator   interface{ Count() int64 }
+	denominator interface{ Count() int64 }
+	mutex       sync.Mutex
+	num, den    int64
+}
+
+// Add panics unless PanicOnSnapshotMutation is false.
+func (*StandardRatioGauge) Add(float64) {
+	snapshotMisuse("Add called on a StandardRatioGauge")
+}
+
+// Snapshot returns a read-only copy of the ratio since the last reading,
+// which it counts as a reading itself.
+func (g *StandardRatioGauge) Snapshot() GaugeFloat64 {
+	return GaugeFloat64Snapshot(g.Value())
+}
+
+// Update panics unless PanicOnSnapshotMutation is false.
+func (*StandardRatioGauge) Update(float64) {
+	snapshotMisuse("Update called on a StandardRatioGauge")
+}
+
+// Value returns the change in the numerator divided by the change in the
+// denominator since the last call to Value or Snapshot, or since the gauge
+// was constructed, and starts measuring afresh.  It's zero if the
+// denominator didn't change.
+func (g *StandardRatioGauge) Value() float64 {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	num, den := g.numerator.Count(), g.denominator.Count()
+	dnum, dden := num-g.num, den-g.den
+	g.num, g.den = num, den
+	if 0 == dden {
+		return 0.0
+	}
+	return float64(dnum) / float64(dden)
+}
@@ -0,0 +1,32 @@
+package metrics
+
+import "testing"
+
+func TestRatioGauge(t *testing.T) {
+	r := NewRegistry()
+	errors, requests := NewCounter(), NewCounter()
+	errors.Inc(5)
+	requests.Inc(5)
+	g := GetOrRegisterRatioGauge("errors.ratio", r, errors, requests)
+	errors.Inc(1)
+	requests.Inc(4)
+	if v := g.Value(); 0.25 != v {
+		t.Errorf("g.Value(): 0.25 != %v\n", v)
+	}
+	if v := g.Snapshot().Value(); 0 != v {
+		t.Errorf("g.Snapshot().Value() with no requests: 0 != %v\n", v)
+	}
+	requests.Inc(2)
+	if v := r.Get("errors.ratio").(GaugeFloat64).Value(); 0 != v {
+		t.Errorf("errors.ratio: 0 != %v\n", v)
+	}
+}
+
+func TestRatioGaugeCountFunc(t *testing.T) {
+	hits, lookups := int64(0), int64(0)
+	g := NewRatioGauge(CountFunc(func() int64 { return hits }), CountFunc(func() int64 { return lookups }))
+	hits, lookups = 3, 4
+	if v := g.Value(); 0.75 != v {
+		t.Errorf("g.Value(): 0.75 != %v\n", v)
+	}
+}
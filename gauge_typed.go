@@ -0,0 +1,178 @@
+//go:build go1.18
+// +build go1.18
+
+package metrics
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// GaugeNumber is the constraint on the types of value a TypedGauge holds.
+type GaugeNumber interface {
+	~int32 | ~int64 | ~uint32 | ~uint64 | ~float32 | ~float64
+}
+
+// TypedGauges hold a value of type T that can be set arbitrarily or added
+// to, eg a uint64 count of bytes, which an int64 Gauge would overflow.
+// They're registered as a GaugeFloat64 view of themselves, so reporters
+// export them like GaugeFloat64s.
+type TypedGauge[T GaugeNumber] interface {
+	Add(T)
+	Snapshot() TypedGauge[T]
+	Update(T)
+	Value() T
+}
+
+// GetOrRegisterTypedGauge returns an existing TypedGauge or constructs and
+// registers a new StandardTypedGauge.
+func GetOrRegisterTypedGauge[T GaugeNumber](name string, r Registry) TypedGauge[T] {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	v, ok := r.GetOrRegister(name, func() GaugeFloat64 {
+		return typedGaugeFloat64[T]{NewTypedGauge[T]()}
+	}).(typedGaugeFloat64[T])
+	if !ok {
+		return registrationConflict(DuplicateMetric(name), NewTypedGauge[T]()).(TypedGauge[T])
+	}
+	return v.g
+}
+
+// GetTypedGauge returns the TypedGauge registered under the given name, or
+// false if there is none or the metric registered there is not a
+// TypedGauge of values of type T.
+func GetTypedGauge[T GaugeNumber](name string, r Registry) (TypedGauge[T], bool) {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	v, ok := r.Get(name).(typedGaugeFloat64[T])
+	if !ok {
+		return nil, false
+	}
+	return v.g, true
+}
+
+// NewTypedGauge constructs a new StandardTypedGauge.
+func NewTypedGauge[T GaugeNumber]() TypedGauge[T] {
+	if UseNilMetrics {
+		return NilTypedGauge[T]{}
+	}
+	var half = 0.5
+	return &StandardTypedGauge[T]{float: 0 != T(half)}
+}
+
+// NewRegisteredTypedGauge constructs and registers a new StandardTypedGauge.
+func NewRegisteredTypedGauge[T GaugeNumber](name string, r Registry) TypedGauge[T] {
+	g := NewTypedGauge[T]()
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, typedGaugeFloat64[T]{g})
+	return g
+}
+
+// TypedGaugeSnapshot is a read-only copy of another TypedGauge.
+type TypedGaugeSnapshot[T GaugeNumber] struct {
+	value T
+}
+
+// Add panics unless PanicOnSnapshotMutation is false.
+func (TypedGaugeSnapshot[T]) Add(T) {
+	snapshotMisuse("Add called on a TypedGaugeSnapshot")
+}
+
+// Snapshot returns the snapshot.
+func (g TypedGaugeSnapshot[T]) Snapshot() TypedGauge[T] { return g }
+
+// Update panics unless PanicOnSnapshotMutation is false.
+func (TypedGaugeSnapshot[T]) Update(T) {
+	snapshotMisuse("Update called on a TypedGaugeSnapshot")
+}
+
+// Value returns the value at the time the snapshot was taken.
+func (g TypedGaugeSnapshot[T]) Value() T { return g.value }
+
+// NilTypedGauge is a no-op TypedGauge.
+type NilTypedGauge[T GaugeNumber] struct{}
+
+// Add is a no-op.
+func (NilTypedGauge[T]) Add(T) {}
+
+// Snapshot is a no-op.
+func (NilTypedGauge[T]) Snapshot() TypedGauge[T] { return NilTypedGauge[T]{} }
+
+// Update is a no-op.
+func (NilTypedGauge[T]) Update(T) {}
+
+// Value is a no-op.
+func (NilTypedGauge[T]) Value() T { return 0 }
+
+// StandardTypedGauge is the standard implementation of a TypedGauge and uses
+// the sync/atomic package to manage a single value, stored as 64 bits: the
+// IEEE 754 bits of a float64 for floating-point types and otherwise the
+// value converted to a uint64, which converts back losslessly.
+type StandardTypedGauge[T GaugeNumber] struct {
+	bits  uint64
+	float bool
+}
+
+// Add adds the given amount to the gauge's value.  Integer values wrap
+// around on overflow, as they would in Go.
+func (g *StandardTypedGauge[T]) Add(v T) {
+	if !g.float {
+		atomic.AddUint64(&g.bits, uint64(v))
+		return
+	}
+	for {
+		old := atomic.LoadUint64(&g.bits)
+		if atomic.CompareAndSwapUint64(&g.bits, old, g.toBits(g.fromBits(old)+v)) {
+			return
+		}
+	}
+}
+
+// Snapshot returns a read-only copy of the gauge.
+func (g *StandardTypedGauge[T]) Snapshot() TypedGauge[T] {
+	return TypedGaugeSnapshot[T]{g.Value()}
+}
+
+// Update updates the gauge's value.
+func (g *StandardTypedGauge[T]) Update(v T) {
+	atomic.StoreUint64(&g.bits, g.toBits(v))
+}
+
+// Value returns the gauge's current value.
+func (g *StandardTypedGauge[T]) Value() T {
+	return g.fromBits(atomic.LoadUint64(&g.bits))
+}
+
+func (g *StandardTypedGauge[T]) fromBits(bits uint64) T {
+	if g.float {
+		return T(math.Float64frombits(bits))
+	}
+	return T(bits)
+}
+
+func (g *StandardTypedGauge[T]) toBits(v T) uint64 {
+	if g.float {
+		return math.Float64bits(float64(v))
+	}
+	return uint64(v)
+}
+
+// typedGaugeFloat64 is the GaugeFloat64 view of a TypedGauge which is
+// registered in its place.
+type typedGaugeFloat64[T GaugeNumber] struct {
+	g TypedGauge[T]
+}
+
+func (v typedGaugeFloat64[T]) Add(f float64) { v.g.Add(T(f)) }
+
+func (v typedGaugeFloat64[T]) Snapshot() GaugeFloat64 {
+	return GaugeFloat64Snapshot(v.Value())
+}
+
+func (v typedGaugeFloat64[T]) Update(f float64) { v.g.Update(T(f)) }
+
+func (v typedGaugeFloat64[T]) Value() float64 { return float64(v.g.Value()) }
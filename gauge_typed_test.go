@@ -0,0 +1,119 @@
+//go:build go1.18
+// +build go1.18
+
+package metrics
+
+import (
+	"math"
+	"sync"
+	"testing"
+)
+
+func BenchmarkTypedGauge(b *testing.B) {
+	g := NewTypedGauge[uint64]()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g.Update(uint64(i))
+	}
+}
+
+func TestTypedGaugeUint64(t *testing.T) {
+	g := NewTypedGauge[uint64]()
+	g.Update(math.MaxUint64 - 1)
+	if v := g.Value(); math.MaxUint64-1 != v {
+		t.Errorf("g.Value(): %v != %v\n", uint64(math.MaxUint64-1), v)
+	}
+	g.Add(1)
+	if v := g.Value(); math.MaxUint64 != v {
+		t.Errorf("g.Value(): %v != %v\n", uint64(math.MaxUint64), v)
+	}
+}
+
+func TestTypedGaugeInt32(t *testing.T) {
+	g := NewTypedGauge[int32]()
+	g.Update(-47)
+	g.Add(-3)
+	if v := g.Value(); -50 != v {
+		t.Errorf("g.Value(): -50 != %v\n", v)
+	}
+	g.Update(math.MaxInt32)
+	g.Add(1)
+	if v := g.Value(); math.MinInt32 != v {
+		t.Errorf("g.Value(): %v != %v\n", math.MinInt32, v)
+	}
+}
+
+func TestTypedGaugeFloat32(t *testing.T) {
+	g := NewTypedGauge[float32]()
+	g.Update(1.25)
+	g.Add(-0.5)
+	if v := g.Value(); 0.75 != v {
+		t.Errorf("g.Value(): 0.75 != %v\n", v)
+	}
+}
+
+func TestTypedGaugeConcurrentAdd(t *testing.T) {
+	g := NewTypedGauge[float32]()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				g.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if v := g.Value(); 8000 != v {
+		t.Errorf("g.Value(): 8000 != %v\n", v)
+	}
+}
+
+func TestTypedGaugeSnapshot(t *testing.T) {
+	g := NewTypedGauge[uint64]()
+	g.Update(47)
+	snapshot := g.Snapshot()
+	g.Update(0)
+	if v := snapshot.Value(); 47 != v {
+		t.Errorf("snapshot.Value(): 47 != %v\n", v)
+	}
+}
+
+func TestGetOrRegisterTypedGauge(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredTypedGauge[uint64]("foo", r).Update(47)
+	if g := GetOrRegisterTypedGauge[uint64]("foo", r); 47 != g.Value() {
+		t.Fatal(g)
+	}
+	if g, ok := GetTypedGauge[uint64]("foo", r); !ok || 47 != g.Value() {
+		t.Fatal(g, ok)
+	}
+	if _, ok := GetTypedGauge[int32]("foo", r); ok {
+		t.Fatal("GetTypedGauge[int32] found a TypedGauge[uint64]")
+	}
+}
+
+func TestGetOrRegisterTypedGaugeConflict(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredTypedGauge[uint64]("foo", r)
+	defer func() {
+		if nil == recover() {
+			t.Error("GetOrRegisterTypedGauge[int32] didn't panic")
+		}
+	}()
+	GetOrRegisterTypedGauge[int32]("foo", r)
+}
+
+func TestTypedGaugeReported(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredTypedGauge[uint64]("foo", r).Update(1 << 62)
+	g, ok := r.Get("foo").(GaugeFloat64)
+	if !ok {
+		t.Fatalf("r.Get(\"foo\"): %T isn't a GaugeFloat64\n", r.Get("foo"))
+	}
+	if v := g.Snapshot().Value(); 1<<62 != v {
+		t.Errorf("g.Snapshot().Value(): %v != %v\n", float64(1<<62), v)
+	}
+}
@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+// Heartbeats detect stalls in polling loops and stream processors, which
+// call Beat every time round.  A Heartbeat is the Timer of the gaps between
+// beats, so reporters export it as one, and counts the beats which came
+// later than its threshold in a Counter which can be registered alongside
+// it.  A stall in progress, before the late beat comes, shows in
+// SinceLastBeat.
+type Heartbeat interface {
+	Timer
+	Beat()
+	Missed() Counter
+	SinceLastBeat() time.Duration
+}
+
+// GetOrRegisterHeartbeat returns an existing Heartbeat or constructs and
+// registers a new StandardHeartbeat with the given threshold, along with
+// its Missed counter under the name followed by .Missed and a GaugeFloat64
+// of the seconds since the last beat under the name followed by
+// .SinceLastBeat.
+func GetOrRegisterHeartbeat(name string, r Registry, threshold time.Duration) Heartbeat {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	h, ok := r.GetOrRegister(name, func() Heartbeat { return NewHeartbeat(threshold) }).(Heartbeat)
+	if !ok {
+		return registrationConflict(DuplicateMetric(name), NewHeartbeat(threshold)).(Heartbeat)
+	}
+	r.GetOrRegister(name+".Missed", h.Missed())
+	r.GetOrRegister(name+".SinceLastBeat", func() GaugeFloat64 {
+		return NewFunctionalGaugeFloat64(func() float64 { return h.SinceLastBeat().Seconds() })
+	})
+	return h
+}
+
+// NewHeartbeat constructs a new StandardHeartbeat counting the gaps between
+// beats longer than threshold as missed.
+func NewHeartbeat(threshold time.Duration) Heartbeat {
+	return NewHeartbeatWithClock(threshold, DefaultClock)
+}
+
+// NewHeartbeatWithClock is like NewHeartbeat but reads the time from the
+// given Clock.
+func NewHeartbeatWithClock(threshold time.Duration, c Clock) Heartbeat {
+	if UseNilMetrics {
+		return NilHeartbeat{}
+	}
+	return &StandardHeartbeat{
+		Timer:     NewTimerWithClock(c),
+		threshold: threshold,
+		clock:     c,
+		missed:    NewCounter(),
+		last:      c.Now(),
+	}
+}
+
+// NilHeartbeat is a no-op Heartbeat.
+type NilHeartbeat struct {
+	NilTimer
+}
+
+// Beat is a no-op.
+func (NilHeartbeat) Beat() {}
+
+// Missed returns a no-op Counter.
+func (NilHeartbeat) Missed() Counter { return NilCounter{} }
+
+// SinceLastBeat is a no-op.
+func (NilHeartbeat) SinceLastBeat() time.Duration { return 0 }
+
+// StandardHeartbeat is the standard implementation of a Heartbeat, timing
+// the gaps between beats with a StandardTimer.  The first gap is timed
+// from its construction.
+type StandardHeartbeat struct {
+	Timer
+	threshold time.Duration
+	clock     Clock
+	missed    Counter
+	mutex     sync.Mutex
+	last      time.Time
+}
+
+// Beat records the gap since the last beat, counting it as missed if it's
+// longer than the threshold.
+func (h *StandardHeartbeat) Beat() {
+	now := h.clock.Now()
+	h.mutex.Lock()
+	gap := now.Sub(h.last)
+	h.last = now
+	h.mutex.Unlock()
+	h.Timer.Update(gap)
+	if gap > h.threshold {
+		h.missed.Inc(1)
+	}
+}
+
+// Missed returns the Counter of the beats which came late.
+func (h *StandardHeartbeat) Missed() Counter {
+	return h.missed
+}
+
+// SinceLastBeat returns the time since the last beat.
+func (h *StandardHeartbeat) SinceLastBeat() time.Duration {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.clock.Now().Sub(h.last)
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHeartbeat(t *testing.T) {
+	now := time.Unix(0, 0)
+	h := NewHeartbeatWithClock(time.Second, ClockFunc(func() time.Time { return now }))
+	now = now.Add(500 * time.Millisecond)
+	h.Beat()
+	now = now.Add(3 * time.Second)
+	h.Beat()
+	now = now.Add(time.Second)
+	h.Beat()
+	if count := h.Count(); 3 != count {
+		t.Errorf("h.Count(): 3 != %v\n", count)
+	}
+	if max := h.Max(); int64(3*time.Second) != max {
+		t.Errorf("h.Max(): 3s != %v\n", time.Duration(max))
+	}
+	if missed := h.Missed().Count(); 1 != missed {
+		t.Errorf("h.Missed().Count(): 1 != %v\n", missed)
+	}
+	now = now.Add(5 * time.Second)
+	if since := h.SinceLastBeat(); 5*time.Second != since {
+		t.Errorf("h.SinceLastBeat(): 5s != %v\n", since)
+	}
+}
+
+func TestGetOrRegisterHeartbeat(t *testing.T) {
+	r := NewRegistry()
+	h := GetOrRegisterHeartbeat("loop", r, time.Second)
+	if h != GetOrRegisterHeartbeat("loop", r, time.Second) {
+		t.Error("GetOrRegisterHeartbeat: want the same Heartbeat")
+	}
+	if _, ok := r.Get("loop").(Timer); !ok {
+		t.Error("loop: want a Timer")
+	}
+	if h.Missed() != r.Get("loop.Missed") {
+		t.Error("loop.Missed: want h.Missed()")
+	}
+	if _, ok := r.Get("loop.SinceLastBeat").(GaugeFloat64); !ok {
+		t.Error("loop.SinceLastBeat: want a GaugeFloat64")
+	}
+}
@@ -22,10 +22,35 @@ type Histogram interface {
 // GetOrRegisterHistogram returns an existing Histogram or constructs and
 // registers a new StandardHistogram.
 func GetOrRegisterHistogram(name string, r Registry, s Sample) Histogram {
+	h, err := TryGetOrRegisterHistogram(name, r, s)
+	if nil != err {
+		return registrationConflict(err, NewHistogram(s)).(Histogram)
+	}
+	return h
+}
+
+// GetHistogram returns the Histogram registered under the given name, or
+// false if there is none or the metric registered there is not a Histogram.
+func GetHistogram(name string, r Registry) (Histogram, bool) {
 	if nil == r {
 		r = DefaultRegistry
 	}
-	return r.GetOrRegister(name, func() Histogram { return NewHistogram(s) }).(Histogram)
+	h, ok := r.Get(name).(Histogram)
+	return h, ok
+}
+
+// TryGetOrRegisterHistogram is like GetOrRegisterHistogram but returns a
+// DuplicateMetric error, rather than panicking, if a metric which is not a
+// Histogram is registered under the given name.
+func TryGetOrRegisterHistogram(name string, r Registry, s Sample) (Histogram, error) {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	h, ok := r.GetOrRegister(name, func() Histogram { return NewHistogram(s) }).(Histogram)
+	if !ok {
+		return nil, DuplicateMetric(name)
+	}
+	return h, nil
 }
 
 // NewHistogram constructs a new StandardHistogram from a Sample.
@@ -36,6 +61,25 @@ func NewHistogram(s Sample) Histogram {
 	return &StandardHistogram{sample: s}
 }
 
+// NewFunctionalHistogram constructs a new FunctionalHistogram.
+func NewFunctionalHistogram(f func() HistogramStats) Histogram {
+	if UseNilMetrics {
+		return NilHistogram{}
+	}
+	return &FunctionalHistogram{stats: f}
+}
+
+// NewRegisteredFunctionalHistogram constructs and registers a new
+// FunctionalHistogram.
+func NewRegisteredFunctionalHistogram(name string, r Registry, f func() HistogramStats) Histogram {
+	c := NewFunctionalHistogram(f)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
 // NewRegisteredHistogram constructs and registers a new StandardHistogram from
 // a Sample.
 func NewRegisteredHistogram(name string, r Registry, s Sample) Histogram {
@@ -52,9 +96,10 @@ type HistogramSnapshot struct {
 	sample *SampleSnapshot
 }
 
-// Clear panics.
-func (*HistogramSnapshot) Clear() Histogram {
-	panic("Clear called on a HistogramSnapshot")
+// Clear panics unless PanicOnSnapshotMutation is false.
+func (h *HistogramSnapshot) Clear() Histogram {
+	snapshotMisuse("Clear called on a HistogramSnapshot")
+	return h
 }
 
 // Count returns the number of samples recorded at the time the snapshot was
@@ -98,9 +143,9 @@ func (h *HistogramSnapshot) StdDev() float64 { return h.sample.StdDev() }
 // Sum returns the sum in the sample at the time the snapshot was taken.
 func (h *HistogramSnapshot) Sum() int64 { return h.sample.Sum() }
 
-// Update panics.
+// Update panics unless PanicOnSnapshotMutation is false.
 func (*HistogramSnapshot) Update(int64) {
-	panic("Update called on a HistogramSnapshot")
+	snapshotMisuse("Update called on a HistogramSnapshot")
 }
 
 // Variance returns the variance of inputs at the time the snapshot was taken.
@@ -153,8 +198,11 @@ func (NilHistogram) Variance() float64 { return 0.0 }
 // StandardHistogram is the standard implementation of a Histogram and uses a
 // Sample to bound its memory use.
 type StandardHistogram struct {
-	sample Sample
-	mutex  sync.Mutex
+	sample    Sample
+	mutex     sync.Mutex
+	recent    recentRing
+	exemplars exemplarSlots
+	outliers  outlierCount
 }
 
 // Clear clears the histogram and its sample.
@@ -204,8 +252,244 @@ func (h *StandardHistogram) StdDev() float64 { return h.sample.StdDev() }
 // Sum returns the sum in the sample.
 func (h *StandardHistogram) Sum() int64 { return h.sample.Sum() }
 
+// CountOutliers starts counting the values recorded over threshold, eg the
+// rare spikes a reservoir might not keep, in a new Counter which it returns
+// for registering alongside the histogram.  Calling it again replaces the
+// Counter.  Clear leaves it be.
+func (h *StandardHistogram) CountOutliers(threshold int64) Counter {
+	return h.outliers.count(float64(threshold))
+}
+
+// Exemplars returns the latest exemplar, if any.
+func (h *StandardHistogram) Exemplars() []Exemplar { return h.exemplars.exemplars() }
+
+// KeepRecent starts keeping the last size values recorded, raw, for Recent,
+// or stops if size is zero or less.  Clear leaves them be.
+func (h *StandardHistogram) KeepRecent(size int) { h.recent.keep(size) }
+
+// Recent returns up to the last n values recorded, oldest first, if
+// KeepRecent has been called.
+func (h *StandardHistogram) Recent(n int) []int64 { return h.recent.recent(n) }
+
 // Update samples a new value.
-func (h *StandardHistogram) Update(v int64) { h.sample.Update(v) }
+func (h *StandardHistogram) Update(v int64) {
+	h.sample.Update(v)
+	h.recent.update(v)
+	h.outliers.update(float64(v))
+}
+
+// UpdateWithExemplar samples v, truncated to an integer, and keeps it as the
+// latest exemplar with the given labels.
+func (h *StandardHistogram) UpdateWithExemplar(v float64, labels map[string]string) {
+	h.Update(int64(v))
+	h.exemplars.set(0, 1, v, labels)
+}
 
 // Variance returns the variance of the values in the sample.
 func (h *StandardHistogram) Variance() float64 { return h.sample.Variance() }
+
+// ExpDecayHistogram is a Histogram backed directly by an ExpDecaySample, for
+// hot paths where calling Update through the Histogram and then the Sample
+// interface costs too much.  Its Update is small enough to be inlined and
+// calls the sample's without dynamic dispatch.  Unlike NewHistogram it
+// ignores UseNilMetrics, since it can't return a NilHistogram.
+type ExpDecayHistogram struct {
+	sample *ExpDecaySample
+	mutex  sync.Mutex
+}
+
+// NewExpDecayHistogram constructs a new ExpDecayHistogram with the given
+// reservoir size and alpha, like
+// NewHistogram(NewExpDecaySample(reservoirSize, alpha)).
+func NewExpDecayHistogram(reservoirSize int, alpha float64) *ExpDecayHistogram {
+	return &ExpDecayHistogram{sample: newExpDecaySample(reservoirSize, alpha)}
+}
+
+// Clear clears the histogram and its sample.
+func (h *ExpDecayHistogram) Clear() Histogram {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	hSnap := &HistogramSnapshot{sample: h.sample.Snapshot().(*SampleSnapshot)}
+	h.sample.Clear()
+	return hSnap
+}
+
+// Count returns the number of samples recorded since the histogram was last
+// cleared.
+func (h *ExpDecayHistogram) Count() int64 { return h.sample.Count() }
+
+// Max returns the maximum value in the sample.
+func (h *ExpDecayHistogram) Max() int64 { return h.sample.Max() }
+
+// Mean returns the mean of the values in the sample.
+func (h *ExpDecayHistogram) Mean() float64 { return h.sample.Mean() }
+
+// Min returns the minimum value in the sample.
+func (h *ExpDecayHistogram) Min() int64 { return h.sample.Min() }
+
+// Percentile returns an arbitrary percentile of the values in the sample.
+func (h *ExpDecayHistogram) Percentile(p float64) float64 {
+	return h.sample.Percentile(p)
+}
+
+// Percentiles returns a slice of arbitrary percentiles of the values in the
+// sample.
+func (h *ExpDecayHistogram) Percentiles(ps []float64) []float64 {
+	return h.sample.Percentiles(ps)
+}
+
+// Sample returns the Sample underlying the histogram.
+func (h *ExpDecayHistogram) Sample() Sample { return h.sample }
+
+// Snapshot returns a read-only copy of the histogram.
+func (h *ExpDecayHistogram) Snapshot() Histogram {
+	return &HistogramSnapshot{sample: h.sample.Snapshot().(*SampleSnapshot)}
+}
+
+// StdDev returns the standard deviation of the values in the sample.
+func (h *ExpDecayHistogram) StdDev() float64 { return h.sample.StdDev() }
+
+// Sum returns the sum in the sample.
+func (h *ExpDecayHistogram) Sum() int64 { return h.sample.Sum() }
+
+// Update samples a new value.
+func (h *ExpDecayHistogram) Update(v int64) { h.sample.Update(v) }
+
+// Variance returns the variance of the values in the sample.
+func (h *ExpDecayHistogram) Variance() float64 { return h.sample.Variance() }
+
+// HistogramStats holds distribution statistics computed outside this package,
+// as returned by the callback of a FunctionalHistogram.  Percentile may be
+// nil, in which case every percentile is reported as zero.
+type HistogramStats struct {
+	Count      int64
+	Min        int64
+	Max        int64
+	Sum        int64
+	Mean       float64
+	StdDev     float64
+	Variance   float64
+	Percentile func(float64) float64
+}
+
+// FunctionalHistogram returns statistics from the given function, which is
+// called every time a statistic or a snapshot is requested.  It's read-only:
+// since the statistics are kept elsewhere, Clear and Update are no-ops, so
+// that code which clears or updates every histogram in a registry can pass
+// over it.
+type FunctionalHistogram struct {
+	stats func() HistogramStats
+}
+
+// Clear returns a snapshot without clearing anything, since the statistics
+// are kept elsewhere.
+func (h *FunctionalHistogram) Clear() Histogram {
+	return h.Snapshot()
+}
+
+// Count returns the number of samples.
+func (h *FunctionalHistogram) Count() int64 { return h.stats().Count }
+
+// Max returns the maximum value.
+func (h *FunctionalHistogram) Max() int64 { return h.stats().Max }
+
+// Mean returns the mean of the values.
+func (h *FunctionalHistogram) Mean() float64 { return h.stats().Mean }
+
+// Min returns the minimum value.
+func (h *FunctionalHistogram) Min() int64 { return h.stats().Min }
+
+// Percentile returns an arbitrary percentile of the values.
+func (h *FunctionalHistogram) Percentile(p float64) float64 {
+	return h.Snapshot().Percentile(p)
+}
+
+// Percentiles returns a slice of arbitrary percentiles of the values.
+func (h *FunctionalHistogram) Percentiles(ps []float64) []float64 {
+	return h.Snapshot().Percentiles(ps)
+}
+
+// Sample is a no-op since there is no underlying sample.
+func (*FunctionalHistogram) Sample() Sample { return NilSample{} }
+
+// Snapshot calls the function once and returns a read-only copy of its
+// result.
+func (h *FunctionalHistogram) Snapshot() Histogram {
+	return &FunctionalHistogramSnapshot{stats: h.stats()}
+}
+
+// StdDev returns the standard deviation of the values.
+func (h *FunctionalHistogram) StdDev() float64 { return h.stats().StdDev }
+
+// Sum returns the sum of the values.
+func (h *FunctionalHistogram) Sum() int64 { return h.stats().Sum }
+
+// Update is a no-op.
+func (*FunctionalHistogram) Update(int64) {}
+
+// Variance returns the variance of the values.
+func (h *FunctionalHistogram) Variance() float64 { return h.stats().Variance }
+
+// FunctionalHistogramSnapshot is a read-only copy of the statistics returned
+// by a FunctionalHistogram.
+type FunctionalHistogramSnapshot struct {
+	stats HistogramStats
+}
+
+// Clear panics unless PanicOnSnapshotMutation is false.
+func (h *FunctionalHistogramSnapshot) Clear() Histogram {
+	snapshotMisuse("Clear called on a FunctionalHistogramSnapshot")
+	return h
+}
+
+// Count returns the number of samples at the time the snapshot was taken.
+func (h *FunctionalHistogramSnapshot) Count() int64 { return h.stats.Count }
+
+// Max returns the maximum value at the time the snapshot was taken.
+func (h *FunctionalHistogramSnapshot) Max() int64 { return h.stats.Max }
+
+// Mean returns the mean value at the time the snapshot was taken.
+func (h *FunctionalHistogramSnapshot) Mean() float64 { return h.stats.Mean }
+
+// Min returns the minimum value at the time the snapshot was taken.
+func (h *FunctionalHistogramSnapshot) Min() int64 { return h.stats.Min }
+
+// Percentile returns an arbitrary percentile of values at the time the
+// snapshot was taken.
+func (h *FunctionalHistogramSnapshot) Percentile(p float64) float64 {
+	if nil == h.stats.Percentile {
+		return 0.0
+	}
+	return h.stats.Percentile(p)
+}
+
+// Percentiles returns a slice of arbitrary percentiles of values at the time
+// the snapshot was taken.
+func (h *FunctionalHistogramSnapshot) Percentiles(ps []float64) []float64 {
+	scores := make([]float64, len(ps))
+	for i, p := range ps {
+		scores[i] = h.Percentile(p)
+	}
+	return scores
+}
+
+// Sample is a no-op since there is no underlying sample.
+func (*FunctionalHistogramSnapshot) Sample() Sample { return NilSample{} }
+
+// Snapshot returns the snapshot.
+func (h *FunctionalHistogramSnapshot) Snapshot() Histogram { return h }
+
+// StdDev returns the standard deviation of values at the time the snapshot was
+// taken.
+func (h *FunctionalHistogramSnapshot) StdDev() float64 { return h.stats.StdDev }
+
+// Sum returns the sum of values at the time the snapshot was taken.
+func (h *FunctionalHistogramSnapshot) Sum() int64 { return h.stats.Sum }
+
+// Update panics unless PanicOnSnapshotMutation is false.
+func (*FunctionalHistogramSnapshot) Update(int64) {
+	snapshotMisuse("Update called on a FunctionalHistogramSnapshot")
+}
+
+// Variance returns the variance of values at the time the snapshot was taken.
+func (h *FunctionalHistogramSnapshot) Variance() float64 { return h.stats.Variance }
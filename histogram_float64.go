@@ -22,10 +22,36 @@ type HistogramFloat64 interface {
 // GetOrRegisterHistogram returns an existing Histogram or constructs and
 // registers a new StandardHistogramFloat64.
 func GetOrRegisterHistogramFloat64(name string, r Registry, s SampleFloat64) HistogramFloat64 {
+	h, err := TryGetOrRegisterHistogramFloat64(name, r, s)
+	if nil != err {
+		return registrationConflict(err, NewHistogramFloat64(s)).(HistogramFloat64)
+	}
+	return h
+}
+
+// GetHistogramFloat64 returns the HistogramFloat64 registered under the given
+// name, or false if there is none or the metric registered there is not a
+// HistogramFloat64.
+func GetHistogramFloat64(name string, r Registry) (HistogramFloat64, bool) {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	h, ok := r.Get(name).(HistogramFloat64)
+	return h, ok
+}
+
+// TryGetOrRegisterHistogramFloat64 is like GetOrRegisterHistogramFloat64 but
+// returns a DuplicateMetric error, rather than panicking, if a metric which
+// is not a HistogramFloat64 is registered under the given name.
+func TryGetOrRegisterHistogramFloat64(name string, r Registry, s SampleFloat64) (HistogramFloat64, error) {
 	if nil == r {
 		r = DefaultRegistry
 	}
-	return r.GetOrRegister(name, func() HistogramFloat64 { return NewHistogramFloat64(s) }).(HistogramFloat64)
+	h, ok := r.GetOrRegister(name, func() HistogramFloat64 { return NewHistogramFloat64(s) }).(HistogramFloat64)
+	if !ok {
+		return nil, DuplicateMetric(name)
+	}
+	return h, nil
 }
 
 // NewHistogram constructs a new StandardHistogramFloat64 from a Sample.
@@ -49,12 +75,13 @@ func NewRegisteredHistogramFloat64(name string, r Registry, s SampleFloat64) His
 
 // HistogramSnapshotFloat64 is a read-only copy of another Histogram.
 type HistogramSnapshotFloat64 struct {
-	sample *SampleFloat64Snapshot
+	sample SampleFloat64
 }
 
-// Clear panics.
-func (*HistogramSnapshotFloat64) Clear() HistogramFloat64 {
-	panic("Clear called on a HistogramSnapshotFloat64")
+// Clear panics unless PanicOnSnapshotMutation is false.
+func (h *HistogramSnapshotFloat64) Clear() HistogramFloat64 {
+	snapshotMisuse("Clear called on a HistogramSnapshotFloat64")
+	return h
 }
 
 // Count returns the number of samples recorded at the time the snapshot was
@@ -98,9 +125,9 @@ func (h *HistogramSnapshotFloat64) StdDev() float64 { return h.sample.StdDev() }
 // Sum returns the sum in the sample at the time the snapshot was taken.
 func (h *HistogramSnapshotFloat64) Sum() float64 { return h.sample.Sum() }
 
-// Update panics.
+// Update panics unless PanicOnSnapshotMutation is false.
 func (*HistogramSnapshotFloat64) Update(float64) {
-	panic("Update called on a HistogramSnapshotFloat64")
+	snapshotMisuse("Update called on a HistogramSnapshotFloat64")
 }
 
 // Variance returns the variance of inputs at the time the snapshot was taken.
@@ -153,15 +180,17 @@ func (NilHistogramFloat64) Variance() float64 { return 0.0 }
 // StandardHistogramFloat64 is the standard implementation of a Histogram and uses a
 // Sample to bound its memory use.
 type StandardHistogramFloat64 struct {
-	sample SampleFloat64
-	mutex  sync.Mutex
+	sample    SampleFloat64
+	mutex     sync.Mutex
+	exemplars exemplarSlots
+	outliers  outlierCount
 }
 
 // Clear clears the histogram and its sample.
 func (h *StandardHistogramFloat64) Clear() HistogramFloat64 {
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
-	hSnap := &HistogramSnapshotFloat64{sample: h.sample.Snapshot().(*SampleFloat64Snapshot)}
+	hSnap := &HistogramSnapshotFloat64{sample: h.sample.Snapshot()}
 	h.sample.Clear()
 	return hSnap
 }
@@ -170,6 +199,18 @@ func (h *StandardHistogramFloat64) Clear() HistogramFloat64 {
 // cleared.
 func (h *StandardHistogramFloat64) Count() int64 { return h.sample.Count() }
 
+// CountOutliers starts counting the values recorded over threshold, eg the
+// rare spikes a reservoir might not keep, in a new Counter which it returns
+// for registering alongside the histogram.  Calling it again replaces the
+// Counter.  Clear leaves it be.
+func (h *StandardHistogramFloat64) CountOutliers(threshold float64) Counter {
+	return h.outliers.count(threshold)
+}
+
+// Exemplars returns the latest exemplar for each bucket, if the sample is a
+// BucketSampleFloat64, or else the latest, if any.
+func (h *StandardHistogramFloat64) Exemplars() []Exemplar { return h.exemplars.exemplars() }
+
 // Max returns the maximum value in the sample.
 func (h *StandardHistogramFloat64) Max() float64 { return h.sample.Max() }
 
@@ -195,7 +236,7 @@ func (h *StandardHistogramFloat64) Sample() SampleFloat64 { return h.sample }
 
 // Snapshot returns a read-only copy of the histogram.
 func (h *StandardHistogramFloat64) Snapshot() HistogramFloat64 {
-	return &HistogramSnapshotFloat64{sample: h.sample.Snapshot().(*SampleFloat64Snapshot)}
+	return &HistogramSnapshotFloat64{sample: h.sample.Snapshot()}
 }
 
 // StdDev returns the standard deviation of the values in the sample.
@@ -205,7 +246,89 @@ func (h *StandardHistogramFloat64) StdDev() float64 { return h.sample.StdDev() }
 func (h *StandardHistogramFloat64) Sum() float64 { return h.sample.Sum() }
 
 // Update samples a new value.
-func (h *StandardHistogramFloat64) Update(v float64) { h.sample.Update(v) }
+func (h *StandardHistogramFloat64) Update(v float64) {
+	h.sample.Update(v)
+	h.outliers.update(v)
+}
+
+// UpdateWithExemplar samples v and keeps it as the latest exemplar, for its
+// bucket if the sample is a BucketSampleFloat64, with the given labels.
+func (h *StandardHistogramFloat64) UpdateWithExemplar(v float64, labels map[string]string) {
+	h.Update(v)
+	i, n := exemplarSlot(h.sample, v)
+	h.exemplars.set(i, n, v, labels)
+}
 
 // Variance returns the variance of the values in the sample.
 func (h *StandardHistogramFloat64) Variance() float64 { return h.sample.Variance() }
+
+// ExpDecayHistogramFloat64 is a HistogramFloat64 backed directly by an
+// ExpDecaySampleFloat64, for hot paths where calling Update through the
+// HistogramFloat64 and then the SampleFloat64 interface costs too much.  Its
+// Update is small enough to be inlined and calls the sample's without dynamic
+// dispatch.  Unlike NewHistogramFloat64 it ignores UseNilMetrics, since it
+// can't return a NilHistogramFloat64.
+type ExpDecayHistogramFloat64 struct {
+	sample *ExpDecaySampleFloat64
+	mutex  sync.Mutex
+}
+
+// NewExpDecayHistogramFloat64 constructs a new ExpDecayHistogramFloat64 with
+// the given reservoir size and alpha, like
+// NewHistogramFloat64(NewExpDecaySampleFloat64(reservoirSize, alpha)).
+func NewExpDecayHistogramFloat64(reservoirSize int, alpha float64) *ExpDecayHistogramFloat64 {
+	return &ExpDecayHistogramFloat64{sample: newExpDecaySampleFloat64(reservoirSize, alpha)}
+}
+
+// Clear clears the histogram and its sample.
+func (h *ExpDecayHistogramFloat64) Clear() HistogramFloat64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	hSnap := &HistogramSnapshotFloat64{sample: h.sample.Snapshot()}
+	h.sample.Clear()
+	return hSnap
+}
+
+// Count returns the number of samples recorded since the histogram was last
+// cleared.
+func (h *ExpDecayHistogramFloat64) Count() int64 { return h.sample.Count() }
+
+// Max returns the maximum value in the sample.
+func (h *ExpDecayHistogramFloat64) Max() float64 { return h.sample.Max() }
+
+// Mean returns the mean of the values in the sample.
+func (h *ExpDecayHistogramFloat64) Mean() float64 { return h.sample.Mean() }
+
+// Min returns the minimum value in the sample.
+func (h *ExpDecayHistogramFloat64) Min() float64 { return h.sample.Min() }
+
+// Percentile returns an arbitrary percentile of the values in the sample.
+func (h *ExpDecayHistogramFloat64) Percentile(p float64) float64 {
+	return h.sample.Percentile(p)
+}
+
+// Percentiles returns a slice of arbitrary percentiles of the values in the
+// sample.
+func (h *ExpDecayHistogramFloat64) Percentiles(ps []float64) []float64 {
+	return h.sample.Percentiles(ps)
+}
+
+// Sample returns the Sample underlying the histogram.
+func (h *ExpDecayHistogramFloat64) Sample() SampleFloat64 { return h.sample }
+
+// Snapshot returns a read-only copy of the histogram.
+func (h *ExpDecayHistogramFloat64) Snapshot() HistogramFloat64 {
+	return &HistogramSnapshotFloat64{sample: h.sample.Snapshot()}
+}
+
+// StdDev returns the standard deviation of the values in the sample.
+func (h *ExpDecayHistogramFloat64) StdDev() float64 { return h.sample.StdDev() }
+
+// Sum returns the sum in the sample.
+func (h *ExpDecayHistogramFloat64) Sum() float64 { return h.sample.Sum() }
+
+// Update samples a new value.
+func (h *ExpDecayHistogramFloat64) Update(v float64) { h.sample.Update(v) }
+
+// Variance returns the variance of the values in the sample.
+func (h *ExpDecayHistogramFloat64) Variance() float64 { return h.sample.Variance() }
@@ -10,6 +10,24 @@ func BenchmarkHistogramFloat64(b *testing.B) {
 	}
 }
 
+// BenchmarkHistogramFloat64ExpDecay and BenchmarkExpDecayHistogramFloat64
+// compare updating through the interfaces with the concrete type.
+func BenchmarkHistogramFloat64ExpDecay(b *testing.B) {
+	h := NewHistogramFloat64(NewExpDecaySampleFloat64(1028, 0.015))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.Update(float64(i))
+	}
+}
+
+func BenchmarkExpDecayHistogramFloat64(b *testing.B) {
+	h := NewExpDecayHistogramFloat64(1028, 0.015)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.Update(float64(i))
+	}
+}
+
 func TestGetOrRegisterHistogramFloat64(t *testing.T) {
 	r := NewRegistry()
 	s := NewUniformSampleFloat64(100)
@@ -27,6 +45,18 @@ func TestHistogramFloat6410000(t *testing.T) {
 	testHistogramFloat6410000(t, h)
 }
 
+func TestExpDecayHistogramFloat6410000(t *testing.T) {
+	var h HistogramFloat64 = NewExpDecayHistogramFloat64(100000, 0.015)
+	for i := 1; i <= 10000; i++ {
+		h.Update(float64(i))
+	}
+	testHistogramFloat6410000(t, h)
+	testHistogramFloat6410000(t, h.Clear())
+	if count := h.Count(); 0 != count {
+		t.Errorf("h.Count(): 0 != %v\n", count)
+	}
+}
+
 func TestHistogramFloat64Empty(t *testing.T) {
 	h := NewHistogramFloat64(NewUniformSampleFloat64(100))
 	if count := h.Count(); 0 != count {
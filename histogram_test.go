@@ -10,6 +10,24 @@ func BenchmarkHistogram(b *testing.B) {
 	}
 }
 
+// BenchmarkHistogramExpDecay and BenchmarkExpDecayHistogram compare
+// updating through the interfaces with the concrete type.
+func BenchmarkHistogramExpDecay(b *testing.B) {
+	h := NewHistogram(NewExpDecaySample(1028, 0.015))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.Update(int64(i))
+	}
+}
+
+func BenchmarkExpDecayHistogram(b *testing.B) {
+	h := NewExpDecayHistogram(1028, 0.015)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.Update(int64(i))
+	}
+}
+
 func TestGetOrRegisterHistogram(t *testing.T) {
 	r := NewRegistry()
 	s := NewUniformSample(100)
@@ -19,6 +37,79 @@ func TestGetOrRegisterHistogram(t *testing.T) {
 	}
 }
 
+func TestTryGetOrRegisterHistogram(t *testing.T) {
+	r := NewRegistry()
+	s := NewUniformSample(100)
+	NewRegisteredHistogram("foo", r, s).Update(47)
+	if h, err := TryGetOrRegisterHistogram("foo", r, s); nil != err || 1 != h.Count() {
+		t.Fatal(h, err)
+	}
+	if h, ok := GetHistogram("foo", r); !ok || 1 != h.Count() {
+		t.Fatal(h, ok)
+	}
+	NewRegisteredCounter("bar", r)
+	if h, err := TryGetOrRegisterHistogram("bar", r, s); DuplicateMetric("bar") != err {
+		t.Fatal(h, err)
+	}
+}
+
+func TestFunctionalHistogram(t *testing.T) {
+	var calls int
+	h := NewFunctionalHistogram(func() HistogramStats {
+		calls++
+		return HistogramStats{
+			Count:      10000,
+			Min:        1,
+			Max:        10000,
+			Mean:       5000.5,
+			StdDev:     2886.751331514372,
+			Percentile: func(p float64) float64 { return p * 10001 },
+		}
+	})
+	snapshot := h.Snapshot()
+	if 1 != calls {
+		t.Errorf("calls: 1 != %v\n", calls)
+	}
+	testHistogram10000(t, snapshot)
+	if 1 != calls {
+		t.Errorf("calls: 1 != %v\n", calls)
+	}
+	if count := h.Count(); 10000 != count {
+		t.Errorf("h.Count(): 10000 != %v\n", count)
+	}
+	if 2 != calls {
+		t.Errorf("calls: 2 != %v\n", calls)
+	}
+}
+
+func TestFunctionalHistogramNilPercentile(t *testing.T) {
+	h := NewFunctionalHistogram(func() HistogramStats { return HistogramStats{Count: 1} })
+	if ps := h.Percentiles([]float64{0.5, 0.99}); 0.0 != ps[0] || 0.0 != ps[1] {
+		t.Errorf("h.Percentiles(): [0 0] != %v\n", ps)
+	}
+}
+
+func TestFunctionalHistogramReadOnly(t *testing.T) {
+	h := NewFunctionalHistogram(func() HistogramStats { return HistogramStats{Count: 3} })
+	h.Update(1)
+	if count := h.Clear().Count(); 3 != count {
+		t.Errorf("h.Clear().Count(): 3 != %v\n", count)
+	}
+	if count := h.Count(); 3 != count {
+		t.Errorf("h.Count(): 3 != %v\n", count)
+	}
+}
+
+func TestGetOrRegisterFunctionalHistogram(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredFunctionalHistogram("foo", r, func() HistogramStats {
+		return HistogramStats{Count: 47}
+	})
+	if h := GetOrRegisterHistogram("foo", r, nil); 47 != h.Count() {
+		t.Fatal(h)
+	}
+}
+
 func TestHistogram10000(t *testing.T) {
 	h := NewHistogram(NewUniformSample(100000))
 	for i := 1; i <= 10000; i++ {
@@ -27,6 +118,18 @@ func TestHistogram10000(t *testing.T) {
 	testHistogram10000(t, h)
 }
 
+func TestExpDecayHistogram10000(t *testing.T) {
+	var h Histogram = NewExpDecayHistogram(100000, 0.015)
+	for i := 1; i <= 10000; i++ {
+		h.Update(int64(i))
+	}
+	testHistogram10000(t, h)
+	testHistogram10000(t, h.Clear())
+	if count := h.Count(); 0 != count {
+		t.Errorf("h.Count(): 0 != %v\n", count)
+	}
+}
+
 func TestHistogramEmpty(t *testing.T) {
 	h := NewHistogram(NewUniformSample(100))
 	if count := h.Count(); 0 != count {
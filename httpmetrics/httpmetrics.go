@@ -0,0 +1,215 @@
+// Package httpmetrics provides net/http middleware which records the
+// standard metrics of an HTTP server in a go-metrics registry.
+package httpmetrics
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/go-metrics"
+)
+
+// Config provides a container with configuration parameters for a
+// Middleware.
+type Config struct {
+	Registry    metrics.Registry           // Registry in which to register the metrics, defaulting to metrics.DefaultRegistry
+	Prefix      string                     // Prefix of the metrics' names, defaulting to "http"
+	Route       func(*http.Request) string // Names the route of a request for Handler, defaulting to "all"; keep routes few, eg the pattern matched rather than the path
+	SizeBuckets []float64                  // Upper bounds in bytes of the size histograms' buckets, defaulting to powers of four from 64B to 16MiB
+}
+
+// Middleware records, for each route, the metrics
+//
+//	<prefix>.<route>.Requests      a Counter of the requests
+//	<prefix>.<route>.Errors4xx     a Counter of the responses with 4xx statuses
+//	<prefix>.<route>.Errors5xx     a Counter of the responses with 5xx statuses
+//	<prefix>.<route>.InFlight      a GaugeCounter of the requests being served
+//	<prefix>.<route>.Duration      a Timer of the time taken to serve requests
+//	<prefix>.<route>.RequestSize   a HistogramFloat64 of the request bodies' sizes in bytes
+//	<prefix>.<route>.ResponseSize  a HistogramFloat64 of the response bodies' sizes in bytes
+//
+// registering them on a route's first request.  A handler which panics is
+// counted as having responded 500.
+type Middleware struct {
+	config Config
+	mutex  sync.Mutex
+	routes map[string]*routeMetrics
+}
+
+type routeMetrics struct {
+	requests     metrics.Counter
+	errors4xx    metrics.Counter
+	errors5xx    metrics.Counter
+	inFlight     metrics.GaugeCounter
+	duration     metrics.Timer
+	requestSize  metrics.HistogramFloat64
+	responseSize metrics.HistogramFloat64
+}
+
+// NewMiddleware constructs a new Middleware.  It panics if c.SizeBuckets
+// don't pass metrics.ValidateBuckets.
+func NewMiddleware(c Config) *Middleware {
+	if nil == c.Registry {
+		c.Registry = metrics.DefaultRegistry
+	}
+	if "" == c.Prefix {
+		c.Prefix = "http"
+	}
+	if nil == c.Route {
+		c.Route = func(*http.Request) string { return "all" }
+	}
+	if 0 == len(c.SizeBuckets) {
+		c.SizeBuckets = metrics.ExponentialBuckets(64, 4, 10)
+	}
+	if err := metrics.ValidateBuckets(c.SizeBuckets); nil != err {
+		panic(err)
+	}
+	return &Middleware{config: c, routes: make(map[string]*routeMetrics)}
+}
+
+// Handler wraps h, recording each request under the route named by the
+// configured Route.  It has the signature of the middleware of most routers.
+func (m *Middleware) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.serve(m.config.Route(r), h, w, r)
+	})
+}
+
+// RouteHandler wraps h, recording each request under the given route.
+func (m *Middleware) RouteHandler(route string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.serve(route, h, w, r)
+	})
+}
+
+func (m *Middleware) serve(route string, h http.Handler, w http.ResponseWriter, r *http.Request) {
+	rm := m.route(route)
+	rm.requests.Inc(1)
+	rm.inFlight.Inc(1)
+	t := time.Now()
+	body := &countingBody{ReadCloser: r.Body}
+	if nil != r.Body {
+		r.Body = body
+	}
+	rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+	done := false
+	defer func() {
+		if !done {
+			rw.status = http.StatusInternalServerError // panicking
+		}
+		rm.duration.UpdateSince(t)
+		rm.inFlight.Dec(1)
+		switch {
+		case rw.status >= 500:
+			rm.errors5xx.Inc(1)
+		case rw.status >= 400:
+			rm.errors4xx.Inc(1)
+		}
+		size := body.n
+		if r.ContentLength > size {
+			size = r.ContentLength // not all read
+		}
+		rm.requestSize.Update(float64(size))
+		rm.responseSize.Update(float64(rw.n))
+	}()
+	h.ServeHTTP(rw, r)
+	done = true
+}
+
+// route returns the metrics of the given route, registering them if they
+// haven't been already.
+func (m *Middleware) route(route string) *routeMetrics {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if rm, ok := m.routes[route]; ok {
+		return rm
+	}
+	r, prefix := m.config.Registry, m.config.Prefix+"."+route+"."
+	rm := &routeMetrics{
+		requests:     metrics.GetOrRegisterCounter(prefix+"Requests", r),
+		errors4xx:    metrics.GetOrRegisterCounter(prefix+"Errors4xx", r),
+		errors5xx:    metrics.GetOrRegisterCounter(prefix+"Errors5xx", r),
+		inFlight:     metrics.GetOrRegisterGaugeCounter(prefix+"InFlight", r),
+		duration:     metrics.GetOrRegisterTimer(prefix+"Duration", r),
+		requestSize:  metrics.GetOrRegisterHistogramFloat64(prefix+"RequestSize", r, metrics.NewBucketSampleFloat64(m.config.SizeBuckets)),
+		responseSize: metrics.GetOrRegisterHistogramFloat64(prefix+"ResponseSize", r, metrics.NewBucketSampleFloat64(m.config.SizeBuckets)),
+	}
+	m.routes[route] = rm
+	return rm
+}
+
+// countingBody counts the bytes read from a request body.
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+// responseWriter records the status and counts the bytes of a response.
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	n           int64
+	wroteHeader bool
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if !w.wroteHeader && status >= 200 { // not informational
+		w.status, w.wroteHeader = status, true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// Flush flushes the response if the underlying ResponseWriter can, so that
+// streaming handlers keep working.
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack takes over the connection if the underlying ResponseWriter can, so
+// that websocket and other handlers which upgrade the connection keep
+// working.  The response is recorded as 101 Switching Protocols.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("httpmetrics: ResponseWriter does not implement http.Hijacker")
+	}
+	conn, rw, err := h.Hijack()
+	if nil == err && !w.wroteHeader {
+		w.status, w.wroteHeader = http.StatusSwitchingProtocols, true
+	}
+	return conn, rw, err
+}
+
+// Push initiates an HTTP/2 server push if the underlying ResponseWriter
+// can, and otherwise returns http.ErrNotSupported.
+func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// Unwrap returns the underlying ResponseWriter, for http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,120 @@
+package httpmetrics
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/launchdarkly/go-metrics"
+)
+
+func TestMiddleware(t *testing.T) {
+	r := metrics.NewRegistry()
+	m := NewMiddleware(Config{Registry: r, Route: func(req *http.Request) string {
+		return strings.Trim(req.URL.Path, "/")
+	}})
+	h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/ok":
+			if count := r.Get("http.ok.InFlight").(metrics.GaugeCounter).Count(); 1 != count {
+				t.Errorf("InFlight: 1 != %v\n", count)
+			}
+			ioutil.ReadAll(req.Body)
+			io.WriteString(w, "hello")
+		case "/missing":
+			http.NotFound(w, req)
+		case "/fail":
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	for _, path := range []string{"/ok", "/ok", "/missing", "/fail"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", path, strings.NewReader("body")))
+	}
+
+	for name, want := range map[string]int64{
+		"http.ok.Requests":       2,
+		"http.ok.Errors4xx":      0,
+		"http.ok.Errors5xx":      0,
+		"http.missing.Requests":  1,
+		"http.missing.Errors4xx": 1,
+		"http.fail.Errors5xx":    1,
+	} {
+		if count := r.Get(name).(metrics.Counter).Count(); want != count {
+			t.Errorf("%s: %v != %v\n", name, want, count)
+		}
+	}
+	if count := r.Get("http.ok.InFlight").(metrics.GaugeCounter).Count(); 0 != count {
+		t.Errorf("InFlight: 0 != %v\n", count)
+	}
+	if count := r.Get("http.ok.Duration").(metrics.Timer).Count(); 2 != count {
+		t.Errorf("Duration: 2 != %v\n", count)
+	}
+	if sum := r.Get("http.ok.RequestSize").(metrics.HistogramFloat64).Sum(); 8 != sum {
+		t.Errorf("RequestSize: 8 != %v\n", sum)
+	}
+	if sum := r.Get("http.ok.ResponseSize").(metrics.HistogramFloat64).Sum(); 10 != sum {
+		t.Errorf("ResponseSize: 10 != %v\n", sum)
+	}
+}
+
+func TestMiddlewareHijack(t *testing.T) {
+	r := metrics.NewRegistry()
+	m := NewMiddleware(Config{Registry: r})
+	h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: test\r\nConnection: Upgrade\r\n\r\n")
+		rw.Flush()
+	}))
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: test\r\nUpgrade: test\r\nConnection: Upgrade\r\n\r\n")
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if http.StatusSwitchingProtocols != resp.StatusCode {
+		t.Errorf("status: 101 != %v\n", resp.StatusCode)
+	}
+
+	var hijackErr error
+	h = m.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _, hijackErr = w.(http.Hijacker).Hijack()
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if nil == hijackErr {
+		t.Error("Hijack of a ResponseRecorder: nil error")
+	}
+}
+
+func TestMiddlewarePanic(t *testing.T) {
+	r := metrics.NewRegistry()
+	h := NewMiddleware(Config{Registry: r}).RouteHandler("panic", http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	}))
+	func() {
+		defer func() { recover() }()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+	if count := r.Get("http.panic.Errors5xx").(metrics.Counter).Count(); 1 != count {
+		t.Errorf("Errors5xx: 1 != %v\n", count)
+	}
+	if count := r.Get("http.panic.InFlight").(metrics.GaugeCounter).Count(); 0 != count {
+		t.Errorf("InFlight: 0 != %v\n", count)
+	}
+}
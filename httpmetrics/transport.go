@@ -0,0 +1,125 @@
+package httpmetrics
+
+import (
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/go-metrics"
+)
+
+// TransportConfig provides a container with configuration parameters for a
+// Transport.
+type TransportConfig struct {
+	Registry metrics.Registry // Registry in which to register the metrics, defaulting to metrics.DefaultRegistry
+	Prefix   string           // Prefix of the metrics' names, defaulting to "http.client"
+	MaxHosts int              // Most hosts to record separately, defaulting to 100, beyond which requests are recorded under the host "other"
+}
+
+// Transport is an http.RoundTripper which records, for each host requested,
+// the metrics
+//
+//	<prefix>.<host>.Duration    a Timer of the time taken to get responses' headers
+//	<prefix>.<host>.Status2xx   a Counter of the responses with 2xx statuses, and so on to Status5xx
+//	<prefix>.<host>.Errors      a Counter of the requests which got no response
+//	<prefix>.<host>.ConnReused  a MovingAverage of whether the last 100 requests reused a connection
+//	<prefix>.<host>.DNSSeconds  a GaugeFloat64 of how long the last DNS lookup took
+//
+// registering them on a host's first request.  The dots in host names are
+// replaced with underscores, eg http.client.api_example_com.Duration.
+type Transport struct {
+	base   http.RoundTripper
+	config TransportConfig
+	mutex  sync.Mutex
+	hosts  map[string]*hostMetrics
+}
+
+type hostMetrics struct {
+	duration   metrics.Timer
+	statuses   [4]metrics.Counter // 2xx to 5xx
+	errors     metrics.Counter
+	connReused metrics.MovingAverage
+	dnsSeconds metrics.GaugeFloat64
+}
+
+// NewTransport constructs a new Transport which makes requests with base,
+// or http.DefaultTransport if it's nil.
+func NewTransport(base http.RoundTripper, c TransportConfig) *Transport {
+	if nil == base {
+		base = http.DefaultTransport
+	}
+	if nil == c.Registry {
+		c.Registry = metrics.DefaultRegistry
+	}
+	if "" == c.Prefix {
+		c.Prefix = "http.client"
+	}
+	if c.MaxHosts <= 0 {
+		c.MaxHosts = 100
+	}
+	return &Transport{base: base, config: c, hosts: make(map[string]*hostMetrics)}
+}
+
+// RoundTrip makes the request with the underlying RoundTripper, recording it.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	hm := t.host(req.URL.Hostname())
+	var dnsStart time.Time
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			hm.dnsSeconds.Update(time.Since(dnsStart).Seconds())
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				hm.connReused.Update(1)
+			} else {
+				hm.connReused.Update(0)
+			}
+		},
+	}))
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	hm.duration.UpdateSince(start)
+	if nil != err {
+		hm.errors.Inc(1)
+		return resp, err
+	}
+	if class := resp.StatusCode / 100; 2 <= class && class <= 5 {
+		hm.statuses[class-2].Inc(1)
+	}
+	return resp, nil
+}
+
+// host returns the metrics of the given host, registering them if they
+// haven't been already.
+func (t *Transport) host(host string) *hostMetrics {
+	host = strings.Replace(host, ".", "_", -1)
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if hm, ok := t.hosts[host]; ok {
+		return hm
+	}
+	if len(t.hosts) >= t.config.MaxHosts {
+		host = "other"
+		if hm, ok := t.hosts[host]; ok {
+			return hm
+		}
+	}
+	r, prefix := t.config.Registry, t.config.Prefix+"."+host+"."
+	hm := &hostMetrics{
+		duration:   metrics.GetOrRegisterTimer(prefix+"Duration", r),
+		errors:     metrics.GetOrRegisterCounter(prefix+"Errors", r),
+		connReused: metrics.GetOrRegisterMovingAverage(prefix+"ConnReused", r, 100),
+		dnsSeconds: metrics.GetOrRegisterGaugeFloat64(prefix+"DNSSeconds", r),
+	}
+	for i := range hm.statuses {
+		hm.statuses[i] = metrics.GetOrRegisterCounter(prefix+"Status"+strconv.Itoa(i+2)+"xx", r)
+	}
+	t.hosts[host] = hm
+	return hm
+}
@@ -0,0 +1,67 @@
+package httpmetrics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/launchdarkly/go-metrics"
+)
+
+func TestTransport(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if "/missing" == req.URL.Path {
+			http.NotFound(w, req)
+		}
+	}))
+	defer s.Close()
+	r := metrics.NewRegistry()
+	c := &http.Client{Transport: NewTransport(nil, TransportConfig{Registry: r, MaxHosts: 1})}
+	for _, url := range []string{
+		s.URL + "/",
+		s.URL + "/missing",
+		strings.Replace(s.URL, "127.0.0.1", "localhost", 1) + "/",
+	} {
+		resp, err := c.Get(url)
+		if nil != err {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	for name, want := range map[string]int64{
+		"http.client.127_0_0_1.Status2xx": 1,
+		"http.client.127_0_0_1.Status4xx": 1,
+		"http.client.127_0_0_1.Errors":    0,
+		"http.client.other.Status2xx":     1,
+	} {
+		if count := r.Get(name).(metrics.Counter).Count(); want != count {
+			t.Errorf("%s: %v != %v\n", name, want, count)
+		}
+	}
+	if count := r.Get("http.client.127_0_0_1.Duration").(metrics.Timer).Count(); 2 != count {
+		t.Errorf("Duration: 2 != %v\n", count)
+	}
+	if reused := r.Get("http.client.127_0_0_1.ConnReused").(metrics.MovingAverage).Value(); 0.5 != reused {
+		t.Errorf("ConnReused: 0.5 != %v\n", reused)
+	}
+	if nil != r.Get("http.client.localhost.Duration") {
+		t.Error("localhost: registered beyond MaxHosts")
+	}
+}
+
+func TestTransportError(t *testing.T) {
+	s := httptest.NewServer(http.NotFoundHandler())
+	s.Close()
+	r := metrics.NewRegistry()
+	c := &http.Client{Transport: NewTransport(nil, TransportConfig{Registry: r})}
+	if _, err := c.Get(s.URL); nil == err {
+		t.Fatal("err: want != nil")
+	}
+	if count := r.Get("http.client.127_0_0_1.Errors").(metrics.Counter).Count(); 1 != count {
+		t.Errorf("Errors: 1 != %v\n", count)
+	}
+}
@@ -0,0 +1,100 @@
+package metrics
+
+import "sort"
+
+// InfoMetrics hold static string key-value pairs, eg version and commit, so
+// that build metadata travels with the metrics.  Reporters with labels or
+// tags export them as a gauge of 1 labelled with the pairs, the Prometheus
+// info pattern, and the others print the pairs.
+type InfoMetric interface {
+	Labels() map[string]string
+	Snapshot() InfoMetric
+}
+
+// GetOrRegisterInfoMetric returns an existing InfoMetric or constructs and
+// registers a new StandardInfoMetric of the given labels.
+func GetOrRegisterInfoMetric(name string, r Registry, labels map[string]string) InfoMetric {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	m, ok := r.GetOrRegister(name, func() InfoMetric { return NewInfoMetric(labels) }).(InfoMetric)
+	if !ok {
+		return registrationConflict(DuplicateMetric(name), NewInfoMetric(labels)).(InfoMetric)
+	}
+	return m
+}
+
+// GetInfoMetric returns the InfoMetric registered under the given name, or
+// false if there is none or the metric registered there is not an
+// InfoMetric.
+func GetInfoMetric(name string, r Registry) (InfoMetric, bool) {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	m, ok := r.Get(name).(InfoMetric)
+	return m, ok
+}
+
+// NewInfoMetric constructs a new StandardInfoMetric holding a copy of the
+// given labels.
+func NewInfoMetric(labels map[string]string) InfoMetric {
+	if UseNilMetrics {
+		return NilInfoMetric{}
+	}
+	m := &StandardInfoMetric{labels: make(map[string]string, len(labels))}
+	for k, v := range labels {
+		m.labels[k] = v
+	}
+	return m
+}
+
+// NewRegisteredInfoMetric constructs and registers a new
+// StandardInfoMetric.
+func NewRegisteredInfoMetric(name string, r Registry, labels map[string]string) InfoMetric {
+	c := NewInfoMetric(labels)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// NilInfoMetric is a no-op InfoMetric.
+type NilInfoMetric struct{}
+
+// Labels is a no-op.
+func (NilInfoMetric) Labels() map[string]string { return map[string]string{} }
+
+// Snapshot is a no-op.
+func (NilInfoMetric) Snapshot() InfoMetric { return NilInfoMetric{} }
+
+// StandardInfoMetric is the standard implementation of an InfoMetric.  Since
+// it never changes it serves as its own snapshot.
+type StandardInfoMetric struct {
+	labels map[string]string
+}
+
+// Labels returns a copy of the labels.
+func (m *StandardInfoMetric) Labels() map[string]string {
+	labels := make(map[string]string, len(m.labels))
+	for k, v := range m.labels {
+		labels[k] = v
+	}
+	return labels
+}
+
+// Snapshot returns the metric itself.
+func (m *StandardInfoMetric) Snapshot() InfoMetric { return m }
+
+// eachInfoLabel calls f for each of the metric's labels in order of key.
+func eachInfoLabel(m InfoMetric, f func(k, v string)) {
+	labels := m.Labels()
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		f(k, labels[k])
+	}
+}
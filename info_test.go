@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInfoMetric(t *testing.T) {
+	labels := map[string]string{"version": "1.2.3"}
+	m := NewInfoMetric(labels)
+	labels["version"] = "4.5.6"
+	m.Labels()["commit"] = "abc123"
+	if got := m.Snapshot().Labels(); !reflect.DeepEqual(map[string]string{"version": "1.2.3"}, got) {
+		t.Errorf("m.Snapshot().Labels(): %v\n", got)
+	}
+}
+
+func TestInfoMetricReported(t *testing.T) {
+	r := NewRegistry()
+	m := NewInfoMetric(map[string]string{"version": "1.2.3", "commit": "abc123"})
+	RegisterWithMetadata("build_info", r, m, Metadata{Tags: map[string]string{"region": "us-east-1"}})
+	if got, ok := GetInfoMetric("build_info", r); !ok || m != got || m != GetOrRegisterInfoMetric("build_info", r, nil) {
+		t.Fatal(got, ok)
+	}
+
+	points := CollectPoints(r, 0)
+	want := map[string]string{"version": "1.2.3", "commit": "abc123", "region": "us-east-1"}
+	if 1 != len(points) || 1.0 != points[0].Value || !reflect.DeepEqual(want, points[0].Tags) {
+		t.Fatalf("%+v\n", points)
+	}
+
+	var b bytes.Buffer
+	if err := writePrometheusText(&b, r, 0, ""); nil != err {
+		t.Fatal(err)
+	}
+	if s := "build_info{commit=\"abc123\",region=\"us-east-1\",version=\"1.2.3\"} 1\n"; !strings.HasSuffix(b.String(), s) {
+		t.Errorf("%q doesn't end %q\n", b.String(), s)
+	}
+
+	var s strings.Builder
+	WriteOnce(r, &s)
+	if want := "info build_info\n  commit:      abc123\n  version:     1.2.3\n"; want != s.String() {
+		t.Errorf("%q != %q\n", want, s.String())
+	}
+}
@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"time"
@@ -9,86 +10,159 @@ import (
 // MarshalJSON returns a byte slice containing a JSON representation of all
 // the metrics in the Registry.
 func (r *StandardRegistry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(registryJSON(r, 0))
+}
+
+// MarshalJSON returns a byte slice containing a JSON representation of all
+// the metrics in the Registry.
+func (r *ShardedRegistry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(registryJSON(r, 0))
+}
+
+// registryJSON builds the JSON representation of the metrics in r, with
+// timings in `scale` units unless the timer carries its own DurationUnit.
+// With a scale of zero, timers without a DurationUnit are represented in
+// nanoseconds, their minimum and maximum as integers, as MarshalJSON always
+// has.
+func registryJSON(r Registry, scale time.Duration) map[string]map[string]interface{} {
 	data := make(map[string]map[string]interface{})
 	r.Each(func(name string, i interface{}) {
-		values := make(map[string]interface{})
-		switch metric := i.(type) {
-		case Counter:
-			values["count"] = metric.Count()
-		case GaugeCounter:
-			values["value"] = metric.Count()
-		case Gauge:
-			values["value"] = metric.Value()
-		case GaugeFloat64:
-			values["value"] = metric.Value()
-		case Healthcheck:
-			values["error"] = nil
-			metric.Check()
-			if err := metric.Error(); nil != err {
-				values["error"] = metric.Error().Error()
-			}
-		case Histogram:
-			h := metric.Snapshot()
-			ps := h.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
-			values["count"] = h.Count()
-			values["min"] = h.Min()
-			values["max"] = h.Max()
-			values["mean"] = h.Mean()
-			values["stddev"] = h.StdDev()
-			values["median"] = ps[0]
-			values["75%"] = ps[1]
-			values["95%"] = ps[2]
-			values["99%"] = ps[3]
-			values["99.9%"] = ps[4]
-		case HistogramFloat64:
-			h := metric.Snapshot()
-			ps := h.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
-			values["count"] = h.Count()
-			values["min"] = h.Min()
-			values["max"] = h.Max()
-			values["mean"] = h.Mean()
-			values["stddev"] = h.StdDev()
-			values["median"] = ps[0]
-			values["75%"] = ps[1]
-			values["95%"] = ps[2]
-			values["99%"] = ps[3]
-			values["99.9%"] = ps[4]
-		case Meter:
-			m := metric.Snapshot()
-			values["count"] = m.Count()
-			values["1m.rate"] = m.Rate1()
-			values["5m.rate"] = m.Rate5()
-			values["15m.rate"] = m.Rate15()
-			values["mean.rate"] = m.RateMean()
-		case Timer:
-			t := metric.Snapshot()
-			ps := t.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
-			values["count"] = t.Count()
-			values["min"] = t.Min()
-			values["max"] = t.Max()
-			values["mean"] = t.Mean()
-			values["stddev"] = t.StdDev()
-			values["median"] = ps[0]
-			values["75%"] = ps[1]
-			values["95%"] = ps[2]
-			values["99%"] = ps[3]
-			values["99.9%"] = ps[4]
-			values["1m.rate"] = t.Rate1()
-			values["5m.rate"] = t.Rate5()
-			values["15m.rate"] = t.Rate15()
-			values["mean.rate"] = t.RateMean()
-		}
-		data[name] = values
+		data[name] = metricJSON(i, scale)
 	})
-	return json.Marshal(data)
+	return data
+}
+
+// metricJSON builds the JSON representation of the given metric, as
+// registryJSON does each in a registry.
+func metricJSON(i interface{}, scale time.Duration) map[string]interface{} {
+	values := make(map[string]interface{})
+	switch metric := i.(type) {
+	case Counter:
+		values["count"] = metric.Count()
+	case Uint64Counter:
+		values["count"] = metric.Count()
+	case BigCounter:
+		values["count"] = metric.Count()
+	case GaugeCounter:
+		values["value"] = metric.Count()
+	case Gauge:
+		values["value"] = metric.Value()
+	case GaugeFloat64:
+		values["value"] = metric.Value()
+	case MovingAverage:
+		values["value"] = metric.Value()
+	case RateGauge:
+		values["value"] = metric.Rate()
+	case Cardinality:
+		values["value"] = metric.Snapshot().Estimate()
+	case BoolGauge:
+		values["value"] = boolGaugeValue(metric)
+	case EnumGauge:
+		eachEnumState(metric, func(state string, v int64) {
+			values[state] = v
+		})
+	case InfoMetric:
+		for k, v := range metric.Labels() {
+			values[k] = v
+		}
+	case Healthcheck:
+		values["error"] = nil
+		metric.Check()
+		if err := metric.Error(); nil != err {
+			values["error"] = metric.Error().Error()
+		}
+	case Histogram:
+		h := metric.Snapshot()
+		ps := h.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
+		values["count"] = h.Count()
+		values["min"] = h.Min()
+		values["max"] = h.Max()
+		values["mean"] = h.Mean()
+		values["stddev"] = h.StdDev()
+		values["median"] = ps[0]
+		values["75%"] = ps[1]
+		values["95%"] = ps[2]
+		values["99%"] = ps[3]
+		values["99.9%"] = ps[4]
+	case HistogramFloat64:
+		h := metric.Snapshot()
+		ps := h.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
+		values["count"] = h.Count()
+		values["min"] = h.Min()
+		values["max"] = h.Max()
+		values["mean"] = h.Mean()
+		values["stddev"] = h.StdDev()
+		values["median"] = ps[0]
+		values["75%"] = ps[1]
+		values["95%"] = ps[2]
+		values["99%"] = ps[3]
+		values["99.9%"] = ps[4]
+	case SummaryFloat64:
+		s := metric.Snapshot()
+		values["count"] = s.Count()
+		values["min"] = s.Min()
+		values["max"] = s.Max()
+		values["mean"] = s.Mean()
+		values["stddev"] = s.StdDev()
+		values["sum"] = s.Sum()
+	case TopK:
+		for _, e := range metric.Top() {
+			values[e.Key] = e.Count
+		}
+	case Meter:
+		m := metric.Snapshot()
+		values["count"] = m.Count()
+		values["1m.rate"] = m.Rate1()
+		values["5m.rate"] = m.Rate5()
+		values["15m.rate"] = m.Rate15()
+		values["mean.rate"] = m.RateMean()
+	case Timer:
+		t := metric.Snapshot()
+		ts := newTimerScale(t, scale)
+		ps := t.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
+		values["count"] = t.Count()
+		values["min"] = ts.extreme(t.Min())
+		values["max"] = ts.extreme(t.Max())
+		values["mean"] = ts.scale(t.Mean())
+		values["stddev"] = ts.scale(t.StdDev())
+		values["median"] = ts.scale(ps[0])
+		values["75%"] = ts.scale(ps[1])
+		values["95%"] = ts.scale(ps[2])
+		values["99%"] = ts.scale(ps[3])
+		values["99.9%"] = ts.scale(ps[4])
+		values["1m.rate"] = t.Rate1()
+		values["5m.rate"] = t.Rate5()
+		values["15m.rate"] = t.Rate15()
+		values["mean.rate"] = t.RateMean()
+	}
+	return values
 }
 
 // WriteJSON writes metrics from the given registry  periodically to the
 // specified io.Writer as JSON.
 func WriteJSON(r Registry, d time.Duration, w io.Writer) {
-	for _ = range time.Tick(d) {
-		WriteJSONOnce(r, w)
-	}
+	NewReporter(d, func() { WriteJSONOnce(r, w) }).Run(context.Background())
+}
+
+// WriteJSONScaled is like WriteJSON but writes timings in `scale` units (eg
+// time.Millisecond) rather than nanos.
+func WriteJSONScaled(r Registry, d time.Duration, scale time.Duration, w io.Writer) {
+	NewJSONReporter(r, d, scale, w).Run(context.Background())
+}
+
+// NewJSONReporter constructs a new Reporter which writes just as
+// WriteJSONScaled does.
+func NewJSONReporter(r Registry, d time.Duration, scale time.Duration, w io.Writer) *Reporter {
+	return newExporterReporter(r, NewJSONExporter(scale, w), SchedulerConfig{Interval: d})
+}
+
+// NewJSONExporter constructs a new Exporter which writes the registry it's
+// given as WriteJSONOnceScaled does, for use with a Scheduler, returning any
+// error writing.
+func NewJSONExporter(scale time.Duration, w io.Writer) Exporter {
+	return ExporterFunc(func(r Registry) error {
+		return json.NewEncoder(w).Encode(registryJSON(r, scale))
+	})
 }
 
 // WriteJSONOnce writes metrics from the given registry to the specified
@@ -97,6 +171,13 @@ func WriteJSONOnce(r Registry, w io.Writer) {
 	json.NewEncoder(w).Encode(r)
 }
 
+// WriteJSONOnceScaled writes metrics from the given registry to the specified
+// io.Writer as JSON, with timings in `scale` units unless the timer carries its
+// own DurationUnit.
+func WriteJSONOnceScaled(r Registry, scale time.Duration, w io.Writer) {
+	json.NewEncoder(w).Encode(registryJSON(r, scale))
+}
+
 func (p *PrefixedRegistry) MarshalJSON() ([]byte, error) {
 	return json.Marshal(p.underlying)
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"testing"
+	"time"
 )
 
 func TestRegistryMarshallJSON(t *testing.T) {
@@ -26,3 +27,41 @@ func TestRegistryWriteJSONOnce(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestRegistryWriteJSONOnceScaled(t *testing.T) {
+	r := NewRegistry()
+	tm := NewTimer()
+	tm.Update(1500 * time.Microsecond)
+	r.Register("timer", tm)
+	tmu := NewTimerWithDurationUnit(time.Second)
+	tmu.Update(1500 * time.Millisecond)
+	r.Register("timer.seconds", tmu)
+	b := &bytes.Buffer{}
+	WriteJSONOnceScaled(r, time.Millisecond, b)
+	var data map[string]map[string]interface{}
+	if err := json.Unmarshal(b.Bytes(), &data); nil != err {
+		t.Fatal(err)
+	}
+	if max := data["timer"]["max"]; 1.5 != max {
+		t.Errorf("timer max: 1.5 != %v\n", max)
+	}
+	if max := data["timer.seconds"]["max"]; 1.5 != max {
+		t.Errorf("timer.seconds max: 1.5 != %v\n", max)
+	}
+}
+
+func TestRegistryMarshalJSONTimer(t *testing.T) {
+	r := NewRegistry()
+	tm := NewTimer()
+	tm.Update(1500 * time.Microsecond)
+	r.Register("timer", tm)
+	b, err := json.Marshal(r)
+	if nil != err {
+		t.Fatal(err)
+	}
+	for _, field := range []string{`"max":1500000,`, `"min":1500000,`, `"mean":1500000,`} {
+		if !bytes.Contains(b, []byte(field)) {
+			t.Errorf("no %s in %s\n", field, b)
+		}
+	}
+}
@@ -1,6 +1,12 @@
 package metrics
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
 	"time"
 )
 
@@ -8,90 +14,405 @@ type Logger interface {
 	Printf(format string, v ...interface{})
 }
 
+// StructuredLogger receives one record per metric, suitable for routing into
+// a structured logging library.  The fields always include "type", eg
+// "timer", and otherwise use the same keys as the LogFormatJSON format.
+type StructuredLogger interface {
+	LogMetric(name string, fields map[string]interface{})
+}
+
+// StructuredLoggerFunc adapts an ordinary function to the StructuredLogger
+// interface.
+type StructuredLoggerFunc func(name string, fields map[string]interface{})
+
+// LogMetric calls f(name, fields).
+func (f StructuredLoggerFunc) LogMetric(name string, fields map[string]interface{}) {
+	f(name, fields)
+}
+
+// LogFormat selects how each metric is rendered by LogWithConfig.
+type LogFormat int
+
+const (
+	// LogFormatText prints each metric over several human-readable lines.
+	LogFormatText LogFormat = iota
+	// LogFormatKeyValue prints each metric on one line of key=value pairs.
+	LogFormatKeyValue
+	// LogFormatJSON prints each metric as one JSON object.
+	LogFormatJSON
+)
+
+// LogConfig provides a container with configuration parameters for the
+// Log reporter.
+type LogConfig struct {
+	Registry      Registry         // Registry to be logged
+	FlushInterval time.Duration    // Flush interval
+	DurationUnit  time.Duration    // Time conversion unit for durations
+	Logger        Logger           // Logger to print to
+	Format        LogFormat        // Output format
+	Structured    StructuredLogger // If set, used instead of Logger and Format
+	OnlyChanged   bool             // Skip metrics unchanged since the last flush
+	Include       []string         // If set, only log names matching one of these patterns
+	Exclude       []string         // Never log names matching one of these patterns
+	IncludeRegexp []*regexp.Regexp // If set, only log names matching one of these or one of Include
+	ExcludeRegexp []*regexp.Regexp // Never log names matching one of these
+	Temporality   Temporality      // Whether to log counts as they stand, the default, or since the last flush
+}
+
 func Log(r Registry, freq time.Duration, l Logger) {
 	LogScaled(r, freq, time.Nanosecond, l)
 }
 
 // Output each metric in the given registry periodically using the given
-// logger. Print timings in `scale` units (eg time.Millisecond) rather than nanos.
+// logger. Print timings in `scale` units (eg time.Millisecond) rather than nanos,
+// unless the timer carries its own DurationUnit.
 func LogScaled(r Registry, freq time.Duration, scale time.Duration, l Logger) {
-	du := float64(scale)
-	duSuffix := scale.String()[1:]
-
-	for _ = range time.Tick(freq) {
-		r.Each(func(name string, i interface{}) {
-			switch metric := i.(type) {
-			case Counter:
-				l.Printf("counter %s\n", name)
-				l.Printf("  count:       %9d\n", metric.Count())
-			case GaugeCounter:
-				l.Printf("value %s\n", name)
-				l.Printf("  count:       %9d\n", metric.Count())
-			case Gauge:
-				l.Printf("gauge %s\n", name)
-				l.Printf("  value:       %9d\n", metric.Value())
-			case GaugeFloat64:
-				l.Printf("gauge %s\n", name)
-				l.Printf("  value:       %f\n", metric.Value())
-			case Healthcheck:
-				metric.Check()
-				l.Printf("healthcheck %s\n", name)
-				l.Printf("  error:       %v\n", metric.Error())
-			case Histogram:
-				h := metric.Snapshot()
-				ps := h.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
-				l.Printf("histogram %s\n", name)
-				l.Printf("  count:       %9d\n", h.Count())
-				l.Printf("  min:         %9d\n", h.Min())
-				l.Printf("  max:         %9d\n", h.Max())
-				l.Printf("  mean:        %12.2f\n", h.Mean())
-				l.Printf("  stddev:      %12.2f\n", h.StdDev())
-				l.Printf("  median:      %12.2f\n", ps[0])
-				l.Printf("  75%%:         %12.2f\n", ps[1])
-				l.Printf("  95%%:         %12.2f\n", ps[2])
-				l.Printf("  99%%:         %12.2f\n", ps[3])
-				l.Printf("  99.9%%:       %12.2f\n", ps[4])
-			case HistogramFloat64:
-				h := metric.Snapshot()
-				ps := h.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
-				l.Printf("histogram %s\n", name)
-				l.Printf("  count:       %9d\n", h.Count())
-				l.Printf("  min:         %12.2f\n", h.Min())
-				l.Printf("  max:         %12.2f\n", h.Max())
-				l.Printf("  mean:        %12.2f\n", h.Mean())
-				l.Printf("  stddev:      %12.2f\n", h.StdDev())
-				l.Printf("  median:      %12.2f\n", ps[0])
-				l.Printf("  75%%:         %12.2f\n", ps[1])
-				l.Printf("  95%%:         %12.2f\n", ps[2])
-				l.Printf("  99%%:         %12.2f\n", ps[3])
-				l.Printf("  99.9%%:       %12.2f\n", ps[4])
-			case Meter:
-				m := metric.Snapshot()
-				l.Printf("meter %s\n", name)
-				l.Printf("  count:       %9d\n", m.Count())
-				l.Printf("  1-min rate:  %12.2f\n", m.Rate1())
-				l.Printf("  5-min rate:  %12.2f\n", m.Rate5())
-				l.Printf("  15-min rate: %12.2f\n", m.Rate15())
-				l.Printf("  mean rate:   %12.2f\n", m.RateMean())
-			case Timer:
-				t := metric.Snapshot()
-				ps := t.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
-				l.Printf("timer %s\n", name)
-				l.Printf("  count:       %9d\n", t.Count())
-				l.Printf("  min:         %12.2f%s\n", float64(t.Min())/du, duSuffix)
-				l.Printf("  max:         %12.2f%s\n", float64(t.Max())/du, duSuffix)
-				l.Printf("  mean:        %12.2f%s\n", t.Mean()/du, duSuffix)
-				l.Printf("  stddev:      %12.2f%s\n", t.StdDev()/du, duSuffix)
-				l.Printf("  median:      %12.2f%s\n", ps[0]/du, duSuffix)
-				l.Printf("  75%%:         %12.2f%s\n", ps[1]/du, duSuffix)
-				l.Printf("  95%%:         %12.2f%s\n", ps[2]/du, duSuffix)
-				l.Printf("  99%%:         %12.2f%s\n", ps[3]/du, duSuffix)
-				l.Printf("  99.9%%:       %12.2f%s\n", ps[4]/du, duSuffix)
-				l.Printf("  1-min rate:  %12.2f\n", t.Rate1())
-				l.Printf("  5-min rate:  %12.2f\n", t.Rate5())
-				l.Printf("  15-min rate: %12.2f\n", t.Rate15())
-				l.Printf("  mean rate:   %12.2f\n", t.RateMean())
+	LogWithConfig(LogConfig{
+		Registry:      r,
+		FlushInterval: freq,
+		DurationUnit:  scale,
+		Logger:        l,
+	})
+}
+
+// LogStructured outputs each metric in the given registry periodically as a
+// record passed to the given StructuredLogger.
+func LogStructured(r Registry, freq time.Duration, l StructuredLogger) {
+	LogWithConfig(LogConfig{
+		Registry:      r,
+		FlushInterval: freq,
+		DurationUnit:  time.Nanosecond,
+		Structured:    l,
+	})
+}
+
+// LogWithConfig is a blocking exporter function just like Log, but it takes
+// a LogConfig instead.
+//
+// Include and Exclude hold patterns in the syntax of path.Match, eg
+// "runtime.*".  With OnlyChanged set, counters and gauges are logged when
+// their value differs from the last flush and histograms, meters and timers
+// when their count does, so that idle metrics whose rates are merely decaying
+// stay quiet.
+func LogWithConfig(c LogConfig) {
+	NewLogReporter(c).Run(context.Background())
+}
+
+// NewLogReporter constructs a new Reporter which logs just as LogWithConfig
+// does.
+func NewLogReporter(c LogConfig) *Reporter {
+	return newExporterReporter(c.Registry, NewLogExporter(c), SchedulerConfig{Interval: c.FlushInterval})
+}
+
+// NewLogExporter constructs a new Exporter which logs the registry it's given
+// as LogWithConfig does, for use with a Scheduler.  c.Registry and
+// c.FlushInterval are ignored.
+func NewLogExporter(c LogConfig) Exporter {
+	return newLogReporter(c)
+}
+
+// logReporter holds the state LogWithConfig keeps between flushes.
+type logReporter struct {
+	LogConfig
+	last map[string]string
+}
+
+func newLogReporter(c LogConfig) *logReporter {
+	return &logReporter{LogConfig: c, last: make(map[string]string)}
+}
+
+// flush logs the configured registry.
+func (lr *logReporter) flush() {
+	lr.Export(lr.Registry)
+}
+
+// Export logs the given registry.
+func (lr *logReporter) Export(r Registry) error {
+	r = lr.Temporality.registry(lr.filter().registry(r))
+	seen := make(map[string]bool)
+	r.EachSorted(func(name string, i interface{}) {
+		m, ok := metricFields(name, i, lr.DurationUnit)
+		if !ok {
+			return
+		}
+		if lr.OnlyChanged {
+			seen[name] = true
+			key := m.changeKey()
+			if last, ok := lr.last[name]; ok && last == key {
+				return
 			}
+			lr.last[name] = key
+		}
+		if nil != lr.Structured {
+			lr.Structured.LogMetric(name, m.values())
+			return
+		}
+		switch lr.Format {
+		case LogFormatKeyValue:
+			lr.Logger.Printf("%s\n", m.keyValue())
+		case LogFormatJSON:
+			lr.Logger.Printf("%s\n", m.json())
+		default:
+			m.printText(lr.Logger)
+		}
+	})
+	for name := range lr.last {
+		if !seen[name] {
+			delete(lr.last, name)
+		}
+	}
+	return nil
+}
+
+// filter returns the MetricFilter of the configured inclusions and
+// exclusions.
+func (lr *logReporter) filter() MetricFilter {
+	return MetricFilter{Include: lr.Include, Exclude: lr.Exclude, IncludeRegexp: lr.IncludeRegexp, ExcludeRegexp: lr.ExcludeRegexp}
+}
+
+// metricField is one value of a metric as rendered by the reporters in this
+// file.  Label and verb reproduce the human-readable text format while key is
+// used by the structured formats.
+type metricField struct {
+	key   string
+	label string
+	verb  string
+	unit  string
+	value interface{}
+}
+
+// loggedMetric is a metric broken down into its fields.
+type loggedMetric struct {
+	kind   string
+	name   string
+	fields []metricField
+}
+
+// metricFields snapshots i and breaks it down into fields, converting timer
+// durations to `scale` units unless the timer carries its own DurationUnit.
+// It returns false for values which are not metrics.
+func metricFields(name string, i interface{}, scale time.Duration) (loggedMetric, bool) {
+	m := loggedMetric{name: name}
+	add := func(key, label, verb string, value interface{}) {
+		m.fields = append(m.fields, metricField{key: key, label: label, verb: verb, value: value})
+	}
+	switch metric := i.(type) {
+	case Counter:
+		m.kind = "counter"
+		add("count", "count", "%9d", metric.Count())
+	case Uint64Counter:
+		m.kind = "counter"
+		add("count", "count", "%9d", metric.Count())
+	case BigCounter:
+		m.kind = "counter"
+		add("count", "count", "%9d", metric.Count())
+	case GaugeCounter:
+		m.kind = "value"
+		add("count", "count", "%9d", metric.Count())
+	case Gauge:
+		m.kind = "gauge"
+		add("value", "value", "%9d", metric.Value())
+	case GaugeFloat64:
+		m.kind = "gauge"
+		add("value", "value", "%f", metric.Value())
+	case MovingAverage:
+		m.kind = "gauge"
+		add("value", "value", "%f", metric.Value())
+	case RateGauge:
+		m.kind = "gauge"
+		add("value", "value", "%f", metric.Rate())
+	case Cardinality:
+		m.kind = "gauge"
+		add("value", "value", "%9d", metric.Snapshot().Estimate())
+	case BoolGauge:
+		m.kind = "gauge"
+		add("value", "value", "%9d", boolGaugeValue(metric))
+	case EnumGauge:
+		m.kind = "enum"
+		eachEnumState(metric, func(state string, v int64) {
+			add(state, state, "%9d", v)
 		})
+	case InfoMetric:
+		m.kind = "info"
+		eachInfoLabel(metric, func(k, v string) {
+			add(k, k, "%s", v)
+		})
+	case Healthcheck:
+		metric.Check()
+		m.kind = "healthcheck"
+		var err interface{}
+		if e := metric.Error(); nil != e {
+			err = e.Error()
+		}
+		add("error", "error", "%v", err)
+	case Histogram:
+		h := metric.Snapshot()
+		ps := h.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
+		m.kind = "histogram"
+		add("count", "count", "%9d", h.Count())
+		add("min", "min", "%9d", h.Min())
+		add("max", "max", "%9d", h.Max())
+		add("mean", "mean", "%12.2f", h.Mean())
+		add("stddev", "stddev", "%12.2f", h.StdDev())
+		m.addPercentiles(ps, 1, "")
+	case HistogramFloat64:
+		h := metric.Snapshot()
+		ps := h.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
+		m.kind = "histogram"
+		add("count", "count", "%9d", h.Count())
+		add("min", "min", "%12.2f", h.Min())
+		add("max", "max", "%12.2f", h.Max())
+		add("mean", "mean", "%12.2f", h.Mean())
+		add("stddev", "stddev", "%12.2f", h.StdDev())
+		m.addPercentiles(ps, 1, "")
+	case SummaryFloat64:
+		s := metric.Snapshot()
+		m.kind = "summary"
+		add("count", "count", "%9d", s.Count())
+		add("min", "min", "%12.2f", s.Min())
+		add("max", "max", "%12.2f", s.Max())
+		add("mean", "mean", "%12.2f", s.Mean())
+		add("stddev", "stddev", "%12.2f", s.StdDev())
+		add("sum", "sum", "%12.2f", s.Sum())
+	case TopK:
+		m.kind = "topk"
+		for _, e := range metric.Top() {
+			add(e.Key, e.Key, "%9d", e.Count)
+		}
+	case Meter:
+		mt := metric.Snapshot()
+		m.kind = "meter"
+		add("count", "count", "%9d", mt.Count())
+		m.addRates(mt.Rate1(), mt.Rate5(), mt.Rate15(), mt.RateMean())
+	case Timer:
+		t := metric.Snapshot()
+		unit := timerDurationUnit(t, scale)
+		du := float64(unit)
+		suffix := durationUnitSuffix(unit)
+		ps := t.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
+		m.kind = "timer"
+		add("count", "count", "%9d", t.Count())
+		for _, f := range []struct {
+			key string
+			v   float64
+		}{
+			{"min", float64(t.Min())},
+			{"max", float64(t.Max())},
+			{"mean", t.Mean()},
+			{"stddev", t.StdDev()},
+		} {
+			m.fields = append(m.fields, metricField{key: f.key, label: f.key, verb: "%12.2f", unit: suffix, value: f.v / du})
+		}
+		m.addPercentiles(ps, du, suffix)
+		m.addRates(t.Rate1(), t.Rate5(), t.Rate15(), t.RateMean())
+		if ut, ok := t.(UtilizationTimer); ok {
+			m.fields = append(m.fields, metricField{key: "total", label: "total", verb: "%12.2f", unit: suffix, value: float64(ut.TotalDuration()) / du})
+			add("utilization", "utilization", "%12.4f", ut.Utilization())
+		}
+	default:
+		return m, false
+	}
+	return m, true
+}
+
+func (m *loggedMetric) addPercentiles(ps []float64, du float64, unit string) {
+	for i, f := range []struct{ key, label string }{
+		{"median", "median"},
+		{"p75", "75%"},
+		{"p95", "95%"},
+		{"p99", "99%"},
+		{"p999", "99.9%"},
+	} {
+		m.fields = append(m.fields, metricField{key: f.key, label: f.label, verb: "%12.2f", unit: unit, value: ps[i] / du})
+	}
+}
+
+func (m *loggedMetric) addRates(rate1, rate5, rate15, rateMean float64) {
+	for _, f := range []struct {
+		key, label string
+		v          float64
+	}{
+		{"rate1", "1-min rate", rate1},
+		{"rate5", "5-min rate", rate5},
+		{"rate15", "15-min rate", rate15},
+		{"rate_mean", "mean rate", rateMean},
+	} {
+		m.fields = append(m.fields, metricField{key: f.key, label: f.label, verb: "%12.2f", value: f.v})
+	}
+}
+
+// changeKey summarises the metric for change detection.  Metrics which count
+// events are considered changed only when their count changes.
+func (m *loggedMetric) changeKey() string {
+	switch m.kind {
+	case "histogram", "meter", "timer":
+		return fmt.Sprint(m.fields[0].value)
+	}
+	var buf bytes.Buffer
+	for _, f := range m.fields {
+		fmt.Fprint(&buf, f.value, " ")
+	}
+	return buf.String()
+}
+
+func (m *loggedMetric) printText(l Logger) {
+	l.Printf("%s %s\n", m.kind, m.name)
+	for _, f := range m.fields {
+		l.Printf("  %-13s"+f.verb+"%s\n", f.label+":", f.value, f.unit)
+	}
+}
+
+func (m *loggedMetric) keyValue() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "type=%s name=%s", m.kind, strconv.Quote(m.name))
+	for _, f := range m.fields {
+		fmt.Fprintf(&buf, " %s=", f.key)
+		switch v := f.value.(type) {
+		case string:
+			buf.WriteString(strconv.Quote(v))
+		case float64:
+			buf.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+		case nil:
+			buf.WriteString("nil")
+		default:
+			fmt.Fprint(&buf, v)
+		}
+	}
+	if "timer" == m.kind {
+		fmt.Fprintf(&buf, " unit=%s", m.unit())
+	}
+	return buf.String()
+}
+
+// values returns the metric's fields keyed for the structured formats.
+func (m *loggedMetric) values() map[string]interface{} {
+	data := make(map[string]interface{}, len(m.fields)+2)
+	data["type"] = m.kind
+	for _, f := range m.fields {
+		data[f.key] = f.value
+	}
+	if "timer" == m.kind {
+		data["unit"] = m.unit()
+	}
+	return data
+}
+
+func (m *loggedMetric) json() string {
+	data := m.values()
+	data["name"] = m.name
+	b, err := json.Marshal(data)
+	if nil != err {
+		return fmt.Sprintf(`{"type":%q,"name":%q,"error":%q}`, m.kind, m.name, err.Error())
+	}
+	return string(b)
+}
+
+// unit returns the duration unit of a timer's fields.
+func (m *loggedMetric) unit() string {
+	for _, f := range m.fields {
+		if "" != f.unit {
+			return f.unit
+		}
 	}
+	return ""
 }
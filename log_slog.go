@@ -0,0 +1,60 @@
+//go:build go1.21
+// +build go1.21
+
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// LogSlog outputs each metric in the given registry periodically as one
+// record on the given slog.Logger, with the metric's values as typed
+// attributes.
+func LogSlog(r Registry, freq time.Duration, l *slog.Logger) {
+	LogWithConfig(LogConfig{
+		Registry:      r,
+		FlushInterval: freq,
+		DurationUnit:  time.Nanosecond,
+		Structured:    SlogLogger(l),
+	})
+}
+
+// SlogLogger returns a StructuredLogger which writes each metric as an info
+// record with the message "metric" and attributes name, type and then the
+// metric's values in alphabetical order, eg count, max, median, p99.  Use it
+// with LogConfig for filtering and change detection.
+func SlogLogger(l *slog.Logger) StructuredLogger {
+	return slogLogger{l}
+}
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+func (l slogLogger) LogMetric(name string, fields map[string]interface{}) {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		if "type" != k {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	attrs := make([]slog.Attr, 0, len(fields)+1)
+	attrs = append(attrs, slog.String("name", name), slog.Any("type", fields["type"]))
+	for _, k := range keys {
+		switch v := fields[k].(type) {
+		case int64:
+			attrs = append(attrs, slog.Int64(k, v))
+		case float64:
+			attrs = append(attrs, slog.Float64(k, v))
+		case string:
+			attrs = append(attrs, slog.String(k, v))
+		default:
+			attrs = append(attrs, slog.Any(k, v))
+		}
+	}
+	l.logger.LogAttrs(context.Background(), slog.LevelInfo, "metric", attrs...)
+}
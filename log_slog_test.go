@@ -0,0 +1,34 @@
+//go:build go1.21
+// +build go1.21
+
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestSlogLogger(t *testing.T) {
+	r := NewRegistry()
+	h := NewRegisteredHistogram("foo", r, NewUniformSample(100))
+	for i := 1; i <= 100; i++ {
+		h.Update(int64(i))
+	}
+	var buf bytes.Buffer
+	newLogReporter(LogConfig{
+		Registry:   r,
+		Structured: SlogLogger(slog.New(slog.NewJSONHandler(&buf, nil))),
+	}).flush()
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); nil != err {
+		t.Fatal(err)
+	}
+	if "metric" != record["msg"] || "foo" != record["name"] || "histogram" != record["type"] {
+		t.Errorf("unexpected record: %s", buf.String())
+	}
+	if 100.0 != record["count"] || 99.99 != record["p99"] {
+		t.Errorf("unexpected record: %s", buf.String())
+	}
+}
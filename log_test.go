@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+type bufferLogger struct {
+	bytes.Buffer
+}
+
+func (l *bufferLogger) Printf(format string, v ...interface{}) {
+	fmt.Fprintf(&l.Buffer, format, v...)
+}
+
+func TestLogText(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("foo", r).Inc(47)
+	tm := NewRegisteredTimer("bar", r)
+	tm.Update(2 * time.Millisecond)
+	l := &bufferLogger{}
+	newLogReporter(LogConfig{Registry: r, Logger: l, Include: []string{"foo"}}).flush()
+	if s := l.String(); "counter foo\n  count:              47\n" != s {
+		t.Errorf("unexpected output:\n%s", s)
+	}
+	l.Reset()
+	newLogReporter(LogConfig{Registry: r, Logger: l, DurationUnit: time.Millisecond, Exclude: []string{"f*"}}).flush()
+	if s := l.String(); !strings.HasPrefix(s, "timer bar\n  count:               1\n  min:                 2.00ms\n") {
+		t.Errorf("unexpected output:\n%s", s)
+	}
+}
+
+func TestLogKeyValue(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredGauge("foo", r).Update(47)
+	l := &bufferLogger{}
+	newLogReporter(LogConfig{Registry: r, Logger: l, Format: LogFormatKeyValue}).flush()
+	if s := l.String(); "type=gauge name=\"foo\" value=47\n" != s {
+		t.Errorf("unexpected output: %q", s)
+	}
+}
+
+func TestLogJSON(t *testing.T) {
+	r := NewRegistry()
+	tm := NewRegisteredTimer("foo", r)
+	tm.Update(3 * time.Second)
+	l := &bufferLogger{}
+	newLogReporter(LogConfig{Registry: r, Logger: l, Format: LogFormatJSON, DurationUnit: time.Second}).flush()
+	var data map[string]interface{}
+	if err := json.Unmarshal(l.Bytes(), &data); nil != err {
+		t.Fatal(err)
+	}
+	if "timer" != data["type"] || "foo" != data["name"] || 3.0 != data["max"] || "s" != data["unit"] {
+		t.Errorf("unexpected output: %s", l.String())
+	}
+}
+
+func TestLogOnlyChanged(t *testing.T) {
+	r := NewRegistry()
+	c := NewRegisteredCounter("foo", r)
+	m := NewRegisteredMeter("bar", r)
+	l := &bufferLogger{}
+	lr := newLogReporter(LogConfig{Registry: r, Logger: l, Format: LogFormatKeyValue, OnlyChanged: true})
+	lr.flush()
+	if n := strings.Count(l.String(), "\n"); 2 != n {
+		t.Errorf("first flush: 2 != %v lines\n%s", n, l.String())
+	}
+	l.Reset()
+	m.(*StandardMeter).tick() // Rates decay but the count is unchanged.
+	lr.flush()
+	if s := l.String(); "" != s {
+		t.Errorf("unexpected output: %q", s)
+	}
+	c.Inc(1)
+	lr.flush()
+	if s := l.String(); "type=counter name=\"foo\" count=1\n" != s {
+		t.Errorf("unexpected output: %q", s)
+	}
+}
+
+func TestLogStructured(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("foo", r).Inc(47)
+	NewRegisteredTimer("bar", r).Update(2 * time.Millisecond)
+	records := make(map[string]map[string]interface{})
+	newLogReporter(LogConfig{
+		Registry:     r,
+		DurationUnit: time.Millisecond,
+		Structured: StructuredLoggerFunc(func(name string, fields map[string]interface{}) {
+			records[name] = fields
+		}),
+	}).flush()
+	if fields := records["foo"]; "counter" != fields["type"] || int64(47) != fields["count"] {
+		t.Errorf("foo: %v", fields)
+	}
+	if fields := records["bar"]; "timer" != fields["type"] || 2.0 != fields["max"] || "ms" != fields["unit"] {
+		t.Errorf("bar: %v", fields)
+	}
+}
@@ -0,0 +1,224 @@
+package metrics
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// MapRule is one step of a Mapper.  It's given a metric's name and tags,
+// which it may modify since they're the Mapper's own copy, and returns the
+// metric's new name, or false to drop the metric.
+type MapRule func(name string, tags map[string]string) (string, bool)
+
+// Mapper maps the names and tags of metrics by applying its rules in order,
+// so that one registry can be reported to backends with different naming
+// conventions, eg dotted paths to OpenTSDB and snake_case to Prometheus,
+// through a view of it for each made with NewMappedRegistry.
+type Mapper []MapRule
+
+// Map returns the given metric's new name and tags, or false if it's dropped.
+// The given tags are not modified.
+func (m Mapper) Map(name string, tags map[string]string) (string, map[string]string, bool) {
+	mapped := make(map[string]string, len(tags))
+	for k, v := range tags {
+		mapped[k] = v
+	}
+	for _, rule := range m {
+		var ok bool
+		if name, ok = rule(name, mapped); !ok {
+			return "", nil, false
+		}
+	}
+	if 0 == len(mapped) {
+		mapped = nil
+	}
+	return name, mapped, true
+}
+
+// MapRename renames the metric named from to.
+func MapRename(from, to string) MapRule {
+	return func(name string, tags map[string]string) (string, bool) {
+		if from == name {
+			return to, true
+		}
+		return name, true
+	}
+}
+
+// MapRenameRegexp replaces the matches of re in names with repl, which may
+// refer to submatches as in regexp.Regexp's ReplaceAllString.
+func MapRenameRegexp(re *regexp.Regexp, repl string) MapRule {
+	return func(name string, tags map[string]string) (string, bool) {
+		return re.ReplaceAllString(name, repl), true
+	}
+}
+
+// MapDrop drops metrics whose names match one of the given patterns, in the
+// syntax of path.Match, eg "runtime.*".
+func MapDrop(patterns ...string) MapRule {
+	return func(name string, tags map[string]string) (string, bool) {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, name); ok {
+				return name, false
+			}
+		}
+		return name, true
+	}
+}
+
+// MapRelabel renames the tag with key from to to, replacing any tag with
+// key to.
+func MapRelabel(from, to string) MapRule {
+	return func(name string, tags map[string]string) (string, bool) {
+		if v, ok := tags[from]; ok {
+			delete(tags, from)
+			tags[to] = v
+		}
+		return name, true
+	}
+}
+
+// MapDropTags removes the tags with the given keys.
+func MapDropTags(keys ...string) MapRule {
+	return func(name string, tags map[string]string) (string, bool) {
+		for _, k := range keys {
+			delete(tags, k)
+		}
+		return name, true
+	}
+}
+
+// MapAddTags adds the given tags to every metric which doesn't already have
+// a tag with the same key.
+func MapAddTags(add map[string]string) MapRule {
+	return func(name string, tags map[string]string) (string, bool) {
+		for k, v := range add {
+			if _, ok := tags[k]; !ok {
+				tags[k] = v
+			}
+		}
+		return name, true
+	}
+}
+
+// MapReplaceSeparator replaces every old in names with new, eg "." with "_"
+// for snake_case or with "/" for MQTT topics.
+func MapReplaceSeparator(old, new string) MapRule {
+	return func(name string, tags map[string]string) (string, bool) {
+		return strings.Replace(name, old, new, -1), true
+	}
+}
+
+// NewMappedRegistry returns a view of r whose iteration methods, eg Each and
+// EachWithMetadata, pass each metric by the name and with the tags m maps it
+// to, skipping those m drops.  Get, Metadata and SetMetadata take mapped
+// names, while the methods which register and unregister metrics are r's
+// own.  m should map no two metrics to the same name.
+func NewMappedRegistry(r Registry, m Mapper) Registry {
+	return mappedRegistry{Registry: r, mapper: m}
+}
+
+type mappedRegistry struct {
+	Registry
+	mapper Mapper
+}
+
+// each calls f for each metric m doesn't drop with its mapped name and
+// Metadata and its name in the underlying registry.
+func (r mappedRegistry) each(f func(name, original string, i interface{}, md Metadata)) {
+	EachWithMetadata(r.Registry, func(original string, i interface{}, md Metadata) {
+		name, tags, ok := r.mapper.Map(original, md.Tags)
+		if !ok {
+			return
+		}
+		md.Tags = tags
+		f(name, original, i, md)
+	})
+}
+
+// registered returns the metrics by their mapped names.
+func (r mappedRegistry) registered() map[string]interface{} {
+	metrics := make(map[string]interface{})
+	r.each(func(name, _ string, i interface{}, _ Metadata) {
+		metrics[name] = i
+	})
+	return metrics
+}
+
+// original returns the name in the underlying registry of the metric mapped
+// to name.
+func (r mappedRegistry) original(name string) (string, bool) {
+	var original string
+	ok := false
+	r.each(func(mapped, o string, _ interface{}, _ Metadata) {
+		if !ok && mapped == name {
+			original, ok = o, true
+		}
+	})
+	return original, ok
+}
+
+func (r mappedRegistry) Each(f func(string, interface{})) {
+	r.each(func(name, _ string, i interface{}, _ Metadata) {
+		f(name, i)
+	})
+}
+
+func (r mappedRegistry) EachErr(f func(string, interface{}) error) error {
+	var err error
+	r.each(func(name, _ string, i interface{}, _ Metadata) {
+		if nil == err {
+			err = f(name, i)
+		}
+	})
+	return err
+}
+
+func (r mappedRegistry) EachSortedErr(f func(string, interface{}) error) error {
+	return eachSortedErr(r.registered(), f)
+}
+
+func (r mappedRegistry) EachSorted(f func(string, interface{})) {
+	eachSortedErr(r.registered(), ignoreErr(f))
+}
+
+func (r mappedRegistry) EachMatching(pattern string, f func(string, interface{})) error {
+	return eachMatching(r, pattern, f)
+}
+
+func (r mappedRegistry) VisitSnapshots(v Visitor) {
+	visitEach(r, v)
+}
+
+func (r mappedRegistry) EachWithMetadata(f func(string, interface{}, Metadata)) {
+	r.each(func(name, _ string, i interface{}, md Metadata) {
+		f(name, i, md)
+	})
+}
+
+func (r mappedRegistry) Get(name string) interface{} {
+	if original, ok := r.original(name); ok {
+		return r.Registry.Get(original)
+	}
+	return nil
+}
+
+func (r mappedRegistry) Metadata(name string) (Metadata, bool) {
+	var md Metadata
+	ok := false
+	r.each(func(mapped, _ string, _ interface{}, m Metadata) {
+		if !ok && mapped == name {
+			md, ok = m, true
+		}
+	})
+	return md, ok
+}
+
+func (r mappedRegistry) SetMetadata(name string, md Metadata) {
+	if mr, ok := r.Registry.(MetadataRegistry); ok {
+		if original, ok := r.original(name); ok {
+			mr.SetMetadata(original, md)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"bytes"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestMapper(t *testing.T) {
+	m := Mapper{
+		MapDrop("runtime.*"),
+		MapRename("ld.stream.conns", "ld.stream.connections"),
+		MapRenameRegexp(regexp.MustCompile(`^ld\.`), "launchdarkly."),
+		MapRelabel("region", "aws_region"),
+		MapDropTags("pod"),
+		MapAddTags(map[string]string{"env": "prod", "aws_region": "us-west-2"}),
+		MapReplaceSeparator(".", "_"),
+	}
+	tags := map[string]string{"region": "us-east-1", "pod": "a"}
+	name, mapped, ok := m.Map("ld.stream.conns", tags)
+	if !ok {
+		t.Fatal("dropped")
+	}
+	if "launchdarkly_stream_connections" != name {
+		t.Errorf("name: launchdarkly_stream_connections != %v\n", name)
+	}
+	if want := map[string]string{"aws_region": "us-east-1", "env": "prod"}; !reflect.DeepEqual(want, mapped) {
+		t.Errorf("tags: %v != %v\n", want, mapped)
+	}
+	if 2 != len(tags) || "us-east-1" != tags["region"] {
+		t.Errorf("tags modified: %v\n", tags)
+	}
+	if _, _, ok := m.Map("runtime.MemStats.Alloc", nil); ok {
+		t.Error("runtime.MemStats.Alloc: not dropped")
+	}
+}
+
+func TestMappedRegistry(t *testing.T) {
+	r := NewRegistry()
+	c := NewRegisteredCounter("http.requests", r)
+	c.Inc(47)
+	RegisterWithMetadata("http.latency", r, NewGauge(), Metadata{Tags: map[string]string{"route": "/"}})
+	NewRegisteredGauge("runtime.goroutines", r)
+	m := NewMappedRegistry(r, Mapper{
+		MapDrop("runtime.*"),
+		MapReplaceSeparator(".", "_"),
+		MapAddTags(map[string]string{"env": "prod"}),
+	})
+	var names []string
+	m.EachSorted(func(name string, i interface{}) {
+		names = append(names, name)
+	})
+	if want := []string{"http_latency", "http_requests"}; !reflect.DeepEqual(want, names) {
+		t.Errorf("names: %v != %v\n", want, names)
+	}
+	if m.Get("http_requests") != c {
+		t.Error("Get: not the counter")
+	}
+	if nil != m.Get("http.requests") {
+		t.Error("Get: unmapped name found")
+	}
+	if md, ok := GetMetadata("http_latency", m); !ok || "/" != md.Tags["route"] || "prod" != md.Tags["env"] {
+		t.Errorf("Metadata: %v, %v\n", md, ok)
+	}
+
+	var b bytes.Buffer
+	if err := writePrometheusText(&b, m, 0, ""); nil != err {
+		t.Fatal(err)
+	}
+	if s := b.String(); !strings.Contains(s, `http_requests{env="prod"} 47`) || strings.Contains(s, "runtime") {
+		t.Errorf("%s\n", s)
+	}
+}
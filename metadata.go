@@ -0,0 +1,69 @@
+package metrics
+
+// Metadata describes a metric to the systems it's exported to.
+type Metadata struct {
+	Description string            // Help text, eg "Requests served"
+	Unit        string            // Unit of the metric's values, eg "bytes"
+	Kind        string            // If set, "counter" or "gauge" to override the kind exporters infer from the metric's type
+	Tags        map[string]string // Tags, or labels, for exporters which support them, eg {"region": "us-east-1"}
+}
+
+// MetadataRegistry is implemented by Registries which can hold Metadata
+// alongside their metrics.  StandardRegistry and PrefixedRegistry do, as long
+// as the registry underlying the latter does.
+type MetadataRegistry interface {
+	Registry
+
+	// Call the given function for each registered metric with its Metadata,
+	// which is zero if none has been set.
+	EachWithMetadata(func(string, interface{}, Metadata))
+
+	// Get the Metadata set for the metric by the given name.
+	Metadata(string) (Metadata, bool)
+
+	// Set the Metadata for the metric by the given name, which is forgotten
+	// when the metric is unregistered.
+	SetMetadata(string, Metadata)
+}
+
+// RegisterWithMetadata registers the given metric under the given name in r,
+// or the DefaultRegistry if r is nil, and sets its Metadata if r is a
+// MetadataRegistry.  Returns a DuplicateMetric if a metric by the given name
+// is already registered, in which case the Metadata is left as it was.
+func RegisterWithMetadata(name string, r Registry, i interface{}, md Metadata) error {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	if err := r.Register(name, i); nil != err {
+		return err
+	}
+	if mr, ok := r.(MetadataRegistry); ok {
+		mr.SetMetadata(name, md)
+	}
+	return nil
+}
+
+// GetMetadata returns the Metadata set for the metric registered under the
+// given name in r, or the DefaultRegistry if r is nil.
+func GetMetadata(name string, r Registry) (Metadata, bool) {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	if mr, ok := r.(MetadataRegistry); ok {
+		return mr.Metadata(name)
+	}
+	return Metadata{}, false
+}
+
+// EachWithMetadata calls the given function for each metric registered in r
+// with its Metadata, which is zero if none has been set or r is not a
+// MetadataRegistry.
+func EachWithMetadata(r Registry, f func(string, interface{}, Metadata)) {
+	if mr, ok := r.(MetadataRegistry); ok {
+		mr.EachWithMetadata(f)
+		return
+	}
+	r.Each(func(name string, i interface{}) {
+		f(name, i, Metadata{})
+	})
+}
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRegisterWithMetadata(t *testing.T) {
+	r := NewRegistry()
+	md := Metadata{Description: "Requests served", Unit: "requests", Tags: map[string]string{"region": "us-east-1"}}
+	if err := RegisterWithMetadata("foo", r, NewCounter(), md); nil != err {
+		t.Fatal(err)
+	}
+	if got, ok := GetMetadata("foo", r); !ok || !reflect.DeepEqual(md, got) {
+		t.Fatal(got, ok)
+	}
+	if err := RegisterWithMetadata("foo", r, NewCounter(), Metadata{}); DuplicateMetric("foo") != err {
+		t.Fatal(err)
+	}
+	if got, _ := GetMetadata("foo", r); !reflect.DeepEqual(md, got) {
+		t.Fatal(got)
+	}
+	r.Register("bar", NewCounter())
+	i := 0
+	EachWithMetadata(r, func(name string, _ interface{}, got Metadata) {
+		i++
+		if "foo" == name && !reflect.DeepEqual(md, got) || "bar" == name && !reflect.DeepEqual(Metadata{}, got) {
+			t.Errorf("%s: %+v\n", name, got)
+		}
+	})
+	if 2 != i {
+		t.Fatal(i)
+	}
+	r.Unregister("foo")
+	if got, ok := GetMetadata("foo", r); ok {
+		t.Fatal(got)
+	}
+}
+
+func TestPrefixedRegistryMetadata(t *testing.T) {
+	r := NewRegistry()
+	pr := NewPrefixedChildRegistry(r, "prefix.")
+	md := Metadata{Description: "Requests served"}
+	RegisterWithMetadata("foo", pr, NewCounter(), md)
+	if got, ok := GetMetadata("prefix.foo", r); !ok || !reflect.DeepEqual(md, got) {
+		t.Fatal(got, ok)
+	}
+	if got, ok := GetMetadata("foo", pr); !ok || !reflect.DeepEqual(md, got) {
+		t.Fatal(got, ok)
+	}
+	EachWithMetadata(pr, func(name string, _ interface{}, got Metadata) {
+		if "prefix.foo" != name || !reflect.DeepEqual(md, got) {
+			t.Errorf("%s: %+v\n", name, got)
+		}
+	})
+}
@@ -8,7 +8,7 @@ import (
 // Meters count events to produce exponentially-weighted moving average rates
 // at one-, five-, and fifteen-minutes and a mean rate.
 type Meter interface {
-	Clear()
+	Clear() Meter // atomically clears and returns a snapshot
 	Count() int64
 	Mark(int64)
 	Rate1() float64
@@ -21,10 +21,35 @@ type Meter interface {
 // GetOrRegisterMeter returns an existing Meter or constructs and registers a
 // new StandardMeter.
 func GetOrRegisterMeter(name string, r Registry) Meter {
+	m, err := TryGetOrRegisterMeter(name, r)
+	if nil != err {
+		return registrationConflict(err, NewMeter()).(Meter)
+	}
+	return m
+}
+
+// GetMeter returns the Meter registered under the given name, or false if
+// there is none or the metric registered there is not a Meter.
+func GetMeter(name string, r Registry) (Meter, bool) {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	m, ok := r.Get(name).(Meter)
+	return m, ok
+}
+
+// TryGetOrRegisterMeter is like GetOrRegisterMeter but returns a
+// DuplicateMetric error, rather than panicking, if a metric which is not a
+// Meter is registered under the given name.
+func TryGetOrRegisterMeter(name string, r Registry) (Meter, error) {
 	if nil == r {
 		r = DefaultRegistry
 	}
-	return r.GetOrRegister(name, NewMeter).(Meter)
+	m, ok := r.GetOrRegister(name, NewMeter).(Meter)
+	if !ok {
+		return nil, DuplicateMetric(name)
+	}
+	return m, nil
 }
 
 // NewMeter constructs a new StandardMeter and launches a goroutine.
@@ -32,17 +57,31 @@ func NewMeter() Meter {
 	if UseNilMetrics {
 		return NilMeter{}
 	}
-	m := newStandardMeter()
+	m := newStandardMeter(DefaultClock)
 	arbiter.Lock()
 	defer arbiter.Unlock()
 	arbiter.meters = append(arbiter.meters, m)
 	if !arbiter.started {
 		arbiter.started = true
+		arbiter.ticker = DefaultClock.NewTicker(meterTickInterval)
 		go arbiter.tick()
 	}
 	return m
 }
 
+// NewMeterWithClock constructs a new StandardMeter which reads the time from
+// the given Clock.  Rather than being ticked by a goroutine it catches up on
+// the ticks due whenever it's marked or read, so the effect of advancing a
+// fake clock is seen straight away.
+func NewMeterWithClock(c Clock) Meter {
+	if UseNilMetrics {
+		return NilMeter{}
+	}
+	m := newStandardMeter(c)
+	m.lazy = true
+	return m
+}
+
 // NewMeter constructs and registers a new StandardMeter and launches a
 // goroutine.
 func NewRegisteredMeter(name string, r Registry) Meter {
@@ -60,17 +99,30 @@ type MeterSnapshot struct {
 	rate1, rate5, rate15, rateMean float64
 }
 
-// Clear panics.
-func (m *MeterSnapshot) Clear() {
-	panic("Clear called on a MeterSnapshot")
+// Clear panics unless PanicOnSnapshotMutation is false.
+func (m *MeterSnapshot) Clear() Meter {
+	snapshotMisuse("Clear called on a MeterSnapshot")
+	return m
 }
 
 // Count returns the count of events at the time the snapshot was taken.
 func (m *MeterSnapshot) Count() int64 { return m.count }
 
-// Mark panics.
+// CountSince returns the count of events between the given earlier snapshot
+// of the same meter, or its construction if that's nil, and this snapshot,
+// so that a reporter can send exactly how many events happened in each
+// interval without clearing the meter's rates.  If the meter was cleared in
+// between, it returns the count at the time this snapshot was taken.
+func (m *MeterSnapshot) CountSince(last Meter) int64 {
+	if nil == last || last.Count() > m.count {
+		return m.count
+	}
+	return m.count - last.Count()
+}
+
+// Mark panics unless PanicOnSnapshotMutation is false.
 func (*MeterSnapshot) Mark(n int64) {
-	panic("Mark called on a MeterSnapshot")
+	snapshotMisuse("Mark called on a MeterSnapshot")
 }
 
 // Rate1 returns the one-minute moving average rate of events per second at the
@@ -96,7 +148,7 @@ func (m *MeterSnapshot) Snapshot() Meter { return m }
 type NilMeter struct{}
 
 // Clear is a no-op.
-func (NilMeter) Clear() {}
+func (NilMeter) Clear() Meter { return NilMeter{} }
 
 // Count is a no-op.
 func (NilMeter) Count() int64 { return 0 }
@@ -125,28 +177,40 @@ type StandardMeter struct {
 	snapshot    *MeterSnapshot
 	a1, a5, a15 EWMA
 	startTime   time.Time
+	clock       Clock
+	lazy        bool      // ticked by catchUp rather than the arbiter
+	lastTick    time.Time // when lazy
 }
 
-func newStandardMeter() *StandardMeter {
+func newStandardMeter(c Clock) *StandardMeter {
+	now := c.Now()
 	return &StandardMeter{
 		snapshot:  &MeterSnapshot{},
 		a1:        NewEWMA1(),
 		a5:        NewEWMA5(),
 		a15:       NewEWMA15(),
-		startTime: time.Now(),
+		startTime: now,
+		clock:     c,
+		lastTick:  now,
 	}
 }
 
-// Clear resets the meter.
-func (m *StandardMeter) Clear() {
+// Clear resets the meter and returns a snapshot of it from before.
+func (m *StandardMeter) Clear() Meter {
 	m.lock.Lock()
 	defer m.lock.Unlock()
+	if m.lazy {
+		m.catchUp()
+	}
+	snapshot := *m.snapshot
 
 	m.snapshot = &MeterSnapshot{}
 	m.a1 = NewEWMA1()
 	m.a5 = NewEWMA5()
 	m.a15 = NewEWMA15()
-	m.startTime = time.Now()
+	m.startTime = m.clock.Now()
+	m.lastTick = m.startTime
+	return &snapshot
 }
 
 // Count returns the number of events recorded.
@@ -161,6 +225,9 @@ func (m *StandardMeter) Count() int64 {
 func (m *StandardMeter) Mark(n int64) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
+	if m.lazy {
+		m.catchUp()
+	}
 	m.snapshot.count += n
 	m.a1.Update(n)
 	m.a5.Update(n)
@@ -170,7 +237,7 @@ func (m *StandardMeter) Mark(n int64) {
 
 // Rate1 returns the one-minute moving average rate of events per second.
 func (m *StandardMeter) Rate1() float64 {
-	m.lock.RLock()
+	m.rlock()
 	rate1 := m.snapshot.rate1
 	m.lock.RUnlock()
 	return rate1
@@ -178,7 +245,7 @@ func (m *StandardMeter) Rate1() float64 {
 
 // Rate5 returns the five-minute moving average rate of events per second.
 func (m *StandardMeter) Rate5() float64 {
-	m.lock.RLock()
+	m.rlock()
 	rate5 := m.snapshot.rate5
 	m.lock.RUnlock()
 	return rate5
@@ -186,7 +253,7 @@ func (m *StandardMeter) Rate5() float64 {
 
 // Rate15 returns the fifteen-minute moving average rate of events per second.
 func (m *StandardMeter) Rate15() float64 {
-	m.lock.RLock()
+	m.rlock()
 	rate15 := m.snapshot.rate15
 	m.lock.RUnlock()
 	return rate15
@@ -194,7 +261,7 @@ func (m *StandardMeter) Rate15() float64 {
 
 // RateMean returns the meter's mean rate of events per second.
 func (m *StandardMeter) RateMean() float64 {
-	m.lock.RLock()
+	m.rlock()
 	rateMean := m.snapshot.rateMean
 	m.lock.RUnlock()
 	return rateMean
@@ -202,7 +269,7 @@ func (m *StandardMeter) RateMean() float64 {
 
 // Snapshot returns a read-only copy of the meter.
 func (m *StandardMeter) Snapshot() Meter {
-	m.lock.RLock()
+	m.rlock()
 	snapshot := *m.snapshot
 	m.lock.RUnlock()
 	return &snapshot
@@ -214,7 +281,32 @@ func (m *StandardMeter) updateSnapshot() {
 	snapshot.rate1 = m.a1.Rate()
 	snapshot.rate5 = m.a5.Rate()
 	snapshot.rate15 = m.a15.Rate()
-	snapshot.rateMean = float64(snapshot.count) / time.Since(m.startTime).Seconds()
+	if elapsed := m.clock.Now().Sub(m.startTime); elapsed > 0 {
+		snapshot.rateMean = float64(snapshot.count) / elapsed.Seconds()
+	}
+}
+
+// catchUp ticks a lazy meter once for each tick interval which has passed
+// since it was last ticked.
+func (m *StandardMeter) catchUp() {
+	// should run with write lock held on m.lock
+	for now := m.clock.Now(); !now.Before(m.lastTick.Add(meterTickInterval)); {
+		m.lastTick = m.lastTick.Add(meterTickInterval)
+		m.a1.Tick()
+		m.a5.Tick()
+		m.a15.Tick()
+	}
+	m.updateSnapshot()
+}
+
+// rlock read-locks m, first bringing a lazy meter up to date.
+func (m *StandardMeter) rlock() {
+	if m.lazy {
+		m.lock.Lock()
+		m.catchUp()
+		m.lock.Unlock()
+	}
+	m.lock.RLock()
 }
 
 func (m *StandardMeter) tick() {
@@ -230,16 +322,20 @@ type meterArbiter struct {
 	sync.RWMutex
 	started bool
 	meters  []*StandardMeter
-	ticker  *time.Ticker
+	ticker  Ticker
 }
 
-var arbiter = meterArbiter{ticker: time.NewTicker(5e9)}
+// meterTickInterval is how often meters' moving averages are ticked, which
+// the EWMA constructors' alphas assume.
+const meterTickInterval = 5 * time.Second
+
+var arbiter = meterArbiter{}
 
 // Ticks meters on the scheduled interval
 func (ma *meterArbiter) tick() {
 	for {
 		select {
-		case <-ma.ticker.C:
+		case <-ma.ticker.C():
 			ma.tickMeters()
 		}
 	}
@@ -0,0 +1,208 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+// slidingWindowSeconds is the length of the longest window of a
+// SlidingWindowMeter, in one-second buckets.
+const slidingWindowSeconds = 15 * 60
+
+// GetOrRegisterSlidingWindowMeter returns an existing SlidingWindowMeter or
+// constructs and registers a new one.
+func GetOrRegisterSlidingWindowMeter(name string, r Registry) *SlidingWindowMeter {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	m, ok := r.GetOrRegister(name, func() interface{} { return NewSlidingWindowMeter() }).(*SlidingWindowMeter)
+	if !ok {
+		return registrationConflict(DuplicateMetric(name), NewSlidingWindowMeter()).(*SlidingWindowMeter)
+	}
+	return m
+}
+
+// NewSlidingWindowMeter constructs a new SlidingWindowMeter.  Unlike
+// NewMeter it ignores UseNilMetrics, since it can't return a NilMeter, and
+// launches no goroutine.
+func NewSlidingWindowMeter() *SlidingWindowMeter {
+	return NewSlidingWindowMeterWithClock(DefaultClock)
+}
+
+// NewSlidingWindowMeterWithClock constructs a new SlidingWindowMeter which
+// reads the time from the given Clock.
+func NewSlidingWindowMeterWithClock(c Clock) *SlidingWindowMeter {
+	now := c.Now()
+	return &SlidingWindowMeter{clock: c, last: now.Unix(), start: now}
+}
+
+// NewRegisteredSlidingWindowMeter constructs and registers a new
+// SlidingWindowMeter.
+func NewRegisteredSlidingWindowMeter(name string, r Registry) *SlidingWindowMeter {
+	m := NewSlidingWindowMeter()
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, m)
+	return m
+}
+
+// SlidingWindowMeter is a Meter whose one-, five- and fifteen-minute rates
+// are exact rather than exponentially-weighted: the number of events counted
+// in the trailing minute, five or fifteen, or since the meter was
+// constructed or cleared if that's less, divided by that time.  It counts
+// events in a ring of one-second buckets, so that its rates can be checked
+// against eg the lines logged over the same minutes, at the cost of 7KB per
+// meter.  Count1, Count5 and Count15 return the counts themselves.
+type SlidingWindowMeter struct {
+	buckets [slidingWindowSeconds]int64
+	clock   Clock
+	count   int64
+	last    int64 // Unix time of the newest bucket
+	mutex   sync.Mutex
+	start   time.Time
+}
+
+// Clear resets the meter and returns a snapshot of it from before.
+func (m *SlidingWindowMeter) Clear() Meter {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	now := m.clock.Now()
+	snapshot := m.snapshot(now)
+	m.buckets = [slidingWindowSeconds]int64{}
+	m.count, m.last, m.start = 0, now.Unix(), now
+	return snapshot
+}
+
+// Count returns the number of events recorded.
+func (m *SlidingWindowMeter) Count() int64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.count
+}
+
+// Count1 returns the number of events recorded in the trailing minute.
+func (m *SlidingWindowMeter) Count1() int64 { return m.windowCount(60) }
+
+// Count5 returns the number of events recorded in the trailing five minutes.
+func (m *SlidingWindowMeter) Count5() int64 { return m.windowCount(5 * 60) }
+
+// Count15 returns the number of events recorded in the trailing fifteen
+// minutes.
+func (m *SlidingWindowMeter) Count15() int64 { return m.windowCount(15 * 60) }
+
+// Mark records the occurance of n events.
+func (m *SlidingWindowMeter) Mark(n int64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.advance(m.clock.Now())
+	m.buckets[slidingWindowBucket(m.last)] += n
+	m.count += n
+}
+
+// Rate1 returns the rate of events per second over the trailing minute.
+func (m *SlidingWindowMeter) Rate1() float64 { return m.windowRate(60) }
+
+// Rate5 returns the rate of events per second over the trailing five
+// minutes.
+func (m *SlidingWindowMeter) Rate5() float64 { return m.windowRate(5 * 60) }
+
+// Rate15 returns the rate of events per second over the trailing fifteen
+// minutes.
+func (m *SlidingWindowMeter) Rate15() float64 { return m.windowRate(15 * 60) }
+
+// RateMean returns the meter's mean rate of events per second.
+func (m *SlidingWindowMeter) RateMean() float64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return float64(m.count) / m.elapsed(m.clock.Now(), 0).Seconds()
+}
+
+// Snapshot returns a read-only copy of the meter.
+func (m *SlidingWindowMeter) Snapshot() Meter {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.snapshot(m.clock.Now())
+}
+
+// advance moves the newest bucket up to the given time, zeroing the buckets
+// of the seconds passed over.  If the clock went backwards it leaves the
+// newest bucket as it is.  The caller must hold mutex.
+func (m *SlidingWindowMeter) advance(now time.Time) {
+	sec := now.Unix()
+	n := sec - m.last
+	if n <= 0 {
+		return
+	}
+	if n > slidingWindowSeconds {
+		n = slidingWindowSeconds
+	}
+	for i := int64(1); i <= n; i++ {
+		m.buckets[slidingWindowBucket(m.last+i)] = 0
+	}
+	m.last = sec
+}
+
+// elapsed returns the time since the meter was constructed or cleared, at
+// most the given window unless that's zero and at least a second.
+func (m *SlidingWindowMeter) elapsed(now time.Time, window time.Duration) time.Duration {
+	d := now.Sub(m.start)
+	if 0 < window && d > window {
+		d = window
+	}
+	if d < time.Second {
+		d = time.Second
+	}
+	return d
+}
+
+// snapshot returns a MeterSnapshot of the meter at the given time.  The
+// caller must hold mutex.
+func (m *SlidingWindowMeter) snapshot(now time.Time) *MeterSnapshot {
+	m.advance(now)
+	return &MeterSnapshot{
+		count:    m.count,
+		rate1:    m.rate(now, 60),
+		rate5:    m.rate(now, 5*60),
+		rate15:   m.rate(now, 15*60),
+		rateMean: float64(m.count) / m.elapsed(now, 0).Seconds(),
+	}
+}
+
+// rate returns the rate of events per second over the trailing window of
+// the given number of seconds.  The caller must hold mutex.
+func (m *SlidingWindowMeter) rate(now time.Time, seconds int64) float64 {
+	return float64(m.sum(seconds)) / m.elapsed(now, time.Duration(seconds)*time.Second).Seconds()
+}
+
+// sum returns the number of events in the newest buckets of the given
+// number of seconds.  The caller must hold mutex and have advanced the
+// buckets.
+func (m *SlidingWindowMeter) sum(seconds int64) int64 {
+	var n int64
+	for i := int64(0); i < seconds; i++ {
+		n += m.buckets[slidingWindowBucket(m.last-i)]
+	}
+	return n
+}
+
+func (m *SlidingWindowMeter) windowCount(seconds int64) int64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.advance(m.clock.Now())
+	return m.sum(seconds)
+}
+
+func (m *SlidingWindowMeter) windowRate(seconds int64) float64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	now := m.clock.Now()
+	m.advance(now)
+	return m.rate(now, seconds)
+}
+
+// slidingWindowBucket returns the index of the bucket of the given Unix
+// time, which may be negative.
+func slidingWindowBucket(sec int64) int64 {
+	return (sec%slidingWindowSeconds + slidingWindowSeconds) % slidingWindowSeconds
+}
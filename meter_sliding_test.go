@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSlidingWindowMeter(t *testing.T) {
+	now := time.Unix(1000, 0)
+	m := NewSlidingWindowMeterWithClock(ClockFunc(func() time.Time { return now }))
+	for i := 0; i < 20*60; i++ {
+		m.Mark(2)
+		now = now.Add(time.Second)
+	}
+	if count := m.Count(); 2400 != count {
+		t.Errorf("m.Count(): 2400 != %v\n", count)
+	}
+	if count := m.Count1(); 118 != count {
+		t.Errorf("m.Count1(): 118 != %v\n", count)
+	}
+	for _, rate := range []float64{m.Rate1(), m.Rate5(), m.Rate15(), m.RateMean()} {
+		if rate < 1.9 || 2 < rate {
+			t.Errorf("rate: 2 != %v\n", rate)
+		}
+	}
+
+	now = now.Add(2 * time.Minute)
+	if count := m.Count1(); 0 != count {
+		t.Errorf("m.Count1() after 2 idle minutes: 0 != %v\n", count)
+	}
+	if count := m.Count5(); 358 != count {
+		t.Errorf("m.Count5() after 2 idle minutes: 358 != %v\n", count)
+	}
+	if rate := m.Snapshot().Rate5(); 358.0/300 != rate {
+		t.Errorf("m.Snapshot().Rate5(): %v != %v\n", 358.0/300, rate)
+	}
+
+	now = now.Add(time.Hour)
+	if count := m.Count15(); 0 != count {
+		t.Errorf("m.Count15() after an idle hour: 0 != %v\n", count)
+	}
+}
+
+func TestSlidingWindowMeterYoung(t *testing.T) {
+	now := time.Unix(1000, 0)
+	m := NewSlidingWindowMeterWithClock(ClockFunc(func() time.Time { return now }))
+	m.Mark(10)
+	now = now.Add(10 * time.Second)
+	if rate := m.Rate15(); 1 != rate {
+		t.Errorf("m.Rate15(): 1 != %v\n", rate)
+	}
+}
+
+func TestSlidingWindowMeterClear(t *testing.T) {
+	now := time.Unix(1000, 0)
+	m := NewSlidingWindowMeterWithClock(ClockFunc(func() time.Time { return now }))
+	m.Mark(3)
+	if count := m.Clear().Count(); 3 != count {
+		t.Errorf("m.Clear().Count(): 3 != %v\n", count)
+	}
+	if count := m.Count1(); 0 != count {
+		t.Errorf("m.Count1(): 0 != %v\n", count)
+	}
+}
+
+func TestGetOrRegisterSlidingWindowMeter(t *testing.T) {
+	r := NewRegistry()
+	GetOrRegisterSlidingWindowMeter("foo", r).Mark(47)
+	if m := GetOrRegisterSlidingWindowMeter("foo", r); 47 != m.Count() {
+		t.Fatal(m)
+	}
+	if _, ok := r.Get("foo").(Meter); !ok {
+		t.Error("not a Meter")
+	}
+}
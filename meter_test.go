@@ -23,9 +23,9 @@ func TestGetOrRegisterMeter(t *testing.T) {
 
 func TestMeterDecay(t *testing.T) {
 	ma := meterArbiter{
-		ticker: time.NewTicker(time.Millisecond),
+		ticker: DefaultClock.NewTicker(time.Millisecond),
 	}
-	m := newStandardMeter()
+	m := newStandardMeter(DefaultClock)
 	ma.meters = append(ma.meters, m)
 	go ma.tick()
 	m.Mark(1)
@@ -44,6 +44,17 @@ func TestMeterNonzero(t *testing.T) {
 	}
 }
 
+func TestMeterClear(t *testing.T) {
+	m := NewMeter()
+	m.Mark(3)
+	if count := m.Clear().Count(); 3 != count {
+		t.Errorf("m.Clear().Count(): 3 != %v\n", count)
+	}
+	if count := m.Count(); 0 != count {
+		t.Errorf("m.Count(): 0 != %v\n", count)
+	}
+}
+
 func TestMeterSnapshot(t *testing.T) {
 	m := NewMeter()
 	m.Mark(1)
@@ -52,6 +63,25 @@ func TestMeterSnapshot(t *testing.T) {
 	}
 }
 
+func TestMeterSnapshotCountSince(t *testing.T) {
+	m := NewMeter()
+	m.Mark(3)
+	first := m.Snapshot().(*MeterSnapshot)
+	if count := first.CountSince(nil); 3 != count {
+		t.Errorf("first.CountSince(nil): 3 != %v\n", count)
+	}
+	m.Mark(4)
+	second := m.Snapshot().(*MeterSnapshot)
+	if count := second.CountSince(first); 4 != count {
+		t.Errorf("second.CountSince(first): 4 != %v\n", count)
+	}
+	m.Clear()
+	m.Mark(2)
+	if count := m.Snapshot().(*MeterSnapshot).CountSince(second); 2 != count {
+		t.Errorf("CountSince after Clear: 2 != %v\n", count)
+	}
+}
+
 func TestMeterZero(t *testing.T) {
 	m := NewMeter()
 	if count := m.Count(); 0 != count {
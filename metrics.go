@@ -11,3 +11,28 @@ package metrics
 // This global kill-switch helps quantify the observer effect and makes
 // for less cluttered pprof profiles.
 var UseNilMetrics bool = false
+
+// UseBiasCorrectedEWMAs is checked by NewEWMA, and so by the constructors of
+// meters and timers.  If it is true, the EWMAs constructed are bias-corrected
+// as by NewBiasCorrectedEWMA, so that the rates of meters created at startup
+// don't ramp up from a single partial first tick over their first minutes,
+// eg on dashboards after a deploy.
+var UseBiasCorrectedEWMAs bool = false
+
+// PanicOnSnapshotMutation is checked by the methods of snapshots and other
+// read-only metrics, eg functional gauges, which would change them, such as
+// Update and Clear.  If it is true, as by default, they panic.  If it is
+// false they do nothing but count the misuse in metrics.SnapshotMisuses,
+// once RegisterSelfStats has been called, so that eg a misbehaving plugin
+// writing to a snapshot from Each can't take down the process.  Those
+// returning a metric, eg Clear on a TimerSnapshot, return the snapshot.
+var PanicOnSnapshotMutation bool = true
+
+// snapshotMisuse panics with the given message, or counts the misuse if
+// PanicOnSnapshotMutation is false.
+func snapshotMisuse(msg string) {
+	if PanicOnSnapshotMutation {
+		panic(msg)
+	}
+	countSnapshotMisuse()
+}
@@ -0,0 +1,104 @@
+package metricstest
+
+import (
+	"testing"
+
+	"github.com/launchdarkly/go-metrics"
+)
+
+// AssertRegistered fails the test unless a metric is registered under the
+// given name, and returns the metric.
+func AssertRegistered(t testing.TB, r metrics.Registry, name string) interface{} {
+	t.Helper()
+	i := r.Get(name)
+	if nil == i {
+		t.Errorf("%s: not registered", name)
+	}
+	return i
+}
+
+// AssertNotRegistered fails the test if a metric is registered under the
+// given name.
+func AssertNotRegistered(t testing.TB, r metrics.Registry, name string) {
+	t.Helper()
+	if i := r.Get(name); nil != i {
+		t.Errorf("%s: registered as a %T", name, i)
+	}
+}
+
+// AssertCounterEquals fails the test unless the metric registered under the
+// given name is a Counter or GaugeCounter with the given count.
+func AssertCounterEquals(t testing.TB, r metrics.Registry, name string, want int64) {
+	t.Helper()
+	var got int64
+	switch metric := AssertRegistered(t, r, name).(type) {
+	case nil:
+		return
+	case metrics.Counter:
+		got = metric.Count()
+	case metrics.GaugeCounter:
+		got = metric.Count()
+	default:
+		t.Errorf("%s: %T is not a Counter", name, metric)
+		return
+	}
+	if want != got {
+		t.Errorf("%s: count %d != %d", name, got, want)
+	}
+}
+
+// AssertGaugeEquals fails the test unless the metric registered under the
+// given name is a Gauge with the given value.
+func AssertGaugeEquals(t testing.TB, r metrics.Registry, name string, want int64) {
+	t.Helper()
+	switch metric := AssertRegistered(t, r, name).(type) {
+	case nil:
+	case metrics.Gauge:
+		if got := metric.Value(); want != got {
+			t.Errorf("%s: value %d != %d", name, got, want)
+		}
+	default:
+		t.Errorf("%s: %T is not a Gauge", name, metric)
+	}
+}
+
+// AssertGaugeFloat64Equals fails the test unless the metric registered under
+// the given name is a GaugeFloat64 with the given value.
+func AssertGaugeFloat64Equals(t testing.TB, r metrics.Registry, name string, want float64) {
+	t.Helper()
+	switch metric := AssertRegistered(t, r, name).(type) {
+	case nil:
+	case metrics.GaugeFloat64:
+		if got := metric.Value(); want != got {
+			t.Errorf("%s: value %v != %v", name, got, want)
+		}
+	default:
+		t.Errorf("%s: %T is not a GaugeFloat64", name, metric)
+	}
+}
+
+// AssertCountEquals fails the test unless the metric registered under the
+// given name is a Histogram, HistogramFloat64, Meter or Timer which has
+// counted the given number of events.
+func AssertCountEquals(t testing.TB, r metrics.Registry, name string, want int64) {
+	t.Helper()
+	var got int64
+	switch metric := AssertRegistered(t, r, name).(type) {
+	case nil:
+		return
+	case metrics.Histogram:
+		got = metric.Count()
+	case metrics.HistogramFloat64:
+		got = metric.Count()
+	case metrics.Meter:
+		got = metric.Count()
+	case metrics.Timer:
+		got = metric.Count()
+	default:
+		t.Errorf("%s: %T does not count events", name, metric)
+		return
+	}
+	if want != got {
+		t.Errorf("%s: count %d != %d", name, got, want)
+	}
+}
@@ -0,0 +1,53 @@
+package metricstest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/launchdarkly/go-metrics"
+)
+
+// recordingTB is a testing.TB which records its errors rather than failing.
+type recordingTB struct {
+	testing.TB
+	errors []string
+}
+
+func (t *recordingTB) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func (t *recordingTB) Helper() {}
+
+func TestAssertions(t *testing.T) {
+	r := metrics.NewRegistry()
+	metrics.GetOrRegisterCounter("counter", r).Inc(3)
+	metrics.GetOrRegisterGauge("gauge", r).Update(47)
+	metrics.GetOrRegisterGaugeFloat64("gauge_float64", r).Update(0.5)
+	metrics.NewRegisteredTimer("timer", r).Update(1)
+
+	tb := &recordingTB{TB: t}
+	AssertCounterEquals(tb, r, "counter", 3)
+	AssertGaugeEquals(tb, r, "gauge", 47)
+	AssertGaugeFloat64Equals(tb, r, "gauge_float64", 0.5)
+	AssertCountEquals(tb, r, "timer", 1)
+	AssertNotRegistered(tb, r, "missing")
+	if 0 != len(tb.errors) {
+		t.Errorf("unexpected failures: %v\n", tb.errors)
+	}
+
+	tb = &recordingTB{TB: t}
+	AssertCounterEquals(tb, r, "counter", 4)
+	AssertCounterEquals(tb, r, "gauge", 47)
+	AssertCounterEquals(tb, r, "missing", 0)
+	AssertNotRegistered(tb, r, "counter")
+	want := []string{
+		"counter: count 3 != 4",
+		"gauge: *metrics.StandardGauge is not a Counter",
+		"missing: not registered",
+		"counter: registered as a *metrics.StandardCounter",
+	}
+	if fmt.Sprint(want) != fmt.Sprint(tb.errors) {
+		t.Errorf("failures: %q != %q\n", want, tb.errors)
+	}
+}
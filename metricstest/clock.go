@@ -0,0 +1,101 @@
+// Package metricstest provides helpers for testing code instrumented with
+// go-metrics.
+package metricstest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/go-metrics"
+)
+
+// FakeClock is a metrics.Clock whose time only moves when it's told to.
+// Give it to the metrics' WithClock constructors, or make it the
+// metrics.DefaultClock, to test time-based metrics deterministically.
+type FakeClock struct {
+	mutex   sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+// NewFakeClock constructs a new FakeClock reading the given time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Advance moves the clock forward by d, firing each ticker once for every
+// period which has ended.  Like a time.Ticker, a ticker drops the ticks its
+// receiver isn't ready for.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.set(c.now.Add(d))
+}
+
+// set moves the clock and fires tickers with c.mutex held.
+func (c *FakeClock) set(now time.Time) {
+	c.now = now
+	for _, t := range c.tickers {
+		for !t.next.After(c.now) {
+			select {
+			case t.c <- t.next:
+			default:
+			}
+			t.next = t.next.Add(t.period)
+		}
+	}
+}
+
+// NewTicker returns a Ticker which fires as Advance moves the clock past
+// each multiple of d from now.  It panics if d is not positive.
+func (c *FakeClock) NewTicker(d time.Duration) metrics.Ticker {
+	if d <= 0 {
+		panic("non-positive interval for FakeClock.NewTicker")
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	t := &fakeTicker{
+		clock:  c,
+		c:      make(chan time.Time, 1),
+		next:   c.now.Add(d),
+		period: d,
+	}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// Now returns the clock's current time.
+func (c *FakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// Set moves the clock to the given time, which may be in the past.  Moving
+// forward fires tickers just as Advance does.
+func (c *FakeClock) Set(now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.set(now)
+}
+
+type fakeTicker struct {
+	clock  *FakeClock
+	c      chan time.Time
+	next   time.Time
+	period time.Duration
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.c }
+
+func (t *fakeTicker) Stop() {
+	c := t.clock
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for i, other := range c.tickers {
+		if other == t {
+			c.tickers = append(c.tickers[:i], c.tickers[i+1:]...)
+			return
+		}
+	}
+}
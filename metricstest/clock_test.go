@@ -0,0 +1,166 @@
+package metricstest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/go-metrics"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Unix(1000, 0)
+	c := NewFakeClock(start)
+	tk := c.NewTicker(time.Second)
+	c.Advance(999 * time.Millisecond)
+	select {
+	case <-tk.C():
+		t.Fatal("ticked early")
+	default:
+	}
+	c.Advance(time.Millisecond)
+	if now := c.Now(); !start.Add(time.Second).Equal(now) {
+		t.Errorf("c.Now(): %v != %v\n", start.Add(time.Second), now)
+	}
+	select {
+	case tick := <-tk.C():
+		if !start.Add(time.Second).Equal(tick) {
+			t.Errorf("tick: %v != %v\n", start.Add(time.Second), tick)
+		}
+	default:
+		t.Fatal("didn't tick")
+	}
+	c.Advance(10 * time.Second)
+	<-tk.C()
+	select {
+	case <-tk.C():
+		t.Fatal("didn't drop ticks")
+	default:
+	}
+	tk.Stop()
+	c.Set(start.Add(time.Hour))
+	select {
+	case <-tk.C():
+		t.Fatal("ticked after Stop")
+	default:
+	}
+}
+
+func TestFakeClockMeter(t *testing.T) {
+	c := NewFakeClock(time.Unix(1000, 0))
+	m := metrics.NewMeterWithClock(c)
+	m.Mark(3)
+	if rate := m.Rate1(); 0 != rate {
+		t.Errorf("m.Rate1(): 0 != %v\n", rate)
+	}
+	c.Advance(5 * time.Second)
+	if rate := m.Rate1(); 0.6 != rate {
+		t.Errorf("m.Rate1(): 0.6 != %v\n", rate)
+	}
+	if rate := m.RateMean(); 0.6 != rate {
+		t.Errorf("m.RateMean(): 0.6 != %v\n", rate)
+	}
+	c.Advance(time.Minute)
+	if rate := m.Snapshot().Rate1(); 0.22072766470286553 != rate {
+		t.Errorf("m.Snapshot().Rate1(): 0.22072766470286553 != %v\n", rate)
+	}
+}
+
+func TestFakeClockTimer(t *testing.T) {
+	c := NewFakeClock(time.Unix(1000, 0))
+	tm := metrics.NewTimerWithClock(c)
+	tm.Time(func() { c.Advance(50 * time.Millisecond) })
+	start := c.Now()
+	c.Advance(20 * time.Millisecond)
+	tm.UpdateSince(start)
+	if max := tm.Max(); int64(50*time.Millisecond) != max {
+		t.Errorf("tm.Max(): %v != %v\n", 50*time.Millisecond, time.Duration(max))
+	}
+	if min := tm.Min(); int64(20*time.Millisecond) != min {
+		t.Errorf("tm.Min(): %v != %v\n", 20*time.Millisecond, time.Duration(min))
+	}
+}
+
+func TestFakeClockExpDecaySample(t *testing.T) {
+	c := NewFakeClock(time.Unix(1000, 0))
+	s := metrics.NewExpDecaySampleWithClock(10, 0.1, c)
+	for i := 1; i <= 100; i++ {
+		c.Advance(10 * time.Minute)
+		s.Update(int64(i))
+	}
+	// Ten minutes apart, a value's priority is so much higher than those before
+	// it that only the latest values can stay in the reservoir.
+	if min := s.Min(); 91 != min {
+		t.Errorf("s.Min(): 91 != %v\n", min)
+	}
+}
+
+func TestFakeClockSchedulerAlign(t *testing.T) {
+	c := NewFakeClock(time.Unix(100, int64(250*time.Millisecond)))
+	exported := make(chan time.Time, 1)
+	s := metrics.NewScheduler(metrics.NewRegistry(), metrics.ExporterFunc(func(metrics.Registry) error {
+		select {
+		case exported <- c.Now():
+		default:
+		}
+		return nil
+	}), metrics.SchedulerConfig{Interval: 30 * time.Second, Align: true, Clock: c})
+	s.Start()
+	defer s.Close()
+
+	// The tick at 130.25s is delayed on the fake clock to the next multiple
+	// of the interval, 150s.
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		c.Advance(250 * time.Millisecond)
+		select {
+		case now := <-exported:
+			if now.Before(time.Unix(150, 0)) || !now.Before(time.Unix(160, 0)) {
+				t.Errorf("exported at %v, not just after 150s\n", now.Unix())
+			}
+			return
+		case <-time.After(time.Millisecond):
+		}
+	}
+	t.Fatal("not exported")
+}
+
+func TestFakeClockRateGauge(t *testing.T) {
+	c := NewFakeClock(time.Unix(1000, 0))
+	counter := metrics.NewGaugeCounter()
+	g := metrics.NewRateGaugeWithClock(counter, c)
+	counter.Inc(30)
+	c.Advance(10 * time.Second)
+	if rate := g.Rate(); 3.0 != rate {
+		t.Errorf("g.Rate(): 3.0 != %v\n", rate)
+	}
+	if rate := g.Clear().Rate(); 3.0 != rate {
+		t.Errorf("g.Clear().Rate(): 3.0 != %v\n", rate)
+	}
+	if rate := g.Rate(); 0.0 != rate {
+		t.Errorf("g.Rate(): 0.0 != %v\n", rate)
+	}
+	counter.Inc(5)
+	c.Advance(5 * time.Second)
+	if rate := g.Snapshot().Rate(); 1.0 != rate {
+		t.Errorf("g.Snapshot().Rate(): 1.0 != %v\n", rate)
+	}
+	if rate := g.Clear().Rate(); 1.0 != rate {
+		t.Errorf("g.Clear().Rate(): 1.0 != %v\n", rate)
+	}
+	counter.Dec(10)
+	c.Advance(5 * time.Second)
+	if rate := g.Rate(); -2.0 != rate {
+		t.Errorf("g.Rate(): -2.0 != %v\n", rate)
+	}
+}
+
+func TestFakeClockReporter(t *testing.T) {
+	c := NewFakeClock(time.Unix(1000, 0))
+	flushed := make(chan struct{})
+	rep := metrics.NewReporterWithClock(time.Minute, func() { flushed <- struct{}{} }, c)
+	rep.Start()
+	c.Advance(time.Minute)
+	<-flushed
+	go rep.Stop()
+	<-flushed
+}
@@ -0,0 +1,76 @@
+package metricstest
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/launchdarkly/go-metrics"
+)
+
+// RegistrySnapshot holds the values of every metric in a registry at one
+// moment, keyed by name.  Timer durations are in nanoseconds unless the timer
+// carries its own DurationUnit.
+type RegistrySnapshot map[string]metrics.Point
+
+// SnapshotRegistry captures the values of every metric in the given registry
+// using metrics.CollectPoints.
+func SnapshotRegistry(r metrics.Registry) RegistrySnapshot {
+	s := make(RegistrySnapshot)
+	for _, p := range metrics.CollectPoints(r, time.Nanosecond) {
+		s[p.Name] = p
+	}
+	return s
+}
+
+// Change is a metric which differs between two RegistrySnapshots.  Before is
+// nil if the metric was registered in between and After is nil if it was
+// unregistered.
+type Change struct {
+	Name   string
+	Before *metrics.Point
+	After  *metrics.Point
+}
+
+// String describes the change, eg "requests: 3 -> 5".
+func (c Change) String() string {
+	switch {
+	case nil == c.Before:
+		return fmt.Sprintf("%s: registered with %s", c.Name, pointString(c.After))
+	case nil == c.After:
+		return fmt.Sprintf("%s: unregistered", c.Name)
+	}
+	return fmt.Sprintf("%s: %s -> %s", c.Name, pointString(c.Before), pointString(c.After))
+}
+
+func pointString(p *metrics.Point) string {
+	if metrics.PointSummary == p.Kind {
+		return fmt.Sprintf("count=%d sum=%v", p.Count, p.Sum)
+	}
+	return fmt.Sprint(p.Value)
+}
+
+// Diff returns the metrics which were registered, unregistered or changed
+// value between two snapshots, sorted by name.  An application's test can take
+// a snapshot, exercise the code under test, and check that Diff reports
+// exactly the metrics it expects to have moved.
+func Diff(before, after RegistrySnapshot) []Change {
+	var changes []Change
+	for name, b := range before {
+		b := b
+		if a, ok := after[name]; !ok {
+			changes = append(changes, Change{Name: name, Before: &b})
+		} else if !reflect.DeepEqual(a, b) {
+			changes = append(changes, Change{Name: name, Before: &b, After: &a})
+		}
+	}
+	for name, a := range after {
+		a := a
+		if _, ok := before[name]; !ok {
+			changes = append(changes, Change{Name: name, After: &a})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes
+}
@@ -0,0 +1,34 @@
+package metricstest
+
+import (
+	"testing"
+
+	"github.com/launchdarkly/go-metrics"
+)
+
+func TestDiff(t *testing.T) {
+	r := metrics.NewRegistry()
+	metrics.GetOrRegisterCounter("requests", r).Inc(3)
+	metrics.GetOrRegisterGauge("idle", r).Update(1)
+	metrics.GetOrRegisterGauge("removed", r).Update(1)
+	before := SnapshotRegistry(r)
+
+	metrics.GetOrRegisterCounter("requests", r).Inc(2)
+	metrics.GetOrRegisterHistogram("sizes", r, metrics.NewUniformSample(10)).Update(7)
+	r.Unregister("removed")
+	changes := Diff(before, SnapshotRegistry(r))
+
+	want := []string{
+		"removed: unregistered",
+		"requests: 3 -> 5",
+		"sizes: registered with count=1 sum=7",
+	}
+	if len(want) != len(changes) {
+		t.Fatalf("Diff: %v != %v\n", want, changes)
+	}
+	for i, c := range changes {
+		if want[i] != c.String() {
+			t.Errorf("changes[%d]: %q != %q\n", i, want[i], c.String())
+		}
+	}
+}
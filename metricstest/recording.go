@@ -0,0 +1,257 @@
+package metricstest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/go-metrics"
+)
+
+// RecordingCounter is a Counter which remembers every increment made to it
+// as well as keeping the count.
+type RecordingCounter struct {
+	metrics.Counter
+	mutex sync.Mutex
+	incs  []int64
+}
+
+// NewRecordingCounter constructs a new RecordingCounter.
+func NewRecordingCounter() *RecordingCounter {
+	return &RecordingCounter{Counter: &metrics.StandardCounter{}}
+}
+
+// Inc increments the counter and records the increment.
+func (c *RecordingCounter) Inc(i int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.incs = append(c.incs, i)
+	c.Counter.Inc(i)
+}
+
+// Incs returns a copy of the increments recorded, in order.
+func (c *RecordingCounter) Incs() []int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]int64(nil), c.incs...)
+}
+
+// RecordingGaugeCounter is a GaugeCounter which remembers every increment
+// and decrement made to it as well as keeping the count.
+type RecordingGaugeCounter struct {
+	metrics.GaugeCounter
+	mutex sync.Mutex
+	incs  []int64
+	decs  []int64
+}
+
+// NewRecordingGaugeCounter constructs a new RecordingGaugeCounter.
+func NewRecordingGaugeCounter() *RecordingGaugeCounter {
+	return &RecordingGaugeCounter{GaugeCounter: &metrics.StandardGaugeCounter{}}
+}
+
+// Dec decrements the counter and records the decrement.
+func (c *RecordingGaugeCounter) Dec(i int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.decs = append(c.decs, i)
+	c.GaugeCounter.Dec(i)
+}
+
+// Decs returns a copy of the decrements recorded, in order.
+func (c *RecordingGaugeCounter) Decs() []int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]int64(nil), c.decs...)
+}
+
+// Inc increments the counter and records the increment.
+func (c *RecordingGaugeCounter) Inc(i int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.incs = append(c.incs, i)
+	c.GaugeCounter.Inc(i)
+}
+
+// Incs returns a copy of the increments recorded, in order.
+func (c *RecordingGaugeCounter) Incs() []int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]int64(nil), c.incs...)
+}
+
+// RecordingGauge is a Gauge which remembers every value it's updated with.
+type RecordingGauge struct {
+	metrics.Gauge
+	mutex   sync.Mutex
+	updates []int64
+}
+
+// NewRecordingGauge constructs a new RecordingGauge.
+func NewRecordingGauge() *RecordingGauge {
+	return &RecordingGauge{Gauge: &metrics.StandardGauge{}}
+}
+
+// Update updates the gauge's value and records it.
+func (g *RecordingGauge) Update(v int64) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.updates = append(g.updates, v)
+	g.Gauge.Update(v)
+}
+
+// Updates returns a copy of the values recorded, in order.
+func (g *RecordingGauge) Updates() []int64 {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return append([]int64(nil), g.updates...)
+}
+
+// RecordingHistogram is a Histogram which remembers every value it's
+// updated with.  Its statistics are computed over all of them.
+type RecordingHistogram struct {
+	metrics.Histogram
+	mutex   sync.Mutex
+	updates []int64
+}
+
+// NewRecordingHistogram constructs a new RecordingHistogram.
+func NewRecordingHistogram() *RecordingHistogram {
+	return &RecordingHistogram{
+		Histogram: metrics.NewHistogram(&unboundedSample{}),
+	}
+}
+
+// Update samples a new value and records it.
+func (h *RecordingHistogram) Update(v int64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.updates = append(h.updates, v)
+	h.Histogram.Update(v)
+}
+
+// Updates returns a copy of the values recorded, in order.
+func (h *RecordingHistogram) Updates() []int64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return append([]int64(nil), h.updates...)
+}
+
+// RecordingHistogramFloat64 is a HistogramFloat64 which remembers every
+// value it's updated with.  Its statistics are computed over all of them.
+type RecordingHistogramFloat64 struct {
+	metrics.HistogramFloat64
+	mutex   sync.Mutex
+	updates []float64
+}
+
+// NewRecordingHistogramFloat64 constructs a new RecordingHistogramFloat64.
+func NewRecordingHistogramFloat64() *RecordingHistogramFloat64 {
+	return &RecordingHistogramFloat64{
+		HistogramFloat64: metrics.NewHistogramFloat64(&unboundedSampleFloat64{}),
+	}
+}
+
+// Update samples a new value and records it.
+func (h *RecordingHistogramFloat64) Update(v float64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.updates = append(h.updates, v)
+	h.HistogramFloat64.Update(v)
+}
+
+// Updates returns a copy of the values recorded, in order.
+func (h *RecordingHistogramFloat64) Updates() []float64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return append([]float64(nil), h.updates...)
+}
+
+// RecordingMeter is a Meter which remembers every mark made on it.
+type RecordingMeter struct {
+	metrics.Meter
+	mutex sync.Mutex
+	marks []int64
+}
+
+// NewRecordingMeter constructs a new RecordingMeter which reads the time from
+// the given Clock, or metrics.DefaultClock if c is nil.
+func NewRecordingMeter(c metrics.Clock) *RecordingMeter {
+	if nil == c {
+		c = metrics.DefaultClock
+	}
+	return &RecordingMeter{Meter: metrics.NewMeterWithClock(c)}
+}
+
+// Mark records the occurance of n events.
+func (m *RecordingMeter) Mark(n int64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.marks = append(m.marks, n)
+	m.Meter.Mark(n)
+}
+
+// Marks returns a copy of the marks recorded, in order.
+func (m *RecordingMeter) Marks() []int64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]int64(nil), m.marks...)
+}
+
+// RecordingTimer is a Timer which remembers every duration it's updated
+// with, including those it measures itself in Time and UpdateSince.
+type RecordingTimer struct {
+	metrics.Timer
+	clock   metrics.Clock
+	mutex   sync.Mutex
+	updates []time.Duration
+}
+
+// NewRecordingTimer constructs a new RecordingTimer which reads the time from
+// the given Clock, or metrics.DefaultClock if c is nil.
+func NewRecordingTimer(c metrics.Clock) *RecordingTimer {
+	if nil == c {
+		c = metrics.DefaultClock
+	}
+	return &RecordingTimer{
+		Timer: metrics.NewCustomTimer(
+			metrics.NewHistogram(&unboundedSample{}),
+			metrics.NewMeterWithClock(c),
+		),
+		clock: c,
+	}
+}
+
+// Stopwatch returns a new Stopwatch, started now, whose Stop records through
+// the recording timer's own Update, so that the duration appears in Updates,
+// rather than straight into the wrapped Timer.
+func (t *RecordingTimer) Stopwatch() *metrics.Stopwatch {
+	return metrics.NewStopwatch(t, t.clock)
+}
+
+// Time records the duration of the execution of the given function.
+func (t *RecordingTimer) Time(f func()) {
+	ts := t.clock.Now()
+	f()
+	t.Update(t.clock.Now().Sub(ts))
+}
+
+// Update records the duration of an event.
+func (t *RecordingTimer) Update(d time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.updates = append(t.updates, d)
+	t.Timer.Update(d)
+}
+
+// UpdateSince records the duration of an event that started at a time and
+// ends now.
+func (t *RecordingTimer) UpdateSince(ts time.Time) {
+	t.Update(t.clock.Now().Sub(ts))
+}
+
+// Updates returns a copy of the durations recorded, in order.
+func (t *RecordingTimer) Updates() []time.Duration {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return append([]time.Duration(nil), t.updates...)
+}
@@ -0,0 +1,95 @@
+package metricstest
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/go-metrics"
+)
+
+func TestRecordingCounter(t *testing.T) {
+	c := NewRecordingCounter()
+	c.Inc(1)
+	c.Inc(2)
+	if count := c.Count(); 3 != count {
+		t.Errorf("c.Count(): 3 != %v\n", count)
+	}
+	if incs := c.Incs(); !reflect.DeepEqual([]int64{1, 2}, incs) {
+		t.Errorf("c.Incs(): [1 2] != %v\n", incs)
+	}
+}
+
+func TestRecordingGaugeCounter(t *testing.T) {
+	c := NewRecordingGaugeCounter()
+	c.Inc(5)
+	c.Dec(2)
+	if count := c.Count(); 3 != count {
+		t.Errorf("c.Count(): 3 != %v\n", count)
+	}
+	if decs := c.Decs(); !reflect.DeepEqual([]int64{2}, decs) {
+		t.Errorf("c.Decs(): [2] != %v\n", decs)
+	}
+}
+
+func TestRecordingHistogram(t *testing.T) {
+	h := NewRecordingHistogram()
+	for i := int64(1); i <= 2000; i++ {
+		h.Update(i)
+	}
+	if n := len(h.Updates()); 2000 != n {
+		t.Errorf("len(h.Updates()): 2000 != %v\n", n)
+	}
+	// Every value is kept, unlike the standard samples' reservoirs.
+	if min := h.Snapshot().Min(); 1 != min {
+		t.Errorf("h.Snapshot().Min(): 1 != %v\n", min)
+	}
+}
+
+func TestRecordingHistogramFloat64(t *testing.T) {
+	h := NewRecordingHistogramFloat64()
+	h.Update(0.5)
+	h.Update(1.5)
+	if sum := h.Snapshot().Sum(); 2.0 != sum {
+		t.Errorf("h.Snapshot().Sum(): 2.0 != %v\n", sum)
+	}
+	if updates := h.Updates(); !reflect.DeepEqual([]float64{0.5, 1.5}, updates) {
+		t.Errorf("h.Updates(): [0.5 1.5] != %v\n", updates)
+	}
+}
+
+func TestRecordingMeter(t *testing.T) {
+	c := NewFakeClock(time.Unix(1000, 0))
+	m := NewRecordingMeter(c)
+	m.Mark(3)
+	c.Advance(5 * time.Second)
+	if rate := m.Rate1(); 0.6 != rate {
+		t.Errorf("m.Rate1(): 0.6 != %v\n", rate)
+	}
+	if marks := m.Marks(); !reflect.DeepEqual([]int64{3}, marks) {
+		t.Errorf("m.Marks(): [3] != %v\n", marks)
+	}
+}
+
+func TestRecordingTimer(t *testing.T) {
+	c := NewFakeClock(time.Unix(1000, 0))
+	tm := NewRecordingTimer(c)
+	tm.Time(func() { c.Advance(time.Second) })
+	start := c.Now()
+	c.Advance(2 * time.Second)
+	tm.UpdateSince(start)
+	tm.Update(3 * time.Second)
+	sw := tm.Stopwatch()
+	c.Advance(4 * time.Second)
+	if d := sw.Stop(); 4*time.Second != d {
+		t.Errorf("sw.Stop(): 4s != %v\n", d)
+	}
+	want := []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 4 * time.Second}
+	if updates := tm.Updates(); !reflect.DeepEqual(want, updates) {
+		t.Errorf("tm.Updates(): %v != %v\n", want, updates)
+	}
+	if sum := tm.Snapshot().Sum(); int64(10*time.Second) != sum {
+		t.Errorf("tm.Snapshot().Sum(): %v != %v\n", 10*time.Second, time.Duration(sum))
+	}
+	var _ metrics.Timer = tm
+}
@@ -0,0 +1,98 @@
+package metricstest
+
+import (
+	"sync"
+
+	"github.com/launchdarkly/go-metrics"
+)
+
+// unboundedSample is a Sample which keeps every value so that the statistics
+// recording histograms and timers report are exact.
+type unboundedSample struct {
+	mutex  sync.Mutex
+	values []int64
+}
+
+func (s *unboundedSample) snapshot() *metrics.SampleSnapshot {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	values := append([]int64(nil), s.values...)
+	return metrics.NewSampleSnapshot(int64(len(values)), values)
+}
+
+func (s *unboundedSample) AppendValues(dst []int64) []int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append(dst, s.values...)
+}
+
+func (s *unboundedSample) Clear() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.values = nil
+}
+
+func (s *unboundedSample) Count() int64                       { return s.snapshot().Count() }
+func (s *unboundedSample) Max() int64                         { return s.snapshot().Max() }
+func (s *unboundedSample) Mean() float64                      { return s.snapshot().Mean() }
+func (s *unboundedSample) Min() int64                         { return s.snapshot().Min() }
+func (s *unboundedSample) Percentile(p float64) float64       { return s.snapshot().Percentile(p) }
+func (s *unboundedSample) Percentiles(ps []float64) []float64 { return s.snapshot().Percentiles(ps) }
+func (s *unboundedSample) Size() int                          { return s.snapshot().Size() }
+func (s *unboundedSample) Snapshot() metrics.Sample           { return s.snapshot() }
+func (s *unboundedSample) StdDev() float64                    { return s.snapshot().StdDev() }
+func (s *unboundedSample) Sum() int64                         { return s.snapshot().Sum() }
+func (s *unboundedSample) Values() []int64                    { return s.snapshot().Values() }
+func (s *unboundedSample) Variance() float64                  { return s.snapshot().Variance() }
+
+func (s *unboundedSample) Update(v int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.values = append(s.values, v)
+}
+
+// unboundedSampleFloat64 is the float64 counterpart of unboundedSample.
+type unboundedSampleFloat64 struct {
+	mutex  sync.Mutex
+	values []float64
+}
+
+func (s *unboundedSampleFloat64) snapshot() *metrics.SampleFloat64Snapshot {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	values := append([]float64(nil), s.values...)
+	return metrics.NewSampleFloat64Snapshot(int64(len(values)), values)
+}
+
+func (s *unboundedSampleFloat64) AppendValues(dst []float64) []float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append(dst, s.values...)
+}
+
+func (s *unboundedSampleFloat64) Clear() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.values = nil
+}
+
+func (s *unboundedSampleFloat64) Count() int64                 { return s.snapshot().Count() }
+func (s *unboundedSampleFloat64) Max() float64                 { return s.snapshot().Max() }
+func (s *unboundedSampleFloat64) Mean() float64                { return s.snapshot().Mean() }
+func (s *unboundedSampleFloat64) Min() float64                 { return s.snapshot().Min() }
+func (s *unboundedSampleFloat64) Percentile(p float64) float64 { return s.snapshot().Percentile(p) }
+func (s *unboundedSampleFloat64) Percentiles(ps []float64) []float64 {
+	return s.snapshot().Percentiles(ps)
+}
+func (s *unboundedSampleFloat64) Size() int                       { return s.snapshot().Size() }
+func (s *unboundedSampleFloat64) Snapshot() metrics.SampleFloat64 { return s.snapshot() }
+func (s *unboundedSampleFloat64) StdDev() float64                 { return s.snapshot().StdDev() }
+func (s *unboundedSampleFloat64) Sum() float64                    { return s.snapshot().Sum() }
+func (s *unboundedSampleFloat64) Values() []float64               { return s.snapshot().Values() }
+func (s *unboundedSampleFloat64) Variance() float64               { return s.snapshot().Variance() }
+
+func (s *unboundedSampleFloat64) Update(v float64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.values = append(s.values, v)
+}
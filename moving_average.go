@@ -0,0 +1,227 @@
+package metrics
+
+import "sync"
+
+// MovingAverages smooth a noisy series of float64 values, reporting the
+// average of the most recent ones rather than only the last like a
+// GaugeFloat64.  Reporters export them as gauges.
+type MovingAverage interface {
+	Clear() MovingAverage // atomically clears and returns a snapshot
+	Snapshot() MovingAverage
+	Update(float64)
+	Value() float64
+}
+
+// GetOrRegisterMovingAverage returns an existing MovingAverage or constructs
+// and registers a new SimpleMovingAverage over the given window.
+func GetOrRegisterMovingAverage(name string, r Registry, window int) MovingAverage {
+	return getOrRegisterMovingAverage(name, r, func() MovingAverage { return NewMovingAverage(window) })
+}
+
+// GetOrRegisterExpMovingAverage returns an existing MovingAverage or
+// constructs and registers a new ExpMovingAverage with the given alpha.
+func GetOrRegisterExpMovingAverage(name string, r Registry, alpha float64) MovingAverage {
+	return getOrRegisterMovingAverage(name, r, func() MovingAverage { return NewExpMovingAverage(alpha) })
+}
+
+// GetMovingAverage returns the MovingAverage registered under the given
+// name, or false if there is none or the metric registered there is not a
+// MovingAverage.
+func GetMovingAverage(name string, r Registry) (MovingAverage, bool) {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	a, ok := r.Get(name).(MovingAverage)
+	return a, ok
+}
+
+func getOrRegisterMovingAverage(name string, r Registry, f func() MovingAverage) MovingAverage {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	a, ok := r.GetOrRegister(name, f).(MovingAverage)
+	if !ok {
+		return registrationConflict(DuplicateMetric(name), f()).(MovingAverage)
+	}
+	return a
+}
+
+// NewMovingAverage constructs a new SimpleMovingAverage over the given
+// number of most recent values.  It panics if window is less than one.
+func NewMovingAverage(window int) MovingAverage {
+	if window < 1 {
+		panic("NewMovingAverage called with a window less than one")
+	}
+	if UseNilMetrics {
+		return NilMovingAverage{}
+	}
+	return &SimpleMovingAverage{values: make([]float64, 0, window)}
+}
+
+// NewExpMovingAverage constructs a new ExpMovingAverage which weights each
+// new value by alpha, between zero and one, and the previous average by one
+// minus alpha.
+func NewExpMovingAverage(alpha float64) MovingAverage {
+	if UseNilMetrics {
+		return NilMovingAverage{}
+	}
+	return &ExpMovingAverage{alpha: alpha}
+}
+
+// NewRegisteredMovingAverage constructs and registers a new
+// SimpleMovingAverage.
+func NewRegisteredMovingAverage(name string, r Registry, window int) MovingAverage {
+	c := NewMovingAverage(window)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// NewRegisteredExpMovingAverage constructs and registers a new
+// ExpMovingAverage.
+func NewRegisteredExpMovingAverage(name string, r Registry, alpha float64) MovingAverage {
+	c := NewExpMovingAverage(alpha)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// MovingAverageSnapshot is a read-only copy of another MovingAverage.
+type MovingAverageSnapshot float64
+
+// Clear panics unless PanicOnSnapshotMutation is false.
+func (a MovingAverageSnapshot) Clear() MovingAverage {
+	snapshotMisuse("Clear called on a MovingAverageSnapshot")
+	return a
+}
+
+// Snapshot returns the snapshot.
+func (a MovingAverageSnapshot) Snapshot() MovingAverage { return a }
+
+// Update panics unless PanicOnSnapshotMutation is false.
+func (MovingAverageSnapshot) Update(float64) {
+	snapshotMisuse("Update called on a MovingAverageSnapshot")
+}
+
+// Value returns the average at the time the snapshot was taken.
+func (a MovingAverageSnapshot) Value() float64 { return float64(a) }
+
+// NilMovingAverage is a no-op MovingAverage.
+type NilMovingAverage struct{}
+
+// Clear is a no-op.
+func (NilMovingAverage) Clear() MovingAverage { return NilMovingAverage{} }
+
+// Snapshot is a no-op.
+func (NilMovingAverage) Snapshot() MovingAverage { return NilMovingAverage{} }
+
+// Update is a no-op.
+func (NilMovingAverage) Update(v float64) {}
+
+// Value is a no-op.
+func (NilMovingAverage) Value() float64 { return 0.0 }
+
+// SimpleMovingAverage is the arithmetic mean of the most recent values, up to
+// the size of its window, kept in a ring buffer.
+type SimpleMovingAverage struct {
+	mutex  sync.Mutex
+	next   int
+	values []float64
+}
+
+// Clear forgets all the values and returns a snapshot of the average from
+// before.
+func (a *SimpleMovingAverage) Clear() MovingAverage {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	snapshot := MovingAverageSnapshot(a.value())
+	a.next = 0
+	a.values = a.values[:0]
+	return snapshot
+}
+
+// Snapshot returns a read-only copy of the average.
+func (a *SimpleMovingAverage) Snapshot() MovingAverage {
+	return MovingAverageSnapshot(a.Value())
+}
+
+// Update adds a value, replacing the oldest once the window is full.
+func (a *SimpleMovingAverage) Update(v float64) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if len(a.values) < cap(a.values) {
+		a.values = append(a.values, v)
+		return
+	}
+	a.values[a.next] = v
+	a.next = (a.next + 1) % len(a.values)
+}
+
+// Value returns the mean of the values in the window, or zero if there are
+// none.  It's summed afresh each time so that rounding errors can't
+// accumulate over a long-lived average.
+func (a *SimpleMovingAverage) Value() float64 {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.value()
+}
+
+func (a *SimpleMovingAverage) value() float64 {
+	if 0 == len(a.values) {
+		return 0.0
+	}
+	var sum float64
+	for _, v := range a.values {
+		sum += v
+	}
+	return sum / float64(len(a.values))
+}
+
+// ExpMovingAverage is an exponentially-weighted moving average of every
+// value, unlike an EWMA which averages the rate of events per tick.  Its
+// first value is taken as is.
+type ExpMovingAverage struct {
+	alpha   float64
+	mutex   sync.Mutex
+	value   float64
+	updated bool
+}
+
+// Clear forgets all the values and returns a snapshot of the average from
+// before.
+func (a *ExpMovingAverage) Clear() MovingAverage {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	snapshot := MovingAverageSnapshot(a.value)
+	a.value = 0.0
+	a.updated = false
+	return snapshot
+}
+
+// Snapshot returns a read-only copy of the average.
+func (a *ExpMovingAverage) Snapshot() MovingAverage {
+	return MovingAverageSnapshot(a.Value())
+}
+
+// Update folds a value into the average.
+func (a *ExpMovingAverage) Update(v float64) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.updated {
+		a.value += a.alpha * (v - a.value)
+	} else {
+		a.value = v
+		a.updated = true
+	}
+}
+
+// Value returns the average, or zero if there have been no values.
+func (a *ExpMovingAverage) Value() float64 {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.value
+}
@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func BenchmarkMovingAverage(b *testing.B) {
+	a := NewMovingAverage(10)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		a.Update(float64(i))
+	}
+}
+
+func BenchmarkExpMovingAverage(b *testing.B) {
+	a := NewExpMovingAverage(0.5)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		a.Update(float64(i))
+	}
+}
+
+func TestMovingAverage(t *testing.T) {
+	a := NewMovingAverage(3)
+	if v := a.Value(); 0.0 != v {
+		t.Errorf("a.Value(): 0.0 != %v\n", v)
+	}
+	a.Update(1)
+	a.Update(2)
+	if v := a.Value(); 1.5 != v {
+		t.Errorf("a.Value(): 1.5 != %v\n", v)
+	}
+	a.Update(3)
+	a.Update(4)
+	a.Update(5)
+	if v := a.Value(); 4.0 != v {
+		t.Errorf("a.Value(): 4.0 != %v\n", v)
+	}
+	if v := a.Clear().Value(); 4.0 != v {
+		t.Errorf("a.Clear().Value(): 4.0 != %v\n", v)
+	}
+	if v := a.Value(); 0.0 != v {
+		t.Errorf("a.Value(): 0.0 != %v\n", v)
+	}
+	a.Update(7)
+	if v := a.Value(); 7.0 != v {
+		t.Errorf("a.Value(): 7.0 != %v\n", v)
+	}
+}
+
+func TestMovingAverageWindow(t *testing.T) {
+	defer func() {
+		if nil == recover() {
+			t.Error("NewMovingAverage(0) didn't panic")
+		}
+	}()
+	NewMovingAverage(0)
+}
+
+func TestExpMovingAverage(t *testing.T) {
+	a := NewExpMovingAverage(0.25)
+	a.Update(8)
+	if v := a.Value(); 8.0 != v {
+		t.Errorf("a.Value(): 8.0 != %v\n", v)
+	}
+	a.Update(0)
+	if v := a.Value(); 6.0 != v {
+		t.Errorf("a.Value(): 6.0 != %v\n", v)
+	}
+	a.Update(10)
+	if v := a.Value(); 7.0 != v {
+		t.Errorf("a.Value(): 7.0 != %v\n", v)
+	}
+	if v := a.Clear().Value(); 7.0 != v {
+		t.Errorf("a.Clear().Value(): 7.0 != %v\n", v)
+	}
+	a.Update(1)
+	if v := a.Value(); 1.0 != v {
+		t.Errorf("a.Value(): 1.0 != %v\n", v)
+	}
+}
+
+func TestMovingAverageSnapshot(t *testing.T) {
+	a := NewMovingAverage(2)
+	a.Update(47)
+	snapshot := a.Snapshot()
+	a.Update(0)
+	if v := snapshot.Value(); 47.0 != v {
+		t.Errorf("snapshot.Value(): 47.0 != %v\n", v)
+	}
+}
+
+func TestGetOrRegisterMovingAverage(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredExpMovingAverage("foo", r, 0.5).Update(47)
+	if a := GetOrRegisterMovingAverage("foo", r, 10); 47.0 != a.Value() {
+		t.Fatal(a)
+	}
+	if a, ok := GetMovingAverage("foo", r); !ok || 47.0 != a.Value() {
+		t.Fatal(a, ok)
+	}
+}
+
+func TestMovingAverageReported(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredMovingAverage("foo", r, 10).Update(47)
+	points := CollectPoints(r, 0)
+	if 1 != len(points) || PointGauge != points[0].Kind || 47.0 != points[0].Value {
+		t.Fatalf("%+v\n", points)
+	}
+	var b strings.Builder
+	WriteOnce(r, &b)
+	if want := "gauge foo\n  value:       47.000000\n"; want != b.String() {
+		t.Errorf("%q != %q\n", want, b.String())
+	}
+}
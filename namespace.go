@@ -0,0 +1,160 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+)
+
+// MetricNamespace builds the names of metrics from dotted segments, so that
+// components name theirs consistently, eg
+//
+//	metrics.Namespace("ld", "stream").Counter("connections")
+//
+// is the Counter ld.stream.connections in the DefaultRegistry.
+//
+// A namespace given tags with WithTags appends them to the names it builds
+// in Graphite's tagged-series syntax, eg ld.stream.connections;env=prod, so
+// that each set of tags is a series of its own.  Exporters which name series
+// by dotted paths alone see those names, while those which support tags
+// should be given a view of the registry made with NewMappedRegistry and
+// MapSplitTags, through which they see the name ld.stream.connections with
+// the tag env=prod.
+type MetricNamespace struct {
+	prefix   string
+	registry Registry
+	tags     string
+}
+
+// Namespace returns the MetricNamespace of the given segments, in the
+// DefaultRegistry.  Empty segments are skipped.
+func Namespace(segments ...string) MetricNamespace {
+	return MetricNamespace{}.Namespace(segments...)
+}
+
+// Namespace returns the MetricNamespace of the given segments within n.
+func (n MetricNamespace) Namespace(segments ...string) MetricNamespace {
+	n.prefix = joinSegments(n.prefix, segments)
+	return n
+}
+
+// In returns n in the given registry rather than n's own.
+func (n MetricNamespace) In(r Registry) MetricNamespace {
+	n.registry = r
+	return n
+}
+
+// WithTags returns n with the given tags added to n's own, replacing any
+// with the same keys.
+func (n MetricNamespace) WithTags(tags map[string]string) MetricNamespace {
+	merged := splitTagSuffix(n.tags)
+	for k, v := range tags {
+		merged[k] = v
+	}
+	n.tags = tagSuffix(merged)
+	return n
+}
+
+// Name returns the full name of the metric named name in n.
+func (n MetricNamespace) Name(name string) string {
+	return joinSegments(n.prefix, []string{name}) + n.tags
+}
+
+// Counter returns the Counter named name in n, registering a new
+// StandardCounter if there's none.
+func (n MetricNamespace) Counter(name string) Counter {
+	return GetOrRegisterCounter(n.Name(name), n.registry)
+}
+
+// Gauge returns the Gauge named name in n, registering a new StandardGauge
+// if there's none.
+func (n MetricNamespace) Gauge(name string) Gauge {
+	return GetOrRegisterGauge(n.Name(name), n.registry)
+}
+
+// GaugeFloat64 returns the GaugeFloat64 named name in n, registering a new
+// StandardGaugeFloat64 if there's none.
+func (n MetricNamespace) GaugeFloat64(name string) GaugeFloat64 {
+	return GetOrRegisterGaugeFloat64(n.Name(name), n.registry)
+}
+
+// Histogram returns the Histogram named name in n, registering a new
+// StandardHistogram of the given Sample if there's none.
+func (n MetricNamespace) Histogram(name string, s Sample) Histogram {
+	return GetOrRegisterHistogram(n.Name(name), n.registry, s)
+}
+
+// Meter returns the Meter named name in n, registering a new StandardMeter
+// if there's none.
+func (n MetricNamespace) Meter(name string) Meter {
+	return GetOrRegisterMeter(n.Name(name), n.registry)
+}
+
+// Timer returns the Timer named name in n, registering a new StandardTimer
+// if there's none.
+func (n MetricNamespace) Timer(name string) Timer {
+	return GetOrRegisterTimer(n.Name(name), n.registry)
+}
+
+// MapSplitTags moves the tags appended to names in Graphite's tagged-series
+// syntax, eg by a MetricNamespace given tags, from the name to the tags,
+// for exporters which support tags.  Tags already set in a metric's Metadata
+// take precedence.
+func MapSplitTags() MapRule {
+	return func(name string, tags map[string]string) (string, bool) {
+		i := strings.IndexByte(name, ';')
+		if i < 0 {
+			return name, true
+		}
+		for k, v := range splitTagSuffix(name[i:]) {
+			if _, ok := tags[k]; !ok {
+				tags[k] = v
+			}
+		}
+		return name[:i], true
+	}
+}
+
+// joinSegments appends the non-empty segments to the dotted prefix.
+func joinSegments(prefix string, segments []string) string {
+	for _, s := range segments {
+		if "" == s {
+			continue
+		}
+		if "" == prefix {
+			prefix = s
+		} else {
+			prefix += "." + s
+		}
+	}
+	return prefix
+}
+
+// tagSuffix returns the given tags in Graphite's tagged-series syntax, in
+// order of key, eg ";env=prod;region=us".
+func tagSuffix(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteByte(';')
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(tags[k])
+	}
+	return b.String()
+}
+
+// splitTagSuffix parses tags in Graphite's tagged-series syntax, ignoring
+// any part without an "=".
+func splitTagSuffix(s string) map[string]string {
+	tags := make(map[string]string)
+	for _, part := range strings.Split(s, ";") {
+		if i := strings.IndexByte(part, '='); i > 0 {
+			tags[part[:i]] = part[i+1:]
+		}
+	}
+	return tags
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNamespace(t *testing.T) {
+	r := NewRegistry()
+	n := Namespace("ld", "", "stream").In(r)
+	n.Counter("connections").Inc(1)
+	if _, ok := r.Get("ld.stream.connections").(Counter); !ok {
+		t.Fatal(r.Get("ld.stream.connections"))
+	}
+	if name := n.Namespace("relay").Name("events"); "ld.stream.relay.events" != name {
+		t.Errorf("n.Namespace(\"relay\").Name(\"events\"): ld.stream.relay.events != %v\n", name)
+	}
+	if name := Namespace().Name("events"); "events" != name {
+		t.Errorf("Namespace().Name(\"events\"): events != %v\n", name)
+	}
+	if c := n.Counter("connections"); 1 != c.Count() {
+		t.Errorf("n.Counter(\"connections\").Count(): 1 != %v\n", c.Count())
+	}
+}
+
+func TestNamespaceWithTags(t *testing.T) {
+	r := NewRegistry()
+	n := Namespace("ld", "stream").In(r).WithTags(map[string]string{"region": "us", "env": "dev"})
+	n = n.WithTags(map[string]string{"env": "prod"})
+	n.Meter("events").Mark(1)
+	name := "ld.stream.events;env=prod;region=us"
+	if _, ok := r.Get(name).(Meter); !ok {
+		t.Fatal(r.Get(name))
+	}
+
+	m := NewMappedRegistry(r, Mapper{MapSplitTags()})
+	m.(MetadataRegistry).EachWithMetadata(func(name string, i interface{}, md Metadata) {
+		if "ld.stream.events" != name {
+			t.Errorf("name: ld.stream.events != %v\n", name)
+		}
+		if tags := map[string]string{"env": "prod", "region": "us"}; !reflect.DeepEqual(tags, md.Tags) {
+			t.Errorf("md.Tags: %v != %v\n", tags, md.Tags)
+		}
+	})
+}
+
+func TestMapSplitTags(t *testing.T) {
+	name, tags, ok := Mapper{MapSplitTags()}.Map("requests;route=/a;env=dev", map[string]string{"env": "prod"})
+	if !ok || "requests" != name {
+		t.Fatal(name, ok)
+	}
+	if want := map[string]string{"env": "prod", "route": "/a"}; !reflect.DeepEqual(want, tags) {
+		t.Errorf("tags: %v != %v\n", want, tags)
+	}
+	if name, tags, _ := (Mapper{MapSplitTags()}).Map("requests", nil); "requests" != name || nil != tags {
+		t.Errorf("Map(\"requests\"): %v %v\n", name, tags)
+	}
+}
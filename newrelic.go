@@ -0,0 +1,226 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NewRelicConfig provides a container with configuration parameters for the
+// New Relic exporter, which posts to New Relic's Metric API.
+//
+// Counters become count metrics of their increase since the last flush, with
+// the flush's interval.  Histograms, timers and SummaryFloat64s become
+// summary metrics of the count, sum, minimum and maximum of their samples,
+// plus a gauge per quantile, eg name.p99.  Meters become gauges of their
+// rates, name.rate1, name.rate5, name.rate15 and name.mean-rate, or, if
+// MetersAsCounts is set, count metrics of the events marked since the last
+// flush, from which New Relic derives rates over any window.  Everything
+// else is translated as by CollectPoints, monotonic sums becoming counts and
+// all else gauges.  Every metric has Attributes and then the Tags from its
+// Metadata as attributes.
+type NewRelicConfig struct {
+	APIKey         string            // New Relic license or insert key
+	URL            string            // Metric API endpoint, defaulting to "https://metric-api.newrelic.com/metric/v1"; use "https://metric-api.eu.newrelic.com/metric/v1" for EU accounts
+	Registry       Registry          // Registry to be exported
+	FlushInterval  time.Duration     // Flush interval
+	DurationUnit   time.Duration     // Time conversion unit for durations
+	Prefix         string            // Prefix to be prepended to metric names
+	Attributes     map[string]string // Attributes added to every metric, eg {"service.name": "api"}
+	MetersAsCounts bool              // Report meters as counts rather than gauges of their rates
+	Client         *http.Client      // HTTP client, defaulting to http.DefaultClient
+	Filter         MetricFilter      // Selects the metrics to send, defaulting to all
+}
+
+// NewRelic is a blocking exporter function which posts the metrics in
+// c.Registry to New Relic every c.FlushInterval.
+func NewRelic(c NewRelicConfig) {
+	NewNewRelicReporter(c).Run(context.Background())
+}
+
+// NewNewRelicReporter constructs a new Reporter which exports just as
+// NewRelic does, logging any errors.
+func NewNewRelicReporter(c NewRelicConfig) *Reporter {
+	return newExporterReporter(c.Registry, NewNewRelicClient(c), SchedulerConfig{Interval: c.FlushInterval})
+}
+
+// NewRelicClient posts metrics to New Relic.  It remembers what each counter
+// stood at when last sent, and when that was, so that it can send their
+// increases and the interval they happened in.
+type NewRelicClient struct {
+	config   NewRelicConfig
+	mutex    sync.Mutex
+	counts   map[string]float64
+	lastSent time.Time
+}
+
+// NewNewRelicClient constructs a new NewRelicClient.  The first counts it
+// sends are of everything counted before, over the interval since now.
+func NewNewRelicClient(c NewRelicConfig) *NewRelicClient {
+	return &NewRelicClient{config: c, counts: make(map[string]float64), lastSent: time.Now()}
+}
+
+// newRelicMetric is one metric, in the shape the Metric API takes.
+type newRelicMetric struct {
+	Name       string            `json:"name"`
+	Type       string            `json:"type"`
+	Value      interface{}       `json:"value"`
+	IntervalMs int64             `json:"interval.ms,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// newRelicSummary is the value of a summary metric.
+type newRelicSummary struct {
+	Count int64   `json:"count"`
+	Sum   float64 `json:"sum"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+}
+
+// Send posts the metrics in the registry once.
+func (n *NewRelicClient) Send() error {
+	return n.Export(n.config.Registry)
+}
+
+// Export posts the metrics in r once, as Send does the configured
+// registry's, so that a NewRelicClient is an Exporter.
+func (n *NewRelicClient) Export(r Registry) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	now := time.Now()
+	metrics := n.metrics(r, now.Sub(n.lastSent))
+	n.lastSent = now
+	body, err := json.Marshal([]interface{}{map[string]interface{}{
+		"common":  map[string]interface{}{"timestamp": now.UnixNano() / int64(time.Millisecond)},
+		"metrics": metrics,
+	}})
+	if nil != err {
+		return err
+	}
+	u := n.config.URL
+	if "" == u {
+		u = "https://metric-api.newrelic.com/metric/v1"
+	}
+	req, err := http.NewRequest("POST", u, bytes.NewReader(body))
+	if nil != err {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Api-Key", n.config.APIKey)
+	client := n.config.Client
+	if nil == client {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if nil != err {
+		return err
+	}
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("New Relic: %s: %s", resp.Status, bytes.TrimSpace(b))
+	}
+	return nil
+}
+
+// metrics translates r, updating the remembered counts.
+func (n *NewRelicClient) metrics(r Registry, interval time.Duration) []newRelicMetric {
+	r = n.config.Filter.registry(r)
+	intervalMs := int64(interval / time.Millisecond)
+	if intervalMs < 1 {
+		intervalMs = 1
+	}
+	var metrics []newRelicMetric
+	attributes := func(tags map[string]string) map[string]string {
+		attrs := make(map[string]string, len(n.config.Attributes)+len(tags))
+		for k, v := range n.config.Attributes {
+			attrs[k] = v
+		}
+		for k, v := range tags {
+			attrs[k] = v
+		}
+		return attrs
+	}
+	gauge := func(name string, attrs map[string]string, v float64) {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return
+		}
+		metrics = append(metrics, newRelicMetric{Name: n.config.Prefix + name, Type: "gauge", Value: v, Attributes: attrs})
+	}
+	count := func(name string, attrs map[string]string, v float64) {
+		key := name + "|" + strings.Join(datadogTags(attrs), ",")
+		delta := v - n.counts[key]
+		if delta < 0 {
+			delta = v // reset, eg by Clear
+		}
+		n.counts[key] = v
+		metrics = append(metrics, newRelicMetric{Name: n.config.Prefix + name, Type: "count", Value: delta, IntervalMs: intervalMs, Attributes: attrs})
+	}
+	summary := func(name string, attrs map[string]string, s newRelicSummary, ps []float64) {
+		for _, v := range []float64{s.Sum, s.Min, s.Max} {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return
+			}
+		}
+		metrics = append(metrics, newRelicMetric{Name: n.config.Prefix + name, Type: "summary", Value: s, IntervalMs: intervalMs, Attributes: attrs})
+		for i, q := range pointQuantiles[:len(ps)] {
+			gauge(name+"."+quantileSuffix(q), attrs, ps[i])
+		}
+	}
+
+	handled := make(map[string]bool)
+	EachWithMetadata(r, func(name string, i interface{}, md Metadata) {
+		attrs := attributes(md.Tags)
+		switch metric := i.(type) {
+		case Histogram:
+			h := metric.Snapshot()
+			summary(name, attrs, newRelicSummary{h.Count(), float64(h.Sum()), float64(h.Min()), float64(h.Max())}, h.Percentiles(pointQuantiles))
+		case HistogramFloat64:
+			h := metric.Snapshot()
+			summary(name, attrs, newRelicSummary{h.Count(), h.Sum(), h.Min(), h.Max()}, h.Percentiles(pointQuantiles))
+		case SummaryFloat64:
+			s := metric.Snapshot()
+			summary(name, attrs, newRelicSummary{s.Count(), s.Sum(), s.Min(), s.Max()}, nil)
+		case Timer:
+			t := metric.Snapshot()
+			du := float64(timerDurationUnit(t, n.config.DurationUnit))
+			ps := t.Percentiles(pointQuantiles)
+			for i := range ps {
+				ps[i] /= du
+			}
+			summary(name, attrs, newRelicSummary{t.Count(), float64(t.Sum()) / du, float64(t.Min()) / du, float64(t.Max()) / du}, ps)
+		case Meter:
+			m := metric.Snapshot()
+			if n.config.MetersAsCounts {
+				count(name, attrs, float64(m.Count()))
+			} else {
+				gauge(name+".rate1", attrs, m.Rate1())
+				gauge(name+".rate5", attrs, m.Rate5())
+				gauge(name+".rate15", attrs, m.Rate15())
+				gauge(name+".mean-rate", attrs, m.RateMean())
+			}
+		default:
+			return
+		}
+		handled[name] = true
+	})
+	for _, p := range CollectPoints(r, n.config.DurationUnit) {
+		if handled[p.Name] {
+			continue
+		}
+		attrs := attributes(p.Tags)
+		if PointSum == p.Kind && p.Monotonic {
+			count(p.Name, attrs, p.Value)
+		} else {
+			gauge(p.Name, attrs, p.Value)
+		}
+	}
+	return metrics
+}
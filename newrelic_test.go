@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewRelic(t *testing.T) {
+	var keys []string
+	var bodies [][]struct {
+		Common  map[string]interface{}
+		Metrics []map[string]interface{}
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		keys = append(keys, req.Header.Get("Api-Key"))
+		b, _ := ioutil.ReadAll(req.Body)
+		bodies = append(bodies, nil)
+		if err := json.Unmarshal(b, &bodies[len(bodies)-1]); nil != err {
+			t.Error(err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	r := NewRegistry()
+	c := NewRegisteredCounter("requests", r)
+	m := NewRegisteredMeter("events", r)
+	NewRegisteredGauge("queue", r).Update(3)
+	NewRegisteredHistogram("sizes", r, NewUniformSample(100)).Update(10)
+	r.(*StandardRegistry).SetMetadata("queue", Metadata{Tags: map[string]string{"queue": "jobs"}})
+	client := NewNewRelicClient(NewRelicConfig{
+		APIKey:         "key",
+		URL:            server.URL,
+		Registry:       r,
+		Attributes:     map[string]string{"service.name": "api"},
+		MetersAsCounts: true,
+	})
+	c.Inc(5)
+	m.Mark(3)
+	if err := client.Send(); nil != err {
+		t.Fatal(err)
+	}
+	c.Inc(2)
+	if err := client.Send(); nil != err {
+		t.Fatal(err)
+	}
+	if 2 != len(bodies) || "key" != keys[0] {
+		t.Fatalf("requests: %v, %v\n", keys, bodies)
+	}
+	metric := func(i int, name string) map[string]interface{} {
+		for _, m := range bodies[i][0].Metrics {
+			if name == m["name"] {
+				return m
+			}
+		}
+		t.Errorf("%s: missing from %v\n", name, bodies[i][0].Metrics)
+		return map[string]interface{}{}
+	}
+	if m := metric(0, "requests"); "count" != m["type"] || 5.0 != m["value"] || nil == m["interval.ms"] {
+		t.Errorf("requests: %v\n", m)
+	}
+	if m := metric(1, "requests"); 2.0 != m["value"] {
+		t.Errorf("requests: 2 != %v\n", m["value"])
+	}
+	if m := metric(0, "events"); "count" != m["type"] || 3.0 != m["value"] {
+		t.Errorf("events: %v\n", m)
+	}
+	if m := metric(1, "events"); 0.0 != m["value"] {
+		t.Errorf("events: 0 != %v\n", m["value"])
+	}
+	m0 := metric(0, "queue")
+	if "gauge" != m0["type"] || 3.0 != m0["value"] {
+		t.Errorf("queue: %v\n", m0)
+	}
+	if attrs := m0["attributes"].(map[string]interface{}); "jobs" != attrs["queue"] || "api" != attrs["service.name"] {
+		t.Errorf("queue attributes: %v\n", attrs)
+	}
+	s := metric(0, "sizes")
+	if v := s["value"].(map[string]interface{}); "summary" != s["type"] || 1.0 != v["count"] || 10.0 != v["sum"] || 10.0 != v["max"] {
+		t.Errorf("sizes: %v\n", s)
+	}
+	if m := metric(0, "sizes.p99"); 10.0 != m["value"] {
+		t.Errorf("sizes.p99: 10 != %v\n", m["value"])
+	}
+}
+
+func TestNewRelicMeterRates(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredMeter("events", r).Mark(1)
+	client := NewNewRelicClient(NewRelicConfig{Registry: r})
+	names := make(map[string]string)
+	for _, m := range client.metrics(client.config.Registry, 0) {
+		names[m.Name] = m.Type
+	}
+	for _, name := range []string{"events.rate1", "events.rate5", "events.rate15", "events.mean-rate"} {
+		if "gauge" != names[name] {
+			t.Errorf("%s: gauge != %q\n", name, names[name])
+		}
+	}
+	if _, ok := names["events"]; ok {
+		t.Errorf("events: reported as a count\n")
+	}
+}
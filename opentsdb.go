@@ -2,11 +2,21 @@ package metrics
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
 	"fmt"
-	"log"
+	"io"
+	"io/ioutil"
+	"math"
 	"net"
+	"net/http"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -14,12 +24,31 @@ var shortHostName string = ""
 
 // OpenTSDBConfig provides a container with configuration parameters for
 // the OpenTSDB exporter
+//
+// Every data point is tagged with the short hostname as "host", then with
+// Tags, then with the Tags from its metric's Metadata, later tags replacing
+// earlier ones with the same key.
+//
+// Over TCP, the data points of a flush which fails, eg because the server is
+// restarting, are kept, up to BufferSize of them, and sent with the next
+// flush, so that a short outage leaves no gap.  Those beyond BufferSize are
+// dropped, oldest first, and counted in Dropped.
 type OpenTSDBConfig struct {
-	Addr          *net.TCPAddr  // Network address to connect to
-	Registry      Registry      // Registry to be exported
-	FlushInterval time.Duration // Flush interval
-	DurationUnit  time.Duration // Time conversion unit for durations
-	Prefix        string        // Prefix to be prepended to metric names
+	Addr          *net.TCPAddr      // Network address to connect to
+	Registry      Registry          // Registry to be exported
+	FlushInterval time.Duration     // Flush interval
+	DurationUnit  time.Duration     // Time conversion unit for durations
+	Prefix        string            // Prefix to be prepended to metric names
+	Tags          map[string]string // Tags added to every data point
+	URL           string            // If set, base URL of the HTTP API, eg "http://tsdb:4242", used instead of Addr
+	BatchSize     int               // Data points per HTTP request, defaulting to 50
+	Gzip          bool              // Compress HTTP request bodies
+	Client        *http.Client      // HTTP client, defaulting to http.DefaultClient
+	BufferSize    int               // Data points from failed flushes over TCP to keep for the next, if any
+	MaxBackoff    time.Duration     // If set, flushes over TCP only buffer after failing to connect, for a second doubling after each consecutive failure up to this
+	Dropped       Counter           // If set, counts the data points dropped by flushes over TCP which failed
+	Temporality   Temporality       // Whether to send counts as they stand, the default, or since the last flush
+	Filter        MetricFilter      // Selects the metrics to send, defaulting to all
 }
 
 // OpenTSDB is a blocking exporter function which reports metrics in r
@@ -38,13 +67,95 @@ func OpenTSDB(r Registry, d time.Duration, prefix string, addr *net.TCPAddr) {
 // OpenTSDBWithConfig is a blocking exporter function just like OpenTSDB,
 // but it takes a OpenTSDBConfig instead.
 func OpenTSDBWithConfig(c OpenTSDBConfig) {
-	for _ = range time.Tick(c.FlushInterval) {
-		if err := openTSDB(&c); nil != err {
-			log.Println(err)
+	NewOpenTSDBReporter(c).Run(context.Background())
+}
+
+// NewOpenTSDBReporter constructs a new Reporter which exports just as
+// OpenTSDBWithConfig does, logging any errors.
+func NewOpenTSDBReporter(c OpenTSDBConfig) *Reporter {
+	return newExporterReporter(c.Registry, NewOpenTSDBExporter(c), SchedulerConfig{Interval: c.FlushInterval})
+}
+
+// NewOpenTSDBExporter constructs a new Exporter which exports the registry
+// it's given as OpenTSDBWithConfig does, for use with a Scheduler.
+// c.Registry and c.FlushInterval are ignored.
+func NewOpenTSDBExporter(c OpenTSDBConfig) Exporter {
+	return &openTSDBExporter{config: c}
+}
+
+// openTSDBExporter is the Exporter returned by NewOpenTSDBExporter, which
+// remembers the data points it failed to send over TCP and when it may next
+// try to connect.
+type openTSDBExporter struct {
+	config   OpenTSDBConfig
+	mutex    sync.Mutex
+	buffer   []openTSDBPoint
+	failures uint
+	retryAt  time.Time
+}
+
+func (e *openTSDBExporter) Export(r Registry) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	c := e.config
+	c.Registry = r
+	now := time.Now()
+	points := openTSDBPoints(&c, now.Unix())
+	if "" != c.URL {
+		return openTSDBHTTP(&c, points)
+	}
+	points = append(e.buffer, points...)
+	e.buffer = nil
+	if now.Before(e.retryAt) {
+		e.keep(points)
+		return fmt.Errorf("OpenTSDB: reconnecting in %v with %d data points buffered", e.retryAt.Sub(now), len(e.buffer))
+	}
+	conn, err := net.DialTCP("tcp", nil, c.Addr)
+	if nil != err {
+		e.keep(points)
+		e.backoff(now)
+		return err
+	}
+	defer conn.Close()
+	e.failures, e.retryAt = 0, time.Time{}
+	if err := writeOpenTSDBTelnet(conn, points); nil != err {
+		e.keep(points)
+		return err
+	}
+	return nil
+}
+
+// backoff puts off connecting again after a failure to connect at now, for
+// a second doubling after each consecutive failure up to MaxBackoff, if set.
+func (e *openTSDBExporter) backoff(now time.Time) {
+	c := e.config
+	if 0 < c.MaxBackoff {
+		backoff := c.MaxBackoff
+		if e.failures < 32 {
+			if b := time.Second << e.failures; 0 < b && b < backoff {
+				backoff = b
+			}
 		}
+		e.failures++
+		e.retryAt = now.Add(backoff)
 	}
 }
 
+// keep buffers the given data points for the next flush, dropping the oldest
+// beyond BufferSize.
+func (e *openTSDBExporter) keep(points []openTSDBPoint) {
+	if over := len(points) - e.config.BufferSize; 0 < over {
+		if nil != e.config.Dropped {
+			e.config.Dropped.Inc(int64(over))
+		}
+		points = points[over:]
+	}
+	if 0 == len(points) {
+		points = nil
+	}
+	e.buffer = points
+}
+
 func getShortHostname() string {
 	if shortHostName == "" {
 		host, _ := os.Hostname()
@@ -58,77 +169,224 @@ func getShortHostname() string {
 }
 
 func openTSDB(c *OpenTSDBConfig) error {
-	shortHostname := getShortHostname()
-	now := time.Now().Unix()
-	du := float64(c.DurationUnit)
-	conn, err := net.DialTCP("tcp", nil, c.Addr)
+	points := openTSDBPoints(c, time.Now().Unix())
+	if "" != c.URL {
+		return openTSDBHTTP(c, points)
+	}
+	return openTSDBTelnet(c.Addr, points)
+}
+
+// openTSDBTelnet sends the given data points with "put" commands over a new
+// TCP connection to addr.
+func openTSDBTelnet(addr *net.TCPAddr, points []openTSDBPoint) error {
+	conn, err := net.DialTCP("tcp", nil, addr)
 	if nil != err {
 		return err
 	}
 	defer conn.Close()
+	return writeOpenTSDBTelnet(conn, points)
+}
+
+// writeOpenTSDBTelnet writes the given data points with "put" commands to
+// conn.
+func writeOpenTSDBTelnet(conn net.Conn, points []openTSDBPoint) error {
 	w := bufio.NewWriter(conn)
-	c.Registry.Each(func(name string, i interface{}) {
+	for _, p := range points {
+		fmt.Fprintf(w, "put %s %d %s", p.Metric, p.Timestamp, p.Value)
+		keys := make([]string, 0, len(p.Tags))
+		for k := range p.Tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(w, " %s=%s", k, p.Tags[k])
+		}
+		fmt.Fprint(w, "\n")
+	}
+	return w.Flush()
+}
+
+// openTSDBHTTP posts the given data points to OpenTSDB's /api/put endpoint
+// in batches, skipping values JSON can't represent.
+func openTSDBHTTP(c *OpenTSDBConfig, points []openTSDBPoint) error {
+	size := c.BatchSize
+	if size <= 0 {
+		size = 50
+	}
+	client := c.Client
+	if nil == client {
+		client = http.DefaultClient
+	}
+	batch := make([]openTSDBPoint, 0, size)
+	for _, p := range points {
+		if f, err := strconv.ParseFloat(string(p.Value), 64); nil != err || math.IsInf(f, 0) || math.IsNaN(f) {
+			continue
+		}
+		batch = append(batch, p)
+		if len(batch) == size {
+			if err := openTSDBPost(c, client, batch); nil != err {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if 0 < len(batch) {
+		return openTSDBPost(c, client, batch)
+	}
+	return nil
+}
+
+func openTSDBPost(c *OpenTSDBConfig, client *http.Client, batch []openTSDBPoint) error {
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	var zw *gzip.Writer
+	if c.Gzip {
+		zw = gzip.NewWriter(&buf)
+		w = zw
+	}
+	if err := json.NewEncoder(w).Encode(batch); nil != err {
+		return err
+	}
+	if nil != zw {
+		if err := zw.Close(); nil != err {
+			return err
+		}
+	}
+	req, err := http.NewRequest("POST", strings.TrimSuffix(c.URL, "/")+"/api/put", &buf)
+	if nil != err {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	resp, err := client.Do(req)
+	if nil != err {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("OpenTSDB: %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// openTSDBPoint is one data point, in the shape OpenTSDB's HTTP API takes.
+// The value is kept formatted as the telnet protocol sends it.
+type openTSDBPoint struct {
+	Metric    string            `json:"metric"`
+	Timestamp int64             `json:"timestamp"`
+	Value     json.Number       `json:"value"`
+	Tags      map[string]string `json:"tags"`
+}
+
+func openTSDBPoints(c *OpenTSDBConfig, now int64) []openTSDBPoint {
+	var points []openTSDBPoint
+	EachWithMetadata(c.Temporality.registry(c.Filter.registry(c.Registry)), func(name string, i interface{}, md Metadata) {
+		tags := map[string]string{"host": getShortHostname()}
+		for k, v := range c.Tags {
+			tags[k] = v
+		}
+		for k, v := range md.Tags {
+			tags[k] = v
+		}
+		put := func(field, verb string, v interface{}) {
+			points = append(points, openTSDBPoint{
+				Metric:    fmt.Sprintf("%s.%s.%s", c.Prefix, name, field),
+				Timestamp: now,
+				Value:     json.Number(fmt.Sprintf(verb, v)),
+				Tags:      tags,
+			})
+		}
 		switch metric := i.(type) {
 		case Counter:
-			fmt.Fprintf(w, "put %s.%s.count %d %d host=%s\n", c.Prefix, name, now, metric.Count(), shortHostname)
+			put("count", "%d", metric.Count())
+		case Uint64Counter:
+			put("count", "%d", metric.Count())
+		case BigCounter:
+			put("count", "%d", metric.Count())
 		case GaugeCounter:
-			fmt.Fprintf(w, "put %s.%s.value %d %d host=%s\n", c.Prefix, name, now, metric.Count(), shortHostname)
+			put("value", "%d", metric.Count())
 		case Gauge:
-			fmt.Fprintf(w, "put %s.%s.value %d %d host=%s\n", c.Prefix, name, now, metric.Value(), shortHostname)
+			put("value", "%d", metric.Value())
 		case GaugeFloat64:
-			fmt.Fprintf(w, "put %s.%s.value %d %f host=%s\n", c.Prefix, name, now, metric.Value(), shortHostname)
+			put("value", "%f", metric.Value())
+		case MovingAverage:
+			put("value", "%f", metric.Value())
+		case RateGauge:
+			put("value", "%f", metric.Rate())
+		case Cardinality:
+			put("value", "%d", metric.Snapshot().Estimate())
+		case BoolGauge:
+			put("value", "%d", boolGaugeValue(metric))
+		case EnumGauge:
+			eachEnumState(metric, func(state string, v int64) {
+				put(state, "%d", v)
+			})
+		case InfoMetric:
+			tags = infoTags(metric, tags)
+			put("info", "%d", 1)
 		case Histogram:
 			h := metric.Snapshot()
 			ps := h.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
-			fmt.Fprintf(w, "put %s.%s.count %d %d host=%s\n", c.Prefix, name, now, h.Count(), shortHostname)
-			fmt.Fprintf(w, "put %s.%s.min %d %d host=%s\n", c.Prefix, name, now, h.Min(), shortHostname)
-			fmt.Fprintf(w, "put %s.%s.max %d %d host=%s\n", c.Prefix, name, now, h.Max(), shortHostname)
-			fmt.Fprintf(w, "put %s.%s.mean %d %.2f host=%s\n", c.Prefix, name, now, h.Mean(), shortHostname)
-			fmt.Fprintf(w, "put %s.%s.std-dev %d %.2f host=%s\n", c.Prefix, name, now, h.StdDev(), shortHostname)
-			fmt.Fprintf(w, "put %s.%s.50-percentile %d %.2f host=%s\n", c.Prefix, name, now, ps[0], shortHostname)
-			fmt.Fprintf(w, "put %s.%s.75-percentile %d %.2f host=%s\n", c.Prefix, name, now, ps[1], shortHostname)
-			fmt.Fprintf(w, "put %s.%s.95-percentile %d %.2f host=%s\n", c.Prefix, name, now, ps[2], shortHostname)
-			fmt.Fprintf(w, "put %s.%s.99-percentile %d %.2f host=%s\n", c.Prefix, name, now, ps[3], shortHostname)
-			fmt.Fprintf(w, "put %s.%s.999-percentile %d %.2f host=%s\n", c.Prefix, name, now, ps[4], shortHostname)
+			put("count", "%d", h.Count())
+			put("min", "%d", h.Min())
+			put("max", "%d", h.Max())
+			put("mean", "%.2f", h.Mean())
+			put("std-dev", "%.2f", h.StdDev())
+			put("50-percentile", "%.2f", ps[0])
+			put("75-percentile", "%.2f", ps[1])
+			put("95-percentile", "%.2f", ps[2])
+			put("99-percentile", "%.2f", ps[3])
+			put("999-percentile", "%.2f", ps[4])
 		case HistogramFloat64:
 			h := metric.Snapshot()
 			ps := h.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
-			fmt.Fprintf(w, "put %s.%s.count %d %d host=%s\n", c.Prefix, name, now, h.Count(), shortHostname)
-			fmt.Fprintf(w, "put %s.%s.min %d %.2f host=%s\n", c.Prefix, name, now, h.Min(), shortHostname)
-			fmt.Fprintf(w, "put %s.%s.max %d %.2f host=%s\n", c.Prefix, name, now, h.Max(), shortHostname)
-			fmt.Fprintf(w, "put %s.%s.mean %d %.2f host=%s\n", c.Prefix, name, now, h.Mean(), shortHostname)
-			fmt.Fprintf(w, "put %s.%s.std-dev %d %.2f host=%s\n", c.Prefix, name, now, h.StdDev(), shortHostname)
-			fmt.Fprintf(w, "put %s.%s.50-percentile %d %.2f host=%s\n", c.Prefix, name, now, ps[0], shortHostname)
-			fmt.Fprintf(w, "put %s.%s.75-percentile %d %.2f host=%s\n", c.Prefix, name, now, ps[1], shortHostname)
-			fmt.Fprintf(w, "put %s.%s.95-percentile %d %.2f host=%s\n", c.Prefix, name, now, ps[2], shortHostname)
-			fmt.Fprintf(w, "put %s.%s.99-percentile %d %.2f host=%s\n", c.Prefix, name, now, ps[3], shortHostname)
-			fmt.Fprintf(w, "put %s.%s.999-percentile %d %.2f host=%s\n", c.Prefix, name, now, ps[4], shortHostname)
+			put("count", "%d", h.Count())
+			put("min", "%.2f", h.Min())
+			put("max", "%.2f", h.Max())
+			put("mean", "%.2f", h.Mean())
+			put("std-dev", "%.2f", h.StdDev())
+			put("50-percentile", "%.2f", ps[0])
+			put("75-percentile", "%.2f", ps[1])
+			put("95-percentile", "%.2f", ps[2])
+			put("99-percentile", "%.2f", ps[3])
+			put("999-percentile", "%.2f", ps[4])
+		case SummaryFloat64:
+			s := metric.Snapshot()
+			put("count", "%d", s.Count())
+			put("min", "%.2f", s.Min())
+			put("max", "%.2f", s.Max())
+			put("mean", "%.2f", s.Mean())
+			put("std-dev", "%.2f", s.StdDev())
+			put("sum", "%.2f", s.Sum())
 		case Meter:
 			m := metric.Snapshot()
-			fmt.Fprintf(w, "put %s.%s.count %d %d host=%s\n", c.Prefix, name, now, m.Count(), shortHostname)
-			fmt.Fprintf(w, "put %s.%s.one-minute %d %.2f host=%s\n", c.Prefix, name, now, m.Rate1(), shortHostname)
-			fmt.Fprintf(w, "put %s.%s.five-minute %d %.2f host=%s\n", c.Prefix, name, now, m.Rate5(), shortHostname)
-			fmt.Fprintf(w, "put %s.%s.fifteen-minute %d %.2f host=%s\n", c.Prefix, name, now, m.Rate15(), shortHostname)
-			fmt.Fprintf(w, "put %s.%s.mean %d %.2f host=%s\n", c.Prefix, name, now, m.RateMean(), shortHostname)
+			put("count", "%d", m.Count())
+			put("one-minute", "%.2f", m.Rate1())
+			put("five-minute", "%.2f", m.Rate5())
+			put("fifteen-minute", "%.2f", m.Rate15())
+			put("mean", "%.2f", m.RateMean())
 		case Timer:
 			t := metric.Snapshot()
+			du := float64(timerDurationUnit(t, c.DurationUnit))
 			ps := t.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
-			fmt.Fprintf(w, "put %s.%s.count %d %d host=%s\n", c.Prefix, name, now, t.Count(), shortHostname)
-			fmt.Fprintf(w, "put %s.%s.min %d %d host=%s\n", c.Prefix, name, now, t.Min()/int64(du), shortHostname)
-			fmt.Fprintf(w, "put %s.%s.max %d %d host=%s\n", c.Prefix, name, now, t.Max()/int64(du), shortHostname)
-			fmt.Fprintf(w, "put %s.%s.mean %d %.2f host=%s\n", c.Prefix, name, now, t.Mean()/du, shortHostname)
-			fmt.Fprintf(w, "put %s.%s.std-dev %d %.2f host=%s\n", c.Prefix, name, now, t.StdDev()/du, shortHostname)
-			fmt.Fprintf(w, "put %s.%s.50-percentile %d %.2f host=%s\n", c.Prefix, name, now, ps[0]/du, shortHostname)
-			fmt.Fprintf(w, "put %s.%s.75-percentile %d %.2f host=%s\n", c.Prefix, name, now, ps[1]/du, shortHostname)
-			fmt.Fprintf(w, "put %s.%s.95-percentile %d %.2f host=%s\n", c.Prefix, name, now, ps[2]/du, shortHostname)
-			fmt.Fprintf(w, "put %s.%s.99-percentile %d %.2f host=%s\n", c.Prefix, name, now, ps[3]/du, shortHostname)
-			fmt.Fprintf(w, "put %s.%s.999-percentile %d %.2f host=%s\n", c.Prefix, name, now, ps[4]/du, shortHostname)
-			fmt.Fprintf(w, "put %s.%s.one-minute %d %.2f host=%s\n", c.Prefix, name, now, t.Rate1(), shortHostname)
-			fmt.Fprintf(w, "put %s.%s.five-minute %d %.2f host=%s\n", c.Prefix, name, now, t.Rate5(), shortHostname)
-			fmt.Fprintf(w, "put %s.%s.fifteen-minute %d %.2f host=%s\n", c.Prefix, name, now, t.Rate15(), shortHostname)
-			fmt.Fprintf(w, "put %s.%s.mean-rate %d %.2f host=%s\n", c.Prefix, name, now, t.RateMean(), shortHostname)
+			put("count", "%d", t.Count())
+			put("min", "%d", t.Min()/int64(du))
+			put("max", "%d", t.Max()/int64(du))
+			put("mean", "%.2f", t.Mean()/du)
+			put("std-dev", "%.2f", t.StdDev()/du)
+			put("50-percentile", "%.2f", ps[0]/du)
+			put("75-percentile", "%.2f", ps[1]/du)
+			put("95-percentile", "%.2f", ps[2]/du)
+			put("99-percentile", "%.2f", ps[3]/du)
+			put("999-percentile", "%.2f", ps[4]/du)
+			put("one-minute", "%.2f", t.Rate1())
+			put("five-minute", "%.2f", t.Rate5())
+			put("fifteen-minute", "%.2f", t.Rate15())
+			put("mean-rate", "%.2f", t.RateMean())
 		}
-		w.Flush()
 	})
-	return nil
+	return points
 }
@@ -1,7 +1,16 @@
 package metrics
 
 import (
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"math"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
 	"time"
 )
 
@@ -19,3 +28,152 @@ func ExampleOpenTSDBWithConfig() {
 		DurationUnit:  time.Millisecond,
 	})
 }
+
+func ExampleOpenTSDBWithConfig_http() {
+	go OpenTSDBWithConfig(OpenTSDBConfig{
+		Registry:      DefaultRegistry,
+		FlushInterval: 10 * time.Second,
+		DurationUnit:  time.Millisecond,
+		Tags:          map[string]string{"env": "production"},
+		URL:           "http://localhost:4242",
+		Gzip:          true,
+	})
+}
+
+func TestOpenTSDBTags(t *testing.T) {
+	r := NewRegistry()
+	RegisterWithMetadata("foo", r, NewCounter(), Metadata{Tags: map[string]string{"region": "us-east-1"}})
+	ln, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	lines := make(chan string)
+	go func() {
+		conn, err := ln.Accept()
+		if nil != err {
+			close(lines)
+			return
+		}
+		defer conn.Close()
+		b, _ := ioutil.ReadAll(conn)
+		lines <- string(b)
+	}()
+	c := OpenTSDBConfig{Addr: ln.Addr().(*net.TCPAddr), Registry: r, Prefix: "p", Tags: map[string]string{"env": "test"}}
+	if err := openTSDB(&c); nil != err {
+		t.Fatal(err)
+	}
+	want := regexp.MustCompile(`^put p\.foo\.count \d+ 0 env=test host=\S* region=us-east-1\n$`)
+	if got := <-lines; !want.MatchString(got) {
+		t.Errorf("%q\n", got)
+	}
+}
+
+func TestOpenTSDBHTTP(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("foo", r).Inc(47)
+	NewRegisteredGauge("bar", r).Update(48)
+	NewRegisteredGaugeFloat64("baz", r).Update(math.NaN())
+	var batches [][]openTSDBPoint
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if "/api/put" != req.URL.Path || "gzip" != req.Header.Get("Content-Encoding") {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		zr, err := gzip.NewReader(req.Body)
+		if nil != err {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var batch []openTSDBPoint
+		if err := json.NewDecoder(zr).Decode(&batch); nil != err {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		batches = append(batches, batch)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+	c := OpenTSDBConfig{Registry: r, Prefix: "p", URL: ts.URL, BatchSize: 1, Gzip: true}
+	if err := openTSDB(&c); nil != err {
+		t.Fatal(err)
+	}
+	if 2 != len(batches) {
+		t.Fatalf("batches: 2 != %v\n", len(batches))
+	}
+	for _, batch := range batches {
+		if 1 != len(batch) {
+			t.Fatal(batch)
+		}
+		p := batch[0]
+		if "p.foo.count" == p.Metric && "47" != p.Value.String() || "p.bar.value" == p.Metric && "48" != p.Value.String() {
+			t.Errorf("%+v\n", p)
+		}
+	}
+
+	c.URL = ts.URL + "/nowhere"
+	c.Gzip = false
+	if err := openTSDB(&c); nil == err {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenTSDBBuffer(t *testing.T) {
+	r := NewRegistry()
+	r.Register("foo", NewCounter())
+	r.Register("bar", NewGauge())
+	ln, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if nil != err {
+		t.Fatal(err)
+	}
+	down := ln.Addr().(*net.TCPAddr)
+	ln.Close()
+	dropped := NewCounter()
+	e := NewOpenTSDBExporter(OpenTSDBConfig{Addr: down, Prefix: "p", BufferSize: 3, MaxBackoff: time.Minute, Dropped: dropped}).(*openTSDBExporter)
+	if err := e.Export(r); nil == err {
+		t.Fatal(err)
+	}
+	if 2 != len(e.buffer) {
+		t.Errorf("buffer: 2 != %v\n", len(e.buffer))
+	}
+	if e.retryAt.IsZero() {
+		t.Error("retryAt: not set")
+	}
+	if err := e.Export(r); nil == err {
+		t.Fatal(err)
+	}
+	if 3 != len(e.buffer) {
+		t.Errorf("buffer: 3 != %v\n", len(e.buffer))
+	}
+	if count := dropped.Count(); 1 != count {
+		t.Errorf("dropped: 1 != %v\n", count)
+	}
+
+	ln, err = net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	lines := make(chan string)
+	go func() {
+		conn, err := ln.Accept()
+		if nil != err {
+			close(lines)
+			return
+		}
+		defer conn.Close()
+		b, _ := ioutil.ReadAll(conn)
+		lines <- string(b)
+	}()
+	e.config.Addr = ln.Addr().(*net.TCPAddr)
+	e.retryAt = time.Time{}
+	if err := e.Export(r); nil != err {
+		t.Fatal(err)
+	}
+	if got := strings.Count(<-lines, "put "); 5 != got {
+		t.Errorf("puts: 5 != %v\n", got)
+	}
+	if 0 != len(e.buffer) || 0 != e.failures {
+		t.Errorf("buffer: %v, failures: %v\n", len(e.buffer), e.failures)
+	}
+}
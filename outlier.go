@@ -0,0 +1,29 @@
+package metrics
+
+import "sync/atomic"
+
+// outlierCount holds the threshold and Counter of a histogram or timer
+// counting its outliers, if any, for it to load on every update without
+// taking a lock.
+type outlierCount struct {
+	v atomic.Value // *outliers
+}
+
+type outliers struct {
+	threshold float64
+	counter   Counter
+}
+
+// count starts counting the values over threshold in a new Counter, which
+// it returns.
+func (o *outlierCount) count(threshold float64) Counter {
+	c := NewCounter()
+	o.v.Store(&outliers{threshold, c})
+	return c
+}
+
+func (o *outlierCount) update(v float64) {
+	if out, _ := o.v.Load().(*outliers); nil != out && v > out.threshold {
+		out.counter.Inc(1)
+	}
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistogramCountOutliers(t *testing.T) {
+	h := NewHistogram(NewUniformSample(100)).(*StandardHistogram)
+	h.Update(2000)
+	c := h.CountOutliers(1000)
+	for _, v := range []int64{10, 1000, 1001, 5000} {
+		h.Update(v)
+	}
+	h.Clear()
+	if count := c.Count(); 2 != count {
+		t.Errorf("c.Count(): 2 != %v\n", count)
+	}
+}
+
+func TestHistogramFloat64CountOutliers(t *testing.T) {
+	h := NewHistogramFloat64(NewUniformSampleFloat64(100)).(*StandardHistogramFloat64)
+	c := h.CountOutliers(0.5)
+	h.Update(0.25)
+	h.UpdateWithExemplar(0.75, map[string]string{"trace_id": "abc"})
+	if count := c.Count(); 1 != count {
+		t.Errorf("c.Count(): 1 != %v\n", count)
+	}
+}
+
+func TestTimerCountOutliers(t *testing.T) {
+	r := NewRegistry()
+	tm := NewTimer().(*StandardTimer)
+	r.Register("latency", tm)
+	r.Register("latency.Outliers", tm.CountOutliers(time.Second))
+	tm.Update(10 * time.Millisecond)
+	tm.Update(2 * time.Second)
+	if count := r.Get("latency.Outliers").(Counter).Count(); 1 != count {
+		t.Errorf("latency.Outliers: 1 != %v\n", count)
+	}
+	if count := tm.CountOutliers(time.Second).Count(); 0 != count {
+		t.Errorf("replaced Counter: 0 != %v\n", count)
+	}
+}
+
+func BenchmarkHistogramUpdateCountingOutliers(b *testing.B) {
+	h := NewHistogram(NewUniformSample(1028)).(*StandardHistogram)
+	h.CountOutliers(1 << 62)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.Update(int64(i))
+	}
+}
@@ -0,0 +1,70 @@
+package metrics
+
+import "math"
+
+// Interpolation is a method of computing a percentile which falls between two
+// values of a sample.
+type Interpolation int
+
+const (
+	// InterpolationWeibull interpolates linearly at position p*(n+1) of the
+	// n sorted values, clamping to the smallest and largest, as this package
+	// always has.
+	InterpolationWeibull Interpolation = iota
+
+	// InterpolationLinear interpolates linearly at position p*(n-1) counting
+	// from zero, as NumPy does by default and as Prometheus's quantile_over_time
+	// does.
+	InterpolationLinear
+
+	// InterpolationLower takes the value below position p*(n-1).
+	InterpolationLower
+
+	// InterpolationHigher takes the value above position p*(n-1).
+	InterpolationHigher
+
+	// InterpolationNearest takes the value nearest position p*(n-1), the even
+	// index of the two when it's halfway between.
+	InterpolationNearest
+
+	// InterpolationMidpoint takes the mean of the values either side of
+	// position p*(n-1).
+	InterpolationMidpoint
+)
+
+// PercentileInterpolation is the Interpolation SamplePercentiles and
+// SampleFloat64Percentiles use, and so the percentiles of the standard
+// samples.  Set it before recording any metrics.
+var PercentileInterpolation = InterpolationWeibull
+
+// percentilePosition returns the indexes of the two of n sorted values which
+// the p-th percentile lies between and the fraction of the way from the
+// first to the second it lies, by the given Interpolation.
+func percentilePosition(n int, p float64, m Interpolation) (lo, hi int, frac float64) {
+	if InterpolationWeibull == m {
+		pos := p * float64(n+1)
+		if pos < 1.0 {
+			return 0, 0, 0
+		} else if pos >= float64(n) {
+			return n - 1, n - 1, 0
+		}
+		return int(pos) - 1, int(pos), pos - math.Floor(pos)
+	}
+	pos := math.Max(0, math.Min(p*float64(n-1), float64(n-1)))
+	lo, hi = int(math.Floor(pos)), int(math.Ceil(pos))
+	switch m {
+	case InterpolationLower:
+		return lo, lo, 0
+	case InterpolationHigher:
+		return hi, hi, 0
+	case InterpolationNearest:
+		i := int(math.RoundToEven(pos))
+		return i, i, 0
+	case InterpolationMidpoint:
+		if lo == hi {
+			return lo, hi, 0
+		}
+		return lo, hi, 0.5
+	}
+	return lo, hi, pos - float64(lo)
+}
@@ -0,0 +1,218 @@
+package metrics
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// PerfCounterConfig provides a container with configuration parameters for
+// the Windows Performance Counters exporter, which publishes the metrics in
+// a registry as the counters of a multi-instance counter set, one instance
+// per process, for perfmon and the tools built on it.
+//
+// Windows only shows a counter set once its manifest is installed, which
+// WritePerfCounterManifest writes for the counters of a registry and
+// `lodctr /m:` installs.  Since the manifest fixes the counters, they're
+// named when the exporter is constructed, by Counters or else by the
+// metrics then registered, and metrics registered later aren't published.
+//
+// Metrics are translated as by CollectPoints.  Histograms and timers become
+// counters named eg name.count, name.sum and name.p99, and EnumGauges a
+// counter per state named eg name.running.  Every counter is a 64-bit raw
+// count, so values are rounded and negative ones published as zero.
+type PerfCounterConfig struct {
+	Registry       Registry      // Registry to be exported
+	FlushInterval  time.Duration // Flush interval
+	DurationUnit   time.Duration // Time conversion unit for durations
+	Provider       string        // Name of the provider and the counter set, eg the service's
+	ProviderGUID   string        // GUID of the provider, eg "{5b9fd8a4-6d33-4f29-9d3e-2a1e6b2f7c10}"
+	CounterSetGUID string        // GUID of the counter set
+	Instance       string        // Name of the process's instance, defaulting to eg "myservice_4242" for its executable and ID
+	Counters       []string      // Names of the counters, defaulting to those of the metrics registered when the exporter is constructed
+	Filter         MetricFilter  // Selects the metrics to publish, defaulting to all
+}
+
+// PerfCounterExporter is an Exporter which publishes the registry it's given
+// as Windows Performance Counters.  Close it to remove its instance.
+type PerfCounterExporter struct {
+	config   PerfCounterConfig
+	ids      map[string]uint32
+	provider perfCounterProvider
+}
+
+// NewPerfCounterExporter constructs a new PerfCounterExporter and registers
+// its provider and instance with Windows.  It returns an error on other
+// platforms or if the GUIDs are malformed.
+func NewPerfCounterExporter(c PerfCounterConfig) (*PerfCounterExporter, error) {
+	providerGUID, err := parsePerfGUID(c.ProviderGUID)
+	if nil != err {
+		return nil, err
+	}
+	counterSetGUID, err := parsePerfGUID(c.CounterSetGUID)
+	if nil != err {
+		return nil, err
+	}
+	if nil == c.Counters {
+		c.Counters = PerfCounterNames(c)
+	}
+	if "" == c.Instance {
+		c.Instance = fmt.Sprintf("%s_%d", strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe"), os.Getpid())
+	}
+	e := &PerfCounterExporter{config: c, ids: make(map[string]uint32, len(c.Counters))}
+	for i, name := range c.Counters {
+		e.ids[name] = uint32(i + 1)
+	}
+	if err := e.provider.start(providerGUID, counterSetGUID, c.Instance, len(c.Counters)); nil != err {
+		return nil, err
+	}
+	return e, nil
+}
+
+// NewPerfCounterReporter constructs a new Reporter which publishes c.Registry
+// every c.FlushInterval with a new PerfCounterExporter, logging any errors.
+// The exporter's instance lasts as long as the process.
+func NewPerfCounterReporter(c PerfCounterConfig) (*Reporter, error) {
+	e, err := NewPerfCounterExporter(c)
+	if nil != err {
+		return nil, err
+	}
+	return newExporterReporter(c.Registry, e, SchedulerConfig{Interval: c.FlushInterval}), nil
+}
+
+// Close removes the exporter's instance and unregisters its provider.
+func (e *PerfCounterExporter) Close() error {
+	return e.provider.close()
+}
+
+// Export sets the counters to the values of the metrics in r, skipping those
+// without a counter.
+func (e *PerfCounterExporter) Export(r Registry) error {
+	var err error
+	eachPerfCounter(CollectPoints(e.config.Filter.registry(r), e.config.DurationUnit), func(name string, v float64) {
+		id, ok := e.ids[name]
+		if !ok || nil != err {
+			return
+		}
+		err = e.provider.set(id, perfCounterValue(v))
+	})
+	return err
+}
+
+// PerfCounterNames returns the names of the counters which the metrics in
+// c.Registry, as selected by c.Filter, are published as, in the order of
+// their IDs from 1.
+func PerfCounterNames(c PerfCounterConfig) []string {
+	r := c.Registry
+	if nil == r {
+		r = DefaultRegistry
+	}
+	var names []string
+	eachPerfCounter(CollectPoints(c.Filter.registry(r), c.DurationUnit), func(name string, _ float64) {
+		names = append(names, name)
+	})
+	return names
+}
+
+// WritePerfCounterManifest writes to w the instrumentation manifest which
+// declares the provider, counter set and counters of c, with the counters
+// named by c.Counters or else PerfCounterNames, for installing with eg
+// `lodctr /m:metrics.man` before the exporter runs.
+func WritePerfCounterManifest(c PerfCounterConfig, w io.Writer) error {
+	for _, guid := range []string{c.ProviderGUID, c.CounterSetGUID} {
+		if _, err := parsePerfGUID(guid); nil != err {
+			return err
+		}
+	}
+	names := c.Counters
+	if nil == names {
+		names = PerfCounterNames(c)
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, `<?xml version="1.0" encoding="UTF-8"?>`)
+	fmt.Fprintln(bw, `<instrumentationManifest xmlns="http://schemas.microsoft.com/win/2004/08/events" xmlns:win="http://manifests.microsoft.com/win/2004/08/windows/events" xmlns:xs="http://www.w3.org/2001/XMLSchema">`)
+	fmt.Fprintln(bw, `  <instrumentation>`)
+	fmt.Fprintln(bw, `    <counters xmlns="http://schemas.microsoft.com/win/2005/12/counters" schemaVersion="2.0">`)
+	fmt.Fprintf(bw, "      <provider providerName=%s providerGuid=%s applicationIdentity=%s providerType=\"userMode\" callback=\"custom\">\n", xmlAttr(c.Provider), xmlAttr(c.ProviderGUID), xmlAttr(filepath.Base(os.Args[0])))
+	fmt.Fprintf(bw, "        <counterSet guid=%s uri=%s name=%s description=%s instances=\"multiple\">\n", xmlAttr(c.CounterSetGUID), xmlAttr(c.Provider+".Metrics"), xmlAttr(c.Provider), xmlAttr("Metrics of "+c.Provider))
+	for i, name := range names {
+		fmt.Fprintf(bw, "          <counter id=\"%d\" uri=%s name=%s description=%s type=\"perf_counter_large_rawcount\" detailLevel=\"standard\"/>\n", i+1, xmlAttr(c.Provider+".Metrics."+name), xmlAttr(name), xmlAttr(name))
+	}
+	fmt.Fprintln(bw, `        </counterSet>`)
+	fmt.Fprintln(bw, `      </provider>`)
+	fmt.Fprintln(bw, `    </counters>`)
+	fmt.Fprintln(bw, `  </instrumentation>`)
+	fmt.Fprintln(bw, `</instrumentationManifest>`)
+	return bw.Flush()
+}
+
+// eachPerfCounter calls f with the name and value of each counter the given
+// points are published as.
+func eachPerfCounter(points []Point, f func(string, float64)) {
+	for _, p := range points {
+		switch {
+		case PointSummary == p.Kind:
+			f(p.Name+".count", float64(p.Count))
+			f(p.Name+".sum", p.Sum)
+			for _, q := range p.Quantiles {
+				f(p.Name+"."+quantileSuffix(q.Quantile), q.Value)
+			}
+		case "" != p.Tags["state"]:
+			f(p.Name+"."+p.Tags["state"], p.Value)
+		default:
+			f(p.Name, p.Value)
+		}
+	}
+}
+
+// perfCounterValue rounds v to a raw count, which is unsigned.
+func perfCounterValue(v float64) uint64 {
+	switch {
+	case math.IsNaN(v) || v <= 0:
+		return 0
+	case v >= math.MaxUint64:
+		return math.MaxUint64
+	}
+	return uint64(math.Round(v))
+}
+
+// perfGUID mirrors GUID from guiddef.h.
+type perfGUID struct {
+	Data1        uint32
+	Data2, Data3 uint16
+	Data4        [8]byte
+}
+
+// parsePerfGUID parses a GUID in the registry format of a manifest, eg
+// "{5b9fd8a4-6d33-4f29-9d3e-2a1e6b2f7c10}".
+func parsePerfGUID(s string) (perfGUID, error) {
+	var g perfGUID
+	var d4 [2]uint16
+	var d5 uint64
+	if 38 != len(s) {
+		return g, fmt.Errorf("metrics: malformed GUID %q", s)
+	}
+	if n, err := fmt.Sscanf(s, "{%08x-%04x-%04x-%04x-%012x}", &g.Data1, &g.Data2, &g.Data3, &d4[0], &d5); 5 != n || nil != err {
+		return g, fmt.Errorf("metrics: malformed GUID %q", s)
+	}
+	g.Data4[0], g.Data4[1] = byte(d4[0]>>8), byte(d4[0])
+	for i := 0; i < 6; i++ {
+		g.Data4[2+i] = byte(d5 >> uint(40-8*i))
+	}
+	return g, nil
+}
+
+// xmlAttr returns s quoted and escaped as the value of an XML attribute.
+func xmlAttr(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	xml.EscapeText(&b, []byte(s))
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+// +build !windows
+
+package metrics
+
+import "errors"
+
+// perfCounterProvider is not supported on this platform.
+type perfCounterProvider struct{}
+
+func (*perfCounterProvider) start(perfGUID, perfGUID, string, int) error {
+	return errors.New("metrics: Windows performance counters are not supported on this platform")
+}
+
+func (*perfCounterProvider) set(uint32, uint64) error { return nil }
+
+func (*perfCounterProvider) close() error { return nil }
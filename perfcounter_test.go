@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/xml"
+	"math"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestPerfCounterNames(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("requests", r).Inc(47)
+	NewRegisteredGaugeFloat64("load", r).Update(0.5)
+	NewRegisteredTimer("latency", r)
+	names := PerfCounterNames(PerfCounterConfig{Registry: r, Filter: MetricFilter{Exclude: []string{"load"}}})
+	want := []string{"latency.count", "latency.sum", "latency.p50", "latency.p75", "latency.p95", "latency.p99", "latency.p999", "requests"}
+	if strings.Join(want, " ") != strings.Join(names, " ") {
+		t.Errorf("PerfCounterNames: %q != %q\n", want, names)
+	}
+}
+
+func TestWritePerfCounterManifest(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WritePerfCounterManifest(PerfCounterConfig{
+		Provider:       "Relay",
+		ProviderGUID:   "{5b9fd8a4-6d33-4f29-9d3e-2a1e6b2f7c10}",
+		CounterSetGUID: "{0c1d2e3f-4a5b-6c7d-8e9f-a0b1c2d3e4f5}",
+		Counters:       []string{"requests", `a"b<c`},
+	}, &buf); nil != err {
+		t.Fatal(err)
+	}
+	var manifest struct {
+		Counters []struct {
+			ID   string `xml:"id,attr"`
+			Name string `xml:"name,attr"`
+			Type string `xml:"type,attr"`
+		} `xml:"instrumentation>counters>provider>counterSet>counter"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &manifest); nil != err {
+		t.Fatal(err, buf.String())
+	}
+	if 2 != len(manifest.Counters) || "2" != manifest.Counters[1].ID || `a"b<c` != manifest.Counters[1].Name {
+		t.Errorf("counters: %+v\n", manifest.Counters)
+	}
+	if err := WritePerfCounterManifest(PerfCounterConfig{ProviderGUID: "5b9fd8a4"}, &buf); nil == err {
+		t.Error("malformed GUID: no error")
+	}
+}
+
+func TestParsePerfGUID(t *testing.T) {
+	g, err := parsePerfGUID("{5b9fd8a4-6d33-4f29-9d3e-2a1e6b2f7c10}")
+	if nil != err {
+		t.Fatal(err)
+	}
+	if want := (perfGUID{0x5b9fd8a4, 0x6d33, 0x4f29, [8]byte{0x9d, 0x3e, 0x2a, 0x1e, 0x6b, 0x2f, 0x7c, 0x10}}); want != g {
+		t.Errorf("parsePerfGUID: %+v != %+v\n", want, g)
+	}
+	for _, s := range []string{"", "5b9fd8a4-6d33-4f29-9d3e-2a1e6b2f7c10", "{5b9fd8a4-6d33-4f29-9d3e-2a1e6b2f7c1g}"} {
+		if _, err := parsePerfGUID(s); nil == err {
+			t.Errorf("parsePerfGUID(%q): no error\n", s)
+		}
+	}
+}
+
+func TestPerfCounterValue(t *testing.T) {
+	for v, want := range map[float64]uint64{2.5: 3, 47: 47, -1: 0, math.NaN(): 0, math.Inf(1): math.MaxUint64} {
+		if got := perfCounterValue(v); want != got {
+			t.Errorf("perfCounterValue(%v): %v != %v\n", v, want, got)
+		}
+	}
+}
+
+func TestNewPerfCounterExporterUnsupported(t *testing.T) {
+	if "windows" == runtime.GOOS {
+		t.Skip("supported on Windows")
+	}
+	if _, err := NewPerfCounterExporter(PerfCounterConfig{
+		Registry:       NewRegistry(),
+		ProviderGUID:   "{5b9fd8a4-6d33-4f29-9d3e-2a1e6b2f7c10}",
+		CounterSetGUID: "{0c1d2e3f-4a5b-6c7d-8e9f-a0b1c2d3e4f5}",
+	}); nil == err {
+		t.Error("no error")
+	}
+}
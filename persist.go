@@ -0,0 +1,260 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// maxRestoredReservoirSize is the largest reservoir restoreMetric will
+// allocate for a histogram, to keep a corrupt or hostile dump from exhausting
+// memory.
+const maxRestoredReservoirSize = 1 << 20
+
+// registryDump is the document Dump writes and RestoreRegistry reads.
+type registryDump struct {
+	Version int                     `json:"version"`
+	Metrics map[string]dumpedMetric `json:"metrics"`
+}
+
+// dumpedMetric is the state of one metric in a registryDump.  Which fields
+// are set depends on Type.
+type dumpedMetric struct {
+	Type          string    `json:"type"`
+	Value         int64     `json:"value,omitempty"`
+	BigValue      string    `json:"bigValue,omitempty"` // Decimal, for counts which may not fit in Value
+	FloatValue    float64   `json:"floatValue,omitempty"`
+	Sample        string    `json:"sample,omitempty"`
+	ReservoirSize int       `json:"reservoirSize,omitempty"`
+	Alpha         float64   `json:"alpha,omitempty"`
+	Count         int64     `json:"count,omitempty"`
+	Values        []int64   `json:"values,omitempty"`
+	FloatValues   []float64 `json:"floatValues,omitempty"`
+}
+
+// DumpRegistry writes the state of the counters, gauge counters, gauges and
+// histograms in r to w as JSON, so that it can be restored with
+// RestoreRegistry after a process restarts or in another process which takes
+// over from this one.
+//
+// Histograms are dumped with the values in their reservoirs, and only if
+// they're backed by a uniform or exponentially-decaying sample.  Other
+// metrics, and float64 gauges which aren't finite, are left out.
+func DumpRegistry(r Registry, w io.Writer) error {
+	dump := registryDump{Version: 1, Metrics: make(map[string]dumpedMetric)}
+	r.Each(func(name string, i interface{}) {
+		if m, ok := dumpMetric(i); ok {
+			dump.Metrics[name] = m
+		}
+	})
+	return json.NewEncoder(w).Encode(dump)
+}
+
+// Dump writes the state of the registry's metrics to w as DumpRegistry does.
+func (r *StandardRegistry) Dump(w io.Writer) error {
+	return DumpRegistry(r, w)
+}
+
+// Dump writes the state of the registry's metrics to w as DumpRegistry does.
+func (r *ShardedRegistry) Dump(w io.Writer) error {
+	return DumpRegistry(r, w)
+}
+
+// RestoreRegistry reads the JSON written by DumpRegistry and returns a new
+// registry holding metrics of the same kinds, names and state.
+// Exponentially-decaying samples are refilled as if all their values had
+// been recorded at the time of the restore.
+func RestoreRegistry(rd io.Reader) (Registry, error) {
+	var dump registryDump
+	if err := json.NewDecoder(rd).Decode(&dump); nil != err {
+		return nil, err
+	}
+	if 1 != dump.Version {
+		return nil, fmt.Errorf("metrics: unsupported registry dump version %d", dump.Version)
+	}
+	r := NewRegistry()
+	for name, m := range dump.Metrics {
+		i, err := restoreMetric(m)
+		if nil != err {
+			return nil, fmt.Errorf("metrics: restoring %s: %v", name, err)
+		}
+		r.Register(name, i)
+	}
+	return r, nil
+}
+
+func dumpMetric(i interface{}) (dumpedMetric, bool) {
+	switch metric := i.(type) {
+	case Counter:
+		return dumpedMetric{Type: "counter", Value: metric.Count()}, true
+	case Uint64Counter:
+		return dumpedMetric{Type: "uint64counter", BigValue: strconv.FormatUint(metric.Count(), 10)}, true
+	case BigCounter:
+		return dumpedMetric{Type: "bigcounter", BigValue: metric.Count().String()}, true
+	case GaugeCounter:
+		return dumpedMetric{Type: "gaugecounter", Value: metric.Count()}, true
+	case Gauge:
+		return dumpedMetric{Type: "gauge", Value: metric.Value()}, true
+	case GaugeFloat64:
+		v := metric.Value()
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return dumpedMetric{}, false
+		}
+		return dumpedMetric{Type: "gaugefloat64", FloatValue: v}, true
+	case Histogram:
+		m := dumpedMetric{Type: "histogram"}
+		switch s := metric.Sample().(type) {
+		case *UniformSample:
+			s.mutex.Lock()
+			defer s.mutex.Unlock()
+			m.Sample, m.ReservoirSize, m.Count = "uniform", s.reservoirSize, s.count
+			m.Values = append([]int64(nil), s.values...)
+		case *ExpDecaySample:
+			s.mutex.Lock()
+			defer s.mutex.Unlock()
+			m.Sample, m.ReservoirSize, m.Alpha, m.Count = "expdecay", s.reservoirSize, s.alpha, s.count
+			for _, v := range s.values.Values() {
+				m.Values = append(m.Values, v.v)
+			}
+		default:
+			return dumpedMetric{}, false
+		}
+		return m, true
+	case HistogramFloat64:
+		m := dumpedMetric{Type: "histogramfloat64"}
+		switch s := metric.Sample().(type) {
+		case *UniformSampleFloat64:
+			s.mutex.Lock()
+			defer s.mutex.Unlock()
+			m.Sample, m.ReservoirSize, m.Count = "uniform", s.reservoirSize, s.count
+			m.FloatValues = append([]float64(nil), s.values...)
+		case *ExpDecaySampleFloat64:
+			s.mutex.Lock()
+			defer s.mutex.Unlock()
+			m.Sample, m.ReservoirSize, m.Alpha, m.Count = "expdecay", s.reservoirSize, s.alpha, s.count
+			for _, v := range s.values.Values() {
+				m.FloatValues = append(m.FloatValues, v.v)
+			}
+		default:
+			return dumpedMetric{}, false
+		}
+		for _, v := range m.FloatValues {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return dumpedMetric{}, false
+			}
+		}
+		return m, true
+	}
+	return dumpedMetric{}, false
+}
+
+func restoreMetric(m dumpedMetric) (interface{}, error) {
+	switch m.Type {
+	case "counter":
+		c := NewCounter()
+		c.Inc(m.Value)
+		return c, nil
+	case "uint64counter":
+		v, err := strconv.ParseUint(m.BigValue, 10, 64)
+		if nil != err {
+			return nil, err
+		}
+		c := NewUint64Counter()
+		c.Inc(v)
+		return c, nil
+	case "bigcounter":
+		v, err := m.bigValue()
+		if nil != err {
+			return nil, err
+		}
+		c := NewBigCounter()
+		c.Add(v)
+		return c, nil
+	case "gaugecounter":
+		c := NewGaugeCounter()
+		c.Inc(m.Value)
+		return c, nil
+	case "gauge":
+		g := NewGauge()
+		g.Update(m.Value)
+		return g, nil
+	case "gaugefloat64":
+		g := NewGaugeFloat64()
+		g.Update(m.FloatValue)
+		return g, nil
+	case "histogram":
+		if err := checkReservoirSize(m.ReservoirSize, len(m.Values)); nil != err {
+			return nil, err
+		}
+		switch m.Sample {
+		case "uniform":
+			s := &UniformSample{
+				count:         m.Count,
+				reservoirSize: m.ReservoirSize,
+				values:        make([]int64, 0, m.ReservoirSize),
+			}
+			s.values = append(s.values, m.Values...)
+			return NewHistogram(s), nil
+		case "expdecay":
+			s := newExpDecaySample(m.ReservoirSize, m.Alpha)
+			now := s.clock.Now()
+			for _, v := range m.Values {
+				s.update(now, v)
+			}
+			s.count = m.Count
+			return NewHistogram(s), nil
+		}
+		return nil, fmt.Errorf("unknown sample %q", m.Sample)
+	case "histogramfloat64":
+		if err := checkReservoirSize(m.ReservoirSize, len(m.FloatValues)); nil != err {
+			return nil, err
+		}
+		switch m.Sample {
+		case "uniform":
+			s := &UniformSampleFloat64{
+				count:         m.Count,
+				reservoirSize: m.ReservoirSize,
+				values:        make([]float64, 0, m.ReservoirSize),
+			}
+			s.values = append(s.values, m.FloatValues...)
+			return NewHistogramFloat64(s), nil
+		case "expdecay":
+			s := newExpDecaySampleFloat64(m.ReservoirSize, m.Alpha)
+			now := s.clock.Now()
+			for _, v := range m.FloatValues {
+				s.update(now, v)
+			}
+			s.count = m.Count
+			return NewHistogramFloat64(s), nil
+		}
+		return nil, fmt.Errorf("unknown sample %q", m.Sample)
+	}
+	return nil, fmt.Errorf("unknown type %q", m.Type)
+}
+
+// checkReservoirSize returns an error unless a dumped histogram's reservoir
+// is of a size restoreMetric can allocate and holds its n values.
+func checkReservoirSize(size, n int) error {
+	if size <= 0 || size > maxRestoredReservoirSize {
+		return fmt.Errorf("invalid reservoir size %d", size)
+	}
+	if size < n {
+		return fmt.Errorf("%d values in a reservoir of %d", n, size)
+	}
+	return nil
+}
+
+// bigValue returns the count of a dumped counter of any kind as a big.Int.
+func (m dumpedMetric) bigValue() (*big.Int, error) {
+	if "counter" == m.Type {
+		return big.NewInt(m.Value), nil
+	}
+	v, ok := new(big.Int).SetString(m.BigValue, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid count %q", m.BigValue)
+	}
+	return v, nil
+}
@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestDumpRestoreRegistry(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("counter", r).Inc(47)
+	gc := NewGaugeCounter()
+	gc.Inc(5)
+	gc.Dec(2)
+	r.Register("gaugecounter", gc)
+	NewRegisteredGauge("gauge", r).Update(-3)
+	NewRegisteredGaugeFloat64("gaugefloat64", r).Update(1.5)
+	NewRegisteredGaugeFloat64("nan", r).Update(math.NaN())
+	h := NewRegisteredHistogram("histogram", r, NewUniformSample(10))
+	he := NewRegisteredHistogram("histogram.expdecay", r, NewExpDecaySample(10, 0.015))
+	hf := NewRegisteredHistogramFloat64("histogramfloat64", r, NewUniformSampleFloat64(10))
+	for i := 1; i <= 20; i++ {
+		h.Update(int64(i))
+		he.Update(int64(i))
+		hf.Update(float64(i) / 2)
+	}
+	NewRegisteredMeter("meter", r)
+
+	var buf bytes.Buffer
+	if err := r.(*StandardRegistry).Dump(&buf); nil != err {
+		t.Fatal(err)
+	}
+	restored, err := RestoreRegistry(&buf)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if c := restored.Get("counter").(Counter).Count(); 47 != c {
+		t.Errorf("counter: 47 != %v\n", c)
+	}
+	if c := restored.Get("gaugecounter").(GaugeCounter).Count(); 3 != c {
+		t.Errorf("gaugecounter: 3 != %v\n", c)
+	}
+	if v := restored.Get("gauge").(Gauge).Value(); -3 != v {
+		t.Errorf("gauge: -3 != %v\n", v)
+	}
+	if v := restored.Get("gaugefloat64").(GaugeFloat64).Value(); 1.5 != v {
+		t.Errorf("gaugefloat64: 1.5 != %v\n", v)
+	}
+	for _, name := range []string{"nan", "meter"} {
+		if i := restored.Get(name); nil != i {
+			t.Errorf("%s: %v\n", name, i)
+		}
+	}
+	for _, name := range []string{"histogram", "histogram.expdecay"} {
+		got, want := restored.Get(name).(Histogram), r.Get(name).(Histogram)
+		if got.Count() != want.Count() || got.Sum() != want.Sum() || got.Max() != want.Max() {
+			t.Errorf("%s: count %v, sum %v, max %v != count %v, sum %v, max %v\n", name, want.Count(), want.Sum(), want.Max(), got.Count(), got.Sum(), got.Max())
+		}
+	}
+	got := restored.Get("histogramfloat64").(HistogramFloat64)
+	if got.Count() != hf.Count() || got.Sum() != hf.Sum() {
+		t.Errorf("histogramfloat64: count %v, sum %v != count %v, sum %v\n", hf.Count(), hf.Sum(), got.Count(), got.Sum())
+	}
+}
+
+func TestRestoreRegistryErrors(t *testing.T) {
+	for _, s := range []string{
+		`not json`,
+		`{"version":2,"metrics":{}}`,
+		`{"version":1,"metrics":{"foo":{"type":"bar"}}}`,
+		`{"version":1,"metrics":{"foo":{"type":"histogram","sample":"uniform","reservoirSize":1,"values":[1,2]}}}`,
+		`{"version":1,"metrics":{"foo":{"type":"histogram","sample":"expdecay","alpha":0.015}}}`,
+		`{"version":1,"metrics":{"foo":{"type":"histogram","sample":"uniform","reservoirSize":4611686018427387904}}}`,
+	} {
+		if _, err := RestoreRegistry(strings.NewReader(s)); nil == err {
+			t.Errorf("RestoreRegistry(%s): nil error\n", s)
+		}
+	}
+}
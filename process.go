@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"log"
+	"time"
+)
+
+var (
+	processMetrics struct {
+		CPUSeconds       GaugeFloat64
+		RSS              Gauge
+		VMS              Gauge
+		OpenFDs          Gauge
+		NumThread        Gauge
+		ReadProcessStats Timer
+	}
+	procStats processStats
+)
+
+// processStats holds the raw values read from the operating system for the
+// current process.  Fields the platform cannot provide are left at zero.
+type processStats struct {
+	cpuSeconds float64
+	rss        int64
+	vms        int64
+	openFDs    int64
+	numThread  int64
+}
+
+// Capture new values for the process statistics of the current process.
+// This is designed to be called as a goroutine.
+func CaptureProcessStats(r Registry, d time.Duration) {
+	for _ = range time.Tick(d) {
+		if err := CaptureProcessStatsOnce(r); nil != err {
+			log.Println(err)
+		}
+	}
+}
+
+// Capture new values for the process statistics of the current process.
+// This is designed to be called in a background goroutine.  Giving a
+// registry which has not been given to RegisterProcessStats will panic.
+//
+// On Linux the values are read from /proc/self.  Other platforms use the
+// closest equivalent they offer; see readProcessStats for each platform.
+// If reading fails the gauges keep their previous values.
+func CaptureProcessStatsOnce(r Registry) error {
+	t := time.Now()
+	err := readProcessStats(&procStats)
+	processMetrics.ReadProcessStats.UpdateSince(t)
+	if nil != err {
+		return err
+	}
+
+	processMetrics.CPUSeconds.Update(procStats.cpuSeconds)
+	processMetrics.RSS.Update(procStats.rss)
+	processMetrics.VMS.Update(procStats.vms)
+	processMetrics.OpenFDs.Update(procStats.openFDs)
+	processMetrics.NumThread.Update(procStats.numThread)
+	return nil
+}
+
+// Register metrics for the operating system statistics of the current
+// process: CPU time in seconds, resident and virtual memory in bytes, open
+// file descriptors and the number of threads.  The metrics are named
+// process.CPUSeconds, process.RSS, and so on.
+func RegisterProcessStats(r Registry) {
+	processMetrics.CPUSeconds = NewGaugeFloat64()
+	processMetrics.RSS = NewGauge()
+	processMetrics.VMS = NewGauge()
+	processMetrics.OpenFDs = NewGauge()
+	processMetrics.NumThread = NewGauge()
+	processMetrics.ReadProcessStats = NewTimer()
+
+	r.Register("process.CPUSeconds", processMetrics.CPUSeconds)
+	r.Register("process.RSS", processMetrics.RSS)
+	r.Register("process.VMS", processMetrics.VMS)
+	r.Register("process.OpenFDs", processMetrics.OpenFDs)
+	r.Register("process.NumThread", processMetrics.NumThread)
+	r.Register("process.ReadProcessStats", processMetrics.ReadProcessStats)
+}
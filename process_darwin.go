@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"os"
+	"syscall"
+)
+
+// readProcessStats uses getrusage(2) for CPU time and counts the entries in
+// /dev/fd.  Darwin has no unprivileged way to read the current resident or
+// virtual size without cgo, so RSS reports the peak resident size and VMS is
+// left at zero.  The thread count is the number of OS threads the Go runtime
+// has created.
+func readProcessStats(s *processStats) error {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); nil != err {
+		return err
+	}
+	f, err := os.Open("/dev/fd")
+	if nil != err {
+		return err
+	}
+	defer f.Close()
+	names, err := f.Readdirnames(-1)
+	if nil != err {
+		return err
+	}
+
+	s.cpuSeconds = timevalSeconds(ru.Utime) + timevalSeconds(ru.Stime)
+	s.rss = int64(ru.Maxrss) // Bytes on darwin, unlike Linux.
+	s.openFDs = int64(len(names))
+	s.numThread = int64(threadCreateProfile.Count())
+	return nil
+}
+
+func timevalSeconds(tv syscall.Timeval) float64 {
+	return float64(tv.Sec) + float64(tv.Usec)/1e6
+}
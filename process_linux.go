@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+)
+
+// clockTicks is the kernel's USER_HZ, which is 100 on every architecture Go
+// supports.  Reading it properly needs sysconf(3) and therefore cgo.
+const clockTicks = 100
+
+// readProcessStats reads /proc/self/stat for CPU time, memory and thread
+// count and counts the entries in /proc/self/fd.
+func readProcessStats(s *processStats) error {
+	data, err := ioutil.ReadFile("/proc/self/stat")
+	if nil != err {
+		return err
+	}
+	// The command name is in parentheses and may itself contain spaces or
+	// parentheses, so split only what follows the last closing paren.
+	i := bytes.LastIndexByte(data, ')')
+	if i < 0 {
+		return fmt.Errorf("malformed /proc/self/stat: %q", data)
+	}
+	fields := bytes.Fields(data[i+1:])
+	// fields[0] is field 3 (state) in proc(5) numbering.
+	if len(fields) < 22 {
+		return fmt.Errorf("malformed /proc/self/stat: %q", data)
+	}
+	field := func(n int) (int64, error) {
+		return strconv.ParseInt(string(fields[n-3]), 10, 64)
+	}
+	utime, err := field(14)
+	if nil != err {
+		return err
+	}
+	stime, err := field(15)
+	if nil != err {
+		return err
+	}
+	numThread, err := field(20)
+	if nil != err {
+		return err
+	}
+	vsize, err := field(23)
+	if nil != err {
+		return err
+	}
+	rss, err := field(24)
+	if nil != err {
+		return err
+	}
+
+	fds, err := ioutil.ReadDir("/proc/self/fd")
+	if nil != err {
+		return err
+	}
+
+	s.cpuSeconds = float64(utime+stime) / clockTicks
+	s.rss = rss * int64(os.Getpagesize())
+	s.vms = vsize
+	s.openFDs = int64(len(fds))
+	s.numThread = numThread
+	return nil
+}
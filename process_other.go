@@ -0,0 +1,11 @@
+//go:build !linux && !darwin && !windows
+// +build !linux,!darwin,!windows
+
+package metrics
+
+import "errors"
+
+// readProcessStats is not supported on this platform.
+func readProcessStats(s *processStats) error {
+	return errors.New("metrics: process stats are not supported on this platform")
+}
@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func BenchmarkProcessStats(b *testing.B) {
+	r := NewRegistry()
+	RegisterProcessStats(r)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		CaptureProcessStatsOnce(r)
+	}
+}
+
+func TestProcessStats(t *testing.T) {
+	switch runtime.GOOS {
+	case "linux", "darwin", "windows":
+	default:
+		t.Skipf("process stats are not supported on %s", runtime.GOOS)
+	}
+	r := NewRegistry()
+	RegisterProcessStats(r)
+	if err := CaptureProcessStatsOnce(r); nil != err {
+		t.Fatal(err)
+	}
+	if v := r.Get("process.RSS").(Gauge).Value(); v <= 0 {
+		t.Errorf("process.RSS: %d <= 0", v)
+	}
+	if v := r.Get("process.OpenFDs").(Gauge).Value(); v <= 0 {
+		t.Errorf("process.OpenFDs: %d <= 0", v)
+	}
+	if v := r.Get("process.NumThread").(Gauge).Value(); v < 1 {
+		t.Errorf("process.NumThread: %d < 1", v)
+	}
+	if v := r.Get("process.CPUSeconds").(GaugeFloat64).Value(); v < 0 {
+		t.Errorf("process.CPUSeconds: %f < 0", v)
+	}
+	if 1 != processMetrics.ReadProcessStats.Count() {
+		t.Errorf("process.ReadProcessStats.Count(): 1 != %d", processMetrics.ReadProcessStats.Count())
+	}
+}
+
+func TestProcessStatsCPU(t *testing.T) {
+	if "linux" != runtime.GOOS {
+		t.Skip("CPU accounting granularity is only known on linux")
+	}
+	r := NewRegistry()
+	RegisterProcessStats(r)
+	CaptureProcessStatsOnce(r)
+	before := processMetrics.CPUSeconds.Value()
+	// Burn well over one clock tick of CPU time.
+	for t0 := time.Now(); time.Since(t0) < 100*time.Millisecond; {
+	}
+	CaptureProcessStatsOnce(r)
+	if after := processMetrics.CPUSeconds.Value(); after <= before {
+		t.Errorf("process.CPUSeconds did not advance: %f <= %f", after, before)
+	}
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var (
+	modpsapi                  = syscall.NewLazyDLL("psapi.dll")
+	modkernel32               = syscall.NewLazyDLL("kernel32.dll")
+	procGetProcessMemoryInfo  = modpsapi.NewProc("GetProcessMemoryInfo")
+	procGetProcessHandleCount = modkernel32.NewProc("GetProcessHandleCount")
+)
+
+// processMemoryCounters mirrors PROCESS_MEMORY_COUNTERS from psapi.h.
+type processMemoryCounters struct {
+	cb                         uint32
+	PageFaultCount             uint32
+	PeakWorkingSetSize         uintptr
+	WorkingSetSize             uintptr
+	QuotaPeakPagedPoolUsage    uintptr
+	QuotaPagedPoolUsage        uintptr
+	QuotaPeakNonPagedPoolUsage uintptr
+	QuotaNonPagedPoolUsage     uintptr
+	PagefileUsage              uintptr
+	PeakPagefileUsage          uintptr
+}
+
+// readProcessStats uses GetProcessTimes for CPU time and
+// GetProcessMemoryInfo for the working set (RSS) and pagefile usage (VMS).
+// Open handles stand in for file descriptors and the thread count is the
+// number of OS threads the Go runtime has created.
+func readProcessStats(s *processStats) error {
+	h, err := syscall.GetCurrentProcess()
+	if nil != err {
+		return err
+	}
+
+	var creation, exit, kernel, user syscall.Filetime
+	if err := syscall.GetProcessTimes(h, &creation, &exit, &kernel, &user); nil != err {
+		return err
+	}
+
+	var mem processMemoryCounters
+	mem.cb = uint32(unsafe.Sizeof(mem))
+	if r, _, err := procGetProcessMemoryInfo.Call(uintptr(h), uintptr(unsafe.Pointer(&mem)), uintptr(mem.cb)); 0 == r {
+		return err
+	}
+
+	var handles uint32
+	if r, _, err := procGetProcessHandleCount.Call(uintptr(h), uintptr(unsafe.Pointer(&handles))); 0 == r {
+		return err
+	}
+
+	s.cpuSeconds = filetimeSeconds(kernel) + filetimeSeconds(user)
+	s.rss = int64(mem.WorkingSetSize)
+	s.vms = int64(mem.PagefileUsage)
+	s.openFDs = int64(handles)
+	s.numThread = int64(threadCreateProfile.Count())
+	return nil
+}
+
+// filetimeSeconds converts a FILETIME duration, in 100ns units, to seconds.
+func filetimeSeconds(ft syscall.Filetime) float64 {
+	return float64(uint64(ft.HighDateTime)<<32|uint64(ft.LowDateTime)) / 1e7
+}
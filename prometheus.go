@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// writePrometheusText writes the metrics in r in the Prometheus text
+// exposition format, translated as by CollectPoints and named as by the
+// remote-write exporter.
+func writePrometheusText(w io.Writer, r Registry, scale time.Duration, prefix string) error {
+	bw := bufio.NewWriter(w)
+	var last string
+	for _, p := range CollectPoints(r, scale) {
+		name := prometheusName(prefix + p.Name)
+		labels := prometheusLabels(p.Tags)
+		if name == last {
+			// Another state of an EnumGauge, which shares its HELP and TYPE.
+			fmt.Fprintf(bw, "%s%s %s\n", name, labels, prometheusValue(p.Value))
+			continue
+		}
+		last = name
+		if "" != p.Description {
+			fmt.Fprintf(bw, "# HELP %s %s\n", name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(p.Description))
+		}
+		switch {
+		case PointSummary == p.Kind:
+			fmt.Fprintf(bw, "# TYPE %s summary\n", name)
+			for _, q := range p.Quantiles {
+				tags := make(map[string]string, len(p.Tags)+1)
+				for k, v := range p.Tags {
+					tags[k] = v
+				}
+				tags["quantile"] = strconv.FormatFloat(q.Quantile, 'g', -1, 64)
+				fmt.Fprintf(bw, "%s%s %s\n", name, prometheusLabels(tags), prometheusValue(q.Value))
+			}
+			fmt.Fprintf(bw, "%s_sum%s %s\n", name, labels, prometheusValue(p.Sum))
+			fmt.Fprintf(bw, "%s_count%s %d\n", name, labels, p.Count)
+		case PointSum == p.Kind && p.Monotonic:
+			fmt.Fprintf(bw, "# TYPE %s counter\n%s%s %s\n", name, name, labels, prometheusValue(p.Value))
+		default:
+			fmt.Fprintf(bw, "# TYPE %s gauge\n%s%s %s\n", name, name, labels, prometheusValue(p.Value))
+		}
+	}
+	return bw.Flush()
+}
+
+// prometheusLabels renders the given labels sorted by name, eg
+// `{region="us-east-1"}`, or returns "" if there are none.
+func prometheusLabels(labels map[string]string) string {
+	if 0 == len(labels) {
+		return ""
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, k := range keys {
+		if 0 < i {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, prometheusName(k), escape.Replace(labels[k]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func prometheusValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
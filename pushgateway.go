@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PushgatewayConfig provides a container with configuration parameters for
+// a PushgatewayClient.
+type PushgatewayConfig struct {
+	URL          string            // Pushgateway's base URL, eg "http://pushgateway:9091"
+	Job          string            // Job label of the group pushed to
+	Grouping     map[string]string // Further labels identifying the group, eg {"instance": "host1"}
+	Registry     Registry          // Registry to be pushed
+	DurationUnit time.Duration     // Time conversion unit for durations
+	Prefix       string            // Prefix to be prepended to metric names
+	Headers      map[string]string // Extra request headers, eg for authorization
+	Client       *http.Client      // HTTP client, defaulting to http.DefaultClient
+	Filter       MetricFilter      // Selects the metrics to push, defaulting to all
+}
+
+// PushgatewayClient pushes snapshots of a registry to a Prometheus
+// Pushgateway, for cron-style jobs which aggregate with this package but
+// don't live long enough to be scraped.  Metrics are translated as by the
+// remote-write exporter.
+type PushgatewayClient struct {
+	config PushgatewayConfig
+}
+
+// NewPushgatewayClient constructs a new PushgatewayClient.
+func NewPushgatewayClient(c PushgatewayConfig) *PushgatewayClient {
+	return &PushgatewayClient{config: c}
+}
+
+// Push replaces every metric in the group with the registry's.
+func (p *PushgatewayClient) Push() error {
+	return p.push("PUT", p.config.Registry)
+}
+
+// Export replaces every metric in the group with r's, as Push does with the
+// configured registry's, so that a PushgatewayClient is an Exporter.
+func (p *PushgatewayClient) Export(r Registry) error {
+	return p.push("PUT", r)
+}
+
+// Add replaces only the metrics in the group with the same names as the
+// registry's.
+func (p *PushgatewayClient) Add() error {
+	return p.push("POST", p.config.Registry)
+}
+
+// Delete deletes every metric in the group, eg when the job shuts down.
+func (p *PushgatewayClient) Delete() error {
+	return p.do("DELETE", nil)
+}
+
+func (p *PushgatewayClient) push(method string, r Registry) error {
+	var buf bytes.Buffer
+	if err := writePrometheusText(&buf, p.config.Filter.registry(r), p.config.DurationUnit, p.config.Prefix); nil != err {
+		return err
+	}
+	return p.do(method, &buf)
+}
+
+func (p *PushgatewayClient) do(method string, body io.Reader) error {
+	req, err := http.NewRequest(method, p.url(), body)
+	if nil != err {
+		return err
+	}
+	if nil != body {
+		req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	}
+	for k, v := range p.config.Headers {
+		req.Header.Set(k, v)
+	}
+	client := p.config.Client
+	if nil == client {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if nil != err {
+		return err
+	}
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("pushgateway: %s: %s", resp.Status, bytes.TrimSpace(b))
+	}
+	return nil
+}
+
+// url returns the URL of the group, eg
+// "http://pushgateway:9091/metrics/job/backup/instance/host1".
+func (p *PushgatewayClient) url() string {
+	var b strings.Builder
+	b.WriteString(strings.TrimSuffix(p.config.URL, "/"))
+	b.WriteString("/metrics")
+	b.WriteString(pushgatewayLabel("job", p.config.Job))
+	keys := make([]string, 0, len(p.config.Grouping))
+	for k := range p.config.Grouping {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.WriteString(pushgatewayLabel(k, p.config.Grouping[k]))
+	}
+	return b.String()
+}
+
+// pushgatewayLabel renders one label of a group's URL path, base64-encoding
+// values which are empty or contain slashes as the Pushgateway requires.
+func pushgatewayLabel(name, value string) string {
+	if "" == value {
+		return "/" + name + "@base64/="
+	}
+	if strings.Contains(value, "/") {
+		return "/" + name + "@base64/" + base64.RawURLEncoding.EncodeToString([]byte(value))
+	}
+	return "/" + name + "/" + url.PathEscape(value)
+}
@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPushgatewayClient(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("foo", r).Inc(47)
+	var method, path, body string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		method, path, body = req.Method, req.URL.EscapedPath(), string(b)
+	}))
+	defer ts.Close()
+	p := NewPushgatewayClient(PushgatewayConfig{
+		URL:      ts.URL + "/",
+		Job:      "backup",
+		Grouping: map[string]string{"instance": "host1", "path": "/var/db", "shard": ""},
+		Registry: r,
+	})
+	if err := p.Push(); nil != err {
+		t.Fatal(err)
+	}
+	if want := "/metrics/job/backup/instance/host1/path@base64/L3Zhci9kYg/shard@base64/="; "PUT" != method || want != path {
+		t.Errorf("%v %v\n", method, path)
+	}
+	if want := "# TYPE foo counter\nfoo 47\n"; want != body {
+		t.Errorf("body: %q != %q\n", want, body)
+	}
+	if err := p.Add(); nil != err || "POST" != method {
+		t.Fatal(err, method)
+	}
+	if err := p.Delete(); nil != err || "DELETE" != method || "" != body {
+		t.Fatal(err, method, body)
+	}
+}
+
+func TestWritePrometheusText(t *testing.T) {
+	r := NewRegistry()
+	RegisterWithMetadata("foo.bar", r, NewGauge(), Metadata{Description: "Foo\nbar", Tags: map[string]string{"q": `"x"`}})
+	h := NewRegisteredHistogram("baz", r, NewUniformSample(10))
+	h.Update(1)
+	var b strings.Builder
+	if err := writePrometheusText(&b, r, 1, "p_"); nil != err {
+		t.Fatal(err)
+	}
+	want := `# TYPE p_baz summary
+p_baz{quantile="0.5"} 1
+p_baz{quantile="0.75"} 1
+p_baz{quantile="0.95"} 1
+p_baz{quantile="0.99"} 1
+p_baz{quantile="0.999"} 1
+p_baz_sum 1
+p_baz_count 1
+# HELP p_foo_bar Foo\nbar
+# TYPE p_foo_bar gauge
+p_foo_bar{q="\"x\""} 0
+`
+	if got := b.String(); want != got {
+		t.Errorf("\n%s!=\n%s\n", want, got)
+	}
+}
@@ -0,0 +1,186 @@
+//go:build go1.18
+// +build go1.18
+
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// InstrumentedChan is a buffered channel of values of type T which records
+// in a registry, under the name it's constructed with followed by
+//
+//	.Depth     a GaugeCounter of the values sent, or being sent, and not yet received
+//	.Enqueued  a Meter of the values sent
+//	.Dequeued  a Meter of the values received
+//	.WaitTime  a Timer of the time values spent in the channel
+//
+// so that a queue's backlog and throughput can be watched.
+type InstrumentedChan[T any] struct {
+	ch       chan queued[T]
+	depth    GaugeCounter
+	enqueued Meter
+	dequeued Meter
+	wait     Timer
+}
+
+type queued[T any] struct {
+	v T
+	t time.Time
+}
+
+// NewInstrumentedChan constructs a new InstrumentedChan with a buffer of
+// the given size and registers its metrics in r.
+func NewInstrumentedChan[T any](name string, r Registry, size int) *InstrumentedChan[T] {
+	return &InstrumentedChan[T]{
+		ch:       make(chan queued[T], size),
+		depth:    GetOrRegisterGaugeCounter(name+".Depth", r),
+		enqueued: GetOrRegisterMeter(name+".Enqueued", r),
+		dequeued: GetOrRegisterMeter(name+".Dequeued", r),
+		wait:     GetOrRegisterTimer(name+".WaitTime", r),
+	}
+}
+
+// Send sends v, blocking while the buffer is full.  Like sending on a
+// channel, it panics if the channel is closed.
+func (c *InstrumentedChan[T]) Send(v T) {
+	c.depth.Inc(1)
+	c.ch <- queued[T]{v, time.Now()}
+	c.enqueued.Mark(1)
+}
+
+// SendContext is like Send but gives up when ctx is done, returning its
+// error.
+func (c *InstrumentedChan[T]) SendContext(ctx context.Context, v T) error {
+	c.depth.Inc(1)
+	select {
+	case c.ch <- queued[T]{v, time.Now()}:
+		c.enqueued.Mark(1)
+		return nil
+	case <-ctx.Done():
+		c.depth.Dec(1)
+		return ctx.Err()
+	}
+}
+
+// TrySend sends v unless the buffer is full, returning whether it did.
+func (c *InstrumentedChan[T]) TrySend(v T) bool {
+	c.depth.Inc(1)
+	select {
+	case c.ch <- queued[T]{v, time.Now()}:
+		c.enqueued.Mark(1)
+		return true
+	default:
+		c.depth.Dec(1)
+		return false
+	}
+}
+
+// Receive receives a value, blocking while there's none, and returns false
+// once the channel is closed and drained.
+func (c *InstrumentedChan[T]) Receive() (T, bool) {
+	q, ok := <-c.ch
+	return c.received(q, ok)
+}
+
+// ReceiveContext is like Receive but gives up when ctx is done, returning
+// its error.
+func (c *InstrumentedChan[T]) ReceiveContext(ctx context.Context) (T, bool, error) {
+	select {
+	case q, ok := <-c.ch:
+		v, ok := c.received(q, ok)
+		return v, ok, nil
+	case <-ctx.Done():
+		var zero T
+		return zero, false, ctx.Err()
+	}
+}
+
+func (c *InstrumentedChan[T]) received(q queued[T], ok bool) (T, bool) {
+	if !ok {
+		return q.v, false
+	}
+	c.depth.Dec(1)
+	c.dequeued.Mark(1)
+	c.wait.UpdateSince(q.t)
+	return q.v, true
+}
+
+// Len returns the number of values in the buffer.
+func (c *InstrumentedChan[T]) Len() int {
+	return len(c.ch)
+}
+
+// Close closes the channel, after which the values left in it can still be
+// received.
+func (c *InstrumentedChan[T]) Close() {
+	close(c.ch)
+}
+
+// WorkerPool runs tasks on a fixed number of goroutines from an
+// InstrumentedChan, recording its metrics under the pool's name as well as
+//
+//	.TaskTime  a Timer of the time tasks took to run
+//	.Busy      a GaugeCounter of the workers running tasks
+//
+// so that the queue time, in .WaitTime, and task latency can be told apart.
+type WorkerPool struct {
+	tasks    *InstrumentedChan[func()]
+	taskTime Timer
+	busy     GaugeCounter
+	wg       sync.WaitGroup
+}
+
+// NewWorkerPool constructs a new WorkerPool, starting the given number of
+// workers and queueing up to queueSize tasks, and registers its metrics in
+// r.
+func NewWorkerPool(name string, r Registry, workers, queueSize int) *WorkerPool {
+	p := &WorkerPool{
+		tasks:    NewInstrumentedChan[func()](name, r, queueSize),
+		taskTime: GetOrRegisterTimer(name+".TaskTime", r),
+		busy:     GetOrRegisterGaugeCounter(name+".Busy", r),
+	}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *WorkerPool) work() {
+	defer p.wg.Done()
+	for {
+		f, ok := p.tasks.Receive()
+		if !ok {
+			return
+		}
+		p.busy.Inc(1)
+		p.taskTime.Time(f)
+		p.busy.Dec(1)
+	}
+}
+
+// Submit queues f to be run, blocking while the queue is full.
+func (p *WorkerPool) Submit(f func()) {
+	p.tasks.Send(f)
+}
+
+// SubmitContext is like Submit but gives up when ctx is done, returning its
+// error.
+func (p *WorkerPool) SubmitContext(ctx context.Context, f func()) error {
+	return p.tasks.SendContext(ctx, f)
+}
+
+// TrySubmit queues f to be run unless the queue is full, returning whether
+// it did.
+func (p *WorkerPool) TrySubmit(f func()) bool {
+	return p.tasks.TrySend(f)
+}
+
+// Close stops accepting tasks and returns once the tasks queued have run.
+func (p *WorkerPool) Close() {
+	p.tasks.Close()
+	p.wg.Wait()
+}
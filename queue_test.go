@@ -0,0 +1,75 @@
+//go:build go1.18
+// +build go1.18
+
+package metrics
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+)
+
+func TestInstrumentedChan(t *testing.T) {
+	r := NewRegistry()
+	c := NewInstrumentedChan[string]("queue", r, 2)
+	c.Send("a")
+	if !c.TrySend("b") {
+		t.Fatal("TrySend: want true")
+	}
+	if c.TrySend("c") {
+		t.Fatal("TrySend: want false when full")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.SendContext(ctx, "c"); context.Canceled != err {
+		t.Fatalf("SendContext: %v != %v\n", context.Canceled, err)
+	}
+	if depth := r.Get("queue.Depth").(GaugeCounter).Count(); 2 != depth {
+		t.Errorf("queue.Depth: 2 != %v\n", depth)
+	}
+	if v, ok := c.Receive(); "a" != v || !ok {
+		t.Errorf("Receive: a true != %v %v\n", v, ok)
+	}
+	c.Close()
+	if v, ok, err := c.ReceiveContext(context.Background()); "b" != v || !ok || nil != err {
+		t.Errorf("ReceiveContext: b true <nil> != %v %v %v\n", v, ok, err)
+	}
+	if _, ok := c.Receive(); ok {
+		t.Error("Receive: want false once closed and drained")
+	}
+
+	if depth := r.Get("queue.Depth").(GaugeCounter).Count(); 0 != depth {
+		t.Errorf("queue.Depth: 0 != %v\n", depth)
+	}
+	if count := r.Get("queue.Enqueued").(Meter).Count(); 2 != count {
+		t.Errorf("queue.Enqueued: 2 != %v\n", count)
+	}
+	if count := r.Get("queue.Dequeued").(Meter).Count(); 2 != count {
+		t.Errorf("queue.Dequeued: 2 != %v\n", count)
+	}
+	if count := r.Get("queue.WaitTime").(Timer).Count(); 2 != count {
+		t.Errorf("queue.WaitTime: 2 != %v\n", count)
+	}
+}
+
+func TestWorkerPool(t *testing.T) {
+	r := NewRegistry()
+	p := NewWorkerPool("pool", r, 3, 10)
+	var ran int64
+	for i := 0; i < 20; i++ {
+		p.Submit(func() { atomic.AddInt64(&ran, 1) })
+	}
+	p.Close()
+	if 20 != atomic.LoadInt64(&ran) {
+		t.Fatalf("ran: 20 != %v\n", ran)
+	}
+	if count := r.Get("pool.TaskTime").(Timer).Count(); 20 != count {
+		t.Errorf("pool.TaskTime: 20 != %v\n", count)
+	}
+	if count := r.Get("pool.WaitTime").(Timer).Count(); 20 != count {
+		t.Errorf("pool.WaitTime: 20 != %v\n", count)
+	}
+	if busy := r.Get("pool.Busy").(GaugeCounter).Count(); 0 != busy {
+		t.Errorf("pool.Busy: 0 != %v\n", busy)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build race
+// +build race
+
+package metrics
+
+// raceEnabled is whether the tests were built with the race detector, under
+// which allocation counts aren't what they'd otherwise be.
+const raceEnabled = true
@@ -0,0 +1,132 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+// RateGauges report the per-second rate at which a Counter or GaugeCounter
+// changed since they were constructed or last cleared, so that backends
+// which can't compute derivatives get rates without a Meter's EWMA lag.
+// Reading one doesn't change it, so several reporters can share it; one
+// reading through a NewDeltaRegistry, eg with Delta temporality, clears it
+// as it reads it and so reports the rate over each flush interval.
+type RateGauge interface {
+	Clear() RateGauge // atomically clears and returns a snapshot
+	Rate() float64
+	Snapshot() RateGauge
+}
+
+// GetOrRegisterRateGauge returns an existing RateGauge or constructs and
+// registers a new StandardRateGauge of the given counter.
+func GetOrRegisterRateGauge(name string, r Registry, c interface{ Count() int64 }) RateGauge {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	g, ok := r.GetOrRegister(name, func() RateGauge { return NewRateGauge(c) }).(RateGauge)
+	if !ok {
+		return registrationConflict(DuplicateMetric(name), NewRateGauge(c)).(RateGauge)
+	}
+	return g
+}
+
+// NewRateGauge constructs a new StandardRateGauge of the given Counter or
+// GaugeCounter.
+func NewRateGauge(c interface{ Count() int64 }) RateGauge {
+	return NewRateGaugeWithClock(c, DefaultClock)
+}
+
+// NewRateGaugeWithClock constructs a new StandardRateGauge which reads the
+// time from the given Clock.
+func NewRateGaugeWithClock(c interface{ Count() int64 }, clock Clock) RateGauge {
+	if UseNilMetrics {
+		return NilRateGauge{}
+	}
+	return &StandardRateGauge{
+		clock:   clock,
+		counter: c,
+		count:   c.Count(),
+		time:    clock.Now(),
+	}
+}
+
+// NewRegisteredRateGauge constructs and registers a new StandardRateGauge.
+func NewRegisteredRateGauge(name string, r Registry, c interface{ Count() int64 }) RateGauge {
+	g := NewRateGauge(c)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, g)
+	return g
+}
+
+// RateGaugeSnapshot is a read-only copy of another RateGauge.
+type RateGaugeSnapshot float64
+
+// Clear panics, or with PanicOnSnapshotMutation off returns the snapshot.
+func (g RateGaugeSnapshot) Clear() RateGauge {
+	snapshotMisuse("Clear called on a RateGaugeSnapshot")
+	return g
+}
+
+// Rate returns the rate at the time the snapshot was taken.
+func (g RateGaugeSnapshot) Rate() float64 { return float64(g) }
+
+// Snapshot returns the snapshot.
+func (g RateGaugeSnapshot) Snapshot() RateGauge { return g }
+
+// NilRateGauge is a no-op RateGauge.
+type NilRateGauge struct{}
+
+// Clear is a no-op.
+func (NilRateGauge) Clear() RateGauge { return NilRateGauge{} }
+
+// Rate is a no-op.
+func (NilRateGauge) Rate() float64 { return 0.0 }
+
+// Snapshot is a no-op.
+func (NilRateGauge) Snapshot() RateGauge { return NilRateGauge{} }
+
+// StandardRateGauge is the standard implementation of a RateGauge.  It
+// remembers the count and time at which it was constructed or last cleared
+// and measures each reading against them.
+type StandardRateGauge struct {
+	clock   Clock
+	count   int64
+	counter interface{ Count() int64 }
+	mutex   sync.Mutex
+	time    time.Time
+}
+
+// Clear starts measuring afresh from the counter's current count and
+// returns a snapshot of the rate beforehand.
+func (g *StandardRateGauge) Clear() RateGauge {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	count, now := g.counter.Count(), g.clock.Now()
+	s := RateGaugeSnapshot(g.rate(count, now))
+	g.count, g.time = count, now
+	return s
+}
+
+// Rate returns the counter's change per second since the gauge was
+// constructed or last cleared.  It's zero if no time has passed.
+func (g *StandardRateGauge) Rate() float64 {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.rate(g.counter.Count(), g.clock.Now())
+}
+
+// Snapshot returns a read-only copy of the rate.
+func (g *StandardRateGauge) Snapshot() RateGauge {
+	return RateGaugeSnapshot(g.Rate())
+}
+
+// rate must be called with the mutex held.
+func (g *StandardRateGauge) rate(count int64, now time.Time) float64 {
+	elapsed := now.Sub(g.time)
+	if elapsed <= 0 {
+		return 0.0
+	}
+	return float64(count-g.count) / elapsed.Seconds()
+}
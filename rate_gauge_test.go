@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateGauge(t *testing.T) {
+	c := NewCounter()
+	g := NewRateGauge(c)
+	c.Inc(47)
+	if rate := g.Snapshot().Rate(); 0 > rate {
+		t.Errorf("g.Snapshot().Rate(): 0 > %v\n", rate)
+	}
+	if rate := (NilRateGauge{}).Rate(); 0 != rate {
+		t.Errorf("NilRateGauge{}.Rate(): 0 != %v\n", rate)
+	}
+}
+
+func TestRateGaugeReadersShare(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := NewCounter()
+	g := NewRateGaugeWithClock(c, ClockFunc(func() time.Time { return now }))
+	c.Inc(10)
+	now = now.Add(2 * time.Second)
+	for i := 0; i < 2; i++ {
+		if rate := g.Snapshot().Rate(); 5 != rate {
+			t.Errorf("%v: g.Snapshot().Rate(): 5 != %v\n", i, rate)
+		}
+		if rate := g.Rate(); 5 != rate {
+			t.Errorf("%v: g.Rate(): 5 != %v\n", i, rate)
+		}
+	}
+	if rate := g.Clear().Rate(); 5 != rate {
+		t.Errorf("g.Clear().Rate(): 5 != %v\n", rate)
+	}
+	c.Inc(3)
+	now = now.Add(time.Second)
+	if rate := g.Rate(); 3 != rate {
+		t.Errorf("g.Rate() after Clear: 3 != %v\n", rate)
+	}
+}
+
+func TestRateGaugeDelta(t *testing.T) {
+	now := time.Unix(1000, 0)
+	r := NewRegistry()
+	c := NewCounter()
+	r.Register("rate", NewRateGaugeWithClock(c, ClockFunc(func() time.Time { return now })))
+	d := NewDeltaRegistry(r)
+	for i, inc := range []int64{10, 4} {
+		c.Inc(inc)
+		now = now.Add(2 * time.Second)
+		points := CollectPoints(d, 0)
+		if 1 != len(points) || float64(inc)/2 != points[0].Value {
+			t.Errorf("%v: %+v\n", i, points)
+		}
+	}
+}
+
+func TestGetOrRegisterRateGauge(t *testing.T) {
+	r := NewRegistry()
+	c := NewGaugeCounter()
+	NewRegisteredRateGauge("foo", r, c)
+	if _, ok := GetOrRegisterRateGauge("foo", r, c).(*StandardRateGauge); !ok {
+		t.Fatal(r.Get("foo"))
+	}
+	points := CollectPoints(r, 0)
+	if 1 != len(points) || PointGauge != points[0].Kind {
+		t.Fatalf("%+v\n", points)
+	}
+}
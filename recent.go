@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// RecentRecorder is implemented by Histograms and Timers which can keep the
+// last values recorded, raw, eg to debug tail latencies with the actual
+// values rather than a reservoir's selection of them.  Keeping them is off
+// until KeepRecent is called, so that it costs nothing otherwise.
+// StandardHistogram and StandardTimer implement it.
+type RecentRecorder interface {
+	// KeepRecent starts keeping the last size values recorded, forgetting
+	// any kept so far, or stops if size is zero or less.
+	KeepRecent(size int)
+
+	// Recent returns up to the last n values kept, oldest first.  Timers'
+	// are in nanoseconds.
+	Recent(n int) []int64
+}
+
+// recentValues is a ring buffer of the last values recorded.
+type recentValues struct {
+	mutex  sync.Mutex
+	values []int64
+	next   int
+	full   bool
+}
+
+func newRecentValues(size int) *recentValues {
+	return &recentValues{values: make([]int64, size)}
+}
+
+func (r *recentValues) update(v int64) {
+	r.mutex.Lock()
+	r.values[r.next] = v
+	r.next++
+	if r.next == len(r.values) {
+		r.next, r.full = 0, true
+	}
+	r.mutex.Unlock()
+}
+
+func (r *recentValues) recent(n int) []int64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	count := r.next
+	if r.full {
+		count = len(r.values)
+	}
+	if n > count {
+		n = count
+	}
+	if n <= 0 {
+		return nil
+	}
+	values := make([]int64, n)
+	start := r.next - n
+	if start < 0 {
+		start += len(r.values)
+	}
+	for i := range values {
+		values[i] = r.values[(start+i)%len(r.values)]
+	}
+	return values
+}
+
+// recentRing holds the recentValues of a metric, if any, for RecentRecorders
+// to load on every update without taking a lock.
+type recentRing struct {
+	v atomic.Value // *recentValues
+}
+
+func (r *recentRing) keep(size int) {
+	if size <= 0 {
+		r.v.Store((*recentValues)(nil))
+		return
+	}
+	r.v.Store(newRecentValues(size))
+}
+
+func (r *recentRing) update(v int64) {
+	if rv, _ := r.v.Load().(*recentValues); nil != rv {
+		rv.update(v)
+	}
+}
+
+func (r *recentRing) recent(n int) []int64 {
+	if rv, _ := r.v.Load().(*recentValues); nil != rv {
+		return rv.recent(n)
+	}
+	return nil
+}
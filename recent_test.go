@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHistogramRecent(t *testing.T) {
+	h := NewHistogram(NewUniformSample(100)).(*StandardHistogram)
+	h.Update(1)
+	if r := h.Recent(10); nil != r {
+		t.Errorf("h.Recent(10) before KeepRecent: nil != %v\n", r)
+	}
+
+	h.KeepRecent(3)
+	h.Update(2)
+	h.Update(3)
+	if want, r := []int64{2, 3}, h.Recent(10); !reflect.DeepEqual(want, r) {
+		t.Errorf("h.Recent(10): %v != %v\n", want, r)
+	}
+	h.Update(4)
+	h.Update(5)
+	if want, r := []int64{3, 4, 5}, h.Recent(3); !reflect.DeepEqual(want, r) {
+		t.Errorf("h.Recent(3): %v != %v\n", want, r)
+	}
+	if want, r := []int64{4, 5}, h.Recent(2); !reflect.DeepEqual(want, r) {
+		t.Errorf("h.Recent(2): %v != %v\n", want, r)
+	}
+	h.Clear()
+	if want, r := []int64{5}, h.Recent(1); !reflect.DeepEqual(want, r) {
+		t.Errorf("h.Recent(1) after Clear: %v != %v\n", want, r)
+	}
+
+	h.KeepRecent(0)
+	h.Update(6)
+	if r := h.Recent(10); nil != r {
+		t.Errorf("h.Recent(10) after KeepRecent(0): nil != %v\n", r)
+	}
+}
+
+func TestTimerRecent(t *testing.T) {
+	tm := NewTimer()
+	r, ok := tm.(RecentRecorder)
+	if !ok {
+		t.Fatalf("%T isn't a RecentRecorder\n", tm)
+	}
+	r.KeepRecent(10)
+	tm.Update(time.Millisecond)
+	tm.Update(2 * time.Millisecond)
+	if want, v := []int64{int64(time.Millisecond), int64(2 * time.Millisecond)}, r.Recent(10); !reflect.DeepEqual(want, v) {
+		t.Errorf("r.Recent(10): %v != %v\n", want, v)
+	}
+}
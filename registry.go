@@ -2,9 +2,13 @@ package metrics
 
 import (
 	"fmt"
+	"path"
 	"reflect"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 // DuplicateMetric is the error returned by Registry.Register when a metric
@@ -16,12 +20,47 @@ func (err DuplicateMetric) Error() string {
 	return fmt.Sprintf("duplicate metric: %s", string(err))
 }
 
+// RegistryFrozen is the error returned by StandardRegistry.Register, and the
+// value its GetOrRegister, Unregister and UnregisterAll panic with, when a
+// metric is registered or unregistered after the registry has been frozen.
+type RegistryFrozen string
+
+func (err RegistryFrozen) Error() string {
+	return fmt.Sprintf("registry frozen: %s", string(err))
+}
+
 // A Registry holds references to a set of metrics by name and can iterate
 // over them, calling callback functions provided by the user.
 //
 // This is an interface so as to encourage other structs to implement
-// the Registry API as appropriate.
+// the Registry API as appropriate.  Registries implementing only
+// BasicRegistry can be adapted with AdaptRegistry.
 type Registry interface {
+	BasicRegistry
+
+	// Call the given function for each registered metric, stopping at and
+	// returning the first error it returns.
+	EachErr(func(string, interface{}) error) error
+
+	// Call the given function for each registered metric in order of name,
+	// stopping at and returning the first error it returns.
+	EachSortedErr(func(string, interface{}) error) error
+
+	// Call the given function for each registered metric in order of name.
+	EachSorted(func(string, interface{}))
+
+	// Call the given function for each registered metric whose name matches
+	// the given pattern, in the syntax of path.Match, eg "ld.stream.*".
+	// Returns path.ErrBadPattern if the pattern is malformed.
+	EachMatching(string, func(string, interface{})) error
+
+	// Call the given Visitor for each registered metric.
+	VisitSnapshots(Visitor)
+}
+
+// BasicRegistry is the part of the Registry interface which doesn't depend
+// on the others to implement.
+type BasicRegistry interface {
 
 	// Call the given function for each registered metric.
 	Each(func(string, interface{}))
@@ -48,10 +87,15 @@ type Registry interface {
 }
 
 // The standard implementation of a Registry is a mutex-protected map
-// of names to metrics.
+// of names to metrics.  Lookups, including GetOrRegister of a metric which is
+// already registered, take a read lock, so that they don't contend with each
+// other or with reporters iterating over the registry.  Once all its metrics
+// are registered, it can be frozen so that they're read without any lock.
 type StandardRegistry struct {
-	metrics map[string]interface{}
-	mutex   sync.Mutex
+	metadata map[string]Metadata
+	metrics  map[string]interface{}
+	mutex    sync.RWMutex
+	frozen   int32 // Set by Freeze, after which metrics is read without the lock
 }
 
 // Create a new registry.
@@ -66,10 +110,72 @@ func (r *StandardRegistry) Each(f func(string, interface{})) {
 	}
 }
 
-// Get the metric by the given name or nil if none is registered.
-func (r *StandardRegistry) Get(name string) interface{} {
+// Call the given function for each registered metric, stopping at and
+// returning the first error it returns.
+func (r *StandardRegistry) EachErr(f func(string, interface{}) error) error {
+	for name, i := range r.registered() {
+		if err := f(name, i); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// Call the given function for each registered metric in order of name,
+// stopping at and returning the first error it returns.
+func (r *StandardRegistry) EachSortedErr(f func(string, interface{}) error) error {
+	return eachSortedErr(r.registered(), f)
+}
+
+// Call the given function for each registered metric in order of name.
+func (r *StandardRegistry) EachSorted(f func(string, interface{})) {
+	eachSortedErr(r.registered(), ignoreErr(f))
+}
+
+// Call the given function for each registered metric whose name matches the
+// given pattern, in the syntax of path.Match.  Returns path.ErrBadPattern if
+// the pattern is malformed.
+func (r *StandardRegistry) EachMatching(pattern string, f func(string, interface{})) error {
+	return eachMatching(r, pattern, f)
+}
+
+// Call the given function for each registered metric with its Metadata,
+// which is zero if none has been set.
+func (r *StandardRegistry) EachWithMetadata(f func(string, interface{}, Metadata)) {
+	r.mutex.RLock()
+	metadata := make(map[string]Metadata, len(r.metadata))
+	for name, md := range r.metadata {
+		metadata[name] = md
+	}
+	r.mutex.RUnlock()
+	for name, i := range r.registered() {
+		f(name, i, metadata[name])
+	}
+}
+
+// Freeze fixes the set of registered metrics, for programs which register
+// all their metrics at startup, so that looking them up and iterating over
+// them no longer takes any lock.  Afterwards Register returns a
+// RegistryFrozen error, and GetOrRegister of a metric which isn't registered,
+// Unregister and UnregisterAll panic with one.  Metadata may still be set.
+func (r *StandardRegistry) Freeze() {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
+	atomic.StoreInt32(&r.frozen, 1)
+}
+
+// Frozen returns whether Freeze has been called.
+func (r *StandardRegistry) Frozen() bool {
+	return 1 == atomic.LoadInt32(&r.frozen)
+}
+
+// Get the metric by the given name or nil if none is registered.
+func (r *StandardRegistry) Get(name string) interface{} {
+	if r.Frozen() {
+		return r.metrics[name]
+	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
 	return r.metrics[name]
 }
 
@@ -78,18 +184,30 @@ func (r *StandardRegistry) Get(name string) interface{} {
 // The interface can be the metric to register if not found in registry,
 // or a function returning the metric for lazy instantiation.
 func (r *StandardRegistry) GetOrRegister(name string, i interface{}) interface{} {
+	if metric := r.Get(name); nil != metric {
+		return metric
+	}
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 	if metric, ok := r.metrics[name]; ok {
 		return metric
 	}
-	if v := reflect.ValueOf(i); v.Kind() == reflect.Func {
-		i = v.Call(nil)[0].Interface()
+	if r.Frozen() {
+		panic(RegistryFrozen(name))
 	}
+	i = instantiate(i)
 	r.register(name, i)
 	return i
 }
 
+// Get the Metadata set for the metric by the given name.
+func (r *StandardRegistry) Metadata(name string) (Metadata, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	md, ok := r.metadata[name]
+	return md, ok
+}
+
 // Register the given metric under the given name.  Returns a DuplicateMetric
 // if a metric by the given name is already registered.
 func (r *StandardRegistry) Register(name string, i interface{}) error {
@@ -100,8 +218,10 @@ func (r *StandardRegistry) Register(name string, i interface{}) error {
 
 // Run all registered healthchecks.
 func (r *StandardRegistry) RunHealthchecks() {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+	if !r.Frozen() {
+		r.mutex.RLock()
+		defer r.mutex.RUnlock()
+	}
 	for _, i := range r.metrics {
 		if h, ok := i.(Healthcheck); ok {
 			h.Check()
@@ -109,36 +229,140 @@ func (r *StandardRegistry) RunHealthchecks() {
 	}
 }
 
+// Set the Metadata for the metric by the given name, which is forgotten when
+// the metric is unregistered.
+func (r *StandardRegistry) SetMetadata(name string, md Metadata) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if nil == r.metadata {
+		r.metadata = make(map[string]Metadata)
+	}
+	r.metadata[name] = md
+}
+
 // Unregister the metric with the given name.
 func (r *StandardRegistry) Unregister(name string) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
+	if r.Frozen() {
+		panic(RegistryFrozen(name))
+	}
 	delete(r.metrics, name)
+	delete(r.metadata, name)
 }
 
 // Unregister all metrics.  (Mostly for testing.)
 func (r *StandardRegistry) UnregisterAll() {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
+	if r.Frozen() {
+		panic(RegistryFrozen("UnregisterAll"))
+	}
 	for name, _ := range r.metrics {
 		delete(r.metrics, name)
 	}
+	r.metadata = nil
 }
 
 func (r *StandardRegistry) register(name string, i interface{}) error {
 	if _, ok := r.metrics[name]; ok {
 		return DuplicateMetric(name)
 	}
-	switch i.(type) {
-	case Counter, GaugeCounter, Gauge, GaugeFloat64, Healthcheck, Histogram, HistogramFloat64, Meter, Timer:
+	if r.Frozen() {
+		return RegistryFrozen(name)
+	}
+	if isMetric(i) {
 		r.metrics[name] = i
 	}
 	return nil
 }
 
+// instantiate returns the metric GetOrRegister was given, calling it first if
+// it's a function returning the metric.  The constructors of this package's
+// metric types and closures returning them, as GetOrRegisterCounter and the
+// like pass, are called without reflection.
+func instantiate(i interface{}) interface{} {
+	switch f := i.(type) {
+	case func() Counter:
+		return f()
+	case func() Gauge:
+		return f()
+	case func() GaugeFloat64:
+		return f()
+	case func() Histogram:
+		return f()
+	case func() HistogramFloat64:
+		return f()
+	case func() Meter:
+		return f()
+	case func() Timer:
+		return f()
+	case func() interface{}:
+		return f()
+	}
+	if v := reflect.ValueOf(i); v.Kind() == reflect.Func {
+		return v.Call(nil)[0].Interface()
+	}
+	return i
+}
+
+// isMetric returns whether i is of one of the types a StandardRegistry will
+// hold.  Anything else is silently ignored by Register.
+func isMetric(i interface{}) bool {
+	switch i.(type) {
+	case Counter, Uint64Counter, BigCounter, GaugeCounter, Gauge, GaugeFloat64, Healthcheck, Histogram, HistogramFloat64, Meter, MovingAverage, RateGauge, Timer, TopK, Cardinality, BoolGauge, EnumGauge, InfoMetric, SummaryFloat64:
+		return true
+	}
+	return false
+}
+
+// eachSortedErr calls f for each of the given metrics in order of name,
+// stopping at and returning the first error it returns.
+func eachSortedErr(metrics map[string]interface{}, f func(string, interface{}) error) error {
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := f(name, metrics[name]); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// eachMatching calls f for each metric in r whose name matches the given
+// pattern, in the syntax of path.Match.
+func eachMatching(r BasicRegistry, pattern string, f func(string, interface{})) error {
+	if _, err := path.Match(pattern, ""); nil != err {
+		return err
+	}
+	r.Each(func(name string, i interface{}) {
+		if ok, _ := path.Match(pattern, name); ok {
+			f(name, i)
+		}
+	})
+	return nil
+}
+
+// ignoreErr adapts f for the methods which take a function returning an
+// error.
+func ignoreErr(f func(string, interface{})) func(string, interface{}) error {
+	return func(name string, i interface{}) error {
+		f(name, i)
+		return nil
+	}
+}
+
+// registered returns a copy of the registered metrics, or once the registry
+// is frozen the metrics themselves, which the caller mustn't modify.
 func (r *StandardRegistry) registered() map[string]interface{} {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+	if r.Frozen() {
+		return r.metrics
+	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
 	metrics := make(map[string]interface{}, len(r.metrics))
 	for name, i := range r.metrics {
 		metrics[name] = i
@@ -181,14 +405,74 @@ func (r *PrefixedRegistry) Each(fn func(string, interface{})) {
 	baseRegistry.Each(wrappedFn(prefix))
 }
 
+// Call the given function for each registered metric, stopping at and
+// returning the first error it returns.
+func (r *PrefixedRegistry) EachErr(fn func(string, interface{}) error) error {
+	baseRegistry, prefix := findPrefix(r, "")
+	return baseRegistry.EachErr(prefixedFn(prefix, fn))
+}
+
+// Call the given function for each registered metric in order of name,
+// stopping at and returning the first error it returns.
+func (r *PrefixedRegistry) EachSortedErr(fn func(string, interface{}) error) error {
+	baseRegistry, prefix := findPrefix(r, "")
+	return baseRegistry.EachSortedErr(prefixedFn(prefix, fn))
+}
+
+// Call the given function for each registered metric in order of name.
+func (r *PrefixedRegistry) EachSorted(fn func(string, interface{})) {
+	r.EachSortedErr(ignoreErr(fn))
+}
+
+// Call the given function for each registered metric whose name matches the
+// given pattern, in the syntax of path.Match.  Returns path.ErrBadPattern if
+// the pattern is malformed.
+func (r *PrefixedRegistry) EachMatching(pattern string, fn func(string, interface{})) error {
+	return eachMatching(r, pattern, fn)
+}
+
+// Call the given function for each registered metric with its Metadata,
+// which is zero if none has been set or the underlying registry is not a
+// MetadataRegistry.
+func (r *PrefixedRegistry) EachWithMetadata(fn func(string, interface{}, Metadata)) {
+	baseRegistry, prefix := findPrefix(r, "")
+	EachWithMetadata(baseRegistry, func(name string, i interface{}, md Metadata) {
+		if strings.HasPrefix(name, prefix) {
+			fn(name, i, md)
+		}
+	})
+}
+
+// Get the Metadata set for the metric by the given name.  The name will be
+// prefixed.
+func (r *PrefixedRegistry) Metadata(name string) (Metadata, bool) {
+	return GetMetadata(r.prefix+name, r.underlying)
+}
+
+// Set the Metadata for the metric by the given name, if the underlying
+// registry is a MetadataRegistry.  The name will be prefixed.
+func (r *PrefixedRegistry) SetMetadata(name string, md Metadata) {
+	if mr, ok := r.underlying.(MetadataRegistry); ok {
+		mr.SetMetadata(r.prefix+name, md)
+	}
+}
+
+// prefixedFn wraps fn so that it's only called for names with the given
+// prefix.
+func prefixedFn(prefix string, fn func(string, interface{}) error) func(string, interface{}) error {
+	return func(name string, iface interface{}) error {
+		if strings.HasPrefix(name, prefix) {
+			return fn(name, iface)
+		}
+		return nil
+	}
+}
+
 func findPrefix(registry Registry, prefix string) (Registry, string) {
-	switch r := registry.(type) {
-	case *PrefixedRegistry:
+	if r, ok := registry.(*PrefixedRegistry); ok {
 		return findPrefix(r.underlying, r.prefix+prefix)
-	case *StandardRegistry:
-		return r, prefix
 	}
-	return nil, ""
+	return registry, prefix
 }
 
 // Get the metric by the given name or nil if none is registered.
@@ -227,6 +511,67 @@ func (r *PrefixedRegistry) UnregisterAll() {
 	r.underlying.UnregisterAll()
 }
 
+// Call the given Visitor for each registered metric.
+func (r *PrefixedRegistry) VisitSnapshots(v Visitor) {
+	visitEach(r, v)
+}
+
+// AdaptRegistry returns a Registry which implements the methods beyond
+// BasicRegistry in terms of the given registry's Each.  Since Each can't be
+// stopped, the rest of the iteration is skipped over after an error rather
+// than cut short.  Registries which already implement Registry are returned
+// as they are.
+func AdaptRegistry(r BasicRegistry) Registry {
+	if registry, ok := r.(Registry); ok {
+		return registry
+	}
+	return adaptedRegistry{r}
+}
+
+type adaptedRegistry struct {
+	BasicRegistry
+}
+
+func (r adaptedRegistry) EachErr(f func(string, interface{}) error) error {
+	var err error
+	r.Each(func(name string, i interface{}) {
+		if nil == err {
+			err = f(name, i)
+		}
+	})
+	return err
+}
+
+func (r adaptedRegistry) EachSortedErr(f func(string, interface{}) error) error {
+	metrics := make(map[string]interface{})
+	r.Each(func(name string, i interface{}) {
+		metrics[name] = i
+	})
+	return eachSortedErr(metrics, f)
+}
+
+func (r adaptedRegistry) EachSorted(f func(string, interface{})) {
+	r.EachSortedErr(ignoreErr(f))
+}
+
+func (r adaptedRegistry) EachMatching(pattern string, f func(string, interface{})) error {
+	return eachMatching(r, pattern, f)
+}
+
+func (r adaptedRegistry) VisitSnapshots(v Visitor) {
+	visitEach(r, v)
+}
+
+// EachMatchingRegexp calls the given function for each metric registered in
+// r whose name matches the given regular expression.
+func EachMatchingRegexp(r Registry, re *regexp.Regexp, f func(string, interface{})) {
+	r.Each(func(name string, i interface{}) {
+		if re.MatchString(name) {
+			f(name, i)
+		}
+	})
+}
+
 var DefaultRegistry Registry = NewRegistry()
 
 // Call the given function for each registered metric.
@@ -234,6 +579,30 @@ func Each(f func(string, interface{})) {
 	DefaultRegistry.Each(f)
 }
 
+// Call the given function for each registered metric, stopping at and
+// returning the first error it returns.
+func EachErr(f func(string, interface{}) error) error {
+	return DefaultRegistry.EachErr(f)
+}
+
+// Call the given function for each registered metric in order of name,
+// stopping at and returning the first error it returns.
+func EachSortedErr(f func(string, interface{}) error) error {
+	return DefaultRegistry.EachSortedErr(f)
+}
+
+// Call the given function for each registered metric in order of name.
+func EachSorted(f func(string, interface{})) {
+	DefaultRegistry.EachSorted(f)
+}
+
+// Call the given function for each registered metric whose name matches the
+// given pattern, in the syntax of path.Match.  Returns path.ErrBadPattern if
+// the pattern is malformed.
+func EachMatching(pattern string, f func(string, interface{})) error {
+	return DefaultRegistry.EachMatching(pattern, f)
+}
+
 // Get the metric by the given name or nil if none is registered.
 func Get(name string) interface{} {
 	return DefaultRegistry.Get(name)
@@ -259,6 +628,73 @@ func MustRegister(name string, i interface{}) {
 	}
 }
 
+// onRegistrationConflict holds the func(error) SetOnRegistrationConflict
+// was last called with, if any.
+var onRegistrationConflict atomic.Value
+
+// SetOnRegistrationConflict sets a function to be called instead of
+// panicking when GetOrRegisterCounter and the other typed GetOrRegister
+// functions find a metric of another type registered under the name they're
+// given.  They then return a new metric which is not registered anywhere.
+// Setting nil restores the panic.  It's safe to call while metrics are being
+// registered.
+func SetOnRegistrationConflict(f func(error)) {
+	onRegistrationConflict.Store(f)
+}
+
+// registrationConflict handles err, a conflict found by a typed
+// GetOrRegister function, returning i for it to use instead.
+func registrationConflict(err error, i interface{}) interface{} {
+	f, _ := onRegistrationConflict.Load().(func(error))
+	if nil == f {
+		panic(err)
+	}
+	f(err)
+	return i
+}
+
+// TryRegister registers the given metric under the given name in r, or the
+// DefaultRegistry if r is nil.  Unlike Register, it returns an error rather
+// than ignoring a value which is not a metric, as well as returning a
+// DuplicateMetric if the name is taken.
+func TryRegister(name string, r Registry, i interface{}) error {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	if !isMetric(i) {
+		return fmt.Errorf("%s: %T is not a metric", name, i)
+	}
+	return r.Register(name, i)
+}
+
+// TryGetOrRegister is like the GetOrRegister method of r, or the
+// DefaultRegistry if r is nil, but returns a DuplicateMetric if the metric
+// registered under the given name is not of the type given, which is the
+// return type if i is a function.  It returns an error for a value which is
+// not a metric, as TryRegister does.
+func TryGetOrRegister(name string, r Registry, i interface{}) (interface{}, error) {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	want := reflect.TypeOf(i)
+	if nil == want {
+		return nil, fmt.Errorf("%s: nil is not a metric", name)
+	}
+	if reflect.Func == want.Kind() {
+		want = want.Out(0)
+	}
+	m := r.GetOrRegister(name, i)
+	if !isMetric(m) {
+		return nil, fmt.Errorf("%s: %T is not a metric", name, m)
+	}
+	got := reflect.TypeOf(m)
+	if reflect.Interface == want.Kind() && !got.Implements(want) ||
+		reflect.Interface != want.Kind() && got != want {
+		return nil, DuplicateMetric(name)
+	}
+	return m, nil
+}
+
 // Run all registered healthchecks.
 func RunHealthchecks() {
 	DefaultRegistry.RunHealthchecks()
@@ -0,0 +1,231 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RegistryManagerConfig provides a container with configuration parameters
+// for NewRegistryManager.
+type RegistryManagerConfig struct {
+	Prefix func(id string) string // Prefix of a tenant's metrics in the combined registry, defaulting to the ID followed by "."
+	TagKey string                 // If set, a tenant's metrics are told apart by this tag, eg "env", appended to their names as MetricNamespace does, rather than by a prefix
+}
+
+// RegistryManager keeps a registry for each of a set of tenants, eg the
+// environments a LaunchDarkly Relay serves, so that each tenant's metrics
+// can be torn down together when it goes away, while one reporter pipeline
+// exports them all through the combined registry returned by Registry.
+// Each tenant's registry is a StandardRegistry of its own, so UnregisterAll
+// on it leaves the other tenants' metrics alone.
+type RegistryManager struct {
+	config  RegistryManagerConfig
+	mutex   sync.Mutex
+	tenants map[string]Registry
+}
+
+// NewRegistryManager constructs a new RegistryManager with no tenants.
+func NewRegistryManager(c RegistryManagerConfig) *RegistryManager {
+	if nil == c.Prefix {
+		c.Prefix = func(id string) string { return id + "." }
+	}
+	return &RegistryManager{config: c, tenants: make(map[string]Registry)}
+}
+
+// Tenant returns the registry of the tenant with the given ID, creating it
+// if there's none.
+func (m *RegistryManager) Tenant(id string) Registry {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	r, ok := m.tenants[id]
+	if !ok {
+		r = NewRegistry()
+		m.tenants[id] = r
+	}
+	return r
+}
+
+// Remove retires the tenant with the given ID, so that none of its metrics
+// are exported any more, and returns its registry, eg for a final flush, or
+// nil if there's no such tenant.
+func (m *RegistryManager) Remove(id string) Registry {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	r := m.tenants[id]
+	delete(m.tenants, id)
+	return r
+}
+
+// Tenants returns the IDs of the tenants, in order.
+func (m *RegistryManager) Tenants() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	ids := make([]string, 0, len(m.tenants))
+	for id := range m.tenants {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Registry returns a registry spanning every tenant's, now and as they come
+// and go, for reporters.  Each tenant's metrics appear there with the
+// tenant's prefix, or tag if the TagKey is set, added to their names.
+// Register, GetOrRegister, Unregister and the like act on the tenant a name
+// belongs to, if it exists; Register ignores metrics of other names and
+// GetOrRegister returns nil for them.  UnregisterAll unregisters every
+// tenant's metrics but keeps the tenants.
+func (m *RegistryManager) Registry() Registry {
+	return managedRegistry{m}
+}
+
+// name returns the name in the combined registry of the metric of the given
+// tenant named name in its own.
+func (m *RegistryManager) name(id, name string) string {
+	if "" != m.config.TagKey {
+		return name + ";" + m.config.TagKey + "=" + id
+	}
+	return m.config.Prefix(id) + name
+}
+
+// split returns the tenant's registry and the name in it of the metric
+// named name in the combined registry, or false if it belongs to no tenant.
+// Where the prefixes of several tenants match, the longest wins.
+func (m *RegistryManager) split(name string) (Registry, string, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if "" != m.config.TagKey {
+		i := strings.LastIndex(name, ";"+m.config.TagKey+"=")
+		if i < 0 {
+			return nil, "", false
+		}
+		r, ok := m.tenants[name[i+len(m.config.TagKey)+2:]]
+		return r, name[:i], ok
+	}
+	var tenant Registry
+	longest := -1
+	for id, r := range m.tenants {
+		if prefix := m.config.Prefix(id); strings.HasPrefix(name, prefix) && len(prefix) > longest {
+			tenant, longest = r, len(prefix)
+		}
+	}
+	if nil == tenant {
+		return nil, "", false
+	}
+	return tenant, name[longest:], true
+}
+
+// each calls f for each tenant's registry with its ID, in order of ID.
+func (m *RegistryManager) each(f func(string, Registry)) {
+	for _, id := range m.Tenants() {
+		m.mutex.Lock()
+		r, ok := m.tenants[id]
+		m.mutex.Unlock()
+		if ok {
+			f(id, r)
+		}
+	}
+}
+
+type managedRegistry struct {
+	m *RegistryManager
+}
+
+func (r managedRegistry) Each(f func(string, interface{})) {
+	r.EachWithMetadata(func(name string, i interface{}, _ Metadata) {
+		f(name, i)
+	})
+}
+
+func (r managedRegistry) EachErr(f func(string, interface{}) error) error {
+	var err error
+	r.Each(func(name string, i interface{}) {
+		if nil == err {
+			err = f(name, i)
+		}
+	})
+	return err
+}
+
+func (r managedRegistry) EachSortedErr(f func(string, interface{}) error) error {
+	metrics := make(map[string]interface{})
+	r.Each(func(name string, i interface{}) {
+		metrics[name] = i
+	})
+	return eachSortedErr(metrics, f)
+}
+
+func (r managedRegistry) EachSorted(f func(string, interface{})) {
+	r.EachSortedErr(ignoreErr(f))
+}
+
+func (r managedRegistry) EachMatching(pattern string, f func(string, interface{})) error {
+	return eachMatching(r, pattern, f)
+}
+
+func (r managedRegistry) EachWithMetadata(f func(string, interface{}, Metadata)) {
+	r.m.each(func(id string, tenant Registry) {
+		EachWithMetadata(tenant, func(name string, i interface{}, md Metadata) {
+			f(r.m.name(id, name), i, md)
+		})
+	})
+}
+
+func (r managedRegistry) Metadata(name string) (Metadata, bool) {
+	if tenant, name, ok := r.m.split(name); ok {
+		return GetMetadata(name, tenant)
+	}
+	return Metadata{}, false
+}
+
+func (r managedRegistry) SetMetadata(name string, md Metadata) {
+	if tenant, name, ok := r.m.split(name); ok {
+		if mr, ok := tenant.(MetadataRegistry); ok {
+			mr.SetMetadata(name, md)
+		}
+	}
+}
+
+func (r managedRegistry) Get(name string) interface{} {
+	if tenant, name, ok := r.m.split(name); ok {
+		return tenant.Get(name)
+	}
+	return nil
+}
+
+func (r managedRegistry) GetOrRegister(name string, i interface{}) interface{} {
+	if tenant, name, ok := r.m.split(name); ok {
+		return tenant.GetOrRegister(name, i)
+	}
+	return nil
+}
+
+func (r managedRegistry) Register(name string, i interface{}) error {
+	if tenant, name, ok := r.m.split(name); ok {
+		return tenant.Register(name, i)
+	}
+	return nil
+}
+
+func (r managedRegistry) RunHealthchecks() {
+	r.m.each(func(_ string, tenant Registry) {
+		tenant.RunHealthchecks()
+	})
+}
+
+func (r managedRegistry) Unregister(name string) {
+	if tenant, name, ok := r.m.split(name); ok {
+		tenant.Unregister(name)
+	}
+}
+
+func (r managedRegistry) UnregisterAll() {
+	r.m.each(func(_ string, tenant Registry) {
+		tenant.UnregisterAll()
+	})
+}
+
+func (r managedRegistry) VisitSnapshots(v Visitor) {
+	visitEach(r, v)
+}
@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRegistryManager(t *testing.T) {
+	m := NewRegistryManager(RegistryManagerConfig{})
+	NewRegisteredCounter("requests", m.Tenant("env1")).Inc(1)
+	NewRegisteredCounter("requests", m.Tenant("env2")).Inc(2)
+	r := m.Registry()
+
+	var names []string
+	r.EachSorted(func(name string, i interface{}) {
+		names = append(names, name)
+	})
+	if want := []string{"env1.requests", "env2.requests"}; !reflect.DeepEqual(want, names) {
+		t.Errorf("names: %v != %v\n", want, names)
+	}
+	if c, ok := r.Get("env2.requests").(Counter); !ok || 2 != c.Count() {
+		t.Fatal(r.Get("env2.requests"))
+	}
+	if err := r.Register("env1.errors", NewCounter()); nil != err {
+		t.Fatal(err)
+	}
+	if nil == m.Tenant("env1").Get("errors") {
+		t.Error("env1 errors not registered")
+	}
+	if nil != r.GetOrRegister("env3.errors", NewCounter) {
+		t.Error("r.GetOrRegister(\"env3.errors\") registered a metric")
+	}
+
+	removed := m.Remove("env1")
+	if c, ok := removed.Get("requests").(Counter); !ok || 1 != c.Count() {
+		t.Fatal(removed.Get("requests"))
+	}
+	if nil != m.Remove("env1") {
+		t.Error("m.Remove(\"env1\") again returned a registry")
+	}
+	names = nil
+	r.Each(func(name string, i interface{}) {
+		names = append(names, name)
+	})
+	if want := []string{"env2.requests"}; !reflect.DeepEqual(want, names) {
+		t.Errorf("names after Remove: %v != %v\n", want, names)
+	}
+	if want := []string{"env2"}; !reflect.DeepEqual(want, m.Tenants()) {
+		t.Errorf("m.Tenants(): %v != %v\n", want, m.Tenants())
+	}
+}
+
+func TestRegistryManagerTagKey(t *testing.T) {
+	m := NewRegistryManager(RegistryManagerConfig{TagKey: "env"})
+	NewRegisteredCounter("requests", m.Tenant("env1")).Inc(1)
+	r := m.Registry()
+	if c, ok := r.Get("requests;env=env1").(Counter); !ok || 1 != c.Count() {
+		t.Fatal(r.Get("requests;env=env1"))
+	}
+	r.Unregister("requests;env=env1")
+	if nil != m.Tenant("env1").Get("requests") {
+		t.Error("requests not unregistered from env1")
+	}
+
+	NewCounterVec("errors", m.Tenant("env1"), "code").WithLabelValues("500").Inc(1)
+	EachWithMetadata(NewMappedRegistry(r, Mapper{MapSplitTags()}), func(name string, i interface{}, md Metadata) {
+		if "errors" != name {
+			t.Errorf("name: errors != %v\n", name)
+		}
+		if want := map[string]string{"code": "500", "env": "env1"}; !reflect.DeepEqual(want, md.Tags) {
+			t.Errorf("md.Tags: %v != %v\n", want, md.Tags)
+		}
+	})
+}
+
+func TestRegistryManagerPrefix(t *testing.T) {
+	m := NewRegistryManager(RegistryManagerConfig{Prefix: func(id string) string { return "relay." + id + "." }})
+	NewRegisteredGauge("conns", m.Tenant("a"))
+	NewRegisteredGauge("conns", m.Tenant("a.b"))
+	if g := m.Registry().Get("relay.a.b.conns"); g != m.Tenant("a.b").Get("conns") {
+		t.Error("relay.a.b.conns isn't a.b's conns")
+	}
+}
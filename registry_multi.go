@@ -0,0 +1,221 @@
+package metrics
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MultiRegistryChild is one of the registries viewed through a
+// MultiRegistry, whose metrics appear there with the given prefix.
+type MultiRegistryChild struct {
+	Prefix   string
+	Registry Registry
+}
+
+// MultiRegistry is a Registry spanning several others, so that one reporter
+// can flush, say, the DefaultRegistry along with the registries of several
+// components without registering everything twice.
+//
+// Each and Get see every child's metrics with that child's prefix added to
+// their names.  Where two children have a metric by the same name the first
+// child's wins.  Register and GetOrRegister act on the child with the
+// longest prefix the name has, with the prefix removed, while Unregister,
+// UnregisterAll and RunHealthchecks act on every child.
+type MultiRegistry struct {
+	children []MultiRegistryChild
+}
+
+// NewMultiRegistry constructs a new MultiRegistry spanning the given
+// registries, none of them prefixed.
+func NewMultiRegistry(rs ...Registry) Registry {
+	children := make([]MultiRegistryChild, len(rs))
+	for i, r := range rs {
+		children[i] = MultiRegistryChild{Registry: r}
+	}
+	return &MultiRegistry{children: children}
+}
+
+// NewMultiRegistryWithPrefixes constructs a new MultiRegistry spanning the
+// given registries, each with its own prefix.
+func NewMultiRegistryWithPrefixes(children ...MultiRegistryChild) Registry {
+	return &MultiRegistry{children: append([]MultiRegistryChild(nil), children...)}
+}
+
+// Call the given function for each registered metric.
+func (r *MultiRegistry) Each(f func(string, interface{})) {
+	r.EachWithMetadata(func(name string, i interface{}, _ Metadata) {
+		f(name, i)
+	})
+}
+
+// Call the given function for each registered metric, stopping at and
+// returning the first error it returns.
+func (r *MultiRegistry) EachErr(f func(string, interface{}) error) error {
+	seen := make(map[string]bool)
+	for _, c := range r.children {
+		if err := c.Registry.EachErr(func(name string, i interface{}) error {
+			name = c.Prefix + name
+			if seen[name] {
+				return nil
+			}
+			seen[name] = true
+			return f(name, i)
+		}); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// Call the given function for each registered metric whose name matches the
+// given pattern, in the syntax of path.Match.  Returns path.ErrBadPattern if
+// the pattern is malformed.
+func (r *MultiRegistry) EachMatching(pattern string, f func(string, interface{})) error {
+	return eachMatching(r, pattern, f)
+}
+
+// Call the given function for each registered metric in order of name.
+func (r *MultiRegistry) EachSorted(f func(string, interface{})) {
+	r.EachSortedErr(ignoreErr(f))
+}
+
+// Call the given function for each registered metric in order of name,
+// stopping at and returning the first error it returns.
+func (r *MultiRegistry) EachSortedErr(f func(string, interface{}) error) error {
+	metrics := make(map[string]interface{})
+	r.Each(func(name string, i interface{}) {
+		metrics[name] = i
+	})
+	return eachSortedErr(metrics, f)
+}
+
+// Call the given function for each registered metric with its Metadata,
+// which is zero if none has been set or its registry is not a
+// MetadataRegistry.
+func (r *MultiRegistry) EachWithMetadata(f func(string, interface{}, Metadata)) {
+	seen := make(map[string]bool)
+	for _, c := range r.children {
+		EachWithMetadata(c.Registry, func(name string, i interface{}, md Metadata) {
+			name = c.Prefix + name
+			if seen[name] {
+				return
+			}
+			seen[name] = true
+			f(name, i, md)
+		})
+	}
+}
+
+// Get the metric by the given name or nil if none is registered.
+func (r *MultiRegistry) Get(name string) interface{} {
+	c, ok := r.owner(name)
+	if !ok {
+		return nil
+	}
+	return c.Registry.Get(name[len(c.Prefix):])
+}
+
+// Gets an existing metric or registers the given one in the child with the
+// longest prefix the name has.  The interface can be the metric to register if
+// not found in registry, or a function returning the metric for lazy
+// instantiation.
+func (r *MultiRegistry) GetOrRegister(name string, i interface{}) interface{} {
+	if metric := r.Get(name); nil != metric {
+		return metric
+	}
+	c, ok := r.child(name)
+	if !ok {
+		return nil
+	}
+	return c.Registry.GetOrRegister(name[len(c.Prefix):], i)
+}
+
+// Get the Metadata set for the metric by the given name.
+func (r *MultiRegistry) Metadata(name string) (Metadata, bool) {
+	c, ok := r.owner(name)
+	if !ok {
+		return Metadata{}, false
+	}
+	return GetMetadata(name[len(c.Prefix):], c.Registry)
+}
+
+// Register the given metric under the given name in the child with the
+// longest prefix the name has.  Returns a DuplicateMetric if a metric by the given
+// name is already registered in any child, or an error if the name has no
+// child's prefix.
+func (r *MultiRegistry) Register(name string, i interface{}) error {
+	if nil != r.Get(name) {
+		return DuplicateMetric(name)
+	}
+	c, ok := r.child(name)
+	if !ok {
+		return fmt.Errorf("%s: no child registry has a prefix of the name", name)
+	}
+	return c.Registry.Register(name[len(c.Prefix):], i)
+}
+
+// Run all registered healthchecks in every child.
+func (r *MultiRegistry) RunHealthchecks() {
+	for _, c := range r.children {
+		c.Registry.RunHealthchecks()
+	}
+}
+
+// Set the Metadata for the metric by the given name in the child which holds
+// it, or else the child with the longest prefix the name has.
+func (r *MultiRegistry) SetMetadata(name string, md Metadata) {
+	c, ok := r.owner(name)
+	if !ok {
+		if c, ok = r.child(name); !ok {
+			return
+		}
+	}
+	if mr, ok := c.Registry.(MetadataRegistry); ok {
+		mr.SetMetadata(name[len(c.Prefix):], md)
+	}
+}
+
+// Unregister the metric with the given name from every child.
+func (r *MultiRegistry) Unregister(name string) {
+	for _, c := range r.children {
+		if strings.HasPrefix(name, c.Prefix) {
+			c.Registry.Unregister(name[len(c.Prefix):])
+		}
+	}
+}
+
+// Unregister all metrics from every child.  (Mostly for testing.)
+func (r *MultiRegistry) UnregisterAll() {
+	for _, c := range r.children {
+		c.Registry.UnregisterAll()
+	}
+}
+
+// Call the given Visitor for each registered metric.
+func (r *MultiRegistry) VisitSnapshots(v Visitor) {
+	visitEach(r, v)
+}
+
+// child returns the child with the longest prefix the given name has, the
+// first such if there are several.
+func (r *MultiRegistry) child(name string) (MultiRegistryChild, bool) {
+	var child MultiRegistryChild
+	ok := false
+	for _, c := range r.children {
+		if strings.HasPrefix(name, c.Prefix) && (!ok || len(c.Prefix) > len(child.Prefix)) {
+			child, ok = c, true
+		}
+	}
+	return child, ok
+}
+
+// owner returns the first child in which a metric by the given name is
+// registered.
+func (r *MultiRegistry) owner(name string) (MultiRegistryChild, bool) {
+	for _, c := range r.children {
+		if strings.HasPrefix(name, c.Prefix) && nil != c.Registry.Get(name[len(c.Prefix):]) {
+			return c, true
+		}
+	}
+	return MultiRegistryChild{}, false
+}
@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMultiRegistry(t *testing.T) {
+	r1, r2 := NewRegistry(), NewRegistry()
+	r1.Register("foo", NewCounter())
+	r2.Register("bar", NewGauge())
+	r2.Register("foo", NewMeter())
+	r := NewMultiRegistry(r1, r2)
+
+	var names []string
+	r.EachSorted(func(name string, i interface{}) {
+		names = append(names, name)
+	})
+	if 2 != len(names) || "bar" != names[0] || "foo" != names[1] {
+		t.Fatal(names)
+	}
+	if _, ok := r.Get("foo").(Counter); !ok {
+		t.Fatal(r.Get("foo"))
+	}
+	if _, ok := r.Get("bar").(Gauge); !ok {
+		t.Fatal(r.Get("bar"))
+	}
+	if nil != r.Get("baz") {
+		t.Fatal(r.Get("baz"))
+	}
+
+	if err := r.Register("bar", NewCounter()); nil == err {
+		t.Fatal(err)
+	}
+	if err := r.Register("baz", NewCounter()); nil != err {
+		t.Fatal(err)
+	}
+	if nil == r1.Get("baz") {
+		t.Fatal("baz not registered in the first child")
+	}
+
+	r.Unregister("foo")
+	if nil != r1.Get("foo") || nil != r2.Get("foo") {
+		t.Fatal("foo not unregistered from every child")
+	}
+}
+
+func TestMultiRegistryWithPrefixes(t *testing.T) {
+	r1, r2 := NewRegistry(), NewRegistry()
+	r1.Register("foo", NewCounter())
+	r2.Register("foo", NewGauge())
+	r := NewMultiRegistryWithPrefixes(
+		MultiRegistryChild{Registry: r1},
+		MultiRegistryChild{Prefix: "db.", Registry: r2},
+	)
+
+	var names []string
+	r.EachSorted(func(name string, i interface{}) {
+		names = append(names, name)
+	})
+	if 2 != len(names) || "db.foo" != names[0] || "foo" != names[1] {
+		t.Fatal(names)
+	}
+	if _, ok := r.Get("db.foo").(Gauge); !ok {
+		t.Fatal(r.Get("db.foo"))
+	}
+
+	c := GetOrRegisterCounter("db.bar", r)
+	if r2.Get("bar") != c {
+		t.Fatal(r2.Get("bar"))
+	}
+	RegisterWithMetadata("db.baz", r, NewCounter(), Metadata{Unit: "bytes"})
+	if md, ok := GetMetadata("baz", r2); !ok || "bytes" != md.Unit {
+		t.Fatal(md, ok)
+	}
+	if md, ok := GetMetadata("db.baz", r); !ok || "bytes" != md.Unit {
+		t.Fatal(md, ok)
+	}
+}
+
+func TestMultiRegistryRegisterNoChild(t *testing.T) {
+	r1 := NewRegistry()
+	r := NewMultiRegistryWithPrefixes(MultiRegistryChild{Prefix: "db.", Registry: r1})
+	if err := r.Register("http.requests", NewCounter()); nil == err {
+		t.Fatal("no error")
+	}
+	if nil != r.Get("http.requests") {
+		t.Fatal(r.Get("http.requests"))
+	}
+}
+
+func TestPrefixedChildOfMultiRegistry(t *testing.T) {
+	r1, r2 := NewRegistry(), NewRegistry()
+	r := NewPrefixedChildRegistry(NewMultiRegistry(r1, r2), "prefix.")
+	r.Register("foo", NewCounter())
+	if nil == r1.Get("prefix.foo") {
+		t.Fatal("prefix.foo not registered in the first child")
+	}
+}
+
+func TestMultiRegistryEachErr(t *testing.T) {
+	r1, r2 := NewRegistry(), NewRegistry()
+	r1.Register("foo", NewCounter())
+	r2.Register("bar", NewCounter())
+	r2.Register("foo", NewMeter())
+	r := NewMultiRegistry(r1, r2)
+
+	stop := errors.New("stop")
+	i := 0
+	err := r.EachErr(func(string, interface{}) error {
+		i++
+		return stop
+	})
+	if stop != err {
+		t.Fatal(err)
+	}
+	if 1 != i {
+		t.Fatal(i)
+	}
+
+	var names []string
+	if err := r.EachErr(func(name string, _ interface{}) error {
+		names = append(names, name)
+		return nil
+	}); nil != err {
+		t.Fatal(err)
+	}
+	if 2 != len(names) || "foo" != names[0] || "bar" != names[1] {
+		t.Fatal(names)
+	}
+}
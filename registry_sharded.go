@@ -0,0 +1,242 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+)
+
+// registryShards is the number of shards of a ShardedRegistry.
+const registryShards = 32
+
+// ShardedRegistry is a Registry which spreads its metrics over a number of
+// shards, each a mutex-protected map, by a hash of their names.  Iterating
+// over it holds each shard's lock only while copying that shard's metrics,
+// and registrations and lookups contend only with those of the same shard,
+// so that flushing a registry of tens of thousands of metrics doesn't stall
+// the goroutines registering and looking up metrics in it for as long as it
+// would a StandardRegistry.
+type ShardedRegistry struct {
+	shards [registryShards]registryShard
+}
+
+type registryShard struct {
+	metadata map[string]Metadata
+	metrics  map[string]interface{}
+	mutex    sync.RWMutex
+}
+
+// NewShardedRegistry constructs a new ShardedRegistry.
+func NewShardedRegistry() Registry {
+	r := &ShardedRegistry{}
+	for i := range r.shards {
+		r.shards[i].metrics = make(map[string]interface{})
+	}
+	return r
+}
+
+// Call the given function for each registered metric.
+func (r *ShardedRegistry) Each(f func(string, interface{})) {
+	r.EachErr(ignoreErr(f))
+}
+
+// Call the given function for each registered metric, stopping at and
+// returning the first error it returns.
+func (r *ShardedRegistry) EachErr(f func(string, interface{}) error) error {
+	var metrics []namedMetric
+	for i := range r.shards {
+		metrics = r.shards[i].appendTo(metrics[:0])
+		for _, m := range metrics {
+			if err := f(m.name, m.m); nil != err {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Call the given function for each registered metric in order of name,
+// stopping at and returning the first error it returns.
+func (r *ShardedRegistry) EachSortedErr(f func(string, interface{}) error) error {
+	var metrics []namedMetric
+	for i := range r.shards {
+		metrics = r.shards[i].appendTo(metrics)
+	}
+	sort.Sort(namedMetricSlice(metrics))
+	for _, m := range metrics {
+		if err := f(m.name, m.m); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// Call the given function for each registered metric in order of name.
+func (r *ShardedRegistry) EachSorted(f func(string, interface{})) {
+	r.EachSortedErr(ignoreErr(f))
+}
+
+// Call the given function for each registered metric whose name matches the
+// given pattern, in the syntax of path.Match.  Returns path.ErrBadPattern if
+// the pattern is malformed.
+func (r *ShardedRegistry) EachMatching(pattern string, f func(string, interface{})) error {
+	return eachMatching(r, pattern, f)
+}
+
+// Call the given function for each registered metric with its Metadata,
+// which is zero if none has been set.
+func (r *ShardedRegistry) EachWithMetadata(f func(string, interface{}, Metadata)) {
+	type metricWithMetadata struct {
+		name string
+		m    interface{}
+		md   Metadata
+	}
+	var metrics []metricWithMetadata
+	for i := range r.shards {
+		s := &r.shards[i]
+		metrics = metrics[:0]
+		s.mutex.RLock()
+		for name, m := range s.metrics {
+			metrics = append(metrics, metricWithMetadata{name, m, s.metadata[name]})
+		}
+		s.mutex.RUnlock()
+		for _, m := range metrics {
+			f(m.name, m.m, m.md)
+		}
+	}
+}
+
+// Get the metric by the given name or nil if none is registered.
+func (r *ShardedRegistry) Get(name string) interface{} {
+	s := r.shard(name)
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.metrics[name]
+}
+
+// Gets an existing metric or creates and registers a new one. Threadsafe
+// alternative to calling Get and Register on failure.
+// The interface can be the metric to register if not found in registry,
+// or a function returning the metric for lazy instantiation.
+func (r *ShardedRegistry) GetOrRegister(name string, i interface{}) interface{} {
+	s := r.shard(name)
+	s.mutex.RLock()
+	metric, ok := s.metrics[name]
+	s.mutex.RUnlock()
+	if ok {
+		return metric
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if metric, ok := s.metrics[name]; ok {
+		return metric
+	}
+	i = instantiate(i)
+	s.register(name, i)
+	return i
+}
+
+// Get the Metadata set for the metric by the given name.
+func (r *ShardedRegistry) Metadata(name string) (Metadata, bool) {
+	s := r.shard(name)
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	md, ok := s.metadata[name]
+	return md, ok
+}
+
+// Register the given metric under the given name.  Returns a DuplicateMetric
+// if a metric by the given name is already registered.
+func (r *ShardedRegistry) Register(name string, i interface{}) error {
+	s := r.shard(name)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.register(name, i)
+}
+
+// Run all registered healthchecks.
+func (r *ShardedRegistry) RunHealthchecks() {
+	r.Each(func(_ string, i interface{}) {
+		if h, ok := i.(Healthcheck); ok {
+			h.Check()
+		}
+	})
+}
+
+// Set the Metadata for the metric by the given name, which is forgotten when
+// the metric is unregistered.
+func (r *ShardedRegistry) SetMetadata(name string, md Metadata) {
+	s := r.shard(name)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if nil == s.metadata {
+		s.metadata = make(map[string]Metadata)
+	}
+	s.metadata[name] = md
+}
+
+// Unregister the metric with the given name.
+func (r *ShardedRegistry) Unregister(name string) {
+	s := r.shard(name)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.metrics, name)
+	delete(s.metadata, name)
+}
+
+// Unregister all metrics.  (Mostly for testing.)
+func (r *ShardedRegistry) UnregisterAll() {
+	for i := range r.shards {
+		s := &r.shards[i]
+		s.mutex.Lock()
+		s.metrics = make(map[string]interface{})
+		s.metadata = nil
+		s.mutex.Unlock()
+	}
+}
+
+// Call the given Visitor for each registered metric, without allocating
+// once the registry has been visited a few times.
+func (r *ShardedRegistry) VisitSnapshots(v Visitor) {
+	buf := visitBuffers.Get().(*[]namedMetric)
+	metrics := (*buf)[:0]
+	for i := range r.shards {
+		metrics = r.shards[i].appendTo(metrics[:0])
+		for j, m := range metrics {
+			visitMetric(v, m.name, m.m)
+			metrics[j] = namedMetric{}
+		}
+	}
+	*buf = metrics[:0]
+	visitBuffers.Put(buf)
+}
+
+// shard returns the shard of the metric by the given name, chosen by the
+// FNV-1a hash of the name.
+func (r *ShardedRegistry) shard(name string) *registryShard {
+	h := uint32(2166136261)
+	for i := 0; i < len(name); i++ {
+		h ^= uint32(name[i])
+		h *= 16777619
+	}
+	return &r.shards[h%registryShards]
+}
+
+// appendTo appends the shard's metrics to metrics.
+func (s *registryShard) appendTo(metrics []namedMetric) []namedMetric {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for name, i := range s.metrics {
+		metrics = append(metrics, namedMetric{name, i})
+	}
+	return metrics
+}
+
+func (s *registryShard) register(name string, i interface{}) error {
+	if _, ok := s.metrics[name]; ok {
+		return DuplicateMetric(name)
+	}
+	if isMetric(i) {
+		s.metrics[name] = i
+	}
+	return nil
+}
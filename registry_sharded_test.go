@@ -0,0 +1,145 @@
+package metrics
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// BenchmarkRegistryEach50k and BenchmarkShardedRegistryEach50k compare the
+// time taken to flush a registry of 50,000 metrics while other goroutines
+// look metrics up in it, which a StandardRegistry blocks for the whole copy.
+func BenchmarkRegistryEach50k(b *testing.B) {
+	benchmarkRegistryEach(b, NewRegistry())
+}
+func BenchmarkShardedRegistryEach50k(b *testing.B) {
+	benchmarkRegistryEach(b, NewShardedRegistry())
+}
+
+func benchmarkRegistryEach(b *testing.B, r Registry) {
+	names := make([]string, 50000)
+	for i := range names {
+		names[i] = "metric." + strconv.Itoa(i)
+		r.Register(names[i], NewCounter())
+	}
+	quit := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for {
+				select {
+				case <-quit:
+					return
+				default:
+				}
+				GetOrRegisterCounter(names[i], r).Inc(1)
+				i = (i + 7919) % len(names)
+			}
+		}(i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Each(func(string, interface{}) {})
+	}
+	b.StopTimer()
+	close(quit)
+	wg.Wait()
+}
+
+func TestShardedRegistry(t *testing.T) {
+	r := NewShardedRegistry()
+	for i := 0; i < 100; i++ {
+		if err := r.Register(fmt.Sprintf("foo%02d", i), NewCounter()); nil != err {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Register("foo00", NewCounter()); DuplicateMetric("foo00") != err {
+		t.Errorf("r.Register(\"foo00\") again: %v\n", err)
+	}
+	r.Register("bar", "not a metric")
+	if nil != r.Get("bar") {
+		t.Error("r.Get(\"bar\"): not a metric registered")
+	}
+	c := r.GetOrRegister("foo99", NewCounter)
+	if c != r.Get("foo99") {
+		t.Error("r.GetOrRegister(\"foo99\") isn't the registered counter")
+	}
+	if _, ok := r.GetOrRegister("baz", NewGauge).(Gauge); !ok {
+		t.Error("r.GetOrRegister(\"baz\", NewGauge) isn't a Gauge")
+	}
+
+	var names []string
+	r.EachSorted(func(name string, i interface{}) {
+		names = append(names, name)
+	})
+	if 101 != len(names) || "baz" != names[0] || "foo00" != names[1] || "foo99" != names[100] {
+		t.Errorf("names: %v\n", names)
+	}
+	n := 0
+	r.Each(func(string, interface{}) { n++ })
+	if 101 != n {
+		t.Errorf("n: 101 != %v\n", n)
+	}
+
+	r.(MetadataRegistry).SetMetadata("foo01", Metadata{Unit: "requests"})
+	if md, ok := GetMetadata("foo01", r); !ok || "requests" != md.Unit {
+		t.Errorf("GetMetadata(\"foo01\"): %v, %v\n", md, ok)
+	}
+	EachWithMetadata(r, func(name string, i interface{}, md Metadata) {
+		if want := "foo01" == name; want != ("requests" == md.Unit) {
+			t.Errorf("%v metadata: %v\n", name, md)
+		}
+	})
+
+	r.Unregister("foo01")
+	if nil != r.Get("foo01") {
+		t.Error("foo01 still registered")
+	}
+	if _, ok := GetMetadata("foo01", r); ok {
+		t.Error("foo01 metadata not forgotten")
+	}
+	r.UnregisterAll()
+	r.Each(func(name string, i interface{}) {
+		t.Errorf("%v registered after UnregisterAll\n", name)
+	})
+}
+
+func TestShardedRegistryVisitSnapshots(t *testing.T) {
+	r := NewShardedRegistry()
+	want := make(map[string]interface{})
+	for i := 0; i < 100; i++ {
+		name := "c" + strconv.Itoa(i)
+		NewRegisteredCounter(name, r).Inc(int64(i))
+		want[name] = int64(i)
+	}
+	v := &recordingVisitor{visited: make(map[string]interface{})}
+	r.VisitSnapshots(v)
+	if !reflect.DeepEqual(want, v.visited) {
+		t.Errorf("%v != %v\n", want, v.visited)
+	}
+}
+
+func TestShardedRegistryConcurrent(t *testing.T) {
+	r := NewShardedRegistry()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				GetOrRegisterCounter("c"+strconv.Itoa(j), r).Inc(1)
+				r.Each(func(string, interface{}) {})
+			}
+		}()
+	}
+	wg.Wait()
+	r.Each(func(name string, i interface{}) {
+		if c := i.(Counter).Count(); 8 != c {
+			t.Errorf("%v: 8 != %v\n", name, c)
+		}
+	})
+}
@@ -1,6 +1,10 @@
 package metrics
 
 import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
 	"testing"
 )
 
@@ -13,6 +17,64 @@ func BenchmarkRegistry(b *testing.B) {
 	}
 }
 
+// BenchmarkRegistryGetOrRegister{,Miss} look up a registered counter and
+// construct and register one, and BenchmarkRegistryGetOrRegisterReflect does
+// the latter with a constructor which has to be called by reflection.
+func BenchmarkRegistryGetOrRegister(b *testing.B) {
+	r := NewRegistry()
+	GetOrRegisterCounter("foo", r)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		GetOrRegisterCounter("foo", r)
+	}
+}
+func BenchmarkRegistryGetOrRegisterMiss(b *testing.B) {
+	r := NewRegistry()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.GetOrRegister("foo", NewCounter)
+		r.Unregister("foo")
+	}
+}
+func BenchmarkRegistryGetOrRegisterReflect(b *testing.B) {
+	r := NewRegistry()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.GetOrRegister("foo", NewUint64Counter)
+		r.Unregister("foo")
+	}
+}
+
+// BenchmarkRegistryGetOrRegisterParallel looks up a registered metric from
+// many goroutines, as request handlers do, while the registry is flushed.
+func BenchmarkRegistryGetOrRegisterParallel(b *testing.B) {
+	r := NewRegistry()
+	for i := 0; i < 1000; i++ {
+		r.Register(fmt.Sprintf("foo%d", i), NewCounter())
+	}
+	quit := make(chan struct{})
+	defer close(quit)
+	go func() {
+		for {
+			select {
+			case <-quit:
+				return
+			default:
+				r.Each(func(string, interface{}) {})
+			}
+		}
+	}()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			GetOrRegisterCounter("foo0", r).Inc(1)
+		}
+	})
+}
+
 func TestRegistry(t *testing.T) {
 	r := NewRegistry()
 	r.Register("foo", NewCounter())
@@ -94,6 +156,20 @@ func TestRegistryGetOrRegister(t *testing.T) {
 	}
 }
 
+func TestInstantiate(t *testing.T) {
+	c := NewCounter()
+	for _, i := range []interface{}{
+		c,
+		func() Counter { return c },
+		func() interface{} { return c },
+		func() *StandardCounter { return c.(*StandardCounter) },
+	} {
+		if m := instantiate(i); c != m {
+			t.Errorf("instantiate(%T): %v != %v\n", i, c, m)
+		}
+	}
+}
+
 func TestRegistryGetOrRegisterWithLazyInstantiation(t *testing.T) {
 	r := NewRegistry()
 
@@ -286,3 +362,281 @@ func TestWalkRegistries(t *testing.T) {
 	}
 
 }
+
+func TestRegistryEachErr(t *testing.T) {
+	r := NewRegistry()
+	for _, name := range []string{"c", "a", "b"} {
+		r.Register(name, NewCounter())
+	}
+	stop := errors.New("stop")
+	i := 0
+	err := r.EachErr(func(name string, iface interface{}) error {
+		i++
+		return stop
+	})
+	if stop != err {
+		t.Fatal(err)
+	}
+	if 1 != i {
+		t.Fatal(i)
+	}
+	if err := r.EachErr(func(string, interface{}) error { return nil }); nil != err {
+		t.Fatal(err)
+	}
+}
+
+func TestRegistryEachSortedErr(t *testing.T) {
+	r := NewRegistry()
+	for _, name := range []string{"c", "a", "b"} {
+		r.Register(name, NewCounter())
+	}
+	var names []string
+	err := r.EachSortedErr(func(name string, iface interface{}) error {
+		names = append(names, name)
+		if "b" == name {
+			return fmt.Errorf("at %s", name)
+		}
+		return nil
+	})
+	if nil == err || "at b" != err.Error() {
+		t.Fatal(err)
+	}
+	if "[a b]" != fmt.Sprint(names) {
+		t.Fatal(names)
+	}
+}
+
+func TestPrefixedRegistryEachSortedErr(t *testing.T) {
+	r := NewRegistry()
+	pr := NewPrefixedChildRegistry(r, "prefix.")
+	r.Register("foo", NewCounter())
+	pr.Register("foo", NewCounter())
+	pr.Register("bar", NewCounter())
+	var names []string
+	pr.EachSortedErr(func(name string, iface interface{}) error {
+		names = append(names, name)
+		return nil
+	})
+	if "[prefix.bar prefix.foo]" != fmt.Sprint(names) {
+		t.Fatal(names)
+	}
+}
+
+// basicRegistry is a custom registry which implements only BasicRegistry.
+type basicRegistry struct {
+	BasicRegistry
+}
+
+func TestAdaptRegistry(t *testing.T) {
+	r := NewRegistry()
+	if AdaptRegistry(r) != r {
+		t.Fatal("AdaptRegistry wrapped a Registry")
+	}
+	for _, name := range []string{"c", "a", "b"} {
+		r.Register(name, NewCounter())
+	}
+	ar := AdaptRegistry(basicRegistry{r})
+	i := 0
+	stop := errors.New("stop")
+	if err := ar.EachErr(func(string, interface{}) error {
+		i++
+		return stop
+	}); stop != err {
+		t.Fatal(err)
+	}
+	if 1 != i {
+		t.Fatal(i)
+	}
+	var names []string
+	ar.EachSortedErr(func(name string, iface interface{}) error {
+		names = append(names, name)
+		return nil
+	})
+	if "[a b c]" != fmt.Sprint(names) {
+		t.Fatal(names)
+	}
+}
+
+func TestRegistryEachSorted(t *testing.T) {
+	r := NewRegistry()
+	for _, name := range []string{"c", "a", "b"} {
+		r.Register(name, NewCounter())
+	}
+	var names []string
+	r.EachSorted(func(name string, iface interface{}) {
+		names = append(names, name)
+	})
+	if "[a b c]" != fmt.Sprint(names) {
+		t.Fatal(names)
+	}
+}
+
+func TestRegistryEachMatching(t *testing.T) {
+	r := NewRegistry()
+	for _, name := range []string{"ld.stream.errors", "ld.stream.events", "ld.poll.errors"} {
+		r.Register(name, NewCounter())
+	}
+	names := make(map[string]bool)
+	if err := r.EachMatching("ld.stream.*", func(name string, iface interface{}) {
+		names[name] = true
+	}); nil != err {
+		t.Fatal(err)
+	}
+	if 2 != len(names) || !names["ld.stream.errors"] || !names["ld.stream.events"] {
+		t.Fatal(names)
+	}
+	if err := r.EachMatching("[", func(string, interface{}) {}); path.ErrBadPattern != err {
+		t.Fatal(err)
+	}
+
+	names = make(map[string]bool)
+	EachMatchingRegexp(r, regexp.MustCompile(`\.errors$`), func(name string, iface interface{}) {
+		names[name] = true
+	})
+	if 2 != len(names) || !names["ld.stream.errors"] || !names["ld.poll.errors"] {
+		t.Fatal(names)
+	}
+}
+
+func TestTryRegister(t *testing.T) {
+	r := NewRegistry()
+	if err := TryRegister("foo", r, NewCounter()); nil != err {
+		t.Fatal(err)
+	}
+	if err := TryRegister("foo", r, NewCounter()); DuplicateMetric("foo") != err {
+		t.Fatal(err)
+	}
+	if err := TryRegister("bar", r, "not a metric"); nil == err {
+		t.Fatal("registered a string")
+	}
+}
+
+func TestTryGetOrRegister(t *testing.T) {
+	r := NewRegistry()
+	c := NewCounter()
+	r.Register("foo", c)
+	if m, err := TryGetOrRegister("foo", r, NewCounter); nil != err || c != m {
+		t.Fatal(m, err)
+	}
+	if m, err := TryGetOrRegister("foo", r, NewCounter()); nil != err || c != m {
+		t.Fatal(m, err)
+	}
+	if m, err := TryGetOrRegister("foo", r, NewGauge); DuplicateMetric("foo") != err {
+		t.Fatal(m, err)
+	}
+	if m, err := TryGetOrRegister("foo", r, NewGauge()); DuplicateMetric("foo") != err {
+		t.Fatal(m, err)
+	}
+	if m, err := TryGetOrRegister("bar", r, 47); nil == err {
+		t.Fatal(m)
+	}
+}
+
+func TestOnRegistrationConflict(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredGauge("foo", r)
+	var conflict error
+	SetOnRegistrationConflict(func(err error) { conflict = err })
+	defer SetOnRegistrationConflict(nil)
+	c := GetOrRegisterCounter("foo", r)
+	c.Inc(1)
+	if DuplicateMetric("foo") != conflict {
+		t.Fatal(conflict)
+	}
+	if _, ok := r.Get("foo").(Gauge); !ok {
+		t.Fatal(r.Get("foo"))
+	}
+}
+
+func TestSetOnRegistrationConflictConcurrently(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredGauge("foo", r)
+	SetOnRegistrationConflict(func(error) {})
+	defer SetOnRegistrationConflict(nil)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			GetOrRegisterCounter("foo", r)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		SetOnRegistrationConflict(func(error) {})
+	}
+	<-done
+}
+
+func TestRegistrationConflictPanics(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredGauge("foo", r)
+	defer func() {
+		if err := recover(); DuplicateMetric("foo") != err {
+			t.Fatal(err)
+		}
+	}()
+	GetOrRegisterCounter("foo", r)
+}
+
+func TestRegistryFreeze(t *testing.T) {
+	r := NewRegistry().(*StandardRegistry)
+	c := NewRegisteredCounter("foo", r)
+	r.Freeze()
+	if !r.Frozen() {
+		t.Fatal("r.Frozen(): false")
+	}
+	if m := r.GetOrRegister("foo", NewCounter); c != m {
+		t.Errorf("r.GetOrRegister(\"foo\"): %v != %v\n", c, m)
+	}
+	if err := r.Register("bar", NewCounter()); RegistryFrozen("bar") != err {
+		t.Errorf("r.Register(\"bar\"): %v\n", err)
+	}
+	if err := r.Register("foo", NewCounter()); DuplicateMetric("foo") != err {
+		t.Errorf("r.Register(\"foo\"): %v\n", err)
+	}
+	r.SetMetadata("foo", Metadata{Unit: "requests"})
+	n := 0
+	r.Each(func(string, interface{}) { n++ })
+	if 1 != n {
+		t.Errorf("n: 1 != %v\n", n)
+	}
+	v := &recordingVisitor{visited: make(map[string]interface{})}
+	r.VisitSnapshots(v)
+	if 1 != len(v.visited) {
+		t.Errorf("v.visited: %v\n", v.visited)
+	}
+
+	for name, f := range map[string]func(){
+		"GetOrRegister": func() { r.GetOrRegister("bar", NewCounter) },
+		"Unregister":    func() { r.Unregister("foo") },
+		"UnregisterAll": func() { r.UnregisterAll() },
+	} {
+		func() {
+			defer func() {
+				if _, ok := recover().(RegistryFrozen); !ok {
+					t.Errorf("%v didn't panic with RegistryFrozen\n", name)
+				}
+			}()
+			f()
+		}()
+	}
+	if c != r.Get("foo") {
+		t.Error("foo unregistered")
+	}
+}
+
+func TestRegistryFreezeConcurrent(t *testing.T) {
+	r := NewRegistry().(*StandardRegistry)
+	NewRegisteredCounter("foo", r)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			GetOrRegisterCounter("foo", r).Inc(1)
+		}
+	}()
+	r.Freeze()
+	<-done
+	if c := GetOrRegisterCounter("foo", r).Count(); 1000 != c {
+		t.Errorf("c: 1000 != %v\n", c)
+	}
+}
@@ -0,0 +1,221 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// RemoteWriteConfig provides a container with configuration parameters for
+// the Prometheus remote-write exporter, which pushes to any receiver of the
+// remote-write protocol, eg VictoriaMetrics, Mimir or Thanos, so that short-
+// lived jobs needn't be scraped.
+//
+// Metrics are translated as by CollectPoints, with names sanitised to
+// Prometheus' rules.  Histograms and timers become summaries, ie a series
+// per quantile labelled "quantile" plus name_sum and name_count.  Every
+// series is labelled with Labels and then the Tags from its metric's
+// Metadata.  Exemplars are sent with a sum's series or a summary's
+// name_count.
+type RemoteWriteConfig struct {
+	URL           string            // Remote-write endpoint, eg "http://vm:8428/api/v1/write"
+	Registry      Registry          // Registry to be exported
+	FlushInterval time.Duration     // Flush interval
+	DurationUnit  time.Duration     // Time conversion unit for durations
+	Prefix        string            // Prefix to be prepended to metric names
+	Labels        map[string]string // Labels added to every series, eg {"job": "backup"}
+	Headers       map[string]string // Extra request headers, eg for authorization
+	Client        *http.Client      // HTTP client, defaulting to http.DefaultClient
+	Filter        MetricFilter      // Selects the metrics to send, defaulting to all
+}
+
+// RemoteWrite is a blocking exporter function which pushes the metrics in
+// c.Registry to c.URL every c.FlushInterval.
+func RemoteWrite(c RemoteWriteConfig) {
+	NewRemoteWriteReporter(c).Run(context.Background())
+}
+
+// NewRemoteWriteReporter constructs a new Reporter which exports just as
+// RemoteWrite does, logging any errors.
+func NewRemoteWriteReporter(c RemoteWriteConfig) *Reporter {
+	return newExporterReporter(c.Registry, NewRemoteWriteExporter(c), SchedulerConfig{Interval: c.FlushInterval})
+}
+
+// NewRemoteWriteExporter constructs a new Exporter which pushes the registry
+// it's given as RemoteWriteOnce does, for use with a Scheduler.  c.Registry
+// and c.FlushInterval are ignored.
+func NewRemoteWriteExporter(c RemoteWriteConfig) Exporter {
+	return ExporterFunc(func(r Registry) error {
+		c := c
+		c.Registry = r
+		return RemoteWriteOnce(c)
+	})
+}
+
+// RemoteWriteOnce pushes the metrics in c.Registry to c.URL once, eg at the
+// end of a batch job.
+func RemoteWriteOnce(c RemoteWriteConfig) error {
+	body := snappyEncode(remoteWriteRequest(&c, time.Now()))
+	req, err := http.NewRequest("POST", c.URL, bytes.NewReader(body))
+	if nil != err {
+		return err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	for k, v := range c.Headers {
+		req.Header.Set(k, v)
+	}
+	client := c.Client
+	if nil == client {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if nil != err {
+		return err
+	}
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("remote write: %s: %s", resp.Status, bytes.TrimSpace(b))
+	}
+	return nil
+}
+
+// remoteWriteRequest encodes the registry as a prometheus.WriteRequest
+// protocol buffer.
+func remoteWriteRequest(c *RemoteWriteConfig, now time.Time) []byte {
+	ts := now.UnixNano() / int64(time.Millisecond)
+	var buf []byte
+	series := func(name string, labels map[string]string, quantile string, v float64, exemplars []Exemplar) {
+		buf = appendProtoBytes(buf, 1, remoteWriteSeries(name, labels, quantile, v, ts, exemplars))
+	}
+	for _, p := range CollectPoints(c.Filter.registry(c.Registry), c.DurationUnit) {
+		name := prometheusName(c.Prefix + p.Name)
+		labels := make(map[string]string, len(c.Labels)+len(p.Tags))
+		for k, v := range c.Labels {
+			labels[prometheusName(k)] = v
+		}
+		for k, v := range p.Tags {
+			labels[prometheusName(k)] = v
+		}
+		if PointSummary != p.Kind {
+			series(name, labels, "", p.Value, p.Exemplars)
+			continue
+		}
+		for _, q := range p.Quantiles {
+			series(name, labels, strconv.FormatFloat(q.Quantile, 'g', -1, 64), q.Value, nil)
+		}
+		series(name+"_sum", labels, "", p.Sum, nil)
+		series(name+"_count", labels, "", float64(p.Count), p.Exemplars)
+	}
+	return buf
+}
+
+// remoteWriteSeries encodes one prometheus.TimeSeries with one sample and
+// the given exemplars, its labels sorted by name as the protocol requires.
+// The quantile label is added unless empty.
+func remoteWriteSeries(name string, labels map[string]string, quantile string, v float64, ts int64, exemplars []Exemplar) []byte {
+	all := map[string]string{"__name__": name}
+	for k, v := range labels {
+		all[k] = v
+	}
+	if "" != quantile {
+		all["quantile"] = quantile
+	}
+	buf := appendRemoteWriteLabels(nil, all)
+	buf = appendProtoBytes(buf, 2, appendRemoteWriteValue(nil, 1, v, ts))
+	for _, e := range exemplars {
+		exemplar := appendRemoteWriteLabels(nil, e.Labels)
+		exemplar = appendRemoteWriteValue(exemplar, 2, e.Value, e.Timestamp.UnixNano()/int64(time.Millisecond))
+		buf = appendProtoBytes(buf, 3, exemplar)
+	}
+	return buf
+}
+
+// appendRemoteWriteLabels encodes the given labels, sorted by name, as
+// field 1 of a prometheus.TimeSeries or Exemplar.
+func appendRemoteWriteLabels(buf []byte, labels map[string]string) []byte {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		var label []byte
+		label = appendProtoBytes(label, 1, []byte(k))
+		label = appendProtoBytes(label, 2, []byte(labels[k]))
+		buf = appendProtoBytes(buf, 1, label)
+	}
+	return buf
+}
+
+// appendRemoteWriteValue encodes a value and timestamp as the given field
+// and the next, ie fields 1 and 2 of a prometheus.Sample or 2 and 3 of an
+// Exemplar.
+func appendRemoteWriteValue(buf []byte, field int, v float64, ts int64) []byte {
+	buf = appendProtoTag(buf, field, 1)
+	var bits [8]byte
+	binary.LittleEndian.PutUint64(bits[:], math.Float64bits(v))
+	buf = append(buf, bits[:]...)
+	buf = appendProtoTag(buf, field+1, 0)
+	return appendUvarint(buf, uint64(ts))
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+func appendProtoTag(b []byte, field, wireType int) []byte {
+	return appendUvarint(b, uint64(field<<3|wireType))
+}
+
+func appendProtoBytes(b []byte, field int, v []byte) []byte {
+	b = appendProtoTag(b, field, 2)
+	b = appendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// prometheusName replaces the characters Prometheus doesn't allow in metric
+// and label names with underscores.
+func prometheusName(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '_' == c || ':' == c || 0 < i && '0' <= c && c <= '9') {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// snappyEncode frames src in the snappy block format without compressing
+// it, as a run of literals, which every snappy decoder accepts.  Metrics
+// payloads are small enough that it's not worth depending on a compressor.
+func snappyEncode(src []byte) []byte {
+	dst := appendUvarint(nil, uint64(len(src)))
+	for 0 < len(src) {
+		n := len(src)
+		if n > 1<<16 {
+			n = 1 << 16
+		}
+		switch {
+		case n <= 60:
+			dst = append(dst, byte(n-1)<<2)
+		case n <= 1<<8:
+			dst = append(dst, 60<<2, byte(n-1))
+		default:
+			dst = append(dst, 61<<2, byte(n-1), byte((n-1)>>8))
+		}
+		dst = append(dst, src[:n]...)
+		src = src[n:]
+	}
+	return dst
+}
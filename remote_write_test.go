@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// snappyDecodeLiterals decodes the literal-only snappy blocks snappyEncode
+// produces.
+func snappyDecodeLiterals(t *testing.T, src []byte) []byte {
+	size, n := binary.Uvarint(src)
+	src = src[n:]
+	var dst []byte
+	for 0 < len(src) {
+		tag := src[0]
+		if 0 != tag&3 {
+			t.Fatalf("not a literal: %x\n", tag)
+		}
+		var l int
+		switch tag >> 2 {
+		case 60:
+			l, src = int(src[1])+1, src[2:]
+		case 61:
+			l, src = int(src[1])|int(src[2])<<8+1, src[3:]
+		default:
+			l, src = int(tag>>2)+1, src[1:]
+		}
+		dst, src = append(dst, src[:l]...), src[l:]
+	}
+	if uint64(len(dst)) != size {
+		t.Fatalf("size: %v != %v\n", size, len(dst))
+	}
+	return dst
+}
+
+func TestSnappyEncode(t *testing.T) {
+	for _, n := range []int{0, 1, 60, 61, 256, 257, 1 << 16, 1<<16 + 1, 200000} {
+		src := bytes.Repeat([]byte{'x'}, n)
+		if got := snappyDecodeLiterals(t, snappyEncode(src)); !bytes.Equal(src, got) {
+			t.Errorf("%v: round trip failed\n", n)
+		}
+	}
+}
+
+func TestRemoteWriteRequest(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("foo", r).Inc(47)
+	got := remoteWriteRequest(&RemoteWriteConfig{Registry: r}, time.Unix(1, 0))
+	want := []byte{
+		0x0a, 0x1f, // timeseries
+		0x0a, 0x0f, // labels
+		0x0a, 0x08, '_', '_', 'n', 'a', 'm', 'e', '_', '_',
+		0x12, 0x03, 'f', 'o', 'o',
+		0x12, 0x0c, // samples
+		0x09, 0, 0, 0, 0, 0, 0x80, 0x47, 0x40, // 47.0
+		0x10, 0xe8, 0x07, // 1000ms
+	}
+	if !bytes.Equal(want, got) {
+		t.Errorf("%x != %x\n", want, got)
+	}
+}
+
+func TestRemoteWriteRequestExemplars(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("foo", r).(ExemplarRecorder).UpdateWithExemplar(47, map[string]string{"trace_id": "abc"})
+	got := remoteWriteRequest(&RemoteWriteConfig{Registry: r}, time.Unix(1, 0))
+	want := []byte{
+		0x0a, 0x0f, // exemplar labels
+		0x0a, 0x08, 't', 'r', 'a', 'c', 'e', '_', 'i', 'd',
+		0x12, 0x03, 'a', 'b', 'c',
+		0x11, 0, 0, 0, 0, 0, 0x80, 0x47, 0x40, // 47.0
+		0x18, // timestamp
+	}
+	if !bytes.Contains(got, want) {
+		t.Errorf("%x doesn't contain %x\n", got, want)
+	}
+}
+
+func TestPrometheusName(t *testing.T) {
+	for in, want := range map[string]string{
+		"foo.bar-baz": "foo_bar_baz",
+		"0foo:1":      "_foo:1",
+	} {
+		if got := prometheusName(in); want != got {
+			t.Errorf("%v: %v != %v\n", in, want, got)
+		}
+	}
+}
+
+func TestRemoteWriteOnce(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredTimer("foo", r).Update(time.Millisecond)
+	var body []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if "snappy" != req.Header.Get("Content-Encoding") || "Bearer x" != req.Header.Get("Authorization") {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		body, _ = ioutil.ReadAll(req.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+	c := RemoteWriteConfig{
+		URL:      ts.URL,
+		Registry: r,
+		Labels:   map[string]string{"job": "test"},
+		Headers:  map[string]string{"Authorization": "Bearer x"},
+	}
+	if err := RemoteWriteOnce(c); nil != err {
+		t.Fatal(err)
+	}
+	got := snappyDecodeLiterals(t, body)
+	for _, s := range []string{"foo_sum", "foo_count", "quantile", "0.999", "job", "test"} {
+		if !bytes.Contains(got, []byte(s)) {
+			t.Errorf("request doesn't contain %q\n", s)
+		}
+	}
+
+	c.Headers = nil
+	if err := RemoteWriteOnce(c); nil == err {
+		t.Fatal(err)
+	}
+}
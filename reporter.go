@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Reporter calls a flush function periodically, once more when it's stopped
+// so that nothing recorded since the last tick is lost, and on demand via
+// FlushNow or Once.  The blocking exporter functions, eg Log and WriteJSON,
+// run one forever; construct one with eg NewLogReporter instead to be able
+// to stop it.  It's a Scheduler which logs the errors of periodic flushes;
+// use a Scheduler directly for backoff.
+type Reporter struct {
+	scheduler *Scheduler
+}
+
+// NewReporter constructs a new Reporter which calls flush every d.
+func NewReporter(d time.Duration, flush func()) *Reporter {
+	return NewReporterWithClock(d, flush, DefaultClock)
+}
+
+// NewReporterWithClock is like NewReporter but ticks with the given Clock.
+func NewReporterWithClock(d time.Duration, flush func(), c Clock) *Reporter {
+	return newExporterReporter(nil, ExporterFunc(func(Registry) error {
+		flush()
+		return nil
+	}), SchedulerConfig{Interval: d, Clock: c})
+}
+
+// newExporterReporter constructs a new Reporter which schedules e, for the
+// exporters which construct Reporters but can fail.
+func newExporterReporter(r Registry, e Exporter, c SchedulerConfig) *Reporter {
+	return &Reporter{scheduler: NewScheduler(r, e, c)}
+}
+
+// SetJitter delays each periodic flush by a random duration up to d, so that
+// many processes started together don't all flush at once.  Call it before
+// Start or Run.
+func (r *Reporter) SetJitter(d time.Duration) {
+	r.scheduler.config.Jitter = d
+}
+
+// SetAlign sets whether periodic flushes happen at multiples of the interval
+// on the wall clock, eg at :00 and :30 every thirty seconds, before any
+// jitter.  Call it before Start or Run.
+func (r *Reporter) SetAlign(align bool) {
+	r.scheduler.config.Align = align
+}
+
+// FlushNow flushes immediately, waiting for any flush already under way to
+// finish first.
+func (r *Reporter) FlushNow() {
+	if err := r.scheduler.FlushNow(); nil != err {
+		log.Println(err)
+	}
+}
+
+// Once flushes immediately, just as FlushNow does, but returns any error
+// rather than logging it, so that eg a command-line tool or a test can
+// force a single emission and check it.  Reporters constructed with
+// NewReporter never fail.
+func (r *Reporter) Once() error {
+	return r.scheduler.FlushNow()
+}
+
+// Run flushes periodically until the given context is done or Shutdown is
+// called, then flushes one final time and returns.
+func (r *Reporter) Run(ctx context.Context) {
+	r.scheduler.Run(ctx)
+}
+
+// Start runs the reporter in a new goroutine until Stop or Shutdown is
+// called.  Calling Start on a reporter which is already running does
+// nothing.
+func (r *Reporter) Start() {
+	r.scheduler.Start()
+}
+
+// Stop stops a reporter started with Start, returning once its final flush
+// is done.  Calling Stop on a reporter which isn't running does nothing.
+func (r *Reporter) Stop() {
+	if err := r.scheduler.Close(); nil != err {
+		log.Println(err)
+	}
+}
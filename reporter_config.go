@@ -0,0 +1,219 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"time"
+)
+
+// ReportersConfig is the configuration of the reporters of a program, eg
+// read from a file with ReadReportersConfig, so that it can switch backends
+// without code changes.  NewReportersFromConfig constructs them.
+type ReportersConfig struct {
+	Reporters []ReporterConfig `json:"reporters" yaml:"reporters"`
+}
+
+// ReporterConfig is the configuration of one reporter.  Type selects the
+// backend, which determines the other fields it reads:
+//
+//	"log"          Format, OnlyChanged
+//	"opentsdb"     Address of the telnet interface or URL of the HTTP API, Tags
+//	"remotewrite"  URL of a Prometheus remote-write endpoint, Tags as labels, Headers
+//	"pushgateway"  URL of a Prometheus Pushgateway, Job, Tags as the grouping labels, Headers
+//	"datadog"      APIKey, URL if not the default, Tags
+//	"statsd"       Address, eg "localhost:8125" or "unix:///var/run/statsd.sock", WriteTimeout
+//
+// Every backend reads Interval, DurationUnit, Prefix, which the log reporter
+// ignores, and Include and Exclude.  URL, APIKey and the values of Headers
+// are expanded with os.ExpandEnv, so that eg secrets can be given as
+// "$DD_API_KEY" and kept out of the file.
+type ReporterConfig struct {
+	Type         string            `json:"type" yaml:"type"`                 // Backend, eg "remotewrite"
+	Interval     ConfigDuration    `json:"interval" yaml:"interval"`         // Flush interval, eg "30s"
+	DurationUnit ConfigDuration    `json:"durationUnit" yaml:"durationUnit"` // Time conversion unit for durations, eg "1ms", defaulting to nanoseconds
+	Prefix       string            `json:"prefix" yaml:"prefix"`             // Prefix to be prepended to metric names
+	Include      []string          `json:"include" yaml:"include"`           // If set, only report names matching one of these patterns in the syntax of path.Match, eg "http.*"
+	Exclude      []string          `json:"exclude" yaml:"exclude"`           // Never report names matching one of these patterns
+	Address      string            `json:"address" yaml:"address"`           // Network address to connect to, eg "tsdb:4242"
+	URL          string            `json:"url" yaml:"url"`                   // Endpoint to send to
+	APIKey       string            `json:"apiKey" yaml:"apiKey"`             // API key
+	Job          string            `json:"job" yaml:"job"`                   // Job label
+	Tags         map[string]string `json:"tags" yaml:"tags"`                 // Tags, or labels, added to every series
+	Headers      map[string]string `json:"headers" yaml:"headers"`           // Extra request headers, eg for authorization
+	Format       string            `json:"format" yaml:"format"`             // Log format: "text", the default, "keyvalue" or "json"
+	OnlyChanged  bool              `json:"onlyChanged" yaml:"onlyChanged"`   // Skip metrics unchanged since the last flush
+	WriteTimeout ConfigDuration    `json:"writeTimeout" yaml:"writeTimeout"` // Timeout for writing each flush, if any
+}
+
+// ConfigDuration is a time.Duration which is read from and written to
+// configuration in the syntax of time.ParseDuration, eg "30s".
+type ConfigDuration time.Duration
+
+// MarshalText returns the duration in the syntax of time.ParseDuration.
+func (d ConfigDuration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText parses a duration in the syntax of time.ParseDuration.
+func (d *ConfigDuration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	if nil != err {
+		return err
+	}
+	*d = ConfigDuration(v)
+	return nil
+}
+
+// ReadReportersConfig reads a ReportersConfig from rd as JSON, rejecting
+// unknown fields, or with unmarshal if it's not nil, eg yaml.Unmarshal from
+// a YAML package, which reads the fields' yaml tags.
+func ReadReportersConfig(rd io.Reader, unmarshal func([]byte, interface{}) error) (ReportersConfig, error) {
+	var c ReportersConfig
+	b, err := ioutil.ReadAll(rd)
+	if nil != err {
+		return c, err
+	}
+	if nil != unmarshal {
+		err = unmarshal(b, &c)
+	} else {
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.DisallowUnknownFields()
+		err = d.Decode(&c)
+	}
+	return c, err
+}
+
+// NewReportersFromConfig constructs a Reporter of r, or the DefaultRegistry
+// if r is nil, for each reporter configured, or returns an error if any of
+// them is misconfigured.  The log reporter logs to standard error.  Start
+// the reporters with their Start method.
+func NewReportersFromConfig(c ReportersConfig, r Registry) ([]*Reporter, error) {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	reporters := make([]*Reporter, 0, len(c.Reporters))
+	for i, rc := range c.Reporters {
+		reporter, err := newReporterFromConfig(rc, r)
+		if nil != err {
+			return nil, fmt.Errorf("reporter %d (%s): %v", i, rc.Type, err)
+		}
+		reporters = append(reporters, reporter)
+	}
+	return reporters, nil
+}
+
+func newReporterFromConfig(c ReporterConfig, r Registry) (*Reporter, error) {
+	interval, unit := time.Duration(c.Interval), time.Duration(c.DurationUnit)
+	if interval <= 0 {
+		return nil, fmt.Errorf("no interval")
+	}
+	if 0 == unit {
+		unit = time.Nanosecond
+	}
+	url, apiKey := os.ExpandEnv(c.URL), os.ExpandEnv(c.APIKey)
+	headers := make(map[string]string, len(c.Headers))
+	for k, v := range c.Headers {
+		headers[k] = os.ExpandEnv(v)
+	}
+	filter := MetricFilter{Include: c.Include, Exclude: c.Exclude}
+	switch c.Type {
+	case "log":
+		format, ok := map[string]LogFormat{"": LogFormatText, "text": LogFormatText, "keyvalue": LogFormatKeyValue, "json": LogFormatJSON}[c.Format]
+		if !ok {
+			return nil, fmt.Errorf("unknown format %q", c.Format)
+		}
+		return NewLogReporter(LogConfig{
+			Registry:      r,
+			FlushInterval: interval,
+			DurationUnit:  unit,
+			Logger:        log.New(os.Stderr, "metrics: ", log.Lmicroseconds),
+			Format:        format,
+			OnlyChanged:   c.OnlyChanged,
+			Include:       c.Include,
+			Exclude:       c.Exclude,
+		}), nil
+	case "opentsdb":
+		oc := OpenTSDBConfig{
+			Registry:      r,
+			FlushInterval: interval,
+			DurationUnit:  unit,
+			Prefix:        c.Prefix,
+			Tags:          c.Tags,
+			URL:           url,
+			Filter:        filter,
+		}
+		if "" == url {
+			addr, err := net.ResolveTCPAddr("tcp", c.Address)
+			if nil != err {
+				return nil, err
+			}
+			oc.Addr = addr
+		}
+		return NewOpenTSDBReporter(oc), nil
+	case "remotewrite":
+		if "" == url {
+			return nil, fmt.Errorf("no url")
+		}
+		return NewRemoteWriteReporter(RemoteWriteConfig{
+			URL:           url,
+			Registry:      r,
+			FlushInterval: interval,
+			DurationUnit:  unit,
+			Prefix:        c.Prefix,
+			Labels:        c.Tags,
+			Headers:       headers,
+			Filter:        filter,
+		}), nil
+	case "pushgateway":
+		if "" == url || "" == c.Job {
+			return nil, fmt.Errorf("no url or job")
+		}
+		return newExporterReporter(r, NewPushgatewayClient(PushgatewayConfig{
+			URL:          url,
+			Job:          c.Job,
+			Grouping:     c.Tags,
+			Registry:     r,
+			DurationUnit: unit,
+			Prefix:       c.Prefix,
+			Headers:      headers,
+			Filter:       filter,
+		}), SchedulerConfig{Interval: interval}), nil
+	case "datadog":
+		if "" == apiKey {
+			return nil, fmt.Errorf("no apiKey")
+		}
+		return NewDatadogReporter(DatadogConfig{
+			APIKey:        apiKey,
+			URL:           url,
+			Registry:      r,
+			FlushInterval: interval,
+			DurationUnit:  unit,
+			Prefix:        c.Prefix,
+			Tags:          c.Tags,
+			Filter:        filter,
+		}), nil
+	case "statsd":
+		if "" == c.Address {
+			return nil, fmt.Errorf("no address")
+		}
+		if _, _, err := parseStatsDAddr(c.Address); nil != err {
+			return nil, err
+		}
+		return NewStatsDReporter(StatsDConfig{
+			Addr:          c.Address,
+			Registry:      r,
+			FlushInterval: interval,
+			DurationUnit:  unit,
+			Prefix:        c.Prefix,
+			WriteTimeout:  time.Duration(c.WriteTimeout),
+			Filter:        filter,
+		}), nil
+	}
+	return nil, fmt.Errorf("unknown type")
+}
@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadReportersConfig(t *testing.T) {
+	c, err := ReadReportersConfig(strings.NewReader(`{"reporters": [
+		{"type": "log", "interval": "1m", "durationUnit": "1ms", "format": "json", "include": ["http.*"]},
+		{"type": "remotewrite", "interval": "30s", "url": "http://vm:8428/api/v1/write", "tags": {"job": "relay"}}
+	]}`), nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if 2 != len(c.Reporters) {
+		t.Fatalf("c.Reporters: %v\n", c.Reporters)
+	}
+	if l := c.Reporters[0]; time.Minute != time.Duration(l.Interval) || time.Millisecond != time.Duration(l.DurationUnit) || "http.*" != l.Include[0] {
+		t.Errorf("c.Reporters[0]: %+v\n", l)
+	}
+	if "relay" != c.Reporters[1].Tags["job"] {
+		t.Errorf("c.Reporters[1].Tags: %v\n", c.Reporters[1].Tags)
+	}
+	if _, err := NewReportersFromConfig(c, NewRegistry()); nil != err {
+		t.Error(err)
+	}
+
+	if _, err := ReadReportersConfig(strings.NewReader(`{"reporters": [{"typo": "log"}]}`), nil); nil == err {
+		t.Error("unknown field: no error")
+	}
+	if _, err := ReadReportersConfig(strings.NewReader(`{"reporters": [{"interval": "soon"}]}`), nil); nil == err {
+		t.Error("bad interval: no error")
+	}
+}
+
+func TestNewReportersFromConfigErrors(t *testing.T) {
+	for _, rc := range []ReporterConfig{
+		{Type: "graphite", Interval: ConfigDuration(time.Second)},
+		{Type: "log"},
+		{Type: "log", Interval: ConfigDuration(time.Second), Format: "xml"},
+		{Type: "remotewrite", Interval: ConfigDuration(time.Second)},
+		{Type: "pushgateway", Interval: ConfigDuration(time.Second), URL: "http://pushgateway:9091"},
+		{Type: "datadog", Interval: ConfigDuration(time.Second)},
+		{Type: "statsd", Interval: ConfigDuration(time.Second)},
+		{Type: "statsd", Interval: ConfigDuration(time.Second), Address: "sctp://statsd:8125"},
+	} {
+		if _, err := NewReportersFromConfig(ReportersConfig{Reporters: []ReporterConfig{rc}}, nil); nil == err {
+			t.Errorf("%+v: no error\n", rc)
+		}
+	}
+}
+
+func TestNewReportersFromConfigRemoteWrite(t *testing.T) {
+	os.Setenv("METRICS_TEST_TOKEN", "x")
+	defer os.Unsetenv("METRICS_TEST_TOKEN")
+	var authorization string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		authorization = req.Header.Get("Authorization")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+	r := NewRegistry()
+	NewRegisteredCounter("foo", r).Inc(1)
+	reporters, err := NewReportersFromConfig(ReportersConfig{Reporters: []ReporterConfig{{
+		Type:     "remotewrite",
+		Interval: ConfigDuration(time.Minute),
+		URL:      ts.URL,
+		Headers:  map[string]string{"Authorization": "Bearer $METRICS_TEST_TOKEN"},
+	}}}, r)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if err := reporters[0].Once(); nil != err {
+		t.Fatal(err)
+	}
+	if "Bearer x" != authorization {
+		t.Errorf("authorization: Bearer x != %v\n", authorization)
+	}
+}
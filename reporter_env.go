@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// ReportersConfigFromEnv returns the configuration of the reporters enabled
+// by environment variables, for containerised programs which embed this
+// package but have no metrics configuration of their own:
+//
+//	GO_METRICS_LOG                text, keyvalue or json to log metrics to standard error
+//	GO_METRICS_OPENTSDB_ADDR      host:port of OpenTSDB's telnet interface
+//	GO_METRICS_OPENTSDB_URL       base URL of OpenTSDB's HTTP API, instead
+//	GO_METRICS_REMOTE_WRITE_URL   Prometheus remote-write endpoint
+//	GO_METRICS_PUSHGATEWAY_URL    Prometheus Pushgateway, with GO_METRICS_PUSHGATEWAY_JOB
+//	GO_METRICS_DATADOG_API_KEY    Datadog API key, with GO_METRICS_DATADOG_URL if not the default
+//	GO_METRICS_STATSD_ADDR        StatsD address, eg localhost:8125 or unix:///var/run/statsd.sock
+//
+// and, for every reporter enabled,
+//
+//	GO_METRICS_FLUSH_INTERVAL     flush interval, eg 30s, defaulting to 1m
+//	GO_METRICS_DURATION_UNIT      time conversion unit for durations, eg 1ms
+//	GO_METRICS_PREFIX             prefix to be prepended to metric names
+//	GO_METRICS_TAGS               tags added to every series, eg env=prod,region=eu
+//	GO_METRICS_INCLUDE            comma-separated patterns of the only names to report
+//	GO_METRICS_EXCLUDE            comma-separated patterns of names never to report
+//
+// It returns an error if a variable is malformed or names a backend this
+// package has no reporter for, eg GO_METRICS_GRAPHITE_ADDR.
+func ReportersConfigFromEnv() (ReportersConfig, error) {
+	var c ReportersConfig
+	for _, name := range []string{"GO_METRICS_GRAPHITE_ADDR"} {
+		if "" != os.Getenv(name) {
+			return c, fmt.Errorf("%s: no such reporter", name)
+		}
+	}
+	base := ReporterConfig{
+		Interval: ConfigDuration(time.Minute),
+		Prefix:   os.Getenv("GO_METRICS_PREFIX"),
+		Include:  envList("GO_METRICS_INCLUDE"),
+		Exclude:  envList("GO_METRICS_EXCLUDE"),
+	}
+	for name, d := range map[string]*ConfigDuration{
+		"GO_METRICS_FLUSH_INTERVAL": &base.Interval,
+		"GO_METRICS_DURATION_UNIT":  &base.DurationUnit,
+	} {
+		if s := os.Getenv(name); "" != s {
+			if err := d.UnmarshalText([]byte(s)); nil != err {
+				return c, fmt.Errorf("%s: %v", name, err)
+			}
+		}
+	}
+	if s := os.Getenv("GO_METRICS_TAGS"); "" != s {
+		base.Tags = make(map[string]string)
+		for _, tag := range envList("GO_METRICS_TAGS") {
+			kv := strings.SplitN(tag, "=", 2)
+			if 2 != len(kv) {
+				return c, fmt.Errorf("GO_METRICS_TAGS: %q is not key=value", tag)
+			}
+			base.Tags[kv[0]] = kv[1]
+		}
+	}
+
+	add := func(typ string, f func(*ReporterConfig)) {
+		rc := base
+		rc.Type = typ
+		f(&rc)
+		c.Reporters = append(c.Reporters, rc)
+	}
+	if s := os.Getenv("GO_METRICS_LOG"); "" != s {
+		add("log", func(rc *ReporterConfig) { rc.Format = s })
+	}
+	if addr, url := os.Getenv("GO_METRICS_OPENTSDB_ADDR"), os.Getenv("GO_METRICS_OPENTSDB_URL"); "" != addr || "" != url {
+		add("opentsdb", func(rc *ReporterConfig) { rc.Address, rc.URL = addr, url })
+	}
+	if s := os.Getenv("GO_METRICS_REMOTE_WRITE_URL"); "" != s {
+		add("remotewrite", func(rc *ReporterConfig) { rc.URL = s })
+	}
+	if s := os.Getenv("GO_METRICS_PUSHGATEWAY_URL"); "" != s {
+		add("pushgateway", func(rc *ReporterConfig) { rc.URL, rc.Job = s, os.Getenv("GO_METRICS_PUSHGATEWAY_JOB") })
+	}
+	if s := os.Getenv("GO_METRICS_DATADOG_API_KEY"); "" != s {
+		add("datadog", func(rc *ReporterConfig) { rc.APIKey, rc.URL = s, os.Getenv("GO_METRICS_DATADOG_URL") })
+	}
+	if s := os.Getenv("GO_METRICS_STATSD_ADDR"); "" != s {
+		add("statsd", func(rc *ReporterConfig) { rc.Address = s })
+	}
+	return c, nil
+}
+
+// StartReportersFromEnv constructs and starts the reporters of the
+// DefaultRegistry enabled by environment variables, as described by
+// ReportersConfigFromEnv, and returns them so that they can be stopped.  It
+// returns none if no reporter is enabled.
+func StartReportersFromEnv() ([]*Reporter, error) {
+	c, err := ReportersConfigFromEnv()
+	if nil != err {
+		return nil, err
+	}
+	reporters, err := NewReportersFromConfig(c, DefaultRegistry)
+	if nil != err {
+		return nil, err
+	}
+	for _, r := range reporters {
+		r.Start()
+	}
+	return reporters, nil
+}
+
+// envList returns the comma-separated values of the environment variable by
+// the given name, trimmed of spaces, or nil if it's unset or empty.
+func envList(name string) []string {
+	var values []string
+	for _, v := range strings.Split(os.Getenv(name), ",") {
+		if v = strings.TrimSpace(v); "" != v {
+			values = append(values, v)
+		}
+	}
+	return values
+}
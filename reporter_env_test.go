@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// setenv sets the given environment variables and returns a function which
+// unsets them.
+func setenv(env map[string]string) func() {
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+	return func() {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+	}
+}
+
+func TestReportersConfigFromEnv(t *testing.T) {
+	defer setenv(map[string]string{
+		"GO_METRICS_FLUSH_INTERVAL":   "30s",
+		"GO_METRICS_PREFIX":           "relay.",
+		"GO_METRICS_TAGS":             "env=prod, region=eu",
+		"GO_METRICS_EXCLUDE":          "go.*, runtime.*",
+		"GO_METRICS_LOG":              "json",
+		"GO_METRICS_REMOTE_WRITE_URL": "http://vm:8428/api/v1/write",
+		"GO_METRICS_STATSD_ADDR":      "unix:///var/run/statsd.sock",
+	})()
+	c, err := ReportersConfigFromEnv()
+	if nil != err {
+		t.Fatal(err)
+	}
+	if 3 != len(c.Reporters) {
+		t.Fatalf("c.Reporters: %+v\n", c.Reporters)
+	}
+	if l := c.Reporters[0]; "log" != l.Type || "json" != l.Format || 30*time.Second != time.Duration(l.Interval) {
+		t.Errorf("c.Reporters[0]: %+v\n", l)
+	}
+	rw := c.Reporters[1]
+	if "remotewrite" != rw.Type || "http://vm:8428/api/v1/write" != rw.URL || "relay." != rw.Prefix {
+		t.Errorf("c.Reporters[1]: %+v\n", rw)
+	}
+	if "prod" != rw.Tags["env"] || "eu" != rw.Tags["region"] {
+		t.Errorf("c.Reporters[1].Tags: %v\n", rw.Tags)
+	}
+	if 2 != len(rw.Exclude) || "runtime.*" != rw.Exclude[1] {
+		t.Errorf("c.Reporters[1].Exclude: %q\n", rw.Exclude)
+	}
+	if s := c.Reporters[2]; "statsd" != s.Type || "unix:///var/run/statsd.sock" != s.Address {
+		t.Errorf("c.Reporters[2]: %+v\n", s)
+	}
+	if _, err := NewReportersFromConfig(c, NewRegistry()); nil != err {
+		t.Error(err)
+	}
+}
+
+func TestReportersConfigFromEnvNone(t *testing.T) {
+	reporters, err := StartReportersFromEnv()
+	if nil != err {
+		t.Fatal(err)
+	}
+	if 0 != len(reporters) {
+		t.Errorf("reporters: %v\n", reporters)
+	}
+}
+
+func TestReportersConfigFromEnvErrors(t *testing.T) {
+	for _, env := range []map[string]string{
+		{"GO_METRICS_GRAPHITE_ADDR": "graphite:2003"},
+		{"GO_METRICS_FLUSH_INTERVAL": "soon"},
+		{"GO_METRICS_TAGS": "env"},
+	} {
+		unset := setenv(env)
+		if _, err := ReportersConfigFromEnv(); nil == err {
+			t.Errorf("%v: no error\n", env)
+		}
+		unset()
+	}
+}
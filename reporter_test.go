@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Every client is an Exporter, so that it can be scheduled or wrapped.
+var (
+	_ Exporter = (*AggregationClient)(nil)
+	_ Exporter = (*CloudMonitoringClient)(nil)
+	_ Exporter = (*DatadogClient)(nil)
+	_ Exporter = (*EMFWriter)(nil)
+	_ Exporter = (*NewRelicClient)(nil)
+	_ Exporter = (*PushgatewayClient)(nil)
+)
+
+func TestReporterFlushNow(t *testing.T) {
+	n := 0
+	rep := NewReporter(time.Hour, func() { n++ })
+	rep.FlushNow()
+	rep.FlushNow()
+	if 2 != n {
+		t.Errorf("n: 2 != %v\n", n)
+	}
+}
+
+func TestReporterOnce(t *testing.T) {
+	status := int32(http.StatusNoContent)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer ts.Close()
+	r := NewRegistry()
+	NewRegisteredCounter("foo", r).Inc(47)
+	rep := NewRemoteWriteReporter(RemoteWriteConfig{URL: ts.URL, Registry: r, FlushInterval: time.Hour})
+	if err := rep.Once(); nil != err {
+		t.Fatal(err)
+	}
+	atomic.StoreInt32(&status, http.StatusServiceUnavailable)
+	if err := rep.Once(); nil == err {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	if err := NewJSONReporter(r, time.Hour, time.Nanosecond, &b).Once(); nil != err {
+		t.Fatal(err)
+	}
+	if s := b.String(); `{"foo":{"count":47}}`+"\n" != s {
+		t.Errorf("unexpected output: %q", s)
+	}
+}
+
+func TestReporterStopFlushes(t *testing.T) {
+	r := NewRegistry()
+	c := NewRegisteredCounter("foo", r)
+	var buf bytes.Buffer
+	rep := NewWriteReporter(r, time.Hour, time.Nanosecond, &buf)
+	rep.Start()
+	rep.Start()
+	c.Inc(47)
+	rep.Stop()
+	if !strings.Contains(buf.String(), "counter foo") {
+		t.Errorf("final flush: %q\n", buf.String())
+	}
+	rep.Stop()
+}
+
+func TestReporterRunFlushesWhenDone(t *testing.T) {
+	n := 0
+	rep := NewReporter(time.Hour, func() { n++ })
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rep.Run(ctx)
+	if 1 != n {
+		t.Errorf("n: 1 != %v\n", n)
+	}
+}
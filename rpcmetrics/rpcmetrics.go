@@ -0,0 +1,118 @@
+// Package rpcmetrics records the standard metrics of RPCs, eg gRPC calls, in
+// a go-metrics registry.  It has no dependencies beyond the standard
+// library, so it's called from interceptors written against the RPC
+// framework; see the README for gRPC's.
+package rpcmetrics
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/go-metrics"
+)
+
+// Config provides a container with configuration parameters for a Recorder.
+type Config struct {
+	Registry metrics.Registry // Registry in which to register the metrics, defaulting to metrics.DefaultRegistry
+	Prefix   string           // Prefix of the metrics' names, defaulting to "rpc", eg "grpc.server" or "grpc.client"
+}
+
+// Recorder records, for each method called, the metrics
+//
+//	<prefix>.<method>.Started      a Counter of the calls started
+//	<prefix>.<method>.Errors       a Counter of the calls which failed
+//	<prefix>.<method>.InFlight     a GaugeCounter of the calls under way
+//	<prefix>.<method>.Duration     a Timer of the time taken by calls
+//	<prefix>.<method>.MsgSent      a Counter of the messages sent
+//	<prefix>.<method>.MsgReceived  a Counter of the messages received
+//
+// registering them on a method's first call.  Full gRPC method names become
+// eg grpc.server.pkg_Service.Method.Duration for /pkg.Service/Method.
+type Recorder struct {
+	config  Config
+	mutex   sync.Mutex
+	methods map[string]*methodMetrics
+}
+
+type methodMetrics struct {
+	started     metrics.Counter
+	errors      metrics.Counter
+	inFlight    metrics.GaugeCounter
+	duration    metrics.Timer
+	msgSent     metrics.Counter
+	msgReceived metrics.Counter
+}
+
+// NewRecorder constructs a new Recorder.
+func NewRecorder(c Config) *Recorder {
+	if nil == c.Registry {
+		c.Registry = metrics.DefaultRegistry
+	}
+	if "" == c.Prefix {
+		c.Prefix = "rpc"
+	}
+	return &Recorder{config: c, methods: make(map[string]*methodMetrics)}
+}
+
+// Start records the start of a call of the given method, returning the Call
+// on which to record its messages and end.
+func (r *Recorder) Start(method string) *Call {
+	mm := r.method(method)
+	mm.started.Inc(1)
+	mm.inFlight.Inc(1)
+	return &Call{metrics: mm, start: time.Now()}
+}
+
+// method returns the metrics of the given method, registering them if they
+// haven't been already.
+func (r *Recorder) method(method string) *methodMetrics {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if mm, ok := r.methods[method]; ok {
+		return mm
+	}
+	name := strings.Replace(strings.TrimPrefix(method, "/"), ".", "_", -1)
+	reg, prefix := r.config.Registry, r.config.Prefix+"."+strings.Replace(name, "/", ".", -1)+"."
+	mm := &methodMetrics{
+		started:     metrics.GetOrRegisterCounter(prefix+"Started", reg),
+		errors:      metrics.GetOrRegisterCounter(prefix+"Errors", reg),
+		inFlight:    metrics.GetOrRegisterGaugeCounter(prefix+"InFlight", reg),
+		duration:    metrics.GetOrRegisterTimer(prefix+"Duration", reg),
+		msgSent:     metrics.GetOrRegisterCounter(prefix+"MsgSent", reg),
+		msgReceived: metrics.GetOrRegisterCounter(prefix+"MsgReceived", reg),
+	}
+	r.methods[method] = mm
+	return mm
+}
+
+// Call is a call under way, started with Recorder.Start.  Its methods are
+// safe to call from several goroutines, as a stream's sender and receiver
+// are.
+type Call struct {
+	metrics *methodMetrics
+	start   time.Time
+	once    sync.Once
+}
+
+// Sent records a message sent, eg by a stream's SendMsg.
+func (c *Call) Sent() {
+	c.metrics.msgSent.Inc(1)
+}
+
+// Received records a message received, eg by a stream's RecvMsg.
+func (c *Call) Received() {
+	c.metrics.msgReceived.Inc(1)
+}
+
+// Done records the end of the call, as having failed if err isn't nil.
+// Only the first call of Done is recorded.
+func (c *Call) Done(err error) {
+	c.once.Do(func() {
+		c.metrics.duration.UpdateSince(c.start)
+		c.metrics.inFlight.Dec(1)
+		if nil != err {
+			c.metrics.errors.Inc(1)
+		}
+	})
+}
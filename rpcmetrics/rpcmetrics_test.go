@@ -0,0 +1,41 @@
+package rpcmetrics
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/launchdarkly/go-metrics"
+)
+
+func TestRecorder(t *testing.T) {
+	r := metrics.NewRegistry()
+	rec := NewRecorder(Config{Registry: r, Prefix: "grpc.server"})
+	c := rec.Start("/pkg.Service/Method")
+	if count := r.Get("grpc.server.pkg_Service.Method.InFlight").(metrics.GaugeCounter).Count(); 1 != count {
+		t.Errorf("InFlight: 1 != %v\n", count)
+	}
+	c.Received()
+	c.Sent()
+	c.Sent()
+	c.Done(nil)
+	c = rec.Start("/pkg.Service/Method")
+	c.Done(errors.New("unavailable"))
+	c.Done(nil)
+
+	for name, want := range map[string]int64{
+		"grpc.server.pkg_Service.Method.Started":     2,
+		"grpc.server.pkg_Service.Method.Errors":      1,
+		"grpc.server.pkg_Service.Method.MsgSent":     2,
+		"grpc.server.pkg_Service.Method.MsgReceived": 1,
+	} {
+		if count := r.Get(name).(metrics.Counter).Count(); want != count {
+			t.Errorf("%s: %v != %v\n", name, want, count)
+		}
+	}
+	if count := r.Get("grpc.server.pkg_Service.Method.InFlight").(metrics.GaugeCounter).Count(); 0 != count {
+		t.Errorf("InFlight: 0 != %v\n", count)
+	}
+	if count := r.Get("grpc.server.pkg_Service.Method.Duration").(metrics.Timer).Count(); 2 != count {
+		t.Errorf("Duration: 2 != %v\n", count)
+	}
+}
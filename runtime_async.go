@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RuntimeMemStatsCaptureConfig provides a container with configuration
+// parameters for StartRuntimeMemStatsCapture.
+type RuntimeMemStatsCaptureConfig struct {
+	Interval time.Duration // Capture interval
+	Deadline time.Duration // Longest Refresh waits for a capture before giving up, defaulting to 10ms
+}
+
+// RuntimeMemStatsCapture captures the Go runtime statistics on its own
+// goroutine, so that the stop-the-world pause of runtime.ReadMemStats never
+// delays a reporter's flush.  Reporters read the last values captured, and
+// the gauge runtime.ReadMemStatsAge says how many seconds old those are.
+// Nothing else should capture the runtime statistics meanwhile, eg with
+// CaptureRuntimeMemStats.
+type RuntimeMemStatsCapture struct {
+	captured int64 // Unix nanoseconds of the last capture, accessed atomically and so first for alignment
+	config   RuntimeMemStatsCaptureConfig
+	requests chan chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+// StartRuntimeMemStatsCapture captures new values for the Go runtime
+// statistics now and then every c.Interval on a new goroutine, until Stop is
+// called.  Giving a registry which has not been given to
+// RegisterRuntimeMemStats will panic.  It registers the gauge
+// runtime.ReadMemStatsAge in r.
+func StartRuntimeMemStatsCapture(r Registry, c RuntimeMemStatsCaptureConfig) *RuntimeMemStatsCapture {
+	if c.Deadline <= 0 {
+		c.Deadline = 10 * time.Millisecond
+	}
+	rc := &RuntimeMemStatsCapture{
+		config:   c,
+		requests: make(chan chan struct{}),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	r.Register("runtime.ReadMemStatsAge", NewFunctionalGaugeFloat64(rc.age))
+	CaptureRuntimeMemStatsOnce(r)
+	atomic.StoreInt64(&rc.captured, time.Now().UnixNano())
+	go rc.run(r)
+	return rc
+}
+
+func (rc *RuntimeMemStatsCapture) run(r Registry) {
+	defer close(rc.done)
+	t := time.NewTicker(rc.config.Interval)
+	defer t.Stop()
+	for {
+		var captured chan struct{}
+		select {
+		case <-t.C:
+		case captured = <-rc.requests:
+		case <-rc.stop:
+			return
+		}
+		CaptureRuntimeMemStatsOnce(r)
+		atomic.StoreInt64(&rc.captured, time.Now().UnixNano())
+		if nil != captured {
+			close(captured)
+		}
+	}
+}
+
+// age returns the seconds since the last capture.
+func (rc *RuntimeMemStatsCapture) age() float64 {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&rc.captured))).Seconds()
+}
+
+// Refresh asks for a capture now, eg just before a flush, and waits for it
+// until the configured deadline, returning whether it finished in time.
+// If not, reporters read the last values captured.
+func (rc *RuntimeMemStatsCapture) Refresh() bool {
+	t := time.NewTimer(rc.config.Deadline)
+	defer t.Stop()
+	captured := make(chan struct{})
+	select {
+	case rc.requests <- captured:
+	case <-rc.done:
+		return false
+	case <-t.C:
+		return false // a capture is under way
+	}
+	select {
+	case <-captured:
+		return true
+	case <-t.C:
+		return false
+	}
+}
+
+// Stop stops capturing, returning once any capture under way is done.
+func (rc *RuntimeMemStatsCapture) Stop() {
+	rc.once.Do(func() { close(rc.stop) })
+	<-rc.done
+}
@@ -0,0 +1,9 @@
+// +build !go1.8
+
+package metrics
+
+import "runtime"
+
+func numForcedGC(memStats *runtime.MemStats) uint32 {
+	return 0
+}
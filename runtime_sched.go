@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"math"
+	"time"
+)
+
+var (
+	schedMetrics struct {
+		Latencies HistogramFloat64
+	}
+	schedLatencies struct {
+		sample     *BucketSampleFloat64
+		boundaries []float64 // The runtime's, one more than the counts
+		counts     []uint64  // The runtime's at the last capture
+	}
+)
+
+// Capture new values for the Go scheduler latencies exported in
+// runtime/metrics as /sched/latencies:seconds.  This is designed to be called
+// as a goroutine.
+func CaptureSchedLatencies(r Registry, d time.Duration) {
+	for _ = range time.Tick(d) {
+		CaptureSchedLatenciesOnce(r)
+	}
+}
+
+// Capture new values for the Go scheduler latencies exported in
+// runtime/metrics as /sched/latencies:seconds.  This is designed to be called
+// in a background goroutine.  Giving a registry which has not been given to
+// RegisterSchedLatencies will panic.
+//
+// The histogram runtime.SchedLatencies then holds only the latencies of the
+// goroutines scheduled since the last capture, each counted at the middle of
+// its runtime bucket, so that its percentiles track scheduler starvation as
+// it happens rather than since the process started.
+func CaptureSchedLatenciesOnce(r Registry) {
+	boundaries, counts, ok := readSchedLatencies()
+	if !ok || nil == schedLatencies.sample {
+		return
+	}
+	if len(boundaries) != len(schedLatencies.boundaries) {
+		return // can't happen, the runtime's buckets never change
+	}
+	b := bucketCounts{
+		bounds: schedLatencies.sample.buckets.bounds,
+		counts: make([]int64, len(schedLatencies.sample.buckets.bounds)+1),
+	}
+	for i, count := range counts {
+		var last uint64
+		if i < len(schedLatencies.counts) {
+			last = schedLatencies.counts[i]
+		}
+		if count > last {
+			b.addN(schedLatencyValue(boundaries[i], boundaries[i+1]), int64(count-last))
+		}
+	}
+	schedLatencies.sample.set(b)
+	schedLatencies.counts = append(schedLatencies.counts[:0], counts...)
+}
+
+// Register runtime.SchedLatencies, a HistogramFloat64 of the Go scheduler
+// latencies in seconds exported in runtime/metrics, ie how long goroutines
+// wait runnable before they run, bucketed as the runtime buckets them.  It's
+// left empty before Go 1.17, which first exported them.
+func RegisterSchedLatencies(r Registry) {
+	boundaries, counts, ok := readSchedLatencies()
+	var bounds []float64
+	if ok {
+		for _, bound := range boundaries[1:] {
+			if 0 < bound && !math.IsInf(bound, 1) {
+				bounds = append(bounds, bound)
+			}
+		}
+	}
+	if 0 == len(bounds) {
+		bounds = ExponentialBuckets(1e-6, 10, 7)
+	}
+	sample := NewBucketSampleFloat64(bounds)
+	schedMetrics.Latencies = NewHistogramFloat64(sample)
+	schedLatencies.sample, _ = sample.(*BucketSampleFloat64)
+	schedLatencies.boundaries = boundaries
+	schedLatencies.counts = append([]uint64(nil), counts...)
+
+	r.Register("runtime.SchedLatencies", schedMetrics.Latencies)
+}
+
+// schedLatencyValue returns the value at which to count the latencies in the
+// runtime bucket [lo, hi), its middle unless it's unbounded.
+func schedLatencyValue(lo, hi float64) float64 {
+	if math.IsInf(hi, 1) {
+		return math.Nextafter(lo, hi) // overflow
+	}
+	if lo < 0 {
+		lo = 0
+	}
+	return lo + (hi-lo)/2
+}
@@ -0,0 +1,17 @@
+// +build go1.17
+
+package metrics
+
+import rtmetrics "runtime/metrics"
+
+// readSchedLatencies returns the boundaries and counts of the runtime's
+// histogram of scheduler latencies in seconds, or false if there's none.
+func readSchedLatencies() ([]float64, []uint64, bool) {
+	samples := []rtmetrics.Sample{{Name: "/sched/latencies:seconds"}}
+	rtmetrics.Read(samples)
+	if rtmetrics.KindFloat64Histogram != samples[0].Value.Kind() {
+		return nil, nil, false
+	}
+	h := samples[0].Value.Float64Histogram()
+	return h.Buckets, h.Counts, true
+}
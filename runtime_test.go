@@ -2,6 +2,7 @@ package metrics
 
 import (
 	"runtime"
+	"sync"
 	"testing"
 	"time"
 )
@@ -18,6 +19,7 @@ func BenchmarkRuntimeMemStats(b *testing.B) {
 func TestRuntimeMemStats(t *testing.T) {
 	r := NewRegistry()
 	RegisterRuntimeMemStats(r)
+	runtime.GC() // Finish any cycle left in progress by earlier tests.
 	CaptureRuntimeMemStatsOnce(r)
 	zero := runtimeMetrics.MemStats.PauseNs.Count() // Get a "zero" since GC may have run before these tests.
 	runtime.GC()
@@ -86,3 +88,60 @@ func testRuntimeMemStatsBlocking(ch chan int) {
 		}
 	}
 }
+
+func TestRuntimeMemStatsCapture(t *testing.T) {
+	r := NewRegistry()
+	RegisterRuntimeMemStats(r)
+	rc := StartRuntimeMemStatsCapture(r, RuntimeMemStatsCaptureConfig{Interval: time.Hour, Deadline: time.Second})
+	if v := runtimeMetrics.NumGoroutine.Value(); v < 1 {
+		t.Errorf("NumGoroutine: %v\n", v)
+	}
+	time.Sleep(10 * time.Millisecond)
+	age := r.Get("runtime.ReadMemStatsAge").(GaugeFloat64)
+	if v := age.Value(); v < 0.01 {
+		t.Errorf("ReadMemStatsAge: %v\n", v)
+	}
+	if !rc.Refresh() {
+		t.Fatal("Refresh: not captured in time")
+	}
+	if v := age.Value(); v >= 0.01 {
+		t.Errorf("ReadMemStatsAge after Refresh: %v\n", v)
+	}
+	rc.Stop()
+	if rc.Refresh() {
+		t.Error("Refresh after Stop: captured")
+	}
+}
+
+func TestSchedLatencies(t *testing.T) {
+	r := NewRegistry()
+	RegisterSchedLatencies(r)
+	if _, _, ok := readSchedLatencies(); !ok {
+		t.Skip("no /sched/latencies:seconds before Go 1.17")
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 1000; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runtime.Gosched()
+		}()
+	}
+	wg.Wait()
+	CaptureSchedLatenciesOnce(r)
+	h := r.Get("runtime.SchedLatencies").(HistogramFloat64)
+	if count := h.Count(); count < 1 {
+		t.Fatalf("runtime.SchedLatencies: 0 < %v\n", count)
+	}
+	var count int64
+	_, counts := h.Sample().(*BucketSampleFloat64).Buckets()
+	for _, c := range counts {
+		count += c
+	}
+	if h.Count() != count {
+		t.Errorf("bucket counts: %v != %v\n", h.Count(), count)
+	}
+	if min := h.Min(); min < 0 {
+		t.Errorf("h.Min(): 0 <= %v\n", min)
+	}
+}
@@ -2,6 +2,7 @@ package metrics
 
 import (
 	"math"
+	"math/bits"
 	"math/rand"
 	"sort"
 	"sync"
@@ -29,18 +30,60 @@ type Sample interface {
 	Variance() float64
 }
 
+// ValuesAppenders are Samples which can append their values to a slice the
+// caller provides, so that reporters can reuse one buffer across metrics and
+// flushes rather than allocating a copy each time as Values does.
+type ValuesAppender interface {
+	AppendValues([]int64) []int64
+}
+
+// AppendSampleValues appends the values in s to dst and returns the extended
+// slice, through s's AppendValues method if it's a ValuesAppender and
+// otherwise by copying its Values.
+func AppendSampleValues(dst []int64, s Sample) []int64 {
+	if a, ok := s.(ValuesAppender); ok {
+		return a.AppendValues(dst)
+	}
+	return append(dst, s.Values()...)
+}
+
+// TimestampedSamples are Samples which remember when each of their values was
+// updated, so that reporters can compute statistics over just the values
+// updated since their last report and outliers can be traced to when they
+// occurred.
+type TimestampedSample interface {
+	Sample
+	TimestampedValues() []TimestampedValue
+	ValuesSince(time.Time) []int64
+}
+
+// TimestampedValue is a value in a TimestampedSample and the time it was
+// updated.
+type TimestampedValue struct {
+	Time  time.Time
+	Value int64
+}
+
 // ExpDecaySample is an exponentially-decaying sample using a forward-decaying
 // priority reservoir.  See Cormode et al's "Forward Decay: A Practical Time
 // Decay Model for Streaming Systems".
 //
 // <http://dimacs.rutgers.edu/~graham/pubs/papers/fwddecay.pdf>
+//
+// It is a TimestampedSample.
 type ExpDecaySample struct {
-	alpha         float64
-	count         int64
-	mutex         sync.Mutex
-	reservoirSize int
-	t0, t1        time.Time
-	values        *expDecaySampleHeap
+	alpha           float64
+	count           int64
+	mutex           sync.Mutex
+	reservoirSize   int
+	rng             *rand.Rand
+	clock           Clock
+	t0, t1          time.Time
+	values          *expDecaySampleHeap
+	rescaleInterval time.Duration
+	maxAge          time.Duration
+	stable          bool
+	seq             uint64 // Of the last update, if stable
 }
 
 // NewExpDecaySample constructs a new exponentially-decaying sample with the
@@ -49,23 +92,103 @@ func NewExpDecaySample(reservoirSize int, alpha float64) Sample {
 	if UseNilMetrics {
 		return NilSample{}
 	}
+	return newExpDecaySample(reservoirSize, alpha)
+}
+
+func newExpDecaySample(reservoirSize int, alpha float64) *ExpDecaySample {
 	s := &ExpDecaySample{
-		alpha:         alpha,
-		reservoirSize: reservoirSize,
-		t0:            time.Now(),
-		values:        newExpDecaySampleHeap(reservoirSize),
+		alpha:           alpha,
+		reservoirSize:   reservoirSize,
+		clock:           DefaultClock,
+		t0:              DefaultClock.Now(),
+		values:          newExpDecaySampleHeap(reservoirSize),
+		rescaleInterval: rescaleThreshold,
+	}
+	s.t1 = s.t0.Add(s.rescaleInterval)
+	return s
+}
+
+// NewExpDecaySampleWithRand constructs a new exponentially-decaying sample
+// like NewExpDecaySample but draws priorities from r rather than from the
+// shared source in math/rand.  r is only used while holding the sample's lock,
+// so it needn't be safe for concurrent use and must not be shared with other
+// samples.
+func NewExpDecaySampleWithRand(reservoirSize int, alpha float64, r *rand.Rand) Sample {
+	s := NewExpDecaySample(reservoirSize, alpha)
+	if es, ok := s.(*ExpDecaySample); ok {
+		es.rng = r
 	}
-	s.t1 = s.t0.Add(rescaleThreshold)
 	return s
 }
 
+// NewExpDecaySampleWithClock constructs a new exponentially-decaying sample
+// like NewExpDecaySample but which reads the time from the given Clock.
+func NewExpDecaySampleWithClock(reservoirSize int, alpha float64, c Clock) Sample {
+	s := NewExpDecaySample(reservoirSize, alpha)
+	if es, ok := s.(*ExpDecaySample); ok {
+		es.clock = c
+		es.t0 = c.Now()
+		es.t1 = es.t0.Add(es.rescaleInterval)
+	}
+	return s
+}
+
+// ExpDecaySampleOptions configures the samples constructed by
+// NewExpDecaySampleWithOptions and NewExpDecaySampleFloat64WithOptions.
+type ExpDecaySampleOptions struct {
+	// RescaleInterval is how long after the last rescale a sample rebases the
+	// priorities of its values, on the next update or snapshot, defaulting to
+	// an hour.  Long-lived, low-traffic metrics may want it shorter so that
+	// their reservoirs don't grow stale between updates.
+	RescaleInterval time.Duration
+
+	// MaxAge, if positive, is how long values stay in a sample: each snapshot
+	// first drops those updated longer ago, so that the sample decays to
+	// empty once traffic stops rather than reporting the last values forever.
+	MaxAge time.Duration
+
+	// StablePriorities, if set, clamps priorities which overflow, or which
+	// a random draw of zero makes infinite, to the largest finite float64,
+	// and breaks ties between equal priorities by evicting the value added
+	// first, so that bursts of updates at one instant keep a fair and
+	// deterministic selection.
+	StablePriorities bool
+}
+
+// NewExpDecaySampleWithOptions constructs a new exponentially-decaying sample
+// like NewExpDecaySample but configured by the given options.
+func NewExpDecaySampleWithOptions(reservoirSize int, alpha float64, o ExpDecaySampleOptions) Sample {
+	s := NewExpDecaySample(reservoirSize, alpha)
+	if es, ok := s.(*ExpDecaySample); ok {
+		if o.RescaleInterval > 0 {
+			es.rescaleInterval = o.RescaleInterval
+			es.t1 = es.t0.Add(es.rescaleInterval)
+		}
+		es.maxAge = o.MaxAge
+		es.stable = o.StablePriorities
+	}
+	return s
+}
+
+// AppendValues appends the values in the sample to dst and returns the
+// extended slice, so that callers can reuse dst rather than allocating a copy
+// each time as Values does.
+func (s *ExpDecaySample) AppendValues(dst []int64) []int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, v := range s.values.Values() {
+		dst = append(dst, v.v)
+	}
+	return dst
+}
+
 // Clear clears all samples.
 func (s *ExpDecaySample) Clear() {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	s.count = 0
-	s.t0 = time.Now()
-	s.t1 = s.t0.Add(rescaleThreshold)
+	s.t0 = s.clock.Now()
+	s.t1 = s.t0.Add(s.rescaleInterval)
 	s.values.Clear()
 }
 
@@ -80,29 +203,39 @@ func (s *ExpDecaySample) Count() int64 {
 // Max returns the maximum value in the sample, which may not be the maximum
 // value ever to be part of the sample.
 func (s *ExpDecaySample) Max() int64 {
-	return SampleMax(s.Values())
+	buf := s.pooledValues()
+	defer int64Buffers.Put(buf)
+	return SampleMax(*buf)
 }
 
 // Mean returns the mean of the values in the sample.
 func (s *ExpDecaySample) Mean() float64 {
-	return SampleMean(s.Values())
+	buf := s.pooledValues()
+	defer int64Buffers.Put(buf)
+	return SampleMean(*buf)
 }
 
 // Min returns the minimum value in the sample, which may not be the minimum
 // value ever to be part of the sample.
 func (s *ExpDecaySample) Min() int64 {
-	return SampleMin(s.Values())
+	buf := s.pooledValues()
+	defer int64Buffers.Put(buf)
+	return SampleMin(*buf)
 }
 
 // Percentile returns an arbitrary percentile of values in the sample.
 func (s *ExpDecaySample) Percentile(p float64) float64 {
-	return SamplePercentile(s.Values(), p)
+	buf := s.pooledValues()
+	defer int64Buffers.Put(buf)
+	return SamplePercentile(*buf, p)
 }
 
 // Percentiles returns a slice of arbitrary percentiles of values in the
 // sample.
 func (s *ExpDecaySample) Percentiles(ps []float64) []float64 {
-	return SamplePercentiles(s.Values(), ps)
+	buf := s.pooledValues()
+	defer int64Buffers.Put(buf)
+	return SamplePercentiles(*buf, ps)
 }
 
 // Size returns the size of the sample, which is at most the reservoir size.
@@ -114,12 +247,19 @@ func (s *ExpDecaySample) Size() int {
 
 // Snapshot returns a read-only copy of the sample.
 func (s *ExpDecaySample) Snapshot() Sample {
+	defer observeSampleSnapshot(selfStart())
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	vals := s.values.Values()
-	values := make([]int64, len(vals))
-	for i, v := range vals {
-		values[i] = v.v
+	now := s.clock.Now()
+	if now.After(s.t1) {
+		s.rescale(now)
+	}
+	if s.maxAge > 0 {
+		s.expire(now.Add(-s.maxAge))
+	}
+	values := make([]int64, 0, s.values.Size())
+	for _, v := range s.values.Values() {
+		values = append(values, v.v)
 	}
 	return &SampleSnapshot{
 		count:  s.count,
@@ -129,34 +269,72 @@ func (s *ExpDecaySample) Snapshot() Sample {
 
 // StdDev returns the standard deviation of the values in the sample.
 func (s *ExpDecaySample) StdDev() float64 {
-	return SampleStdDev(s.Values())
+	buf := s.pooledValues()
+	defer int64Buffers.Put(buf)
+	return SampleStdDev(*buf)
 }
 
 // Sum returns the sum of the values in the sample.
 func (s *ExpDecaySample) Sum() int64 {
-	return SampleSum(s.Values())
+	buf := s.pooledValues()
+	defer int64Buffers.Put(buf)
+	return SampleSum(*buf)
+}
+
+// TimestampedValues returns a copy of the values in the sample with the
+// times they were updated, oldest first.
+func (s *ExpDecaySample) TimestampedValues() []TimestampedValue {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	values := s.values.Values()
+	tvs := make([]TimestampedValue, len(values))
+	for i, v := range values {
+		tvs[i] = TimestampedValue{Time: time.Unix(0, v.t), Value: v.v}
+	}
+	sort.SliceStable(tvs, func(i, j int) bool { return tvs[i].Time.Before(tvs[j].Time) })
+	return tvs
 }
 
 // Update samples a new value.
 func (s *ExpDecaySample) Update(v int64) {
-	s.update(time.Now(), v)
+	s.update(s.clock.Now(), v)
 }
 
 // Values returns a copy of the values in the sample.
 func (s *ExpDecaySample) Values() []int64 {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-	vals := s.values.Values()
-	values := make([]int64, len(vals))
-	for i, v := range vals {
-		values[i] = v.v
+	return s.AppendValues(make([]int64, 0, s.Size()))
+}
+
+// ValuesSince returns a copy of the values in the sample updated at or
+// after the given time, oldest first.
+func (s *ExpDecaySample) ValuesSince(t time.Time) []int64 {
+	var values []int64
+	for _, tv := range s.TimestampedValues() {
+		if !tv.Time.Before(t) {
+			values = append(values, tv.Value)
+		}
 	}
 	return values
 }
 
 // Variance returns the variance of the values in the sample.
 func (s *ExpDecaySample) Variance() float64 {
-	return SampleVariance(s.Values())
+	buf := s.pooledValues()
+	defer int64Buffers.Put(buf)
+	return SampleVariance(*buf)
+}
+
+// int64Buffers holds the slices ExpDecaySample copies its values into to
+// compute statistics, so that reading them from a live histogram doesn't
+// allocate.
+var int64Buffers = sync.Pool{New: func() interface{} { return new([]int64) }}
+
+// pooledValues copies the values into a buffer from int64Buffers, which the
+// caller must put back once it's done with them.
+func (s *ExpDecaySample) pooledValues() *[]int64 {
+	buf := int64Buffers.Get().(*[]int64)
+	*buf = s.AppendValues((*buf)[:0])
+	return buf
 }
 
 // update samples a new value at a particular timestamp.  This is a method all
@@ -168,18 +346,45 @@ func (s *ExpDecaySample) update(t time.Time, v int64) {
 	if s.values.Size() == s.reservoirSize {
 		s.values.Pop()
 	}
+	k := math.Exp(t.Sub(s.t0).Seconds()*s.alpha) / randFloat64(s.rng)
+	if s.stable {
+		k = stablePriority(k)
+		s.seq++
+	}
 	s.values.Push(expDecaySample{
-		k: math.Exp(t.Sub(s.t0).Seconds()*s.alpha) / rand.Float64(),
-		v: v,
+		k:   k,
+		seq: s.seq,
+		t:   t.UnixNano(),
+		v:   v,
 	})
 	if t.After(s.t1) {
-		values := s.values.Values()
-		t0 := s.t0
-		s.values.Clear()
-		s.t0 = t
-		s.t1 = s.t0.Add(rescaleThreshold)
-		for _, v := range values {
-			v.k = v.k * math.Exp(-s.alpha*s.t0.Sub(t0).Seconds())
+		s.rescale(t)
+	}
+}
+
+// rescale rebases the priorities of the values in the sample on the given
+// time, which keeps them from overflowing.  The caller must hold the lock.
+func (s *ExpDecaySample) rescale(t time.Time) {
+	values := s.values.Values()
+	t0 := s.t0
+	s.values.Clear()
+	s.t0 = t
+	s.t1 = s.t0.Add(s.rescaleInterval)
+	countRescale()
+	for _, v := range values {
+		v.k = v.k * math.Exp(-s.alpha*s.t0.Sub(t0).Seconds())
+		s.values.Push(v)
+	}
+}
+
+// expire drops the values updated before the given time.  The caller must
+// hold the lock.
+func (s *ExpDecaySample) expire(t time.Time) {
+	cutoff := t.UnixNano()
+	values := s.values.Values()
+	s.values.Clear()
+	for _, v := range values {
+		if v.t >= cutoff {
 			s.values.Push(v)
 		}
 	}
@@ -188,6 +393,9 @@ func (s *ExpDecaySample) update(t time.Time, v int64) {
 // NilSample is a no-op Sample.
 type NilSample struct{}
 
+// AppendValues returns dst unchanged.
+func (NilSample) AppendValues(dst []int64) []int64 { return dst }
+
 // Clear is a no-op.
 func (NilSample) Clear() {}
 
@@ -251,7 +459,14 @@ func SampleMean(values []int64) float64 {
 	if 0 == len(values) {
 		return 0.0
 	}
-	return float64(SampleSum(values)) / float64(len(values))
+	if sum, ok := SampleSumChecked(values); ok {
+		return float64(sum) / float64(len(values))
+	}
+	var sum kahanSum
+	for _, v := range values {
+		sum.Add(float64(v))
+	}
+	return sum.Sum() / float64(len(values))
 }
 
 // SampleMin returns the minimum value of the slice of int64.
@@ -274,34 +489,144 @@ func SamplePercentile(values int64Slice, p float64) float64 {
 }
 
 // SamplePercentiles returns a slice of arbitrary percentiles of the slice of
-// int64.
+// int64, computed by PercentileInterpolation.
 func SamplePercentiles(values int64Slice, ps []float64) []float64 {
+	return SamplePercentilesWithInterpolation(values, ps, PercentileInterpolation)
+}
+
+// SamplePercentilesWithInterpolation returns a slice of arbitrary percentiles of
+// the slice of int64, computed by the given Interpolation.
+func SamplePercentilesWithInterpolation(values int64Slice, ps []float64, m Interpolation) []float64 {
 	scores := make([]float64, len(ps))
 	size := len(values)
 	if size > 0 {
-		sort.Sort(values)
+		if len(ps) <= percentileSelectMaxPercentiles && size >= percentileSelectMinSize {
+			selectInt64Percentiles(values, ps, m)
+		} else {
+			sort.Sort(values)
+		}
 		for i, p := range ps {
-			pos := p * float64(size+1)
-			if pos < 1.0 {
-				scores[i] = float64(values[0])
-			} else if pos >= float64(size) {
-				scores[i] = float64(values[size-1])
-			} else {
-				lower := float64(values[int(pos)-1])
-				upper := float64(values[int(pos)])
-				scores[i] = lower + (pos-math.Floor(pos))*(upper-lower)
-			}
+			lo, hi, frac := percentilePosition(size, p, m)
+			lower := float64(values[lo])
+			upper := float64(values[hi])
+			scores[i] = lower + frac*(upper-lower)
 		}
 	}
 	return scores
 }
 
+// Percentiles are found by selection rather than by sorting the whole sample
+// when asking for only a few of them from a large enough sample.  Each
+// percentile reads at most two positions and each selection costs a linear
+// pass, so beyond a handful of percentiles one sort is cheaper.
+const (
+	percentileSelectMaxPercentiles = 4
+	percentileSelectMinSize        = 64
+)
+
+// percentileIndexes returns, in ascending order and without duplicates, the
+// indexes of a sorted slice of the given size which SamplePercentiles reads
+// to compute the given percentiles by the given Interpolation.
+func percentileIndexes(size int, ps []float64, m Interpolation) []int {
+	indexes := make([]int, 0, 2*len(ps))
+	for _, p := range ps {
+		lo, hi, _ := percentilePosition(size, p, m)
+		indexes = append(indexes, lo, hi)
+	}
+	sort.Ints(indexes)
+	n := 0
+	for i, k := range indexes {
+		if 0 == i || k != indexes[n-1] {
+			indexes[n] = k
+			n++
+		}
+	}
+	return indexes[:n]
+}
+
+// selectInt64Percentiles partially orders values so that every element
+// SamplePercentiles reads for the given percentiles is where it would be if
+// values were sorted.  The positions are selected in ascending order, each
+// within the range left over by the last.
+func selectInt64Percentiles(values int64Slice, ps []float64, m Interpolation) {
+	from := 0
+	for _, k := range percentileIndexes(len(values), ps, m) {
+		selectInt64(values, from, len(values)-1, k)
+		from = k
+	}
+}
+
+// selectInt64 moves the k-th smallest element of values[lo:hi+1] to index k,
+// with smaller elements before it and larger ones after, using quickselect
+// with a median-of-three pivot.  It falls back to sorting if partitioning
+// stops making progress.
+func selectInt64(values int64Slice, lo, hi, k int) {
+	for budget := 2 * bits.Len(uint(hi-lo+1)); lo < hi; budget-- {
+		if 0 == budget {
+			sort.Sort(values[lo : hi+1])
+			return
+		}
+		mid := lo + (hi-lo)/2
+		if values[mid] < values[lo] {
+			values[mid], values[lo] = values[lo], values[mid]
+		}
+		if values[hi] < values[lo] {
+			values[hi], values[lo] = values[lo], values[hi]
+		}
+		if values[hi] < values[mid] {
+			values[hi], values[mid] = values[mid], values[hi]
+		}
+		pivot := values[mid]
+		i, j := lo, hi
+		for i <= j {
+			for values[i] < pivot {
+				i++
+			}
+			for pivot < values[j] {
+				j--
+			}
+			if i <= j {
+				values[i], values[j] = values[j], values[i]
+				i++
+				j--
+			}
+		}
+		switch {
+		case k <= j:
+			hi = j
+		case k >= i:
+			lo = i
+		default:
+			return
+		}
+	}
+}
+
 // SampleSnapshot is a read-only copy of another Sample.
+//
+// Snapshots are immutable and safe for concurrent use: their percentiles
+// are read from a sorted copy of the values, made on first use, so that the
+// values keep their order, and their max, min, sum, mean and variance are
+// computed together on first use, so that reporters asking for each of them
+// of the same snapshot don't each go through the values again.
 type SampleSnapshot struct {
-	count  int64
-	values []int64
+	count     int64
+	values    []int64
+	once      sync.Once
+	sorted    int64Slice
+	statsOnce sync.Once
+	stats     sampleStats
+}
+
+// sampleStats are the statistics of a SampleSnapshot, computed on first use.
+type sampleStats struct {
+	max, min, sum  int64
+	mean, variance float64
 }
 
+// NewSampleSnapshot constructs a new SampleSnapshot of the given values,
+// which it retains, so the caller mustn't modify them afterwards.  Use
+// NewSampleSnapshotCopy to keep using them.
 func NewSampleSnapshot(count int64, values []int64) *SampleSnapshot {
 	return &SampleSnapshot{
 		count:  count,
@@ -309,33 +634,54 @@ func NewSampleSnapshot(count int64, values []int64) *SampleSnapshot {
 	}
 }
 
-// Clear panics.
+// NewSampleSnapshotCopy constructs a new SampleSnapshot of a copy of the
+// given values, which the caller remains free to modify.
+func NewSampleSnapshotCopy(count int64, values []int64) *SampleSnapshot {
+	return NewSampleSnapshot(count, append([]int64(nil), values...))
+}
+
+// AppendValues appends the values at the time the snapshot was taken to dst.
+func (s *SampleSnapshot) AppendValues(dst []int64) []int64 { return append(dst, s.values...) }
+
+// Clear panics unless PanicOnSnapshotMutation is false.
 func (*SampleSnapshot) Clear() {
-	panic("Clear called on a SampleSnapshot")
+	snapshotMisuse("Clear called on a SampleSnapshot")
 }
 
 // Count returns the count of inputs at the time the snapshot was taken.
 func (s *SampleSnapshot) Count() int64 { return s.count }
 
 // Max returns the maximal value at the time the snapshot was taken.
-func (s *SampleSnapshot) Max() int64 { return SampleMax(s.values) }
+func (s *SampleSnapshot) Max() int64 { return s.statistics().max }
 
 // Mean returns the mean value at the time the snapshot was taken.
-func (s *SampleSnapshot) Mean() float64 { return SampleMean(s.values) }
+func (s *SampleSnapshot) Mean() float64 { return s.statistics().mean }
 
 // Min returns the minimal value at the time the snapshot was taken.
-func (s *SampleSnapshot) Min() int64 { return SampleMin(s.values) }
+func (s *SampleSnapshot) Min() int64 { return s.statistics().min }
 
 // Percentile returns an arbitrary percentile of values at the time the
 // snapshot was taken.
 func (s *SampleSnapshot) Percentile(p float64) float64 {
-	return SamplePercentile(s.values, p)
+	return s.Percentiles([]float64{p})[0]
 }
 
 // Percentiles returns a slice of arbitrary percentiles of values at the time
 // the snapshot was taken.
 func (s *SampleSnapshot) Percentiles(ps []float64) []float64 {
-	return SamplePercentiles(s.values, ps)
+	s.once.Do(func() {
+		s.sorted = append(int64Slice(nil), s.values...)
+		sort.Sort(s.sorted)
+	})
+	scores := make([]float64, len(ps))
+	if size := len(s.sorted); size > 0 {
+		for i, p := range ps {
+			lo, hi, frac := percentilePosition(size, p, PercentileInterpolation)
+			lower, upper := float64(s.sorted[lo]), float64(s.sorted[hi])
+			scores[i] = lower + frac*(upper-lower)
+		}
+	}
+	return scores
 }
 
 // Size returns the size of the sample at the time the snapshot was taken.
@@ -346,14 +692,14 @@ func (s *SampleSnapshot) Snapshot() Sample { return s }
 
 // StdDev returns the standard deviation of values at the time the snapshot was
 // taken.
-func (s *SampleSnapshot) StdDev() float64 { return SampleStdDev(s.values) }
+func (s *SampleSnapshot) StdDev() float64 { return math.Sqrt(s.statistics().variance) }
 
 // Sum returns the sum of values at the time the snapshot was taken.
-func (s *SampleSnapshot) Sum() int64 { return SampleSum(s.values) }
+func (s *SampleSnapshot) Sum() int64 { return s.statistics().sum }
 
-// Update panics.
+// Update panics unless PanicOnSnapshotMutation is false.
 func (*SampleSnapshot) Update(int64) {
-	panic("Update called on a SampleSnapshot")
+	snapshotMisuse("Update called on a SampleSnapshot")
 }
 
 // Values returns a copy of the values in the sample.
@@ -364,34 +710,77 @@ func (s *SampleSnapshot) Values() []int64 {
 }
 
 // Variance returns the variance of values at the time the snapshot was taken.
-func (s *SampleSnapshot) Variance() float64 { return SampleVariance(s.values) }
+func (s *SampleSnapshot) Variance() float64 { return s.statistics().variance }
+
+func (s *SampleSnapshot) statistics() *sampleStats {
+	s.statsOnce.Do(func() {
+		s.stats = sampleStats{
+			max:      SampleMax(s.values),
+			min:      SampleMin(s.values),
+			sum:      SampleSum(s.values),
+			mean:     SampleMean(s.values),
+			variance: SampleVariance(s.values),
+		}
+	})
+	return &s.stats
+}
 
 // SampleStdDev returns the standard deviation of the slice of int64.
 func SampleStdDev(values []int64) float64 {
 	return math.Sqrt(SampleVariance(values))
 }
 
-// SampleSum returns the sum of the slice of int64.
+// SampleSum returns the sum of the slice of int64, saturating at
+// math.MaxInt64 or math.MinInt64 rather than wrapping around if it overflows.
 func SampleSum(values []int64) int64 {
-	var sum int64
-	for _, v := range values {
-		sum += v
+	sum, wraps := sampleSum(values)
+	if wraps > 0 {
+		return math.MaxInt64
+	} else if wraps < 0 {
+		return math.MinInt64
 	}
 	return sum
 }
 
-// SampleVariance returns the variance of the slice of int64.
+// SampleSumChecked returns the sum of the slice of int64 and whether it fit
+// in an int64.  If it didn't, the sum returned has wrapped around.
+func SampleSumChecked(values []int64) (int64, bool) {
+	sum, wraps := sampleSum(values)
+	return sum, 0 == wraps
+}
+
+// sampleSum returns the sum of the slice of int64, wrapped around, and the
+// net number of times it wrapped, positive for overflow and negative for
+// underflow.  Since intermediate wraps cancel out the sum is exact when
+// that's zero.
+func sampleSum(values []int64) (sum int64, wraps int) {
+	for _, v := range values {
+		next := sum + v
+		if v > 0 && next < sum {
+			wraps++
+		} else if v < 0 && next > sum {
+			wraps--
+		}
+		sum = next
+	}
+	return sum, wraps
+}
+
+// SampleVariance returns the variance of the slice of int64, computed in two
+// passes with a correction term which cancels out the rounding error in the
+// mean.
 func SampleVariance(values []int64) float64 {
 	if 0 == len(values) {
 		return 0.0
 	}
 	m := SampleMean(values)
-	var sum float64
+	var sum, comp float64
 	for _, v := range values {
 		d := float64(v) - m
 		sum += d * d
+		comp += d
 	}
-	return sum / float64(len(values))
+	return (sum - comp*comp/float64(len(values))) / float64(len(values))
 }
 
 // A uniform sample using Vitter's Algorithm R.
@@ -401,6 +790,7 @@ type UniformSample struct {
 	count         int64
 	mutex         sync.Mutex
 	reservoirSize int
+	rng           *rand.Rand
 	values        []int64
 }
 
@@ -416,6 +806,26 @@ func NewUniformSample(reservoirSize int) Sample {
 	}
 }
 
+// NewUniformSampleWithRand constructs a new uniform sample like
+// NewUniformSample but draws from r rather than from the shared source in
+// math/rand.  r is only used while holding the sample's lock, so it needn't be
+// safe for concurrent use and must not be shared with other samples.
+func NewUniformSampleWithRand(reservoirSize int, r *rand.Rand) Sample {
+	s := NewUniformSample(reservoirSize)
+	if us, ok := s.(*UniformSample); ok {
+		us.rng = r
+	}
+	return s
+}
+
+// AppendValues appends the values in the sample to dst and returns the
+// extended slice.
+func (s *UniformSample) AppendValues(dst []int64) []int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append(dst, s.values...)
+}
+
 // Clear clears all samples.
 func (s *UniformSample) Clear() {
 	s.mutex.Lock()
@@ -479,6 +889,7 @@ func (s *UniformSample) Size() int {
 
 // Snapshot returns a read-only copy of the sample.
 func (s *UniformSample) Snapshot() Sample {
+	defer observeSampleSnapshot(selfStart())
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	values := make([]int64, len(s.values))
@@ -511,7 +922,7 @@ func (s *UniformSample) Update(v int64) {
 	if len(s.values) < s.reservoirSize {
 		s.values = append(s.values, v)
 	} else {
-		r := rand.Int63n(s.count)
+		r := randInt63n(s.rng, s.count)
 		if r < int64(len(s.values)) {
 			s.values[int(r)] = v
 		}
@@ -536,8 +947,10 @@ func (s *UniformSample) Variance() float64 {
 
 // expDecaySample represents an individual sample in a heap.
 type expDecaySample struct {
-	k float64
-	v int64
+	k   float64
+	seq uint64 // Breaks ties between equal priorities, if the sample is stable
+	t   int64  // UnixNano of the update
+	v   int64
 }
 
 func newExpDecaySampleHeap(reservoirSize int) *expDecaySampleHeap {
@@ -583,7 +996,7 @@ func (h *expDecaySampleHeap) Values() []expDecaySample {
 func (h *expDecaySampleHeap) up(j int) {
 	for {
 		i := (j - 1) / 2 // parent
-		if i == j || !(h.s[j].k < h.s[i].k) {
+		if i == j || !h.less(j, i) {
 			break
 		}
 		h.s[i], h.s[j] = h.s[j], h.s[i]
@@ -598,10 +1011,10 @@ func (h *expDecaySampleHeap) down(i, n int) {
 			break
 		}
 		j := j1 // left child
-		if j2 := j1 + 1; j2 < n && !(h.s[j1].k < h.s[j2].k) {
+		if j2 := j1 + 1; j2 < n && !h.less(j1, j2) {
 			j = j2 // = 2*i + 2  // right child
 		}
-		if !(h.s[j].k < h.s[i].k) {
+		if !h.less(j, i) {
 			break
 		}
 		h.s[i], h.s[j] = h.s[j], h.s[i]
@@ -609,6 +1022,35 @@ func (h *expDecaySampleHeap) down(i, n int) {
 	}
 }
 
+func (h *expDecaySampleHeap) less(i, j int) bool {
+	return h.s[i].k < h.s[j].k || h.s[i].k == h.s[j].k && h.s[i].seq < h.s[j].seq
+}
+
+// stablePriority clamps an infinite priority to the largest finite float64
+// and a NaN one, from zero divided by zero, to zero.
+func stablePriority(k float64) float64 {
+	if math.IsNaN(k) {
+		return 0
+	}
+	return math.Min(k, math.MaxFloat64)
+}
+
+// randFloat64 returns r.Float64(), or rand.Float64() if r is nil.
+func randFloat64(r *rand.Rand) float64 {
+	if nil == r {
+		return rand.Float64()
+	}
+	return r.Float64()
+}
+
+// randInt63n returns r.Int63n(n), or rand.Int63n(n) if r is nil.
+func randInt63n(r *rand.Rand, n int64) int64 {
+	if nil == r {
+		return rand.Int63n(n)
+	}
+	return r.Int63n(n)
+}
+
 type int64Slice []int64
 
 func (p int64Slice) Len() int           { return len(p) }
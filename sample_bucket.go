@@ -0,0 +1,382 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+// LinearBuckets returns count bucket upper bounds, the first being start and
+// each being width more than the one before.  It panics if the bounds
+// wouldn't pass ValidateBuckets.
+func LinearBuckets(start, width float64, count int) []float64 {
+	if count < 1 || start <= 0 || width <= 0 {
+		panic("LinearBuckets called with a count less than one or a start or width which isn't positive")
+	}
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start + float64(i)*width
+	}
+	return buckets
+}
+
+// ExponentialBuckets returns count bucket upper bounds, the first being start
+// and each being factor times the one before.  It panics if the bounds
+// wouldn't pass ValidateBuckets.
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	if count < 1 || start <= 0 || factor <= 1 {
+		panic("ExponentialBuckets called with a count less than one, a start which isn't positive or a factor not greater than one")
+	}
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start * math.Pow(factor, float64(i))
+	}
+	return buckets
+}
+
+// ValidateBuckets returns an error unless the given bucket upper bounds are
+// positive, finite and in strictly increasing order, and there is at least
+// one.
+func ValidateBuckets(buckets []float64) error {
+	if 0 == len(buckets) {
+		return fmt.Errorf("metrics: no buckets")
+	}
+	for i, b := range buckets {
+		if math.IsNaN(b) || math.IsInf(b, 0) || b <= 0 {
+			return fmt.Errorf("metrics: bucket %d (%v) is not positive and finite", i, b)
+		}
+		if 0 < i && b <= buckets[i-1] {
+			return fmt.Errorf("metrics: bucket %d (%v) is not greater than bucket %d (%v)", i, b, i-1, buckets[i-1])
+		}
+	}
+	return nil
+}
+
+// BucketSampleFloat64 is a SampleFloat64 which counts values in fixed
+// buckets rather than keeping a reservoir of them, so that it records every
+// value in constant memory and its counts can be exported as a cumulative
+// histogram.  Percentiles are interpolated linearly within the bucket they
+// fall in.
+//
+// Count, Min, Max, Mean, StdDev, Sum and Variance are exact.  Since no
+// individual values are kept, Values returns an empty slice and Size returns
+// the number of buckets in use.
+type BucketSampleFloat64 struct {
+	mutex   sync.Mutex
+	buckets bucketCounts
+}
+
+// NewBucketSampleFloat64 constructs a new bucket sample with the given
+// bucket upper bounds, eg from LinearBuckets or ExponentialBuckets, and an
+// overflow bucket above them.  It panics if they don't pass
+// ValidateBuckets.
+func NewBucketSampleFloat64(buckets []float64) SampleFloat64 {
+	if err := ValidateBuckets(buckets); nil != err {
+		panic(err)
+	}
+	if UseNilMetrics {
+		return NilSampleFloat64{}
+	}
+	return &BucketSampleFloat64{buckets: bucketCounts{
+		bounds: append([]float64(nil), buckets...),
+		counts: make([]int64, len(buckets)+1),
+	}}
+}
+
+// AppendValues returns dst unchanged since no individual values are kept.
+func (*BucketSampleFloat64) AppendValues(dst []float64) []float64 { return dst }
+
+// Buckets returns the bucket upper bounds and the number of values counted
+// in each, with a final count for the values above the last bound.
+func (s *BucketSampleFloat64) Buckets() ([]float64, []int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.buckets.bounds, append([]int64(nil), s.buckets.counts...)
+}
+
+// Clear clears all samples.
+func (s *BucketSampleFloat64) Clear() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.buckets.clear()
+}
+
+// Count returns the number of samples recorded.
+func (s *BucketSampleFloat64) Count() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.buckets.count
+}
+
+// Max returns the maximum value recorded.
+func (s *BucketSampleFloat64) Max() float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.buckets.max
+}
+
+// Mean returns the mean of the values recorded.
+func (s *BucketSampleFloat64) Mean() float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.buckets.mean
+}
+
+// Min returns the minimum value recorded.
+func (s *BucketSampleFloat64) Min() float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.buckets.min
+}
+
+// Percentile returns an arbitrary percentile of values in the sample.
+func (s *BucketSampleFloat64) Percentile(p float64) float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.buckets.quantile(p)
+}
+
+// Percentiles returns a slice of arbitrary percentiles of values in the
+// sample.
+func (s *BucketSampleFloat64) Percentiles(ps []float64) []float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.buckets.quantiles(ps)
+}
+
+// Size returns the number of buckets in use.
+func (s *BucketSampleFloat64) Size() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.buckets.size()
+}
+
+// Snapshot returns a read-only copy of the sample.
+func (s *BucketSampleFloat64) Snapshot() SampleFloat64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return &BucketSampleFloat64Snapshot{buckets: s.buckets.copy()}
+}
+
+// StdDev returns the standard deviation of the values recorded.
+func (s *BucketSampleFloat64) StdDev() float64 {
+	return math.Sqrt(s.Variance())
+}
+
+// Sum returns the sum of the values recorded.
+func (s *BucketSampleFloat64) Sum() float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.buckets.sum
+}
+
+// Update samples a new value.  NaN values are ignored.
+func (s *BucketSampleFloat64) Update(v float64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.buckets.add(v)
+}
+
+// Values returns an empty slice since no individual values are kept.
+func (s *BucketSampleFloat64) Values() []float64 { return []float64{} }
+
+// bounds returns the bucket upper bounds.
+func (s *BucketSampleFloat64) bounds() []float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.buckets.bounds
+}
+
+// set replaces what the sample has recorded with b, whose bounds must be
+// the sample's.
+func (s *BucketSampleFloat64) set(b bucketCounts) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.buckets = b
+}
+
+// Variance returns the variance of the values recorded.
+func (s *BucketSampleFloat64) Variance() float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.buckets.variance()
+}
+
+// BucketSampleFloat64Snapshot is a read-only copy of a BucketSampleFloat64.
+type BucketSampleFloat64Snapshot struct {
+	buckets bucketCounts
+}
+
+// AppendValues returns dst unchanged since no individual values are kept.
+func (*BucketSampleFloat64Snapshot) AppendValues(dst []float64) []float64 { return dst }
+
+// Buckets returns the bucket upper bounds and the number of values counted
+// in each at the time the snapshot was taken, with a final count for the
+// values above the last bound.
+func (s *BucketSampleFloat64Snapshot) Buckets() ([]float64, []int64) {
+	return s.buckets.bounds, append([]int64(nil), s.buckets.counts...)
+}
+
+// Clear panics unless PanicOnSnapshotMutation is false.
+func (*BucketSampleFloat64Snapshot) Clear() {
+	snapshotMisuse("Clear called on a BucketSampleFloat64Snapshot")
+}
+
+// Count returns the count of values at the time the snapshot was taken.
+func (s *BucketSampleFloat64Snapshot) Count() int64 { return s.buckets.count }
+
+// Max returns the maximal value at the time the snapshot was taken.
+func (s *BucketSampleFloat64Snapshot) Max() float64 { return s.buckets.max }
+
+// Mean returns the mean value at the time the snapshot was taken.
+func (s *BucketSampleFloat64Snapshot) Mean() float64 { return s.buckets.mean }
+
+// Min returns the minimal value at the time the snapshot was taken.
+func (s *BucketSampleFloat64Snapshot) Min() float64 { return s.buckets.min }
+
+// Percentile returns an arbitrary percentile of values at the time the
+// snapshot was taken.
+func (s *BucketSampleFloat64Snapshot) Percentile(p float64) float64 {
+	return s.buckets.quantile(p)
+}
+
+// Percentiles returns a slice of arbitrary percentiles of values at the time
+// the snapshot was taken.
+func (s *BucketSampleFloat64Snapshot) Percentiles(ps []float64) []float64 {
+	return s.buckets.quantiles(ps)
+}
+
+// Size returns the number of buckets in use at the time the snapshot was
+// taken.
+func (s *BucketSampleFloat64Snapshot) Size() int { return s.buckets.size() }
+
+// Snapshot returns the snapshot.
+func (s *BucketSampleFloat64Snapshot) Snapshot() SampleFloat64 { return s }
+
+// StdDev returns the standard deviation of values at the time the snapshot
+// was taken.
+func (s *BucketSampleFloat64Snapshot) StdDev() float64 {
+	return math.Sqrt(s.buckets.variance())
+}
+
+// Sum returns the sum of values at the time the snapshot was taken.
+func (s *BucketSampleFloat64Snapshot) Sum() float64 { return s.buckets.sum }
+
+// Update panics unless PanicOnSnapshotMutation is false.
+func (*BucketSampleFloat64Snapshot) Update(float64) {
+	snapshotMisuse("Update called on a BucketSampleFloat64Snapshot")
+}
+
+// Values returns an empty slice since no individual values are kept.
+func (s *BucketSampleFloat64Snapshot) Values() []float64 { return []float64{} }
+
+// Variance returns the variance of values at the time the snapshot was taken.
+func (s *BucketSampleFloat64Snapshot) Variance() float64 {
+	return s.buckets.variance()
+}
+
+// bucketCounts is the unsynchronised state shared by BucketSampleFloat64 and
+// its snapshots.  counts[i] counts the values no greater than bounds[i] and
+// greater than bounds[i-1], and the last count those above every bound.
+type bucketCounts struct {
+	bounds        []float64
+	counts        []int64
+	count         int64
+	min, max, sum float64
+	mean, m2      float64 // Welford's running mean and sum of squared deviations
+}
+
+func (b *bucketCounts) add(v float64) {
+	b.addN(v, 1)
+}
+
+// addN adds n values of v at once, as from another histogram's bucket.
+func (b *bucketCounts) addN(v float64, n int64) {
+	if math.IsNaN(v) || n <= 0 {
+		return
+	}
+	b.counts[sort.SearchFloat64s(b.bounds, v)] += n
+	if 0 == b.count || v < b.min {
+		b.min = v
+	}
+	if 0 == b.count || v > b.max {
+		b.max = v
+	}
+	b.count += n
+	b.sum += v * float64(n)
+	delta := v - b.mean
+	b.mean += delta * float64(n) / float64(b.count)
+	b.m2 += delta * (v - b.mean) * float64(n)
+}
+
+func (b *bucketCounts) clear() {
+	for i := range b.counts {
+		b.counts[i] = 0
+	}
+	b.count = 0
+	b.min, b.max, b.sum, b.mean, b.m2 = 0, 0, 0, 0, 0
+}
+
+func (b *bucketCounts) copy() bucketCounts {
+	c := *b
+	c.counts = append([]int64(nil), b.counts...)
+	return c
+}
+
+// quantile returns the value at rank p*count, interpolated between the
+// bounds of the bucket it falls in and clamped to the exact minimum and
+// maximum.
+func (b *bucketCounts) quantile(p float64) float64 {
+	if 0 == b.count {
+		return 0.0
+	}
+	if p <= 0 {
+		return b.min
+	}
+	if p >= 1 {
+		return b.max
+	}
+	rank := p * float64(b.count)
+	var seen int64
+	for i, c := range b.counts {
+		if 0 == c || float64(seen+c) < rank {
+			seen += c
+			continue
+		}
+		lo, hi := b.min, b.max
+		if 0 < i && b.bounds[i-1] > lo {
+			lo = b.bounds[i-1]
+		}
+		if i < len(b.bounds) && b.bounds[i] < hi {
+			hi = b.bounds[i]
+		}
+		return lo + (hi-lo)*(rank-float64(seen))/float64(c)
+	}
+	return b.max
+}
+
+func (b *bucketCounts) quantiles(ps []float64) []float64 {
+	scores := make([]float64, len(ps))
+	for i, p := range ps {
+		scores[i] = b.quantile(p)
+	}
+	return scores
+}
+
+func (b *bucketCounts) size() int {
+	n := 0
+	for _, c := range b.counts {
+		if 0 != c {
+			n++
+		}
+	}
+	return n
+}
+
+func (b *bucketCounts) variance() float64 {
+	if 0 == b.count {
+		return 0.0
+	}
+	return b.m2 / float64(b.count)
+}
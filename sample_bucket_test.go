@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func BenchmarkBucketSampleFloat64(b *testing.B) {
+	benchmarkSampleFloat64(b, NewBucketSampleFloat64(ExponentialBuckets(1, 2, 16)))
+}
+
+func TestLinearBuckets(t *testing.T) {
+	if b := LinearBuckets(10, 5, 4); !reflect.DeepEqual([]float64{10, 15, 20, 25}, b) {
+		t.Errorf("LinearBuckets(10, 5, 4): [10 15 20 25] != %v\n", b)
+	}
+}
+
+func TestExponentialBuckets(t *testing.T) {
+	if b := ExponentialBuckets(1, 10, 4); !reflect.DeepEqual([]float64{1, 10, 100, 1000}, b) {
+		t.Errorf("ExponentialBuckets(1, 10, 4): [1 10 100 1000] != %v\n", b)
+	}
+}
+
+func TestBucketsPanic(t *testing.T) {
+	for name, f := range map[string]func(){
+		"LinearBuckets(0, 1, 1)":      func() { LinearBuckets(0, 1, 1) },
+		"LinearBuckets(1, 0, 1)":      func() { LinearBuckets(1, 0, 1) },
+		"LinearBuckets(1, 1, 0)":      func() { LinearBuckets(1, 1, 0) },
+		"ExponentialBuckets(1, 1, 1)": func() { ExponentialBuckets(1, 1, 1) },
+		"ExponentialBuckets(0, 2, 1)": func() { ExponentialBuckets(0, 2, 1) },
+		"ExponentialBuckets(1, 2, 0)": func() { ExponentialBuckets(1, 2, 0) },
+	} {
+		func() {
+			defer func() {
+				if nil == recover() {
+					t.Errorf("%s: no panic\n", name)
+				}
+			}()
+			f()
+		}()
+	}
+}
+
+func TestValidateBuckets(t *testing.T) {
+	for _, b := range [][]float64{
+		nil,
+		{0, 1},
+		{-1, 1},
+		{1, 1},
+		{2, 1},
+		{1, math.Inf(1)},
+		{1, math.NaN()},
+	} {
+		if err := ValidateBuckets(b); nil == err {
+			t.Errorf("ValidateBuckets(%v): nil error\n", b)
+		}
+	}
+	if err := ValidateBuckets([]float64{0.5, 1, 2.5}); nil != err {
+		t.Error(err)
+	}
+}
+
+func TestBucketSampleFloat64(t *testing.T) {
+	s := NewBucketSampleFloat64(LinearBuckets(10, 10, 10))
+	for i := 1; i <= 200; i++ {
+		s.Update(float64(i))
+	}
+	s.Update(math.NaN())
+	if count := s.Count(); 200 != count {
+		t.Errorf("s.Count(): 200 != %v\n", count)
+	}
+	if min := s.Min(); 1 != min {
+		t.Errorf("s.Min(): 1 != %v\n", min)
+	}
+	if max := s.Max(); 200 != max {
+		t.Errorf("s.Max(): 200 != %v\n", max)
+	}
+	if mean := s.Mean(); 100.5 != mean {
+		t.Errorf("s.Mean(): 100.5 != %v\n", mean)
+	}
+	if sum := s.Sum(); 20100 != sum {
+		t.Errorf("s.Sum(): 20100 != %v\n", sum)
+	}
+	if size := s.Size(); 11 != size {
+		t.Errorf("s.Size(): 11 != %v\n", size)
+	}
+	ps := s.Percentiles([]float64{0, 0.25, 0.5, 0.75, 1})
+	for i, want := range []float64{1, 50, 100, 150, 200} {
+		if math.Abs(ps[i]-want) > 1e-9 {
+			t.Errorf("ps[%d]: %v != %v\n", i, want, ps[i])
+		}
+	}
+	bounds, counts := s.(*BucketSampleFloat64).Buckets()
+	if 10 != len(bounds) || 11 != len(counts) {
+		t.Fatalf("s.Buckets(): %d bounds and %d counts\n", len(bounds), len(counts))
+	}
+	if 10 != counts[0] || 100 != counts[10] {
+		t.Errorf("counts: 10 != %v or 100 != %v\n", counts[0], counts[10])
+	}
+}
+
+func TestBucketSampleFloat64Snapshot(t *testing.T) {
+	s := NewBucketSampleFloat64([]float64{1, 2, 4})
+	s.Update(1)
+	s.Update(3)
+	snapshot := s.Snapshot()
+	s.Update(5)
+	if count := snapshot.Count(); 2 != count {
+		t.Errorf("snapshot.Count(): 2 != %v\n", count)
+	}
+	if _, counts := snapshot.(*BucketSampleFloat64Snapshot).Buckets(); !reflect.DeepEqual([]int64{1, 0, 1, 0}, counts) {
+		t.Errorf("snapshot.Buckets(): [1 0 1 0] != %v\n", counts)
+	}
+	s.Clear()
+	if count := s.Count(); 0 != count {
+		t.Errorf("s.Count(): 0 != %v\n", count)
+	}
+	if max := snapshot.Max(); 3 != max {
+		t.Errorf("snapshot.Max(): 3 != %v\n", max)
+	}
+}
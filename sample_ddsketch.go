@@ -0,0 +1,459 @@
+package metrics
+
+import (
+	"errors"
+	"math"
+	"sync"
+)
+
+// ErrIncompatibleSketch is returned when merging DDSketch samples built with
+// different relative accuracies.
+var ErrIncompatibleSketch = errors.New("metrics: cannot merge sketches with different relative accuracy")
+
+// DDSketchSampleFloat64 is a SampleFloat64 which keeps a DDSketch rather than
+// a reservoir of values.  Every percentile it reports is within the configured
+// relative accuracy of the true value, memory is bounded by the maximum number
+// of bins, and sketches from different instances can be merged exactly.  See
+// Masson et al's "DDSketch: A Fast and Fully-Mergeable Quantile Sketch with
+// Relative-Error Guarantees".
+//
+// <https://arxiv.org/abs/1908.10693>
+//
+// Count, Min, Max, Mean, StdDev, Sum and Variance are exact.  Since no
+// individual values are kept, Values returns an empty slice and Size returns
+// the number of bins in use.  Once the bin limit is reached the bins holding
+// the values closest to zero are collapsed, so the accuracy guarantee is kept
+// for the higher percentiles which matter for latencies.
+type DDSketchSampleFloat64 struct {
+	mutex  sync.Mutex
+	sketch ddSketch
+}
+
+// NewDDSketchSampleFloat64 constructs a new DDSketch sample with the given
+// relative accuracy, eg 0.01 for 1%, using at most maxBins bins for each of
+// the positive and negative values.  A maxBins of zero leaves the number of
+// bins unbounded.
+func NewDDSketchSampleFloat64(relativeAccuracy float64, maxBins int) SampleFloat64 {
+	if UseNilMetrics {
+		return NilSampleFloat64{}
+	}
+	if relativeAccuracy <= 0 || relativeAccuracy >= 1 {
+		panic("metrics: DDSketch relative accuracy must be between 0 and 1")
+	}
+	gamma := (1 + relativeAccuracy) / (1 - relativeAccuracy)
+	return &DDSketchSampleFloat64{sketch: ddSketch{
+		relativeAccuracy: relativeAccuracy,
+		gamma:            gamma,
+		lnGamma:          math.Log(gamma),
+		maxBins:          maxBins,
+	}}
+}
+
+// Clear clears all samples.
+func (s *DDSketchSampleFloat64) Clear() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.sketch.clear()
+}
+
+// Count returns the number of samples recorded.
+func (s *DDSketchSampleFloat64) Count() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.sketch.count
+}
+
+// Max returns the maximum value recorded.
+func (s *DDSketchSampleFloat64) Max() float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.sketch.max
+}
+
+// Mean returns the mean of the values recorded.
+func (s *DDSketchSampleFloat64) Mean() float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.sketch.mean
+}
+
+// Merge adds the values recorded by another DDSketch sample, or a snapshot of
+// one, to this sample.  Both must have the same relative accuracy.
+func (s *DDSketchSampleFloat64) Merge(other SampleFloat64) error {
+	o, ok := other.Snapshot().(*DDSketchSampleFloat64Snapshot)
+	if !ok {
+		return ErrIncompatibleSketch
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.sketch.merge(&o.sketch)
+}
+
+// Min returns the minimum value recorded.
+func (s *DDSketchSampleFloat64) Min() float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.sketch.min
+}
+
+// Percentile returns an arbitrary percentile of values in the sample.
+func (s *DDSketchSampleFloat64) Percentile(p float64) float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.sketch.quantile(p)
+}
+
+// Percentiles returns a slice of arbitrary percentiles of values in the
+// sample.
+func (s *DDSketchSampleFloat64) Percentiles(ps []float64) []float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.sketch.quantiles(ps)
+}
+
+// RelativeAccuracy returns the relative accuracy the sample was built with.
+func (s *DDSketchSampleFloat64) RelativeAccuracy() float64 {
+	return s.sketch.relativeAccuracy
+}
+
+// Size returns the number of bins in use.
+func (s *DDSketchSampleFloat64) Size() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.sketch.size()
+}
+
+// Snapshot returns a read-only copy of the sample.
+func (s *DDSketchSampleFloat64) Snapshot() SampleFloat64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return &DDSketchSampleFloat64Snapshot{sketch: s.sketch.copy()}
+}
+
+// StdDev returns the standard deviation of the values recorded.
+func (s *DDSketchSampleFloat64) StdDev() float64 {
+	return math.Sqrt(s.Variance())
+}
+
+// Sum returns the sum of the values recorded.
+func (s *DDSketchSampleFloat64) Sum() float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.sketch.sum
+}
+
+// Update samples a new value.  NaN and infinite values are ignored.
+func (s *DDSketchSampleFloat64) Update(v float64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.sketch.add(v)
+}
+
+// AppendValues returns dst unchanged since the sketch keeps no individual
+// values.
+func (*DDSketchSampleFloat64) AppendValues(dst []float64) []float64 { return dst }
+
+// Values returns an empty slice since the sketch keeps no individual values.
+func (s *DDSketchSampleFloat64) Values() []float64 { return []float64{} }
+
+// Variance returns the variance of the values recorded.
+func (s *DDSketchSampleFloat64) Variance() float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.sketch.variance()
+}
+
+// DDSketchSampleFloat64Snapshot is a read-only copy of a DDSketchSampleFloat64.
+type DDSketchSampleFloat64Snapshot struct {
+	sketch ddSketch
+}
+
+// Clear panics unless PanicOnSnapshotMutation is false.
+func (*DDSketchSampleFloat64Snapshot) Clear() {
+	snapshotMisuse("Clear called on a DDSketchSampleFloat64Snapshot")
+}
+
+// Count returns the count of inputs at the time the snapshot was taken.
+func (s *DDSketchSampleFloat64Snapshot) Count() int64 { return s.sketch.count }
+
+// Max returns the maximal value at the time the snapshot was taken.
+func (s *DDSketchSampleFloat64Snapshot) Max() float64 { return s.sketch.max }
+
+// Mean returns the mean value at the time the snapshot was taken.
+func (s *DDSketchSampleFloat64Snapshot) Mean() float64 { return s.sketch.mean }
+
+// Min returns the minimal value at the time the snapshot was taken.
+func (s *DDSketchSampleFloat64Snapshot) Min() float64 { return s.sketch.min }
+
+// Percentile returns an arbitrary percentile of values at the time the
+// snapshot was taken.
+func (s *DDSketchSampleFloat64Snapshot) Percentile(p float64) float64 {
+	return s.sketch.quantile(p)
+}
+
+// Percentiles returns a slice of arbitrary percentiles of values at the time
+// the snapshot was taken.
+func (s *DDSketchSampleFloat64Snapshot) Percentiles(ps []float64) []float64 {
+	return s.sketch.quantiles(ps)
+}
+
+// RelativeAccuracy returns the relative accuracy the sample was built with.
+func (s *DDSketchSampleFloat64Snapshot) RelativeAccuracy() float64 {
+	return s.sketch.relativeAccuracy
+}
+
+// Size returns the number of bins in use at the time the snapshot was taken.
+func (s *DDSketchSampleFloat64Snapshot) Size() int { return s.sketch.size() }
+
+// Snapshot returns the snapshot.
+func (s *DDSketchSampleFloat64Snapshot) Snapshot() SampleFloat64 { return s }
+
+// StdDev returns the standard deviation of values at the time the snapshot was
+// taken.
+func (s *DDSketchSampleFloat64Snapshot) StdDev() float64 {
+	return math.Sqrt(s.sketch.variance())
+}
+
+// Sum returns the sum of values at the time the snapshot was taken.
+func (s *DDSketchSampleFloat64Snapshot) Sum() float64 { return s.sketch.sum }
+
+// Update panics unless PanicOnSnapshotMutation is false.
+func (*DDSketchSampleFloat64Snapshot) Update(float64) {
+	snapshotMisuse("Update called on a DDSketchSampleFloat64Snapshot")
+}
+
+// AppendValues returns dst unchanged since the sketch keeps no individual
+// values.
+func (*DDSketchSampleFloat64Snapshot) AppendValues(dst []float64) []float64 { return dst }
+
+// Values returns an empty slice since the sketch keeps no individual values.
+func (s *DDSketchSampleFloat64Snapshot) Values() []float64 { return []float64{} }
+
+// Variance returns the variance of values at the time the snapshot was taken.
+func (s *DDSketchSampleFloat64Snapshot) Variance() float64 {
+	return s.sketch.variance()
+}
+
+// ddSketch is the unsynchronised sketch shared by DDSketchSampleFloat64 and
+// its snapshots.  Positive values x are counted in bin ceil(log_gamma(x)) of
+// the positive store, negative values in the same bin of the negative store
+// for -x, and zeros separately.
+type ddSketch struct {
+	relativeAccuracy float64
+	gamma, lnGamma   float64
+	maxBins          int
+	positive         ddSketchStore
+	negative         ddSketchStore
+	zeros            int64
+	count            int64
+	min, max, sum    float64
+	mean, m2         float64 // Welford's running mean and sum of squared deviations
+}
+
+func (d *ddSketch) add(v float64) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return
+	}
+	switch {
+	case v > 0:
+		d.positive.add(d.index(v), 1, d.maxBins)
+	case v < 0:
+		d.negative.add(d.index(-v), 1, d.maxBins)
+	default:
+		d.zeros++
+	}
+	if 0 == d.count || v < d.min {
+		d.min = v
+	}
+	if 0 == d.count || v > d.max {
+		d.max = v
+	}
+	d.count++
+	d.sum += v
+	delta := v - d.mean
+	d.mean += delta / float64(d.count)
+	d.m2 += delta * (v - d.mean)
+}
+
+func (d *ddSketch) clear() {
+	d.positive = ddSketchStore{}
+	d.negative = ddSketchStore{}
+	d.zeros, d.count = 0, 0
+	d.min, d.max, d.sum, d.mean, d.m2 = 0, 0, 0, 0, 0
+}
+
+func (d *ddSketch) copy() ddSketch {
+	c := *d
+	c.positive.bins = append([]int64(nil), d.positive.bins...)
+	c.negative.bins = append([]int64(nil), d.negative.bins...)
+	return c
+}
+
+// index returns the bin for the positive value v.
+func (d *ddSketch) index(v float64) int {
+	return int(math.Ceil(math.Log(v) / d.lnGamma))
+}
+
+// value returns the representative value of a bin, which is within the
+// relative accuracy of every value counted in it.
+func (d *ddSketch) value(index int) float64 {
+	return 2 * math.Exp(float64(index)*d.lnGamma) / (1 + d.gamma)
+}
+
+func (d *ddSketch) merge(o *ddSketch) error {
+	if d.gamma != o.gamma {
+		return ErrIncompatibleSketch
+	}
+	if 0 == o.count {
+		return nil
+	}
+	for i, n := range o.positive.bins {
+		if 0 != n {
+			d.positive.add(o.positive.offset+i, n, d.maxBins)
+		}
+	}
+	for i, n := range o.negative.bins {
+		if 0 != n {
+			d.negative.add(o.negative.offset+i, n, d.maxBins)
+		}
+	}
+	d.zeros += o.zeros
+	if 0 == d.count || o.min < d.min {
+		d.min = o.min
+	}
+	if 0 == d.count || o.max > d.max {
+		d.max = o.max
+	}
+	// Chan et al's parallel variant of Welford's algorithm.
+	count := d.count + o.count
+	delta := o.mean - d.mean
+	d.mean += delta * float64(o.count) / float64(count)
+	d.m2 += o.m2 + delta*delta*float64(d.count)*float64(o.count)/float64(count)
+	d.count = count
+	d.sum += o.sum
+	return nil
+}
+
+// quantile returns the value at rank p*(count-1), clamped to the exact
+// minimum and maximum.
+func (d *ddSketch) quantile(p float64) float64 {
+	if 0 == d.count {
+		return 0.0
+	}
+	if p <= 0 {
+		return d.min
+	}
+	if p >= 1 {
+		return d.max
+	}
+	rank := p * float64(d.count-1)
+	var v float64
+	var seen int64
+	found := false
+	for i := len(d.negative.bins) - 1; i >= 0 && !found; i-- {
+		seen += d.negative.bins[i]
+		if float64(seen) > rank {
+			v, found = -d.value(d.negative.offset+i), true
+		}
+	}
+	if !found {
+		seen += d.zeros
+		if float64(seen) > rank {
+			v, found = 0, true
+		}
+	}
+	for i := 0; i < len(d.positive.bins) && !found; i++ {
+		seen += d.positive.bins[i]
+		if float64(seen) > rank {
+			v, found = d.value(d.positive.offset+i), true
+		}
+	}
+	if !found || v > d.max {
+		v = d.max
+	}
+	if v < d.min {
+		v = d.min
+	}
+	return v
+}
+
+func (d *ddSketch) quantiles(ps []float64) []float64 {
+	scores := make([]float64, len(ps))
+	for i, p := range ps {
+		scores[i] = d.quantile(p)
+	}
+	return scores
+}
+
+func (d *ddSketch) size() int {
+	n := 0
+	for _, c := range d.positive.bins {
+		if 0 != c {
+			n++
+		}
+	}
+	for _, c := range d.negative.bins {
+		if 0 != c {
+			n++
+		}
+	}
+	if 0 != d.zeros {
+		n++
+	}
+	return n
+}
+
+func (d *ddSketch) variance() float64 {
+	if 0 == d.count {
+		return 0.0
+	}
+	return d.m2 / float64(d.count)
+}
+
+// ddSketchStore is a dense range of bin counts.  bins[i] counts bin
+// offset+i.
+type ddSketchStore struct {
+	bins   []int64
+	offset int
+}
+
+// add counts n values in the given bin, growing the store to cover it.  If
+// that would take more than maxBins bins, the lowest bins are collapsed into
+// one.
+func (s *ddSketchStore) add(index int, n int64, maxBins int) {
+	if 0 == len(s.bins) {
+		s.bins = []int64{n}
+		s.offset = index
+		return
+	}
+	lo, hi := s.offset, s.offset+len(s.bins)-1
+	if index < lo {
+		lo = index
+	}
+	if index > hi {
+		hi = index
+	}
+	if maxBins > 0 && hi-lo+1 > maxBins {
+		lo = hi - maxBins + 1
+	}
+	s.resize(lo, hi)
+	if index < lo {
+		index = lo
+	}
+	s.bins[index-lo] += n
+}
+
+func (s *ddSketchStore) resize(lo, hi int) {
+	if lo == s.offset && hi == s.offset+len(s.bins)-1 {
+		return
+	}
+	bins := make([]int64, hi-lo+1)
+	for i, c := range s.bins {
+		index := s.offset + i
+		if index < lo {
+			index = lo
+		}
+		bins[index-lo] += c
+	}
+	s.bins, s.offset = bins, lo
+}
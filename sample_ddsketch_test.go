@@ -0,0 +1,145 @@
+package metrics
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func BenchmarkDDSketchSampleFloat64(b *testing.B) {
+	benchmarkSampleFloat64(b, NewDDSketchSampleFloat64(0.01, 2048))
+}
+
+func TestDDSketchSampleFloat64RelativeAccuracy(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	s := NewDDSketchSampleFloat64(0.01, 0)
+	values := make([]float64, 10000)
+	for i := range values {
+		values[i] = math.Exp(r.NormFloat64() * 3) // Latency-like, spanning many orders of magnitude.
+		s.Update(values[i])
+	}
+	sort.Float64s(values)
+	for _, p := range []float64{0.5, 0.75, 0.9, 0.95, 0.99, 0.999} {
+		want := values[int(p*float64(len(values)-1))]
+		if got := s.Percentile(p); math.Abs(got-want) > 0.01*want {
+			t.Errorf("s.Percentile(%v): %v not within 1%% of %v\n", p, got, want)
+		}
+	}
+	if count := s.Count(); 10000 != count {
+		t.Errorf("s.Count(): 10000 != %v\n", count)
+	}
+	if min := s.Min(); values[0] != min {
+		t.Errorf("s.Min(): %v != %v\n", values[0], min)
+	}
+	if max := s.Max(); values[len(values)-1] != max {
+		t.Errorf("s.Max(): %v != %v\n", values[len(values)-1], max)
+	}
+}
+
+func TestDDSketchSampleFloat64Stats(t *testing.T) {
+	s := NewDDSketchSampleFloat64(0.01, 0)
+	for i := 1; i <= 10000; i++ {
+		s.Update(float64(i))
+	}
+	if mean := s.Mean(); 5000.5 != mean {
+		t.Errorf("s.Mean(): 5000.5 != %v\n", mean)
+	}
+	if sum := s.Sum(); 50005000 != sum {
+		t.Errorf("s.Sum(): 50005000 != %v\n", sum)
+	}
+	if stdDev := s.StdDev(); math.Abs(2886.751331514372-stdDev) > 1e-6 {
+		t.Errorf("s.StdDev(): 2886.751331514372 != %v\n", stdDev)
+	}
+}
+
+func TestDDSketchSampleFloat64Negative(t *testing.T) {
+	s := NewDDSketchSampleFloat64(0.01, 0)
+	for i := -50; i <= 50; i++ {
+		s.Update(float64(i))
+	}
+	if p := s.Percentile(0.5); 0 != p {
+		t.Errorf("s.Percentile(0.5): 0 != %v\n", p)
+	}
+	if p := s.Percentile(0.1); math.Abs(p+40) > 0.4 {
+		t.Errorf("s.Percentile(0.1): -40 != %v\n", p)
+	}
+}
+
+func TestDDSketchSampleFloat64NonFinite(t *testing.T) {
+	s := NewDDSketchSampleFloat64(0.01, 0)
+	s.Update(1)
+	s.Update(math.Inf(1))
+	s.Update(math.Inf(-1))
+	s.Update(math.NaN())
+	s.Update(3)
+	if c := s.Count(); 2 != c {
+		t.Errorf("s.Count(): 2 != %v\n", c)
+	}
+	if max := s.Max(); 3 != max {
+		t.Errorf("s.Max(): 3 != %v\n", max)
+	}
+	if sum := s.Sum(); 4 != sum {
+		t.Errorf("s.Sum(): 4 != %v\n", sum)
+	}
+}
+
+func TestDDSketchSampleFloat64Merge(t *testing.T) {
+	a := NewDDSketchSampleFloat64(0.01, 0)
+	b := NewDDSketchSampleFloat64(0.01, 0)
+	whole := NewDDSketchSampleFloat64(0.01, 0)
+	for i := 1; i <= 1000; i++ {
+		if i%2 == 0 {
+			a.Update(float64(i))
+		} else {
+			b.Update(float64(i))
+		}
+		whole.Update(float64(i))
+	}
+	if err := a.(*DDSketchSampleFloat64).Merge(b); nil != err {
+		t.Fatal(err)
+	}
+	if count := a.Count(); 1000 != count {
+		t.Errorf("a.Count(): 1000 != %v\n", count)
+	}
+	ps := []float64{0.1, 0.5, 0.99}
+	got, want := a.Percentiles(ps), whole.Percentiles(ps)
+	for i := range ps {
+		if got[i] != want[i] {
+			t.Errorf("percentile %v: %v != %v\n", ps[i], want[i], got[i])
+		}
+	}
+	if math.Abs(a.Variance()-whole.Variance()) > 1e-6 {
+		t.Errorf("a.Variance(): %v != %v\n", whole.Variance(), a.Variance())
+	}
+	if err := a.(*DDSketchSampleFloat64).Merge(NewDDSketchSampleFloat64(0.02, 0)); ErrIncompatibleSketch != err {
+		t.Errorf("merge with different accuracy: %v\n", err)
+	}
+}
+
+func TestDDSketchSampleFloat64MaxBins(t *testing.T) {
+	s := NewDDSketchSampleFloat64(0.01, 100)
+	for i := 1; i <= 100000; i++ {
+		s.Update(float64(i))
+	}
+	if size := s.Size(); size > 100 {
+		t.Errorf("s.Size(): %v > 100\n", size)
+	}
+	if p := s.Percentile(0.99); math.Abs(p-99000) > 0.01*99000 {
+		t.Errorf("s.Percentile(0.99): %v not within 1%% of 99000\n", p)
+	}
+}
+
+func TestDDSketchSampleFloat64Snapshot(t *testing.T) {
+	s := NewDDSketchSampleFloat64(0.01, 0)
+	s.Update(1)
+	snapshot := s.Snapshot()
+	s.Update(2)
+	if count := snapshot.Count(); 1 != count {
+		t.Errorf("snapshot.Count(): 1 != %v\n", count)
+	}
+	h := NewHistogramFloat64(s)
+	if max := h.Snapshot().Max(); 2 != max {
+		t.Errorf("h.Snapshot().Max(): 2 != %v\n", max)
+	}
+}
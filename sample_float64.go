@@ -2,6 +2,7 @@ package metrics
 
 import (
 	"math"
+	"math/bits"
 	"math/rand"
 	"sort"
 	"sync"
@@ -27,18 +28,59 @@ type SampleFloat64 interface {
 	Variance() float64
 }
 
+// ValuesAppenderFloat64s are SampleFloat64s which can append their values to
+// a slice the caller provides, as ValuesAppenders do.
+type ValuesAppenderFloat64 interface {
+	AppendValues([]float64) []float64
+}
+
+// AppendSampleFloat64Values appends the values in s to dst and returns the
+// extended slice, through s's AppendValues method if it's a
+// ValuesAppenderFloat64 and otherwise by copying its Values.
+func AppendSampleFloat64Values(dst []float64, s SampleFloat64) []float64 {
+	if a, ok := s.(ValuesAppenderFloat64); ok {
+		return a.AppendValues(dst)
+	}
+	return append(dst, s.Values()...)
+}
+
+// TimestampedSampleFloat64s are SampleFloat64s which remember when each of
+// their values was updated, so that reporters can compute statistics over
+// just the values updated since their last report and outliers can be traced
+// to when they occurred.
+type TimestampedSampleFloat64 interface {
+	SampleFloat64
+	TimestampedValues() []TimestampedValueFloat64
+	ValuesSince(time.Time) []float64
+}
+
+// TimestampedValueFloat64 is a value in a TimestampedSampleFloat64 and the
+// time it was updated.
+type TimestampedValueFloat64 struct {
+	Time  time.Time
+	Value float64
+}
+
 // ExpDecaySampleFloat64 is an exponentially-decaying SampleFloat64 using a forward-decaying
 // priority reservoir.  See Cormode et al's "Forward Decay: A Practical Time
 // Decay Model for Streaming Systems".
 //
 // <http://dimacs.rutgers.edu/~graham/pubs/papers/fwddecay.pdf>
+//
+// It is a TimestampedSampleFloat64.
 type ExpDecaySampleFloat64 struct {
-	alpha         float64
-	count         int64
-	mutex         sync.Mutex
-	reservoirSize int
-	t0, t1        time.Time
-	values        *expDecaySampleFloat64Heap
+	alpha           float64
+	count           int64
+	mutex           sync.Mutex
+	reservoirSize   int
+	rng             *rand.Rand
+	clock           Clock
+	t0, t1          time.Time
+	values          *expDecaySampleFloat64Heap
+	rescaleInterval time.Duration
+	maxAge          time.Duration
+	stable          bool
+	seq             uint64 // Of the last update, if stable
 }
 
 // NewExpDecaySampleFloat64 constructs a new exponentially-decaying SampleFloat64 with the
@@ -47,23 +89,82 @@ func NewExpDecaySampleFloat64(reservoirSize int, alpha float64) SampleFloat64 {
 	if UseNilMetrics {
 		return NilSampleFloat64{}
 	}
+	return newExpDecaySampleFloat64(reservoirSize, alpha)
+}
+
+func newExpDecaySampleFloat64(reservoirSize int, alpha float64) *ExpDecaySampleFloat64 {
 	s := &ExpDecaySampleFloat64{
-		alpha:         alpha,
-		reservoirSize: reservoirSize,
-		t0:            time.Now(),
-		values:        newExpDecaySampleFloat64Heap(reservoirSize),
+		alpha:           alpha,
+		reservoirSize:   reservoirSize,
+		clock:           DefaultClock,
+		t0:              DefaultClock.Now(),
+		values:          newExpDecaySampleFloat64Heap(reservoirSize),
+		rescaleInterval: rescaleThreshold,
+	}
+	s.t1 = s.t0.Add(s.rescaleInterval)
+	return s
+}
+
+// NewExpDecaySampleFloat64WithRand constructs a new exponentially-decaying
+// sample like NewExpDecaySampleFloat64 but draws priorities from r rather than
+// from the shared source in math/rand.  r is only used while holding the
+// sample's lock, so it needn't be safe for concurrent use and must not be
+// shared with other samples.
+func NewExpDecaySampleFloat64WithRand(reservoirSize int, alpha float64, r *rand.Rand) SampleFloat64 {
+	s := NewExpDecaySampleFloat64(reservoirSize, alpha)
+	if es, ok := s.(*ExpDecaySampleFloat64); ok {
+		es.rng = r
 	}
-	s.t1 = s.t0.Add(rescaleThreshold)
 	return s
 }
 
+// NewExpDecaySampleFloat64WithClock constructs a new exponentially-decaying sample
+// like NewExpDecaySampleFloat64 but which reads the time from the given Clock.
+func NewExpDecaySampleFloat64WithClock(reservoirSize int, alpha float64, c Clock) SampleFloat64 {
+	s := NewExpDecaySampleFloat64(reservoirSize, alpha)
+	if es, ok := s.(*ExpDecaySampleFloat64); ok {
+		es.clock = c
+		es.t0 = c.Now()
+		es.t1 = es.t0.Add(es.rescaleInterval)
+	}
+	return s
+}
+
+// NewExpDecaySampleFloat64WithOptions constructs a new exponentially-decaying
+// SampleFloat64 like NewExpDecaySampleFloat64 but configured by the given
+// options.
+func NewExpDecaySampleFloat64WithOptions(reservoirSize int, alpha float64, o ExpDecaySampleOptions) SampleFloat64 {
+	s := NewExpDecaySampleFloat64(reservoirSize, alpha)
+	if es, ok := s.(*ExpDecaySampleFloat64); ok {
+		if o.RescaleInterval > 0 {
+			es.rescaleInterval = o.RescaleInterval
+			es.t1 = es.t0.Add(es.rescaleInterval)
+		}
+		es.maxAge = o.MaxAge
+		es.stable = o.StablePriorities
+	}
+	return s
+}
+
+// AppendValues appends the values in the SampleFloat64 to dst and returns the
+// extended slice, so that callers can reuse dst rather than allocating a copy
+// each time as Values does.
+func (s *ExpDecaySampleFloat64) AppendValues(dst []float64) []float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, v := range s.values.Values() {
+		dst = append(dst, v.v)
+	}
+	return dst
+}
+
 // Clear clears all SampleFloat64s.
 func (s *ExpDecaySampleFloat64) Clear() {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	s.count = 0
-	s.t0 = time.Now()
-	s.t1 = s.t0.Add(rescaleThreshold)
+	s.t0 = s.clock.Now()
+	s.t1 = s.t0.Add(s.rescaleInterval)
 	s.values.Clear()
 }
 
@@ -78,29 +179,39 @@ func (s *ExpDecaySampleFloat64) Count() int64 {
 // Max returns the maximum value in the SampleFloat64, which may not be the maximum
 // value ever to be part of the SampleFloat64.
 func (s *ExpDecaySampleFloat64) Max() float64 {
-	return SampleFloat64Max(s.Values())
+	buf := s.pooledValues()
+	defer float64Buffers.Put(buf)
+	return SampleFloat64Max(*buf)
 }
 
 // Mean returns the mean of the values in the SampleFloat64.
 func (s *ExpDecaySampleFloat64) Mean() float64 {
-	return SampleFloat64Mean(s.Values())
+	buf := s.pooledValues()
+	defer float64Buffers.Put(buf)
+	return SampleFloat64Mean(*buf)
 }
 
 // Min returns the minimum value in the SampleFloat64, which may not be the minimum
 // value ever to be part of the SampleFloat64.
 func (s *ExpDecaySampleFloat64) Min() float64 {
-	return SampleFloat64Min(s.Values())
+	buf := s.pooledValues()
+	defer float64Buffers.Put(buf)
+	return SampleFloat64Min(*buf)
 }
 
 // Percentile returns an arbitrary percentile of values in the SampleFloat64.
 func (s *ExpDecaySampleFloat64) Percentile(p float64) float64 {
-	return SampleFloat64Percentile(s.Values(), p)
+	buf := s.pooledValues()
+	defer float64Buffers.Put(buf)
+	return SampleFloat64Percentile(*buf, p)
 }
 
 // Percentiles returns a slice of arbitrary percentiles of values in the
 // SampleFloat64.
 func (s *ExpDecaySampleFloat64) Percentiles(ps []float64) []float64 {
-	return SampleFloat64Percentiles(s.Values(), ps)
+	buf := s.pooledValues()
+	defer float64Buffers.Put(buf)
+	return SampleFloat64Percentiles(*buf, ps)
 }
 
 // Size returns the size of the SampleFloat64, which is at most the reservoir size.
@@ -112,12 +223,19 @@ func (s *ExpDecaySampleFloat64) Size() int {
 
 // Snapshot returns a read-only copy of the SampleFloat64.
 func (s *ExpDecaySampleFloat64) Snapshot() SampleFloat64 {
+	defer observeSampleSnapshot(selfStart())
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	vals := s.values.Values()
-	values := make([]float64, len(vals))
-	for i, v := range vals {
-		values[i] = v.v
+	now := s.clock.Now()
+	if now.After(s.t1) {
+		s.rescale(now)
+	}
+	if s.maxAge > 0 {
+		s.expire(now.Add(-s.maxAge))
+	}
+	values := make([]float64, 0, s.values.Size())
+	for _, v := range s.values.Values() {
+		values = append(values, v.v)
 	}
 	return &SampleFloat64Snapshot{
 		count:  s.count,
@@ -127,34 +245,72 @@ func (s *ExpDecaySampleFloat64) Snapshot() SampleFloat64 {
 
 // StdDev returns the standard deviation of the values in the SampleFloat64.
 func (s *ExpDecaySampleFloat64) StdDev() float64 {
-	return SampleFloat64StdDev(s.Values())
+	buf := s.pooledValues()
+	defer float64Buffers.Put(buf)
+	return SampleFloat64StdDev(*buf)
 }
 
 // Sum returns the sum of the values in the SampleFloat64.
 func (s *ExpDecaySampleFloat64) Sum() float64 {
-	return SampleFloat64Sum(s.Values())
+	buf := s.pooledValues()
+	defer float64Buffers.Put(buf)
+	return SampleFloat64Sum(*buf)
+}
+
+// TimestampedValues returns a copy of the values in the SampleFloat64 with
+// the times they were updated, oldest first.
+func (s *ExpDecaySampleFloat64) TimestampedValues() []TimestampedValueFloat64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	values := s.values.Values()
+	tvs := make([]TimestampedValueFloat64, len(values))
+	for i, v := range values {
+		tvs[i] = TimestampedValueFloat64{Time: time.Unix(0, v.t), Value: v.v}
+	}
+	sort.SliceStable(tvs, func(i, j int) bool { return tvs[i].Time.Before(tvs[j].Time) })
+	return tvs
 }
 
 // Update SampleFloat64s a new value.
 func (s *ExpDecaySampleFloat64) Update(v float64) {
-	s.update(time.Now(), v)
+	s.update(s.clock.Now(), v)
 }
 
 // Values returns a copy of the values in the SampleFloat64.
 func (s *ExpDecaySampleFloat64) Values() []float64 {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-	vals := s.values.Values()
-	values := make([]float64, len(vals))
-	for i, v := range vals {
-		values[i] = v.v
+	return s.AppendValues(make([]float64, 0, s.Size()))
+}
+
+// ValuesSince returns a copy of the values in the SampleFloat64 updated at
+// or after the given time, oldest first.
+func (s *ExpDecaySampleFloat64) ValuesSince(t time.Time) []float64 {
+	var values []float64
+	for _, tv := range s.TimestampedValues() {
+		if !tv.Time.Before(t) {
+			values = append(values, tv.Value)
+		}
 	}
 	return values
 }
 
 // Variance returns the variance of the values in the SampleFloat64.
 func (s *ExpDecaySampleFloat64) Variance() float64 {
-	return SampleFloat64Variance(s.Values())
+	buf := s.pooledValues()
+	defer float64Buffers.Put(buf)
+	return SampleFloat64Variance(*buf)
+}
+
+// float64Buffers holds the slices ExpDecaySampleFloat64 copies its values
+// into to compute statistics, so that reading them from a live histogram
+// doesn't allocate.
+var float64Buffers = sync.Pool{New: func() interface{} { return new([]float64) }}
+
+// pooledValues copies the values into a buffer from float64Buffers, which the
+// caller must put back once it's done with them.
+func (s *ExpDecaySampleFloat64) pooledValues() *[]float64 {
+	buf := float64Buffers.Get().(*[]float64)
+	*buf = s.AppendValues((*buf)[:0])
+	return buf
 }
 
 // update SampleFloat64s a new value at a particular timestamp.  This is a method all
@@ -166,18 +322,45 @@ func (s *ExpDecaySampleFloat64) update(t time.Time, v float64) {
 	if s.values.Size() == s.reservoirSize {
 		s.values.Pop()
 	}
+	k := math.Exp(t.Sub(s.t0).Seconds()*s.alpha) / randFloat64(s.rng)
+	if s.stable {
+		k = stablePriority(k)
+		s.seq++
+	}
 	s.values.Push(expDecaySampleFloat64{
-		k: math.Exp(t.Sub(s.t0).Seconds()*s.alpha) / rand.Float64(),
-		v: v,
+		k:   k,
+		seq: s.seq,
+		t:   t.UnixNano(),
+		v:   v,
 	})
 	if t.After(s.t1) {
-		values := s.values.Values()
-		t0 := s.t0
-		s.values.Clear()
-		s.t0 = t
-		s.t1 = s.t0.Add(rescaleThreshold)
-		for _, v := range values {
-			v.k = v.k * math.Exp(-s.alpha*s.t0.Sub(t0).Seconds())
+		s.rescale(t)
+	}
+}
+
+// rescale rebases the priorities of the values in the sample on the given
+// time, which keeps them from overflowing.  The caller must hold the lock.
+func (s *ExpDecaySampleFloat64) rescale(t time.Time) {
+	values := s.values.Values()
+	t0 := s.t0
+	s.values.Clear()
+	s.t0 = t
+	s.t1 = s.t0.Add(s.rescaleInterval)
+	countRescale()
+	for _, v := range values {
+		v.k = v.k * math.Exp(-s.alpha*s.t0.Sub(t0).Seconds())
+		s.values.Push(v)
+	}
+}
+
+// expire drops the values updated before the given time.  The caller must
+// hold the lock.
+func (s *ExpDecaySampleFloat64) expire(t time.Time) {
+	cutoff := t.UnixNano()
+	values := s.values.Values()
+	s.values.Clear()
+	for _, v := range values {
+		if v.t >= cutoff {
 			s.values.Push(v)
 		}
 	}
@@ -186,6 +369,9 @@ func (s *ExpDecaySampleFloat64) update(t time.Time, v float64) {
 // NilSampleFloat64 is a no-op SampleFloat64.
 type NilSampleFloat64 struct{}
 
+// AppendValues returns dst unchanged.
+func (NilSampleFloat64) AppendValues(dst []float64) []float64 { return dst }
+
 // Clear is a no-op.
 func (NilSampleFloat64) Clear() {}
 
@@ -273,34 +459,115 @@ func SampleFloat64Percentile(values float64Slice, p float64) float64 {
 }
 
 // SampleFloat64Percentiles returns a slice of arbitrary percentiles of the slice of
-// float64.
+// float64, computed by PercentileInterpolation.
 func SampleFloat64Percentiles(values float64Slice, ps []float64) []float64 {
+	return SampleFloat64PercentilesWithInterpolation(values, ps, PercentileInterpolation)
+}
+
+// SampleFloat64PercentilesWithInterpolation returns a slice of arbitrary percentiles of
+// the slice of float64, computed by the given Interpolation.
+func SampleFloat64PercentilesWithInterpolation(values float64Slice, ps []float64, m Interpolation) []float64 {
 	scores := make([]float64, len(ps))
 	size := len(values)
 	if size > 0 {
-		sort.Sort(values)
+		if len(ps) <= percentileSelectMaxPercentiles && size >= percentileSelectMinSize {
+			selectFloat64Percentiles(values, ps, m)
+		} else {
+			sort.Sort(values)
+		}
 		for i, p := range ps {
-			pos := p * float64(size+1)
-			if pos < 1.0 {
-				scores[i] = float64(values[0])
-			} else if pos >= float64(size) {
-				scores[i] = float64(values[size-1])
-			} else {
-				lower := float64(values[int(pos)-1])
-				upper := float64(values[int(pos)])
-				scores[i] = lower + (pos-math.Floor(pos))*(upper-lower)
-			}
+			lo, hi, frac := percentilePosition(size, p, m)
+			lower := float64(values[lo])
+			upper := float64(values[hi])
+			scores[i] = lower + frac*(upper-lower)
 		}
 	}
 	return scores
 }
 
+// selectFloat64Percentiles partially orders values so that every element
+// SampleFloat64Percentiles reads for the given percentiles is where it would be if
+// values were sorted.  The positions are selected in ascending order, each
+// within the range left over by the last.
+func selectFloat64Percentiles(values float64Slice, ps []float64, m Interpolation) {
+	from := 0
+	for _, k := range percentileIndexes(len(values), ps, m) {
+		selectFloat64(values, from, len(values)-1, k)
+		from = k
+	}
+}
+
+// selectFloat64 moves the k-th smallest element of values[lo:hi+1] to index k,
+// with smaller elements before it and larger ones after, using quickselect
+// with a median-of-three pivot.  It falls back to sorting if partitioning
+// stops making progress.
+func selectFloat64(values float64Slice, lo, hi, k int) {
+	for budget := 2 * bits.Len(uint(hi-lo+1)); lo < hi; budget-- {
+		if 0 == budget {
+			sort.Sort(values[lo : hi+1])
+			return
+		}
+		mid := lo + (hi-lo)/2
+		if values[mid] < values[lo] {
+			values[mid], values[lo] = values[lo], values[mid]
+		}
+		if values[hi] < values[lo] {
+			values[hi], values[lo] = values[lo], values[hi]
+		}
+		if values[hi] < values[mid] {
+			values[hi], values[mid] = values[mid], values[hi]
+		}
+		pivot := values[mid]
+		i, j := lo, hi
+		for i <= j {
+			for values[i] < pivot {
+				i++
+			}
+			for pivot < values[j] {
+				j--
+			}
+			if i <= j {
+				values[i], values[j] = values[j], values[i]
+				i++
+				j--
+			}
+		}
+		switch {
+		case k <= j:
+			hi = j
+		case k >= i:
+			lo = i
+		default:
+			return
+		}
+	}
+}
+
 // SampleFloat64Snapshot is a read-only copy of another SampleFloat64.
+//
+// Snapshots are immutable and safe for concurrent use: their percentiles
+// are read from a sorted copy of the values, made on first use, so that the
+// values keep their order, and their max, min, sum, mean and variance are
+// computed together on first use, so that reporters asking for each of them
+// of the same snapshot don't each go through the values again.
 type SampleFloat64Snapshot struct {
-	count  int64
-	values []float64
+	count     int64
+	values    []float64
+	once      sync.Once
+	sorted    float64Slice
+	statsOnce sync.Once
+	stats     sampleFloat64Stats
+}
+
+// sampleFloat64Stats are the statistics of a SampleFloat64Snapshot, computed on first use.
+type sampleFloat64Stats struct {
+	max, min, sum  float64
+	mean, variance float64
 }
 
+// NewSampleFloat64Snapshot constructs a new SampleFloat64Snapshot of the
+// given values, which it retains, so the caller mustn't modify them
+// afterwards.  Use NewSampleFloat64SnapshotCopy to keep using them.
 func NewSampleFloat64Snapshot(count int64, values []float64) *SampleFloat64Snapshot {
 	return &SampleFloat64Snapshot{
 		count:  count,
@@ -308,33 +575,56 @@ func NewSampleFloat64Snapshot(count int64, values []float64) *SampleFloat64Snaps
 	}
 }
 
-// Clear panics.
+// NewSampleFloat64SnapshotCopy constructs a new SampleFloat64Snapshot of a
+// copy of the given values, which the caller remains free to modify.
+func NewSampleFloat64SnapshotCopy(count int64, values []float64) *SampleFloat64Snapshot {
+	return NewSampleFloat64Snapshot(count, append([]float64(nil), values...))
+}
+
+// AppendValues appends the values at the time the snapshot was taken to dst.
+func (s *SampleFloat64Snapshot) AppendValues(dst []float64) []float64 {
+	return append(dst, s.values...)
+}
+
+// Clear panics unless PanicOnSnapshotMutation is false.
 func (*SampleFloat64Snapshot) Clear() {
-	panic("Clear called on a SampleFloat64Snapshot")
+	snapshotMisuse("Clear called on a SampleFloat64Snapshot")
 }
 
 // Count returns the count of inputs at the time the snapshot was taken.
 func (s *SampleFloat64Snapshot) Count() int64 { return s.count }
 
 // Max returns the maximal value at the time the snapshot was taken.
-func (s *SampleFloat64Snapshot) Max() float64 { return SampleFloat64Max(s.values) }
+func (s *SampleFloat64Snapshot) Max() float64 { return s.statistics().max }
 
 // Mean returns the mean value at the time the snapshot was taken.
-func (s *SampleFloat64Snapshot) Mean() float64 { return SampleFloat64Mean(s.values) }
+func (s *SampleFloat64Snapshot) Mean() float64 { return s.statistics().mean }
 
 // Min returns the minimal value at the time the snapshot was taken.
-func (s *SampleFloat64Snapshot) Min() float64 { return SampleFloat64Min(s.values) }
+func (s *SampleFloat64Snapshot) Min() float64 { return s.statistics().min }
 
 // Percentile returns an arbitrary percentile of values at the time the
 // snapshot was taken.
 func (s *SampleFloat64Snapshot) Percentile(p float64) float64 {
-	return SampleFloat64Percentile(s.values, p)
+	return s.Percentiles([]float64{p})[0]
 }
 
 // Percentiles returns a slice of arbitrary percentiles of values at the time
 // the snapshot was taken.
 func (s *SampleFloat64Snapshot) Percentiles(ps []float64) []float64 {
-	return SampleFloat64Percentiles(s.values, ps)
+	s.once.Do(func() {
+		s.sorted = append(float64Slice(nil), s.values...)
+		sort.Sort(s.sorted)
+	})
+	scores := make([]float64, len(ps))
+	if size := len(s.sorted); size > 0 {
+		for i, p := range ps {
+			lo, hi, frac := percentilePosition(size, p, PercentileInterpolation)
+			lower, upper := s.sorted[lo], s.sorted[hi]
+			scores[i] = lower + frac*(upper-lower)
+		}
+	}
+	return scores
 }
 
 // Size returns the size of the SampleFloat64 at the time the snapshot was taken.
@@ -345,14 +635,14 @@ func (s *SampleFloat64Snapshot) Snapshot() SampleFloat64 { return s }
 
 // StdDev returns the standard deviation of values at the time the snapshot was
 // taken.
-func (s *SampleFloat64Snapshot) StdDev() float64 { return SampleFloat64StdDev(s.values) }
+func (s *SampleFloat64Snapshot) StdDev() float64 { return math.Sqrt(s.statistics().variance) }
 
 // Sum returns the sum of values at the time the snapshot was taken.
-func (s *SampleFloat64Snapshot) Sum() float64 { return SampleFloat64Sum(s.values) }
+func (s *SampleFloat64Snapshot) Sum() float64 { return s.statistics().sum }
 
-// Update panics.
+// Update panics unless PanicOnSnapshotMutation is false.
 func (*SampleFloat64Snapshot) Update(float64) {
-	panic("Update called on a SampleFloat64Snapshot")
+	snapshotMisuse("Update called on a SampleFloat64Snapshot")
 }
 
 // Values returns a copy of the values in the SampleFloat64.
@@ -363,34 +653,73 @@ func (s *SampleFloat64Snapshot) Values() []float64 {
 }
 
 // Variance returns the variance of values at the time the snapshot was taken.
-func (s *SampleFloat64Snapshot) Variance() float64 { return SampleFloat64Variance(s.values) }
+func (s *SampleFloat64Snapshot) Variance() float64 { return s.statistics().variance }
+
+func (s *SampleFloat64Snapshot) statistics() *sampleFloat64Stats {
+	s.statsOnce.Do(func() {
+		s.stats = sampleFloat64Stats{
+			max:      SampleFloat64Max(s.values),
+			min:      SampleFloat64Min(s.values),
+			sum:      SampleFloat64Sum(s.values),
+			mean:     SampleFloat64Mean(s.values),
+			variance: SampleFloat64Variance(s.values),
+		}
+	})
+	return &s.stats
+}
 
 // SampleFloat64StdDev returns the standard deviation of the slice of float64.
 func SampleFloat64StdDev(values []float64) float64 {
 	return math.Sqrt(SampleFloat64Variance(values))
 }
 
-// SampleFloat64Sum returns the sum of the slice of float64.
+// SampleFloat64Sum returns the sum of the slice of float64, using
+// compensated summation so that precision isn't lost adding values of very
+// different magnitudes.
 func SampleFloat64Sum(values []float64) float64 {
-	var sum float64
+	var sum kahanSum
 	for _, v := range values {
-		sum += v
+		sum.Add(v)
 	}
-	return sum
+	return sum.Sum()
 }
 
-// SampleFloat64Variance returns the variance of the slice of float64.
+// kahanSum accumulates float64s with Neumaier's variant of Kahan summation,
+// carrying the low-order bits lost from each addition in a separate
+// compensation term.
+type kahanSum struct {
+	sum, comp float64
+}
+
+func (k *kahanSum) Add(v float64) {
+	t := k.sum + v
+	if math.Abs(k.sum) >= math.Abs(v) {
+		k.comp += (k.sum - t) + v
+	} else {
+		k.comp += (v - t) + k.sum
+	}
+	k.sum = t
+}
+
+func (k *kahanSum) Sum() float64 {
+	return k.sum + k.comp
+}
+
+// SampleFloat64Variance returns the variance of the slice of float64,
+// computed in two passes with a correction term which cancels out the
+// rounding error in the mean.
 func SampleFloat64Variance(values []float64) float64 {
 	if 0 == len(values) {
 		return 0.0
 	}
 	m := SampleFloat64Mean(values)
-	var sum float64
+	var sum, comp float64
 	for _, v := range values {
 		d := float64(v) - m
 		sum += d * d
+		comp += d
 	}
-	return sum / float64(len(values))
+	return (sum - comp*comp/float64(len(values))) / float64(len(values))
 }
 
 // A uniform SampleFloat64 using Vitter's Algorithm R.
@@ -400,6 +729,7 @@ type UniformSampleFloat64 struct {
 	count         int64
 	mutex         sync.Mutex
 	reservoirSize int
+	rng           *rand.Rand
 	values        []float64
 }
 
@@ -415,6 +745,26 @@ func NewUniformSampleFloat64(reservoirSize int) SampleFloat64 {
 	}
 }
 
+// NewUniformSampleFloat64WithRand constructs a new uniform sample like
+// NewUniformSampleFloat64 but draws from r rather than from the shared source
+// in math/rand.  r is only used while holding the sample's lock, so it needn't
+// be safe for concurrent use and must not be shared with other samples.
+func NewUniformSampleFloat64WithRand(reservoirSize int, r *rand.Rand) SampleFloat64 {
+	s := NewUniformSampleFloat64(reservoirSize)
+	if us, ok := s.(*UniformSampleFloat64); ok {
+		us.rng = r
+	}
+	return s
+}
+
+// AppendValues appends the values in the SampleFloat64 to dst and returns the
+// extended slice.
+func (s *UniformSampleFloat64) AppendValues(dst []float64) []float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append(dst, s.values...)
+}
+
 // Clear clears all SampleFloat64s.
 func (s *UniformSampleFloat64) Clear() {
 	s.mutex.Lock()
@@ -478,6 +828,7 @@ func (s *UniformSampleFloat64) Size() int {
 
 // Snapshot returns a read-only copy of the SampleFloat64.
 func (s *UniformSampleFloat64) Snapshot() SampleFloat64 {
+	defer observeSampleSnapshot(selfStart())
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	values := make([]float64, len(s.values))
@@ -510,7 +861,7 @@ func (s *UniformSampleFloat64) Update(v float64) {
 	if len(s.values) < s.reservoirSize {
 		s.values = append(s.values, v)
 	} else {
-		r := rand.Int63n(s.count)
+		r := randInt63n(s.rng, s.count)
 		if r < int64(len(s.values)) {
 			s.values[int(r)] = v
 		}
@@ -535,8 +886,10 @@ func (s *UniformSampleFloat64) Variance() float64 {
 
 // expDecaySampleFloat64 represents an individual SampleFloat64 in a heap.
 type expDecaySampleFloat64 struct {
-	k float64
-	v float64
+	k   float64
+	seq uint64 // Breaks ties between equal priorities, if the sample is stable
+	t   int64  // UnixNano of the update
+	v   float64
 }
 
 func newExpDecaySampleFloat64Heap(reservoirSize int) *expDecaySampleFloat64Heap {
@@ -582,7 +935,7 @@ func (h *expDecaySampleFloat64Heap) Values() []expDecaySampleFloat64 {
 func (h *expDecaySampleFloat64Heap) up(j int) {
 	for {
 		i := (j - 1) / 2 // parent
-		if i == j || !(h.s[j].k < h.s[i].k) {
+		if i == j || !h.less(j, i) {
 			break
 		}
 		h.s[i], h.s[j] = h.s[j], h.s[i]
@@ -597,10 +950,10 @@ func (h *expDecaySampleFloat64Heap) down(i, n int) {
 			break
 		}
 		j := j1 // left child
-		if j2 := j1 + 1; j2 < n && !(h.s[j1].k < h.s[j2].k) {
+		if j2 := j1 + 1; j2 < n && !h.less(j1, j2) {
 			j = j2 // = 2*i + 2  // right child
 		}
-		if !(h.s[j].k < h.s[i].k) {
+		if !h.less(j, i) {
 			break
 		}
 		h.s[i], h.s[j] = h.s[j], h.s[i]
@@ -608,6 +961,10 @@ func (h *expDecaySampleFloat64Heap) down(i, n int) {
 	}
 }
 
+func (h *expDecaySampleFloat64Heap) less(i, j int) bool {
+	return h.s[i].k < h.s[j].k || h.s[i].k == h.s[j].k && h.s[i].seq < h.s[j].seq
+}
+
 type float64Slice []float64
 
 func (p float64Slice) Len() int           { return len(p) }
@@ -1,8 +1,10 @@
 package metrics
 
 import (
+	"math"
 	"math/rand"
 	"runtime"
+	"sync"
 	"testing"
 	"time"
 )
@@ -32,8 +34,7 @@ func BenchmarkUniformSampleFloat641028(b *testing.B) {
 }
 
 func TestExpDecaySampleFloat6410(t *testing.T) {
-	rand.Seed(1)
-	s := NewExpDecaySampleFloat64(100, 0.99)
+	s := NewExpDecaySampleFloat64WithRand(100, 0.99, rand.New(rand.NewSource(1)))
 	for i := 0; i < 10; i++ {
 		s.Update(float64(i))
 	}
@@ -54,8 +55,7 @@ func TestExpDecaySampleFloat6410(t *testing.T) {
 }
 
 func TestExpDecaySampleFloat64100(t *testing.T) {
-	rand.Seed(1)
-	s := NewExpDecaySampleFloat64(1000, 0.01)
+	s := NewExpDecaySampleFloat64WithRand(1000, 0.01, rand.New(rand.NewSource(1)))
 	for i := 0; i < 100; i++ {
 		s.Update(float64(i))
 	}
@@ -76,8 +76,7 @@ func TestExpDecaySampleFloat64100(t *testing.T) {
 }
 
 func TestExpDecaySampleFloat641000(t *testing.T) {
-	rand.Seed(1)
-	s := NewExpDecaySampleFloat64(100, 0.99)
+	s := NewExpDecaySampleFloat64WithRand(100, 0.99, rand.New(rand.NewSource(1)))
 	for i := 0; i < 1000; i++ {
 		s.Update(float64(i))
 	}
@@ -102,8 +101,7 @@ func TestExpDecaySampleFloat641000(t *testing.T) {
 // The priority becomes +Inf quickly after starting if this is done,
 // effectively freezing the set of samples until a rescale step happens.
 func TestExpDecaySampleFloat64NanosecondRegression(t *testing.T) {
-	rand.Seed(1)
-	s := NewExpDecaySampleFloat64(100, 0.99)
+	s := NewExpDecaySampleFloat64WithRand(100, 0.99, rand.New(rand.NewSource(1)))
 	for i := 0; i < 100; i++ {
 		s.Update(10)
 	}
@@ -133,10 +131,91 @@ func TestExpDecaySampleFloat64Rescale(t *testing.T) {
 	}
 }
 
+func TestExpDecaySampleFloat64RescaleInterval(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := ClockFunc(func() time.Time { return now })
+	s := NewExpDecaySampleFloat64WithOptions(100, 0.99, ExpDecaySampleOptions{RescaleInterval: time.Minute}).(*ExpDecaySampleFloat64)
+	s.clock, s.t0, s.t1 = c, now, now.Add(time.Minute)
+	for i := 1; i <= 10; i++ {
+		s.Update(float64(i))
+	}
+	now = now.Add(2 * time.Minute)
+	snapshot := s.Snapshot()
+	if !s.t0.Equal(now) {
+		t.Errorf("s.t0: %v != %v\n", now, s.t0)
+	}
+	if count := snapshot.Count(); 10 != count {
+		t.Errorf("snapshot.Count(): 10 != %v\n", count)
+	}
+	for _, v := range s.values.Values() {
+		if v.k == 0.0 || math.IsInf(v.k, 0) {
+			t.Fatalf("v.k: %v\n", v.k)
+		}
+	}
+}
+
+func TestExpDecaySampleFloat64MaxAge(t *testing.T) {
+	now := time.Unix(1000, 0)
+	s := NewExpDecaySampleFloat64WithOptions(100, 0.99, ExpDecaySampleOptions{MaxAge: time.Minute}).(*ExpDecaySampleFloat64)
+	s.clock = ClockFunc(func() time.Time { return now })
+	for i := 1; i <= 10; i++ {
+		now = now.Add(10 * time.Second)
+		s.Update(float64(i))
+	}
+	if size := s.Snapshot().Size(); 7 != size {
+		t.Errorf("s.Snapshot().Size(): 7 != %v\n", size)
+	}
+	now = now.Add(time.Hour)
+	snapshot := s.Snapshot()
+	if size := snapshot.Size(); 0 != size {
+		t.Errorf("snapshot.Size(): 0 != %v\n", size)
+	}
+	if count := snapshot.Count(); 10 != count {
+		t.Errorf("snapshot.Count(): 10 != %v\n", count)
+	}
+}
+
+func TestExpDecaySampleFloat64StablePriorities(t *testing.T) {
+	now := time.Unix(1000, 0)
+	s := NewExpDecaySampleFloat64WithOptions(10, 0.015, ExpDecaySampleOptions{StablePriorities: true}).(*ExpDecaySampleFloat64)
+	s.clock = ClockFunc(func() time.Time { return now })
+	s.t0, s.t1 = now, now.Add(time.Hour)
+	s.rng = rand.New(constSource(0)) // every priority infinite, so ties
+	for i := 1; i <= 100; i++ {
+		s.Update(float64(i))
+	}
+	if min := s.Snapshot().Min(); 91 != min {
+		t.Errorf("s.Snapshot().Min(): 91 != %v\n", min)
+	}
+	for _, v := range s.values.Values() {
+		if math.IsInf(v.k, 0) {
+			t.Fatalf("priority: want finite != %v\n", v.k)
+		}
+	}
+}
+
+func TestExpDecaySampleFloat64ValuesSince(t *testing.T) {
+	now := time.Unix(1000, 0)
+	s := NewExpDecaySampleFloat64WithClock(100, 0.015, ClockFunc(func() time.Time { return now })).(TimestampedSampleFloat64)
+	for i := 1; i <= 5; i++ {
+		now = now.Add(time.Second)
+		s.Update(float64(i))
+	}
+	if values := s.ValuesSince(time.Unix(1003, 0)); 3 != len(values) || 3 != values[0] || 5 != values[2] {
+		t.Errorf("s.ValuesSince(1003): [3 4 5] != %v\n", values)
+	}
+	tvs := s.TimestampedValues()
+	if 5 != len(tvs) {
+		t.Fatalf("len(s.TimestampedValues()): 5 != %v\n", len(tvs))
+	}
+	if tv := tvs[0]; !time.Unix(1001, 0).Equal(tv.Time) || 1 != tv.Value {
+		t.Errorf("tvs[0]: {1001 1} != %v\n", tv)
+	}
+}
+
 func TestExpDecaySampleFloat64Snapshot(t *testing.T) {
 	now := time.Now()
-	rand.Seed(1)
-	s := NewExpDecaySampleFloat64(100, 0.99)
+	s := NewExpDecaySampleFloat64WithRand(100, 0.99, rand.New(rand.NewSource(1)))
 	for i := 1; i <= 10000; i++ {
 		s.(*ExpDecaySampleFloat64).update(now.Add(time.Duration(i)), float64(i))
 	}
@@ -147,17 +226,68 @@ func TestExpDecaySampleFloat64Snapshot(t *testing.T) {
 
 func TestExpDecaySampleFloat64Statistics(t *testing.T) {
 	now := time.Now()
-	rand.Seed(1)
-	s := NewExpDecaySampleFloat64(100, 0.99)
+	s := NewExpDecaySampleFloat64WithRand(100, 0.99, rand.New(rand.NewSource(1)))
 	for i := 1; i <= 10000; i++ {
 		s.(*ExpDecaySampleFloat64).update(now.Add(time.Duration(i)), float64(i))
 	}
 	testExpDecaySampleFloat64Statistics(t, s)
 }
 
+func TestSampleFloat64AppendValues(t *testing.T) {
+	for _, s := range []SampleFloat64{
+		NewExpDecaySampleFloat64WithRand(100, 0.99, rand.New(rand.NewSource(1))),
+		NewUniformSampleFloat64WithRand(100, rand.New(rand.NewSource(1))),
+	} {
+		for i := 1; i <= 10; i++ {
+			s.Update(float64(i))
+		}
+		dst := make([]float64, 1, 32)
+		got := AppendSampleFloat64Values(dst, s)
+		if 11 != len(got) || 0 != got[0] {
+			t.Fatalf("%T: 11 != len(%v)\n", s, got)
+		}
+		if &dst[0] != &got[0] {
+			t.Errorf("%T: AppendValues didn't reuse dst\n", s)
+		}
+		var sum float64
+		for _, v := range got[1:] {
+			sum += v
+		}
+		if 55 != sum {
+			t.Errorf("%T: 55 != %v\n", s, sum)
+		}
+		if snapshot := AppendSampleFloat64Values(nil, s.Snapshot()); 10 != len(snapshot) {
+			t.Errorf("%T: 10 != len(%v)\n", s, snapshot)
+		}
+		if values := AppendSampleFloat64Values(nil, struct{ SampleFloat64 }{s}); 10 != len(values) {
+			t.Errorf("%T without AppendValues: 10 != len(%v)\n", s, values)
+		}
+	}
+	if got := (NilSampleFloat64{}).AppendValues(nil); 0 != len(got) {
+		t.Errorf("NilSampleFloat64: 0 != len(%v)\n", got)
+	}
+}
+
+func TestExpDecaySampleFloat64StatisticsAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool drops buffers at random under the race detector")
+	}
+	s := NewExpDecaySampleFloat64WithRand(100, 0.99, rand.New(rand.NewSource(1)))
+	for i := 1; i <= 1000; i++ {
+		s.Update(float64(i))
+	}
+	s.Max()
+	if n := testing.AllocsPerRun(100, func() {
+		s.Max()
+		s.Mean()
+		s.Variance()
+	}); 0 != n {
+		t.Errorf("%v allocations\n", n)
+	}
+}
+
 func TestUniformSampleFloat64(t *testing.T) {
-	rand.Seed(1)
-	s := NewUniformSampleFloat64(100)
+	s := NewUniformSampleFloat64WithRand(100, rand.New(rand.NewSource(1)))
 	for i := 0; i < 1000; i++ {
 		s.Update(float64(i))
 	}
@@ -177,9 +307,36 @@ func TestUniformSampleFloat64(t *testing.T) {
 	}
 }
 
+func TestSampleFloat64WithRand(t *testing.T) {
+	now := time.Now()
+	for _, f := range []func() SampleFloat64{
+		func() SampleFloat64 { return NewExpDecaySampleFloat64WithRand(100, 0.99, rand.New(rand.NewSource(2))) },
+		func() SampleFloat64 { return NewUniformSampleFloat64WithRand(100, rand.New(rand.NewSource(2))) },
+	} {
+		s1, s2 := f(), f()
+		for i := 1; i <= 10000; i++ {
+			if es, ok := s1.(*ExpDecaySampleFloat64); ok {
+				es.update(now.Add(time.Duration(i)), float64(i))
+				s2.(*ExpDecaySampleFloat64).update(now.Add(time.Duration(i)), float64(i))
+			} else {
+				s1.Update(float64(i))
+				s2.Update(float64(i))
+			}
+		}
+		v1, v2 := s1.Values(), s2.Values()
+		if len(v1) != len(v2) {
+			t.Fatalf("len(s.Values()): %v != %v\n", len(v1), len(v2))
+		}
+		for i := range v1 {
+			if v1[i] != v2[i] {
+				t.Errorf("s.Values()[%d]: %v != %v\n", i, v1[i], v2[i])
+			}
+		}
+	}
+}
+
 func TestUniformSampleFloat64IncludesTail(t *testing.T) {
-	rand.Seed(1)
-	s := NewUniformSampleFloat64(100)
+	s := NewUniformSampleFloat64WithRand(100, rand.New(rand.NewSource(1)))
 	max := 100
 	for i := 0; i < max; i++ {
 		s.Update(float64(i))
@@ -196,7 +353,7 @@ func TestUniformSampleFloat64IncludesTail(t *testing.T) {
 }
 
 func TestUniformSampleFloat64Snapshot(t *testing.T) {
-	s := NewUniformSampleFloat64(100)
+	s := NewUniformSampleFloat64WithRand(100, rand.New(rand.NewSource(1)))
 	for i := 1; i <= 10000; i++ {
 		s.Update(float64(i))
 	}
@@ -206,8 +363,7 @@ func TestUniformSampleFloat64Snapshot(t *testing.T) {
 }
 
 func TestUniformSampleFloat64Statistics(t *testing.T) {
-	rand.Seed(1)
-	s := NewUniformSampleFloat64(100)
+	s := NewUniformSampleFloat64WithRand(100, rand.New(rand.NewSource(1)))
 	for i := 1; i <= 10000; i++ {
 		s.Update(float64(i))
 	}
@@ -314,3 +470,88 @@ func TestUniformSampleFloat64ConcurrentUpdateCount(t *testing.T) {
 	}
 	quit <- struct{}{}
 }
+
+func TestSampleFloat64PercentilesSelect(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, size := range []int{64, 100, 1028, 4096} {
+		for _, ps := range [][]float64{
+			{0.5},
+			{0.99, 0.5},
+			{0.0, 0.001, 0.999, 1.0},
+			{0.75, 0.75, 0.95},
+		} {
+			values := make([]float64, size)
+			for i := range values {
+				values[i] = float64(r.Intn(size/4)) - r.Float64()
+			}
+			sorted := make([]float64, size)
+			copy(sorted, values)
+			want := SampleFloat64Percentiles(sorted, append(ps, 0.1, 0.2, 0.3, 0.4))
+			got := SampleFloat64Percentiles(values, ps)
+			for i := range ps {
+				if want[i] != got[i] {
+					t.Errorf("size %d, percentile %v: %v != %v\n", size, ps[i], want[i], got[i])
+				}
+			}
+		}
+	}
+}
+
+func TestSampleFloat64PercentilesInterpolation(t *testing.T) {
+	old := PercentileInterpolation
+	defer func() { PercentileInterpolation = old }()
+	PercentileInterpolation = InterpolationLinear
+	if got := SampleFloat64Percentile([]float64{0.5, 1.5, 2.5, 3.5, 4.5}, 0.9); 4.1 != got {
+		t.Errorf("SampleFloat64Percentile(0.9): 4.1 != %v\n", got)
+	}
+	if got := SampleFloat64PercentilesWithInterpolation([]float64{0.5, 1.5, 2.5, 3.5, 4.5}, []float64{0.9}, InterpolationNearest)[0]; 4.5 != got {
+		t.Errorf("nearest: 4.5 != %v\n", got)
+	}
+}
+
+func TestSampleFloat64SumCompensated(t *testing.T) {
+	values := []float64{1e16}
+	for i := 0; i < 1000; i++ {
+		values = append(values, 1)
+	}
+	values = append(values, -1e16)
+	if sum := SampleFloat64Sum(values); 1000 != sum {
+		t.Errorf("SampleFloat64Sum(): 1000 != %v\n", sum)
+	}
+	values = []float64{1e9 + 4, 1e9 + 7, 1e9 + 13, 1e9 + 16}
+	if variance := SampleFloat64Variance(values); 22.5 != variance {
+		t.Errorf("SampleFloat64Variance(): 22.5 != %v\n", variance)
+	}
+}
+
+func TestSampleFloat64SnapshotCopy(t *testing.T) {
+	values := []float64{3, 1, 2}
+	s := NewSampleFloat64SnapshotCopy(3, values)
+	values[0] = 100
+	if max := s.Max(); 3 != max {
+		t.Errorf("s.Max(): 3 != %v\n", max)
+	}
+}
+
+func TestSampleFloat64SnapshotConcurrentPercentiles(t *testing.T) {
+	values := make([]float64, 1000)
+	for i := range values {
+		values[i] = float64(len(values) - i)
+	}
+	s := NewSampleFloat64Snapshot(int64(len(values)), values)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if p := s.Percentile(0.5); 500.5 != p {
+				t.Errorf("s.Percentile(0.5): 500.5 != %v\n", p)
+			}
+			s.Values()
+		}()
+	}
+	wg.Wait()
+	if v := s.Values(); 1000 != v[0] || 1 != v[999] {
+		t.Errorf("s.Values(): [1000 ... 1] != [%v ... %v]\n", v[0], v[999])
+	}
+}
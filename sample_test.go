@@ -1,8 +1,11 @@
 package metrics
 
 import (
+	"math"
 	"math/rand"
 	"runtime"
+	"sort"
+	"sync"
 	"testing"
 	"time"
 )
@@ -54,6 +57,35 @@ func BenchmarkCopy1000000(b *testing.B) {
 	}
 }
 
+// BenchmarkSamplePercentile{,s}4096 compare computing a single percentile,
+// which selects, with computing the usual set of five, which sorts.  Both
+// include the cost of copying the values, as a snapshot would.
+func BenchmarkSamplePercentile4096(b *testing.B) {
+	benchmarkSamplePercentiles(b, 4096, []float64{0.99})
+}
+func BenchmarkSamplePercentiles4096(b *testing.B) {
+	benchmarkSamplePercentiles(b, 4096, []float64{0.5, 0.75, 0.95, 0.99, 0.999})
+}
+
+// BenchmarkSampleSnapshotStatistics4096 asks a snapshot for the statistics a
+// reporter does, which it computes only the first time.
+func BenchmarkSampleSnapshotStatistics4096(b *testing.B) {
+	values := make([]int64, 4096)
+	for i := range values {
+		values[i] = rand.Int63()
+	}
+	s := NewSampleSnapshot(int64(len(values)), values)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Max()
+		s.Mean()
+		s.Min()
+		s.StdDev()
+		s.Sum()
+		s.Variance()
+	}
+}
+
 func BenchmarkExpDecaySample257(b *testing.B) {
 	benchmarkSample(b, NewExpDecaySample(257, 0.015))
 }
@@ -79,8 +111,7 @@ func BenchmarkUniformSample1028(b *testing.B) {
 }
 
 func TestExpDecaySample10(t *testing.T) {
-	rand.Seed(1)
-	s := NewExpDecaySample(100, 0.99)
+	s := NewExpDecaySampleWithRand(100, 0.99, rand.New(rand.NewSource(1)))
 	for i := 0; i < 10; i++ {
 		s.Update(int64(i))
 	}
@@ -101,8 +132,7 @@ func TestExpDecaySample10(t *testing.T) {
 }
 
 func TestExpDecaySample100(t *testing.T) {
-	rand.Seed(1)
-	s := NewExpDecaySample(1000, 0.01)
+	s := NewExpDecaySampleWithRand(1000, 0.01, rand.New(rand.NewSource(1)))
 	for i := 0; i < 100; i++ {
 		s.Update(int64(i))
 	}
@@ -123,8 +153,7 @@ func TestExpDecaySample100(t *testing.T) {
 }
 
 func TestExpDecaySample1000(t *testing.T) {
-	rand.Seed(1)
-	s := NewExpDecaySample(100, 0.99)
+	s := NewExpDecaySampleWithRand(100, 0.99, rand.New(rand.NewSource(1)))
 	for i := 0; i < 1000; i++ {
 		s.Update(int64(i))
 	}
@@ -149,8 +178,7 @@ func TestExpDecaySample1000(t *testing.T) {
 // The priority becomes +Inf quickly after starting if this is done,
 // effectively freezing the set of samples until a rescale step happens.
 func TestExpDecaySampleNanosecondRegression(t *testing.T) {
-	rand.Seed(1)
-	s := NewExpDecaySample(100, 0.99)
+	s := NewExpDecaySampleWithRand(100, 0.99, rand.New(rand.NewSource(1)))
 	for i := 0; i < 100; i++ {
 		s.Update(10)
 	}
@@ -180,10 +208,128 @@ func TestExpDecaySampleRescale(t *testing.T) {
 	}
 }
 
+func TestExpDecaySampleRescaleInterval(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := ClockFunc(func() time.Time { return now })
+	s := NewExpDecaySampleWithOptions(100, 0.99, ExpDecaySampleOptions{RescaleInterval: time.Minute}).(*ExpDecaySample)
+	s.clock, s.t0, s.t1 = c, now, now.Add(time.Minute)
+	for i := 1; i <= 10; i++ {
+		s.Update(int64(i))
+	}
+	now = now.Add(2 * time.Minute)
+	snapshot := s.Snapshot()
+	if !s.t0.Equal(now) {
+		t.Errorf("s.t0: %v != %v\n", now, s.t0)
+	}
+	if count := snapshot.Count(); 10 != count {
+		t.Errorf("snapshot.Count(): 10 != %v\n", count)
+	}
+	for _, v := range s.values.Values() {
+		if v.k == 0.0 || math.IsInf(v.k, 0) {
+			t.Fatalf("v.k: %v\n", v.k)
+		}
+	}
+}
+
+func TestExpDecaySampleMaxAge(t *testing.T) {
+	now := time.Unix(1000, 0)
+	s := NewExpDecaySampleWithOptions(100, 0.99, ExpDecaySampleOptions{MaxAge: time.Minute}).(*ExpDecaySample)
+	s.clock = ClockFunc(func() time.Time { return now })
+	for i := 1; i <= 10; i++ {
+		now = now.Add(10 * time.Second)
+		s.Update(int64(i))
+	}
+	if size := s.Snapshot().Size(); 7 != size {
+		t.Errorf("s.Snapshot().Size(): 7 != %v\n", size)
+	}
+	now = now.Add(time.Hour)
+	snapshot := s.Snapshot()
+	if size := snapshot.Size(); 0 != size {
+		t.Errorf("snapshot.Size(): 0 != %v\n", size)
+	}
+	if count := snapshot.Count(); 10 != count {
+		t.Errorf("snapshot.Count(): 10 != %v\n", count)
+	}
+}
+
+// constSource is a rand.Source which always draws the same number.
+type constSource int64
+
+func (s constSource) Int63() int64 { return int64(s) }
+func (constSource) Seed(int64)     {}
+
+func TestExpDecaySampleStablePriorities(t *testing.T) {
+	now := time.Unix(1000, 0)
+	s := NewExpDecaySampleWithOptions(10, 0.015, ExpDecaySampleOptions{StablePriorities: true}).(*ExpDecaySample)
+	s.clock = ClockFunc(func() time.Time { return now })
+	s.t0, s.t1 = now, now.Add(time.Hour)
+	s.rng = rand.New(constSource(1 << 62)) // every priority ties
+	for i := 1; i <= 100; i++ {
+		s.Update(int64(i))
+	}
+	values := s.Snapshot().Values()
+	sort.Sort(int64Slice(values))
+	if 91 != values[0] || 100 != values[9] {
+		t.Errorf("values: [91 ... 100] != %v\n", values)
+	}
+
+	s.rng = rand.New(constSource(0)) // every priority infinite
+	for i := 1; i <= 100; i++ {
+		s.Update(int64(i))
+	}
+	for _, v := range s.values.Values() {
+		if math.IsInf(v.k, 0) || math.IsNaN(v.k) {
+			t.Fatalf("priority: want finite != %v\n", v.k)
+		}
+	}
+}
+
+func TestExpDecaySampleStablePrioritiesDistribution(t *testing.T) {
+	now := time.Unix(1000, 0)
+	s := NewExpDecaySampleWithOptions(1000, 0.015, ExpDecaySampleOptions{StablePriorities: true}).(*ExpDecaySample)
+	s.clock = ClockFunc(func() time.Time { return now })
+	s.t0, s.t1 = now, now.Add(time.Hour)
+	s.rng = rand.New(rand.NewSource(1))
+	for i := 0; i < 100000; i++ {
+		s.Update(int64(i)) // a burst at one instant
+	}
+	snapshot := s.Snapshot()
+	if mean := snapshot.Mean(); mean < 45000 || mean > 55000 {
+		t.Errorf("snapshot.Mean(): 50000 != %v\n", mean)
+	}
+	var quarters [4]int
+	for _, v := range snapshot.Values() {
+		quarters[v/25000]++
+	}
+	for i, n := range quarters {
+		if n < 200 || n > 300 {
+			t.Errorf("quarter %d: 250 != %v\n", i, n)
+		}
+	}
+}
+
+func TestExpDecaySampleValuesSince(t *testing.T) {
+	now := time.Unix(1000, 0)
+	s := NewExpDecaySampleWithClock(100, 0.015, ClockFunc(func() time.Time { return now })).(TimestampedSample)
+	for i := 1; i <= 5; i++ {
+		now = now.Add(time.Second)
+		s.Update(int64(i))
+	}
+	if values := s.ValuesSince(time.Unix(1003, 0)); 3 != len(values) || 3 != values[0] || 5 != values[2] {
+		t.Errorf("s.ValuesSince(1003): [3 4 5] != %v\n", values)
+	}
+	tvs := s.TimestampedValues()
+	if 5 != len(tvs) {
+		t.Fatalf("len(s.TimestampedValues()): 5 != %v\n", len(tvs))
+	}
+	if tv := tvs[0]; !time.Unix(1001, 0).Equal(tv.Time) || 1 != tv.Value {
+		t.Errorf("tvs[0]: {1001 1} != %v\n", tv)
+	}
+}
+
 func TestExpDecaySampleSnapshot(t *testing.T) {
 	now := time.Now()
-	rand.Seed(1)
-	s := NewExpDecaySample(100, 0.99)
+	s := NewExpDecaySampleWithRand(100, 0.99, rand.New(rand.NewSource(1)))
 	for i := 1; i <= 10000; i++ {
 		s.(*ExpDecaySample).update(now.Add(time.Duration(i)), int64(i))
 	}
@@ -194,17 +340,68 @@ func TestExpDecaySampleSnapshot(t *testing.T) {
 
 func TestExpDecaySampleStatistics(t *testing.T) {
 	now := time.Now()
-	rand.Seed(1)
-	s := NewExpDecaySample(100, 0.99)
+	s := NewExpDecaySampleWithRand(100, 0.99, rand.New(rand.NewSource(1)))
 	for i := 1; i <= 10000; i++ {
 		s.(*ExpDecaySample).update(now.Add(time.Duration(i)), int64(i))
 	}
 	testExpDecaySampleStatistics(t, s)
 }
 
+func TestSampleAppendValues(t *testing.T) {
+	for _, s := range []Sample{
+		NewExpDecaySampleWithRand(100, 0.99, rand.New(rand.NewSource(1))),
+		NewUniformSampleWithRand(100, rand.New(rand.NewSource(1))),
+	} {
+		for i := 1; i <= 10; i++ {
+			s.Update(int64(i))
+		}
+		dst := make([]int64, 1, 32)
+		got := AppendSampleValues(dst, s)
+		if 11 != len(got) || 0 != got[0] {
+			t.Fatalf("%T: 11 != len(%v)\n", s, got)
+		}
+		if &dst[0] != &got[0] {
+			t.Errorf("%T: AppendValues didn't reuse dst\n", s)
+		}
+		var sum int64
+		for _, v := range got[1:] {
+			sum += v
+		}
+		if 55 != sum {
+			t.Errorf("%T: 55 != %v\n", s, sum)
+		}
+		if snapshot := AppendSampleValues(nil, s.Snapshot()); 10 != len(snapshot) {
+			t.Errorf("%T: 10 != len(%v)\n", s, snapshot)
+		}
+		if values := AppendSampleValues(nil, struct{ Sample }{s}); 10 != len(values) {
+			t.Errorf("%T without AppendValues: 10 != len(%v)\n", s, values)
+		}
+	}
+	if got := (NilSample{}).AppendValues(nil); 0 != len(got) {
+		t.Errorf("NilSample: 0 != len(%v)\n", got)
+	}
+}
+
+func TestExpDecaySampleStatisticsAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool drops buffers at random under the race detector")
+	}
+	s := NewExpDecaySampleWithRand(100, 0.99, rand.New(rand.NewSource(1)))
+	for i := 1; i <= 1000; i++ {
+		s.Update(int64(i))
+	}
+	s.Max()
+	if n := testing.AllocsPerRun(100, func() {
+		s.Max()
+		s.Mean()
+		s.Variance()
+	}); 0 != n {
+		t.Errorf("%v allocations\n", n)
+	}
+}
+
 func TestUniformSample(t *testing.T) {
-	rand.Seed(1)
-	s := NewUniformSample(100)
+	s := NewUniformSampleWithRand(100, rand.New(rand.NewSource(1)))
 	for i := 0; i < 1000; i++ {
 		s.Update(int64(i))
 	}
@@ -224,9 +421,36 @@ func TestUniformSample(t *testing.T) {
 	}
 }
 
+func TestSampleWithRand(t *testing.T) {
+	now := time.Now()
+	for _, f := range []func() Sample{
+		func() Sample { return NewExpDecaySampleWithRand(100, 0.99, rand.New(rand.NewSource(2))) },
+		func() Sample { return NewUniformSampleWithRand(100, rand.New(rand.NewSource(2))) },
+	} {
+		s1, s2 := f(), f()
+		for i := 1; i <= 10000; i++ {
+			if es, ok := s1.(*ExpDecaySample); ok {
+				es.update(now.Add(time.Duration(i)), int64(i))
+				s2.(*ExpDecaySample).update(now.Add(time.Duration(i)), int64(i))
+			} else {
+				s1.Update(int64(i))
+				s2.Update(int64(i))
+			}
+		}
+		v1, v2 := s1.Values(), s2.Values()
+		if len(v1) != len(v2) {
+			t.Fatalf("len(s.Values()): %v != %v\n", len(v1), len(v2))
+		}
+		for i := range v1 {
+			if v1[i] != v2[i] {
+				t.Errorf("s.Values()[%d]: %v != %v\n", i, v1[i], v2[i])
+			}
+		}
+	}
+}
+
 func TestUniformSampleIncludesTail(t *testing.T) {
-	rand.Seed(1)
-	s := NewUniformSample(100)
+	s := NewUniformSampleWithRand(100, rand.New(rand.NewSource(1)))
 	max := 100
 	for i := 0; i < max; i++ {
 		s.Update(int64(i))
@@ -243,7 +467,7 @@ func TestUniformSampleIncludesTail(t *testing.T) {
 }
 
 func TestUniformSampleSnapshot(t *testing.T) {
-	s := NewUniformSample(100)
+	s := NewUniformSampleWithRand(100, rand.New(rand.NewSource(1)))
 	for i := 1; i <= 10000; i++ {
 		s.Update(int64(i))
 	}
@@ -253,14 +477,26 @@ func TestUniformSampleSnapshot(t *testing.T) {
 }
 
 func TestUniformSampleStatistics(t *testing.T) {
-	rand.Seed(1)
-	s := NewUniformSample(100)
+	s := NewUniformSampleWithRand(100, rand.New(rand.NewSource(1)))
 	for i := 1; i <= 10000; i++ {
 		s.Update(int64(i))
 	}
 	testUniformSampleStatistics(t, s)
 }
 
+func benchmarkSamplePercentiles(b *testing.B, size int, ps []float64) {
+	s := make([]int64, size)
+	for i := 0; i < len(s); i++ {
+		s[i] = rand.Int63()
+	}
+	sCopy := make([]int64, len(s))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		copy(sCopy, s)
+		SamplePercentiles(sCopy, ps)
+	}
+}
+
 func benchmarkSample(b *testing.B, s Sample) {
 	var memStats runtime.MemStats
 	runtime.ReadMemStats(&memStats)
@@ -331,6 +567,82 @@ func testUniformSampleStatistics(t *testing.T, s Sample) {
 	}
 }
 
+func TestSamplePercentilesSelect(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, size := range []int{64, 100, 1028, 4096} {
+		for _, ps := range [][]float64{
+			{0.5},
+			{0.99, 0.5},
+			{0.0, 0.001, 0.999, 1.0},
+			{0.75, 0.75, 0.95},
+		} {
+			values := make([]int64, size)
+			for i := range values {
+				values[i] = r.Int63n(int64(size / 4))
+			}
+			sorted := make([]int64, size)
+			copy(sorted, values)
+			want := SamplePercentiles(sorted, append(ps, 0.1, 0.2, 0.3, 0.4))
+			got := SamplePercentiles(values, ps)
+			for i := range ps {
+				if want[i] != got[i] {
+					t.Errorf("size %d, percentile %v: %v != %v\n", size, ps[i], want[i], got[i])
+				}
+			}
+		}
+	}
+}
+
+func TestSampleSumOverflow(t *testing.T) {
+	values := []int64{math.MaxInt64, 1}
+	if sum := SampleSum(values); math.MaxInt64 != sum {
+		t.Errorf("SampleSum(): %v != %v\n", int64(math.MaxInt64), sum)
+	}
+	if _, ok := SampleSumChecked(values); ok {
+		t.Error("SampleSumChecked(): ok")
+	}
+	if sum := SampleSum([]int64{math.MinInt64, -1}); math.MinInt64 != sum {
+		t.Errorf("SampleSum(): %v != %v\n", int64(math.MinInt64), sum)
+	}
+	if sum, ok := SampleSumChecked([]int64{math.MaxInt64, 1, -2}); !ok || math.MaxInt64-1 != sum {
+		t.Errorf("SampleSumChecked(): %v, %v\n", sum, ok)
+	}
+	if mean := SampleMean(values); float64(math.MaxInt64)/2 != mean {
+		t.Errorf("SampleMean(): %v != %v\n", float64(math.MaxInt64)/2, mean)
+	}
+}
+
+func TestSamplePercentilesInterpolation(t *testing.T) {
+	for m, want := range map[Interpolation]float64{
+		InterpolationWeibull:  2,
+		InterpolationLinear:   2.2,
+		InterpolationLower:    2,
+		InterpolationHigher:   3,
+		InterpolationNearest:  2,
+		InterpolationMidpoint: 2.5,
+	} {
+		if got := SamplePercentilesWithInterpolation([]int64{4, 1, 3, 2}, []float64{0.4}, m)[0]; math.Abs(want-got) > 1e-9 {
+			t.Errorf("interpolation %d: %v != %v\n", m, want, got)
+		}
+	}
+	r := rand.New(rand.NewSource(1))
+	for m := InterpolationWeibull; m <= InterpolationMidpoint; m++ {
+		values := make([]int64, 100)
+		for i := range values {
+			values[i] = r.Int63n(1000)
+		}
+		sorted := append([]int64(nil), values...)
+		ps := []float64{0, 0.5, 0.99, 1}
+		want := SamplePercentilesWithInterpolation(sorted, append(ps, 0.1, 0.2, 0.3, 0.4), m)
+		got := SamplePercentilesWithInterpolation(values, ps, m)
+		for i := range ps {
+			if want[i] != got[i] {
+				t.Errorf("interpolation %d, percentile %v: %v != %v\n", m, ps[i], want[i], got[i])
+			}
+		}
+	}
+}
+
 // TestUniformSampleConcurrentUpdateCount would expose data race problems with
 // concurrent Update and Count calls on Sample when test is called with -race
 // argument
@@ -361,3 +673,57 @@ func TestUniformSampleConcurrentUpdateCount(t *testing.T) {
 	}
 	quit <- struct{}{}
 }
+
+func TestSampleSnapshotCopy(t *testing.T) {
+	values := []int64{3, 1, 2}
+	s := NewSampleSnapshotCopy(3, values)
+	values[0] = 100
+	if max := s.Max(); 3 != max {
+		t.Errorf("s.Max(): 3 != %v\n", max)
+	}
+}
+
+func TestSampleSnapshotConcurrentPercentiles(t *testing.T) {
+	values := make([]int64, 1000)
+	for i := range values {
+		values[i] = int64(len(values) - i)
+	}
+	s := NewSampleSnapshot(int64(len(values)), values)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if p := s.Percentile(0.5); 500.5 != p {
+				t.Errorf("s.Percentile(0.5): 500.5 != %v\n", p)
+			}
+			s.Values()
+		}()
+	}
+	wg.Wait()
+	if v := s.Values(); 1000 != v[0] || 1 != v[999] {
+		t.Errorf("s.Values(): [1000 ... 1] != [%v ... %v]\n", v[0], v[999])
+	}
+}
+
+func TestSampleSnapshotConcurrentStatistics(t *testing.T) {
+	values := []int64{4, 1, 3, 2}
+	s := NewSampleSnapshot(int64(len(values)), values)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if m := s.Mean(); 2.5 != m {
+				t.Errorf("s.Mean(): 2.5 != %v\n", m)
+			}
+			if v := s.Variance(); 1.25 != v {
+				t.Errorf("s.Variance(): 1.25 != %v\n", v)
+			}
+		}()
+	}
+	wg.Wait()
+	if 4 != s.Max() || 1 != s.Min() || 10 != s.Sum() || math.Sqrt(1.25) != s.StdDev() {
+		t.Errorf("s: max %v, min %v, sum %v, stddev %v\n", s.Max(), s.Min(), s.Sum(), s.StdDev())
+	}
+}
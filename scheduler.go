@@ -0,0 +1,277 @@
+package metrics
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Exporter exports the metrics in a registry to some backend, eg a log or a
+// time-series database.  A Scheduler calls it periodically.
+//
+// Exporters are given the registry itself and read it through snapshots,
+// so that several can share it.  Only an Exporter reading it through a
+// NewDeltaRegistry view clears the metrics it reads.
+type Exporter interface {
+	Export(Registry) error
+}
+
+// ExporterFunc is an Exporter which calls a function.
+type ExporterFunc func(Registry) error
+
+// Export calls f(r).
+func (f ExporterFunc) Export(r Registry) error { return f(r) }
+
+// SchedulerConfig provides a container with configuration parameters for a
+// Scheduler.
+type SchedulerConfig struct {
+	Interval   time.Duration // Flush interval
+	Jitter     time.Duration // If set, each periodic flush is delayed by a random duration up to this, so that many processes don't flush at once
+	Align      bool          // Flush at multiples of Interval on the wall clock, eg at :00 and :30 every thirty seconds, before any jitter
+	MaxBackoff time.Duration // If set, periodic flushes are skipped after a failure, for twice as long after each consecutive one up to this
+	Clock      Clock         // Clock to tick with, defaulting to DefaultClock
+}
+
+// Scheduler exports a registry periodically, once more when it's closed so
+// that nothing recorded since the last flush is lost, and on demand via
+// FlushNow.  Errors from periodic flushes are logged and, once
+// RegisterSelfStats has been called, counted in metrics.ReporterErrors.
+type Scheduler struct {
+	registry Registry
+	exporter Exporter
+	config   SchedulerConfig
+	mutex    sync.Mutex // serialises flushes and guards failures and retryAt
+	failures uint
+	retryAt  time.Time
+	state    sync.Mutex // guards cancel, done, err and stopper
+	cancel   context.CancelFunc
+	done     chan struct{}
+	err      error // from the final flush
+	stopper  *stopper
+}
+
+// running holds the stoppers of the schedulers running, for Shutdown.
+var running struct {
+	sync.Mutex
+	stoppers map[*stopper]struct{}
+}
+
+// stopper stops a running scheduler, returning the error from its final
+// flush once that's done.
+type stopper struct {
+	stop func() error
+}
+
+// track adds a stopper to those Shutdown calls.
+func track(stop func() error) *stopper {
+	st := &stopper{stop}
+	running.Lock()
+	defer running.Unlock()
+	if nil == running.stoppers {
+		running.stoppers = make(map[*stopper]struct{})
+	}
+	running.stoppers[st] = struct{}{}
+	return st
+}
+
+// untrack removes a stopper added by track.
+func untrack(st *stopper) {
+	running.Lock()
+	defer running.Unlock()
+	delete(running.stoppers, st)
+}
+
+// Shutdown stops every Scheduler, and so every Reporter, which is running,
+// whether started with Start or run with Run, and waits for their final
+// flushes, so that a short-lived program doesn't lose what it recorded since
+// their last periodic flushes.  It returns the first error from those
+// flushes or, if ctx is done before they've all finished, ctx.Err(), leaving
+// the rest to finish in the background.  Call it last thing before exiting,
+// eg with a context.WithTimeout of a few seconds.
+func Shutdown(ctx context.Context) error {
+	running.Lock()
+	stops := make([]func() error, 0, len(running.stoppers))
+	for st := range running.stoppers {
+		stops = append(stops, st.stop)
+	}
+	running.Unlock()
+	errs := make(chan error, len(stops))
+	for _, stop := range stops {
+		go func(stop func() error) { errs <- stop() }(stop)
+	}
+	var err error
+	for range stops {
+		select {
+		case e := <-errs:
+			if nil == err {
+				err = e
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return err
+}
+
+// NewScheduler constructs a new Scheduler which exports r with e.
+func NewScheduler(r Registry, e Exporter, c SchedulerConfig) *Scheduler {
+	if nil == c.Clock {
+		c.Clock = DefaultClock
+	}
+	return &Scheduler{registry: r, exporter: e, config: c}
+}
+
+// FlushNow exports immediately, waiting for any flush already under way to
+// finish first, and returns the Exporter's error.
+func (s *Scheduler) FlushNow() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.flush()
+}
+
+// flush exports and, if that fails, backs off.  The caller must hold mutex.
+func (s *Scheduler) flush() error {
+	defer observeReporterFlush(selfStart())
+	err := s.exporter.Export(s.registry)
+	if nil == err {
+		s.failures, s.retryAt = 0, time.Time{}
+		return nil
+	}
+	countReporterError()
+	if 0 < s.config.MaxBackoff {
+		s.failures++
+		backoff := s.config.MaxBackoff
+		if s.failures < 32 {
+			if b := s.config.Interval << s.failures; 0 < b && b < backoff {
+				backoff = b
+			}
+		}
+		s.retryAt = s.config.Clock.Now().Add(backoff)
+	}
+	return err
+}
+
+// tick flushes unless backing off.
+func (s *Scheduler) tick() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.config.Clock.Now().Before(s.retryAt) {
+		return
+	}
+	if err := s.flush(); nil != err {
+		log.Println(err)
+	}
+}
+
+// Run flushes periodically until the given context is done or Shutdown is
+// called, then flushes one final time and returns, logging any error from
+// that unless Shutdown returns it.
+func (s *Scheduler) Run(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		shutdown int32
+		done     = make(chan struct{})
+		err      error
+	)
+	defer untrack(track(func() error {
+		atomic.StoreInt32(&shutdown, 1)
+		cancel()
+		<-done
+		return err
+	}))
+	err = s.run(ctx, s.config.Clock.NewTicker(s.config.Interval))
+	close(done)
+	if nil != err && 0 == atomic.LoadInt32(&shutdown) {
+		log.Println(err)
+	}
+}
+
+// run flushes periodically until the given context is done, then flushes
+// one final time and returns the error from that.
+func (s *Scheduler) run(ctx context.Context, t Ticker) error {
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C():
+			if s.wait(ctx) {
+				s.tick()
+				continue
+			}
+		case <-ctx.Done():
+		}
+		return s.FlushNow()
+	}
+}
+
+// wait waits until the next periodic flush is due, as delayed by the
+// configured alignment and jitter, returning false if the context is done
+// first.  It waits for the first tick of a Ticker from the configured Clock
+// so that the delay runs on the same clock as the flushes.
+func (s *Scheduler) wait(ctx context.Context) bool {
+	d := s.delay()
+	if d <= 0 {
+		return true
+	}
+	t := s.config.Clock.NewTicker(d)
+	defer t.Stop()
+	select {
+	case <-t.C():
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// delay returns how long after a tick to flush: until the next multiple of
+// the interval if aligning and then a random duration up to the jitter.
+func (s *Scheduler) delay() time.Duration {
+	var d time.Duration
+	if s.config.Align && 0 < s.config.Interval {
+		d = (s.config.Interval - time.Duration(s.config.Clock.Now().UnixNano()%int64(s.config.Interval))) % s.config.Interval
+	}
+	if 0 < s.config.Jitter {
+		d += time.Duration(rand.Int63n(int64(s.config.Jitter)))
+	}
+	return d
+}
+
+// Start runs the scheduler in a new goroutine until Close or Shutdown is
+// called.  Calling Start on a scheduler which is already running does
+// nothing.
+func (s *Scheduler) Start() {
+	s.state.Lock()
+	defer s.state.Unlock()
+	if nil != s.done {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	s.cancel, s.done = cancel, done
+	s.stopper = track(s.Close)
+	t := s.config.Clock.NewTicker(s.config.Interval)
+	go func() {
+		defer close(done)
+		s.err = s.run(ctx, t)
+	}()
+}
+
+// Close stops a scheduler started with Start, returning the error from its
+// final flush once that's done.  Calling Close on a scheduler which isn't
+// running does nothing.
+func (s *Scheduler) Close() error {
+	s.state.Lock()
+	defer s.state.Unlock()
+	if nil == s.done {
+		return nil
+	}
+	s.cancel()
+	<-s.done
+	untrack(s.stopper)
+	err := s.err
+	s.cancel, s.done, s.err, s.stopper = nil, nil, nil, nil
+	return err
+}
@@ -0,0 +1,165 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSchedulerBackoff(t *testing.T) {
+	now := time.Unix(0, 0)
+	n := 0
+	var err error
+	s := NewScheduler(NewRegistry(), ExporterFunc(func(Registry) error {
+		n++
+		return err
+	}), SchedulerConfig{
+		Interval:   time.Second,
+		MaxBackoff: 3 * time.Second,
+		Clock:      ClockFunc(func() time.Time { return now }),
+	})
+
+	err = errors.New("down")
+	s.tick()
+	for _, want := range []int{1, 2, 2, 2, 3, 3, 3, 4} {
+		now = now.Add(time.Second)
+		s.tick()
+		if want != n {
+			t.Fatalf("%v: n: %v != %v\n", now.Unix(), want, n)
+		}
+	}
+	if fn := s.FlushNow(); err != fn {
+		t.Errorf("FlushNow: %v != %v\n", err, fn)
+	}
+	if 5 != n {
+		t.Errorf("FlushNow while backing off: n: 5 != %v\n", n)
+	}
+
+	err = nil
+	if fn := s.FlushNow(); nil != fn {
+		t.Error(fn)
+	}
+	now = now.Add(time.Second)
+	s.tick()
+	if 7 != n {
+		t.Errorf("after recovering: n: 7 != %v\n", n)
+	}
+}
+
+func TestSchedulerCloseFlushes(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("foo", r).Inc(47)
+	var exported int64
+	s := NewScheduler(r, ExporterFunc(func(r Registry) error {
+		exported = r.Get("foo").(Counter).Count()
+		return errors.New("final")
+	}), SchedulerConfig{Interval: time.Hour})
+	s.Start()
+	s.Start()
+	if err := s.Close(); nil == err || "final" != err.Error() {
+		t.Errorf("Close: final != %v\n", err)
+	}
+	if 47 != exported {
+		t.Errorf("exported: 47 != %v\n", exported)
+	}
+	if err := s.Close(); nil != err {
+		t.Error(err)
+	}
+}
+
+func TestSchedulerJitter(t *testing.T) {
+	s := NewScheduler(NewRegistry(), ExporterFunc(func(Registry) error { return nil }), SchedulerConfig{Jitter: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if s.wait(ctx) {
+		t.Error("wait: true after the context was done\n")
+	}
+	if d := s.delay(); d < 0 || d >= time.Hour {
+		t.Errorf("delay: %v\n", d)
+	}
+}
+
+func TestSchedulerAlign(t *testing.T) {
+	now := time.Unix(100, int64(250*time.Millisecond))
+	s := NewScheduler(NewRegistry(), ExporterFunc(func(Registry) error { return nil }), SchedulerConfig{
+		Interval: 30 * time.Second,
+		Align:    true,
+		Clock:    ClockFunc(func() time.Time { return now }),
+	})
+	if d := s.delay(); 19750*time.Millisecond != d {
+		t.Errorf("delay: 19.75s != %v\n", d)
+	}
+	now = time.Unix(120, 0)
+	if d := s.delay(); 0 != d {
+		t.Errorf("delay on the boundary: 0 != %v\n", d)
+	}
+}
+
+func TestSchedulerCountsErrors(t *testing.T) {
+	r := NewRegistry()
+	RegisterSelfStats(r)
+	defer selfMetrics.Store((*selfStats)(nil))
+	s := NewScheduler(r, ExporterFunc(func(Registry) error { return errors.New("down") }), SchedulerConfig{Interval: time.Hour})
+	s.FlushNow()
+	if c := r.Get("metrics.ReporterErrors").(Counter).Count(); 1 != c {
+		t.Errorf("metrics.ReporterErrors: 1 != %v\n", c)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	running.Lock()
+	before := len(running.stoppers)
+	running.Unlock()
+	r := NewRegistry()
+	c := NewRegisteredCounter("foo", r)
+	exported := make(chan int64, 2)
+	export := ExporterFunc(func(r Registry) error {
+		exported <- r.Get("foo").(Counter).Count()
+		return nil
+	})
+	NewScheduler(r, export, SchedulerConfig{Interval: time.Hour}).Start()
+	ran := make(chan struct{})
+	go func() {
+		defer close(ran)
+		NewScheduler(r, export, SchedulerConfig{Interval: time.Hour}).Run(context.Background())
+	}()
+	for {
+		running.Lock()
+		n := len(running.stoppers)
+		running.Unlock()
+		if before+2 == n {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	c.Inc(47)
+
+	if err := Shutdown(context.Background()); nil != err {
+		t.Fatal(err)
+	}
+	<-ran
+	for i := 0; i < 2; i++ {
+		if n := <-exported; 47 != n {
+			t.Errorf("exported: 47 != %v\n", n)
+		}
+	}
+	if err := Shutdown(context.Background()); nil != err {
+		t.Error(err)
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	unblock := make(chan struct{})
+	s := NewScheduler(NewRegistry(), ExporterFunc(func(Registry) error {
+		<-unblock
+		return errors.New("final")
+	}), SchedulerConfig{Interval: time.Hour})
+	s.Start()
+	defer close(unblock)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := Shutdown(ctx); context.DeadlineExceeded != err {
+		t.Errorf("Shutdown: %v != %v\n", context.DeadlineExceeded, err)
+	}
+}
@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// selfMetrics holds the metrics of the library's own overhead once
+// RegisterSelfStats has been called, and nil until then.
+var selfMetrics atomic.Value // *selfStats
+
+type selfStats struct {
+	DroppedUpdates  Counter
+	ReporterErrors  Counter
+	ReporterFlush   Timer
+	Rescales        Counter
+	SampleSnapshot  Histogram
+	SnapshotMisuses Counter
+}
+
+// RegisterSelfStats registers metrics of the library's own overhead in r, so
+// that it can be checked that the metrics pipeline itself isn't a
+// bottleneck: the number of times exponentially-decaying samples have
+// rescaled their priorities, the nanoseconds taken to snapshot reservoir
+// samples, the number of updates Timers have dropped, the time Reporters
+// and Schedulers take to flush, the number of their flushes which failed and
+// the number of writes to snapshots ignored since PanicOnSnapshotMutation
+// is false.  The metrics are named metrics.Rescales, metrics.SampleSnapshot,
+// metrics.DroppedUpdates, metrics.ReporterFlush, metrics.ReporterErrors and
+// metrics.SnapshotMisuses.
+//
+// Until this is called nothing is measured.  Calling it again starts a fresh
+// set of metrics, replacing any registered in r under those names.
+func RegisterSelfStats(r Registry) {
+	s := &selfStats{
+		DroppedUpdates:  NewCounter(),
+		ReporterErrors:  NewCounter(),
+		ReporterFlush:   NewTimer(),
+		Rescales:        NewCounter(),
+		SampleSnapshot:  NewHistogram(NewExpDecaySample(1028, 0.015)),
+		SnapshotMisuses: NewCounter(),
+	}
+	for name, i := range map[string]interface{}{
+		"metrics.DroppedUpdates":  s.DroppedUpdates,
+		"metrics.ReporterErrors":  s.ReporterErrors,
+		"metrics.ReporterFlush":   s.ReporterFlush,
+		"metrics.Rescales":        s.Rescales,
+		"metrics.SampleSnapshot":  s.SampleSnapshot,
+		"metrics.SnapshotMisuses": s.SnapshotMisuses,
+	} {
+		r.Unregister(name)
+		r.Register(name, i)
+	}
+	selfMetrics.Store(s)
+}
+
+func loadSelfStats() *selfStats {
+	s, _ := selfMetrics.Load().(*selfStats)
+	return s
+}
+
+// selfStart returns the time now if self stats are registered, to be passed
+// to observeSampleSnapshot or observeReporterFlush, and the zero time
+// otherwise so as to cost nothing.
+func selfStart() time.Time {
+	if nil == loadSelfStats() {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+func countDroppedUpdate() {
+	if s := loadSelfStats(); nil != s {
+		s.DroppedUpdates.Inc(1)
+	}
+}
+
+func countReporterError() {
+	if s := loadSelfStats(); nil != s {
+		s.ReporterErrors.Inc(1)
+	}
+}
+
+func countSnapshotMisuse() {
+	if s := loadSelfStats(); nil != s {
+		s.SnapshotMisuses.Inc(1)
+	}
+}
+
+func countRescale() {
+	if s := loadSelfStats(); nil != s {
+		s.Rescales.Inc(1)
+	}
+}
+
+func observeReporterFlush(ts time.Time) {
+	if s := loadSelfStats(); nil != s && !ts.IsZero() {
+		s.ReporterFlush.UpdateSince(ts)
+	}
+}
+
+// observeSampleSnapshot records the time since ts into the SampleSnapshot
+// histogram.  Samples must call it after releasing their locks since
+// snapshotting that histogram records into it in turn.
+func observeSampleSnapshot(ts time.Time) {
+	if s := loadSelfStats(); nil != s && !ts.IsZero() {
+		s.SampleSnapshot.Update(int64(time.Since(ts)))
+	}
+}
@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRegisterSelfStats(t *testing.T) {
+	r := NewRegistry()
+	RegisterSelfStats(r)
+	defer selfMetrics.Store((*selfStats)(nil))
+
+	tm := NewTimer()
+	tm.Update(-time.Second)
+	if dropped := r.Get("metrics.DroppedUpdates").(Counter).Count(); 1 != dropped {
+		t.Errorf("metrics.DroppedUpdates: 1 != %v\n", dropped)
+	}
+
+	s := NewExpDecaySample(100, 0.99).(*ExpDecaySample)
+	s.update(s.t0.Add(2*time.Hour), 1)
+	if rescales := r.Get("metrics.Rescales").(Counter).Count(); 1 != rescales {
+		t.Errorf("metrics.Rescales: 1 != %v\n", rescales)
+	}
+
+	s.Snapshot()
+	NewUniformSampleFloat64(100).Snapshot()
+	if count := r.Get("metrics.SampleSnapshot").(Histogram).Count(); 2 != count {
+		t.Errorf("metrics.SampleSnapshot: 2 != %v\n", count)
+	}
+
+	rep := NewReporter(time.Minute, func() {
+		r.Each(func(name string, i interface{}) {
+			if h, ok := i.(Histogram); ok {
+				h.Snapshot()
+			}
+		})
+	})
+	rep.FlushNow()
+	if count := r.Get("metrics.ReporterFlush").(Timer).Count(); 1 != count {
+		t.Errorf("metrics.ReporterFlush: 1 != %v\n", count)
+	}
+}
+
+func TestRegisterSelfStatsTwice(t *testing.T) {
+	r := NewRegistry()
+	RegisterSelfStats(r)
+	defer selfMetrics.Store((*selfStats)(nil))
+	NewTimer().Update(-time.Second)
+
+	RegisterSelfStats(r)
+	if dropped := r.Get("metrics.DroppedUpdates").(Counter).Count(); 0 != dropped {
+		t.Errorf("metrics.DroppedUpdates: 0 != %v\n", dropped)
+	}
+	NewTimer().Update(-time.Second)
+	if dropped := r.Get("metrics.DroppedUpdates").(Counter).Count(); 1 != dropped {
+		t.Errorf("metrics.DroppedUpdates: 1 != %v\n", dropped)
+	}
+}
+
+func TestSelfStatsUnregistered(t *testing.T) {
+	if s := loadSelfStats(); nil != s {
+		t.Fatal(s)
+	}
+	if ts := selfStart(); !ts.IsZero() {
+		t.Errorf("selfStart(): zero != %v\n", ts)
+	}
+	countRescale()
+	observeSampleSnapshot(time.Now())
+}
+
+func TestPanicOnSnapshotMutation(t *testing.T) {
+	r := NewRegistry()
+	RegisterSelfStats(r)
+	defer selfMetrics.Store((*selfStats)(nil))
+
+	func() {
+		defer func() {
+			if nil == recover() {
+				t.Error("Update on a snapshot: want a panic")
+			}
+		}()
+		NewGauge().Snapshot().Update(1)
+	}()
+
+	PanicOnSnapshotMutation = false
+	defer func() { PanicOnSnapshotMutation = true }()
+	NewGauge().Snapshot().Update(1)
+	NewCounter().Snapshot().Inc(1)
+	snapshot := NewTimer().Snapshot()
+	if cleared := snapshot.Clear(); snapshot != cleared {
+		t.Errorf("snapshot.Clear(): %v != %v\n", snapshot, cleared)
+	}
+	snapshot.Stopwatch().Stop()
+	if misuses := r.Get("metrics.SnapshotMisuses").(Counter).Count(); 4 != misuses {
+		t.Errorf("metrics.SnapshotMisuses: 4 != %v\n", misuses)
+	}
+}
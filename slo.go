@@ -0,0 +1,240 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// SLOs track a latency objective, eg 99% of requests served within 250ms,
+// over several trailing windows at once, for multiwindow burn-rate
+// alerting.  An SLO is the Timer of the latencies recorded, so reporters
+// export it as one, and counts the events within and over its threshold by
+// time so that it can report, for each window, the fraction within it and
+// the rate at which the error budget is being spent: 1 when it's spent as
+// fast as the objective allows, 30 when a 30-day budget would be gone in a
+// day.
+type SLO interface {
+	Timer
+	BurnRate(window time.Duration) float64
+	Compliance(window time.Duration) float64
+}
+
+// SLOConfig provides a container with configuration parameters for an SLO.
+type SLOConfig struct {
+	Threshold time.Duration   // Latency within which an event is good, eg 250ms
+	Objective float64         // Fraction of events to be good, eg 0.99
+	Windows   []time.Duration // Trailing windows to report, defaulting to 5m, 30m, 1h and 6h
+}
+
+// GetOrRegisterSLO returns an existing SLO or constructs and registers a new
+// StandardSLO with the given configuration, along with, for each window, a
+// GaugeFloat64 of its compliance under the name followed by eg
+// .Compliance.5m and one of its burn rate under the name followed by eg
+// .BurnRate.5m.
+func GetOrRegisterSLO(name string, r Registry, c SLOConfig) SLO {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	s, ok := r.GetOrRegister(name, func() SLO { return NewSLO(c) }).(SLO)
+	if !ok {
+		return registrationConflict(DuplicateMetric(name), NewSLO(c)).(SLO)
+	}
+	for _, w := range sloWindows(c) {
+		w := w
+		r.GetOrRegister(name+".Compliance."+sloWindowName(w), func() GaugeFloat64 {
+			return NewFunctionalGaugeFloat64(func() float64 { return s.Compliance(w) })
+		})
+		r.GetOrRegister(name+".BurnRate."+sloWindowName(w), func() GaugeFloat64 {
+			return NewFunctionalGaugeFloat64(func() float64 { return s.BurnRate(w) })
+		})
+	}
+	return s
+}
+
+// NewSLO constructs a new StandardSLO with the given configuration.
+func NewSLO(c SLOConfig) SLO {
+	return NewSLOWithClock(c, DefaultClock)
+}
+
+// NewSLOWithClock is like NewSLO but reads the time from the given Clock.
+func NewSLOWithClock(c SLOConfig, clock Clock) SLO {
+	if UseNilMetrics {
+		return NilSLO{}
+	}
+	windows := sloWindows(c)
+	shortest, longest := windows[0], windows[0]
+	for _, w := range windows {
+		if w < shortest {
+			shortest = w
+		}
+		if w > longest {
+			longest = w
+		}
+	}
+
+	// Buckets fine enough to resolve the shortest window to a sixtieth, but
+	// no more than 3600 of them.
+	width := shortest / 60
+	if w := longest / 3600; width < w {
+		width = w
+	}
+	if width < time.Second {
+		width = time.Second
+	}
+	return &StandardSLO{
+		Timer:     NewTimerWithClock(clock),
+		threshold: c.Threshold,
+		objective: c.Objective,
+		clock:     clock,
+		width:     width,
+		buckets:   make([]sloBucket, (longest+width-1)/width),
+		last:      clock.Now().UnixNano() / int64(width),
+	}
+}
+
+// NilSLO is a no-op SLO.
+type NilSLO struct {
+	NilTimer
+}
+
+// BurnRate is a no-op.
+func (NilSLO) BurnRate(time.Duration) float64 { return 0 }
+
+// Compliance is a no-op.
+func (NilSLO) Compliance(time.Duration) float64 { return 1 }
+
+// StandardSLO is the standard implementation of an SLO, timing events with a
+// StandardTimer and counting them in a ring of buckets of time covering its
+// longest window.  Windows are rounded up to whole buckets, of a sixtieth
+// of the shortest window or a 3600th of the longest, whichever is longer.
+type StandardSLO struct {
+	Timer
+	threshold time.Duration
+	objective float64
+	clock     Clock
+	width     time.Duration
+	mutex     sync.Mutex
+	buckets   []sloBucket
+	last      int64 // number of the newest bucket since the Unix epoch
+}
+
+type sloBucket struct {
+	good, total int64
+}
+
+// BurnRate returns the rate at which the error budget was spent over the
+// given trailing window: the fraction of events over the threshold divided
+// by the fraction the objective allows.  It's zero if there were none, and
+// +Inf if there were any and the objective allows none.
+func (s *StandardSLO) BurnRate(window time.Duration) float64 {
+	bad := 1 - s.Compliance(window)
+	if 0 == bad {
+		return 0
+	}
+	return bad / (1 - s.objective)
+}
+
+// Compliance returns the fraction of the events in the given trailing
+// window, up to the longest configured, which were within the threshold, or
+// 1 if there were none.
+func (s *StandardSLO) Compliance(window time.Duration) float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.advance(s.clock.Now())
+	n := int64((window + s.width - 1) / s.width)
+	if n > int64(len(s.buckets)) {
+		n = int64(len(s.buckets))
+	}
+	var good, total int64
+	for i := int64(0); i < n; i++ {
+		b := &s.buckets[s.bucket(s.last-i)]
+		good += b.good
+		total += b.total
+	}
+	if 0 == total {
+		return 1
+	}
+	return float64(good) / float64(total)
+}
+
+// Stopwatch returns a new Stopwatch which times from now and records its
+// laps in the SLO.
+func (s *StandardSLO) Stopwatch() *Stopwatch {
+	return NewStopwatch(s, s.clock)
+}
+
+// Time records the duration of the execution of the given function.
+func (s *StandardSLO) Time(f func()) {
+	ts := s.clock.Now()
+	f()
+	s.Update(s.clock.Now().Sub(ts))
+}
+
+// Update records the duration of an event, counting it as good if it's
+// within the threshold.
+func (s *StandardSLO) Update(d time.Duration) {
+	s.Timer.Update(d)
+	if d < 0 {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.advance(s.clock.Now())
+	b := &s.buckets[s.bucket(s.last)]
+	b.total++
+	if d <= s.threshold {
+		b.good++
+	}
+}
+
+// UpdateSince records the duration of an event that started at the given
+// time and ends now.
+func (s *StandardSLO) UpdateSince(ts time.Time) {
+	s.Update(s.clock.Now().Sub(ts))
+}
+
+// advance moves the newest bucket up to the given time, zeroing the buckets
+// passed over.  The caller must hold mutex.
+func (s *StandardSLO) advance(now time.Time) {
+	current := now.UnixNano() / int64(s.width)
+	n := current - s.last
+	if n <= 0 {
+		return
+	}
+	if n > int64(len(s.buckets)) {
+		n = int64(len(s.buckets))
+	}
+	for i := int64(1); i <= n; i++ {
+		s.buckets[s.bucket(s.last+i)] = sloBucket{}
+	}
+	s.last = current
+}
+
+// bucket returns the index in the ring of the given bucket number, which
+// may be negative.
+func (s *StandardSLO) bucket(i int64) int64 {
+	n := int64(len(s.buckets))
+	return (i%n + n) % n
+}
+
+// sloWindows returns the configured windows or the defaults.
+func sloWindows(c SLOConfig) []time.Duration {
+	if 0 == len(c.Windows) {
+		return []time.Duration{5 * time.Minute, 30 * time.Minute, time.Hour, 6 * time.Hour}
+	}
+	return c.Windows
+}
+
+// sloWindowName returns the given window as a suffix of the names of the
+// gauges of an SLO, eg "5m" or "6h".
+func sloWindowName(d time.Duration) string {
+	switch {
+	case 0 == d%time.Hour:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case 0 == d%time.Minute:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+	return fmt.Sprintf("%ds", int64(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSLO(t *testing.T) {
+	now := time.Unix(1000, 0)
+	s := NewSLOWithClock(SLOConfig{
+		Threshold: 250 * time.Millisecond,
+		Objective: 0.99,
+		Windows:   []time.Duration{time.Minute, time.Hour},
+	}, ClockFunc(func() time.Time { return now }))
+	if c := s.Compliance(time.Minute); 1 != c {
+		t.Errorf("s.Compliance(time.Minute) with no events: 1 != %v\n", c)
+	}
+	for i := 0; i < 96; i++ {
+		s.Update(100 * time.Millisecond)
+	}
+	for i := 0; i < 4; i++ {
+		s.Update(time.Second)
+	}
+	if count := s.Count(); 100 != count {
+		t.Errorf("s.Count(): 100 != %v\n", count)
+	}
+	if c := s.Compliance(time.Minute); 0.96 != c {
+		t.Errorf("s.Compliance(time.Minute): 0.96 != %v\n", c)
+	}
+	if b := s.BurnRate(time.Minute); 1e-9 < math.Abs(4-b) {
+		t.Errorf("s.BurnRate(time.Minute): 4 != %v\n", b)
+	}
+
+	now = now.Add(10 * time.Minute)
+	s.Update(time.Millisecond)
+	if c := s.Compliance(time.Minute); 1 != c {
+		t.Errorf("s.Compliance(time.Minute) after 10 minutes: 1 != %v\n", c)
+	}
+	if c := s.Compliance(time.Hour); 97.0/101 != c {
+		t.Errorf("s.Compliance(time.Hour) after 10 minutes: %v != %v\n", 97.0/101, c)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if b := s.BurnRate(time.Hour); 0 != b {
+		t.Errorf("s.BurnRate(time.Hour) after 2 hours: 0 != %v\n", b)
+	}
+}
+
+func TestSLOStopwatch(t *testing.T) {
+	now := time.Unix(1000, 0)
+	s := NewSLOWithClock(SLOConfig{Threshold: time.Second, Objective: 0.9}, ClockFunc(func() time.Time { return now }))
+	sw := s.Stopwatch()
+	now = now.Add(2 * time.Second)
+	sw.Stop()
+	if c := s.Compliance(5 * time.Minute); 0 != c {
+		t.Errorf("s.Compliance(5 * time.Minute): 0 != %v\n", c)
+	}
+}
+
+func TestGetOrRegisterSLO(t *testing.T) {
+	r := NewRegistry()
+	s := GetOrRegisterSLO("api", r, SLOConfig{Threshold: time.Second, Objective: 0.99})
+	s.Update(2 * time.Second)
+	if _, ok := r.Get("api").(Timer); !ok {
+		t.Error("api: not a Timer")
+	}
+	if g, ok := r.Get("api.Compliance.30m").(GaugeFloat64); !ok || 0 != g.Value() {
+		t.Errorf("api.Compliance.30m: %v\n", r.Get("api.Compliance.30m"))
+	}
+	if g, ok := r.Get("api.BurnRate.6h").(GaugeFloat64); !ok || 1e-9 < math.Abs(100-g.Value()) {
+		t.Errorf("api.BurnRate.6h: %v\n", r.Get("api.BurnRate.6h"))
+	}
+	if GetOrRegisterSLO("api", r, SLOConfig{}) != s {
+		t.Error("GetOrRegisterSLO: not the registered SLO")
+	}
+}
+
+func TestSLOWindowName(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		5 * time.Minute:         "5m",
+		6 * time.Hour:           "6h",
+		90 * time.Minute:        "90m",
+		1500 * time.Millisecond: "2s",
+	} {
+		if name := sloWindowName(d); want != name {
+			t.Errorf("sloWindowName(%v): %v != %v\n", d, want, name)
+		}
+	}
+}
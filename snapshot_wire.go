@@ -0,0 +1,302 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// EncodeSnapshot writes a compact binary snapshot of the counters, gauge
+// counters, gauges and reservoir-backed histograms in r to w, in gob, for
+// shipping to another process which merges it with MergeSnapshot.  This
+// lets worker processes forward their metrics to one process which exports
+// them all.
+//
+// Counters and histograms are cleared as they're encoded, so that each
+// snapshot carries what was recorded since the last and merging snapshots
+// from several processes, or from one process repeatedly, adds them up.
+// Gauges and gauge counters are encoded as they stand.
+func EncodeSnapshot(r Registry, w io.Writer) error {
+	return gob.NewEncoder(w).Encode(snapshotDump(r))
+}
+
+// snapshotDump returns a snapshot of r, clearing its counters and
+// histograms.
+func snapshotDump(r Registry) registryDump {
+	dump := registryDump{Version: 1, Metrics: make(map[string]dumpedMetric)}
+	r.Each(func(name string, i interface{}) {
+		if m, ok := encodeSnapshotMetric(i); ok {
+			dump.Metrics[name] = m
+		}
+	})
+	return dump
+}
+
+// encodeSnapshotDatagrams encodes a snapshot of r as EncodeSnapshot does but
+// in datagrams of at most mtu bytes, each a snapshot by itself, so that
+// none is dropped for being too large.  The values of a histogram too large
+// for one datagram are split across several, which MergeSnapshot adds up.
+// A metric which doesn't fit even so is left out and reported in the error.
+func encodeSnapshotDatagrams(r Registry, mtu int) ([][]byte, error) {
+	dump := snapshotDump(r)
+	names := make([]string, 0, len(dump.Metrics))
+	for name := range dump.Metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var (
+		datagrams [][]byte
+		tooLarge  []string
+		batch     = registryDump{Version: 1, Metrics: make(map[string]dumpedMetric)}
+		encoded   []byte
+	)
+	flush := func() {
+		if 0 < len(batch.Metrics) {
+			datagrams = append(datagrams, encoded)
+			batch.Metrics = make(map[string]dumpedMetric)
+		}
+	}
+	for _, name := range names {
+		for _, m := range splitSnapshotMetric(name, dump.Metrics[name], mtu) {
+			if _, ok := batch.Metrics[name]; ok {
+				flush()
+			}
+			batch.Metrics[name] = m
+			if b := encodeDump(batch); len(b) <= mtu {
+				encoded = b
+				continue
+			}
+			delete(batch.Metrics, name)
+			flush()
+			batch.Metrics[name] = m
+			if encoded = encodeDump(batch); len(encoded) > mtu {
+				delete(batch.Metrics, name)
+				tooLarge = append(tooLarge, name)
+			}
+		}
+	}
+	flush()
+	if 0 < len(tooLarge) {
+		return datagrams, fmt.Errorf("metrics: %v too large for datagrams of %d bytes", tooLarge, mtu)
+	}
+	return datagrams, nil
+}
+
+// splitSnapshotMetric splits the values of the given histogram in halves
+// until each part fits in a datagram of mtu bytes by itself, or can't be
+// split further.  The first part carries the count of values which aren't
+// in any part.
+func splitSnapshotMetric(name string, m dumpedMetric, mtu int) []dumpedMetric {
+	if len(encodeDump(registryDump{Version: 1, Metrics: map[string]dumpedMetric{name: m}})) <= mtu {
+		return []dumpedMetric{m}
+	}
+	a, b := m, m
+	switch {
+	case 1 < len(m.Values):
+		n := len(m.Values) / 2
+		a.Values, b.Values = m.Values[:n], m.Values[n:]
+		b.Count = int64(len(b.Values))
+	case 1 < len(m.FloatValues):
+		n := len(m.FloatValues) / 2
+		a.FloatValues, b.FloatValues = m.FloatValues[:n], m.FloatValues[n:]
+		b.Count = int64(len(b.FloatValues))
+	default:
+		return []dumpedMetric{m}
+	}
+	a.Count = m.Count - b.Count
+	return append(splitSnapshotMetric(name, a, mtu), splitSnapshotMetric(name, b, mtu)...)
+}
+
+func encodeDump(dump registryDump) []byte {
+	var buf bytes.Buffer
+	gob.NewEncoder(&buf).Encode(dump)
+	return buf.Bytes()
+}
+
+// MergeSnapshot reads a snapshot written by EncodeSnapshot from rd and merges
+// it into r.  Counts are added to those of existing counters, and values to
+// the samples of existing histograms.  Gauges and gauge counters take the
+// snapshot's values, the last snapshot merged winning.  Metrics which don't
+// exist in r yet are registered.  Counts of any kind of counter can be added
+// to a Uint64Counter or BigCounter registered in r beforehand, so that the
+// sum of many processes' counters doesn't overflow.
+func MergeSnapshot(r Registry, rd io.Reader) error {
+	var dump registryDump
+	if err := gob.NewDecoder(rd).Decode(&dump); nil != err {
+		return err
+	}
+	if 1 != dump.Version {
+		return fmt.Errorf("metrics: unsupported snapshot version %d", dump.Version)
+	}
+	for name, m := range dump.Metrics {
+		if err := mergeSnapshotMetric(r, name, m); nil != err {
+			return fmt.Errorf("metrics: merging %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// MergeSnapshot reads a snapshot written by EncodeSnapshot and merges it into
+// the registry as MergeSnapshot does.
+func (r *StandardRegistry) MergeSnapshot(rd io.Reader) error {
+	return MergeSnapshot(r, rd)
+}
+
+// MergeSnapshot reads a snapshot written by EncodeSnapshot and merges it into
+// the registry as MergeSnapshot does.
+func (r *ShardedRegistry) MergeSnapshot(rd io.Reader) error {
+	return MergeSnapshot(r, rd)
+}
+
+func encodeSnapshotMetric(i interface{}) (dumpedMetric, bool) {
+	switch metric := i.(type) {
+	case Counter:
+		c, ok := metric.(*StandardCounter)
+		if !ok {
+			return dumpedMetric{}, false
+		}
+		return dumpedMetric{Type: "counter", Value: c.Clear().Count()}, true
+	case Uint64Counter:
+		c, ok := metric.(*StandardUint64Counter)
+		if !ok {
+			return dumpedMetric{}, false
+		}
+		return dumpMetric(c.Clear())
+	case BigCounter:
+		c, ok := metric.(*StandardBigCounter)
+		if !ok {
+			return dumpedMetric{}, false
+		}
+		return dumpMetric(c.Clear())
+	case Histogram:
+		m := dumpedMetric{Type: "histogram"}
+		switch s := metric.Sample().(type) {
+		case *UniformSample:
+			m.Sample, m.ReservoirSize = "uniform", s.reservoirSize
+		case *ExpDecaySample:
+			m.Sample, m.ReservoirSize, m.Alpha = "expdecay", s.reservoirSize, s.alpha
+		default:
+			return dumpedMetric{}, false
+		}
+		h := metric.Clear()
+		m.Count, m.Values = h.Count(), h.Sample().Values()
+		return m, true
+	case HistogramFloat64:
+		m := dumpedMetric{Type: "histogramfloat64"}
+		switch s := metric.Sample().(type) {
+		case *UniformSampleFloat64:
+			m.Sample, m.ReservoirSize = "uniform", s.reservoirSize
+		case *ExpDecaySampleFloat64:
+			m.Sample, m.ReservoirSize, m.Alpha = "expdecay", s.reservoirSize, s.alpha
+		default:
+			return dumpedMetric{}, false
+		}
+		h := metric.Clear()
+		m.Count, m.FloatValues = h.Count(), h.Sample().Values()
+		return m, true
+	}
+	return dumpMetric(i)
+}
+
+func mergeSnapshotMetric(r Registry, name string, m dumpedMetric) error {
+	existing := r.Get(name)
+	if nil == existing {
+		i, err := restoreMetric(m)
+		if nil != err {
+			return err
+		}
+		r.Register(name, i)
+		return nil
+	}
+	switch metric := existing.(type) {
+	case Counter:
+		if "counter" == m.Type {
+			metric.Inc(m.Value)
+			return nil
+		}
+	case Uint64Counter:
+		switch m.Type {
+		case "counter", "uint64counter", "bigcounter":
+			v, err := m.bigValue()
+			if nil != err {
+				return err
+			}
+			if v.Sign() > 0 && v.IsUint64() {
+				metric.Inc(v.Uint64())
+			}
+			return nil
+		}
+	case BigCounter:
+		switch m.Type {
+		case "counter", "uint64counter", "bigcounter":
+			v, err := m.bigValue()
+			if nil != err {
+				return err
+			}
+			metric.Add(v)
+			return nil
+		}
+	case GaugeCounter:
+		if "gaugecounter" == m.Type {
+			metric.Inc(m.Value - metric.Count())
+			return nil
+		}
+	case Gauge:
+		if "gauge" == m.Type {
+			metric.Update(m.Value)
+			return nil
+		}
+	case GaugeFloat64:
+		if "gaugefloat64" == m.Type {
+			metric.Update(m.FloatValue)
+			return nil
+		}
+	case Histogram:
+		if "histogram" == m.Type {
+			for _, v := range m.Values {
+				metric.Update(v)
+			}
+			addSampleCount(metric.Sample(), m.Count-int64(len(m.Values)))
+			return nil
+		}
+	case HistogramFloat64:
+		if "histogramfloat64" == m.Type {
+			for _, v := range m.FloatValues {
+				metric.Update(v)
+			}
+			addSampleCount(metric.Sample(), m.Count-int64(len(m.FloatValues)))
+			return nil
+		}
+	}
+	return fmt.Errorf("%s in snapshot but %T registered", m.Type, existing)
+}
+
+// addSampleCount adds n to the count of values a reservoir sample has seen,
+// for values which were counted elsewhere but didn't make it into the
+// reservoir shipped from there.
+func addSampleCount(s interface{}, n int64) {
+	if n <= 0 {
+		return
+	}
+	switch s := s.(type) {
+	case *UniformSample:
+		s.mutex.Lock()
+		s.count += n
+		s.mutex.Unlock()
+	case *ExpDecaySample:
+		s.mutex.Lock()
+		s.count += n
+		s.mutex.Unlock()
+	case *UniformSampleFloat64:
+		s.mutex.Lock()
+		s.count += n
+		s.mutex.Unlock()
+	case *ExpDecaySampleFloat64:
+		s.mutex.Lock()
+		s.count += n
+		s.mutex.Unlock()
+	}
+}
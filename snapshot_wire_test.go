@@ -0,0 +1,154 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEncodeMergeSnapshot(t *testing.T) {
+	exporter := NewRegistry()
+	NewRegisteredCounter("counter", exporter).Inc(10)
+	NewRegisteredGauge("gauge", exporter).Update(1)
+
+	for i := 1; i <= 2; i++ {
+		worker := NewRegistry()
+		NewRegisteredCounter("counter", worker).Inc(int64(i))
+		NewRegisteredGauge("gauge", worker).Update(int64(10 * i))
+		gc := NewGaugeCounter()
+		gc.Inc(int64(i))
+		worker.Register("gaugecounter", gc)
+		h := NewRegisteredHistogram("histogram", worker, NewUniformSample(5))
+		hf := NewRegisteredHistogramFloat64("histogramfloat64", worker, NewExpDecaySampleFloat64(100, 0.015))
+		for j := 1; j <= 10; j++ {
+			h.Update(int64(j))
+			hf.Update(float64(j) / 2)
+		}
+		NewRegisteredMeter("meter", worker)
+
+		var buf bytes.Buffer
+		if err := EncodeSnapshot(worker, &buf); nil != err {
+			t.Fatal(err)
+		}
+		if c := worker.Get("counter").(Counter).Count(); 0 != c {
+			t.Errorf("worker counter: 0 != %v\n", c)
+		}
+		if c := h.Count(); 0 != c {
+			t.Errorf("worker histogram: 0 != %v\n", c)
+		}
+		if err := exporter.(*StandardRegistry).MergeSnapshot(&buf); nil != err {
+			t.Fatal(err)
+		}
+	}
+
+	if c := exporter.Get("counter").(Counter).Count(); 13 != c {
+		t.Errorf("counter: 13 != %v\n", c)
+	}
+	if v := exporter.Get("gauge").(Gauge).Value(); 20 != v {
+		t.Errorf("gauge: 20 != %v\n", v)
+	}
+	if c := exporter.Get("gaugecounter").(GaugeCounter).Count(); 2 != c {
+		t.Errorf("gaugecounter: 2 != %v\n", c)
+	}
+	h := exporter.Get("histogram").(Histogram)
+	if c := h.Count(); 20 != c {
+		t.Errorf("histogram count: 20 != %v\n", c)
+	}
+	if s := h.Sample().Size(); 5 != s {
+		t.Errorf("histogram size: 5 != %v\n", s)
+	}
+	hf := exporter.Get("histogramfloat64").(HistogramFloat64)
+	if c := hf.Count(); 20 != c {
+		t.Errorf("histogramfloat64 count: 20 != %v\n", c)
+	}
+	if s := hf.Sum(); 55 != s {
+		t.Errorf("histogramfloat64 sum: 55 != %v\n", s)
+	}
+	if i := exporter.Get("meter"); nil != i {
+		t.Errorf("meter: %v\n", i)
+	}
+}
+
+func TestMergeSnapshotTypeMismatch(t *testing.T) {
+	worker := NewRegistry()
+	NewRegisteredCounter("foo", worker).Inc(1)
+	var buf bytes.Buffer
+	if err := EncodeSnapshot(worker, &buf); nil != err {
+		t.Fatal(err)
+	}
+	exporter := NewRegistry()
+	NewRegisteredGauge("foo", exporter)
+	err := MergeSnapshot(exporter, &buf)
+	if nil == err || !strings.Contains(err.Error(), "foo") {
+		t.Errorf("err: %v\n", err)
+	}
+}
+
+func TestMergeSnapshotMalformed(t *testing.T) {
+	for _, m := range []dumpedMetric{
+		{Type: "histogram", Sample: "uniform", ReservoirSize: 1 << 62},
+		{Type: "histogram", Sample: "expdecay", ReservoirSize: 0, Alpha: 0.015},
+		{Type: "histogram", Sample: "uniform", ReservoirSize: -1},
+		{Type: "histogramfloat64", Sample: "uniform", ReservoirSize: 1 << 62},
+		{Type: "histogramfloat64", Sample: "expdecay", ReservoirSize: 0, Alpha: 0.015},
+		{Type: "histogramfloat64", Sample: "uniform", ReservoirSize: 1, FloatValues: []float64{1, 2}},
+	} {
+		b := encodeDump(registryDump{Version: 1, Metrics: map[string]dumpedMetric{"foo": m}})
+		r := NewRegistry()
+		if err := MergeSnapshot(r, bytes.NewReader(b)); nil == err {
+			t.Errorf("MergeSnapshot(%+v): nil error\n", m)
+		}
+		if i := r.Get("foo"); nil != i {
+			t.Errorf("MergeSnapshot(%+v): registered %v\n", m, i)
+		}
+	}
+	if err := MergeSnapshot(NewRegistry(), strings.NewReader("not gob")); nil == err {
+		t.Error("MergeSnapshot(not gob): nil error")
+	}
+}
+
+func TestEncodeSnapshotDatagrams(t *testing.T) {
+	r := NewRegistry()
+	for i := 0; i < 50; i++ {
+		NewRegisteredCounter(fmt.Sprintf("requests.%d", i), r).Inc(1)
+	}
+	h := NewRegisteredHistogramFloat64("latency", r, NewUniformSampleFloat64(1028))
+	for i := 0; i < 500; i++ {
+		h.Update(float64(i) / 3)
+	}
+	datagrams, err := encodeSnapshotDatagrams(r, 400)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if len(datagrams) < 2 {
+		t.Fatalf("len(datagrams): 2+ != %v\n", len(datagrams))
+	}
+	merged := NewRegistry()
+	for _, b := range datagrams {
+		if 400 < len(b) {
+			t.Errorf("len(b): 400 >= %v\n", len(b))
+		}
+		if err := MergeSnapshot(merged, bytes.NewReader(b)); nil != err {
+			t.Fatal(err)
+		}
+	}
+	if c := merged.Get("requests.49").(Counter).Count(); 1 != c {
+		t.Errorf("requests.49: 1 != %v\n", c)
+	}
+	if c := merged.Get("latency").(HistogramFloat64).Count(); 500 != c {
+		t.Errorf("latency: 500 != %v\n", c)
+	}
+
+	NewRegisteredCounter(strings.Repeat("x", 500), r).Inc(1)
+	NewRegisteredCounter("requests.0", r).Inc(1)
+	datagrams, err = encodeSnapshotDatagrams(r, 400)
+	if nil == err {
+		t.Error("too large a metric was not reported")
+	}
+	for _, b := range datagrams {
+		if 400 < len(b) {
+			t.Errorf("len(b): 400 >= %v\n", len(b))
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultStatsDMTU is the largest datagram a StatsDClient sends by default,
+// which fits in an Ethernet frame with the IPv6 and UDP headers.
+const defaultStatsDMTU = 1432
+
+// StatsDConfig provides a container with configuration parameters for the
+// StatsD exporter.
+//
+// Addr is "host:port" or "udp://host:port" for UDP, "tcp://host:port" for
+// TCP, "unix:///path" or "unixgram:///path" for a Unix datagram socket, as
+// StatsD agents on the same node usually listen on, and
+// "unixstream:///path" for a Unix stream socket.  A path beginning with "@",
+// eg "unix://@statsd", names a socket in Linux's abstract namespace.
+//
+// Metrics are translated as by CollectPoints: monotonic sums become
+// counters of their increase since the last flush, other sums and gauges
+// become gauges, and summaries become name.count and name.sum gauges plus a
+// gauge per quantile, eg name.p99.  StatsD has no tags, so the Tags of
+// points are dropped.  Lines are packed into datagrams of at most MTU bytes
+// on datagram sockets and written newline-terminated on stream sockets.
+type StatsDConfig struct {
+	Addr          string        // Address of the StatsD server or agent, eg "localhost:8125" or "unix:///var/run/statsd.sock"
+	Registry      Registry      // Registry to be exported
+	FlushInterval time.Duration // Flush interval
+	DurationUnit  time.Duration // Time conversion unit for durations
+	Prefix        string        // Prefix to be prepended to metric names
+	DialTimeout   time.Duration // Timeout for connecting, if any
+	WriteTimeout  time.Duration // Timeout for writing each flush, if any
+	MTU           int           // Largest datagram to send on datagram sockets, defaulting to 1432
+	Filter        MetricFilter  // Selects the metrics to send, defaulting to all
+}
+
+// StatsD is a blocking exporter function which sends the metrics in
+// c.Registry to a StatsD server every c.FlushInterval.
+func StatsD(c StatsDConfig) {
+	NewStatsDReporter(c).Run(context.Background())
+}
+
+// NewStatsDReporter constructs a new Reporter which exports just as StatsD
+// does, logging any errors.
+func NewStatsDReporter(c StatsDConfig) *Reporter {
+	return newExporterReporter(c.Registry, NewStatsDClient(c), SchedulerConfig{Interval: c.FlushInterval})
+}
+
+// StatsDClient sends metrics to a StatsD server.  It remembers what each
+// counter stood at when last sent, so that it can send their increases.
+type StatsDClient struct {
+	config StatsDConfig
+	mutex  sync.Mutex
+	conn   net.Conn
+	counts map[string]float64
+}
+
+// NewStatsDClient constructs a new StatsDClient.  It doesn't connect until
+// the first Send.
+func NewStatsDClient(c StatsDConfig) *StatsDClient {
+	return &StatsDClient{config: c, counts: make(map[string]float64)}
+}
+
+// Send sends the metrics in the registry once, connecting first if need be.
+// After an error the connection is closed, the next Send reconnects and the
+// increases of the counters which weren't sent are sent again.
+func (s *StatsDClient) Send() error {
+	return s.Export(s.config.Registry)
+}
+
+// Export sends the metrics in r once, as Send does the configured
+// registry's, so that a StatsDClient is an Exporter.
+func (s *StatsDClient) Export(r Registry) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	network, addr, err := parseStatsDAddr(s.config.Addr)
+	if nil != err {
+		return err
+	}
+	if nil == s.conn {
+		conn, err := net.DialTimeout(network, addr, s.config.DialTimeout)
+		if nil != err {
+			return err
+		}
+		s.conn = conn
+	}
+	lines := s.lines(r)
+	if 0 < s.config.WriteTimeout {
+		s.conn.SetWriteDeadline(time.Now().Add(s.config.WriteTimeout))
+	}
+	if err := s.write(network, lines); nil != err {
+		s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+// Close closes the connection to the server, if any.
+func (s *StatsDClient) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if nil == s.conn {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// statsDLine is a line of StatsD's protocol and, for a counter's, what the
+// counter stands at, for write to remember once the line is sent.
+type statsDLine struct {
+	text  string
+	key   string
+	count float64
+}
+
+// lines translates r into StatsD lines.
+func (s *StatsDClient) lines(r Registry) []statsDLine {
+	var lines []statsDLine
+	add := func(name, typ string, v float64) {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return
+		}
+		value := strconv.FormatFloat(v, 'f', -1, 64)
+		if "g" == typ && v < 0 {
+			// A signed gauge value is taken as a change, so a negative
+			// one has to be sent as a change from zero.
+			lines = append(lines, statsDLine{text: name + ":0|g"})
+		}
+		lines = append(lines, statsDLine{text: name + ":" + value + "|" + typ})
+	}
+	for _, p := range CollectPoints(s.config.Filter.registry(r), s.config.DurationUnit) {
+		name := statsDName(s.config.Prefix + p.Name)
+		switch {
+		case PointSum == p.Kind && p.Monotonic:
+			key := name + "|" + statsDTagKey(p.Tags)
+			delta := p.Value - s.counts[key]
+			if delta < 0 {
+				delta = p.Value // reset, eg by Clear
+			}
+			if 0 != delta {
+				add(name, "c", delta)
+				lines[len(lines)-1].key, lines[len(lines)-1].count = key, p.Value
+			}
+		case PointSummary == p.Kind:
+			add(name+".count", "g", float64(p.Count))
+			add(name+".sum", "g", p.Sum)
+			for _, q := range p.Quantiles {
+				add(name+"."+quantileSuffix(q.Quantile), "g", q.Value)
+			}
+		default:
+			add(name, "g", p.Value)
+		}
+	}
+	return lines
+}
+
+// write writes the given lines to the connection, packed into datagrams of
+// at most the MTU on datagram networks, and remembers what the counters
+// stood at as each line is sent, so that an error partway through doesn't
+// cause what was sent to be sent again.  The caller must hold mutex.
+func (s *StatsDClient) write(network string, lines []statsDLine) error {
+	sent := func(lines []statsDLine) {
+		for _, line := range lines {
+			if "" != line.key {
+				s.counts[line.key] = line.count
+			}
+		}
+	}
+	if "udp" != network && "unixgram" != network {
+		var buf bytes.Buffer
+		ends := make([]int, len(lines))
+		for i, line := range lines {
+			buf.WriteString(line.text)
+			buf.WriteByte('\n')
+			ends[i] = buf.Len()
+		}
+		n, err := s.conn.Write(buf.Bytes())
+		i := 0
+		for i < len(lines) && ends[i] <= n {
+			i++
+		}
+		sent(lines[:i])
+		return err
+	}
+	mtu := s.config.MTU
+	if mtu <= 0 {
+		mtu = defaultStatsDMTU
+	}
+	var buf bytes.Buffer
+	first := 0 // of the lines in buf
+	for i, line := range lines {
+		if 0 < buf.Len() && buf.Len()+1+len(line.text) > mtu {
+			if _, err := s.conn.Write(buf.Bytes()); nil != err {
+				return err
+			}
+			sent(lines[first:i])
+			buf.Reset()
+			first = i
+		}
+		if 0 < buf.Len() {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(line.text)
+	}
+	if 0 < buf.Len() {
+		if _, err := s.conn.Write(buf.Bytes()); nil != err {
+			return err
+		}
+		sent(lines[first:])
+	}
+	return nil
+}
+
+// parseStatsDAddr returns the network and address to dial for the given
+// StatsDConfig.Addr.
+func parseStatsDAddr(addr string) (string, string, error) {
+	i := strings.Index(addr, "://")
+	if i < 0 {
+		return "udp", addr, nil
+	}
+	network, ok := map[string]string{
+		"udp":        "udp",
+		"tcp":        "tcp",
+		"unix":       "unixgram",
+		"unixgram":   "unixgram",
+		"unixstream": "unix",
+	}[addr[:i]]
+	if !ok {
+		return "", "", fmt.Errorf("StatsD: unknown scheme in %q", addr)
+	}
+	return network, addr[i+3:], nil
+}
+
+// statsDName replaces the characters StatsD's line protocol reserves in
+// the given name with underscores.
+func statsDName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '|', '@', '\n':
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// statsDTagKey returns the given tags in a canonical form, to tell apart
+// the counts of points which differ only in their tags.
+func statsDTagKey(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for k, v := range tags {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
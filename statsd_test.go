@@ -0,0 +1,187 @@
+package metrics
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readStatsDPacket reads one datagram from c, failing the test if none
+// arrives within a few seconds.
+func readStatsDPacket(t *testing.T, c net.PacketConn) string {
+	buf := make([]byte, 65536)
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := c.ReadFrom(buf)
+	if nil != err {
+		t.Fatal(err)
+	}
+	return string(buf[:n])
+}
+
+func TestStatsDUDP(t *testing.T) {
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	r := NewRegistry()
+	counter := NewRegisteredCounter("requests", r)
+	counter.Inc(5)
+	NewRegisteredGauge("temperature", r).Update(-3)
+	client := NewStatsDClient(StatsDConfig{Addr: c.LocalAddr().String(), Registry: r, Prefix: "relay."})
+	defer client.Close()
+
+	if err := client.Send(); nil != err {
+		t.Fatal(err)
+	}
+	if p := readStatsDPacket(t, c); "relay.requests:5|c\nrelay.temperature:0|g\nrelay.temperature:-3|g" != p {
+		t.Errorf("packet: %q\n", p)
+	}
+	counter.Inc(2)
+	if err := client.Send(); nil != err {
+		t.Fatal(err)
+	}
+	if p := readStatsDPacket(t, c); "relay.requests:2|c\nrelay.temperature:0|g\nrelay.temperature:-3|g" != p {
+		t.Errorf("packet: %q\n", p)
+	}
+}
+
+func TestStatsDMTU(t *testing.T) {
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	r := NewRegistry()
+	h := NewRegisteredHistogram("latency", r, NewUniformSample(100))
+	h.Update(10)
+	client := NewStatsDClient(StatsDConfig{Addr: "udp://" + c.LocalAddr().String(), Registry: r, MTU: 40})
+	defer client.Close()
+	if err := client.Send(); nil != err {
+		t.Fatal(err)
+	}
+	var lines []string
+	for len(lines) < 7 {
+		p := readStatsDPacket(t, c)
+		if 40 < len(p) {
+			t.Errorf("len(p): 40 >= %v\n", len(p))
+		}
+		lines = append(lines, strings.Split(p, "\n")...)
+	}
+	if "latency.count:1|g" != lines[0] || "latency.p999:10|g" != lines[6] {
+		t.Errorf("lines: %q\n", lines)
+	}
+}
+
+// statsDConn is a net.Conn which records what's written to it, failing
+// each write after the first n.
+type statsDConn struct {
+	net.Conn
+	n       int
+	written []string
+}
+
+func (c *statsDConn) Write(b []byte) (int, error) {
+	if len(c.written) >= c.n {
+		return 0, errors.New("write failed")
+	}
+	c.written = append(c.written, string(b))
+	return len(b), nil
+}
+
+func (c *statsDConn) Close() error { return nil }
+
+func TestStatsDPartialFailure(t *testing.T) {
+	r := NewRegistry()
+	a := NewRegisteredCounter("a", r)
+	b := NewRegisteredCounter("b", r)
+	a.Inc(5)
+	b.Inc(7)
+	client := NewStatsDClient(StatsDConfig{Addr: "udp://127.0.0.1:8125", Registry: r, MTU: 5})
+
+	// The first datagram is sent and the second fails, so only b's
+	// increase is sent again.
+	conn := &statsDConn{n: 1}
+	client.conn = conn
+	if err := client.Send(); nil == err {
+		t.Fatal("no error")
+	}
+	if 1 != len(conn.written) || "a:5|c" != conn.written[0] {
+		t.Errorf("conn.written: %q\n", conn.written)
+	}
+	conn = &statsDConn{n: 2}
+	client.conn = conn
+	if err := client.Send(); nil != err {
+		t.Fatal(err)
+	}
+	if 1 != len(conn.written) || "b:7|c" != conn.written[0] {
+		t.Errorf("conn.written: %q\n", conn.written)
+	}
+}
+
+func TestStatsDUnixStream(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metrics")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "statsd.sock")
+	r := NewRegistry()
+	NewRegisteredCounter("requests", r).Inc(5)
+	client := NewStatsDClient(StatsDConfig{Addr: "unixstream://" + path, Registry: r, WriteTimeout: time.Second})
+	defer client.Close()
+
+	// Counts sent while the agent is down are sent once it's back.
+	if err := client.Send(); nil == err {
+		t.Fatal("no agent: no error")
+	}
+	l, err := net.Listen("unix", path)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if err := client.Send(); nil != err {
+		t.Fatal(err)
+	}
+	conn, err := l.Accept()
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "requests:5|c\n" != line {
+		t.Errorf("line: %q\n", line)
+	}
+}
+
+func TestStatsDUnixAbstract(t *testing.T) {
+	if "linux" != runtime.GOOS {
+		t.Skip("abstract sockets are Linux-only")
+	}
+	c, err := net.ListenPacket("unixgram", "@go-metrics-statsd-test")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	r := NewRegistry()
+	NewRegisteredGauge("connections", r).Update(7)
+	client := NewStatsDClient(StatsDConfig{Addr: "unix://@go-metrics-statsd-test", Registry: r})
+	defer client.Close()
+	if err := client.Send(); nil != err {
+		t.Fatal(err)
+	}
+	if p := readStatsDPacket(t, c); "connections:7|g" != p {
+		t.Errorf("packet: %q\n", p)
+	}
+}
@@ -0,0 +1,42 @@
+package metrics
+
+import "time"
+
+// Stopwatch times one run of a multi-stage operation, recording the duration
+// of each stage as a lap and the whole run into the Timer it came from, so
+// that the stages can be instrumented with one object rather than one
+// UpdateSince call each.  Stopwatches aren't safe for concurrent use.
+type Stopwatch struct {
+	timer      Timer
+	clock      Clock
+	start, lap time.Time
+}
+
+// NewStopwatch constructs and starts a new Stopwatch which records into the
+// given Timer and reads the time from the given Clock.  Timers return one
+// from their Stopwatch method so this is only needed to implement one.
+func NewStopwatch(t Timer, c Clock) *Stopwatch {
+	now := c.Now()
+	return &Stopwatch{timer: t, clock: c, start: now, lap: now}
+}
+
+// Lap records the time since the previous lap, or since the stopwatch was
+// started if this is the first, into the Timer's sibling Timer for the named
+// lap and returns it.  Laps are only recorded if the Timer is a LapTimer.
+func (sw *Stopwatch) Lap(name string) time.Duration {
+	now := sw.clock.Now()
+	d := now.Sub(sw.lap)
+	sw.lap = now
+	if lt, ok := sw.timer.(LapTimer); ok {
+		lt.Lap(name).Update(d)
+	}
+	return d
+}
+
+// Stop records the time since the stopwatch was started into the Timer and
+// returns it.  It should be called once, after the last lap.
+func (sw *Stopwatch) Stop() time.Duration {
+	d := sw.clock.Now().Sub(sw.start)
+	sw.timer.Update(d)
+	return d
+}
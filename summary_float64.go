@@ -0,0 +1,253 @@
+package metrics
+
+import (
+	"math"
+	"sync"
+)
+
+// SummaryFloat64s track the count, sum, mean, variance, minimum and maximum
+// of a stream of float64s incrementally, using Welford's algorithm, without
+// keeping a reservoir.  They're for when percentiles aren't needed: every
+// value counts towards exact statistics at a constant cost and without
+// allocating.
+type SummaryFloat64 interface {
+	Clear() SummaryFloat64 // atomically clears and returns a snapshot
+	Count() int64
+	Max() float64
+	Mean() float64
+	Min() float64
+	Snapshot() SummaryFloat64
+	StdDev() float64
+	Sum() float64
+	Update(float64)
+	Variance() float64
+}
+
+// GetOrRegisterSummaryFloat64 returns an existing SummaryFloat64 or
+// constructs and registers a new StandardSummaryFloat64.
+func GetOrRegisterSummaryFloat64(name string, r Registry) SummaryFloat64 {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	s, ok := r.GetOrRegister(name, NewSummaryFloat64).(SummaryFloat64)
+	if !ok {
+		return registrationConflict(DuplicateMetric(name), NewSummaryFloat64()).(SummaryFloat64)
+	}
+	return s
+}
+
+// GetSummaryFloat64 returns the SummaryFloat64 registered under the given
+// name, or false if there is none or the metric registered there is not a
+// SummaryFloat64.
+func GetSummaryFloat64(name string, r Registry) (SummaryFloat64, bool) {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	s, ok := r.Get(name).(SummaryFloat64)
+	return s, ok
+}
+
+// NewSummaryFloat64 constructs a new StandardSummaryFloat64.
+func NewSummaryFloat64() SummaryFloat64 {
+	if UseNilMetrics {
+		return NilSummaryFloat64{}
+	}
+	return &StandardSummaryFloat64{}
+}
+
+// NewRegisteredSummaryFloat64 constructs and registers a new
+// StandardSummaryFloat64.
+func NewRegisteredSummaryFloat64(name string, r Registry) SummaryFloat64 {
+	c := NewSummaryFloat64()
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// NilSummaryFloat64 is a no-op SummaryFloat64.
+type NilSummaryFloat64 struct{}
+
+// Clear is a no-op.
+func (NilSummaryFloat64) Clear() SummaryFloat64 { return NilSummaryFloat64{} }
+
+// Count is a no-op.
+func (NilSummaryFloat64) Count() int64 { return 0 }
+
+// Max is a no-op.
+func (NilSummaryFloat64) Max() float64 { return 0.0 }
+
+// Mean is a no-op.
+func (NilSummaryFloat64) Mean() float64 { return 0.0 }
+
+// Min is a no-op.
+func (NilSummaryFloat64) Min() float64 { return 0.0 }
+
+// Snapshot is a no-op.
+func (NilSummaryFloat64) Snapshot() SummaryFloat64 { return NilSummaryFloat64{} }
+
+// StdDev is a no-op.
+func (NilSummaryFloat64) StdDev() float64 { return 0.0 }
+
+// Sum is a no-op.
+func (NilSummaryFloat64) Sum() float64 { return 0.0 }
+
+// Update is a no-op.
+func (NilSummaryFloat64) Update(float64) {}
+
+// Variance is a no-op.
+func (NilSummaryFloat64) Variance() float64 { return 0.0 }
+
+// StandardSummaryFloat64 is the standard implementation of a SummaryFloat64.
+type StandardSummaryFloat64 struct {
+	mutex sync.Mutex
+	stats summaryStats
+}
+
+// Clear clears the summary and returns a snapshot of it from before.
+func (s *StandardSummaryFloat64) Clear() SummaryFloat64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	snapshot := &SummaryFloat64Snapshot{stats: s.stats}
+	s.stats = summaryStats{}
+	return snapshot
+}
+
+// Count returns the number of values recorded.
+func (s *StandardSummaryFloat64) Count() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stats.count
+}
+
+// Max returns the maximum value recorded.
+func (s *StandardSummaryFloat64) Max() float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stats.max
+}
+
+// Mean returns the mean of the values recorded.
+func (s *StandardSummaryFloat64) Mean() float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stats.mean
+}
+
+// Min returns the minimum value recorded.
+func (s *StandardSummaryFloat64) Min() float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stats.min
+}
+
+// Snapshot returns a read-only copy of the summary.
+func (s *StandardSummaryFloat64) Snapshot() SummaryFloat64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return &SummaryFloat64Snapshot{stats: s.stats}
+}
+
+// StdDev returns the standard deviation of the values recorded.
+func (s *StandardSummaryFloat64) StdDev() float64 {
+	return math.Sqrt(s.Variance())
+}
+
+// Sum returns the sum of the values recorded.
+func (s *StandardSummaryFloat64) Sum() float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stats.sum.Sum()
+}
+
+// Update records a new value.  NaN values are ignored.
+func (s *StandardSummaryFloat64) Update(v float64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.stats.add(v)
+}
+
+// Variance returns the variance of the values recorded.
+func (s *StandardSummaryFloat64) Variance() float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stats.variance()
+}
+
+// SummaryFloat64Snapshot is a read-only copy of another SummaryFloat64.
+type SummaryFloat64Snapshot struct {
+	stats summaryStats
+}
+
+// Clear panics unless PanicOnSnapshotMutation is false.
+func (s *SummaryFloat64Snapshot) Clear() SummaryFloat64 {
+	snapshotMisuse("Clear called on a SummaryFloat64Snapshot")
+	return s
+}
+
+// Count returns the number of values recorded at the time the snapshot was
+// taken.
+func (s *SummaryFloat64Snapshot) Count() int64 { return s.stats.count }
+
+// Max returns the maximum value at the time the snapshot was taken.
+func (s *SummaryFloat64Snapshot) Max() float64 { return s.stats.max }
+
+// Mean returns the mean value at the time the snapshot was taken.
+func (s *SummaryFloat64Snapshot) Mean() float64 { return s.stats.mean }
+
+// Min returns the minimum value at the time the snapshot was taken.
+func (s *SummaryFloat64Snapshot) Min() float64 { return s.stats.min }
+
+// Snapshot returns the snapshot.
+func (s *SummaryFloat64Snapshot) Snapshot() SummaryFloat64 { return s }
+
+// StdDev returns the standard deviation of values at the time the snapshot
+// was taken.
+func (s *SummaryFloat64Snapshot) StdDev() float64 {
+	return math.Sqrt(s.stats.variance())
+}
+
+// Sum returns the sum of values at the time the snapshot was taken.
+func (s *SummaryFloat64Snapshot) Sum() float64 { return s.stats.sum.Sum() }
+
+// Update panics unless PanicOnSnapshotMutation is false.
+func (*SummaryFloat64Snapshot) Update(float64) {
+	snapshotMisuse("Update called on a SummaryFloat64Snapshot")
+}
+
+// Variance returns the variance of values at the time the snapshot was taken.
+func (s *SummaryFloat64Snapshot) Variance() float64 { return s.stats.variance() }
+
+// summaryStats is the unsynchronised state shared by StandardSummaryFloat64
+// and its snapshots.
+type summaryStats struct {
+	count    int64
+	min, max float64
+	sum      kahanSum
+	mean, m2 float64 // Welford's running mean and sum of squared deviations
+}
+
+func (s *summaryStats) add(v float64) {
+	if math.IsNaN(v) {
+		return
+	}
+	if 0 == s.count || v < s.min {
+		s.min = v
+	}
+	if 0 == s.count || v > s.max {
+		s.max = v
+	}
+	s.count++
+	s.sum.Add(v)
+	delta := v - s.mean
+	s.mean += delta / float64(s.count)
+	s.m2 += delta * (v - s.mean)
+}
+
+func (s *summaryStats) variance() float64 {
+	if 0 == s.count {
+		return 0.0
+	}
+	return s.m2 / float64(s.count)
+}
@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+)
+
+func BenchmarkSummaryFloat64(b *testing.B) {
+	s := NewSummaryFloat64()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Update(float64(i))
+	}
+}
+
+func TestSummaryFloat64(t *testing.T) {
+	s := NewSummaryFloat64()
+	for i := 1; i <= 10000; i++ {
+		s.Update(float64(i))
+	}
+	s.Update(math.NaN())
+	if count := s.Count(); 10000 != count {
+		t.Errorf("s.Count(): 10000 != %v\n", count)
+	}
+	if min := s.Min(); 1 != min {
+		t.Errorf("s.Min(): 1 != %v\n", min)
+	}
+	if max := s.Max(); 10000 != max {
+		t.Errorf("s.Max(): 10000 != %v\n", max)
+	}
+	if mean := s.Mean(); 5000.5 != mean {
+		t.Errorf("s.Mean(): 5000.5 != %v\n", mean)
+	}
+	if sum := s.Sum(); 50005000 != sum {
+		t.Errorf("s.Sum(): 50005000 != %v\n", sum)
+	}
+	if stdDev := s.StdDev(); math.Abs(2886.751331514372-stdDev) > 1e-6 {
+		t.Errorf("s.StdDev(): 2886.751331514372 != %v\n", stdDev)
+	}
+	if count := s.Clear().Count(); 10000 != count {
+		t.Errorf("s.Clear().Count(): 10000 != %v\n", count)
+	}
+	if count := s.Count(); 0 != count {
+		t.Errorf("s.Count(): 0 != %v\n", count)
+	}
+}
+
+func TestSummaryFloat64Allocs(t *testing.T) {
+	s := NewSummaryFloat64()
+	if n := testing.AllocsPerRun(100, func() { s.Update(1.5) }); 0 != n {
+		t.Errorf("allocations per Update: 0 != %v\n", n)
+	}
+}
+
+func TestSummaryFloat64Snapshot(t *testing.T) {
+	s := NewSummaryFloat64()
+	s.Update(1)
+	s.Update(3)
+	snapshot := s.Snapshot()
+	s.Update(5)
+	if count := snapshot.Count(); 2 != count {
+		t.Errorf("snapshot.Count(): 2 != %v\n", count)
+	}
+	if mean := snapshot.Mean(); 2 != mean {
+		t.Errorf("snapshot.Mean(): 2 != %v\n", mean)
+	}
+	if variance := snapshot.Variance(); 1 != variance {
+		t.Errorf("snapshot.Variance(): 1 != %v\n", variance)
+	}
+}
+
+func TestGetOrRegisterSummaryFloat64(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredSummaryFloat64("foo", r).Update(47)
+	if s := GetOrRegisterSummaryFloat64("foo", r); 1 != s.Count() {
+		t.Fatal(s)
+	}
+	if _, ok := GetSummaryFloat64("foo", r); !ok {
+		t.Fatal("GetSummaryFloat64: not found")
+	}
+}
+
+func TestCollectPointsSummaryFloat64(t *testing.T) {
+	r := NewRegistry()
+	s := NewRegisteredSummaryFloat64("foo", r)
+	s.Update(1.5)
+	s.Update(2.5)
+	points := CollectPoints(r, 0)
+	if 1 != len(points) {
+		t.Fatalf("len(points): 1 != %v\n", len(points))
+	}
+	if p := points[0]; PointSummary != p.Kind || 2 != p.Count || 4 != p.Sum {
+		t.Errorf("points[0]: %+v\n", p)
+	}
+}
@@ -3,6 +3,7 @@
 package metrics
 
 import (
+	"context"
 	"fmt"
 	"log/syslog"
 	"time"
@@ -11,87 +12,149 @@ import (
 // Output each metric in the given registry to syslog periodically using
 // the given syslogger.
 func Syslog(r Registry, d time.Duration, w *syslog.Writer) {
-	for _ = range time.Tick(d) {
-		r.Each(func(name string, i interface{}) {
-			switch metric := i.(type) {
-			case Counter:
-				w.Info(fmt.Sprintf("counter %s: count: %d", name, metric.Count()))
-			case GaugeCounter:
-				w.Info(fmt.Sprintf("counter %s: value: %d", name, metric.Count()))
-			case Gauge:
-				w.Info(fmt.Sprintf("gauge %s: value: %d", name, metric.Value()))
-			case GaugeFloat64:
-				w.Info(fmt.Sprintf("gauge %s: value: %f", name, metric.Value()))
-			case Healthcheck:
-				metric.Check()
-				w.Info(fmt.Sprintf("healthcheck %s: error: %v", name, metric.Error()))
-			case Histogram:
-				h := metric.Snapshot()
-				ps := h.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
-				w.Info(fmt.Sprintf(
-					"histogram %s: count: %d min: %d max: %d mean: %.2f stddev: %.2f median: %.2f 75%%: %.2f 95%%: %.2f 99%%: %.2f 99.9%%: %.2f",
-					name,
-					h.Count(),
-					h.Min(),
-					h.Max(),
-					h.Mean(),
-					h.StdDev(),
-					ps[0],
-					ps[1],
-					ps[2],
-					ps[3],
-					ps[4],
-				))
-			case HistogramFloat64:
-				h := metric.Snapshot()
-				ps := h.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
-				w.Info(fmt.Sprintf(
-					"histogram %s: count: %d min: %.2f max: %.2f mean: %.2f stddev: %.2f median: %.2f 75%%: %.2f 95%%: %.2f 99%%: %.2f 99.9%%: %.2f",
-					name,
-					h.Count(),
-					h.Min(),
-					h.Max(),
-					h.Mean(),
-					h.StdDev(),
-					ps[0],
-					ps[1],
-					ps[2],
-					ps[3],
-					ps[4],
-				))
-			case Meter:
-				m := metric.Snapshot()
-				w.Info(fmt.Sprintf(
-					"meter %s: count: %d 1-min: %.2f 5-min: %.2f 15-min: %.2f mean: %.2f",
-					name,
-					m.Count(),
-					m.Rate1(),
-					m.Rate5(),
-					m.Rate15(),
-					m.RateMean(),
-				))
-			case Timer:
-				t := metric.Snapshot()
-				ps := t.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
-				w.Info(fmt.Sprintf(
-					"timer %s: count: %d min: %d max: %d mean: %.2f stddev: %.2f median: %.2f 75%%: %.2f 95%%: %.2f 99%%: %.2f 99.9%%: %.2f 1-min: %.2f 5-min: %.2f 15-min: %.2f mean-rate: %.2f",
-					name,
-					t.Count(),
-					t.Min(),
-					t.Max(),
-					t.Mean(),
-					t.StdDev(),
-					ps[0],
-					ps[1],
-					ps[2],
-					ps[3],
-					ps[4],
-					t.Rate1(),
-					t.Rate5(),
-					t.Rate15(),
-					t.RateMean(),
-				))
-			}
-		})
-	}
+	SyslogScaled(r, d, 0, w)
+}
+
+// SyslogScaled is like Syslog but prints timings in `scale` units (eg
+// time.Millisecond) rather than nanos, unless the timer carries its own
+// DurationUnit.  With a scale of zero it logs timers without a DurationUnit as
+// Syslog does.
+func SyslogScaled(r Registry, d time.Duration, scale time.Duration, w *syslog.Writer) {
+	NewSyslogReporter(r, d, scale, w).Run(context.Background())
+}
+
+// NewSyslogReporter constructs a new Reporter which logs just as
+// SyslogScaled does.
+func NewSyslogReporter(r Registry, d time.Duration, scale time.Duration, w *syslog.Writer) *Reporter {
+	return newExporterReporter(r, NewSyslogExporter(scale, w), SchedulerConfig{Interval: d})
+}
+
+// NewSyslogExporter constructs a new Exporter which logs the registry it's
+// given as SyslogScaled does, for use with a Scheduler.
+func NewSyslogExporter(scale time.Duration, w *syslog.Writer) Exporter {
+	return ExporterFunc(func(r Registry) error {
+		syslogOnce(r, scale, w)
+		return nil
+	})
+}
+
+func syslogOnce(r Registry, scale time.Duration, w *syslog.Writer) {
+	r.Each(func(name string, i interface{}) {
+		switch metric := i.(type) {
+		case Counter:
+			w.Info(fmt.Sprintf("counter %s: count: %d", name, metric.Count()))
+		case Uint64Counter:
+			w.Info(fmt.Sprintf("counter %s: count: %d", name, metric.Count()))
+		case BigCounter:
+			w.Info(fmt.Sprintf("counter %s: count: %d", name, metric.Count()))
+		case GaugeCounter:
+			w.Info(fmt.Sprintf("counter %s: value: %d", name, metric.Count()))
+		case Gauge:
+			w.Info(fmt.Sprintf("gauge %s: value: %d", name, metric.Value()))
+		case GaugeFloat64:
+			w.Info(fmt.Sprintf("gauge %s: value: %f", name, metric.Value()))
+		case MovingAverage:
+			w.Info(fmt.Sprintf("gauge %s: value: %f", name, metric.Value()))
+		case RateGauge:
+			w.Info(fmt.Sprintf("gauge %s: value: %f", name, metric.Rate()))
+		case Cardinality:
+			w.Info(fmt.Sprintf("gauge %s: value: %d", name, metric.Snapshot().Estimate()))
+		case BoolGauge:
+			w.Info(fmt.Sprintf("gauge %s: value: %d", name, boolGaugeValue(metric)))
+		case EnumGauge:
+			s := fmt.Sprintf("enum %s:", name)
+			eachEnumState(metric, func(state string, v int64) {
+				s += fmt.Sprintf(" %s: %d", state, v)
+			})
+			w.Info(s)
+		case InfoMetric:
+			s := fmt.Sprintf("info %s:", name)
+			eachInfoLabel(metric, func(k, v string) {
+				s += fmt.Sprintf(" %s: %s", k, v)
+			})
+			w.Info(s)
+		case Healthcheck:
+			metric.Check()
+			w.Info(fmt.Sprintf("healthcheck %s: error: %v", name, metric.Error()))
+		case Histogram:
+			h := metric.Snapshot()
+			ps := h.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
+			w.Info(fmt.Sprintf(
+				"histogram %s: count: %d min: %d max: %d mean: %.2f stddev: %.2f median: %.2f 75%%: %.2f 95%%: %.2f 99%%: %.2f 99.9%%: %.2f",
+				name,
+				h.Count(),
+				h.Min(),
+				h.Max(),
+				h.Mean(),
+				h.StdDev(),
+				ps[0],
+				ps[1],
+				ps[2],
+				ps[3],
+				ps[4],
+			))
+		case HistogramFloat64:
+			h := metric.Snapshot()
+			ps := h.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
+			w.Info(fmt.Sprintf(
+				"histogram %s: count: %d min: %.2f max: %.2f mean: %.2f stddev: %.2f median: %.2f 75%%: %.2f 95%%: %.2f 99%%: %.2f 99.9%%: %.2f",
+				name,
+				h.Count(),
+				h.Min(),
+				h.Max(),
+				h.Mean(),
+				h.StdDev(),
+				ps[0],
+				ps[1],
+				ps[2],
+				ps[3],
+				ps[4],
+			))
+		case SummaryFloat64:
+			s := metric.Snapshot()
+			w.Info(fmt.Sprintf(
+				"summary %s: count: %d min: %.2f max: %.2f mean: %.2f stddev: %.2f sum: %.2f",
+				name,
+				s.Count(),
+				s.Min(),
+				s.Max(),
+				s.Mean(),
+				s.StdDev(),
+				s.Sum(),
+			))
+		case Meter:
+			m := metric.Snapshot()
+			w.Info(fmt.Sprintf(
+				"meter %s: count: %d 1-min: %.2f 5-min: %.2f 15-min: %.2f mean: %.2f",
+				name,
+				m.Count(),
+				m.Rate1(),
+				m.Rate5(),
+				m.Rate15(),
+				m.RateMean(),
+			))
+		case Timer:
+			t := metric.Snapshot()
+			ts := newTimerScale(t, scale)
+			ps := t.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
+			w.Info(fmt.Sprintf(
+				"timer %s: count: %d min: %s max: %s mean: %.2f stddev: %.2f median: %.2f 75%%: %.2f 95%%: %.2f 99%%: %.2f 99.9%%: %.2f 1-min: %.2f 5-min: %.2f 15-min: %.2f mean-rate: %.2f",
+				name,
+				t.Count(),
+				ts.format(t.Min(), "%d", "%.2f"),
+				ts.format(t.Max(), "%d", "%.2f"),
+				ts.scale(t.Mean()),
+				ts.scale(t.StdDev()),
+				ts.scale(ps[0]),
+				ts.scale(ps[1]),
+				ts.scale(ps[2]),
+				ts.scale(ps[3]),
+				ts.scale(ps[4]),
+				t.Rate1(),
+				t.Rate5(),
+				t.Rate15(),
+				t.RateMean(),
+			))
+		}
+	})
 }
@@ -0,0 +1,170 @@
+//go:build !windows
+// +build !windows
+
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/syslog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SyslogConfig provides a container with configuration parameters for the
+// RFC 5424 syslog exporter, which sends one message per metric with its
+// values as structured data, eg
+//
+//	<134>1 2006-01-02T15:04:05Z host app - timer [metric@32473 name="foo" count="3" ...] foo
+//
+// Over TCP, and over TLS as in RFC 5425, each message is framed by its
+// length.
+type SyslogConfig struct {
+	Registry      Registry                   // Registry to be exported
+	FlushInterval time.Duration              // Flush interval
+	DurationUnit  time.Duration              // Time conversion unit for durations
+	Network       string                     // "udp", the default, or "tcp"
+	Addr          string                     // Network address to connect to
+	TLSConfig     *tls.Config                // If set, connect over TLS, which implies "tcp"
+	Facility      syslog.Priority            // Facility, eg syslog.LOG_LOCAL0, defaulting to syslog.LOG_USER
+	Severities    map[string]syslog.Priority // Severity by metric type, eg "timer", defaulting to syslog.LOG_INFO
+	Hostname      string                     // Defaults to os.Hostname
+	AppName       string                     // Defaults to "-", meaning none
+	SDID          string                     // Structured data ID, defaulting to "metric@32473"
+	Temporality   Temporality                // Whether to send counts as they stand, the default, or since the last flush
+	Filter        MetricFilter               // Selects the metrics to send, defaulting to all
+}
+
+// SyslogWithConfig is a blocking exporter function like Syslog, but it
+// speaks RFC 5424 directly to the configured endpoint rather than writing
+// plain text through a syslog.Writer.
+func SyslogWithConfig(c SyslogConfig) {
+	NewSyslogReporterWithConfig(c).Run(context.Background())
+}
+
+// NewSyslogReporterWithConfig constructs a new Reporter which exports just
+// as SyslogWithConfig does, logging any errors.
+func NewSyslogReporterWithConfig(c SyslogConfig) *Reporter {
+	return newExporterReporter(c.Registry, NewSyslogExporterWithConfig(c), SchedulerConfig{Interval: c.FlushInterval})
+}
+
+// NewSyslogExporterWithConfig constructs a new Exporter which sends the
+// registry it's given as SyslogWithConfig does, for use with a Scheduler.
+// c.Registry and c.FlushInterval are ignored.  The hostname defaults to
+// os.Hostname as it is at construction.
+func NewSyslogExporterWithConfig(c SyslogConfig) Exporter {
+	if "" == c.Hostname {
+		c.Hostname, _ = os.Hostname()
+	}
+	return ExporterFunc(func(r Registry) error {
+		c := c
+		c.Registry = r
+		return syslog5424(&c)
+	})
+}
+
+func syslog5424(c *SyslogConfig) error {
+	conn, err := c.dial()
+	if nil != err {
+		return err
+	}
+	defer conn.Close()
+	framed := "udp" != c.network()
+	now := time.Now()
+	return c.Temporality.registry(c.Filter.registry(c.Registry)).EachErr(func(name string, i interface{}) error {
+		m, ok := metricFields(name, i, c.DurationUnit)
+		if !ok {
+			return nil
+		}
+		msg := c.format(m, now)
+		if framed {
+			msg = strconv.Itoa(len(msg)) + " " + msg
+		}
+		_, err := conn.Write([]byte(msg))
+		return err
+	})
+}
+
+func (c *SyslogConfig) dial() (net.Conn, error) {
+	if nil != c.TLSConfig {
+		return tls.Dial("tcp", c.Addr, c.TLSConfig)
+	}
+	return net.Dial(c.network(), c.Addr)
+}
+
+func (c *SyslogConfig) network() string {
+	if nil != c.TLSConfig {
+		return "tcp"
+	}
+	if "" == c.Network {
+		return "udp"
+	}
+	return c.Network
+}
+
+// format renders m as an RFC 5424 message.
+func (c *SyslogConfig) format(m loggedMetric, now time.Time) string {
+	severity, ok := c.Severities[m.kind]
+	if !ok {
+		severity = syslog.LOG_INFO
+	}
+	facility := c.Facility &^ 0x07
+	if 0 == facility {
+		facility = syslog.LOG_USER
+	}
+	sdid := c.SDID
+	if "" == sdid {
+		sdid = "metric@32473"
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(
+		&buf,
+		"<%d>1 %s %s %s - %s [%s name=\"%s\"",
+		facility|severity&0x07,
+		now.UTC().Format(time.RFC3339Nano),
+		syslogHeaderField(c.Hostname),
+		syslogHeaderField(c.AppName),
+		m.kind,
+		sdid,
+		syslogParamValue(m.name),
+	)
+	for _, f := range m.fields {
+		var v string
+		switch value := f.value.(type) {
+		case nil:
+			continue
+		case string:
+			v = value
+		case float64:
+			v = strconv.FormatFloat(value, 'f', -1, 64)
+		default:
+			v = fmt.Sprint(value)
+		}
+		fmt.Fprintf(&buf, " %s=\"%s\"", f.key, syslogParamValue(v))
+	}
+	if "timer" == m.kind {
+		fmt.Fprintf(&buf, " unit=\"%s\"", m.unit())
+	}
+	fmt.Fprintf(&buf, "] %s", m.name)
+	return buf.String()
+}
+
+// syslogHeaderField returns s as a header field, which is "-" if empty and
+// can't contain spaces.
+func syslogHeaderField(s string) string {
+	if "" == s {
+		return "-"
+	}
+	return strings.Replace(s, " ", "_", -1)
+}
+
+// syslogParamValue escapes the characters RFC 5424 requires escaping in
+// structured data parameter values.
+func syslogParamValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(s)
+}
@@ -0,0 +1,105 @@
+//go:build !windows
+// +build !windows
+
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"log/syslog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogConfigFormat(t *testing.T) {
+	c := &SyslogConfig{
+		Facility:   syslog.LOG_LOCAL0,
+		Severities: map[string]syslog.Priority{"healthcheck": syslog.LOG_WARNING},
+		Hostname:   "host",
+		AppName:    "app",
+	}
+	now := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	counter := NewCounter()
+	counter.Inc(47)
+	m, _ := metricFields(`a"b]`, counter, time.Nanosecond)
+	if msg, want := c.format(m, now), `<134>1 2006-01-02T15:04:05Z host app - counter [metric@32473 name="a\"b\]" count="47"] a"b]`; want != msg {
+		t.Errorf("format: %v != %v\n", want, msg)
+	}
+
+	hc := NewHealthcheck(func(h Healthcheck) { h.Unhealthy(io.EOF) })
+	m, _ = metricFields("hc", hc, time.Nanosecond)
+	if msg := c.format(m, now); !strings.HasPrefix(msg, "<132>1 ") || !strings.Contains(msg, `error="EOF"`) {
+		t.Errorf("format: %v\n", msg)
+	}
+}
+
+func TestSyslogConfigTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	r := NewRegistry()
+	NewRegisteredGauge("foo", r).Update(47)
+	NewRegisteredGauge("bar", r).Update(48)
+
+	lines := make(chan string)
+	go func() {
+		conn, err := ln.Accept()
+		if nil != err {
+			close(lines)
+			return
+		}
+		defer conn.Close()
+		b, _ := ioutil.ReadAll(bufio.NewReader(conn))
+		lines <- string(b)
+	}()
+	c := SyslogConfig{Registry: r, Network: "tcp", Addr: ln.Addr().String(), Hostname: "host"}
+	if err := syslog5424(&c); nil != err {
+		t.Fatal(err)
+	}
+	got := <-lines
+	for _, s := range []string{` value="47"] foo`, ` value="48"] bar`} {
+		if !strings.Contains(got, s) {
+			t.Errorf("%q doesn't contain %q\n", got, s)
+		}
+	}
+	for "" != got {
+		sp := strings.Index(got, " ")
+		n, err := strconv.Atoi(got[:sp])
+		if nil != err || len(got) < sp+1+n || !strings.HasPrefix(got[sp+1:], "<14>1 ") {
+			t.Fatalf("not octet-counted: %q\n", got)
+		}
+		got = got[sp+1+n:]
+	}
+}
+
+func TestSyslogExporterWithConfigHostname(t *testing.T) {
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	r := NewRegistry()
+	NewRegisteredGauge("foo", r).Update(47)
+	e := NewSyslogExporterWithConfig(SyslogConfig{Addr: c.LocalAddr().String()})
+	if err := e.Export(r); nil != err {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4096)
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := c.ReadFrom(buf)
+	if nil != err {
+		t.Fatal(err)
+	}
+	host, _ := os.Hostname()
+	if fields := strings.Fields(string(buf[:n])); len(fields) < 3 || syslogHeaderField(host) != fields[2] {
+		t.Errorf("hostname: %v != %q\n", host, buf[:n])
+	}
+}
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TagLimitOther is the value of every tag in the tag set a TagLimiter
+// substitutes for those beyond its cap.
+const TagLimitOther = "other"
+
+// TagLimiter protects backends from unbounded tags, eg user IDs, by capping
+// the number of distinct tag sets per metric name.  Give it the tags of each
+// series before registering the series with them, eg with
+// RegisterWithMetadata.  The first tag sets of each name pass unchanged, and
+// once there are as many as the cap, any new tag set is replaced by one with
+// the same keys and every value TagLimitOther, so that what's recorded for
+// all those series goes to one.  Its Dropped counter counts the
+// replacements.
+type TagLimiter struct {
+	dropped Counter
+	max     int
+	mutex   sync.Mutex
+	seen    map[string]map[string]struct{}
+}
+
+// NewTagLimiter constructs a new TagLimiter of at most max tag sets per
+// metric name.  A max of zero or less defaults to 100.
+func NewTagLimiter(max int) *TagLimiter {
+	if max <= 0 {
+		max = 100
+	}
+	return &TagLimiter{
+		dropped: NewCounter(),
+		max:     max,
+		seen:    make(map[string]map[string]struct{}),
+	}
+}
+
+// NewRegisteredTagLimiter constructs a new TagLimiter and registers its
+// Dropped counter under the given name.
+func NewRegisteredTagLimiter(name string, r Registry, max int) *TagLimiter {
+	l := NewTagLimiter(max)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, l.dropped)
+	return l
+}
+
+// Dropped returns the counter of the tag sets replaced because the cap was
+// reached.
+func (l *TagLimiter) Dropped() Counter {
+	return l.dropped
+}
+
+// Limit returns the tags to give the series of the metric named name which
+// would have the given tags: those tags if they're within the cap, and the
+// overflow tag set otherwise.  The given tags are not modified.
+func (l *TagLimiter) Limit(name string, tags map[string]string) map[string]string {
+	key := tagSetKey(tags)
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	seen, ok := l.seen[name]
+	if !ok {
+		seen = make(map[string]struct{})
+		l.seen[name] = seen
+	}
+	if _, ok := seen[key]; ok || len(seen) < l.max {
+		seen[key] = struct{}{}
+		return tags
+	}
+	l.dropped.Inc(1)
+	other := make(map[string]string, len(tags))
+	for k := range tags {
+		other[k] = TagLimitOther
+	}
+	return other
+}
+
+// Forget forgets the tag sets seen of the metric named name, eg when its
+// series have been unregistered, so that new ones may pass.
+func (l *TagLimiter) Forget(name string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	delete(l.seen, name)
+}
+
+// tagSetKey returns a string which identifies the given tag set.
+func tagSetKey(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte(0)
+		b.WriteString(tags[k])
+		b.WriteByte(0)
+	}
+	return b.String()
+}
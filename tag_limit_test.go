@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestTagLimiter(t *testing.T) {
+	r := NewRegistry()
+	l := NewRegisteredTagLimiter("metrics.DroppedTagSets", r, 2)
+	for i := 0; i < 2; i++ {
+		tags := map[string]string{"user": fmt.Sprint(i), "region": "us"}
+		if limited := l.Limit("requests", tags); !reflect.DeepEqual(tags, limited) {
+			t.Errorf("l.Limit(%v): %v\n", tags, limited)
+		}
+	}
+	tags := map[string]string{"user": "2", "region": "us"}
+	other := map[string]string{"user": TagLimitOther, "region": TagLimitOther}
+	if limited := l.Limit("requests", tags); !reflect.DeepEqual(other, limited) {
+		t.Errorf("l.Limit(%v): %v\n", tags, limited)
+	}
+	if "2" != tags["user"] {
+		t.Errorf("tags modified: %v\n", tags)
+	}
+
+	// Tag sets already seen, and those of other names, still pass.
+	tags = map[string]string{"region": "us", "user": "1"}
+	if limited := l.Limit("requests", tags); !reflect.DeepEqual(tags, limited) {
+		t.Errorf("l.Limit(%v): %v\n", tags, limited)
+	}
+	tags = map[string]string{"user": "2"}
+	if limited := l.Limit("errors", tags); !reflect.DeepEqual(tags, limited) {
+		t.Errorf("l.Limit(%v): %v\n", tags, limited)
+	}
+
+	if count := r.Get("metrics.DroppedTagSets").(Counter).Count(); 1 != count {
+		t.Errorf("metrics.DroppedTagSets: 1 != %v\n", count)
+	}
+
+	l.Forget("requests")
+	tags = map[string]string{"user": "3", "region": "us"}
+	if limited := l.Limit("requests", tags); !reflect.DeepEqual(tags, limited) {
+		t.Errorf("l.Limit(%v) after Forget: %v\n", tags, limited)
+	}
+}
+
+func TestTagLimiterDefault(t *testing.T) {
+	l := NewTagLimiter(0)
+	for i := 0; i < 101; i++ {
+		l.Limit("requests", map[string]string{"user": fmt.Sprint(i)})
+	}
+	if count := l.Dropped().Count(); 1 != count {
+		t.Errorf("l.Dropped().Count(): 1 != %v\n", count)
+	}
+}
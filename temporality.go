@@ -0,0 +1,125 @@
+package metrics
+
+// Temporality is whether a reporter sends the counts of counters, histograms
+// and timers as they stand or as what was counted since its last flush.
+type Temporality int
+
+const (
+	// Cumulative sends counts as they stand, for backends which derive
+	// rates from them, eg Prometheus or OpenTSDB's rate function.
+	Cumulative Temporality = iota
+	// Delta sends what was counted since the last flush, exactly, by
+	// reading and clearing each metric as it's sent, as NewDeltaRegistry
+	// does.
+	Delta
+)
+
+// registry returns r as a reporter with this temporality reads it.
+func (t Temporality) registry(r Registry) Registry {
+	if Delta == t && nil != r {
+		return NewDeltaRegistry(r)
+	}
+	return r
+}
+
+// NewDeltaRegistry returns a view of r whose iteration methods, eg Each and
+// EachWithMetadata, clear each StandardCounter, StandardUint64Counter,
+// StandardBigCounter, StandardHistogram, ExpDecayHistogram, their
+// HistogramFloat64 counterparts, StandardTimer, StandardCardinality and
+// StandardRateGauge as they pass it, passing a snapshot of what it held
+// instead.  Get and the
+// methods which register and unregister metrics are r's own.
+//
+// Since the metrics are cleared in r, only one reporter should read r
+// through such a view, and nothing else should rely on their counts.
+func NewDeltaRegistry(r Registry) Registry {
+	return deltaRegistry{r}
+}
+
+type deltaRegistry struct {
+	Registry
+}
+
+// deltaMetric clears the given metric if it's one a deltaRegistry reads
+// and resets, returning the snapshot of it, and otherwise returns it as it
+// is.
+func deltaMetric(i interface{}) interface{} {
+	switch metric := i.(type) {
+	case *StandardCounter:
+		return metric.Clear()
+	case *StandardUint64Counter:
+		return metric.Clear()
+	case *StandardBigCounter:
+		return metric.Clear()
+	case *StandardHistogram:
+		return metric.Clear()
+	case *ExpDecayHistogram:
+		return metric.Clear()
+	case *StandardHistogramFloat64:
+		return metric.Clear()
+	case *ExpDecayHistogramFloat64:
+		return metric.Clear()
+	case *StandardTimer:
+		return metric.Clear()
+	case *StandardCardinality:
+		return metric.Clear()
+	case *StandardRateGauge:
+		return metric.Clear()
+	}
+	return i
+}
+
+func deltaFunc(f func(string, interface{})) func(string, interface{}) {
+	return func(name string, i interface{}) {
+		f(name, deltaMetric(i))
+	}
+}
+
+func deltaErrFunc(f func(string, interface{}) error) func(string, interface{}) error {
+	return func(name string, i interface{}) error {
+		return f(name, deltaMetric(i))
+	}
+}
+
+func (r deltaRegistry) Each(f func(string, interface{})) {
+	r.Registry.Each(deltaFunc(f))
+}
+
+func (r deltaRegistry) EachErr(f func(string, interface{}) error) error {
+	return r.Registry.EachErr(deltaErrFunc(f))
+}
+
+func (r deltaRegistry) EachSortedErr(f func(string, interface{}) error) error {
+	return r.Registry.EachSortedErr(deltaErrFunc(f))
+}
+
+func (r deltaRegistry) EachSorted(f func(string, interface{})) {
+	r.Registry.EachSorted(deltaFunc(f))
+}
+
+func (r deltaRegistry) EachMatching(pattern string, f func(string, interface{})) error {
+	return r.Registry.EachMatching(pattern, deltaFunc(f))
+}
+
+func (r deltaRegistry) VisitSnapshots(v Visitor) {
+	visitEach(r, v)
+}
+
+func (r deltaRegistry) EachWithMetadata(f func(string, interface{}, Metadata)) {
+	EachWithMetadata(r.Registry, func(name string, i interface{}, md Metadata) {
+		f(name, deltaMetric(i), md)
+	})
+}
+
+func (r deltaRegistry) Metadata(name string) (Metadata, bool) {
+	if mr, ok := r.Registry.(MetadataRegistry); ok {
+		return mr.Metadata(name)
+	}
+	return Metadata{}, false
+}
+
+func (r deltaRegistry) SetMetadata(name string, md Metadata) {
+	if mr, ok := r.Registry.(MetadataRegistry); ok {
+		mr.SetMetadata(name, md)
+	}
+}
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDeltaRegistry(t *testing.T) {
+	r := NewRegistry()
+	c := NewRegisteredCounter("counter", r)
+	h := NewRegisteredHistogram("histogram", r, NewUniformSample(100))
+	g := NewRegisteredGauge("gauge", r)
+	RegisterWithMetadata("tagged", r, NewCounter(), Metadata{Tags: map[string]string{"k": "v"}})
+	c.Inc(3)
+	h.Update(47)
+	g.Update(48)
+	d := NewDeltaRegistry(r)
+	d.Each(func(name string, i interface{}) {
+		switch metric := i.(type) {
+		case Counter:
+			if "counter" == name && 3 != metric.Count() {
+				t.Errorf("counter: 3 != %v\n", metric.Count())
+			}
+		case Histogram:
+			if 1 != metric.Count() {
+				t.Errorf("histogram: 1 != %v\n", metric.Count())
+			}
+		case Gauge:
+			if 48 != metric.Value() {
+				t.Errorf("gauge: 48 != %v\n", metric.Value())
+			}
+		}
+	})
+	if 0 != c.Count() {
+		t.Errorf("counter: 0 != %v\n", c.Count())
+	}
+	if 0 != h.Count() {
+		t.Errorf("histogram: 0 != %v\n", h.Count())
+	}
+	if 48 != g.Value() {
+		t.Errorf("gauge: 48 != %v\n", g.Value())
+	}
+	c.Inc(2)
+	EachWithMetadata(d, func(name string, i interface{}, md Metadata) {
+		if "counter" == name && 2 != i.(Counter).Count() {
+			t.Errorf("counter: 2 != %v\n", i.(Counter).Count())
+		}
+		if "tagged" == name && "v" != md.Tags["k"] {
+			t.Errorf("tagged: %v\n", md)
+		}
+	})
+	if d.Get("counter") != c {
+		t.Error("Get: not the counter itself")
+	}
+}
+
+func TestLogDelta(t *testing.T) {
+	r := NewRegistry()
+	c := NewRegisteredCounter("foo", r)
+	l := &bufferLogger{}
+	lr := newLogReporter(LogConfig{Registry: r, Logger: l, Format: LogFormatKeyValue, Temporality: Delta})
+	c.Inc(3)
+	lr.flush()
+	c.Inc(2)
+	lr.flush()
+	if s := l.String(); !strings.HasSuffix(s, "type=counter name=\"foo\" count=2\n") {
+		t.Errorf("unexpected output: %q", s)
+	}
+	if 0 != c.Count() {
+		t.Errorf("counter: 0 != %v\n", c.Count())
+	}
+}
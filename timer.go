@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"fmt"
 	"sync"
 	"time"
 )
@@ -24,6 +25,7 @@ type Timer interface {
 	RateMean() float64
 	Snapshot() Timer
 	StdDev() float64
+	Stopwatch() *Stopwatch
 	Sum() int64
 	Time(func())
 	Update(time.Duration)
@@ -34,10 +36,35 @@ type Timer interface {
 // GetOrRegisterTimer returns an existing Timer or constructs and registers a
 // new StandardTimer.
 func GetOrRegisterTimer(name string, r Registry) Timer {
+	t, err := TryGetOrRegisterTimer(name, r)
+	if nil != err {
+		return registrationConflict(err, NewTimer()).(Timer)
+	}
+	return t
+}
+
+// GetTimer returns the Timer registered under the given name, or false if
+// there is none or the metric registered there is not a Timer.
+func GetTimer(name string, r Registry) (Timer, bool) {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	t, ok := r.Get(name).(Timer)
+	return t, ok
+}
+
+// TryGetOrRegisterTimer is like GetOrRegisterTimer but returns a
+// DuplicateMetric error, rather than panicking, if a metric which is not a
+// Timer is registered under the given name.
+func TryGetOrRegisterTimer(name string, r Registry) (Timer, error) {
 	if nil == r {
 		r = DefaultRegistry
 	}
-	return r.GetOrRegister(name, NewTimer).(Timer)
+	t, ok := r.GetOrRegister(name, NewTimer).(Timer)
+	if !ok {
+		return nil, DuplicateMetric(name)
+	}
+	return t, nil
 }
 
 // NewCustomTimer constructs a new StandardTimer from a Histogram and a Meter.
@@ -48,6 +75,58 @@ func NewCustomTimer(h Histogram, m Meter) Timer {
 	return &StandardTimer{
 		histogram: h,
 		meter:     m,
+		clock:     DefaultClock,
+		since:     DefaultClock.Now(),
+	}
+}
+
+// NewFunctionalTimer constructs a new FunctionalTimer.
+func NewFunctionalTimer(f func() TimerStats) Timer {
+	if UseNilMetrics {
+		return NilTimer{}
+	}
+	return &FunctionalTimer{stats: f}
+}
+
+// NewRegisteredFunctionalTimer constructs and registers a new FunctionalTimer.
+func NewRegisteredFunctionalTimer(name string, r Registry, f func() TimerStats) Timer {
+	c := NewFunctionalTimer(f)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// NewTimerWithDurationUnit constructs a new StandardTimer whose durations
+// reporters will convert to the given unit, eg time.Millisecond, regardless of
+// the unit the reporter itself was configured with.
+func NewTimerWithDurationUnit(unit time.Duration) Timer {
+	if UseNilMetrics {
+		return NilTimer{}
+	}
+	return &StandardTimer{
+		histogram:    NewHistogram(NewUniformSample(histogram_pool_size)),
+		meter:        NewMeter(),
+		durationUnit: unit,
+		clock:        DefaultClock,
+		since:        DefaultClock.Now(),
+	}
+}
+
+// NewTimerWithClock constructs a new StandardTimer which reads the time from
+// the given Clock, both to time events and for its Meter.  Where wall clock
+// jumps would spoil measurements, a MonotonicClock or a ClockFunc reading
+// another source can be used.
+func NewTimerWithClock(c Clock) Timer {
+	if UseNilMetrics {
+		return NilTimer{}
+	}
+	return &StandardTimer{
+		histogram: NewHistogram(NewUniformSample(histogram_pool_size)),
+		meter:     NewMeterWithClock(c),
+		clock:     c,
+		since:     c.Now(),
 	}
 }
 
@@ -69,9 +148,124 @@ func NewTimer() Timer {
 	return &StandardTimer{
 		histogram: NewHistogram(NewUniformSample(histogram_pool_size)),
 		meter:     NewMeter(),
+		clock:     DefaultClock,
+		since:     DefaultClock.Now(),
 	}
 }
 
+// DurationUnitTimer is implemented by Timers which carry the unit their
+// durations should be reported in.  Reporters honour it in preference to their
+// own configured unit.
+type DurationUnitTimer interface {
+	Timer
+	DurationUnit() time.Duration
+}
+
+// UtilizationTimer is implemented by Timers which keep the total of the
+// durations they recorded, which a sample only has while it keeps them all,
+// and so how busy what they time is over each interval between reads of
+// their utilization.
+type UtilizationTimer interface {
+	Timer
+	TotalDuration() time.Duration
+	Utilization() float64
+}
+
+// utilization returns busy divided by elapsed, or zero if no time elapsed.
+func utilization(busy, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(busy) / float64(elapsed)
+}
+
+// timerDurationUnit returns the unit in which to report the durations of t:
+// the timer's own unit if it has one, otherwise the given reporter unit, and
+// nanoseconds if neither is set.
+func timerDurationUnit(t Timer, unit time.Duration) time.Duration {
+	if dut, ok := t.(DurationUnitTimer); ok {
+		if u := dut.DurationUnit(); u > 0 {
+			return u
+		}
+	}
+	if unit > 0 {
+		return unit
+	}
+	return time.Nanosecond
+}
+
+// timerScale converts the durations of a timer to the unit a writer reports
+// them in.  Unless the writer was given a unit or the timer has its own, it
+// leaves them as the raw nanoseconds the writers which predate units print,
+// the minimum and maximum as integers and without a suffix.
+type timerScale struct {
+	unit   time.Duration
+	scaled bool
+}
+
+// newTimerScale returns the timerScale for t reported with the given unit,
+// zero for none.
+func newTimerScale(t Timer, unit time.Duration) timerScale {
+	dut, ok := t.(DurationUnitTimer)
+	return timerScale{
+		unit:   timerDurationUnit(t, unit),
+		scaled: unit > 0 || ok && dut.DurationUnit() > 0,
+	}
+}
+
+// scale returns the given duration in nanoseconds in the unit.
+func (s timerScale) scale(d float64) float64 {
+	return d / float64(s.unit)
+}
+
+// extreme returns the given minimum or maximum in the unit, or as an int64
+// if unscaled.
+func (s timerScale) extreme(d int64) interface{} {
+	if !s.scaled {
+		return d
+	}
+	return s.scale(float64(d))
+}
+
+// format formats the given minimum or maximum with intVerb if unscaled and
+// otherwise with floatVerb in the unit.
+func (s timerScale) format(d int64, intVerb, floatVerb string) string {
+	if !s.scaled {
+		return fmt.Sprintf(intVerb, d)
+	}
+	return fmt.Sprintf(floatVerb, s.scale(float64(d)))
+}
+
+// suffix returns the unit's suffix, eg "ms", or "" if unscaled.
+func (s timerScale) suffix() string {
+	if !s.scaled {
+		return ""
+	}
+	return durationUnitSuffix(s.unit)
+}
+
+// DroppedTimer is implemented by Timers which drop events with a negative
+// duration, as a clock stepping backwards mid-event may produce, and count
+// them in a separate Counter which can be registered alongside the timer.
+type DroppedTimer interface {
+	Timer
+	Dropped() Counter
+}
+
+// LapTimer is implemented by Timers whose Stopwatches record laps into
+// sibling Timers, one per lap name, which can be registered alongside the
+// timer, eg under its name, a dot and the lap's name.
+type LapTimer interface {
+	Timer
+	Lap(name string) Timer
+}
+
+// durationUnitSuffix returns the suffix used when printing durations in the
+// given unit, eg "ms" for time.Millisecond.
+func durationUnitSuffix(unit time.Duration) string {
+	return unit.String()[1:]
+}
+
 // NilTimer is a no-op Timer.
 type NilTimer struct {
 	h Histogram
@@ -121,6 +315,9 @@ func (NilTimer) Snapshot() Timer { return NilTimer{} }
 // StdDev is a no-op.
 func (NilTimer) StdDev() float64 { return 0.0 }
 
+// Stopwatch returns a Stopwatch which records nothing.
+func (t NilTimer) Stopwatch() *Stopwatch { return NewStopwatch(t, DefaultClock) }
+
 // Sum is a no-op.
 func (NilTimer) Sum() int64 { return 0 }
 
@@ -139,20 +336,26 @@ func (NilTimer) Variance() float64 { return 0.0 }
 // StandardTimer is the standard implementation of a Timer and uses a Histogram
 // and Meter.
 type StandardTimer struct {
-	histogram Histogram
-	meter     Meter
-	mutex     sync.Mutex
+	histogram    Histogram
+	meter        Meter
+	mutex        sync.Mutex
+	durationUnit time.Duration
+	clock        Clock
+	dropped      StandardCounter
+	outliers     outlierCount
+	laps         map[string]Timer
+	total        time.Duration // Of every duration recorded since construction or the last Clear
+	since        time.Time     // Start of the utilization interval: construction or the last read of it
+	sinceTotal   time.Duration // What total stood at, at since
 }
 
 func (t *StandardTimer) Clear() Timer {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
-	s := &TimerSnapshot{
-		histogram: t.histogram.Snapshot().(*HistogramSnapshot),
-		meter:     t.meter.Snapshot().(*MeterSnapshot),
-	}
+	s := t.snapshot()
 	t.histogram.Clear()
 	t.meter.Clear()
+	t.total, t.sinceTotal = 0, 0
 	return s
 }
 
@@ -161,6 +364,57 @@ func (t *StandardTimer) Count() int64 {
 	return t.histogram.Count()
 }
 
+// CountOutliers starts counting the durations recorded over threshold, eg
+// latencies over a second which the histogram's reservoir might not keep,
+// in a new Counter which it returns for registering alongside the timer.
+// Calling it again replaces the Counter.  Clear leaves it be.
+func (t *StandardTimer) CountOutliers(threshold time.Duration) Counter {
+	return t.outliers.count(float64(threshold))
+}
+
+// Dropped returns the Counter of events dropped because their duration was
+// negative.
+func (t *StandardTimer) Dropped() Counter {
+	return &t.dropped
+}
+
+// DurationUnit returns the unit the timer's durations should be reported in,
+// or zero to leave it to the reporter.
+func (t *StandardTimer) DurationUnit() time.Duration {
+	return t.durationUnit
+}
+
+// KeepRecent starts keeping the last size durations recorded, raw, for
+// Recent, or stops if size is zero or less, if the timer's histogram is a
+// RecentRecorder, as those of the timers this package constructs are.
+func (t *StandardTimer) KeepRecent(size int) {
+	if r, ok := t.histogram.(RecentRecorder); ok {
+		r.KeepRecent(size)
+	}
+}
+
+// Lap returns the sibling Timer for the named lap, constructing it on first
+// use with the timer's clock and duration unit.
+func (t *StandardTimer) Lap(name string) Timer {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	lap, ok := t.laps[name]
+	if !ok {
+		lap = &StandardTimer{
+			histogram:    NewHistogram(NewUniformSample(histogram_pool_size)),
+			meter:        NewMeterWithClock(t.clock),
+			durationUnit: t.durationUnit,
+			clock:        t.clock,
+			since:        t.clock.Now(),
+		}
+		if nil == t.laps {
+			t.laps = make(map[string]Timer)
+		}
+		t.laps[name] = lap
+	}
+	return lap
+}
+
 // Max returns the maximum value in the sample.
 func (t *StandardTimer) Max() int64 {
 	return t.histogram.Max()
@@ -207,13 +461,29 @@ func (t *StandardTimer) RateMean() float64 {
 	return t.meter.RateMean()
 }
 
+// Recent returns up to the last n durations recorded, in nanoseconds and
+// oldest first, if KeepRecent has been called.
+func (t *StandardTimer) Recent(n int) []int64 {
+	if r, ok := t.histogram.(RecentRecorder); ok {
+		return r.Recent(n)
+	}
+	return nil
+}
+
 // Snapshot returns a read-only copy of the timer.
 func (t *StandardTimer) Snapshot() Timer {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
+	return t.snapshot()
+}
+
+func (t *StandardTimer) snapshot() *TimerSnapshot {
 	return &TimerSnapshot{
-		histogram: t.histogram.Snapshot().(*HistogramSnapshot),
-		meter:     t.meter.Snapshot().(*MeterSnapshot),
+		histogram:    t.histogram.Snapshot().(*HistogramSnapshot),
+		meter:        t.meter.Snapshot().(*MeterSnapshot),
+		durationUnit: t.durationUnit,
+		total:        t.total,
+		utilization:  t.utilization(),
 	}
 }
 
@@ -222,6 +492,12 @@ func (t *StandardTimer) StdDev() float64 {
 	return t.histogram.StdDev()
 }
 
+// Stopwatch returns a new Stopwatch, started now, which records into the
+// timer and its laps.
+func (t *StandardTimer) Stopwatch() *Stopwatch {
+	return NewStopwatch(t, t.clock)
+}
+
 // Sum returns the sum in the sample.
 func (t *StandardTimer) Sum() int64 {
 	return t.histogram.Sum()
@@ -229,25 +505,62 @@ func (t *StandardTimer) Sum() int64 {
 
 // Record the duration of the execution of the given function.
 func (t *StandardTimer) Time(f func()) {
-	ts := time.Now()
+	ts := t.clock.Now()
 	f()
-	t.Update(time.Since(ts))
+	t.Update(t.clock.Now().Sub(ts))
+}
+
+// TotalDuration returns the sum of every duration recorded since the timer
+// was constructed or last cleared, unlike Sum which is only of the sample.
+func (t *StandardTimer) TotalDuration() time.Duration {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.total
+}
+
+// Utilization returns the duration recorded since utilization was last read,
+// by Utilization or by taking a Snapshot, divided by the time since then, or
+// since the timer was constructed, eg 0.25 for something busy a quarter of
+// the time, or 4 for four things busy all the time at once.  A reporter
+// which takes a snapshot each flush thus gets the utilization of each
+// interval.
+func (t *StandardTimer) Utilization() float64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.utilization()
 }
 
-// Record the duration of an event.
+// utilization returns the utilization since the start of the interval and
+// starts the next.  The caller must hold mutex.
+func (t *StandardTimer) utilization() float64 {
+	now := t.clock.Now()
+	var u float64
+	if !t.since.IsZero() { // else not constructed by NewTimer et al
+		u = utilization(t.total-t.sinceTotal, now.Sub(t.since))
+	}
+	t.since, t.sinceTotal = now, t.total
+	return u
+}
+
+// Record the duration of an event.  Negative durations are dropped and
+// counted by Dropped instead.
 func (t *StandardTimer) Update(d time.Duration) {
+	if d < 0 {
+		t.dropped.Inc(1)
+		countDroppedUpdate()
+		return
+	}
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
 	t.histogram.Update(int64(d))
 	t.meter.Mark(1)
+	t.total += d
+	t.outliers.update(float64(d))
 }
 
 // Record the duration of an event that started at a time and ends now.
 func (t *StandardTimer) UpdateSince(ts time.Time) {
-	t.mutex.Lock()
-	defer t.mutex.Unlock()
-	t.histogram.Update(int64(time.Since(ts)))
-	t.meter.Mark(1)
+	t.Update(t.clock.Now().Sub(ts))
 }
 
 // Variance returns the variance of the values in the sample.
@@ -257,19 +570,27 @@ func (t *StandardTimer) Variance() float64 {
 
 // TimerSnapshot is a read-only copy of another Timer.
 type TimerSnapshot struct {
-	histogram *HistogramSnapshot
-	meter     *MeterSnapshot
+	histogram    *HistogramSnapshot
+	meter        *MeterSnapshot
+	durationUnit time.Duration
+	total        time.Duration
+	utilization  float64
 }
 
-// Clear panics.
-func (*TimerSnapshot) Clear() Timer {
-	panic("Clear called on a TimerSnapshot")
+// Clear panics unless PanicOnSnapshotMutation is false.
+func (t *TimerSnapshot) Clear() Timer {
+	snapshotMisuse("Clear called on a TimerSnapshot")
+	return t
 }
 
 // Count returns the number of events recorded at the time the snapshot was
 // taken.
 func (t *TimerSnapshot) Count() int64 { return t.histogram.Count() }
 
+// DurationUnit returns the unit the timer's durations should be reported in,
+// or zero to leave it to the reporter.
+func (t *TimerSnapshot) DurationUnit() time.Duration { return t.durationUnit }
+
 // Max returns the maximum value at the time the snapshot was taken.
 func (t *TimerSnapshot) Max() int64 { return t.histogram.Max() }
 
@@ -317,21 +638,211 @@ func (t *TimerSnapshot) StdDev() float64 { return t.histogram.StdDev() }
 // Sum returns the sum at the time the snapshot was taken.
 func (t *TimerSnapshot) Sum() int64 { return t.histogram.Sum() }
 
-// Time panics.
+// TotalDuration returns the sum of every duration recorded at the time the
+// snapshot was taken.
+func (t *TimerSnapshot) TotalDuration() time.Duration { return t.total }
+
+// Utilization returns the utilization of the timer over the interval which
+// ended when the snapshot was taken.
+func (t *TimerSnapshot) Utilization() float64 { return t.utilization }
+
+// Stopwatch panics unless PanicOnSnapshotMutation is false.
+func (*TimerSnapshot) Stopwatch() *Stopwatch {
+	snapshotMisuse("Stopwatch called on a TimerSnapshot")
+	return NilTimer{}.Stopwatch()
+}
+
+// Time panics unless PanicOnSnapshotMutation is false.
 func (*TimerSnapshot) Time(func()) {
-	panic("Time called on a TimerSnapshot")
+	snapshotMisuse("Time called on a TimerSnapshot")
 }
 
-// Update panics.
+// Update panics unless PanicOnSnapshotMutation is false.
 func (*TimerSnapshot) Update(time.Duration) {
-	panic("Update called on a TimerSnapshot")
+	snapshotMisuse("Update called on a TimerSnapshot")
 }
 
-// UpdateSince panics.
+// UpdateSince panics unless PanicOnSnapshotMutation is false.
 func (*TimerSnapshot) UpdateSince(time.Time) {
-	panic("UpdateSince called on a TimerSnapshot")
+	snapshotMisuse("UpdateSince called on a TimerSnapshot")
 }
 
 // Variance returns the variance of the values at the time the snapshot was
 // taken.
 func (t *TimerSnapshot) Variance() float64 { return t.histogram.Variance() }
+
+// TimerStats holds timing statistics computed outside this package, as
+// returned by the callback of a FunctionalTimer.  Durations are in
+// nanoseconds, like those recorded by a StandardTimer.
+type TimerStats struct {
+	HistogramStats
+	Rate1    float64
+	Rate5    float64
+	Rate15   float64
+	RateMean float64
+}
+
+// FunctionalTimer returns statistics from the given function, which is called
+// every time a statistic or a snapshot is requested.  It's read-only: since
+// the statistics are kept elsewhere, Clear and the methods which record are
+// no-ops, except that Time still calls its function.
+type FunctionalTimer struct {
+	stats func() TimerStats
+}
+
+// Clear returns a snapshot without clearing anything, since the statistics
+// are kept elsewhere.
+func (t *FunctionalTimer) Clear() Timer {
+	return t.Snapshot()
+}
+
+// Count returns the number of events recorded.
+func (t *FunctionalTimer) Count() int64 { return t.stats().Count }
+
+// Max returns the maximum duration.
+func (t *FunctionalTimer) Max() int64 { return t.stats().Max }
+
+// Mean returns the mean duration.
+func (t *FunctionalTimer) Mean() float64 { return t.stats().Mean }
+
+// Min returns the minimum duration.
+func (t *FunctionalTimer) Min() int64 { return t.stats().Min }
+
+// Percentile returns an arbitrary percentile of the durations.
+func (t *FunctionalTimer) Percentile(p float64) float64 {
+	return t.Snapshot().Percentile(p)
+}
+
+// Percentiles returns a slice of arbitrary percentiles of the durations.
+func (t *FunctionalTimer) Percentiles(ps []float64) []float64 {
+	return t.Snapshot().Percentiles(ps)
+}
+
+// Rate1 returns the one-minute moving average rate of events per second.
+func (t *FunctionalTimer) Rate1() float64 { return t.stats().Rate1 }
+
+// Rate5 returns the five-minute moving average rate of events per second.
+func (t *FunctionalTimer) Rate5() float64 { return t.stats().Rate5 }
+
+// Rate15 returns the fifteen-minute moving average rate of events per second.
+func (t *FunctionalTimer) Rate15() float64 { return t.stats().Rate15 }
+
+// RateMean returns the mean rate of events per second.
+func (t *FunctionalTimer) RateMean() float64 { return t.stats().RateMean }
+
+// Snapshot calls the function once and returns a read-only copy of its
+// result.
+func (t *FunctionalTimer) Snapshot() Timer {
+	return &FunctionalTimerSnapshot{stats: t.stats()}
+}
+
+// StdDev returns the standard deviation of the durations.
+func (t *FunctionalTimer) StdDev() float64 { return t.stats().StdDev }
+
+// Sum returns the sum of the durations.
+func (t *FunctionalTimer) Sum() int64 { return t.stats().Sum }
+
+// Stopwatch returns a Stopwatch which records nothing.
+func (*FunctionalTimer) Stopwatch() *Stopwatch {
+	return NilTimer{}.Stopwatch()
+}
+
+// Time calls the given function without recording its duration.
+func (*FunctionalTimer) Time(f func()) { f() }
+
+// Update is a no-op.
+func (*FunctionalTimer) Update(time.Duration) {}
+
+// UpdateSince is a no-op.
+func (*FunctionalTimer) UpdateSince(time.Time) {}
+
+// Variance returns the variance of the durations.
+func (t *FunctionalTimer) Variance() float64 { return t.stats().Variance }
+
+// FunctionalTimerSnapshot is a read-only copy of the statistics returned by a
+// FunctionalTimer.
+type FunctionalTimerSnapshot struct {
+	stats TimerStats
+}
+
+// Clear panics unless PanicOnSnapshotMutation is false.
+func (t *FunctionalTimerSnapshot) Clear() Timer {
+	snapshotMisuse("Clear called on a FunctionalTimerSnapshot")
+	return t
+}
+
+// Count returns the number of events recorded at the time the snapshot was
+// taken.
+func (t *FunctionalTimerSnapshot) Count() int64 { return t.stats.Count }
+
+// Max returns the maximum duration at the time the snapshot was taken.
+func (t *FunctionalTimerSnapshot) Max() int64 { return t.stats.Max }
+
+// Mean returns the mean duration at the time the snapshot was taken.
+func (t *FunctionalTimerSnapshot) Mean() float64 { return t.stats.Mean }
+
+// Min returns the minimum duration at the time the snapshot was taken.
+func (t *FunctionalTimerSnapshot) Min() int64 { return t.stats.Min }
+
+// Percentile returns an arbitrary percentile of durations at the time the
+// snapshot was taken.
+func (t *FunctionalTimerSnapshot) Percentile(p float64) float64 {
+	return (&FunctionalHistogramSnapshot{stats: t.stats.HistogramStats}).Percentile(p)
+}
+
+// Percentiles returns a slice of arbitrary percentiles of durations at the
+// time the snapshot was taken.
+func (t *FunctionalTimerSnapshot) Percentiles(ps []float64) []float64 {
+	return (&FunctionalHistogramSnapshot{stats: t.stats.HistogramStats}).Percentiles(ps)
+}
+
+// Rate1 returns the one-minute moving average rate of events per second at the
+// time the snapshot was taken.
+func (t *FunctionalTimerSnapshot) Rate1() float64 { return t.stats.Rate1 }
+
+// Rate5 returns the five-minute moving average rate of events per second at
+// the time the snapshot was taken.
+func (t *FunctionalTimerSnapshot) Rate5() float64 { return t.stats.Rate5 }
+
+// Rate15 returns the fifteen-minute moving average rate of events per second
+// at the time the snapshot was taken.
+func (t *FunctionalTimerSnapshot) Rate15() float64 { return t.stats.Rate15 }
+
+// RateMean returns the mean rate of events per second at the time the
+// snapshot was taken.
+func (t *FunctionalTimerSnapshot) RateMean() float64 { return t.stats.RateMean }
+
+// Snapshot returns the snapshot.
+func (t *FunctionalTimerSnapshot) Snapshot() Timer { return t }
+
+// StdDev returns the standard deviation of durations at the time the snapshot
+// was taken.
+func (t *FunctionalTimerSnapshot) StdDev() float64 { return t.stats.StdDev }
+
+// Sum returns the sum of durations at the time the snapshot was taken.
+func (t *FunctionalTimerSnapshot) Sum() int64 { return t.stats.Sum }
+
+// Stopwatch panics unless PanicOnSnapshotMutation is false.
+func (*FunctionalTimerSnapshot) Stopwatch() *Stopwatch {
+	snapshotMisuse("Stopwatch called on a FunctionalTimerSnapshot")
+	return NilTimer{}.Stopwatch()
+}
+
+// Time panics unless PanicOnSnapshotMutation is false.
+func (*FunctionalTimerSnapshot) Time(func()) {
+	snapshotMisuse("Time called on a FunctionalTimerSnapshot")
+}
+
+// Update panics unless PanicOnSnapshotMutation is false.
+func (*FunctionalTimerSnapshot) Update(time.Duration) {
+	snapshotMisuse("Update called on a FunctionalTimerSnapshot")
+}
+
+// UpdateSince panics unless PanicOnSnapshotMutation is false.
+func (*FunctionalTimerSnapshot) UpdateSince(time.Time) {
+	snapshotMisuse("UpdateSince called on a FunctionalTimerSnapshot")
+}
+
+// Variance returns the variance of durations at the time the snapshot was
+// taken.
+func (t *FunctionalTimerSnapshot) Variance() float64 { return t.stats.Variance }
@@ -15,6 +15,66 @@ func BenchmarkTimer(b *testing.B) {
 	}
 }
 
+func TestFunctionalTimer(t *testing.T) {
+	var calls int
+	tm := NewFunctionalTimer(func() TimerStats {
+		calls++
+		return TimerStats{
+			HistogramStats: HistogramStats{
+				Count:      3,
+				Min:        int64(time.Millisecond),
+				Max:        int64(3 * time.Millisecond),
+				Percentile: func(p float64) float64 { return p * float64(time.Millisecond) },
+			},
+			Rate1: 0.5,
+		}
+	})
+	snapshot := tm.Snapshot()
+	if 1 != calls {
+		t.Errorf("calls: 1 != %v\n", calls)
+	}
+	if count := snapshot.Count(); 3 != count {
+		t.Errorf("snapshot.Count(): 3 != %v\n", count)
+	}
+	if max := snapshot.Max(); int64(3*time.Millisecond) != max {
+		t.Errorf("snapshot.Max(): 3ms != %v\n", max)
+	}
+	if p := snapshot.Percentile(0.5); 5e5 != p {
+		t.Errorf("snapshot.Percentile(0.5): 5e5 != %v\n", p)
+	}
+	if rate1 := snapshot.Rate1(); 0.5 != rate1 {
+		t.Errorf("snapshot.Rate1(): 0.5 != %v\n", rate1)
+	}
+	if 1 != calls {
+		t.Errorf("calls: 1 != %v\n", calls)
+	}
+}
+
+func TestFunctionalTimerReadOnly(t *testing.T) {
+	tm := NewFunctionalTimer(func() TimerStats { return TimerStats{HistogramStats: HistogramStats{Count: 3}} })
+	tm.Update(time.Second)
+	tm.UpdateSince(time.Now())
+	tm.Stopwatch().Stop()
+	var called bool
+	tm.Time(func() { called = true })
+	if !called {
+		t.Error("tm.Time didn't call its function")
+	}
+	if count := tm.Clear().Count(); 3 != count {
+		t.Errorf("tm.Clear().Count(): 3 != %v\n", count)
+	}
+}
+
+func TestGetOrRegisterFunctionalTimer(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredFunctionalTimer("foo", r, func() TimerStats {
+		return TimerStats{HistogramStats: HistogramStats{Count: 47}}
+	})
+	if tm := GetOrRegisterTimer("foo", r); 47 != tm.Count() {
+		t.Fatal(tm)
+	}
+}
+
 func TestGetOrRegisterTimer(t *testing.T) {
 	r := NewRegistry()
 	NewRegisteredTimer("foo", r).Update(47)
@@ -23,6 +83,19 @@ func TestGetOrRegisterTimer(t *testing.T) {
 	}
 }
 
+func TestTimerDurationUnit(t *testing.T) {
+	tm := NewTimerWithDurationUnit(time.Millisecond)
+	if unit := timerDurationUnit(tm.Snapshot(), time.Second); time.Millisecond != unit {
+		t.Errorf("timerDurationUnit(): 1ms != %v\n", unit)
+	}
+	if unit := timerDurationUnit(NewTimer(), time.Second); time.Second != unit {
+		t.Errorf("timerDurationUnit(): 1s != %v\n", unit)
+	}
+	if unit := timerDurationUnit(NewTimer(), 0); time.Nanosecond != unit {
+		t.Errorf("timerDurationUnit(): 1ns != %v\n", unit)
+	}
+}
+
 func TestTimerExtremes(t *testing.T) {
 	tm := NewTimer()
 	tm.Update(math.MaxInt64)
@@ -32,6 +105,116 @@ func TestTimerExtremes(t *testing.T) {
 	}
 }
 
+func TestTimerDropped(t *testing.T) {
+	tm := NewTimer()
+	tm.Update(-time.Second)
+	tm.UpdateSince(time.Now().Add(time.Hour))
+	tm.Update(time.Second)
+	if count := tm.Count(); 1 != count {
+		t.Errorf("tm.Count(): 1 != %v\n", count)
+	}
+	if dropped := tm.(DroppedTimer).Dropped().Count(); 2 != dropped {
+		t.Errorf("tm.Dropped().Count(): 2 != %v\n", dropped)
+	}
+}
+
+func TestTimerClockFunc(t *testing.T) {
+	now := time.Unix(1000, 0)
+	tm := NewTimerWithClock(ClockFunc(func() time.Time { return now }))
+	tm.Time(func() { now = now.Add(50 * time.Millisecond) })
+	tm.Time(func() { now = now.Add(-time.Second) })
+	if max := tm.Max(); int64(50*time.Millisecond) != max {
+		t.Errorf("tm.Max(): %v != %v\n", 50*time.Millisecond, time.Duration(max))
+	}
+	if dropped := tm.(DroppedTimer).Dropped().Count(); 1 != dropped {
+		t.Errorf("tm.Dropped().Count(): 1 != %v\n", dropped)
+	}
+}
+
+func TestTimerUtilization(t *testing.T) {
+	now := time.Unix(1000, 0)
+	tm := NewTimerWithClock(ClockFunc(func() time.Time { return now })).(UtilizationTimer)
+	tm.Update(time.Second)
+	tm.Update(3 * time.Second)
+	now = now.Add(8 * time.Second)
+	if total := tm.TotalDuration(); 4*time.Second != total {
+		t.Errorf("tm.TotalDuration(): 4s != %v\n", total)
+	}
+	if u := tm.Utilization(); 0.5 != u {
+		t.Errorf("tm.Utilization(): 0.5 != %v\n", u)
+	}
+
+	// Each read starts a new interval.
+	tm.Update(time.Second)
+	now = now.Add(4 * time.Second)
+	snapshot := tm.Snapshot().(UtilizationTimer)
+	if u := snapshot.Utilization(); 0.25 != u {
+		t.Errorf("snapshot.Utilization(): 0.25 != %v\n", u)
+	}
+	if total := snapshot.TotalDuration(); 5*time.Second != total {
+		t.Errorf("snapshot.TotalDuration(): 5s != %v\n", total)
+	}
+	now = now.Add(time.Second)
+	if u := tm.Utilization(); 0 != u {
+		t.Errorf("tm.Utilization() of an idle interval: 0 != %v\n", u)
+	}
+
+	tm.Update(2 * time.Second)
+	now = now.Add(2 * time.Second)
+	snapshot = tm.Clear().(UtilizationTimer)
+	if u := snapshot.Utilization(); 1 != u {
+		t.Errorf("tm.Clear().Utilization(): 1 != %v\n", u)
+	}
+	if total := tm.TotalDuration(); 0 != total {
+		t.Errorf("tm.TotalDuration() after Clear: 0 != %v\n", total)
+	}
+	tm.Update(time.Second)
+	now = now.Add(2 * time.Second)
+	if u := tm.Utilization(); 0.5 != u {
+		t.Errorf("tm.Utilization() after Clear: 0.5 != %v\n", u)
+	}
+}
+
+func TestTimerStopwatch(t *testing.T) {
+	now := time.Unix(1000, 0)
+	tm := NewTimerWithClock(ClockFunc(func() time.Time { return now }))
+	sw := tm.Stopwatch()
+	now = now.Add(10 * time.Millisecond)
+	if d := sw.Lap("parse"); 10*time.Millisecond != d {
+		t.Errorf("sw.Lap(\"parse\"): 10ms != %v\n", d)
+	}
+	now = now.Add(30 * time.Millisecond)
+	sw.Lap("query")
+	if d := sw.Stop(); 40*time.Millisecond != d {
+		t.Errorf("sw.Stop(): 40ms != %v\n", d)
+	}
+	if max := tm.Max(); int64(40*time.Millisecond) != max {
+		t.Errorf("tm.Max(): %v != %v\n", 40*time.Millisecond, time.Duration(max))
+	}
+	lt := tm.(LapTimer)
+	if max := lt.Lap("parse").Max(); int64(10*time.Millisecond) != max {
+		t.Errorf("lt.Lap(\"parse\").Max(): %v != %v\n", 10*time.Millisecond, time.Duration(max))
+	}
+	if max := lt.Lap("query").Max(); int64(30*time.Millisecond) != max {
+		t.Errorf("lt.Lap(\"query\").Max(): %v != %v\n", 30*time.Millisecond, time.Duration(max))
+	}
+	if lt.Lap("parse") != lt.Lap("parse") {
+		t.Error("lt.Lap(\"parse\") constructed twice")
+	}
+}
+
+func TestMonotonicClock(t *testing.T) {
+	c := NewMonotonicClock()
+	prev := c.Now().Round(0)
+	for i := 0; i < 1000; i++ {
+		now := c.Now().Round(0)
+		if now.Before(prev) {
+			t.Fatalf("%v before %v\n", now, prev)
+		}
+		prev = now
+	}
+}
+
 func TestTimerFunc(t *testing.T) {
 	tm := NewTimer()
 	tm.Time(func() { time.Sleep(50e6) })
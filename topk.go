@@ -0,0 +1,260 @@
+package metrics
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+)
+
+// TopKs track the most frequently observed string keys, eg flag keys or
+// endpoints, and their approximate counts in bounded memory, using a
+// count-min sketch to estimate the count of every key and a heap of the
+// K with the highest estimates.
+type TopK interface {
+	Add(string, int64)
+	Clear() TopK // atomically clears and returns a snapshot
+	Observe(string)
+	Snapshot() TopK
+	Top() []TopKEntry
+}
+
+// TopKEntry is a key and its approximate count, which may overestimate but
+// never underestimate the true count.
+type TopKEntry struct {
+	Key   string
+	Count int64
+}
+
+// GetOrRegisterTopK returns an existing TopK or constructs and registers a
+// new StandardTopK tracking the given number of keys.
+func GetOrRegisterTopK(name string, r Registry, k int) TopK {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	t, ok := r.GetOrRegister(name, func() TopK { return NewTopK(k) }).(TopK)
+	if !ok {
+		return registrationConflict(DuplicateMetric(name), NewTopK(k)).(TopK)
+	}
+	return t
+}
+
+// GetTopK returns the TopK registered under the given name, or false if
+// there is none or the metric registered there is not a TopK.
+func GetTopK(name string, r Registry) (TopK, bool) {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	t, ok := r.Get(name).(TopK)
+	return t, ok
+}
+
+// NewTopK constructs a new StandardTopK tracking the given number of keys
+// with a count-min sketch 1024 counters wide and 4 deep.
+func NewTopK(k int) TopK {
+	return NewTopKWithSketch(k, 1024, 4)
+}
+
+// NewTopKWithSketch constructs a new StandardTopK tracking the given number
+// of keys with a count-min sketch of the given width and depth.  Wider
+// sketches overestimate less and deeper ones are less likely to overestimate
+// at all.
+func NewTopKWithSketch(k, width, depth int) TopK {
+	if UseNilMetrics {
+		return NilTopK{}
+	}
+	return &StandardTopK{
+		k:      k,
+		counts: make([]int64, width*depth),
+		depth:  depth,
+		heap:   topKHeap{index: make(map[string]int, k)},
+		width:  width,
+	}
+}
+
+// NewRegisteredTopK constructs and registers a new StandardTopK.
+func NewRegisteredTopK(name string, r Registry, k int) TopK {
+	c := NewTopK(k)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// TopKSnapshot is a read-only copy of another TopK.
+type TopKSnapshot []TopKEntry
+
+// Add panics unless PanicOnSnapshotMutation is false.
+func (TopKSnapshot) Add(string, int64) {
+	snapshotMisuse("Add called on a TopKSnapshot")
+}
+
+// Clear panics unless PanicOnSnapshotMutation is false.
+func (t TopKSnapshot) Clear() TopK {
+	snapshotMisuse("Clear called on a TopKSnapshot")
+	return t
+}
+
+// Observe panics unless PanicOnSnapshotMutation is false.
+func (TopKSnapshot) Observe(string) {
+	snapshotMisuse("Observe called on a TopKSnapshot")
+}
+
+// Snapshot returns the snapshot.
+func (t TopKSnapshot) Snapshot() TopK { return t }
+
+// Top returns a copy of the keys and counts at the time the snapshot was
+// taken, in descending order of count.
+func (t TopKSnapshot) Top() []TopKEntry {
+	top := make([]TopKEntry, len(t))
+	copy(top, t)
+	return top
+}
+
+// NilTopK is a no-op TopK.
+type NilTopK struct{}
+
+// Add is a no-op.
+func (NilTopK) Add(string, int64) {}
+
+// Clear is a no-op.
+func (NilTopK) Clear() TopK { return NilTopK{} }
+
+// Observe is a no-op.
+func (NilTopK) Observe(string) {}
+
+// Snapshot is a no-op.
+func (NilTopK) Snapshot() TopK { return NilTopK{} }
+
+// Top is a no-op.
+func (NilTopK) Top() []TopKEntry { return []TopKEntry{} }
+
+// StandardTopK is the standard implementation of a TopK.
+type StandardTopK struct {
+	counts []int64 // depth rows of width counters
+	depth  int
+	heap   topKHeap
+	k      int
+	mutex  sync.Mutex
+	width  int
+}
+
+// Add counts n observations of the given key.
+func (t *StandardTopK) Add(key string, n int64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	count := t.add(key, n)
+	if i, ok := t.heap.index[key]; ok {
+		t.heap.entries[i].Count = count
+		heap.Fix(&t.heap, i)
+	} else if len(t.heap.entries) < t.k {
+		heap.Push(&t.heap, TopKEntry{key, count})
+	} else if 0 < t.k && t.heap.entries[0].Count < count {
+		delete(t.heap.index, t.heap.entries[0].Key)
+		t.heap.index[key] = 0
+		t.heap.entries[0] = TopKEntry{key, count}
+		heap.Fix(&t.heap, 0)
+	}
+}
+
+// Clear forgets all keys and counts and returns a snapshot of the top keys
+// from before.
+func (t *StandardTopK) Clear() TopK {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	snapshot := TopKSnapshot(t.top())
+	for i := range t.counts {
+		t.counts[i] = 0
+	}
+	t.heap.entries = t.heap.entries[:0]
+	t.heap.index = make(map[string]int, t.k)
+	return snapshot
+}
+
+// Observe counts one observation of the given key.
+func (t *StandardTopK) Observe(key string) {
+	t.Add(key, 1)
+}
+
+// Snapshot returns a read-only copy of the top keys.
+func (t *StandardTopK) Snapshot() TopK {
+	return TopKSnapshot(t.Top())
+}
+
+// Top returns the top keys and their approximate counts in descending order
+// of count, then ascending order of key.
+func (t *StandardTopK) Top() []TopKEntry {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.top()
+}
+
+// top is Top for callers holding the lock.
+func (t *StandardTopK) top() []TopKEntry {
+	top := make([]TopKEntry, len(t.heap.entries))
+	copy(top, t.heap.entries)
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Key < top[j].Key
+	})
+	return top
+}
+
+// add adds n to the key's counter in each row of the sketch and returns its
+// estimated count, the least of them.  The rows' hash functions are derived
+// from two halves of one FNV-1a hash, as by Kirsch and Mitzenmacher.
+func (t *StandardTopK) add(key string, n int64) int64 {
+	h := fnv64a(key)
+	h1, h2 := h&0xffffffff, h>>32|1
+	var est int64
+	for row := 0; row < t.depth; row++ {
+		c := &t.counts[row*t.width+int((h1+uint64(row)*h2)%uint64(t.width))]
+		*c += n
+		if 0 == row || *c < est {
+			est = *c
+		}
+	}
+	return est
+}
+
+// fnv64a returns the 64-bit FNV-1a hash of s without the allocation of
+// hash/fnv.
+func fnv64a(s string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= 1099511628211
+	}
+	return h
+}
+
+// topKHeap is a min-heap of TopKEntries by count, indexed by key.
+type topKHeap struct {
+	entries []TopKEntry
+	index   map[string]int
+}
+
+func (h *topKHeap) Len() int { return len(h.entries) }
+
+func (h *topKHeap) Less(i, j int) bool { return h.entries[i].Count < h.entries[j].Count }
+
+func (h *topKHeap) Swap(i, j int) {
+	h.entries[i], h.entries[j] = h.entries[j], h.entries[i]
+	h.index[h.entries[i].Key] = i
+	h.index[h.entries[j].Key] = j
+}
+
+func (h *topKHeap) Push(x interface{}) {
+	e := x.(TopKEntry)
+	h.index[e.Key] = len(h.entries)
+	h.entries = append(h.entries, e)
+}
+
+func (h *topKHeap) Pop() interface{} {
+	e := h.entries[len(h.entries)-1]
+	h.entries = h.entries[:len(h.entries)-1]
+	delete(h.index, e.Key)
+	return e
+}
@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func BenchmarkTopK(b *testing.B) {
+	t := NewTopK(10)
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		t.Observe(keys[i%len(keys)])
+	}
+}
+
+func TestTopK(t *testing.T) {
+	tk := NewTopK(3)
+	for i := 1; i <= 100; i++ {
+		for j := 0; j < i; j++ {
+			tk.Observe(fmt.Sprintf("key-%d", i))
+		}
+	}
+	want := []TopKEntry{{"key-100", 100}, {"key-99", 99}, {"key-98", 98}}
+	if top := tk.Top(); !reflect.DeepEqual(want, top) {
+		t.Errorf("tk.Top(): %v != %v\n", want, top)
+	}
+	tk.Add("key-1", 1000)
+	want = []TopKEntry{{"key-1", 1001}, {"key-100", 100}, {"key-99", 99}}
+	if top := tk.Top(); !reflect.DeepEqual(want, top) {
+		t.Errorf("tk.Top(): %v != %v\n", want, top)
+	}
+}
+
+func TestTopKClear(t *testing.T) {
+	tk := NewTopK(3)
+	tk.Observe("foo")
+	if top := tk.Clear().Top(); !reflect.DeepEqual([]TopKEntry{{"foo", 1}}, top) {
+		t.Errorf("tk.Clear().Top(): %v\n", top)
+	}
+	if top := tk.Top(); 0 != len(top) {
+		t.Errorf("tk.Top(): %v\n", top)
+	}
+	tk.Observe("bar")
+	if top := tk.Top(); !reflect.DeepEqual([]TopKEntry{{"bar", 1}}, top) {
+		t.Errorf("tk.Top(): %v\n", top)
+	}
+}
+
+func TestTopKSnapshot(t *testing.T) {
+	tk := NewTopK(3)
+	tk.Observe("foo")
+	snapshot := tk.Snapshot()
+	tk.Observe("foo")
+	if top := snapshot.Top(); !reflect.DeepEqual([]TopKEntry{{"foo", 1}}, top) {
+		t.Errorf("snapshot.Top(): %v\n", top)
+	}
+}
+
+func TestGetOrRegisterTopK(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredTopK("foo", r, 3).Observe("bar")
+	if top := GetOrRegisterTopK("foo", r, 3).Top(); 1 != len(top) {
+		t.Fatal(top)
+	}
+}
+
+func TestTopKReported(t *testing.T) {
+	r := NewRegistry()
+	tk := NewRegisteredTopK("foo", r, 3)
+	tk.Add("bar", 2)
+	tk.Observe("baz")
+	b := &bytes.Buffer{}
+	WriteJSONOnce(r, b)
+	if s := b.String(); "{\"foo\":{\"bar\":2,\"baz\":1}}\n" != s {
+		t.Errorf("unexpected output: %q", s)
+	}
+	l := &bufferLogger{}
+	newLogReporter(LogConfig{Registry: r, Logger: l, Format: LogFormatKeyValue}).flush()
+	if s := l.String(); "type=topk name=\"foo\" bar=2 baz=1\n" != s {
+		t.Errorf("unexpected output: %q", s)
+	}
+}
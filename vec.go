@@ -0,0 +1,294 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// metricVec is the family of metrics shared by the vector types.  Each
+// child is created the first time its label values are asked for and
+// registered under the family's name followed by its labels and their
+// values in Graphite's tagged-series syntax, as a MetricNamespace given
+// tags names them, eg requests;code=200;method=GET, so that exporters which
+// support tags can be given a view of the registry with MapSplitTags.
+type metricVec struct {
+	name      string
+	registry  Registry
+	labels    []string
+	newMetric func() interface{}
+	valid     func(interface{}) bool // Whether a metric is of the family's kind
+	mutex     sync.Mutex
+	children  map[string]*vecChild
+	onEvict   func([]string, interface{})
+}
+
+type vecChild struct {
+	name   string
+	values []string
+	metric interface{}
+}
+
+func newMetricVec(name string, r Registry, labels []string, newMetric func() interface{}, valid func(interface{}) bool) *metricVec {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	return &metricVec{
+		name:      name,
+		registry:  r,
+		labels:    append([]string(nil), labels...),
+		newMetric: newMetric,
+		valid:     valid,
+		children:  make(map[string]*vecChild),
+	}
+}
+
+// with returns the child of the given label values, creating and
+// registering it if there's none.  It panics if the number of values isn't
+// the number of labels, and if a metric of another kind is registered under
+// the child's name unless SetOnRegistrationConflict was given a function,
+// when it returns a new metric which isn't registered or kept.
+func (v *metricVec) with(values []string) interface{} {
+	key := v.key(values)
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if c, ok := v.children[key]; ok {
+		return c.metric
+	}
+	tags := make(map[string]string, len(v.labels))
+	for i, label := range v.labels {
+		tags[label] = values[i]
+	}
+	c := &vecChild{
+		name:   v.name + tagSuffix(tags),
+		values: append([]string(nil), values...),
+	}
+	c.metric = v.registry.GetOrRegister(c.name, v.newMetric)
+	if !v.valid(c.metric) {
+		return registrationConflict(DuplicateMetric(c.name), v.newMetric())
+	}
+	v.children[key] = c
+	return c.metric
+}
+
+// delete unregisters the child of the given label values, calling the
+// eviction hook, and returns whether there was one.
+func (v *metricVec) delete(values []string) bool {
+	key := v.key(values)
+	v.mutex.Lock()
+	c, ok := v.children[key]
+	if ok {
+		delete(v.children, key)
+		v.registry.Unregister(c.name)
+	}
+	onEvict := v.onEvict
+	v.mutex.Unlock()
+	if ok && nil != onEvict {
+		onEvict(c.values, c.metric)
+	}
+	return ok
+}
+
+// reset unregisters every child, calling the eviction hook for each.
+func (v *metricVec) reset() {
+	v.mutex.Lock()
+	children := v.children
+	v.children = make(map[string]*vecChild)
+	for _, c := range children {
+		v.registry.Unregister(c.name)
+	}
+	onEvict := v.onEvict
+	v.mutex.Unlock()
+	if nil != onEvict {
+		for _, c := range children {
+			onEvict(c.values, c.metric)
+		}
+	}
+}
+
+// each calls f for each child in order of label values.
+func (v *metricVec) each(f func([]string, interface{})) {
+	v.mutex.Lock()
+	keys := make([]string, 0, len(v.children))
+	for key := range v.children {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	children := make([]*vecChild, len(keys))
+	for i, key := range keys {
+		children[i] = v.children[key]
+	}
+	v.mutex.Unlock()
+	for _, c := range children {
+		f(c.values, c.metric)
+	}
+}
+
+func (v *metricVec) setOnEvict(f func([]string, interface{})) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.onEvict = f
+}
+
+func (v *metricVec) key(values []string) string {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %d label values for the %d labels of %s", len(values), len(v.labels), v.name))
+	}
+	return strings.Join(values, "\x00")
+}
+
+// CounterVec is a family of Counters, one for each combination of values of
+// its labels, created the first time WithLabelValues is called with them.
+type CounterVec struct {
+	vec *metricVec
+}
+
+// NewCounterVec constructs a new CounterVec of the given labels whose
+// Counters are registered in r, or the DefaultRegistry if r is nil, under
+// the given name followed by their label values, eg requests;code=200.
+func NewCounterVec(name string, r Registry, labels ...string) *CounterVec {
+	return &CounterVec{newMetricVec(name, r, labels, func() interface{} { return NewCounter() }, func(i interface{}) bool {
+		_, ok := i.(Counter)
+		return ok
+	})}
+}
+
+// WithLabelValues returns the Counter of the given label values, in the
+// order of the labels, creating and registering it if there's none.  It
+// panics if the number of values isn't the number of labels.
+func (v *CounterVec) WithLabelValues(values ...string) Counter {
+	return v.vec.with(values).(Counter)
+}
+
+// Delete unregisters the Counter of the given label values, calling the
+// eviction hook, and returns whether there was one.
+func (v *CounterVec) Delete(values ...string) bool {
+	return v.vec.delete(values)
+}
+
+// Reset unregisters every Counter, calling the eviction hook for each.
+func (v *CounterVec) Reset() {
+	v.vec.reset()
+}
+
+// Each calls f with a snapshot of each Counter and its label values, in
+// order of label values.
+func (v *CounterVec) Each(f func([]string, Counter)) {
+	v.vec.each(func(values []string, i interface{}) {
+		f(values, i.(Counter).Snapshot())
+	})
+}
+
+// OnEvict sets a function to be called with each Counter unregistered by
+// Delete or Reset and its label values, eg to flush its final count.
+func (v *CounterVec) OnEvict(f func([]string, Counter)) {
+	v.vec.setOnEvict(func(values []string, i interface{}) {
+		f(values, i.(Counter))
+	})
+}
+
+// TimerVec is a family of Timers, one for each combination of values of its
+// labels, created the first time WithLabelValues is called with them.
+type TimerVec struct {
+	vec *metricVec
+}
+
+// NewTimerVec constructs a new TimerVec of the given labels whose Timers are
+// registered in r, or the DefaultRegistry if r is nil, under the given name
+// followed by their label values, eg latency;method=GET.
+func NewTimerVec(name string, r Registry, labels ...string) *TimerVec {
+	return &TimerVec{newMetricVec(name, r, labels, func() interface{} { return NewTimer() }, func(i interface{}) bool {
+		_, ok := i.(Timer)
+		return ok
+	})}
+}
+
+// WithLabelValues returns the Timer of the given label values, in the order
+// of the labels, creating and registering it if there's none.  It panics if
+// the number of values isn't the number of labels.
+func (v *TimerVec) WithLabelValues(values ...string) Timer {
+	return v.vec.with(values).(Timer)
+}
+
+// Delete unregisters the Timer of the given label values, calling the
+// eviction hook, and returns whether there was one.
+func (v *TimerVec) Delete(values ...string) bool {
+	return v.vec.delete(values)
+}
+
+// Reset unregisters every Timer, calling the eviction hook for each.
+func (v *TimerVec) Reset() {
+	v.vec.reset()
+}
+
+// Each calls f with a snapshot of each Timer and its label values, in order
+// of label values.
+func (v *TimerVec) Each(f func([]string, Timer)) {
+	v.vec.each(func(values []string, i interface{}) {
+		f(values, i.(Timer).Snapshot())
+	})
+}
+
+// OnEvict sets a function to be called with each Timer unregistered by
+// Delete or Reset and its label values.
+func (v *TimerVec) OnEvict(f func([]string, Timer)) {
+	v.vec.setOnEvict(func(values []string, i interface{}) {
+		f(values, i.(Timer))
+	})
+}
+
+// HistogramFloat64Vec is a family of HistogramFloat64s, one for each
+// combination of values of its labels, created the first time
+// WithLabelValues is called with them.
+type HistogramFloat64Vec struct {
+	vec *metricVec
+}
+
+// NewHistogramFloat64Vec constructs a new HistogramFloat64Vec of the given
+// labels whose HistogramFloat64s, each of a SampleFloat64 made by newSample,
+// are registered in r, or the DefaultRegistry if r is nil, under the given
+// name followed by their label values, eg size;route=/users.
+func NewHistogramFloat64Vec(name string, r Registry, newSample func() SampleFloat64, labels ...string) *HistogramFloat64Vec {
+	return &HistogramFloat64Vec{newMetricVec(name, r, labels, func() interface{} {
+		return NewHistogramFloat64(newSample())
+	}, func(i interface{}) bool {
+		_, ok := i.(HistogramFloat64)
+		return ok
+	})}
+}
+
+// WithLabelValues returns the HistogramFloat64 of the given label values, in
+// the order of the labels, creating and registering it if there's none.  It
+// panics if the number of values isn't the number of labels.
+func (v *HistogramFloat64Vec) WithLabelValues(values ...string) HistogramFloat64 {
+	return v.vec.with(values).(HistogramFloat64)
+}
+
+// Delete unregisters the HistogramFloat64 of the given label values, calling
+// the eviction hook, and returns whether there was one.
+func (v *HistogramFloat64Vec) Delete(values ...string) bool {
+	return v.vec.delete(values)
+}
+
+// Reset unregisters every HistogramFloat64, calling the eviction hook for
+// each.
+func (v *HistogramFloat64Vec) Reset() {
+	v.vec.reset()
+}
+
+// Each calls f with a snapshot of each HistogramFloat64 and its label
+// values, in order of label values.
+func (v *HistogramFloat64Vec) Each(f func([]string, HistogramFloat64)) {
+	v.vec.each(func(values []string, i interface{}) {
+		f(values, i.(HistogramFloat64).Snapshot())
+	})
+}
+
+// OnEvict sets a function to be called with each HistogramFloat64
+// unregistered by Delete or Reset and its label values.
+func (v *HistogramFloat64Vec) OnEvict(f func([]string, HistogramFloat64)) {
+	v.vec.setOnEvict(func(values []string, i interface{}) {
+		f(values, i.(HistogramFloat64))
+	})
+}
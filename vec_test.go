@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestCounterVec(t *testing.T) {
+	r := NewRegistry()
+	v := NewCounterVec("requests", r, "method", "code")
+	v.WithLabelValues("GET", "200").Inc(2)
+	v.WithLabelValues("GET", "200").Inc(1)
+	v.WithLabelValues("POST", "500").Inc(1)
+	if c, ok := r.Get("requests;code=200;method=GET").(Counter); !ok || 3 != c.Count() {
+		t.Fatal(r.Get("requests;code=200;method=GET"))
+	}
+
+	var values [][]string
+	var counts []int64
+	v.Each(func(vs []string, c Counter) {
+		values = append(values, vs)
+		counts = append(counts, c.Count())
+	})
+	if want := [][]string{{"GET", "200"}, {"POST", "500"}}; !reflect.DeepEqual(want, values) {
+		t.Errorf("values: %v != %v\n", want, values)
+	}
+	if want := []int64{3, 1}; !reflect.DeepEqual(want, counts) {
+		t.Errorf("counts: %v != %v\n", want, counts)
+	}
+
+	var evicted []string
+	v.OnEvict(func(vs []string, c Counter) {
+		evicted = append(evicted, vs[0])
+	})
+	if !v.Delete("GET", "200") {
+		t.Error("v.Delete(\"GET\", \"200\"): false")
+	}
+	if v.Delete("GET", "200") {
+		t.Error("v.Delete(\"GET\", \"200\") again: true")
+	}
+	if nil != r.Get("requests;code=200;method=GET") {
+		t.Error("requests;code=200;method=GET still registered")
+	}
+	if c := v.WithLabelValues("GET", "200"); 0 != c.Count() {
+		t.Errorf("c.Count() after Delete: 0 != %v\n", c.Count())
+	}
+	v.Reset()
+	sort.Strings(evicted)
+	if want := []string{"GET", "GET", "POST"}; !reflect.DeepEqual(want, evicted) {
+		t.Errorf("evicted: %v != %v\n", want, evicted)
+	}
+	if nil != r.Get("requests;code=500;method=POST") {
+		t.Error("requests;code=500;method=POST still registered")
+	}
+}
+
+func TestCounterVecLabelValues(t *testing.T) {
+	v := NewCounterVec("requests", NewRegistry(), "method")
+	defer func() {
+		if nil == recover() {
+			t.Error("v.WithLabelValues(\"GET\", \"200\") didn't panic")
+		}
+	}()
+	v.WithLabelValues("GET", "200")
+}
+
+func TestCounterVecConflict(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredGauge("requests;method=GET", r)
+	v := NewCounterVec("requests", r, "method")
+	defer func() {
+		if nil == recover() {
+			t.Error("v.WithLabelValues(\"GET\") didn't panic")
+		}
+	}()
+	v.WithLabelValues("GET")
+}
+
+func TestTimerVec(t *testing.T) {
+	r := NewRegistry()
+	v := NewTimerVec("latency", r, "method")
+	v.WithLabelValues("GET").Update(time.Second)
+	if tm, ok := r.Get("latency;method=GET").(Timer); !ok || 1 != tm.Count() {
+		t.Fatal(r.Get("latency;method=GET"))
+	}
+	v.Each(func(vs []string, tm Timer) {
+		if _, ok := tm.(*TimerSnapshot); !ok {
+			t.Errorf("Each passed a %T\n", tm)
+		}
+	})
+}
+
+func TestHistogramFloat64Vec(t *testing.T) {
+	r := NewRegistry()
+	v := NewHistogramFloat64Vec("size", r, func() SampleFloat64 { return NewUniformSampleFloat64(10) }, "route")
+	v.WithLabelValues("/a").Update(1.5)
+	v.WithLabelValues("/b").Update(2.5)
+	if h, ok := r.Get("size;route=/a").(HistogramFloat64); !ok || 1.5 != h.Max() {
+		t.Fatal(r.Get("size;route=/a"))
+	}
+	if h := v.WithLabelValues("/b"); 1 != h.Count() {
+		t.Errorf("h.Count(): 1 != %v\n", h.Count())
+	}
+}
@@ -0,0 +1,151 @@
+package metrics
+
+import "sync"
+
+// Visitor receives each metric in a registry from VisitSnapshots.  Counters
+// and gauges are read directly, without taking a Snapshot, so that visiting
+// them doesn't allocate.  Histograms, meters and timers are passed live so
+// that the Visitor can read just what it needs or take a Snapshot for
+// consistent values.  Uint64Counters and BigCounters are visited as
+// Counters, their counts capped at math.MaxInt64.  MovingAverages and
+// RateGauges are visited as GaugeFloat64s, and Cardinalities and BoolGauges
+// as Gauges.  EnumGauges are visited as a Gauge per
+// state, named by appending the state to the name.  SummaryFloat64s are
+// visited as a Counter of their count and a GaugeFloat64 per statistic, named
+// likewise.  InfoMetrics aren't visited.
+type Visitor interface {
+	VisitCounter(name string, count int64)
+	VisitGaugeCounter(name string, count int64)
+	VisitGauge(name string, value int64)
+	VisitGaugeFloat64(name string, value float64)
+	VisitHealthcheck(name string, err error)
+	VisitHistogram(name string, h Histogram)
+	VisitHistogramFloat64(name string, h HistogramFloat64)
+	VisitMeter(name string, m Meter)
+	VisitTimer(name string, t Timer)
+}
+
+// NilVisitor is a no-op Visitor, to be embedded in Visitors which only care
+// about some kinds of metric.
+type NilVisitor struct{}
+
+// VisitCounter is a no-op.
+func (NilVisitor) VisitCounter(string, int64) {}
+
+// VisitGaugeCounter is a no-op.
+func (NilVisitor) VisitGaugeCounter(string, int64) {}
+
+// VisitGauge is a no-op.
+func (NilVisitor) VisitGauge(string, int64) {}
+
+// VisitGaugeFloat64 is a no-op.
+func (NilVisitor) VisitGaugeFloat64(string, float64) {}
+
+// VisitHealthcheck is a no-op.
+func (NilVisitor) VisitHealthcheck(string, error) {}
+
+// VisitHistogram is a no-op.
+func (NilVisitor) VisitHistogram(string, Histogram) {}
+
+// VisitHistogramFloat64 is a no-op.
+func (NilVisitor) VisitHistogramFloat64(string, HistogramFloat64) {}
+
+// VisitMeter is a no-op.
+func (NilVisitor) VisitMeter(string, Meter) {}
+
+// VisitTimer is a no-op.
+func (NilVisitor) VisitTimer(string, Timer) {}
+
+// VisitSnapshots calls the Visitor for each metric registered in the
+// DefaultRegistry.
+func VisitSnapshots(v Visitor) {
+	DefaultRegistry.VisitSnapshots(v)
+}
+
+// visitMetric calls the Visitor method for the kind of metric i is, if any.
+// Healthchecks are checked first.
+func visitMetric(v Visitor, name string, i interface{}) {
+	switch metric := i.(type) {
+	case Counter:
+		v.VisitCounter(name, metric.Count())
+	case Uint64Counter:
+		v.VisitCounter(name, clampInt64(metric.Count()))
+	case BigCounter:
+		v.VisitCounter(name, bigInt64(metric.Count()))
+	case GaugeCounter:
+		v.VisitGaugeCounter(name, metric.Count())
+	case Gauge:
+		v.VisitGauge(name, metric.Value())
+	case GaugeFloat64:
+		v.VisitGaugeFloat64(name, metric.Value())
+	case MovingAverage:
+		v.VisitGaugeFloat64(name, metric.Value())
+	case RateGauge:
+		v.VisitGaugeFloat64(name, metric.Rate())
+	case Cardinality:
+		v.VisitGauge(name, int64(metric.Estimate()))
+	case BoolGauge:
+		v.VisitGauge(name, boolGaugeValue(metric))
+	case EnumGauge:
+		eachEnumState(metric, func(state string, value int64) {
+			v.VisitGauge(name+"."+state, value)
+		})
+	case Healthcheck:
+		metric.Check()
+		v.VisitHealthcheck(name, metric.Error())
+	case Histogram:
+		v.VisitHistogram(name, metric)
+	case HistogramFloat64:
+		v.VisitHistogramFloat64(name, metric)
+	case SummaryFloat64:
+		s := metric.Snapshot()
+		v.VisitCounter(name+".count", s.Count())
+		v.VisitGaugeFloat64(name+".min", s.Min())
+		v.VisitGaugeFloat64(name+".max", s.Max())
+		v.VisitGaugeFloat64(name+".mean", s.Mean())
+		v.VisitGaugeFloat64(name+".stddev", s.StdDev())
+		v.VisitGaugeFloat64(name+".sum", s.Sum())
+	case Meter:
+		v.VisitMeter(name, metric)
+	case Timer:
+		v.VisitTimer(name, metric)
+	}
+}
+
+// visitEach implements VisitSnapshots in terms of Each, for registries
+// which can't do better.
+func visitEach(r Registry, v Visitor) {
+	r.Each(func(name string, i interface{}) {
+		visitMetric(v, name, i)
+	})
+}
+
+// visitBuffers holds the slices StandardRegistry.VisitSnapshots copies the
+// registry into, so that they're reused from one flush to the next.
+var visitBuffers = sync.Pool{New: func() interface{} { return new([]namedMetric) }}
+
+// Call the given Visitor for each registered metric, without allocating
+// once the registry has been visited a few times.
+func (r *StandardRegistry) VisitSnapshots(v Visitor) {
+	if r.Frozen() {
+		for name, i := range r.metrics {
+			visitMetric(v, name, i)
+		}
+		return
+	}
+	buf := visitBuffers.Get().(*[]namedMetric)
+	metrics := (*buf)[:0]
+	r.mutex.RLock()
+	for name, i := range r.metrics {
+		metrics = append(metrics, namedMetric{name, i})
+	}
+	r.mutex.RUnlock()
+	for _, m := range metrics {
+		visitMetric(v, m.name, m.m)
+	}
+	for i := range metrics {
+		metrics[i] = namedMetric{}
+	}
+	*buf = metrics[:0]
+	visitBuffers.Put(buf)
+}
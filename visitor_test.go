@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func BenchmarkVisitSnapshots(b *testing.B) {
+	r := NewRegistry()
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		NewRegisteredCounter(name+".count", r).Inc(1000)
+		NewRegisteredGaugeFloat64(name+".value", r).Update(1000)
+	}
+	var v sumVisitor
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.VisitSnapshots(&v)
+	}
+}
+
+func BenchmarkEachSnapshot(b *testing.B) {
+	r := NewRegistry()
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		NewRegisteredCounter(name+".count", r).Inc(1000)
+		NewRegisteredGaugeFloat64(name+".value", r).Update(1000)
+	}
+	var sum float64
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Each(func(name string, i interface{}) {
+			switch metric := i.(type) {
+			case Counter:
+				sum += float64(metric.Snapshot().Count())
+			case GaugeFloat64:
+				sum += metric.Snapshot().Value()
+			}
+		})
+	}
+}
+
+type sumVisitor struct {
+	NilVisitor
+	sum float64
+}
+
+func (v *sumVisitor) VisitCounter(name string, count int64) { v.sum += float64(count) }
+
+func (v *sumVisitor) VisitGaugeFloat64(name string, value float64) { v.sum += value }
+
+type recordingVisitor struct {
+	NilVisitor
+	visited map[string]interface{}
+}
+
+func (v *recordingVisitor) VisitCounter(name string, count int64)    { v.visited[name] = count }
+func (v *recordingVisitor) VisitGauge(name string, value int64)      { v.visited[name] = value }
+func (v *recordingVisitor) VisitHealthcheck(name string, err error)  { v.visited[name] = err }
+func (v *recordingVisitor) VisitTimer(name string, t Timer)          { v.visited[name] = t.Count() }
+func (v *recordingVisitor) VisitGaugeFloat64(name string, f float64) { v.visited[name] = f }
+
+func TestVisitSnapshots(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("counter", r).Inc(47)
+	NewRegisteredGauge("gauge", r).Update(48)
+	NewRegisteredGaugeFloat64("gauge-float64", r).Update(49.5)
+	NewRegisteredTimer("timer", r).Update(1)
+	err := errors.New("unhealthy")
+	r.Register("healthcheck", NewHealthcheck(func(h Healthcheck) { h.Unhealthy(err) }))
+	NewRegisteredMeter("meter", r)
+
+	v := &recordingVisitor{visited: make(map[string]interface{})}
+	r.VisitSnapshots(v)
+	for name, want := range map[string]interface{}{
+		"counter":       int64(47),
+		"gauge":         int64(48),
+		"gauge-float64": 49.5,
+		"timer":         int64(1),
+		"healthcheck":   err,
+	} {
+		if got := v.visited[name]; want != got {
+			t.Errorf("%s: %v != %v\n", name, want, got)
+		}
+	}
+	if 5 != len(v.visited) {
+		t.Errorf("visited: %v\n", v.visited)
+	}
+
+	v = &recordingVisitor{visited: make(map[string]interface{})}
+	NewPrefixedChildRegistry(r, "gauge").VisitSnapshots(v)
+	if 2 != len(v.visited) {
+		t.Errorf("visited: %v\n", v.visited)
+	}
+}
+
+func TestVisitSnapshotsAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector's instrumentation allocates")
+	}
+	r := NewRegistry()
+	NewRegisteredCounter("counter", r).Inc(1000)
+	NewRegisteredGaugeFloat64("gauge", r).Update(1000)
+	c := NewRegisteredCardinality("cardinality", r)
+	for i := 0; i < 1000; i++ {
+		c.Observe(strconv.Itoa(i))
+	}
+	var v sumVisitor
+	if allocs := testing.AllocsPerRun(100, func() { r.VisitSnapshots(&v) }); 0 != allocs {
+		t.Errorf("allocs: 0 != %v\n", allocs)
+	}
+}
@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"sort"
@@ -10,14 +11,41 @@ import (
 // Write sorts writes each metric in the given registry periodically to the
 // given io.Writer.
 func Write(r Registry, d time.Duration, w io.Writer) {
-	for _ = range time.Tick(d) {
-		WriteOnce(r, w)
-	}
+	WriteScaled(r, d, 0, w)
+}
+
+// WriteScaled is like Write but prints timings in `scale` units (eg
+// time.Millisecond) rather than nanos.
+func WriteScaled(r Registry, d time.Duration, scale time.Duration, w io.Writer) {
+	NewWriteReporter(r, d, scale, w).Run(context.Background())
+}
+
+// NewWriteReporter constructs a new Reporter which writes just as
+// WriteScaled does.
+func NewWriteReporter(r Registry, d time.Duration, scale time.Duration, w io.Writer) *Reporter {
+	return newExporterReporter(r, NewWriteExporter(scale, w), SchedulerConfig{Interval: d})
+}
+
+// NewWriteExporter constructs a new Exporter which writes the registry it's
+// given as WriteOnceScaled does, for use with a Scheduler.
+func NewWriteExporter(scale time.Duration, w io.Writer) Exporter {
+	return ExporterFunc(func(r Registry) error {
+		WriteOnceScaled(r, scale, w)
+		return nil
+	})
 }
 
 // WriteOnce sorts and writes metrics in the given registry to the given
 // io.Writer.
 func WriteOnce(r Registry, w io.Writer) {
+	WriteOnceScaled(r, 0, w)
+}
+
+// WriteOnceScaled sorts and writes metrics in the given registry to the given
+// io.Writer, printing timings in `scale` units unless the timer carries its
+// own DurationUnit.  With a scale of zero, timers without a DurationUnit are
+// written in nanoseconds without a suffix, as WriteOnce writes them.
+func WriteOnceScaled(r Registry, scale time.Duration, w io.Writer) {
 	var namedMetrics namedMetricSlice
 	r.Each(func(name string, i interface{}) {
 		namedMetrics = append(namedMetrics, namedMetric{name, i})
@@ -29,6 +57,12 @@ func WriteOnce(r Registry, w io.Writer) {
 		case Counter:
 			fmt.Fprintf(w, "counter %s\n", namedMetric.name)
 			fmt.Fprintf(w, "  count:       %9d\n", metric.Count())
+		case Uint64Counter:
+			fmt.Fprintf(w, "counter %s\n", namedMetric.name)
+			fmt.Fprintf(w, "  count:       %9d\n", metric.Count())
+		case BigCounter:
+			fmt.Fprintf(w, "counter %s\n", namedMetric.name)
+			fmt.Fprintf(w, "  count:       %9d\n", metric.Count())
 		case GaugeCounter:
 			fmt.Fprintf(w, "counter %s\n", namedMetric.name)
 			fmt.Fprintf(w, "  value:       %9d\n", metric.Count())
@@ -38,6 +72,28 @@ func WriteOnce(r Registry, w io.Writer) {
 		case GaugeFloat64:
 			fmt.Fprintf(w, "gauge %s\n", namedMetric.name)
 			fmt.Fprintf(w, "  value:       %f\n", metric.Value())
+		case MovingAverage:
+			fmt.Fprintf(w, "gauge %s\n", namedMetric.name)
+			fmt.Fprintf(w, "  value:       %f\n", metric.Value())
+		case RateGauge:
+			fmt.Fprintf(w, "gauge %s\n", namedMetric.name)
+			fmt.Fprintf(w, "  value:       %f\n", metric.Rate())
+		case Cardinality:
+			fmt.Fprintf(w, "gauge %s\n", namedMetric.name)
+			fmt.Fprintf(w, "  value:       %9d\n", metric.Snapshot().Estimate())
+		case BoolGauge:
+			fmt.Fprintf(w, "gauge %s\n", namedMetric.name)
+			fmt.Fprintf(w, "  value:       %9d\n", boolGaugeValue(metric))
+		case EnumGauge:
+			fmt.Fprintf(w, "enum %s\n", namedMetric.name)
+			eachEnumState(metric, func(state string, v int64) {
+				fmt.Fprintf(w, "  %-13s%9d\n", state+":", v)
+			})
+		case InfoMetric:
+			fmt.Fprintf(w, "info %s\n", namedMetric.name)
+			eachInfoLabel(metric, func(k, v string) {
+				fmt.Fprintf(w, "  %-13s%s\n", k+":", v)
+			})
 		case Healthcheck:
 			metric.Check()
 			fmt.Fprintf(w, "healthcheck %s\n", namedMetric.name)
@@ -70,6 +126,15 @@ func WriteOnce(r Registry, w io.Writer) {
 			fmt.Fprintf(w, "  95%%:         %12.2f\n", ps[2])
 			fmt.Fprintf(w, "  99%%:         %12.2f\n", ps[3])
 			fmt.Fprintf(w, "  99.9%%:       %12.2f\n", ps[4])
+		case SummaryFloat64:
+			s := metric.Snapshot()
+			fmt.Fprintf(w, "summary %s\n", namedMetric.name)
+			fmt.Fprintf(w, "  count:       %9d\n", s.Count())
+			fmt.Fprintf(w, "  min:         %12.2f\n", s.Min())
+			fmt.Fprintf(w, "  max:         %12.2f\n", s.Max())
+			fmt.Fprintf(w, "  mean:        %12.2f\n", s.Mean())
+			fmt.Fprintf(w, "  stddev:      %12.2f\n", s.StdDev())
+			fmt.Fprintf(w, "  sum:         %12.2f\n", s.Sum())
 		case Meter:
 			m := metric.Snapshot()
 			fmt.Fprintf(w, "meter %s\n", namedMetric.name)
@@ -80,18 +145,20 @@ func WriteOnce(r Registry, w io.Writer) {
 			fmt.Fprintf(w, "  mean rate:   %12.2f\n", m.RateMean())
 		case Timer:
 			t := metric.Snapshot()
+			ts := newTimerScale(t, scale)
+			suffix := ts.suffix()
 			ps := t.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
 			fmt.Fprintf(w, "timer %s\n", namedMetric.name)
 			fmt.Fprintf(w, "  count:       %9d\n", t.Count())
-			fmt.Fprintf(w, "  min:         %9d\n", t.Min())
-			fmt.Fprintf(w, "  max:         %9d\n", t.Max())
-			fmt.Fprintf(w, "  mean:        %12.2f\n", t.Mean())
-			fmt.Fprintf(w, "  stddev:      %12.2f\n", t.StdDev())
-			fmt.Fprintf(w, "  median:      %12.2f\n", ps[0])
-			fmt.Fprintf(w, "  75%%:         %12.2f\n", ps[1])
-			fmt.Fprintf(w, "  95%%:         %12.2f\n", ps[2])
-			fmt.Fprintf(w, "  99%%:         %12.2f\n", ps[3])
-			fmt.Fprintf(w, "  99.9%%:       %12.2f\n", ps[4])
+			fmt.Fprintf(w, "  min:         %s%s\n", ts.format(t.Min(), "%9d", "%12.2f"), suffix)
+			fmt.Fprintf(w, "  max:         %s%s\n", ts.format(t.Max(), "%9d", "%12.2f"), suffix)
+			fmt.Fprintf(w, "  mean:        %12.2f%s\n", ts.scale(t.Mean()), suffix)
+			fmt.Fprintf(w, "  stddev:      %12.2f%s\n", ts.scale(t.StdDev()), suffix)
+			fmt.Fprintf(w, "  median:      %12.2f%s\n", ts.scale(ps[0]), suffix)
+			fmt.Fprintf(w, "  75%%:         %12.2f%s\n", ts.scale(ps[1]), suffix)
+			fmt.Fprintf(w, "  95%%:         %12.2f%s\n", ts.scale(ps[2]), suffix)
+			fmt.Fprintf(w, "  99%%:         %12.2f%s\n", ts.scale(ps[3]), suffix)
+			fmt.Fprintf(w, "  99.9%%:       %12.2f%s\n", ts.scale(ps[4]), suffix)
 			fmt.Fprintf(w, "  1-min rate:  %12.2f\n", t.Rate1())
 			fmt.Fprintf(w, "  5-min rate:  %12.2f\n", t.Rate5())
 			fmt.Fprintf(w, "  15-min rate: %12.2f\n", t.Rate15())
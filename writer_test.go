@@ -1,8 +1,11 @@
 package metrics
 
 import (
+	"bytes"
 	"sort"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestMetricsSorting(t *testing.T) {
@@ -20,3 +23,35 @@ func TestMetricsSorting(t *testing.T) {
 		}
 	}
 }
+
+func TestWriteOnceScaled(t *testing.T) {
+	r := NewRegistry()
+	tm := NewTimer()
+	tm.Update(2 * time.Millisecond)
+	r.Register("timer", tm)
+	b := &bytes.Buffer{}
+	WriteOnceScaled(r, time.Millisecond, b)
+	if s := b.String(); !strings.Contains(s, "  max:                 2.00ms\n") {
+		t.Errorf("unexpected output:\n%s", s)
+	}
+}
+
+func TestWriteOnceTimer(t *testing.T) {
+	r := NewRegistry()
+	tm := NewTimer()
+	tm.Update(2 * time.Millisecond)
+	r.Register("timer", tm)
+	b := &bytes.Buffer{}
+	WriteOnce(r, b)
+	s := b.String()
+	for _, line := range []string{
+		"  min:           2000000\n",
+		"  max:           2000000\n",
+		"  mean:          2000000.00\n",
+		"  99.9%:         2000000.00\n",
+	} {
+		if !strings.Contains(s, line) {
+			t.Errorf("no %q in output:\n%s", line, s)
+		}
+	}
+}